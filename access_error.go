@@ -22,8 +22,6 @@
 package fosite
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -32,27 +30,8 @@ func (f *Fosite) WriteAccessError(rw http.ResponseWriter, _ AccessRequester, err
 }
 
 func (f *Fosite) writeJsonError(rw http.ResponseWriter, err error) {
-	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
 	rw.Header().Set("Cache-Control", "no-store")
 	rw.Header().Set("Pragma", "no-cache")
 
-	rfcerr := ErrorToRFC6749Error(err)
-	if !f.SendDebugMessagesToClients {
-		rfcerr = rfcerr.Sanitize()
-	}
-
-	js, err := json.Marshal(rfcerr)
-	if err != nil {
-		if f.SendDebugMessagesToClients {
-			errorMessage := EscapeJSONString(err.Error())
-			http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
-		} else {
-			http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
-		}
-		return
-	}
-
-	rw.WriteHeader(rfcerr.Code)
-	// ignoring the error because the connection is broken when it happens
-	_, _ = rw.Write(js)
+	f.writeRFC6749Error(rw, f.clientFacingError(err))
 }
@@ -88,7 +88,6 @@ func TestWriteAccessError_RFC6749(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Equal(t, c.code, params.Error)
-			assert.Equal(t, c.err.Hint, params.Hint)
 
 			expectDescription := c.err.Description
 			if c.err.Hint != "" {
@@ -96,9 +95,11 @@ func TestWriteAccessError_RFC6749(t *testing.T) {
 			}
 
 			if !c.debug {
-				assert.Equal(t, expectDescription, params.Description)
+				assert.Equal(t, c.err.Description, params.Description)
+				assert.Empty(t, params.Hint)
 				assert.Empty(t, params.Debug)
 			} else {
+				assert.Equal(t, c.err.Hint, params.Hint)
 				assert.Equal(t, expectDescription+" "+c.expectDebugMessage, params.Description)
 				assert.Equal(t, c.expectDebugMessage, params.Debug)
 			}
@@ -57,16 +57,21 @@ import (
 func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session Session) (AccessRequester, error) {
 	var err error
 	accessRequest := NewAccessRequest(session)
+	if f.IDGenerator != nil {
+		accessRequest.SetID(f.IDGenerator.New())
+	}
 
 	if r.Method != "POST" {
 		return accessRequest, errors.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s', expected 'POST'.", r.Method))
-	} else if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
-		return accessRequest, errors.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithCause(err).WithDebug(err.Error()))
+	} else if err := f.parseRequestForm(r); err != nil {
+		return accessRequest, err
 	} else if len(r.PostForm) == 0 {
 		return accessRequest, errors.WithStack(ErrInvalidRequest.WithHint("The POST body can not be empty."))
 	}
 
 	accessRequest.Form = r.PostForm
+	accessRequest.SetIssuer(f.resolveIssuer(ctx, r))
+	ctx = withRequesterIssuer(ctx, accessRequest)
 	if session == nil {
 		return accessRequest, errors.New("Session must not be nil")
 	}
@@ -75,7 +80,11 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 	accessRequest.SetRequestedAudience(GetAudiences(r.PostForm))
 	accessRequest.GrantTypes = RemoveEmpty(strings.Split(r.PostForm.Get("grant_type"), " "))
 	if len(accessRequest.GrantTypes) < 1 {
-		return accessRequest, errors.WithStack(ErrInvalidRequest.WithHint("Request parameter 'grant_type' is missing"))
+		return accessRequest, errors.WithStack(ErrInvalidRequest.WithHint("Request parameter 'grant_type' is missing").WithParameter("grant_type"))
+	}
+
+	if err := f.checkRateLimit(ctx, TokenEndpoint, r); err != nil {
+		return accessRequest, err
 	}
 
 	client, err := f.AuthenticateClient(ctx, r, r.PostForm)
@@ -84,6 +93,39 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 	}
 	accessRequest.Client = client
 
+	// The authorization_code and refresh_token grants use the scopes of the original request if scope is omitted.
+	if !accessRequest.GetGrantTypes().HasOneOf("authorization_code", "refresh_token") {
+		accessRequest.SetRequestedScopes(f.withDefaultScopes(client, accessRequest.GetRequestedScopes()))
+	}
+
+	if err := f.validateKnownScopes(accessRequest.GetRequestedScopes()); err != nil {
+		return accessRequest, err
+	}
+
+	if err := f.validateClientGrantTypes(accessRequest); err != nil {
+		return accessRequest, f.hideGrantTypeError(err)
+	}
+
+	if session, ok := session.(CertificateBoundSession); ok && isTLSClientAuthMethod(client) {
+		cert, _, err := f.clientCertificateFromRequest(r)
+		if err != nil {
+			return accessRequest, err
+		} else if cert != nil {
+			session.SetCertificateThumbprint(CertificateThumbprint(cert))
+		}
+	}
+
+	dpopThumbprint, err := f.ValidateDPoPProof(ctx, r, "")
+	if err != nil {
+		return accessRequest, err
+	}
+
+	// Handlers restoring the session of a grant, for example the refresh token grant, compare the key of the proof
+	// with the key the grant is bound to.
+	if session, ok := session.(DPoPBoundSession); ok && dpopThumbprint != "" {
+		session.SetDPoPKeyThumbprint(dpopThumbprint)
+	}
+
 	var found = false
 	for _, loader := range f.TokenEndpointHandlers {
 		if err := loader.HandleTokenEndpointRequest(ctx, accessRequest); err == nil {
@@ -91,12 +133,55 @@ func (f *Fosite) NewAccessRequest(ctx context.Context, r *http.Request, session
 		} else if errors.Is(err, ErrUnknownRequest) {
 			// do nothing
 		} else if err != nil {
-			return accessRequest, err
+			return accessRequest, f.hideGrantTypeError(err)
 		}
 	}
 
 	if !found {
-		return nil, errors.WithStack(ErrInvalidRequest)
+		return nil, f.hideGrantTypeError(errors.WithStack(ErrUnsupportedGrantType.WithHintf("The authorization grant type '%s' is not supported by this authorization server.", strings.Join(accessRequest.GrantTypes, " ")).WithParameter("grant_type")))
+	}
+
+	// The handlers may have replaced the session, for example with the session of the authorization code.
+	if session, ok := accessRequest.GetSession().(GrantTypeSession); ok {
+		session.SetGrantType(strings.Join(accessRequest.GrantTypes, " "))
+	}
+
+	if session, ok := accessRequest.GetSession().(DPoPBoundSession); ok && dpopThumbprint != "" {
+		session.SetDPoPKeyThumbprint(dpopThumbprint)
 	}
+
 	return accessRequest, nil
 }
+
+// hideGrantTypeError returns invalid_grant instead of unauthorized_client and unsupported_grant_type if
+// HideUnsupportedGrantTypes is set, so that the response does not reveal which grant types are supported.
+func (f *Fosite) hideGrantTypeError(err error) error {
+	if !f.HideUnsupportedGrantTypes {
+		return err
+	} else if !errors.Is(err, ErrUnauthorizedClient) && !errors.Is(err, ErrUnsupportedGrantType) {
+		return err
+	}
+
+	return errors.WithStack(ErrInvalidGrant.WithHint("The provided authorization grant is invalid.").WithCause(err).WithDebug(err.Error()))
+}
+
+// validateClientGrantTypes returns ErrUnauthorizedClient if the client is not allowed to use a requested grant type
+// which one of the token endpoint handlers supports. Grant types no handler supports are left to the handlers, which
+// reject them with ErrUnsupportedGrantType.
+func (f *Fosite) validateClientGrantTypes(request *AccessRequest) error {
+	for _, handler := range f.TokenEndpointHandlers {
+		handler, ok := handler.(CapabilitiesHandler)
+		if !ok {
+			continue
+		}
+
+		for _, grantType := range handler.Capabilities().GrantTypes {
+			if request.GrantTypes.Has(grantType) {
+				if err := ValidateClientGrantType(request.Client, grantType); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
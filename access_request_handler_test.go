@@ -184,6 +184,20 @@ func TestNewAccessRequest(t *testing.T) {
 			form: url.Values{
 				"grant_type": {"foo"},
 			},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+				client.Public = true
+			},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			header: http.Header{
+				"Authorization": {basicAuth("foo", "")},
+			},
+			method: "POST",
+			form: url.Values{
+				"grant_type": {"foo"},
+			},
 			mock: func() {
 				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
 				client.Public = true
@@ -206,7 +220,7 @@ func TestNewAccessRequest(t *testing.T) {
 				Method:   c.method,
 			}
 			c.mock()
-			ctx := NewContext()
+			ctx := context.TODO()
 			fosite.TokenEndpointHandlers = c.handlers
 			ar, err := fosite.NewAccessRequest(ctx, r, new(DefaultSession))
 
@@ -221,6 +235,104 @@ func TestNewAccessRequest(t *testing.T) {
 	}
 }
 
+func TestNewAccessRequestGrantTypeErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := internal.NewMockStorage(ctrl)
+	handler := internal.NewMockTokenEndpointHandler(ctrl)
+	defer ctrl.Finish()
+
+	client := &DefaultClient{Public: true}
+	for k, c := range []struct {
+		d         string
+		hide      bool
+		handleErr error
+		expectErr error
+	}{
+		{
+			d:         "should return unsupported_grant_type because no handler supports the grant type",
+			handleErr: ErrUnknownRequest,
+			expectErr: ErrUnsupportedGrantType,
+		},
+		{
+			d:         "should return unauthorized_client because the client is not allowed to use the grant type",
+			handleErr: ErrUnauthorizedClient,
+			expectErr: ErrUnauthorizedClient,
+		},
+		{
+			d:         "should return invalid_grant instead of unsupported_grant_type",
+			hide:      true,
+			handleErr: ErrUnknownRequest,
+			expectErr: ErrInvalidGrant,
+		},
+		{
+			d:         "should return invalid_grant instead of unauthorized_client",
+			hide:      true,
+			handleErr: ErrUnauthorizedClient,
+			expectErr: ErrInvalidGrant,
+		},
+		{
+			d:         "should not hide other errors",
+			hide:      true,
+			handleErr: ErrInvalidScope,
+			expectErr: ErrInvalidScope,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+			handler.EXPECT().HandleTokenEndpointRequest(gomock.Any(), gomock.Any()).Return(c.handleErr)
+
+			f := &Fosite{Store: store, TokenEndpointHandlers: TokenEndpointHandlers{handler}, HideUnsupportedGrantTypes: c.hide}
+			form := url.Values{"grant_type": {"foo"}, "client_id": {"foo"}}
+			_, err := f.NewAccessRequest(NewContext(), &http.Request{Header: http.Header{}, PostForm: form, Form: form, Method: "POST"}, new(DefaultSession))
+			assert.EqualError(t, err, c.expectErr.Error())
+		})
+	}
+}
+
+type capabilitiesTokenEndpointHandler struct {
+	TokenEndpointHandler
+	grantTypes []string
+}
+
+func (h *capabilitiesTokenEndpointHandler) Capabilities() Capabilities {
+	return Capabilities{GrantTypes: h.grantTypes}
+}
+
+func TestNewAccessRequestValidatesClientGrantTypes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := internal.NewMockStorage(ctrl)
+	handler := internal.NewMockTokenEndpointHandler(ctrl)
+	defer ctrl.Finish()
+
+	client := &DefaultClient{Public: true, GrantTypes: []string{"client_credentials"}}
+	f := &Fosite{Store: store, TokenEndpointHandlers: TokenEndpointHandlers{&capabilitiesTokenEndpointHandler{
+		TokenEndpointHandler: handler,
+		grantTypes:           []string{"password", "client_credentials"},
+	}}}
+
+	newRequest := func(grantType string) *http.Request {
+		form := url.Values{"grant_type": {grantType}, "client_id": {"foo"}}
+		return &http.Request{Header: http.Header{}, PostForm: form, Form: form, Method: "POST"}
+	}
+
+	// The handlers are not called if the client may not use the grant type.
+	store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+	_, err := f.NewAccessRequest(NewContext(), newRequest("password"), new(DefaultSession))
+	require.EqualError(t, err, ErrUnauthorizedClient.Error())
+	assert.Equal(t, "The OAuth 2.0 Client is not allowed to use authorization grant 'password'.", ErrorToRFC6749Error(err).Hint)
+
+	store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+	handler.EXPECT().HandleTokenEndpointRequest(gomock.Any(), gomock.Any()).Return(nil)
+	_, err = f.NewAccessRequest(NewContext(), newRequest("client_credentials"), new(DefaultSession))
+	require.NoError(t, err)
+
+	// Grant types no handler supports are still reported as unsupported.
+	store.EXPECT().GetClient(gomock.Any(), gomock.Eq("foo")).Return(client, nil)
+	handler.EXPECT().HandleTokenEndpointRequest(gomock.Any(), gomock.Any()).Return(ErrUnknownRequest)
+	_, err = f.NewAccessRequest(NewContext(), newRequest("foo"), new(DefaultSession))
+	require.EqualError(t, err, ErrUnsupportedGrantType.Error())
+}
+
 func basicAuth(username, password string) string {
 	return "Basic " + base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
 }
@@ -42,7 +42,11 @@ func (a *AccessResponse) SetScopes(scopes Arguments) {
 	a.SetExtra("scope", strings.Join(scopes, " "))
 }
 
+// SetExpiresIn sets "expires_in" to the whole seconds of the lifetime of the access token, or zero if it is negative.
 func (a *AccessResponse) SetExpiresIn(expiresIn time.Duration) {
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
 	a.SetExtra("expires_in", int64(expiresIn/time.Second))
 }
 
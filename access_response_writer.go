@@ -31,6 +31,7 @@ func (f *Fosite) NewAccessResponse(ctx context.Context, requester AccessRequeste
 	var err error
 	var tk TokenEndpointHandler
 
+	ctx = withRequesterIssuer(ctx, requester)
 	response := NewAccessResponse()
 	for _, tk = range f.TokenEndpointHandlers {
 		if err = tk.PopulateTokenEndpointResponse(ctx, requester, response); err == nil {
@@ -46,5 +47,30 @@ func (f *Fosite) NewAccessResponse(ctx context.Context, requester AccessRequeste
 		return nil, errors.WithStack(ErrServerError.WithHint("An internal server occurred while trying to complete the request.").WithDebug("Access token or token type not set by TokenEndpointHandlers."))
 	}
 
+	// Tokens bound to a DPoP key must be presented with a DPoP proof, see RFC 9449 Section 5.
+	if requester != nil {
+		if session, ok := requester.GetSession().(DPoPBoundSession); ok && session.GetDPoPKeyThumbprint() != "" {
+			response.SetTokenType(DPoPAccessTokenType)
+		}
+	}
+
+	f.completeAccessResponse(requester, response)
 	return response, nil
 }
+
+// completeAccessResponse adds the parameters of https://tools.ietf.org/html/rfc6749#section-5.1 the token endpoint
+// handlers did not set: "expires_in" if the access token expires, and "scope" if the granted scope differs from the
+// requested one.
+func (f *Fosite) completeAccessResponse(requester AccessRequester, response AccessResponder) {
+	if requester == nil || requester.GetSession() == nil {
+		return
+	}
+
+	if expiresAt := requester.GetSession().GetExpiresAt(AccessToken); response.GetExtra("expires_in") == nil && !expiresAt.IsZero() {
+		response.SetExpiresIn(expiresAt.Sub(f.Clock.Now()))
+	}
+
+	if response.GetExtra("scope") == nil && !requester.GetRequestedScopes().Matches(requester.GetGrantedScopes()...) {
+		response.SetScopes(requester.GetGrantedScopes())
+	}
+}
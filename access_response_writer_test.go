@@ -25,6 +25,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -103,3 +104,93 @@ func TestNewAccessResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAccessResponseCompletesParameters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	handler := internal.NewMockTokenEndpointHandler(ctrl)
+	defer ctrl.Finish()
+
+	now := time.Now().UTC().Add(-time.Hour).Round(time.Second)
+	f := &Fosite{TokenEndpointHandlers: TokenEndpointHandlers{handler}, Clock: func() time.Time { return now }}
+	for k, c := range []struct {
+		d               string
+		expiresAt       time.Time
+		requestedScopes Arguments
+		grantedScopes   Arguments
+		populate        func(resp AccessResponder)
+		expectExpiresIn func(t *testing.T, expiresIn interface{})
+		expectScope     interface{}
+	}{
+		{
+			d:               "should compute expires_in in whole seconds if the handler did not set it",
+			expiresAt:       now.Add(time.Hour),
+			requestedScopes: Arguments{"foo", "bar"},
+			grantedScopes:   Arguments{"bar", "foo"},
+			expectExpiresIn: func(t *testing.T, expiresIn interface{}) {
+				assert.Equal(t, int64(3600), expiresIn)
+			},
+		},
+		{
+			d:               "should not emit a negative expires_in",
+			expiresAt:       now.Add(-time.Minute),
+			requestedScopes: Arguments{"foo"},
+			grantedScopes:   Arguments{"foo"},
+			expectExpiresIn: func(t *testing.T, expiresIn interface{}) {
+				assert.Equal(t, int64(0), expiresIn)
+			},
+		},
+		{
+			d:               "should not emit expires_in if the access token does not expire",
+			requestedScopes: Arguments{"foo"},
+			grantedScopes:   Arguments{"foo"},
+			expectExpiresIn: func(t *testing.T, expiresIn interface{}) {
+				assert.Nil(t, expiresIn)
+			},
+		},
+		{
+			d:               "should keep the expires_in set by the handler",
+			expiresAt:       now.Add(time.Hour),
+			requestedScopes: Arguments{"foo"},
+			grantedScopes:   Arguments{"foo"},
+			populate: func(resp AccessResponder) {
+				resp.SetExpiresIn(time.Minute)
+			},
+			expectExpiresIn: func(t *testing.T, expiresIn interface{}) {
+				assert.Equal(t, int64(60), expiresIn)
+			},
+		},
+		{
+			d:               "should include the scope of a downscoped response",
+			expiresAt:       now.Add(time.Hour),
+			requestedScopes: Arguments{"foo", "bar"},
+			grantedScopes:   Arguments{"foo"},
+			expectExpiresIn: func(t *testing.T, expiresIn interface{}) {
+				assert.NotNil(t, expiresIn)
+			},
+			expectScope: "foo",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			handler.EXPECT().PopulateTokenEndpointResponse(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ context.Context, _ AccessRequester, resp AccessResponder) {
+				resp.SetAccessToken("foo")
+				resp.SetTokenType("bearer")
+				if c.populate != nil {
+					c.populate(resp)
+				}
+			}).Return(nil)
+
+			session := &DefaultSession{}
+			if !c.expiresAt.IsZero() {
+				session.SetExpiresAt(AccessToken, c.expiresAt)
+			}
+			ar := NewAccessRequest(session)
+			ar.RequestedScope = c.requestedScopes
+			ar.GrantedScope = c.grantedScopes
+
+			resp, err := f.NewAccessResponse(context.Background(), ar)
+			require.NoError(t, err)
+			c.expectExpiresIn(t, resp.GetExtra("expires_in"))
+			assert.Equal(t, c.expectScope, resp.GetExtra("scope"))
+		})
+	}
+}
@@ -22,8 +22,6 @@
 package fosite
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 )
 
@@ -31,27 +29,10 @@ func (f *Fosite) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequest
 	rw.Header().Set("Cache-Control", "no-store")
 	rw.Header().Set("Pragma", "no-cache")
 
-	rfcerr := ErrorToRFC6749Error(err)
-	if !f.SendDebugMessagesToClients {
-		rfcerr = rfcerr.Sanitize()
-	}
+	rfcerr := f.clientFacingError(err)
 
 	if !ar.IsRedirectURIValid() {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(rfcerr)
-		if err != nil {
-			if f.SendDebugMessagesToClients {
-				errorMessage := EscapeJSONString(err.Error())
-				http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
-			} else {
-				http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
-			}
-			return
-		}
-
-		rw.WriteHeader(rfcerr.Code)
-		_, _ = rw.Write(js)
+		f.writeRFC6749Error(rw, rfcerr)
 		return
 	}
 
@@ -60,15 +41,36 @@ func (f *Fosite) WriteAuthorizeError(rw http.ResponseWriter, ar AuthorizeRequest
 	// The endpoint URI MUST NOT include a fragment component.
 	redirectURI.Fragment = ""
 
+	// The state is echoed in error responses as well, see https://tools.ietf.org/html/rfc6749#section-4.1.2.1
 	query := rfcerr.ToValues()
-	query.Add("state", ar.GetState())
+	if state := ar.GetState(); state != "" {
+		query.Set("state", state)
+	}
+
+	responseMode := ar.GetResponseMode()
+	if IsJWTResponseMode(responseMode) {
+		signed, err := f.signAuthorizeResponse(ar, query)
+		if err != nil {
+			f.writeRFC6749Error(rw, f.clientFacingError(err))
+			return
+		}
+		query = signed
+
+		// The flow's default response mode is unknown if the request was rejected before it was handled.
+		defaultMode := ar.GetDefaultResponseMode()
+		if defaultMode == ResponseModeDefault {
+			defaultMode = DefaultResponseModeFor(ar.GetResponseTypes())
+		}
+		responseMode = responseModeTransport(responseMode, defaultMode)
+	}
 
 	var redirectURIString string
-	if ar.GetResponseMode() == ResponseModeFormPost {
-		rw.Header().Add("Content-Type", "text/html;charset=UTF-8")
-		WriteAuthorizeFormPostResponse(redirectURI.String(), query, GetPostFormHTMLTemplate(*f), rw)
+	if responseMode == ResponseModeFormPost || responseMode == ResponseModeWebMessage {
+		if err := f.writeAuthorizeHTMLResponse(rw, responseMode, redirectURI, query); err != nil {
+			f.writeRFC6749Error(rw, f.clientFacingError(err))
+		}
 		return
-	} else if ar.GetResponseMode() == ResponseModeFragment {
+	} else if responseMode == ResponseModeFragment {
 		redirectURIString = redirectURI.String() + "#" + query.Encode()
 	} else {
 		for key, values := range redirectURI.Query() {
@@ -22,13 +22,17 @@
 package fosite_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	. "github.com/ory/fosite"
 	. "github.com/ory/fosite/internal"
@@ -88,7 +92,7 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[0]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeQuery).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeQuery)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
@@ -107,12 +111,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[0]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeDefault).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeDefault)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/?error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&state=foostate")
+				a, _ := url.Parse("https://foobar.com/?error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -126,12 +130,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeQuery).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeQuery)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/?error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&foo=bar&state=foostate")
+				a, _ := url.Parse("https://foobar.com/?error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&foo=bar&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -145,7 +149,7 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"foobar"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
@@ -164,12 +168,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[0]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&state=foostate")
+				a, _ := url.Parse("https://foobar.com/#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -183,12 +187,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/?foo=bar#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&state=foostate")
+				a, _ := url.Parse("https://foobar.com/?foo=bar#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -202,12 +206,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[0]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code", "token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&state=foostate")
+				a, _ := url.Parse("https://foobar.com/#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -221,12 +225,12 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code", "token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
 			checkHeader: func(t *testing.T, k int) {
-				a, _ := url.Parse("https://foobar.com/?foo=bar#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.+Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&error_hint=Make+sure+that+the+various+parameters+are+correct%2C+be+aware+of+case+sensitivity+and+trim+your+parameters.+Make+sure+that+the+client+you+are+using+has+exactly+whitelisted+the+redirect_uri+you+specified.&state=foostate")
+				a, _ := url.Parse("https://foobar.com/?foo=bar#error=invalid_request&error_description=The+request+is+missing+a+required+parameter%2C+includes+an+invalid+parameter+value%2C+includes+a+parameter+more+than+once%2C+or+is+otherwise+malformed.&state=foostate")
 				b, _ := url.Parse(header.Get("Location"))
 				assert.Equal(t, a, b)
 				assert.Equal(t, "no-store", header.Get("Cache-Control"))
@@ -241,7 +245,7 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"code", "token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
@@ -261,7 +265,7 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"id_token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
@@ -281,7 +285,7 @@ func TestWriteAuthorizeError(t *testing.T) {
 				req.EXPECT().GetRedirectURI().Return(copyUrl(purls[1]))
 				req.EXPECT().GetState().Return("foostate")
 				req.EXPECT().GetResponseTypes().MaxTimes(2).Return(Arguments([]string{"token"}))
-				req.EXPECT().GetResponseMode().Return(ResponseModeFragment).Times(2)
+				req.EXPECT().GetResponseMode().Return(ResponseModeFragment)
 				rw.EXPECT().Header().Times(3).Return(header)
 				rw.EXPECT().WriteHeader(http.StatusFound)
 			},
@@ -334,3 +338,99 @@ func copyUrl(u *url.URL) *url.URL {
 	u2, _ := url.Parse(u.String())
 	return u2
 }
+
+func TestWriteAuthorizeErrorEchoesState(t *testing.T) {
+	f := &Fosite{}
+	client := &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+
+	newRequest := func(mode ResponseModeType, state string) *AuthorizeRequest {
+		ar := NewAuthorizeRequest()
+		ar.Client = client
+		ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+		ar.ResponseMode = mode
+		ar.State = state
+		return ar
+	}
+
+	for k, c := range []struct {
+		d      string
+		mode   ResponseModeType
+		params func(t *testing.T, rw *httptest.ResponseRecorder) url.Values
+	}{
+		{
+			d:    "query",
+			mode: ResponseModeQuery,
+			params: func(t *testing.T, rw *httptest.ResponseRecorder) url.Values {
+				require.Equal(t, http.StatusFound, rw.Code)
+				location, err := url.Parse(rw.Header().Get("Location"))
+				require.NoError(t, err)
+				return location.Query()
+			},
+		},
+		{
+			d:    "fragment",
+			mode: ResponseModeFragment,
+			params: func(t *testing.T, rw *httptest.ResponseRecorder) url.Values {
+				require.Equal(t, http.StatusFound, rw.Code)
+				location, err := url.Parse(rw.Header().Get("Location"))
+				require.NoError(t, err)
+				fragment, err := url.ParseQuery(location.Fragment)
+				require.NoError(t, err)
+				return fragment
+			},
+		},
+		{
+			d:    "form_post",
+			mode: ResponseModeFormPost,
+			params: func(t *testing.T, rw *httptest.ResponseRecorder) url.Values {
+				require.Equal(t, http.StatusOK, rw.Code)
+				_, state, _, _, _, rfcErr, err := ParseFormPostResponse("https://foobar.com/cb", ioutil.NopCloser(rw.Body))
+				require.NoError(t, err)
+				params := url.Values{"error": {rfcErr["Name"]}}
+				if state != "" {
+					params.Set("state", state)
+				}
+				return params
+			},
+		},
+		{
+			d:    "web_message",
+			mode: ResponseModeWebMessage,
+			params: func(t *testing.T, rw *httptest.ResponseRecorder) url.Values {
+				require.Equal(t, http.StatusOK, rw.Code)
+				_, params := parseWebMessageResponse(t, rw.Body.String())
+				return params
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			f.WriteAuthorizeError(rw, newRequest(c.mode, "some-state"), ErrInvalidScope)
+			params := c.params(t, rw)
+			assert.Equal(t, "invalid_scope", params.Get("error"))
+			assert.Equal(t, "some-state", params.Get("state"))
+
+			rw = httptest.NewRecorder()
+			f.WriteAuthorizeError(rw, newRequest(c.mode, ""), ErrInvalidScope)
+			params = c.params(t, rw)
+			assert.Equal(t, "invalid_scope", params.Get("error"))
+			_, ok := params["state"]
+			assert.False(t, ok)
+		})
+	}
+
+	t.Run("case=invalid redirect uri", func(t *testing.T) {
+		ar := newRequest(ResponseModeQuery, "some-state")
+		ar.RedirectURI, _ = url.Parse("https://evil.com/cb")
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeError(rw, ar, ErrInvalidScope)
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+		assert.Empty(t, rw.Header().Get("Location"))
+		assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+		assert.Equal(t, "invalid_scope", body["error"])
+	})
+}
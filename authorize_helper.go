@@ -37,7 +37,7 @@ var FormPostDefaultTemplate = template.Must(template.New("form_post").Parse(`<ht
    <head>
       <title>Submit This Form</title>
    </head>
-   <body onload="javascript:document.forms[0].submit()">
+   <body>
       <form method="post" action="{{ .RedirURL }}">
          {{ range $key,$value := .Parameters }}
             {{ range $parameter:= $value}}
@@ -45,6 +45,7 @@ var FormPostDefaultTemplate = template.Must(template.New("form_post").Parse(`<ht
             {{end}}
          {{ end }}
       </form>
+      <script type="text/javascript" nonce="{{ .Nonce }}">document.forms[0].submit();</script>
    </body>
 </html>`))
 
@@ -79,12 +80,18 @@ var FormPostDefaultTemplate = template.Must(template.New("form_post").Parse(`<ht
 //     with the redirect URI passed to the token's endpoint, such an
 //     attack is detected (see Section 5.2.4.5).
 func MatchRedirectURIWithClientRedirectURIs(rawurl string, client Client) (*url.URL, error) {
+	return MatchRedirectURIWithStrategy(rawurl, client, LoopbackRedirectURIMatchingStrategy)
+}
+
+// MatchRedirectURIWithStrategy works like MatchRedirectURIWithClientRedirectURIs but uses the given strategy to
+// compare the requested redirect URI with the client's registered redirect URIs.
+func MatchRedirectURIWithStrategy(rawurl string, client Client, strategy RedirectURIMatchingStrategy) (*url.URL, error) {
 	if rawurl == "" && len(client.GetRedirectURIs()) == 1 {
 		if redirectURIFromClient, err := url.Parse(client.GetRedirectURIs()[0]); err == nil && IsValidRedirectURI(redirectURIFromClient) {
 			// If no redirect_uri was given and the client has exactly one valid redirect_uri registered, use that instead
 			return redirectURIFromClient, nil
 		}
-	} else if redirectTo, ok := isMatchingRedirectURI(rawurl, client.GetRedirectURIs()); rawurl != "" && ok {
+	} else if redirectTo, ok := isMatchingRedirectURI(rawurl, client, strategy); rawurl != "" && ok {
 		// If a redirect_uri was given and the clients knows it (simple string comparison!)
 		// return it.
 		if parsed, err := url.Parse(redirectTo); err == nil && IsValidRedirectURI(parsed) {
@@ -93,7 +100,7 @@ func MatchRedirectURIWithClientRedirectURIs(rawurl string, client Client) (*url.
 		}
 	}
 
-	return nil, errors.WithStack(ErrInvalidRequest.WithHint("The 'redirect_uri' parameter does not match any of the OAuth 2.0 Client's pre-registered redirect urls."))
+	return nil, errors.WithStack(ErrInvalidRequest.WithHint("The 'redirect_uri' parameter does not match any of the OAuth 2.0 Client's pre-registered redirect urls.").WithParameter("redirect_uri"))
 }
 
 // Match a requested  redirect URI against a pool of registered client URIs
@@ -111,16 +118,9 @@ func MatchRedirectURIWithClientRedirectURIs(rawurl string, client Client) (*url.
 //
 // Loopback redirect URIs use the "http" scheme and are constructed with
 // the loopback IP literal and whatever port the client is listening on.
-func isMatchingRedirectURI(uri string, haystack []string) (string, bool) {
-	requested, err := url.Parse(uri)
-	if err != nil {
-		return "", false
-	}
-
-	for _, b := range haystack {
-		if b == uri {
-			return b, true
-		} else if isMatchingAsLoopback(requested, b) {
+func isMatchingRedirectURI(uri string, client Client, strategy RedirectURIMatchingStrategy) (string, bool) {
+	for _, b := range client.GetRedirectURIs() {
+		if strategy(uri, b, client) {
 			// We have to return the requested URL here because otherwise the port might get lost (see isMatchingAsLoopback)
 			// description.
 			return uri, true
@@ -202,12 +202,20 @@ func IsLocalhost(redirectURI *url.URL) bool {
 }
 
 func WriteAuthorizeFormPostResponse(redirectURL string, parameters url.Values, template *template.Template, rw io.Writer) {
+	writeAuthorizeFormPostResponse(redirectURL, parameters, "", template, rw)
+}
+
+// writeAuthorizeFormPostResponse renders the form_post page. The nonce is set on the auto submit script so that it
+// runs under the Content-Security-Policy of the response.
+func writeAuthorizeFormPostResponse(redirectURL string, parameters url.Values, nonce string, template *template.Template, rw io.Writer) {
 	_ = template.Execute(rw, struct {
 		RedirURL   string
 		Parameters url.Values
+		Nonce      string
 	}{
 		RedirURL:   redirectURL,
 		Parameters: parameters,
+		Nonce:      nonce,
 	})
 }
 
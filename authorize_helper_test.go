@@ -23,11 +23,14 @@ package fosite_test
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
 
@@ -241,6 +244,105 @@ func TestDoesClientWhiteListRedirect(t *testing.T) {
 	}
 }
 
+func TestMatchRedirectURIWithStrategy(t *testing.T) {
+	native := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:   &fosite.DefaultClient{RedirectURIs: []string{"http://127.0.0.1/cb", "http://[::1]/cb", "http://www.ory.sh/cb"}},
+		ApplicationType: fosite.NativeApplicationType,
+	}
+	web := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:   &fosite.DefaultClient{RedirectURIs: []string{"http://127.0.0.1/cb"}},
+		ApplicationType: fosite.WebApplicationType,
+	}
+	ranged := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:   &fosite.DefaultClient{RedirectURIs: []string{"http://127.0.0.1:[49152-65535]/cb", "http://[::1]:[8000-8010]/cb?foo=bar"}},
+		ApplicationType: fosite.NativeApplicationType,
+	}
+	webRanged := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:   &fosite.DefaultClient{RedirectURIs: []string{"http://127.0.0.1:[49152-65535]/cb"}},
+		ApplicationType: fosite.WebApplicationType,
+	}
+
+	for k, c := range []struct {
+		d        string
+		strategy fosite.RedirectURIMatchingStrategy
+		client   fosite.Client
+		url      string
+		isError  bool
+	}{
+		{d: "exact matching rejects a different loopback port", strategy: fosite.ExactRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1:51004/cb", isError: true},
+		{d: "exact matching accepts the registered loopback uri", strategy: fosite.ExactRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1/cb"},
+		{d: "loopback matching accepts an ephemeral ipv4 port", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1:51004/cb"},
+		{d: "loopback matching accepts another ephemeral ipv4 port", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1:8080/cb"},
+		{d: "loopback matching accepts an ephemeral ipv6 port", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: native, url: "http://[::1]:51004/cb"},
+		{d: "loopback matching rejects a different path", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1:51004/other", isError: true},
+		{d: "loopback matching rejects a different port on a public http uri", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: native, url: "http://www.ory.sh:8080/cb", isError: true},
+		{d: "loopback matching rejects clients which are not native apps", strategy: fosite.LoopbackRedirectURIMatchingStrategy, client: web, url: "http://127.0.0.1:51004/cb", isError: true},
+		{d: "port range matching accepts a port within the range", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1:51004/cb"},
+		{d: "port range matching accepts the lower bound", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1:49152/cb"},
+		{d: "port range matching accepts the upper bound", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1:65535/cb"},
+		{d: "port range matching accepts an ipv6 port within the range", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://[::1]:8005/cb?foo=bar"},
+		{d: "port range matching rejects a port below the range", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1:8080/cb", isError: true},
+		{d: "port range matching rejects a port above the range", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://[::1]:8011/cb?foo=bar", isError: true},
+		{d: "port range matching rejects a missing port", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1/cb", isError: true},
+		{d: "port range matching rejects a different path", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://127.0.0.1:51004/other", isError: true},
+		{d: "port range matching rejects a different query", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://[::1]:8005/cb?foo=baz", isError: true},
+		{d: "port range matching rejects a different loopback address", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "http://[::1]:51004/cb", isError: true},
+		{d: "port range matching rejects https", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: ranged, url: "https://127.0.0.1:51004/cb", isError: true},
+		{d: "port range matching rejects clients which are not native apps", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: webRanged, url: "http://127.0.0.1:51004/cb", isError: true},
+		{d: "port range matching accepts the registered uri exactly", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: native, url: "http://www.ory.sh/cb"},
+		{d: "port range matching does not accept arbitrary loopback ports", strategy: fosite.LoopbackPortRangeRedirectURIMatchingStrategy, client: native, url: "http://127.0.0.1:51004/cb", isError: true},
+		{
+			d: "custom matching is used",
+			strategy: func(requested, registered string, _ fosite.Client) bool {
+				return strings.EqualFold(requested, registered)
+			},
+			client: native,
+			url:    "http://WWW.ORY.SH/cb",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			redir, err := fosite.MatchRedirectURIWithStrategy(c.url, c.client, c.strategy)
+			if c.isError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.url, redir.String())
+		})
+	}
+}
+
+func TestValidateLoopbackPortRangeRedirectURI(t *testing.T) {
+	for k, c := range []struct {
+		uri     string
+		isError bool
+	}{
+		{uri: "http://127.0.0.1:[49152-65535]/cb"},
+		{uri: "http://[::1]:[8000-8000]/cb?foo=bar"},
+		{uri: "http://127.0.0.1:[1-65535]"},
+		{uri: "https://www.ory.sh/cb"},
+		{uri: "http://127.0.0.1/cb"},
+		{uri: "http://127.0.0.1:[65535-49152]/cb", isError: true},
+		{uri: "http://127.0.0.1:[0-100]/cb", isError: true},
+		{uri: "http://127.0.0.1:[49152-65536]/cb", isError: true},
+		{uri: "http://127.0.0.1:[49152]/cb", isError: true},
+		{uri: "https://127.0.0.1:[49152-65535]/cb", isError: true},
+		{uri: "http://localhost:[49152-65535]/cb", isError: true},
+		{uri: "http://www.ory.sh:[49152-65535]/cb", isError: true},
+		{uri: "http://127.0.0.1:[49152-65535]/cb#foo", isError: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/uri=%s", k, c.uri), func(t *testing.T) {
+			err := fosite.ValidateLoopbackPortRangeRedirectURI(c.uri)
+			if c.isError {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, fosite.ErrInvalidRequest))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestIsRedirectURISecure(t *testing.T) {
 	for d, c := range []struct {
 		u   string
@@ -32,6 +32,16 @@ const (
 	ResponseModeFormPost = ResponseModeType("form_post")
 	ResponseModeQuery    = ResponseModeType("query")
 	ResponseModeFragment = ResponseModeType("fragment")
+
+	// ResponseModeWebMessage posts the authorization response to the window which opened the authorization endpoint
+	// using window.postMessage, which allows authorizing in popups and iframes.
+	ResponseModeWebMessage = ResponseModeType("web_message")
+
+	// JWT Secured Authorization Response Modes (JARM), see https://openid.net/specs/oauth-v2-jarm.html#name-response-modes
+	ResponseModeJWT         = ResponseModeType("jwt")
+	ResponseModeQueryJWT    = ResponseModeType("query.jwt")
+	ResponseModeFragmentJWT = ResponseModeType("fragment.jwt")
+	ResponseModeFormPostJWT = ResponseModeType("form_post.jwt")
 )
 
 // AuthorizeRequest is an implementation of AuthorizeRequester
@@ -0,0 +1,185 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultAuthorizeRequestLifespan is the default lifetime of authorization request state.
+	DefaultAuthorizeRequestLifespan = 30 * time.Minute
+
+	// MinAuthorizeRequestLifespan is the shortest lifetime authorization request state may be configured with.
+	MinAuthorizeRequestLifespan = 10 * time.Second
+
+	// MaxAuthorizeRequestLifespan is the longest lifetime authorization request state may be configured with.
+	MaxAuthorizeRequestLifespan = 24 * time.Hour
+)
+
+// GetAuthorizeRequestLifespan returns AuthorizeRequestLifespan if set, or an error if it is not between
+// MinAuthorizeRequestLifespan and MaxAuthorizeRequestLifespan. Defaults to DefaultAuthorizeRequestLifespan.
+func (f *Fosite) GetAuthorizeRequestLifespan() (time.Duration, error) {
+	if f.AuthorizeRequestLifespan == 0 {
+		return DefaultAuthorizeRequestLifespan, nil
+	} else if f.AuthorizeRequestLifespan < MinAuthorizeRequestLifespan || f.AuthorizeRequestLifespan > MaxAuthorizeRequestLifespan {
+		return 0, errors.Errorf("the authorization request lifespan must be between %s and %s but is %s", MinAuthorizeRequestLifespan, MaxAuthorizeRequestLifespan, f.AuthorizeRequestLifespan)
+	}
+	return f.AuthorizeRequestLifespan, nil
+}
+
+// GetEncodedAuthorizeRequestLifespan returns EncodedAuthorizeRequestLifespan if set, or an error if it is shorter than
+// MinAuthorizeRequestLifespan or exceeds the authorization request lifespan. Defaults to GetAuthorizeRequestLifespan.
+func (f *Fosite) GetEncodedAuthorizeRequestLifespan() (time.Duration, error) {
+	max, err := f.GetAuthorizeRequestLifespan()
+	if err != nil {
+		return 0, err
+	} else if f.EncodedAuthorizeRequestLifespan == 0 {
+		return max, nil
+	} else if f.EncodedAuthorizeRequestLifespan < MinAuthorizeRequestLifespan || f.EncodedAuthorizeRequestLifespan > max {
+		return 0, errors.Errorf("the encoded authorization request lifespan must be between %s and %s but is %s", MinAuthorizeRequestLifespan, max, f.EncodedAuthorizeRequestLifespan)
+	}
+	return f.EncodedAuthorizeRequestLifespan, nil
+}
+
+// encodedAuthorizeRequest is the serialized form of an authorization request. The client is referenced by its ID and
+// the session is not included because it is passed to NewAuthorizeResponse when the request is resumed.
+type encodedAuthorizeRequest struct {
+	ID                  string           `json:"id"`
+	RequestedAt         time.Time        `json:"requested_at"`
+	ClientID            string           `json:"client_id"`
+	Form                url.Values       `json:"form"`
+	RequestedScope      Arguments        `json:"requested_scope"`
+	GrantedScope        Arguments        `json:"granted_scope"`
+	RequestedAudience   Arguments        `json:"requested_audience"`
+	GrantedAudience     Arguments        `json:"granted_audience"`
+	ResponseTypes       Arguments        `json:"response_types"`
+	RedirectURI         string           `json:"redirect_uri"`
+	State               string           `json:"state"`
+	ResponseMode        ResponseModeType `json:"response_mode"`
+	DefaultResponseMode ResponseModeType `json:"default_response_mode"`
+	Issuer              string           `json:"issuer,omitempty"`
+	ExpiresAt           time.Time        `json:"expires_at"`
+}
+
+// EncodeAuthorizeRequest serializes an authorization request returned by NewAuthorizeRequest, for example to pause it
+// while the end-user is redirected to a consent screen. DecodeAuthorizeRequest reconstructs the request, which can then
+// be passed to NewAuthorizeResponse. All parsed fields are kept, including the requested scopes and audience, the
+// response types and mode, the form with PKCE and claims parameters, and the issuer resolved from the HTTP request.
+//
+// The encoded request expires after GetEncodedAuthorizeRequestLifespan, after which DecodeAuthorizeRequest rejects it
+// with ErrTokenExpired. The encoded request is not signed or encrypted. It must be stored server-side or be protected by the caller, because
+// a modified request could grant scopes or redirect to URIs the end-user or client did not agree to.
+func (f *Fosite) EncodeAuthorizeRequest(ctx context.Context, requester AuthorizeRequester) ([]byte, error) {
+	if requester.GetClient() == nil {
+		return nil, errors.New("Authorization request can not be encoded because it has no client")
+	}
+
+	lifespan, err := f.GetEncodedAuthorizeRequestLifespan()
+	if err != nil {
+		return nil, err
+	}
+
+	var redirectURI string
+	if requester.GetRedirectURI() != nil {
+		redirectURI = requester.GetRedirectURI().String()
+	}
+
+	out, err := json.Marshal(&encodedAuthorizeRequest{
+		ID:                  requester.GetID(),
+		RequestedAt:         requester.GetRequestedAt(),
+		ClientID:            requester.GetClient().GetID(),
+		Form:                requester.GetRequestForm(),
+		RequestedScope:      requester.GetRequestedScopes(),
+		GrantedScope:        requester.GetGrantedScopes(),
+		RequestedAudience:   requester.GetRequestedAudience(),
+		GrantedAudience:     requester.GetGrantedAudience(),
+		ResponseTypes:       requester.GetResponseTypes(),
+		RedirectURI:         redirectURI,
+		State:               requester.GetState(),
+		ResponseMode:        requester.GetResponseMode(),
+		DefaultResponseMode: requester.GetDefaultResponseMode(),
+		Issuer:              requesterIssuer(requester),
+		ExpiresAt:           f.Clock.Now().Add(lifespan),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
+}
+
+// DecodeAuthorizeRequest reconstructs an authorization request encoded by EncodeAuthorizeRequest. The client is loaded
+// from the storage again, so that changes to the client, for example its deletion, take effect.
+func (f *Fosite) DecodeAuthorizeRequest(ctx context.Context, data []byte) (AuthorizeRequester, error) {
+	var encoded encodedAuthorizeRequest
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHint("Unable to decode the authorization request.").WithCause(err).WithDebug(err.Error()))
+	} else if !f.Clock.Now().Before(encoded.ExpiresAt) {
+		return nil, errors.WithStack(ErrTokenExpired.WithHintf("The authorization request expired at '%s'.", encoded.ExpiresAt))
+	}
+
+	redirectURI, err := url.Parse(encoded.RedirectURI)
+	if err != nil {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHint("Unable to decode the redirect URI of the authorization request.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	client, err := f.getClient(ctx, encoded.ClientID)
+	if err != nil {
+		return nil, errors.WithStack(ErrInvalidClient.WithHint("The requested OAuth 2.0 Client does not exist.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	request := NewAuthorizeRequest()
+	request.ID = encoded.ID
+	request.RequestedAt = encoded.RequestedAt
+	request.Client = client
+	request.RedirectURI = redirectURI
+	request.State = encoded.State
+	request.ResponseMode = encoded.ResponseMode
+	request.DefaultResponseMode = encoded.DefaultResponseMode
+	request.SetIssuer(encoded.Issuer)
+
+	if encoded.Form != nil {
+		request.Form = encoded.Form
+	}
+	if encoded.ResponseTypes != nil {
+		request.ResponseTypes = encoded.ResponseTypes
+	}
+	if encoded.RequestedScope != nil {
+		request.RequestedScope = encoded.RequestedScope
+	}
+	if encoded.GrantedScope != nil {
+		request.GrantedScope = encoded.GrantedScope
+	}
+	if encoded.RequestedAudience != nil {
+		request.RequestedAudience = encoded.RequestedAudience
+	}
+	if encoded.GrantedAudience != nil {
+		request.GrantedAudience = encoded.GrantedAudience
+	}
+
+	return request, nil
+}
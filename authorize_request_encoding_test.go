@@ -0,0 +1,228 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+// echoAuthorizeHandler adds the parsed fields of the authorization request to the response.
+type echoAuthorizeHandler struct{}
+
+func (echoAuthorizeHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+	resp.AddParameter("id", ar.GetID())
+	resp.AddParameter("scope", strings.Join(ar.GetGrantedScopes(), " "))
+	resp.AddParameter("audience", strings.Join(ar.GetGrantedAudience(), " "))
+	resp.AddParameter("redirect_uri", ar.GetRedirectURI().String())
+	resp.AddParameter("response_mode", string(ar.GetResponseMode()))
+	for _, k := range []string{"code_challenge", "code_challenge_method", "claims", "nonce"} {
+		resp.AddParameter(k, ar.GetRequestForm().Get(k))
+	}
+	ar.SetResponseTypeHandled("code")
+	return nil
+}
+
+func TestEncodeAuthorizeRequest(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{
+		ID:            "foo",
+		RedirectURIs:  []string{"https://foo.bar/cb"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"openid", "offline"},
+		Audience:      []string{"https://api.foo.bar"},
+	}
+
+	f := &Fosite{
+		Store:                     store,
+		ScopeStrategy:             ExactScopeStrategy,
+		AudienceMatchingStrategy:  DefaultAudienceMatchingStrategy,
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{echoAuthorizeHandler{}},
+		IssuerFromRequest:         func(*http.Request) string { return "https://tenant.foo.bar" },
+	}
+
+	r := &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: url.Values{
+		"redirect_uri":          {"https://foo.bar/cb"},
+		"client_id":             {"foo"},
+		"response_type":         {"code"},
+		"scope":                 {"openid offline"},
+		"audience":              {"https://api.foo.bar"},
+		"state":                 {"some-random-state"},
+		"nonce":                 {"some-random-nonce"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+		"claims":                {`{"id_token":{"email":{"essential":true}}}`},
+	}.Encode()}}
+
+	original, err := f.NewAuthorizeRequest(context.Background(), r)
+	require.NoError(t, err)
+	original.GrantScope("openid")
+	original.GrantAudience("https://api.foo.bar")
+
+	encoded, err := f.EncodeAuthorizeRequest(context.Background(), original)
+	require.NoError(t, err)
+
+	decoded, err := f.DecodeAuthorizeRequest(context.Background(), encoded)
+	require.NoError(t, err)
+
+	t.Run("case=all parsed fields are restored", func(t *testing.T) {
+		assert.Equal(t, original.GetID(), decoded.GetID())
+		assert.True(t, original.GetRequestedAt().Equal(decoded.GetRequestedAt()))
+		assert.Equal(t, original.GetClient(), decoded.GetClient())
+		assert.Equal(t, original.GetRequestForm(), decoded.GetRequestForm())
+		assert.Equal(t, original.GetRequestedScopes(), decoded.GetRequestedScopes())
+		assert.Equal(t, original.GetGrantedScopes(), decoded.GetGrantedScopes())
+		assert.Equal(t, original.GetRequestedAudience(), decoded.GetRequestedAudience())
+		assert.Equal(t, original.GetGrantedAudience(), decoded.GetGrantedAudience())
+		assert.Equal(t, original.GetResponseTypes(), decoded.GetResponseTypes())
+		assert.Equal(t, original.GetRedirectURI(), decoded.GetRedirectURI())
+		assert.Equal(t, original.GetState(), decoded.GetState())
+		assert.Equal(t, original.GetResponseMode(), decoded.GetResponseMode())
+		assert.Equal(t, original.GetDefaultResponseMode(), decoded.GetDefaultResponseMode())
+		assert.Equal(t, "https://tenant.foo.bar", original.(IssuerRequester).GetIssuer())
+		assert.Equal(t, original.(IssuerRequester).GetIssuer(), decoded.(IssuerRequester).GetIssuer())
+	})
+
+	t.Run("case=the decoded request yields the same response", func(t *testing.T) {
+		expected, err := f.NewAuthorizeResponse(context.Background(), original, &DefaultSession{})
+		require.NoError(t, err)
+
+		actual, err := f.NewAuthorizeResponse(context.Background(), decoded, &DefaultSession{})
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.GetParameters(), actual.GetParameters())
+		assert.Equal(t, expected.GetHeader(), actual.GetHeader())
+	})
+
+	t.Run("case=decoding fails if the client was deleted", func(t *testing.T) {
+		delete(store.Clients, "foo")
+		defer func() {
+			store.Clients["foo"] = original.GetClient()
+		}()
+
+		_, err := f.DecodeAuthorizeRequest(context.Background(), encoded)
+		require.EqualError(t, err, ErrInvalidClient.Error())
+	})
+
+	t.Run("case=decoding fails if the data is malformed", func(t *testing.T) {
+		_, err := f.DecodeAuthorizeRequest(context.Background(), []byte("not-json"))
+		require.EqualError(t, err, ErrInvalidRequest.Error())
+	})
+}
+
+func TestEncodeAuthorizeRequestLifespan(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foo.bar/cb"}}
+	request := NewAuthorizeRequest()
+	request.Client = store.Clients["foo"]
+
+	for k, c := range []struct {
+		d                string
+		lifespan         time.Duration
+		encodedLifespan  time.Duration
+		expectedLifespan time.Duration
+		expectErr        bool
+	}{
+		{
+			d:                "should expire after the default lifespan",
+			expectedLifespan: DefaultAuthorizeRequestLifespan,
+		},
+		{
+			d:                "should expire after the configured lifespan",
+			lifespan:         time.Hour,
+			expectedLifespan: time.Hour,
+		},
+		{
+			d:                "should expire after the lifespan configured for encoded requests",
+			lifespan:         time.Hour,
+			encodedLifespan:  5 * time.Minute,
+			expectedLifespan: 5 * time.Minute,
+		},
+		{
+			d:                "should expire after the lifespan configured for encoded requests below the default cap",
+			encodedLifespan:  MinAuthorizeRequestLifespan,
+			expectedLifespan: MinAuthorizeRequestLifespan,
+		},
+		{
+			d:         "should fail because the lifespan is too short",
+			lifespan:  time.Second,
+			expectErr: true,
+		},
+		{
+			d:         "should fail because the lifespan is too long",
+			lifespan:  MaxAuthorizeRequestLifespan + time.Second,
+			expectErr: true,
+		},
+		{
+			d:               "should fail because the lifespan for encoded requests is too short",
+			encodedLifespan: time.Second,
+			expectErr:       true,
+		},
+		{
+			d:               "should fail because the lifespan for encoded requests exceeds the cap",
+			lifespan:        time.Hour,
+			encodedLifespan: 2 * time.Hour,
+			expectErr:       true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			now := time.Now().UTC()
+			f := &Fosite{
+				Store:                           store,
+				Clock:                           func() time.Time { return now },
+				AuthorizeRequestLifespan:        c.lifespan,
+				EncodedAuthorizeRequestLifespan: c.encodedLifespan,
+			}
+
+			encoded, err := f.EncodeAuthorizeRequest(context.Background(), request)
+			if c.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			now = now.Add(c.expectedLifespan - time.Second)
+			_, err = f.DecodeAuthorizeRequest(context.Background(), encoded)
+			require.NoError(t, err)
+
+			now = now.Add(time.Second)
+			_, err = f.DecodeAuthorizeRequest(context.Background(), encoded)
+			require.EqualError(t, err, ErrTokenExpired.Error())
+		})
+	}
+
+	t.Run("case=requests encoded without an expiry are rejected", func(t *testing.T) {
+		_, err := (&Fosite{Store: store}).DecodeAuthorizeRequest(context.Background(), []byte(`{"client_id":"foo"}`))
+		require.EqualError(t, err, ErrTokenExpired.Error())
+	})
+}
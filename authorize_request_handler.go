@@ -24,6 +24,7 @@ package fosite
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -42,6 +43,31 @@ func wrapSigningKeyFailure(outer *RFC6749Error, inner error) *RFC6749Error {
 	return outer
 }
 
+// requestURIHTTPClient returns the HTTP client used to fetch request objects, following at most
+// GetRequestURIMaxRedirects redirects. Every redirect target must be one of the allowed request URIs of the client,
+// otherwise the client could use a registered request_uri to make the server fetch arbitrary locations.
+func (f *Fosite) requestURIHTTPClient(allowed []string) *http.Client {
+	hc := f.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	client := *hc
+	maxRedirects := f.GetRequestURIMaxRedirects()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return errors.Errorf("stopped after %d redirects", maxRedirects)
+		} else if !stringslice.Has(allowed, req.URL.String()) {
+			return errors.Errorf("redirect to '%s' which is not a registered request_uri", req.URL.String())
+		}
+		if hc.CheckRedirect != nil {
+			return hc.CheckRedirect(req, via)
+		}
+		return nil
+	}
+	return &client
+}
+
 func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *AuthorizeRequest) error {
 	var scope Arguments = RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
 
@@ -55,7 +81,7 @@ func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *Aut
 	if len(request.Form.Get("request")+request.Form.Get("request_uri")) == 0 {
 		return nil
 	} else if len(request.Form.Get("request")) > 0 && len(request.Form.Get("request_uri")) > 0 {
-		return errors.WithStack(ErrInvalidRequest.WithHint("OpenID Connect parameters 'request' and 'request_uri' were both given, but you can use at most one."))
+		return errors.WithStack(ErrInvalidRequest.WithHint("OpenID Connect parameters 'request' and 'request_uri' were both given, but you can use at most one.").WithParameter("request_uri"))
 	}
 
 	oidcClient, ok := request.Client.(OpenIDConnectClient)
@@ -73,15 +99,10 @@ func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *Aut
 	assertion := request.Form.Get("request")
 	if location := request.Form.Get("request_uri"); len(location) > 0 {
 		if !stringslice.Has(oidcClient.GetRequestURIs(), location) {
-			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Request URI '%s' is not whitelisted by the OAuth 2.0 Client.", location))
+			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Request URI '%s' is not whitelisted by the OAuth 2.0 Client.", location).WithParameter("request_uri"))
 		}
 
-		hc := f.HTTPClient
-		if hc == nil {
-			hc = http.DefaultClient
-		}
-
-		response, err := hc.Get(location)
+		response, err := f.requestURIHTTPClient(oidcClient.GetRequestURIs()).Get(location)
 		if err != nil {
 			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Unable to fetch OpenID Connect request parameters from 'request_uri' because: %s.", err.Error()).WithCause(err).WithDebug(err.Error()))
 		}
@@ -91,9 +112,12 @@ func (f *Fosite) authorizeRequestParametersFromOpenIDConnectRequest(request *Aut
 			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Unable to fetch OpenID Connect request parameters from 'request_uri' because status code '%d' was expected, but got '%d'.", http.StatusOK, response.StatusCode))
 		}
 
-		body, err := ioutil.ReadAll(response.Body)
+		maxBodySize := f.GetRequestURIMaxBodySize()
+		body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxBodySize+1))
 		if err != nil {
 			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Unable to fetch OpenID Connect request parameters from 'request_uri' because body parsing failed with: %s.", err).WithCause(err).WithDebug(err.Error()))
+		} else if int64(len(body)) > maxBodySize {
+			return errors.WithStack(ErrInvalidRequestURI.WithHintf("Unable to fetch OpenID Connect request parameters from 'request_uri' because the request object exceeds the maximum size of %d bytes.", maxBodySize))
 		}
 
 		assertion = string(body)
@@ -179,21 +203,25 @@ func (f *Fosite) validateAuthorizeRedirectURI(_ *http.Request, request *Authoriz
 	rawRedirURI := request.Form.Get("redirect_uri")
 
 	// Validate redirect uri
-	redirectURI, err := MatchRedirectURIWithClientRedirectURIs(rawRedirURI, request.Client)
+	redirectURI, err := MatchRedirectURIWithStrategy(rawRedirURI, request.Client, f.GetRedirectURIMatchingStrategy())
 	if err != nil {
 		return err
 	} else if !IsValidRedirectURI(redirectURI) {
-		return errors.WithStack(ErrInvalidRequest.WithHintf("The redirect URI '%s' contains an illegal character (for example #) or is otherwise invalid.", redirectURI))
+		return errors.WithStack(ErrInvalidRequest.WithHintf("The redirect URI '%s' contains an illegal character (for example #) or is otherwise invalid.", redirectURI).WithParameter("redirect_uri"))
 	}
 	request.RedirectURI = redirectURI
 	return nil
 }
 
 func (f *Fosite) validateAuthorizeScope(_ *http.Request, request *AuthorizeRequest) error {
-	scope := RemoveEmpty(strings.Split(request.Form.Get("scope"), " "))
+	scope := f.withDefaultScopes(request.Client, RemoveEmpty(strings.Split(request.Form.Get("scope"), " ")))
+	if err := f.validateKnownScopes(scope); err != nil {
+		return err
+	}
+
 	for _, permission := range scope {
 		if !f.ScopeStrategy(request.Client.GetScopes(), permission) {
-			return errors.WithStack(ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", permission))
+			return errors.WithStack(ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", permission).WithParameter("scope"))
 		}
 	}
 	request.SetRequestedScopes(scope)
@@ -209,7 +237,27 @@ func (f *Fosite) validateResponseTypes(r *http.Request, request *AuthorizeReques
 	// response types is defined by their respective specifications.
 	responseTypes := RemoveEmpty(strings.Split(r.Form.Get("response_type"), " "))
 	if len(responseTypes) == 0 {
-		return errors.WithStack(ErrUnsupportedResponseType.WithHint("`The request is missing the 'response_type' parameter."))
+		return errors.WithStack(ErrUnsupportedResponseType.WithHint("`The request is missing the 'response_type' parameter.").WithParameter("response_type"))
+	}
+
+	// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#none
+	// The response type "none" SHOULD NOT be combined with other response types.
+	if Arguments(responseTypes).Has("none") && len(responseTypes) > 1 {
+		return errors.WithStack(ErrUnsupportedResponseType.WithHint("The response_type 'none' can not be combined with other response types.").WithParameter("response_type"))
+	}
+
+	if f.EnabledResponseTypes != nil {
+		var enabled bool
+		for _, t := range f.EnabledResponseTypes {
+			if Arguments(responseTypes).Matches(RemoveEmpty(strings.Split(t, " "))...) {
+				enabled = true
+				break
+			}
+		}
+
+		if !enabled {
+			return errors.WithStack(ErrUnsupportedResponseType.WithHintf("The response_type '%s' is disabled on this authorization server.", r.Form.Get("response_type")).WithParameter("response_type"))
+		}
 	}
 
 	var found bool
@@ -221,7 +269,7 @@ func (f *Fosite) validateResponseTypes(r *http.Request, request *AuthorizeReques
 	}
 
 	if !found {
-		return errors.WithStack(ErrUnsupportedResponseType.WithHintf("The client is not allowed to request response_type '%s'.", r.Form.Get("response_type")))
+		return errors.WithStack(ErrUnsupportedResponseType.WithHintf("The client is not allowed to request response_type '%s'.", r.Form.Get("response_type")).WithParameter("response_type"))
 	}
 
 	request.ResponseTypes = responseTypes
@@ -229,45 +277,17 @@ func (f *Fosite) validateResponseTypes(r *http.Request, request *AuthorizeReques
 }
 
 func (f *Fosite) ParseResponseMode(r *http.Request, request *AuthorizeRequest) error {
-	switch responseMode := r.Form.Get("response_mode"); responseMode {
-	case string(ResponseModeDefault):
-		request.ResponseMode = ResponseModeDefault
-	case string(ResponseModeFragment):
-		request.ResponseMode = ResponseModeFragment
-	case string(ResponseModeQuery):
-		request.ResponseMode = ResponseModeQuery
-	case string(ResponseModeFormPost):
-		request.ResponseMode = ResponseModeFormPost
-	default:
-		return errors.WithStack(ErrUnsupportedResponseMode.WithHintf("Request with unsupported response_mode \"%s\".", responseMode))
+	responseMode, err := parseResponseMode(r.Form.Get("response_mode"))
+	if err != nil {
+		return err
 	}
 
+	request.ResponseMode = responseMode
 	return nil
 }
 
 func (f *Fosite) validateResponseMode(r *http.Request, request *AuthorizeRequest) error {
-	if request.ResponseMode == ResponseModeDefault {
-		return nil
-	}
-
-	responseModeClient, ok := request.GetClient().(ResponseModeClient)
-	if !ok {
-		return errors.WithStack(ErrUnsupportedResponseMode.WithHintf("The request has response_mode \"%s\". set but registered OAuth 2.0 client doesn't support response_mode", r.Form.Get("response_mode")))
-	}
-
-	var found bool
-	for _, t := range responseModeClient.GetResponseModes() {
-		if request.ResponseMode == t {
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return errors.WithStack(ErrUnsupportedResponseMode.WithHintf("The client is not allowed to request response_mode \"%s\".", r.Form.Get("response_mode")))
-	}
-
-	return nil
+	return validateClientResponseMode(request.GetClient(), request.ResponseMode)
 }
 
 func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (AuthorizeRequester, error) {
@@ -276,18 +296,23 @@ func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (Auth
 		HandledResponseTypes: Arguments{},
 		Request:              *NewRequest(),
 	}
+	if f.IDGenerator != nil {
+		request.SetID(f.IDGenerator.New())
+	}
 
 	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
 		return request, errors.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithCause(err).WithDebug(err.Error()))
 	}
 	request.Form = r.Form
+	request.SetIssuer(f.resolveIssuer(ctx, r))
+	ctx = withRequesterIssuer(ctx, request)
 
 	// Save state to the request to be returned in error conditions (https://github.com/ory/hydra/issues/1642)
 	request.State = request.Form.Get("state")
 
-	client, err := f.Store.GetClient(ctx, request.GetRequestForm().Get("client_id"))
+	client, err := f.getClient(ctx, request.GetRequestForm().Get("client_id"))
 	if err != nil {
-		return request, errors.WithStack(ErrInvalidClient.WithHint("The requested OAuth 2.0 Client does not exist.").WithCause(err).WithDebug(err.Error()))
+		return request, errors.WithStack(ErrInvalidClient.WithHint("The requested OAuth 2.0 Client does not exist.").WithParameter("client_id").WithCause(err).WithDebug(err.Error()))
 	}
 	request.Client = client
 
@@ -310,6 +335,12 @@ func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (Auth
 		return request, err
 	}
 
+	if f.SectorIdentifierValidator != nil {
+		if err := f.SectorIdentifierValidator.ValidateSectorIdentifier(ctx, client); err != nil {
+			return request, err
+		}
+	}
+
 	if err := f.validateAuthorizeScope(r, request); err != nil {
 		return request, err
 	}
@@ -319,7 +350,7 @@ func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (Auth
 	}
 
 	if len(request.Form.Get("registration")) > 0 {
-		return request, errors.WithStack(ErrRegistrationNotSupported)
+		return request, errors.WithStack(ErrRegistrationNotSupported.WithParameter("registration"))
 	}
 
 	if err := f.validateResponseTypes(r, request); err != nil {
@@ -336,9 +367,9 @@ func (f *Fosite) NewAuthorizeRequest(ctx context.Context, r *http.Request) (Auth
 	//
 	// https://tools.ietf.org/html/rfc6819#section-4.4.1.8
 	// The "state" parameter should not	be guessable
-	if len(request.State) < f.GetMinParameterEntropy() {
+	if len(request.State) < f.GetMinStateEntropy() {
 		// We're assuming that using less then, by default, 8 characters for the state can not be considered "unguessable"
-		return request, errors.WithStack(ErrInvalidState.WithHintf("Request parameter 'state' must be at least be %d characters long to ensure sufficient entropy.", f.GetMinParameterEntropy()))
+		return request, errors.WithStack(ErrInvalidState.WithHintf("Request parameter 'state' must be at least be %d characters long to ensure sufficient entropy.", f.GetMinStateEntropy()).WithParameter("state"))
 	}
 
 	return request, nil
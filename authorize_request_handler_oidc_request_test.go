@@ -29,6 +29,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -88,6 +90,29 @@ func TestAuthorizeRequestParametersFromOpenIDConnectRequest(t *testing.T) {
 	reqTS := httptest.NewServer(reqH)
 	defer reqTS.Close()
 
+	var oversizedH http.HandlerFunc = func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(validRequestObject + strings.Repeat(" ", DefaultRequestURIMaxBodySize)))
+	}
+	oversizedTS := httptest.NewServer(oversizedH)
+	defer oversizedTS.Close()
+
+	var redirectH http.HandlerFunc = func(rw http.ResponseWriter, r *http.Request) {
+		hops, _ := strconv.Atoi(r.URL.Query().Get("hops"))
+		if hops == 0 {
+			rw.Write([]byte(validRequestObject))
+			return
+		}
+		http.Redirect(rw, r, fmt.Sprintf("/?hops=%d", hops-1), http.StatusFound)
+	}
+	redirectTS := httptest.NewServer(redirectH)
+	defer redirectTS.Close()
+	redirectingURI := fmt.Sprintf("%s/?hops=%d", redirectTS.URL, DefaultRequestURIMaxRedirects)
+	tooManyRedirectsURI := fmt.Sprintf("%s/?hops=%d", redirectTS.URL, DefaultRequestURIMaxRedirects+1)
+	var redirectHops []string
+	for hops := 0; hops <= DefaultRequestURIMaxRedirects+1; hops++ {
+		redirectHops = append(redirectHops, fmt.Sprintf("%s/?hops=%d", redirectTS.URL, hops))
+	}
+
 	var hJWK http.HandlerFunc = func(rw http.ResponseWriter, r *http.Request) {
 		require.NoError(t, json.NewEncoder(rw).Encode(jwks))
 	}
@@ -190,6 +215,30 @@ func TestAuthorizeRequestParametersFromOpenIDConnectRequest(t *testing.T) {
 			client:     &DefaultOpenIDConnectClient{JSONWebKeysURI: reqJWK.URL, RequestObjectSigningAlgorithm: "RS256", RequestURIs: []string{reqTS.URL}},
 			expectForm: url.Values{"response_type": {"token"}, "response_mode": {"post_form"}, "scope": {"foo openid"}, "request_uri": {reqTS.URL}, "foo": {"bar"}, "baz": {"baz"}},
 		},
+		{
+			d:         "should fail because the request object exceeds the maximum size",
+			form:      url.Values{"scope": {"openid"}, "request_uri": {oversizedTS.URL}},
+			client:    &DefaultOpenIDConnectClient{JSONWebKeysURI: reqJWK.URL, RequestObjectSigningAlgorithm: "RS256", RequestURIs: []string{oversizedTS.URL}},
+			expectErr: ErrInvalidRequestURI,
+		},
+		{
+			d:          "should pass because the request uri redirects at most the maximum number of times",
+			form:       url.Values{"scope": {"openid"}, "request_uri": {redirectingURI}},
+			client:     &DefaultOpenIDConnectClient{JSONWebKeysURI: reqJWK.URL, RequestObjectSigningAlgorithm: "RS256", RequestURIs: redirectHops},
+			expectForm: url.Values{"response_type": {"token"}, "response_mode": {"post_form"}, "scope": {"foo openid"}, "request_uri": {redirectingURI}, "foo": {"bar"}, "baz": {"baz"}},
+		},
+		{
+			d:         "should fail because the request uri redirects too often",
+			form:      url.Values{"scope": {"openid"}, "request_uri": {tooManyRedirectsURI}},
+			client:    &DefaultOpenIDConnectClient{JSONWebKeysURI: reqJWK.URL, RequestObjectSigningAlgorithm: "RS256", RequestURIs: redirectHops},
+			expectErr: ErrInvalidRequestURI,
+		},
+		{
+			d:         "should fail because the request uri redirects to a location which is not registered",
+			form:      url.Values{"scope": {"openid"}, "request_uri": {redirectingURI}},
+			client:    &DefaultOpenIDConnectClient{JSONWebKeysURI: reqJWK.URL, RequestObjectSigningAlgorithm: "RS256", RequestURIs: []string{redirectingURI}},
+			expectErr: ErrInvalidRequestURI,
+		},
 		{
 			d:          "should pass when request object uses algorithm none",
 			form:       url.Values{"scope": {"openid"}, "request": {validNoneRequestObject}},
@@ -35,6 +35,7 @@ import (
 
 	. "github.com/ory/fosite"
 	. "github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 // Should pass
@@ -190,6 +191,26 @@ func TestNewAuthorizeRequest(t *testing.T) {
 			},
 			expectedError: ErrInvalidRequest,
 		},
+		/* none combined with other response types */
+		{
+			desc: "should fail because response type none is combined with code",
+			conf: &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy},
+			query: url.Values{
+				"redirect_uri":  {"https://foo.bar/cb"},
+				"client_id":     {"1234"},
+				"response_type": {"none code"},
+				"state":         {"strong-state"},
+				"scope":         {"foo bar"},
+			},
+			mock: func() {
+				store.EXPECT().GetClient(gomock.Any(), "1234").Return(&DefaultClient{
+					ResponseTypes: []string{"none code"},
+					RedirectURIs:  []string{"https://foo.bar/cb"},
+					Scopes:        []string{"foo", "bar"},
+				}, nil)
+			},
+			expectedError: ErrUnsupportedResponseType,
+		},
 		/* success case */
 		{
 			desc: "should pass",
@@ -494,3 +515,230 @@ func TestNewAuthorizeRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAuthorizeRequestKnownScopes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{
+		ID:            "foo",
+		RedirectURIs:  []string{"https://foo.bar/cb"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"foo"},
+	}
+
+	newRequest := func(scope string) *http.Request {
+		return &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: url.Values{
+			"redirect_uri":  {"https://foo.bar/cb"},
+			"client_id":     {"foo"},
+			"response_type": {"code"},
+			"scope":         {scope},
+			"state":         {"some-random-state"},
+		}.Encode()}}
+	}
+
+	for k, c := range []struct {
+		d           string
+		knownScopes []string
+		scope       string
+		expectHint  string
+	}{
+		{
+			d:     "should pass because the registry is disabled",
+			scope: "foo",
+		},
+		{
+			d:           "should pass because the scope is known and allowed",
+			knownScopes: []string{"foo", "bar"},
+			scope:       "foo",
+		},
+		{
+			d:           "should fail because the client is not allowed to request the known scope",
+			knownScopes: []string{"foo", "bar"},
+			scope:       "foo bar",
+			expectHint:  "The OAuth 2.0 Client is not allowed to request scope 'bar'.",
+		},
+		{
+			d:           "should fail because the scope is unknown even though the registry is checked first",
+			knownScopes: []string{"foo", "bar"},
+			scope:       "baz foo",
+			expectHint:  "The requested scope 'baz' is not known to this authorization server.",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy, KnownScopes: c.knownScopes}
+			ar, err := f.NewAuthorizeRequest(context.Background(), newRequest(c.scope))
+			if c.expectHint == "" {
+				require.NoError(t, err)
+				assert.EqualValues(t, Arguments{"foo"}, ar.GetRequestedScopes())
+				return
+			}
+
+			require.EqualError(t, err, ErrInvalidScope.Error())
+			assert.Equal(t, c.expectHint, ErrorToRFC6749Error(err).Hint)
+		})
+	}
+}
+
+func TestNewAuthorizeRequestDefaultScopes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{
+		ID:            "foo",
+		RedirectURIs:  []string{"https://foo.bar/cb"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"foo", "bar"},
+	}
+	store.Clients["with-defaults"] = &DefaultClient{
+		ID:            "with-defaults",
+		RedirectURIs:  []string{"https://foo.bar/cb"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"foo", "bar"},
+		DefaultScopes: []string{"bar"},
+	}
+
+	newRequest := func(clientID, scope string) *http.Request {
+		query := url.Values{
+			"redirect_uri":  {"https://foo.bar/cb"},
+			"client_id":     {clientID},
+			"response_type": {"code"},
+			"state":         {"some-random-state"},
+		}
+		if scope != "" {
+			query.Set("scope", scope)
+		}
+		return &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: query.Encode()}}
+	}
+
+	for k, c := range []struct {
+		d             string
+		defaultScopes []string
+		clientID      string
+		scope         string
+		expectScopes  Arguments
+		expectHint    string
+	}{
+		{
+			d:             "should request the default scopes if the scope is omitted",
+			defaultScopes: []string{"foo"},
+			clientID:      "foo",
+			expectScopes:  Arguments{"foo"},
+		},
+		{
+			d:             "should request the client's default scopes if the scope is omitted",
+			defaultScopes: []string{"foo"},
+			clientID:      "with-defaults",
+			expectScopes:  Arguments{"bar"},
+		},
+		{
+			d:             "should not apply the default scopes if the scope is requested",
+			defaultScopes: []string{"foo"},
+			clientID:      "with-defaults",
+			scope:         "foo bar",
+			expectScopes:  Arguments{"foo", "bar"},
+		},
+		{
+			d:            "should request no scope if there are no default scopes",
+			clientID:     "foo",
+			expectScopes: Arguments{},
+		},
+		{
+			d:          "should fail because the request exceeds the client's scopes",
+			clientID:   "with-defaults",
+			scope:      "foo bar baz",
+			expectHint: "The OAuth 2.0 Client is not allowed to request scope 'baz'.",
+		},
+		{
+			d:             "should fail because the default scopes exceed the client's scopes",
+			defaultScopes: []string{"foo", "baz"},
+			clientID:      "foo",
+			expectHint:    "The OAuth 2.0 Client is not allowed to request scope 'baz'.",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy, DefaultScopes: c.defaultScopes}
+			ar, err := f.NewAuthorizeRequest(context.Background(), newRequest(c.clientID, c.scope))
+			if c.expectHint == "" {
+				require.NoError(t, err)
+				assert.ElementsMatch(t, c.expectScopes, ar.GetRequestedScopes())
+				return
+			}
+
+			require.EqualError(t, err, ErrInvalidScope.Error())
+			assert.Equal(t, c.expectHint, ErrorToRFC6749Error(err).Hint)
+		})
+	}
+}
+
+func TestNewAuthorizeRequestErrorParameter(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{
+		ID:            "foo",
+		RedirectURIs:  []string{"https://foo.bar/cb", "https://foo.bar/other"},
+		ResponseTypes: []string{"code"},
+		Scopes:        []string{"foo"},
+	}
+	f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy}
+
+	for k, c := range []struct {
+		d               string
+		query           url.Values
+		expectErr       error
+		expectParameter string
+	}{
+		{
+			d:               "should attribute a missing redirect_uri",
+			query:           url.Values{"response_type": {"code"}, "scope": {"foo"}},
+			expectErr:       ErrInvalidRequest,
+			expectParameter: "redirect_uri",
+		},
+		{
+			d:               "should attribute an unknown redirect_uri",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/unknown"}, "response_type": {"code"}, "scope": {"foo"}},
+			expectErr:       ErrInvalidRequest,
+			expectParameter: "redirect_uri",
+		},
+		{
+			d:               "should attribute an invalid scope",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/cb"}, "response_type": {"code"}, "scope": {"foo bar"}},
+			expectErr:       ErrInvalidScope,
+			expectParameter: "scope",
+		},
+		{
+			d:               "should attribute a missing response_type",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/cb"}, "scope": {"foo"}},
+			expectErr:       ErrUnsupportedResponseType,
+			expectParameter: "response_type",
+		},
+		{
+			d:               "should attribute a response_type the client may not use",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/cb"}, "response_type": {"token"}, "scope": {"foo"}},
+			expectErr:       ErrUnsupportedResponseType,
+			expectParameter: "response_type",
+		},
+		{
+			d:               "should attribute an unknown response_mode",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/cb"}, "response_type": {"code"}, "response_mode": {"unknown"}},
+			expectErr:       ErrUnsupportedResponseMode,
+			expectParameter: "response_mode",
+		},
+		{
+			d:               "should attribute a short state",
+			query:           url.Values{"redirect_uri": {"https://foo.bar/cb"}, "response_type": {"code"}, "scope": {"foo"}, "state": {"short"}},
+			expectErr:       ErrInvalidState,
+			expectParameter: "state",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			c.query.Set("client_id", "foo")
+			if c.query.Get("state") == "" {
+				c.query.Set("state", "some-random-state")
+			}
+
+			_, err := f.NewAuthorizeRequest(context.Background(), &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: c.query.Encode()}})
+			require.EqualError(t, err, c.expectErr.Error())
+
+			var rfcerr *RFC6749Error
+			require.True(t, errors.As(err, &rfcerr))
+			assert.Equal(t, c.expectParameter, rfcerr.Parameter())
+			assert.NotContains(t, rfcerr.ToValues(), "parameter")
+		})
+	}
+}
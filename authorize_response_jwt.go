@@ -0,0 +1,158 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite/internal/jwa"
+)
+
+// GetJARMLifespan returns JARMLifespan if set. Defaults to ten minutes.
+func (f *Fosite) GetJARMLifespan() time.Duration {
+	if f.JARMLifespan == 0 {
+		return time.Minute * 10
+	}
+	return f.JARMLifespan
+}
+
+// GetJARMSigningKeyID returns JARMSigningKeyID if set. Defaults to the base64url encoded SHA-256 JWK thumbprint of the
+// JARM signing key.
+func (f *Fosite) GetJARMSigningKeyID() (string, error) {
+	if f.JARMSigningKeyID != "" {
+		return f.JARMSigningKeyID, nil
+	}
+	if f.JARMSigningKey == nil {
+		return "", errors.New("the JARM signing key is not set")
+	}
+	return signingKeyThumbprint(f.JARMSigningKey)
+}
+
+// JARMSigningJWK returns the public key JWT secured authorization responses can be verified with. Add it to the
+// authorization server's published JWKS so clients can resolve the "kid" of the responses.
+func (f *Fosite) JARMSigningJWK() (*jose.JSONWebKey, error) {
+	method := jwtSigningMethod(f.JARMSigningKey)
+	if method == nil {
+		return nil, errors.Errorf("expected the JARM signing key to be an *rsa.PrivateKey or an *ecdsa.PrivateKey on curve P-256, P-384 or P-521 but got %T", f.JARMSigningKey)
+	}
+
+	kid, err := f.GetJARMSigningKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{
+		Key:       f.JARMSigningKey.Public(),
+		KeyID:     kid,
+		Algorithm: method.Alg(),
+		Use:       "sig",
+	}, nil
+}
+
+// signAuthorizeResponse packages the authorization response parameters into a signed JWT as defined by the
+// JWT Secured Authorization Response Mode for OAuth 2.0 (JARM), see https://openid.net/specs/oauth-v2-jarm.html#name-the-jwt-response-document
+func (f *Fosite) signAuthorizeResponse(ar AuthorizeRequester, parameters url.Values) (url.Values, error) {
+	method := jwtSigningMethod(f.JARMSigningKey)
+	if method == nil {
+		return nil, errors.WithStack(ErrMisconfiguration.WithHint("The authorization server is not configured to sign JWT secured authorization responses.").WithDebugf("Expected the JARM signing key to be an *rsa.PrivateKey or an *ecdsa.PrivateKey on curve P-256, P-384 or P-521 but got %T.", f.JARMSigningKey))
+	}
+
+	issuer := requesterIssuer(ar)
+	if issuer == "" {
+		issuer = f.JARMIssuer
+	}
+	if issuer == "" {
+		return nil, errors.WithStack(ErrMisconfiguration.WithHint("The authorization server is not configured to sign JWT secured authorization responses.").WithDebug("The JARM issuer must be set."))
+	}
+
+	claims := jwt.MapClaims{}
+	for k := range parameters {
+		claims[k] = parameters.Get(k)
+	}
+	// The registered claims are set last so that response parameters, for example added by AuthorizeResponseHooks,
+	// can not replace them.
+	claims["iss"] = issuer
+	claims["aud"] = ar.GetClient().GetID()
+	claims["exp"] = f.Clock.Now().Add(f.GetJARMLifespan()).Unix()
+
+	if state := parameters.Get("state"); f.JARMIncludeStateHash && state != "" {
+		sHash, err := stateHash(method, state)
+		if err != nil {
+			return nil, errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+		}
+		claims["s_hash"] = sHash
+	}
+
+	kid, err := f.GetJARMSigningKeyID()
+	if err != nil {
+		return nil, errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(f.JARMSigningKey)
+	if err != nil {
+		return nil, errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return url.Values{"response": {signed}}, nil
+}
+
+// stateHash returns the s_hash claim of the state, using the hash function of the signing method.
+func stateHash(method jwt.SigningMethod, state string) (string, error) {
+	var hash crypto.Hash
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA:
+		hash = m.Hash
+	case *jwt.SigningMethodRSAPSS:
+		hash = m.Hash
+	case *jwt.SigningMethodECDSA:
+		hash = m.Hash
+	}
+	return jwa.TokenHash(state, hash)
+}
+
+// jwtSigningMethod returns the signing method of the key as chosen by jwa.SigningAlgorithm, for example ES384 for ECDSA
+// keys on curve P-384, and nil for any other key.
+func jwtSigningMethod(key crypto.Signer) jwt.SigningMethod {
+	if alg := jwa.SigningAlgorithm(key); alg != "" {
+		return jwt.GetSigningMethod(alg)
+	}
+	return nil
+}
+
+// signingKeyThumbprint returns the base64url encoded SHA-256 JWK thumbprint of the public key of the signing key.
+func signingKeyThumbprint(key crypto.Signer) (string, error) {
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
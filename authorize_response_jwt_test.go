@@ -0,0 +1,330 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+)
+
+// formPostFields returns the names and values of all input fields of the forms in a form_post page.
+func formPostFields(t *testing.T, rec *httptest.ResponseRecorder) (action string, fields map[string][]string) {
+	doc, err := html.Parse(rec.Body)
+	require.NoError(t, err)
+
+	fields = map[string][]string{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := map[string]string{}
+			for _, attr := range n.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch n.Data {
+			case "form":
+				action = attrs["action"]
+			case "input":
+				fields[attrs["name"]] = append(fields[attrs["name"]], attrs["value"])
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return action, fields
+}
+
+func TestWriteAuthorizeResponseFormPostJWT(t *testing.T) {
+	key := internal.MustRSAKey()
+	f := &Fosite{
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+		JARMSigningKey:            key,
+		JARMIssuer:                "https://jarm.example.com",
+	}
+
+	ar := NewAuthorizeRequest()
+	ar.ResponseTypes = Arguments{"code"}
+	ar.ResponseMode = ResponseModeFormPostJWT
+	ar.State = "some-state"
+	ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+	ar.Client = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+
+	resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+	require.NoError(t, err)
+
+	for name, c := range map[string]struct {
+		write  func(rw http.ResponseWriter)
+		expect func(t *testing.T, claims jwt.MapClaims)
+	}{
+		"response": {
+			write: func(rw http.ResponseWriter) { f.WriteAuthorizeResponse(rw, ar, resp) },
+			expect: func(t *testing.T, claims jwt.MapClaims) {
+				assert.Equal(t, "some-code", claims["code"])
+				assert.Empty(t, claims["error"])
+			},
+		},
+		"error": {
+			write: func(rw http.ResponseWriter) { f.WriteAuthorizeError(rw, ar, ErrAccessDenied) },
+			expect: func(t *testing.T, claims jwt.MapClaims) {
+				assert.Equal(t, ErrAccessDenied.Name, claims["error"])
+				assert.Empty(t, claims["code"])
+			},
+		},
+	} {
+		t.Run("case="+name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c.write(rec)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'")
+			assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+
+			// The form carries the signed response only, the state is sent inside the JWT.
+			action, fields := formPostFields(t, rec)
+			assert.Equal(t, "https://foobar.com/cb", action)
+			require.Len(t, fields, 1, "%v", fields)
+			require.Len(t, fields["response"], 1)
+
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(fields["response"][0], claims, func(token *jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			})
+			require.NoError(t, err)
+			require.True(t, token.Valid)
+			assert.Equal(t, jwt.SigningMethodRS256, token.Method)
+
+			assert.Equal(t, "https://jarm.example.com", claims["iss"])
+			assert.Equal(t, "foo", claims["aud"])
+			assert.NotEmpty(t, claims["exp"])
+			assert.Equal(t, "some-state", claims["state"])
+			c.expect(t, claims)
+		})
+	}
+}
+
+func TestWriteAuthorizeResponseJWTStateHash(t *testing.T) {
+	rsaKey := internal.MustRSAKey()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		key              crypto.Signer
+		state            string
+		includeStateHash bool
+		expected         string
+	}{
+		{key: rsaKey, state: "some-state", includeStateHash: true, expected: "1DFxCPoklrWabhfPO9ZDmg"},
+		{key: ecKey, state: "some-state", includeStateHash: true, expected: "1DFxCPoklrWabhfPO9ZDmg"},
+		{key: rsaKey, state: "some-state"},
+		{key: rsaKey, includeStateHash: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/include=%t", k, c.includeStateHash), func(t *testing.T) {
+			f := &Fosite{
+				AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+				JARMSigningKey:            c.key,
+				JARMIssuer:                "https://jarm.example.com",
+				JARMIncludeStateHash:      c.includeStateHash,
+			}
+
+			ar := NewAuthorizeRequest()
+			ar.ResponseTypes = Arguments{"code"}
+			ar.ResponseMode = ResponseModeFormPostJWT
+			ar.State = c.state
+			ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+			ar.Client = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+
+			resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			f.WriteAuthorizeResponse(rec, ar, resp)
+			_, fields := formPostFields(t, rec)
+			require.Len(t, fields["response"], 1)
+
+			claims := jwt.MapClaims{}
+			_, _, err = new(jwt.Parser).ParseUnverified(fields["response"][0], claims)
+			require.NoError(t, err)
+			if c.expected == "" {
+				assert.NotContains(t, claims, "s_hash")
+			} else {
+				assert.Equal(t, c.state, claims["state"])
+				assert.Equal(t, c.expected, claims["s_hash"])
+			}
+		})
+	}
+}
+
+func TestWriteAuthorizeResponseJWTUsesRequestIssuer(t *testing.T) {
+	f := &Fosite{
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+		JARMSigningKey:            internal.MustRSAKey(),
+		JARMIssuer:                "https://jarm.example.com",
+	}
+
+	for k, c := range []struct {
+		issuer   string
+		expected string
+	}{
+		{issuer: "", expected: "https://jarm.example.com"},
+		{issuer: "https://tenant.example.com", expected: "https://tenant.example.com"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			ar := NewAuthorizeRequest()
+			ar.ResponseTypes = Arguments{"code"}
+			ar.ResponseMode = ResponseModeQueryJWT
+			ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+			ar.Client = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+			ar.SetIssuer(c.issuer)
+
+			resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			f.WriteAuthorizeResponse(rec, ar, resp)
+			location, err := url.Parse(rec.Header().Get("Location"))
+			require.NoError(t, err)
+
+			claims := jwt.MapClaims{}
+			_, _, err = new(jwt.Parser).ParseUnverified(location.Query().Get("response"), claims)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, claims["iss"])
+		})
+	}
+}
+
+func TestWriteAuthorizeResponseJWTHookCanNotReplaceRegisteredClaims(t *testing.T) {
+	now := time.Now().UTC().Round(time.Second)
+	f := &Fosite{
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+		AuthorizeResponseHooks: []AuthorizeResponseHook{func(_ context.Context, _ AuthorizeRequester, resp AuthorizeResponder) error {
+			resp.AddParameter("iss", "https://attacker.example.com")
+			resp.AddParameter("aud", "bar")
+			resp.AddParameter("exp", "4102444800")
+			return nil
+		}},
+		JARMSigningKey: internal.MustRSAKey(),
+		JARMIssuer:     "https://jarm.example.com",
+		Clock:          func() time.Time { return now },
+	}
+
+	ar := NewAuthorizeRequest()
+	ar.ResponseTypes = Arguments{"code"}
+	ar.ResponseMode = ResponseModeQueryJWT
+	ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+	ar.Client = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+
+	resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	f.WriteAuthorizeResponse(rec, ar, resp)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _, err = new(jwt.Parser).ParseUnverified(location.Query().Get("response"), claims)
+	require.NoError(t, err)
+	assert.Equal(t, "https://jarm.example.com", claims["iss"])
+	assert.Equal(t, "foo", claims["aud"])
+	assert.EqualValues(t, now.Add(f.GetJARMLifespan()).Unix(), claims["exp"])
+	assert.NotEmpty(t, claims["code"])
+}
+
+func TestWriteAuthorizeResponseJWTSigningKey(t *testing.T) {
+	rsaKey := internal.MustRSAKey()
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		key crypto.Signer
+		kid string
+		alg string
+	}{
+		{key: rsaKey, alg: "RS256"},
+		{key: rsaKey, kid: "jarm-1", alg: "RS256"},
+		{key: internal.MustECDSAKey(), alg: "ES256"},
+		{key: p384Key, alg: "ES384"},
+		{key: p521Key, alg: "ES512"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/alg=%s", k, c.alg), func(t *testing.T) {
+			f := &Fosite{
+				AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+				JARMSigningKey:            c.key,
+				JARMSigningKeyID:          c.kid,
+				JARMIssuer:                "https://jarm.example.com",
+				JARMIncludeStateHash:      true,
+			}
+
+			jwk, err := f.JARMSigningJWK()
+			require.NoError(t, err)
+			assert.Equal(t, c.alg, jwk.Algorithm)
+			if c.kid != "" {
+				assert.Equal(t, c.kid, jwk.KeyID)
+			}
+
+			ar := NewAuthorizeRequest()
+			ar.ResponseTypes = Arguments{"code"}
+			ar.ResponseMode = ResponseModeQueryJWT
+			ar.State = "some-state"
+			ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+			ar.Client = &DefaultClient{ID: "foo", RedirectURIs: []string{"https://foobar.com/cb"}}
+
+			resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			f.WriteAuthorizeResponse(rec, ar, resp)
+			location, err := url.Parse(rec.Header().Get("Location"))
+			require.NoError(t, err)
+
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(location.Query().Get("response"), claims, func(token *jwt.Token) (interface{}, error) {
+				assert.Equal(t, jwk.KeyID, token.Header["kid"])
+				return jwk.Key, nil
+			})
+			require.NoError(t, err)
+			require.True(t, token.Valid)
+			assert.Equal(t, c.alg, token.Method.Alg())
+			assert.NotEmpty(t, claims["s_hash"])
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ResolveResponseMode resolves the response mode which is used to answer an authorization request with the given
+// response types and response_mode parameter made by the client. It applies the same checks as the authorization
+// endpoint and returns either the resolved response mode or the error which the authorization endpoint would return.
+//
+// This is useful for testing response mode negotiation without going through the authorization endpoint.
+func ResolveResponseMode(responseTypes Arguments, responseMode string, client Client) (ResponseModeType, error) {
+	mode, err := parseResponseMode(responseMode)
+	if err != nil {
+		return ResponseModeDefault, err
+	}
+
+	if err := validateClientResponseMode(client, mode); err != nil {
+		return ResponseModeDefault, err
+	}
+
+	defaultMode := DefaultResponseModeFor(responseTypes)
+	if mode == ResponseModeDefault {
+		return defaultMode, nil
+	}
+
+	if defaultMode == ResponseModeFragment && responseModeTransport(mode, defaultMode) == ResponseModeQuery {
+		return ResponseModeDefault, errors.WithStack(ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", mode, responseTypes).WithParameter("response_mode"))
+	}
+
+	return mode, nil
+}
+
+// DefaultResponseModeFor returns the response mode which is used if the client does not request one. Only the
+// authorization code flow and response type "none" return their response in the query, all flows issuing tokens from
+// the authorization endpoint use the fragment.
+func DefaultResponseModeFor(responseTypes Arguments) ResponseModeType {
+	if responseTypes.ExactOne("code") || responseTypes.ExactOne("none") {
+		return ResponseModeQuery
+	}
+	return ResponseModeFragment
+}
+
+// IsJWTResponseMode returns true if the response mode is one of the JWT Secured Authorization Response Modes (JARM),
+// in which case the authorization response parameters are sent as a signed JWT in the "response" parameter.
+func IsJWTResponseMode(responseMode ResponseModeType) bool {
+	switch responseMode {
+	case ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT:
+		return true
+	}
+	return false
+}
+
+// responseModeTransport returns the response mode used to deliver the authorization response to the client. For the
+// JWT response modes this is the underlying query, fragment or form_post mode. The "jwt" response mode uses the default
+// response mode of the flow.
+func responseModeTransport(responseMode, defaultMode ResponseModeType) ResponseModeType {
+	switch responseMode {
+	case ResponseModeQueryJWT:
+		return ResponseModeQuery
+	case ResponseModeFragmentJWT:
+		return ResponseModeFragment
+	case ResponseModeFormPostJWT:
+		return ResponseModeFormPost
+	case ResponseModeJWT:
+		return defaultMode
+	}
+	return responseMode
+}
+
+func parseResponseMode(responseMode string) (ResponseModeType, error) {
+	switch responseMode {
+	case string(ResponseModeDefault):
+		return ResponseModeDefault, nil
+	case string(ResponseModeFragment):
+		return ResponseModeFragment, nil
+	case string(ResponseModeQuery):
+		return ResponseModeQuery, nil
+	case string(ResponseModeFormPost):
+		return ResponseModeFormPost, nil
+	case string(ResponseModeWebMessage):
+		return ResponseModeWebMessage, nil
+	case string(ResponseModeJWT):
+		return ResponseModeJWT, nil
+	case string(ResponseModeQueryJWT):
+		return ResponseModeQueryJWT, nil
+	case string(ResponseModeFragmentJWT):
+		return ResponseModeFragmentJWT, nil
+	case string(ResponseModeFormPostJWT):
+		return ResponseModeFormPostJWT, nil
+	}
+
+	return ResponseModeDefault, errors.WithStack(ErrUnsupportedResponseMode.WithHintf("Request with unsupported response_mode \"%s\".", responseMode).WithParameter("response_mode"))
+}
+
+func validateClientResponseMode(client Client, responseMode ResponseModeType) error {
+	if responseMode == ResponseModeDefault {
+		return nil
+	}
+
+	responseModeClient, ok := client.(ResponseModeClient)
+	if !ok {
+		return errors.WithStack(ErrUnsupportedResponseMode.WithHintf("The request has response_mode \"%s\". set but registered OAuth 2.0 client doesn't support response_mode", responseMode).WithParameter("response_mode"))
+	}
+
+	for _, t := range responseModeClient.GetResponseModes() {
+		if responseMode == t {
+			return nil
+		}
+	}
+
+	return errors.WithStack(ErrUnsupportedResponseMode.WithHintf("The client is not allowed to request response_mode \"%s\".", responseMode).WithParameter("response_mode"))
+}
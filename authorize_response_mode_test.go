@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestResolveResponseMode(t *testing.T) {
+	clientWithModes := func(modes ...ResponseModeType) Client {
+		return &DefaultResponseModeClient{DefaultClient: &DefaultClient{ID: "foo"}, ResponseModes: modes}
+	}
+
+	for k, c := range []struct {
+		description   string
+		responseTypes Arguments
+		responseMode  string
+		client        Client
+		expectMode    ResponseModeType
+		expectErr     error
+		expectHint    string
+	}{
+		{
+			description:   "should fail because implicit grant with response mode query",
+			responseTypes: Arguments{"id_token", "token"},
+			responseMode:  "query",
+			client:        clientWithModes(ResponseModeQuery),
+			expectErr:     ErrUnsupportedResponseMode,
+			expectHint:    "Insecure response_mode 'query' for the response_type '[id_token token]'.",
+		},
+		{
+			description:   "should pass implicit grant with response mode form_post",
+			responseTypes: Arguments{"id_token", "token"},
+			responseMode:  "form_post",
+			client:        clientWithModes(ResponseModeFormPost),
+			expectMode:    ResponseModeFormPost,
+		},
+		{
+			description:   "should fail because response mode form_post is not allowed by the client",
+			responseTypes: Arguments{"id_token", "token"},
+			responseMode:  "form_post",
+			client:        clientWithModes(ResponseModeQuery),
+			expectErr:     ErrUnsupportedResponseMode,
+			expectHint:    "The client is not allowed to request response_mode \"form_post\".",
+		},
+		{
+			description:   "should pass authorization code grant with response mode fragment",
+			responseTypes: Arguments{"code"},
+			responseMode:  "fragment",
+			client:        clientWithModes(ResponseModeFragment),
+			expectMode:    ResponseModeFragment,
+		},
+		{
+			description:   "should pass authorization code grant with response mode form_post",
+			responseTypes: Arguments{"code"},
+			responseMode:  "form_post",
+			client:        clientWithModes(ResponseModeFormPost),
+			expectMode:    ResponseModeFormPost,
+		},
+		{
+			description:   "should fail hybrid grant with response mode query",
+			responseTypes: Arguments{"token", "code"},
+			responseMode:  "query",
+			client:        clientWithModes(ResponseModeQuery),
+			expectErr:     ErrUnsupportedResponseMode,
+			expectHint:    "Insecure response_mode 'query' for the response_type '[token code]'.",
+		},
+		{
+			description:   "should pass hybrid grant with response mode form_post",
+			responseTypes: Arguments{"token", "code"},
+			responseMode:  "form_post",
+			client:        clientWithModes(ResponseModeFormPost),
+			expectMode:    ResponseModeFormPost,
+		},
+		{
+			description:   "should use query for the authorization code grant if no response mode was requested",
+			responseTypes: Arguments{"code"},
+			client:        &DefaultClient{ID: "foo"},
+			expectMode:    ResponseModeQuery,
+		},
+		{
+			description:   "should use fragment for the hybrid grant if no response mode was requested",
+			responseTypes: Arguments{"code", "id_token"},
+			client:        &DefaultClient{ID: "foo"},
+			expectMode:    ResponseModeFragment,
+		},
+		{
+			description:   "should fail because the client does not support response modes",
+			responseTypes: Arguments{"code"},
+			responseMode:  "form_post",
+			client:        &DefaultClient{ID: "foo"},
+			expectErr:     ErrUnsupportedResponseMode,
+		},
+		{
+			description:   "should pass authorization code grant with response mode query.jwt",
+			responseTypes: Arguments{"code"},
+			responseMode:  "query.jwt",
+			client:        clientWithModes(ResponseModeQueryJWT),
+			expectMode:    ResponseModeQueryJWT,
+		},
+		{
+			description:   "should fail because implicit grant with response mode query.jwt",
+			responseTypes: Arguments{"id_token", "token"},
+			responseMode:  "query.jwt",
+			client:        clientWithModes(ResponseModeQueryJWT),
+			expectErr:     ErrUnsupportedResponseMode,
+			expectHint:    "Insecure response_mode 'query.jwt' for the response_type '[id_token token]'.",
+		},
+		{
+			description:   "should pass implicit grant with response mode jwt",
+			responseTypes: Arguments{"id_token", "token"},
+			responseMode:  "jwt",
+			client:        clientWithModes(ResponseModeJWT),
+			expectMode:    ResponseModeJWT,
+		},
+		{
+			description:   "should pass hybrid grant with response mode form_post.jwt",
+			responseTypes: Arguments{"token", "code"},
+			responseMode:  "form_post.jwt",
+			client:        clientWithModes(ResponseModeFormPostJWT),
+			expectMode:    ResponseModeFormPostJWT,
+		},
+		{
+			description:   "should fail because the response mode is unknown",
+			responseTypes: Arguments{"code"},
+			responseMode:  "foo",
+			client:        clientWithModes(ResponseModeFormPost),
+			expectErr:     ErrUnsupportedResponseMode,
+			expectHint:    "Request with unsupported response_mode \"foo\".",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			mode, err := ResolveResponseMode(c.responseTypes, c.responseMode, c.client)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				if c.expectHint != "" {
+					assert.Equal(t, c.expectHint, ErrorToRFC6749Error(err).Hint)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, c.expectMode, mode)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// HTMLResponseSecurityHeaders returns the security headers of the HTML pages rendered for response_mode=form_post and
+// response_mode=web_message. The nonce is unique per response and set on the inline script of the page, which a
+// Content-Security-Policy must allow for the page to work. A nil HTMLResponseSecurityHeaders uses
+// DefaultHTMLResponseSecurityHeaders.
+type HTMLResponseSecurityHeaders func(nonce string, responseMode ResponseModeType, redirectURI *url.URL) http.Header
+
+// Headers returns the security headers of the page.
+func (h HTMLResponseSecurityHeaders) Headers(nonce string, responseMode ResponseModeType, redirectURI *url.URL) http.Header {
+	if h == nil {
+		return DefaultHTMLResponseSecurityHeaders(nonce, responseMode, redirectURI)
+	}
+	return h(nonce, responseMode, redirectURI)
+}
+
+// DefaultHTMLResponseSecurityHeaders only allows the inline script carrying the nonce and loads no other resources.
+// The form_post page must not be framed. The web_message page may only be framed by the origin of the redirect URI,
+// which it posts the response to when it is loaded in an iframe.
+func DefaultHTMLResponseSecurityHeaders(nonce string, responseMode ResponseModeType, redirectURI *url.URL) http.Header {
+	frameAncestors := "'none'"
+	if responseMode == ResponseModeWebMessage {
+		frameAncestors = redirectURIOrigin(redirectURI)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Security-Policy", "default-src 'none'; script-src 'nonce-"+nonce+"'; base-uri 'none'; frame-ancestors "+frameAncestors)
+	if responseMode != ResponseModeWebMessage {
+		header.Set("X-Frame-Options", "DENY")
+	}
+	header.Set("Referrer-Policy", "no-referrer")
+	return header
+}
+
+// writeAuthorizeHTMLResponse renders the page of a form_post or web_message authorization response together with its
+// security headers.
+func (f *Fosite) writeAuthorizeHTMLResponse(rw http.ResponseWriter, responseMode ResponseModeType, redirectURI *url.URL, parameters url.Values) error {
+	nonce, err := newScriptNonce()
+	if err != nil {
+		return errors.WithStack(ErrServerError.WithHint("Unable to generate the script nonce of the response.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	wh := rw.Header()
+	for k, v := range f.HTMLResponseSecurityHeaders.Headers(nonce, responseMode, redirectURI) {
+		wh[k] = v
+	}
+	wh.Add("Content-Type", "text/html;charset=UTF-8")
+
+	if responseMode == ResponseModeWebMessage {
+		writeAuthorizeWebMessageResponse(redirectURIOrigin(redirectURI), parameters, nonce, f.GetWebMessageHTMLTemplate(), rw)
+		return nil
+	}
+	writeAuthorizeFormPostResponse(redirectURI.String(), parameters, nonce, GetPostFormHTMLTemplate(*f), rw)
+	return nil
+}
+
+func newScriptNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
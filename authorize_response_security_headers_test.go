@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+var scriptNoncePattern = regexp.MustCompile(`<script type="text/javascript" nonce="([^"]+)">`)
+
+func TestAuthorizeHTMLResponseSecurityHeaders(t *testing.T) {
+	f := &Fosite{AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}}}
+
+	for _, c := range []struct {
+		responseMode   ResponseModeType
+		frameAncestors string
+		frameOptions   string
+	}{
+		{responseMode: ResponseModeFormPost, frameAncestors: "frame-ancestors 'none'", frameOptions: "DENY"},
+		{responseMode: ResponseModeWebMessage, frameAncestors: "frame-ancestors https://foobar.com"},
+	} {
+		ar := NewAuthorizeRequest()
+		ar.ResponseTypes = Arguments{"code"}
+		ar.ResponseMode = c.responseMode
+		ar.State = "some-state"
+		ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+		ar.Client = &DefaultClient{RedirectURIs: []string{"https://foobar.com/cb"}}
+
+		resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+		require.NoError(t, err)
+
+		for name, write := range map[string]func(rw http.ResponseWriter){
+			"response": func(rw http.ResponseWriter) { f.WriteAuthorizeResponse(rw, ar, resp) },
+			"error":    func(rw http.ResponseWriter) { f.WriteAuthorizeError(rw, ar, ErrAccessDenied) },
+		} {
+			t.Run(fmt.Sprintf("response_mode=%s/write=%s", c.responseMode, name), func(t *testing.T) {
+				rw := httptest.NewRecorder()
+				write(rw)
+
+				matches := scriptNoncePattern.FindStringSubmatch(rw.Body.String())
+				require.Len(t, matches, 2, "%s", rw.Body.String())
+				nonce := matches[1]
+
+				csp := rw.Header().Get("Content-Security-Policy")
+				assert.Contains(t, csp, "default-src 'none'")
+				assert.Contains(t, csp, "script-src 'nonce-"+nonce+"'")
+				assert.Contains(t, csp, c.frameAncestors)
+				assert.Equal(t, c.frameOptions, rw.Header().Get("X-Frame-Options"))
+				assert.Equal(t, "no-referrer", rw.Header().Get("Referrer-Policy"))
+				assert.Equal(t, "text/html;charset=UTF-8", rw.Header().Get("Content-Type"))
+				assert.False(t, strings.Contains(rw.Body.String(), "onload"))
+			})
+		}
+	}
+
+	t.Run("case=nonce is unique per response", func(t *testing.T) {
+		ar := NewAuthorizeRequest()
+		ar.ResponseMode = ResponseModeFormPost
+		ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+		ar.Client = &DefaultClient{RedirectURIs: []string{"https://foobar.com/cb"}}
+
+		first, second := httptest.NewRecorder(), httptest.NewRecorder()
+		f.WriteAuthorizeError(first, ar, ErrAccessDenied)
+		f.WriteAuthorizeError(second, ar, ErrAccessDenied)
+		assert.NotEqual(t, first.Header().Get("Content-Security-Policy"), second.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("case=custom headers", func(t *testing.T) {
+		f := &Fosite{
+			HTMLResponseSecurityHeaders: func(nonce string, responseMode ResponseModeType, redirectURI *url.URL) http.Header {
+				return http.Header{"Content-Security-Policy": {"script-src 'nonce-" + nonce + "'; frame-ancestors " + redirectURI.Host}}
+			},
+		}
+
+		ar := NewAuthorizeRequest()
+		ar.ResponseMode = ResponseModeFormPost
+		ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+		ar.Client = &DefaultClient{RedirectURIs: []string{"https://foobar.com/cb"}}
+
+		rw := httptest.NewRecorder()
+		f.WriteAuthorizeError(rw, ar, ErrAccessDenied)
+
+		matches := scriptNoncePattern.FindStringSubmatch(rw.Body.String())
+		require.Len(t, matches, 2, "%s", rw.Body.String())
+		assert.Equal(t, "script-src 'nonce-"+matches[1]+"'; frame-ancestors foobar.com", rw.Header().Get("Content-Security-Policy"))
+		assert.Empty(t, rw.Header().Get("X-Frame-Options"))
+	})
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"html/template"
+	"io"
+	"net/url"
+)
+
+// WebMessageDefaultTemplate renders the authorization response of requests with response_mode=web_message. It posts
+// the response parameters to the window which opened the authorization endpoint, either as a popup or in an iframe.
+// The message is only delivered if that window shows a page of the origin of the redirect URI.
+var WebMessageDefaultTemplate = template.Must(template.New("web_message").Parse(`<html>
+   <head>
+      <title>Authorization Response</title>
+   </head>
+   <body>
+      <script type="text/javascript" nonce="{{ .Nonce }}">
+         (function (window) {
+            var target = window.opener || window.parent;
+            target.postMessage({type: "authorization_response", response: {{ .Response }}}, {{ .Origin }});
+         })(window);
+      </script>
+   </body>
+</html>`))
+
+// WriteAuthorizeWebMessageResponse renders the authorization response parameters for response_mode=web_message, posting
+// them to the window of the given origin.
+func WriteAuthorizeWebMessageResponse(origin string, parameters url.Values, template *template.Template, rw io.Writer) {
+	writeAuthorizeWebMessageResponse(origin, parameters, "", template, rw)
+}
+
+// writeAuthorizeWebMessageResponse renders the web_message page. The nonce is set on the script posting the response
+// so that it runs under the Content-Security-Policy of the response.
+func writeAuthorizeWebMessageResponse(origin string, parameters url.Values, nonce string, template *template.Template, rw io.Writer) {
+	response := map[string]string{}
+	for k := range parameters {
+		response[k] = parameters.Get(k)
+	}
+
+	_ = template.Execute(rw, struct {
+		Origin     string
+		Response   map[string]string
+		Parameters url.Values
+		Nonce      string
+	}{
+		Origin:     origin,
+		Response:   response,
+		Parameters: parameters,
+		Nonce:      nonce,
+	})
+}
+
+// GetWebMessageHTMLTemplate returns WebMessageHTMLTemplate if set. Defaults to WebMessageDefaultTemplate.
+func (f *Fosite) GetWebMessageHTMLTemplate() *template.Template {
+	if f.WebMessageHTMLTemplate == nil {
+		return WebMessageDefaultTemplate
+	}
+	return f.WebMessageHTMLTemplate
+}
+
+// redirectURIOrigin returns the origin (scheme, host and port) of the redirect URI, which is the only origin a
+// web_message response is posted to.
+func redirectURIOrigin(redirectURI *url.URL) string {
+	return redirectURI.Scheme + "://" + redirectURI.Host
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	. "github.com/ory/fosite/internal"
+)
+
+var webMessagePattern = regexp.MustCompile(`postMessage\(\{type: "authorization_response", response: (.*)\}, (".*")\);`)
+
+// parseWebMessageResponse extracts the target origin and the response parameters posted by a web_message page.
+func parseWebMessageResponse(t *testing.T, body string) (string, url.Values) {
+	matches := webMessagePattern.FindStringSubmatch(body)
+	require.Len(t, matches, 3, "%s", body)
+
+	var response map[string]string
+	require.NoError(t, json.Unmarshal([]byte(matches[1]), &response))
+	var origin string
+	require.NoError(t, json.Unmarshal([]byte(matches[2]), &origin))
+
+	parameters := url.Values{}
+	for k, v := range response {
+		parameters.Set(k, v)
+	}
+	return origin, parameters
+}
+
+func TestWriteAuthorizeWebMessageResponse(t *testing.T) {
+	var buf bytes.Buffer
+	WriteAuthorizeWebMessageResponse("https://foobar.com:8080", url.Values{
+		"code":  {"some-code"},
+		"state": {"</script><script>alert(1)</script>"},
+	}, WebMessageDefaultTemplate, &buf)
+
+	assert.NotContains(t, buf.String(), "<script>alert(1)")
+	origin, parameters := parseWebMessageResponse(t, buf.String())
+	assert.Equal(t, "https://foobar.com:8080", origin)
+	assert.Equal(t, "some-code", parameters.Get("code"))
+	assert.Equal(t, "</script><script>alert(1)</script>", parameters.Get("state"))
+}
+
+func TestWriteAuthorizeResponseWebMessage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ar := NewMockAuthorizeRequester(ctrl)
+	resp := NewMockAuthorizeResponder(ctrl)
+
+	redir, _ := url.Parse("https://foobar.com:8080/some/cb?foo=bar")
+	ar.EXPECT().GetRedirectURI().Return(redir)
+	ar.EXPECT().GetResponseMode().Return(ResponseModeWebMessage)
+	resp.EXPECT().GetParameters().Return(url.Values{"code": {"some-code"}, "state": {"some-state"}})
+	resp.EXPECT().GetHeader().Return(http.Header{})
+
+	rw := httptest.NewRecorder()
+	(&Fosite{}).WriteAuthorizeResponse(rw, ar, resp)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/html;charset=UTF-8", rw.Header().Get("Content-Type"))
+	origin, parameters := parseWebMessageResponse(t, rw.Body.String())
+	assert.Equal(t, "https://foobar.com:8080", origin)
+	assert.Equal(t, "some-code", parameters.Get("code"))
+	assert.Equal(t, "some-state", parameters.Get("state"))
+}
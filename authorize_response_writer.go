@@ -36,6 +36,14 @@ func (f *Fosite) NewAuthorizeResponse(ctx context.Context, ar AuthorizeRequester
 	}
 
 	ar.SetSession(session)
+	ctx = withRequesterIssuer(ctx, ar)
+
+	// The state is only marked as used once a response is issued. NewAuthorizeRequest is usually called again when
+	// the user returns from login and consent, which must not count as a replay.
+	if err := f.validateStateNotReplayed(ctx, ar); err != nil {
+		return nil, err
+	}
+
 	for _, h := range f.AuthorizeEndpointHandlers {
 		if err := h.HandleAuthorizeEndpointRequest(ctx, ar, resp); err != nil {
 			return nil, err
@@ -43,12 +51,78 @@ func (f *Fosite) NewAuthorizeResponse(ctx context.Context, ar AuthorizeRequester
 	}
 
 	if !ar.DidHandleAllResponseTypes() {
-		return nil, errors.WithStack(ErrUnsupportedResponseType)
+		if !ar.GetResponseTypes().ExactOne("none") {
+			return nil, errors.WithStack(ErrUnsupportedResponseType)
+		}
+
+		// https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#none
+		// When response_type "none" is used, the authorization server does not issue any credentials. Only the state
+		// is returned to the client, if it was set.
+		ar.SetResponseTypeHandled("none")
+	}
+
+	// The state is echoed in every response if the client sent it, see https://tools.ietf.org/html/rfc6749#section-4.1.2
+	if state := ar.GetState(); state != "" {
+		resp.Parameters.Set("state", state)
+	} else {
+		resp.Parameters.Del("state")
 	}
 
-	if ar.GetDefaultResponseMode() == ResponseModeFragment && ar.GetResponseMode() == ResponseModeQuery {
+	// For the JWT response modes, the check applies to the mode that is used to transport the response JWT.
+	if defaultMode := ar.GetDefaultResponseMode(); defaultMode == ResponseModeFragment && responseModeTransport(ar.GetResponseMode(), defaultMode) == ResponseModeQuery {
 		return nil, ErrUnsupportedResponseMode.WithHintf("Insecure response_mode '%s' for the response_type '%s'.", ar.GetResponseMode(), ar.GetResponseTypes())
 	}
 
+	if err := f.runAuthorizeResponseHooks(ctx, ar, resp); err != nil {
+		return nil, err
+	}
+
 	return resp, nil
 }
+
+func (f *Fosite) validateStateNotReplayed(ctx context.Context, ar AuthorizeRequester) error {
+	if f.StateReplayStore == nil || ar.GetState() == "" {
+		return nil
+	}
+
+	err := f.StateReplayStore.SetStateUsed(ctx, ar.GetClient().GetID(), ar.GetState(), f.Clock.Now().Add(f.GetStateReplayWindow()))
+	if errors.Is(err, ErrInvalidState) {
+		return errors.WithStack(ErrInvalidState.WithHint("Request parameter 'state' has already been used by this client and must not be reused.").WithParameter("state"))
+	} else if err != nil {
+		return errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+// AuthorizeResponseHook is called after the authorize endpoint handlers populated the authorization response and can be
+// used to add custom parameters, for example "session_state" for OpenID Connect Session Management. The parameters are
+// included in the response regardless of the response mode.
+//
+// Hooks may only add parameters. Parameters which were set by the handlers, such as "state" or "code", can not be
+// changed by hooks.
+type AuthorizeResponseHook func(ctx context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error
+
+func (f *Fosite) runAuthorizeResponseHooks(ctx context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+	if len(f.AuthorizeResponseHooks) == 0 {
+		return nil
+	}
+
+	core := url.Values{}
+	for k, v := range resp.GetParameters() {
+		core[k] = append([]string{}, v...)
+	}
+
+	for _, hook := range f.AuthorizeResponseHooks {
+		if err := hook(ctx, ar, resp); err != nil {
+			return err
+		}
+	}
+
+	parameters := resp.GetParameters()
+	for k, v := range core {
+		parameters[k] = v
+	}
+
+	return nil
+}
@@ -23,14 +23,22 @@ package fosite_test
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	. "github.com/ory/fosite"
 	. "github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestNewAuthorizeResponse(t *testing.T) {
@@ -47,6 +55,7 @@ func TestNewAuthorizeResponse(t *testing.T) {
 		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{handlers[0], handlers[0]},
 	}
 	ar.EXPECT().SetSession(gomock.Eq(new(DefaultSession))).AnyTimes()
+	ar.EXPECT().GetState().Return("some-state").AnyTimes()
 	fooErr := errors.New("foo")
 	for k, c := range []struct {
 		isErr     bool
@@ -115,3 +124,167 @@ func TestNewAuthorizeResponse(t *testing.T) {
 		t.Logf("Passed test case %d", k)
 	}
 }
+
+type codeAuthorizeEndpointHandler struct{}
+
+func (codeAuthorizeEndpointHandler) HandleAuthorizeEndpointRequest(_ context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+	ar.SetDefaultResponseMode(ResponseModeQuery)
+	resp.AddParameter("code", "some-code")
+	resp.AddParameter("state", ar.GetState())
+	ar.SetResponseTypeHandled("code")
+	return nil
+}
+
+func TestAuthorizeResponseHooks(t *testing.T) {
+	f := &Fosite{
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+		AuthorizeResponseHooks: []AuthorizeResponseHook{
+			func(_ context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+				resp.AddParameter("session_state", "some-session-state")
+				return nil
+			},
+			func(_ context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+				// Hooks must not be able to change the state.
+				resp.GetParameters().Set("state", "tampered-state")
+				resp.AddParameter("state", "another-state")
+				return nil
+			},
+		},
+	}
+
+	for _, responseMode := range []ResponseModeType{ResponseModeDefault, ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost, ResponseModeWebMessage} {
+		t.Run(fmt.Sprintf("response_mode=%s", responseMode), func(t *testing.T) {
+			ar := NewAuthorizeRequest()
+			ar.ResponseTypes = Arguments{"code"}
+			ar.ResponseMode = responseMode
+			ar.State = "some-state"
+			ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+
+			resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			f.WriteAuthorizeResponse(rw, ar, resp)
+
+			var parameters url.Values
+			switch responseMode {
+			case ResponseModeFormPost:
+				var code, state string
+				code, state, _, _, parameters, _, err = ParseFormPostResponse("https://foobar.com/cb", ioutil.NopCloser(rw.Body))
+				require.NoError(t, err)
+				parameters.Set("code", code)
+				parameters.Set("state", state)
+			case ResponseModeWebMessage:
+				_, parameters = parseWebMessageResponse(t, rw.Body.String())
+			case ResponseModeFragment:
+				location, err := url.Parse(rw.Header().Get("Location"))
+				require.NoError(t, err)
+				parameters, err = url.ParseQuery(location.Fragment)
+				require.NoError(t, err)
+			default:
+				location, err := url.Parse(rw.Header().Get("Location"))
+				require.NoError(t, err)
+				parameters = location.Query()
+			}
+
+			assert.Equal(t, "some-session-state", parameters.Get("session_state"))
+			assert.Equal(t, "some-state", parameters.Get("state"))
+			assert.Equal(t, "some-code", parameters.Get("code"))
+		})
+	}
+}
+
+func TestAuthorizeResponseHookError(t *testing.T) {
+	hookErr := errors.New("hook failed")
+	f := &Fosite{
+		AuthorizeEndpointHandlers: AuthorizeEndpointHandlers{codeAuthorizeEndpointHandler{}},
+		AuthorizeResponseHooks: []AuthorizeResponseHook{
+			func(_ context.Context, ar AuthorizeRequester, resp AuthorizeResponder) error {
+				return hookErr
+			},
+		},
+	}
+
+	ar := NewAuthorizeRequest()
+	ar.ResponseTypes = Arguments{"code"}
+	resp, err := f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+	assert.Equal(t, hookErr, err)
+	assert.Nil(t, resp)
+}
+
+func TestNewAuthorizeResponseStateReplay(t *testing.T) {
+	store := storage.NewMemoryStore()
+	for _, id := range []string{"foo", "bar"} {
+		store.Clients[id] = &DefaultClient{
+			ID:            id,
+			RedirectURIs:  []string{"https://foo.bar/cb"},
+			ResponseTypes: []string{"none"},
+			Scopes:        []string{"foo"},
+		}
+	}
+
+	authorize := func(f *Fosite, clientID, state string) error {
+		ar, err := f.NewAuthorizeRequest(context.Background(), &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: url.Values{
+			"redirect_uri":  {"https://foo.bar/cb"},
+			"client_id":     {clientID},
+			"response_type": {"none"},
+			"scope":         {"foo"},
+			"state":         {state},
+		}.Encode()}})
+		if err != nil {
+			return err
+		}
+
+		_, err = f.NewAuthorizeResponse(context.Background(), ar, new(DefaultSession))
+		return err
+	}
+
+	t.Run("case=reused state is accepted if no replay store is set", func(t *testing.T) {
+		f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy}
+		for i := 0; i < 2; i++ {
+			require.NoError(t, authorize(f, "foo", "some-reused-state"))
+		}
+	})
+
+	f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy, StateReplayStore: store}
+
+	t.Run("case=state is not marked as used by the authorize request", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := f.NewAuthorizeRequest(context.Background(), &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: url.Values{
+				"redirect_uri":  {"https://foo.bar/cb"},
+				"client_id":     {"foo"},
+				"response_type": {"none"},
+				"scope":         {"foo"},
+				"state":         {"some-consent-state"},
+			}.Encode()}})
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("case=fresh state is accepted", func(t *testing.T) {
+		require.NoError(t, authorize(f, "foo", "some-fresh-state"))
+	})
+
+	t.Run("case=reused state is rejected", func(t *testing.T) {
+		assert.EqualError(t, authorize(f, "foo", "some-fresh-state"), ErrInvalidState.Error())
+	})
+
+	t.Run("case=state used by another client is accepted", func(t *testing.T) {
+		require.NoError(t, authorize(f, "bar", "some-fresh-state"))
+	})
+
+	t.Run("case=expired state is accepted again", func(t *testing.T) {
+		f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy, StateReplayStore: store, StateReplayWindow: -time.Second}
+		for i := 0; i < 2; i++ {
+			require.NoError(t, authorize(f, "foo", "some-expiring-state"))
+		}
+	})
+
+	t.Run("case=the replay window is computed with the clock", func(t *testing.T) {
+		// The replay window ended an hour ago according to the clock.
+		f := &Fosite{Store: store, ScopeStrategy: ExactScopeStrategy, AudienceMatchingStrategy: DefaultAudienceMatchingStrategy, StateReplayStore: store, Clock: func() time.Time { return time.Now().Add(-2 * time.Hour) }}
+		for i := 0; i < 2; i++ {
+			require.NoError(t, authorize(f, "foo", "some-clocked-state"))
+		}
+	})
+}
@@ -37,18 +37,29 @@ func (f *Fosite) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequ
 	wh.Set("Pragma", "no-cache")
 
 	redir := ar.GetRedirectURI()
-	switch ar.GetResponseMode() {
-	case ResponseModeFormPost:
-		//form_post
-		rw.Header().Add("Content-Type", "text/html;charset=UTF-8")
-		WriteAuthorizeFormPostResponse(redir.String(), resp.GetParameters(), GetPostFormHTMLTemplate(*f), rw)
+	responseMode := ar.GetResponseMode()
+	parameters := resp.GetParameters()
+	if IsJWTResponseMode(responseMode) {
+		signed, err := f.signAuthorizeResponse(ar, parameters)
+		if err != nil {
+			f.WriteAuthorizeError(rw, ar, err)
+			return
+		}
+		parameters = signed
+		responseMode = responseModeTransport(responseMode, ar.GetDefaultResponseMode())
+	}
+
+	switch responseMode {
+	case ResponseModeFormPost, ResponseModeWebMessage:
+		if err := f.writeAuthorizeHTMLResponse(rw, responseMode, redir, parameters); err != nil {
+			f.WriteAuthorizeError(rw, ar, err)
+		}
 		return
 	case ResponseModeQuery, ResponseModeDefault:
 		// Explicit grants
 		q := redir.Query()
-		rq := resp.GetParameters()
-		for k := range rq {
-			q.Set(k, rq.Get(k))
+		for k := range parameters {
+			q.Set(k, parameters.Get(k))
 		}
 		redir.RawQuery = q.Encode()
 		sendRedirect(redir.String(), rw)
@@ -57,7 +68,7 @@ func (f *Fosite) WriteAuthorizeResponse(rw http.ResponseWriter, ar AuthorizeRequ
 		// Implicit grants
 		// The endpoint URI MUST NOT include a fragment component.
 		redir.Fragment = ""
-		URLSetFragment(redir, resp.GetParameters())
+		URLSetFragment(redir, parameters)
 		sendRedirect(redir.String(), rw)
 		return
 	}
@@ -0,0 +1,96 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WriteBearerError writes the error of a request to a resource server whose bearer token was rejected, for example
+// by IntrospectToken. The error is mapped to one of the error codes defined in
+// https://tools.ietf.org/html/rfc6750#section-3.1 and sent with a WWW-Authenticate challenge:
+//
+// * invalid_request (400) if the request is malformed,
+// * insufficient_scope (403) if the token was not granted the required scopes and
+// * invalid_token (401) if the token is expired, revoked, malformed, or otherwise invalid.
+//
+// ErrMissingBearerToken is written as 401 with a challenge but without error code or body, as the request lacks any
+// authentication information. Server errors are written without a challenge.
+func (f *Fosite) WriteBearerError(rw http.ResponseWriter, err error) {
+	rfcerr := f.clientFacingError(toBearerTokenError(err))
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	if rfcerr.Is(ErrMissingBearerToken) {
+		rw.Header().Set("WWW-Authenticate", f.bearerChallenge("", rfcerr))
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	} else if rfcerr.Is(ErrInvalidRequest) {
+		rw.Header().Set("WWW-Authenticate", f.bearerChallenge(errInvalidRequestName, rfcerr))
+	}
+
+	f.writeRFC6749Error(rw, rfcerr)
+}
+
+// toBearerTokenError maps the error to one of the errors of bearer token protected resources.
+func toBearerTokenError(err error) *RFC6749Error {
+	rfcerr := ErrorToRFC6749Error(err)
+	switch {
+	case rfcerr.Is(ErrMissingBearerToken), rfcerr.Is(ErrInvalidRequest), rfcerr.Is(ErrInsufficientScope), rfcerr.Is(ErrInvalidToken) && rfcerr.Code == http.StatusUnauthorized:
+		return rfcerr
+	case rfcerr.Is(ErrScopeNotGranted), rfcerr.Is(ErrInvalidScope):
+		return ErrInsufficientScope.WithHint(rfcerr.Hint).WithDebug(rfcerr.DebugField).WithCause(err)
+	case rfcerr.Code >= http.StatusInternalServerError:
+		return rfcerr
+	}
+	return ErrInvalidToken.WithHint(rfcerr.Hint).WithDebug(rfcerr.DebugField).WithCause(err)
+}
+
+// bearerErrorCode returns the error code of the WWW-Authenticate challenge if the error rejects a bearer token.
+func bearerErrorCode(rfcerr *RFC6749Error) string {
+	switch {
+	case rfcerr.Is(ErrInsufficientScope):
+		return errInsufficientScopeName
+	case rfcerr.Is(ErrRequestUnauthorized), rfcerr.Is(ErrInvalidToken) && rfcerr.Code == http.StatusUnauthorized:
+		return errInvalidTokenFormatName
+	}
+	return ""
+}
+
+// bearerChallenge returns the value of the WWW-Authenticate header for the error, see
+// https://tools.ietf.org/html/rfc6750#section-3. The challenge has no error attributes if code is empty.
+func (f *Fosite) bearerChallenge(code string, rfcerr *RFC6749Error) string {
+	var params []string
+	if f.BearerRealm != "" {
+		params = append(params, fmt.Sprintf(`realm="%s"`, f.BearerRealm))
+	}
+	if code != "" {
+		params = append(params, fmt.Sprintf(`error="%s"`, code), fmt.Sprintf(`error_description="%s"`, rfcerr.GetDescription()))
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestWriteBearerError(t *testing.T) {
+	for k, c := range []struct {
+		d               string
+		err             error
+		realm           string
+		expectStatus    int
+		expectName      string
+		expectChallenge string
+	}{
+		{
+			d:               "should respond with invalid_token for expired tokens",
+			err:             ErrTokenExpired.WithHint("Token expired at foo."),
+			realm:           "example",
+			expectStatus:    http.StatusUnauthorized,
+			expectName:      "invalid_token",
+			expectChallenge: `Bearer realm="example", error="invalid_token", error_description="The access token provided is expired, revoked, malformed, or invalid for other reasons."`,
+		},
+		{
+			d:               "should respond with invalid_token for unknown tokens",
+			err:             ErrRequestUnauthorized,
+			expectStatus:    http.StatusUnauthorized,
+			expectName:      "invalid_token",
+			expectChallenge: `Bearer error="invalid_token", error_description="The access token provided is expired, revoked, malformed, or invalid for other reasons."`,
+		},
+		{
+			d:               "should respond with insufficient_scope for scopes which were not granted",
+			err:             errors.WithStack(ErrInvalidScope.WithHint("The request scope 'foo' has not been granted.")),
+			realm:           "example",
+			expectStatus:    http.StatusForbidden,
+			expectName:      "insufficient_scope",
+			expectChallenge: `Bearer realm="example", error="insufficient_scope", error_description="The request requires higher privileges than provided by the access token."`,
+		},
+		{
+			d:               "should respond with invalid_request for malformed requests",
+			err:             ErrInvalidRequest.WithHint("Multiple tokens were sent."),
+			realm:           "example",
+			expectStatus:    http.StatusBadRequest,
+			expectName:      "invalid_request",
+			expectChallenge: `Bearer realm="example", error="invalid_request", error_description="` + ErrInvalidRequest.Description + `"`,
+		},
+		{
+			d:               "should respond with a challenge without error code if the token is missing",
+			err:             errors.WithStack(ErrMissingBearerToken),
+			realm:           "example",
+			expectStatus:    http.StatusUnauthorized,
+			expectChallenge: `Bearer realm="example"`,
+		},
+		{
+			d:               "should respond with a bare challenge if the token is missing and no realm is set",
+			err:             ErrMissingBearerToken,
+			expectStatus:    http.StatusUnauthorized,
+			expectChallenge: `Bearer`,
+		},
+		{
+			d:            "should respond without challenge for server errors",
+			err:          errors.New("database is down"),
+			realm:        "example",
+			expectStatus: http.StatusInternalServerError,
+			expectName:   "error",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{BearerRealm: c.realm}
+			rw := httptest.NewRecorder()
+			f.WriteBearerError(rw, c.err)
+
+			assert.Equal(t, c.expectStatus, rw.Code)
+			assert.Equal(t, c.expectChallenge, rw.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+			if c.expectName == "" {
+				assert.Empty(t, rw.Body.String())
+				return
+			}
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+			assert.Equal(t, c.expectName, body["error"])
+		})
+	}
+}
+
+func TestWriteIntrospectionErrorBearerRealm(t *testing.T) {
+	f := &Fosite{BearerRealm: "example"}
+	rw := httptest.NewRecorder()
+	f.WriteIntrospectionError(rw, ErrRequestUnauthorized)
+
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, `Bearer realm="example", error="invalid_token", error_description="The request could not be authorized."`, rw.Header().Get("WWW-Authenticate"))
+}
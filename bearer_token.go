@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BearerTokenLocation is a part of a request to a resource server which may carry the bearer token.
+type BearerTokenLocation string
+
+const (
+	// BearerTokenLocationHeader is the Authorization request header using the Bearer scheme, see
+	// https://tools.ietf.org/html/rfc6750#section-2.1. ValidateToken also accepts the DPoP scheme from this location.
+	BearerTokenLocationHeader BearerTokenLocation = "header"
+
+	// BearerTokenLocationForm is the access_token parameter of a form-encoded request body, see
+	// https://tools.ietf.org/html/rfc6750#section-2.2
+	BearerTokenLocationForm BearerTokenLocation = "form"
+
+	// BearerTokenLocationQuery is the access_token URI query parameter, see
+	// https://tools.ietf.org/html/rfc6750#section-2.3. Tokens in the URI are likely to be logged and leaked, so this
+	// location should only be enabled if the other locations can not be used.
+	BearerTokenLocationQuery BearerTokenLocation = "query"
+
+	// BearerTokenLocationCustomHeader is the request header named by BearerTokenHeader, which carries the token
+	// without an authorization scheme.
+	BearerTokenLocationCustomHeader BearerTokenLocation = "custom_header"
+)
+
+// DefaultBearerTokenLocations are the locations AccessTokenFromRequest takes the token from.
+var DefaultBearerTokenLocations = []BearerTokenLocation{BearerTokenLocationHeader, BearerTokenLocationForm, BearerTokenLocationQuery}
+
+// GetBearerTokenLocations returns BearerTokenLocations if set. Defaults to DefaultBearerTokenLocations.
+func (f *Fosite) GetBearerTokenLocations() []BearerTokenLocation {
+	if f.BearerTokenLocations == nil {
+		return DefaultBearerTokenLocations
+	}
+	return f.BearerTokenLocations
+}
+
+// BearerTokenFromRequest returns the bearer token of a request to a resource server, looking at the
+// BearerTokenLocations in order. It returns an empty string if none of the locations carries a token.
+func (f *Fosite) BearerTokenFromRequest(r *http.Request) string {
+	for _, location := range f.GetBearerTokenLocations() {
+		if token := f.bearerTokenFromLocation(r, location); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+func (f *Fosite) bearerTokenFromLocation(r *http.Request, location BearerTokenLocation) string {
+	switch location {
+	case BearerTokenLocationHeader:
+		return authorizationToken(r, "bearer")
+	case BearerTokenLocationForm:
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			return ""
+		}
+		return r.PostForm.Get("access_token")
+	case BearerTokenLocationQuery:
+		return r.URL.Query().Get("access_token")
+	case BearerTokenLocationCustomHeader:
+		if f.BearerTokenHeader != "" {
+			return r.Header.Get(f.BearerTokenHeader)
+		}
+	}
+	return ""
+}
+
+// dpopTokenFromRequest returns the DPoP-bound access token of a request using the DPoP authorization scheme, see
+// RFC 9449 Section 7.1. The token must only be accepted together with a valid DPoP proof.
+func (f *Fosite) dpopTokenFromRequest(r *http.Request) string {
+	for _, location := range f.GetBearerTokenLocations() {
+		if location == BearerTokenLocationHeader {
+			return authorizationToken(r, "dpop")
+		}
+	}
+	return ""
+}
+
+func authorizationToken(r *http.Request, scheme string) string {
+	split := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(split) == 2 && strings.EqualFold(split[0], scheme) {
+		return split[1]
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/ory/fosite"
+)
+
+func TestBearerTokenFromRequest(t *testing.T) {
+	newRequest := func(header http.Header, query url.Values, form url.Values) *http.Request {
+		method, body := "GET", ""
+		if form != nil {
+			method, body = "POST", form.Encode()
+		}
+		r, _ := http.NewRequest(method, "https://www.ory.sh/api?"+query.Encode(), strings.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		if form != nil {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		return r
+	}
+
+	for k, c := range []struct {
+		d         string
+		locations []BearerTokenLocation
+		header    http.Header
+		query     url.Values
+		form      url.Values
+		expect    string
+	}{
+		{
+			d:      "should take the token from the authorization header",
+			header: http.Header{"Authorization": {"Bearer header-token"}},
+			expect: "header-token",
+		},
+		{
+			d:      "should ignore the dpop authorization scheme because the proof is not validated",
+			header: http.Header{"Authorization": {"DPoP header-token"}},
+		},
+		{
+			d:      "should ignore other authorization schemes",
+			header: http.Header{"Authorization": {"Basic Zm9vOmJhcg=="}},
+		},
+		{
+			d:      "should take the token from the form",
+			form:   url.Values{"access_token": {"form-token"}},
+			expect: "form-token",
+		},
+		{
+			d:      "should take the token from the query",
+			query:  url.Values{"access_token": {"query-token"}},
+			expect: "query-token",
+		},
+		{
+			d:         "should take the token from the custom header",
+			locations: []BearerTokenLocation{BearerTokenLocationCustomHeader},
+			header:    http.Header{"X-Access-Token": {"custom-token"}},
+			expect:    "custom-token",
+		},
+		{
+			d:      "should prefer the authorization header by default",
+			header: http.Header{"Authorization": {"Bearer header-token"}},
+			query:  url.Values{"access_token": {"query-token"}},
+			form:   url.Values{"access_token": {"form-token"}},
+			expect: "header-token",
+		},
+		{
+			d:      "should prefer the form over the query by default",
+			query:  url.Values{"access_token": {"query-token"}},
+			form:   url.Values{"access_token": {"form-token"}},
+			expect: "form-token",
+		},
+		{
+			d:         "should follow the configured precedence",
+			locations: []BearerTokenLocation{BearerTokenLocationQuery, BearerTokenLocationCustomHeader, BearerTokenLocationHeader},
+			header:    http.Header{"Authorization": {"Bearer header-token"}, "X-Access-Token": {"custom-token"}},
+			query:     url.Values{"access_token": {"query-token"}},
+			expect:    "query-token",
+		},
+		{
+			d:         "should fall back to the next configured location",
+			locations: []BearerTokenLocation{BearerTokenLocationCustomHeader, BearerTokenLocationHeader},
+			header:    http.Header{"Authorization": {"Bearer header-token"}},
+			expect:    "header-token",
+		},
+		{
+			d:         "should reject tokens in the query if the query is disabled",
+			locations: []BearerTokenLocation{BearerTokenLocationHeader, BearerTokenLocationForm},
+			query:     url.Values{"access_token": {"query-token"}},
+		},
+		{
+			d:         "should not take the query for the form",
+			locations: []BearerTokenLocation{BearerTokenLocationForm},
+			query:     url.Values{"access_token": {"query-token"}},
+			form:      url.Values{"foo": {"bar"}},
+		},
+		{
+			d:      "should reject tokens in the custom header unless enabled",
+			header: http.Header{"X-Access-Token": {"custom-token"}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{BearerTokenLocations: c.locations, BearerTokenHeader: "X-Access-Token"}
+			assert.Equal(t, c.expect, f.BearerTokenFromRequest(newRequest(c.header, c.query, c.form)))
+		})
+	}
+}
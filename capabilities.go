@@ -0,0 +1,120 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "strings"
+
+// Capabilities describes the grant types, response types, response modes, PKCE code challenge methods and client
+// authentication methods enabled in an ExtendedOAuth2Provider. It can be used to generate OpenID Connect Discovery or
+// OAuth 2.0 Authorization Server Metadata documents.
+type Capabilities struct {
+	GrantTypes               []string
+	ResponseTypes            []string
+	ResponseModes            []ResponseModeType
+	CodeChallengeMethods     []string
+	TokenEndpointAuthMethods []string
+}
+
+// CapabilitiesHandler is implemented by handlers which enable grant types, response types or PKCE code challenge
+// methods. Fosite.Capabilities collects the capabilities of all registered handlers that implement it.
+type CapabilitiesHandler interface {
+	// Capabilities returns the capabilities enabled by the handler.
+	Capabilities() Capabilities
+}
+
+// Capabilities returns the capabilities enabled by the registered handlers. Capabilities are reported in the order
+// in which the handlers were registered and each value is only reported once.
+func (f *Fosite) Capabilities() Capabilities {
+	var c Capabilities
+	var handlers []interface{}
+	for _, h := range f.AuthorizeEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+	for _, h := range f.TokenEndpointHandlers {
+		handlers = append(handlers, h)
+	}
+
+	for _, h := range handlers {
+		ch, ok := h.(CapabilitiesHandler)
+		if !ok {
+			continue
+		}
+
+		hc := ch.Capabilities()
+		c.GrantTypes = appendUnique(c.GrantTypes, hc.GrantTypes...)
+		c.ResponseTypes = appendUnique(c.ResponseTypes, hc.ResponseTypes...)
+		c.CodeChallengeMethods = appendUnique(c.CodeChallengeMethods, hc.CodeChallengeMethods...)
+	}
+
+	if len(f.AuthorizeEndpointHandlers) > 0 {
+		// Response type "none" and the response modes are handled by fosite itself.
+		c.ResponseTypes = appendUnique(c.ResponseTypes, "none")
+		c.ResponseModes = []ResponseModeType{ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost, ResponseModeWebMessage}
+		if f.JARMSigningKey != nil {
+			c.ResponseModes = append(c.ResponseModes, ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT)
+		}
+	}
+
+	if len(f.TokenEndpointHandlers) > 0 {
+		c.TokenEndpointAuthMethods = f.tokenEndpointAuthMethods()
+	}
+
+	return c
+}
+
+// tokenEndpointAuthMethods returns the client authentication methods supported by the configuration. JWT client
+// assertions can only be validated if the token endpoint URL or the issuer is known, and only with the methods whose
+// signing algorithms are allowed by GetTokenEndpointAuthSigningAlgorithms.
+func (f *Fosite) tokenEndpointAuthMethods() []string {
+	methods := []string{"client_secret_basic", "client_secret_post", "none", TLSClientAuthMethod, SelfSignedTLSClientAuthMethod}
+	if f.TokenURL == "" && f.IssuerFromRequest == nil {
+		return methods
+	}
+
+	var symmetric, asymmetric bool
+	for _, alg := range f.GetTokenEndpointAuthSigningAlgorithms() {
+		switch {
+		case alg == "none":
+		case strings.HasPrefix(alg, "HS"):
+			symmetric = true
+		default:
+			asymmetric = true
+		}
+	}
+
+	if symmetric {
+		methods = append(methods, "client_secret_jwt")
+	}
+	if asymmetric {
+		methods = append(methods, "private_key_jwt")
+	}
+	return methods
+}
+
+func appendUnique(items []string, values ...string) []string {
+	for _, v := range values {
+		if !StringInSlice(v, items) {
+			items = append(items, v)
+		}
+	}
+	return items
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// ProtectedClaims are the claims a ClaimsEnrichmentHook can not set, as they determine who issued a token, who it is
+// about, who it is intended for and how long it is valid.
+var ProtectedClaims = []string{"iss", "exp", "sub", "aud"}
+
+// ClaimsEnrichmentHook returns additional claims for the ID token or JWT access token issued for the request, for
+// example a "tenant_id" claim derived from the session. Use it instead of adding the claims to every session.
+type ClaimsEnrichmentHook func(ctx context.Context, tokenType TokenType, requester Requester) (map[string]interface{}, error)
+
+// Enrich adds the claims returned by the hook to claims. Like the claims of a ScopeClaimMapper, they never replace claims
+// which are already set, and the ProtectedClaims and the additionally protected claims are never added. It does
+// nothing if the hook is nil.
+func (h ClaimsEnrichmentHook) Enrich(ctx context.Context, tokenType TokenType, requester Requester, claims map[string]interface{}, protected ...string) error {
+	if h == nil {
+		return nil
+	}
+
+	extra, err := h(ctx, tokenType, requester)
+	if err != nil {
+		return err
+	}
+
+	skip := map[string]bool{}
+	for _, claim := range ProtectedClaims {
+		skip[claim] = true
+	}
+	for _, claim := range protected {
+		skip[claim] = true
+	}
+
+	for k, v := range extra {
+		if _, ok := claims[k]; !ok && !skip[k] {
+			claims[k] = v
+		}
+	}
+	return nil
+}
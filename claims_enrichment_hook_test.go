@@ -0,0 +1,42 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimsEnrichmentHook(t *testing.T) {
+	claims := map[string]interface{}{"sub": "peter", "jti": "foo", "scp": "read"}
+	require.NoError(t, ClaimsEnrichmentHook(nil).Enrich(context.Background(), AccessToken, NewRequest(), claims))
+	assert.Equal(t, map[string]interface{}{"sub": "peter", "jti": "foo", "scp": "read"}, claims)
+
+	hook := ClaimsEnrichmentHook(func(context.Context, TokenType, Requester) (map[string]interface{}, error) {
+		return map[string]interface{}{"tenant_id": "acme", "sub": "mallory", "iss": "evil", "jti": "bar", "scp": "admin", "nonce": "forged"}, nil
+	})
+	require.NoError(t, hook.Enrich(context.Background(), AccessToken, NewRequest(), claims, "jti", "nonce"))
+	assert.Equal(t, map[string]interface{}{"sub": "peter", "jti": "foo", "tenant_id": "acme", "scp": "read"}, claims)
+}
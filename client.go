@@ -21,7 +21,10 @@
 
 package fosite
 
-import jose "gopkg.in/square/go-jose.v2"
+import (
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
 
 // Client represents a client or an app.
 type Client interface {
@@ -52,6 +55,49 @@ type Client interface {
 	GetAudience() Arguments
 }
 
+const (
+	// ClientTypePublic is the type of clients which can not keep their credentials confidential, see
+	// https://tools.ietf.org/html/rfc6749#section-2.1
+	ClientTypePublic = "public"
+
+	// ClientTypeConfidential is the type of clients capable of keeping their credentials confidential.
+	ClientTypeConfidential = "confidential"
+)
+
+// GetClientType returns ClientTypePublic for public clients and ClientTypeConfidential otherwise.
+func GetClientType(c Client) string {
+	if IsPublicClient(c) {
+		return ClientTypePublic
+	}
+	return ClientTypeConfidential
+}
+
+// IsPublicClient returns true if the client is marked as public or declares the token endpoint authentication method
+// "none". Public clients authenticate at the token endpoint with only their client id.
+func IsPublicClient(c Client) bool {
+	return c.IsPublic() || GetClientTokenEndpointAuthMethod(c) == "none"
+}
+
+// GetClientTokenEndpointAuthMethod returns the token endpoint authentication method of OpenID Connect clients. Other
+// clients use "none" if they are public and "client_secret_basic" otherwise, which is also the default of OpenID
+// Connect Dynamic Client Registration.
+func GetClientTokenEndpointAuthMethod(c Client) string {
+	if oidcClient, ok := c.(OpenIDConnectClient); ok && oidcClient.GetTokenEndpointAuthMethod() != "" {
+		return oidcClient.GetTokenEndpointAuthMethod()
+	} else if c.IsPublic() {
+		return "none"
+	}
+	return "client_secret_basic"
+}
+
+// ValidateClientGrantType returns ErrUnauthorizedClient naming the grant type if the client is not allowed to use it.
+func ValidateClientGrantType(c Client, grantType string) error {
+	if !c.GetGrantTypes().Has(grantType) {
+		return errors.WithStack(ErrUnauthorizedClient.WithHintf("The OAuth 2.0 Client is not allowed to use authorization grant '%s'.", grantType))
+	}
+	return nil
+}
+
 // OpenIDConnectClient represents a client capable of performing OpenID Connect requests.
 type OpenIDConnectClient interface {
 	// GetRequestURIs is an array of request_uri values that are pre-registered by the RP for use at the OP. Servers MAY
@@ -80,6 +126,110 @@ type OpenIDConnectClient interface {
 	GetTokenEndpointAuthSigningAlgorithm() string
 }
 
+// ClientWithSecretJWT represents a client capable of authenticating at the token endpoint using the
+// client_secret_jwt method.
+type ClientWithSecretJWT interface {
+	// GetTokenEndpointAuthSecret returns the secret shared with the client in plain text. It is used as the key
+	// for verifying HMAC signed client assertions. Because GetHashedSecret only returns the hashed secret, this
+	// value has to be stored separately and should be encrypted at rest.
+	GetTokenEndpointAuthSecret() []byte
+}
+
+// ClientWithRotatedSecret represents a client whose secret is being rotated. During the rotation the client may
+// authenticate with either its current secret or the rotated, previous one.
+type ClientWithRotatedSecret interface {
+	// GetRotatedHashedSecret returns the hashed previous secret of the client, or nil once it was retired.
+	GetRotatedHashedSecret() []byte
+}
+
+// ClientWithDefaultScopes represents a client which requests default scopes when it omits the scope parameter. The
+// default scopes are validated like requested scopes, so they must be within the client's scopes, see
+// Client.GetScopes, which are the maximum a client may request.
+type ClientWithDefaultScopes interface {
+	// GetDefaultScopes returns the scopes requested on behalf of the client if it omits the scope parameter.
+	GetDefaultScopes() Arguments
+}
+
+// BackChannelLogoutClient represents a client capable of receiving OpenID Connect Back-Channel Logout requests.
+type BackChannelLogoutClient interface {
+	// GetBackChannelLogoutURI returns the RP URL that will cause the RP to log itself out when sent a Logout Token
+	// by the OP.
+	GetBackChannelLogoutURI() string
+
+	// GetBackChannelLogoutSessionRequired returns true if the RP requires that a sid (session ID) Claim be included
+	// in the Logout Token to identify the RP session with the OP when the backchannel_logout_uri is used.
+	GetBackChannelLogoutSessionRequired() bool
+}
+
+const (
+	// WebApplicationType is the application type of web based clients.
+	WebApplicationType = "web"
+
+	// NativeApplicationType is the application type of native apps, see https://tools.ietf.org/html/rfc8252.
+	NativeApplicationType = "native"
+)
+
+// ApplicationTypeClient represents a client which declares its application type as defined by OpenID Connect
+// Dynamic Client Registration 1.0.
+type ApplicationTypeClient interface {
+	// GetApplicationType returns the kind of the application, either WebApplicationType or NativeApplicationType.
+	GetApplicationType() string
+}
+
+const (
+	// PublicSubjectType is the subject type of clients which receive the same subject identifier as all other clients.
+	PublicSubjectType = "public"
+
+	// PairwiseSubjectType is the subject type of clients which receive a subject identifier unique to their sector, see
+	// https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+	PairwiseSubjectType = "pairwise"
+)
+
+// SubjectTypeClient represents a client which declares the subject type it requires as defined by OpenID Connect
+// Dynamic Client Registration 1.0.
+type SubjectTypeClient interface {
+	// GetSubjectType returns the subject type requested for responses to this client, either PublicSubjectType or
+	// PairwiseSubjectType.
+	GetSubjectType() string
+
+	// GetSectorIdentifierURI returns the URL whose host is used as the sector identifier when computing pairwise
+	// subject identifiers.
+	GetSectorIdentifierURI() string
+}
+
+// UserInfoClient represents a client which declares how UserInfo responses are returned to it as defined by OpenID
+// Connect Dynamic Client Registration 1.0. If neither signing nor encryption is requested, the UserInfo response is
+// returned as JSON.
+type UserInfoClient interface {
+	// GetUserInfoSigningAlgorithm returns the JWS alg algorithm required for signing UserInfo responses
+	// (userinfo_signed_response_alg).
+	GetUserInfoSigningAlgorithm() string
+
+	// GetUserInfoEncryptionAlgorithm returns the JWE alg algorithm required for encrypting UserInfo responses
+	// (userinfo_encrypted_response_alg).
+	GetUserInfoEncryptionAlgorithm() string
+
+	// GetUserInfoEncryptionEncoding returns the JWE enc algorithm required for encrypting UserInfo responses
+	// (userinfo_encrypted_response_enc).
+	GetUserInfoEncryptionEncoding() string
+}
+
+// IDTokenSigningAlgorithmClient represents a client which declares the algorithm ID Tokens issued to it must be
+// signed with as defined by OpenID Connect Dynamic Client Registration 1.0.
+type IDTokenSigningAlgorithmClient interface {
+	// GetIDTokenSignedResponseAlg returns the JWS alg algorithm required for signing ID Tokens
+	// (id_token_signed_response_alg). If empty, the authorization server's default algorithm is used.
+	GetIDTokenSignedResponseAlg() string
+}
+
+// OfflineAccessClient represents a client which opts into the semantics of the offline_access scope as defined by
+// OpenID Connect Core 1.0 Section 11: refresh tokens are only issued for OpenID Connect requests if the offline_access
+// scope was granted.
+type OfflineAccessClient interface {
+	// GetRequireOfflineAccess returns true if refresh tokens require the offline_access scope for OpenID Connect requests.
+	GetRequireOfflineAccess() bool
+}
+
 // ResponseModeClient represents a client capable of handling response_mode
 type ResponseModeClient interface {
 	// GetResponseMode returns the response modes that client is allowed to send
@@ -90,10 +240,12 @@ type ResponseModeClient interface {
 type DefaultClient struct {
 	ID            string   `json:"id"`
 	Secret        []byte   `json:"client_secret,omitempty"`
+	RotatedSecret []byte   `json:"rotated_client_secret,omitempty"`
 	RedirectURIs  []string `json:"redirect_uris"`
 	GrantTypes    []string `json:"grant_types"`
 	ResponseTypes []string `json:"response_types"`
 	Scopes        []string `json:"scopes"`
+	DefaultScopes []string `json:"default_scopes,omitempty"`
 	Audience      []string `json:"audience"`
 	Public        bool     `json:"public"`
 }
@@ -106,6 +258,22 @@ type DefaultOpenIDConnectClient struct {
 	RequestURIs                       []string            `json:"request_uris"`
 	RequestObjectSigningAlgorithm     string              `json:"request_object_signing_alg"`
 	TokenEndpointAuthSigningAlgorithm string              `json:"token_endpoint_auth_signing_alg"`
+	TokenEndpointAuthSecret           []byte              `json:"-"`
+	BackChannelLogoutURI              string              `json:"backchannel_logout_uri"`
+	BackChannelLogoutSessionRequired  bool                `json:"backchannel_logout_session_required"`
+	TLSClientAuthSubjectDN            string              `json:"tls_client_auth_subject_dn"`
+	TLSClientAuthSANDNS               string              `json:"tls_client_auth_san_dns"`
+	TLSClientAuthSANURI               string              `json:"tls_client_auth_san_uri"`
+	TLSClientAuthSANIP                string              `json:"tls_client_auth_san_ip"`
+	TLSClientAuthSANEmail             string              `json:"tls_client_auth_san_email"`
+	ApplicationType                   string              `json:"application_type"`
+	SubjectType                       string              `json:"subject_type"`
+	SectorIdentifierURI               string              `json:"sector_identifier_uri"`
+	UserInfoSigningAlgorithm          string              `json:"userinfo_signed_response_alg"`
+	UserInfoEncryptionAlgorithm       string              `json:"userinfo_encrypted_response_alg"`
+	UserInfoEncryptionEncoding        string              `json:"userinfo_encrypted_response_enc"`
+	IDTokenSignedResponseAlg          string              `json:"id_token_signed_response_alg"`
+	RequireOfflineAccess              bool                `json:"require_offline_access"`
 }
 
 type DefaultResponseModeClient struct {
@@ -133,10 +301,18 @@ func (c *DefaultClient) GetHashedSecret() []byte {
 	return c.Secret
 }
 
+func (c *DefaultClient) GetRotatedHashedSecret() []byte {
+	return c.RotatedSecret
+}
+
 func (c *DefaultClient) GetScopes() Arguments {
 	return c.Scopes
 }
 
+func (c *DefaultClient) GetDefaultScopes() Arguments {
+	return c.DefaultScopes
+}
+
 func (c *DefaultClient) GetGrantTypes() Arguments {
 	// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
 	//
@@ -170,7 +346,9 @@ func (c *DefaultOpenIDConnectClient) GetJSONWebKeys() *jose.JSONWebKeySet {
 }
 
 func (c *DefaultOpenIDConnectClient) GetTokenEndpointAuthSigningAlgorithm() string {
-	if c.TokenEndpointAuthSigningAlgorithm == "" {
+	if c.TokenEndpointAuthSigningAlgorithm == "" && c.TokenEndpointAuthMethod == "client_secret_jwt" {
+		return "HS256"
+	} else if c.TokenEndpointAuthSigningAlgorithm == "" {
 		return "RS256"
 	} else {
 		return c.TokenEndpointAuthSigningAlgorithm
@@ -185,6 +363,70 @@ func (c *DefaultOpenIDConnectClient) GetTokenEndpointAuthMethod() string {
 	return c.TokenEndpointAuthMethod
 }
 
+func (c *DefaultOpenIDConnectClient) GetTokenEndpointAuthSecret() []byte {
+	return c.TokenEndpointAuthSecret
+}
+
+func (c *DefaultOpenIDConnectClient) GetBackChannelLogoutURI() string {
+	return c.BackChannelLogoutURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetBackChannelLogoutSessionRequired() bool {
+	return c.BackChannelLogoutSessionRequired
+}
+
+func (c *DefaultOpenIDConnectClient) GetApplicationType() string {
+	return c.ApplicationType
+}
+
+func (c *DefaultOpenIDConnectClient) GetSubjectType() string {
+	return c.SubjectType
+}
+
+func (c *DefaultOpenIDConnectClient) GetSectorIdentifierURI() string {
+	return c.SectorIdentifierURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetUserInfoSigningAlgorithm() string {
+	return c.UserInfoSigningAlgorithm
+}
+
+func (c *DefaultOpenIDConnectClient) GetUserInfoEncryptionAlgorithm() string {
+	return c.UserInfoEncryptionAlgorithm
+}
+
+func (c *DefaultOpenIDConnectClient) GetUserInfoEncryptionEncoding() string {
+	return c.UserInfoEncryptionEncoding
+}
+
+func (c *DefaultOpenIDConnectClient) GetIDTokenSignedResponseAlg() string {
+	return c.IDTokenSignedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetRequireOfflineAccess() bool {
+	return c.RequireOfflineAccess
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSubjectDN() string {
+	return c.TLSClientAuthSubjectDN
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANDNS() string {
+	return c.TLSClientAuthSANDNS
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANURI() string {
+	return c.TLSClientAuthSANURI
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANIP() string {
+	return c.TLSClientAuthSANIP
+}
+
+func (c *DefaultOpenIDConnectClient) GetTLSClientAuthSANEmail() string {
+	return c.TLSClientAuthSANEmail
+}
+
 func (c *DefaultOpenIDConnectClient) GetRequestURIs() []string {
 	return c.RequestURIs
 }
@@ -29,6 +29,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -43,7 +44,13 @@ func (f *Fosite) findClientPublicJWK(oidcClient OpenIDConnectClient, t *jwt.Toke
 		return findPublicKey(t, set, expectsRSAKey)
 	}
 
-	if location := oidcClient.GetJSONWebKeysURI(); len(location) > 0 {
+	return f.findClientPublicJWKFromURI(oidcClient, t, expectsRSAKey)
+}
+
+// findClientPublicJWKFromURI resolves the key from the client's jwks_uri. If no key can be found in the (possibly cached)
+// JSON Web Key Set, for example because the client rotated its keys, the key set is fetched again.
+func (f *Fosite) findClientPublicJWKFromURI(client ClientWithJWKSURI, t *jwt.Token, expectsRSAKey bool) (interface{}, error) {
+	if location := client.GetJSONWebKeysURI(); len(location) > 0 {
 		keys, err := f.JWKSFetcherStrategy.Resolve(location, false)
 		if err != nil {
 			return nil, err
@@ -91,7 +98,7 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 				}
 			}
 
-			client, err = f.Store.GetClient(ctx, clientID)
+			client, err = f.getClient(ctx, clientID)
 			if err != nil {
 				return nil, errors.WithStack(ErrInvalidClient.WithCause(err).WithDebug(err.Error()))
 			}
@@ -104,22 +111,33 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			switch oidcClient.GetTokenEndpointAuthMethod() {
 			case "private_key_jwt":
 				break
+			case "client_secret_jwt":
+				break
 			case "none":
 				return nil, errors.WithStack(ErrInvalidClient.WithHint("This requested OAuth 2.0 client does not support client authentication, however 'client_assertion' was provided in the request."))
 			case "client_secret_post":
 				fallthrough
 			case "client_secret_basic":
 				return nil, errors.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however 'client_assertion' was provided in the request.", oidcClient.GetTokenEndpointAuthMethod()))
-			case "client_secret_jwt":
-				fallthrough
 			default:
 				return nil, errors.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however that method is not supported by this server.", oidcClient.GetTokenEndpointAuthMethod()))
 			}
 
+			if alg := fmt.Sprintf("%s", t.Header["alg"]); alg == "none" || !Arguments(f.GetTokenEndpointAuthSigningAlgorithms()).Has(alg) {
+				return nil, errors.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' which is not allowed by this authorization server.", alg))
+			}
+
 			if oidcClient.GetTokenEndpointAuthSigningAlgorithm() != fmt.Sprintf("%s", t.Header["alg"]) {
 				return nil, errors.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' but the requested OAuth 2.0 Client enforces signing algorithm '%s'.", t.Header["alg"], oidcClient.GetTokenEndpointAuthSigningAlgorithm()))
 			}
 
+			if oidcClient.GetTokenEndpointAuthMethod() == "client_secret_jwt" {
+				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, errors.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' but client authentication method 'client_secret_jwt' requires a HMAC based signing algorithm.", t.Header["alg"]))
+				}
+				return findClientSecretJWTKey(client)
+			}
+
 			if _, ok := t.Method.(*jwt.SigningMethodRSA); ok {
 				return f.findClientPublicJWK(oidcClient, t, true)
 			} else if _, ok := t.Method.(*jwt.SigningMethodECDSA); ok {
@@ -127,7 +145,7 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			} else if _, ok := t.Method.(*jwt.SigningMethodRSAPSS); ok {
 				return f.findClientPublicJWK(oidcClient, t, true)
 			} else if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
-				return nil, errors.WithStack(ErrInvalidClient.WithHint("This authorization server does not support client authentication method 'client_secret_jwt'."))
+				return nil, errors.WithStack(ErrInvalidClient.WithHint("The 'client_assertion' uses a HMAC based signing algorithm which is only supported by client authentication method 'client_secret_jwt'."))
 			}
 
 			return nil, errors.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' request parameter uses unsupported signing algorithm '%s'.", t.Header["alg"]))
@@ -136,7 +154,7 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			// Do not re-process already enhanced errors
 			var e *jwt.ValidationError
 			if errors.As(err, &e) {
-				if e.Inner != nil {
+				if e.Inner != nil && e.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
 					return nil, e.Inner
 				}
 				return nil, errors.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithCause(err).WithDebug(err.Error()))
@@ -151,19 +169,45 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			return nil, errors.WithStack(ErrInvalidClient.WithHint("Unable to type assert claims from request parameter 'client_assertion'.").WithDebugf("Got claims of type %T but expected type '*jwt.MapClaims'.", token.Claims))
 		}
 
+		// The audience may be the token endpoint URL or the issuer resolved for this request, which differs from the
+		// configured token endpoint URL when several issuers are served from one binary.
+		audiences := RemoveEmpty([]string{f.TokenURL, f.resolveIssuer(ctx, r)})
+
 		var jti string
 		if !claims.VerifyIssuer(clientID, true) {
 			return nil, errors.WithStack(ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
-		} else if f.TokenURL == "" {
+		} else if len(audiences) == 0 {
 			return nil, errors.WithStack(ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
 		} else if sub, ok := (*claims)["sub"].(string); !ok || sub != clientID {
 			return nil, errors.WithStack(ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
 		} else if jti, ok = (*claims)["jti"].(string); !ok || len(jti) == 0 {
 			return nil, errors.WithStack(ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
-		} else if f.Store.ClientAssertionJWTValid(context.Background(), jti) != nil {
+		} else if f.Store.ClientAssertionJWTValid(ctx, jti) != nil {
 			return nil, errors.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
 		}
 
+		var found bool
+		if auds, ok := (*claims)["aud"].([]interface{}); !ok {
+			for _, aud := range audiences {
+				if claims.VerifyAudience(aud, true) {
+					found = true
+					break
+				}
+			}
+		} else {
+			for _, aud := range auds {
+				for _, expected := range audiences {
+					if a, ok := aud.(string); ok && a == expected {
+						found = true
+					}
+				}
+			}
+		}
+
+		if !found {
+			return nil, errors.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", audiences[0]))
+		}
+
 		// type conversion according to jwt.MapClaims.VerifyExpiresAt
 		var expiry int64
 		err = nil
@@ -179,26 +223,10 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
-		if err := f.Store.SetClientAssertionJWT(context.Background(), jti, time.Unix(expiry, 0)); err != nil {
-			return nil, err
-		}
-
-		if auds, ok := (*claims)["aud"].([]interface{}); !ok {
-			if !claims.VerifyAudience(f.TokenURL, true) {
-				return nil, errors.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
-			}
-		} else {
-			var found bool
-			for _, aud := range auds {
-				if a, ok := aud.(string); ok && a == f.TokenURL {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				return nil, errors.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
-			}
+		if err := f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0)); errors.Is(err, ErrJTIKnown) {
+			return nil, errors.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
+		} else if err != nil {
+			return nil, errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
 		}
 
 		return client, nil
@@ -211,7 +239,7 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 		return nil, err
 	}
 
-	client, err := f.Store.GetClient(ctx, clientID)
+	client, err := f.getClient(ctx, clientID)
 	if err != nil {
 		return nil, errors.WithStack(ErrInvalidClient.WithCause(err).WithDebug(err.Error()))
 	}
@@ -220,24 +248,83 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 		// If this isn't an OpenID Connect client then we actually don't care about any of this, just continue!
 	} else if ok && form.Get("client_id") != "" && form.Get("client_secret") != "" && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_post" {
 		return nil, errors.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_post' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_post'.", oidcClient.GetTokenEndpointAuthMethod()))
-	} else if _, _, basicOk := r.BasicAuth(); basicOk && ok && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_basic" {
+	} else if _, _, basicOk := r.BasicAuth(); basicOk && ok && (clientSecret != "" || !IsPublicClient(client)) && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_basic" {
+		// A public client sending its client id with an empty secret uses authentication method 'none' instead.
 		return nil, errors.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_basic' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_basic'.", oidcClient.GetTokenEndpointAuthMethod()))
 	} else if ok && oidcClient.GetTokenEndpointAuthMethod() != "none" && client.IsPublic() {
 		return nil, errors.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'none' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'none'.", oidcClient.GetTokenEndpointAuthMethod()))
 	}
 
-	if client.IsPublic() {
+	if IsPublicClient(client) {
+		// Public clients can not keep a secret confidential, so a client presenting one is not the registered client.
+		if clientSecret != "" {
+			return nil, errors.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client is a public client and must not authenticate with a client secret."))
+		}
+		return client, nil
+	}
+
+	if oidcClient, ok := client.(OpenIDConnectClient); ok && isTLSClientAuthMethod(client) {
+		if err := f.authenticateTLSClient(r, oidcClient); err != nil {
+			return nil, err
+		}
 		return client, nil
 	}
 
-	// Enforce client authentication
-	if err := f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(clientSecret)); err != nil {
+	// Enforce client authentication. An empty secret never authenticates a confidential client, not even one without
+	// a registered secret.
+	if clientSecret == "" {
+		return nil, errors.WithStack(ErrInvalidClient.WithHint("The client secret is missing or empty."))
+	} else if err := f.compareClientSecret(ctx, client, []byte(clientSecret)); err != nil {
 		return nil, errors.WithStack(ErrInvalidClient.WithCause(err).WithDebug(err.Error()))
 	}
 
 	return client, nil
 }
 
+// ClientSecretRotationHook is called when a client authenticates with its current secret while it still has a rotated
+// secret, see ClientWithRotatedSecret. The client evidently received the new secret, so the rotated one can be retired.
+type ClientSecretRotationHook func(ctx context.Context, client Client)
+
+// compareClientSecret compares the secret with the client's hashed secret and, failing that, with its rotated secret.
+func (f *Fosite) compareClientSecret(ctx context.Context, client Client, secret []byte) error {
+	err := f.Hasher.Compare(ctx, client.GetHashedSecret(), secret)
+
+	rotated, ok := client.(ClientWithRotatedSecret)
+	if !ok || len(rotated.GetRotatedHashedSecret()) == 0 {
+		return err
+	}
+
+	if err == nil {
+		if f.ClientSecretRotationHook != nil {
+			f.ClientSecretRotationHook(ctx, client)
+		}
+		return nil
+	}
+
+	if rotatedErr := f.Hasher.Compare(ctx, rotated.GetRotatedHashedSecret(), secret); rotatedErr == nil {
+		return nil
+	}
+
+	return err
+}
+
+// findClientSecretJWTKey returns the key used to verify a client_secret_jwt assertion. The HMAC signature itself is
+// compared in constant time by the signing method, and a missing secret is rejected with the same error as an invalid
+// signature so that the response does not reveal whether a secret is registered.
+func findClientSecretJWTKey(client Client) (interface{}, error) {
+	secretClient, ok := client.(ClientWithSecretJWT)
+	if !ok {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHint("The server configuration does not support client authentication method 'client_secret_jwt'."))
+	}
+
+	secret := secretClient.GetTokenEndpointAuthSecret()
+	if len(secret) == 0 {
+		return nil, errors.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value."))
+	}
+
+	return secret, nil
+}
+
 func findPublicKey(t *jwt.Token, set *jose.JSONWebKeySet, expectsRSAKey bool) (interface{}, error) {
 	keys := set.Keys
 	if len(keys) == 0 {
@@ -245,36 +332,69 @@ func findPublicKey(t *jwt.Token, set *jose.JSONWebKeySet, expectsRSAKey bool) (i
 	}
 
 	kid, ok := t.Header["kid"].(string)
+	ok = ok && kid != ""
 	if ok {
-		keys = set.Key(kid)
+		// An unknown kid is an error so that JSON Web Key Sets fetched from a remote location are refreshed.
+		if len(set.Key(kid)) == 0 {
+			return nil, errors.WithStack(ErrInvalidRequest.WithHintf("The JSON Web Token uses signing key with kid '%s', which could not be found.", kid))
+		} else if key, found := findPublicKeyOfType(set.Key(kid), expectsRSAKey); found {
+			return key, nil
+		}
 	}
 
-	if len(keys) == 0 {
-		return nil, errors.WithStack(ErrInvalidRequest.WithHintf("The JSON Web Token uses signing key with kid '%s', which could not be found.", kid))
+	// The JSON Web Token has no kid or its kid does not match a key of the expected type. This happens with clients
+	// that do not set a kid on their keys, so we try all keys of the expected type and use the first one that
+	// verifies the signature.
+	var candidates []interface{}
+	for _, key := range keys {
+		if key.Use != "sig" || (key.Algorithm != "" && key.Algorithm != t.Method.Alg()) {
+			continue
+		}
+		if k, found := findPublicKeyOfType([]jose.JSONWebKey{key}, expectsRSAKey); found {
+			candidates = append(candidates, k)
+		}
 	}
 
+	if parts := strings.Split(t.Raw, "."); len(parts) == 3 {
+		for _, key := range candidates {
+			if err := t.Method.Verify(strings.Join(parts[0:2], "."), parts[2], key); err == nil {
+				return key, nil
+			}
+		}
+	}
+
+	if len(candidates) > 0 {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHint("The JSON Web Token signature could not be verified with any key of the JSON Web Key Set."))
+	} else if expectsRSAKey {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHintf("Unable to find RSA public key with use='sig' for kid '%s' in JSON Web Key Set.", kid))
+	} else {
+		return nil, errors.WithStack(ErrInvalidRequest.WithHintf("Unable to find ECDSA public key with use='sig' for kid '%s' in JSON Web Key Set.", kid))
+	}
+}
+
+func findPublicKeyOfType(keys []jose.JSONWebKey, expectsRSAKey bool) (interface{}, bool) {
 	for _, key := range keys {
 		if key.Use != "sig" {
 			continue
 		}
 		if expectsRSAKey {
 			if k, ok := key.Key.(*rsa.PublicKey); ok {
-				return k, nil
+				return k, true
 			}
 		} else {
 			if k, ok := key.Key.(*ecdsa.PublicKey); ok {
-				return k, nil
+				return k, true
 			}
 		}
 	}
-
-	if expectsRSAKey {
-		return nil, errors.WithStack(ErrInvalidRequest.WithHintf("Unable to find RSA public key with use='sig' for kid '%s' in JSON Web Key Set.", kid))
-	} else {
-		return nil, errors.WithStack(ErrInvalidRequest.WithHintf("Unable to find ECDSA public key with use='sig' for kid '%s' in JSON Web Key Set.", kid))
-	}
+	return nil, false
 }
 
+// clientCredentialsFromRequest returns the client credentials of the HTTP authorization header or, if there is none,
+// of the HTTP POST body. The client id and secret in the header are encoded using application/x-www-form-urlencoded
+// before they are joined by a colon, so a colon, a plus sign or a space in the secret arrives as "%3A", "%2B" or "+".
+//
+// See https://tools.ietf.org/html/rfc6749#section-2.3.1
 func clientCredentialsFromRequest(r *http.Request, form url.Values) (clientID, clientSecret string, err error) {
 	if id, secret, ok := r.BasicAuth(); !ok {
 		return clientCredentialsFromRequestBody(form, true)
@@ -284,6 +404,14 @@ func clientCredentialsFromRequest(r *http.Request, form url.Values) (clientID, c
 		return "", "", errors.WithStack(ErrInvalidRequest.WithHint("The client secret in the HTTP authorization header could not be decoded from 'application/x-www-form-urlencoded'.").WithCause(err).WithDebug(err.Error()))
 	}
 
+	// The client MUST NOT use more than one authentication method in each request, see
+	// https://tools.ietf.org/html/rfc6749#section-2.3
+	if form.Get("client_secret") != "" {
+		return "", "", errors.WithStack(ErrInvalidRequest.WithHint("Client credentials must not be sent in both the HTTP Authorization header and the HTTP POST body."))
+	} else if bodyClientID := form.Get("client_id"); bodyClientID != "" && bodyClientID != clientID {
+		return "", "", errors.WithStack(ErrInvalidRequest.WithHint("The client id in the HTTP POST body does not match the client id in the HTTP Authorization header."))
+	}
+
 	return clientID, clientSecret, nil
 }
 
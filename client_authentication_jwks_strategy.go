@@ -22,9 +22,13 @@
 package fosite
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	jose "gopkg.in/square/go-jose.v2"
@@ -39,43 +43,163 @@ type JWKSFetcherStrategy interface {
 	Resolve(location string, forceRefresh bool) (*jose.JSONWebKeySet, error)
 }
 
+// ClientWithJWKSURI represents a client which publishes its JSON Web Key Set at a URL (jwks_uri) instead of
+// registering the keys with the authorization server.
+type ClientWithJWKSURI interface {
+	// GetJSONWebKeysURI returns the URL for lookup of JSON Web Key Set containing the
+	// public keys used by the client to authenticate.
+	GetJSONWebKeysURI() string
+}
+
+const (
+	// DefaultJWKSCacheTTL is the time a fetched JSON Web Key Set is cached if the response has no caching headers.
+	DefaultJWKSCacheTTL = time.Hour
+
+	// DefaultJWKSMaxBodySize is the maximum size in bytes of a JSON Web Key Set document.
+	DefaultJWKSMaxBodySize = 1 << 20
+
+	// DefaultJWKSMinRefreshInterval is the minimum time between two fetches of the same JSON Web Key Set when a
+	// refresh is forced, for example because an assertion names an unknown key ID.
+	DefaultJWKSMinRefreshInterval = 10 * time.Second
+)
+
+type cachedJSONWebKeySet struct {
+	set       jose.JSONWebKeySet
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// DefaultJWKSFetcherStrategy fetches JSON Web Key Sets using HTTP and caches them. A cached key set expires after the
+// time given by the Cache-Control max-age or Expires header of the response, or after the default TTL if the response
+// has neither. Forced refreshes of a key set are rate limited, see JWKSFetcherWithMinRefreshInterval.
 type DefaultJWKSFetcherStrategy struct {
-	client *http.Client
-	keys   map[string]jose.JSONWebKeySet
+	fetcher            *remoteFetcher
+	keys               map[string]cachedJSONWebKeySet
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+	clock              Clock
 	sync.Mutex
 }
 
-func NewDefaultJWKSFetcherStrategy() JWKSFetcherStrategy {
-	return &DefaultJWKSFetcherStrategy{
-		keys:   make(map[string]jose.JSONWebKeySet),
-		client: http.DefaultClient,
+// JWKSFetcherWithHTTPClient sets the HTTP client used to fetch JSON Web Key Sets. Defaults to a client which times
+// out after DefaultRemoteFetchTimeout.
+func JWKSFetcherWithHTTPClient(client *http.Client) func(*DefaultJWKSFetcherStrategy) {
+	return func(s *DefaultJWKSFetcherStrategy) {
+		s.fetcher.client = client
+	}
+}
+
+// JWKSFetcherWithCacheTTL sets how long a fetched JSON Web Key Set is cached if the response has no caching headers.
+// Defaults to DefaultJWKSCacheTTL.
+func JWKSFetcherWithCacheTTL(ttl time.Duration) func(*DefaultJWKSFetcherStrategy) {
+	return func(s *DefaultJWKSFetcherStrategy) {
+		s.ttl = ttl
 	}
 }
 
+// JWKSFetcherWithMaxBodySize sets the maximum size in bytes of a JSON Web Key Set document. Defaults to
+// DefaultJWKSMaxBodySize.
+func JWKSFetcherWithMaxBodySize(size int64) func(*DefaultJWKSFetcherStrategy) {
+	return func(s *DefaultJWKSFetcherStrategy) {
+		s.fetcher.maxBodySize = size
+	}
+}
+
+// JWKSFetcherWithMinRefreshInterval sets the minimum time between two fetches of the same JSON Web Key Set when a
+// refresh is forced. Within the interval the cached key set is returned instead. Defaults to
+// DefaultJWKSMinRefreshInterval.
+func JWKSFetcherWithMinRefreshInterval(interval time.Duration) func(*DefaultJWKSFetcherStrategy) {
+	return func(s *DefaultJWKSFetcherStrategy) {
+		s.minRefreshInterval = interval
+	}
+}
+
+// JWKSFetcherWithClock sets the clock used to compute when cached JSON Web Key Sets expire and when a forced refresh
+// is allowed. Defaults to the system clock.
+func JWKSFetcherWithClock(clock Clock) func(*DefaultJWKSFetcherStrategy) {
+	return func(s *DefaultJWKSFetcherStrategy) {
+		s.clock = clock
+	}
+}
+
+func NewDefaultJWKSFetcherStrategy(opts ...func(*DefaultJWKSFetcherStrategy)) JWKSFetcherStrategy {
+	s := &DefaultJWKSFetcherStrategy{
+		fetcher:            newRemoteFetcher(newDefaultRemoteHTTPClient(), DefaultJWKSMaxBodySize),
+		keys:               make(map[string]cachedJSONWebKeySet),
+		ttl:                DefaultJWKSCacheTTL,
+		minRefreshInterval: DefaultJWKSMinRefreshInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// HTTPClient returns the HTTP client used to fetch JSON Web Key Sets.
+func (s *DefaultJWKSFetcherStrategy) HTTPClient() *http.Client {
+	return s.fetcher.client
+}
+
 func (s *DefaultJWKSFetcherStrategy) Resolve(location string, forceRefresh bool) (*jose.JSONWebKeySet, error) {
-	s.Lock()
-	defer s.Unlock()
+	now := s.clock.Now()
 
+	s.Lock()
 	keys, ok := s.keys[location]
-	if !ok || forceRefresh {
-		response, err := s.client.Get(location)
-		if err != nil {
-			return nil, errors.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithCause(err).WithDebug(err.Error()))
-		}
-		defer response.Body.Close()
+	s.Unlock()
 
-		if response.StatusCode < 200 || response.StatusCode >= 400 {
-			return nil, errors.WithStack(ErrServerError.WithHintf("Expected successful status code in range of 200 - 399 from location '%s' but received code %d.", location, response.StatusCode))
-		}
+	if ok && !forceRefresh && now.Before(keys.expiresAt) {
+		return &keys.set, nil
+	} else if ok && forceRefresh && now.Sub(keys.fetchedAt) < s.minRefreshInterval {
+		return &keys.set, nil
+	}
+
+	document, err := s.fetcher.fetch(context.Background(), location)
+	if errors.Is(err, errRemoteDocumentTooLarge) {
+		return nil, errors.WithStack(ErrServerError.WithHintf("The JSON Web Key Set from location '%s' exceeds the maximum size of %d bytes.", location, s.fetcher.maxBodySize))
+	} else if err != nil {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Unable to fetch JSON Web Keys from location '%s'. Check for typos or other network issues.", location).WithCause(err).WithDebug(err.Error()))
+	}
+
+	if document.statusCode < 200 || document.statusCode >= 400 {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Expected successful status code in range of 200 - 399 from location '%s' but received code %d.", location, document.statusCode))
+	}
 
-		var set jose.JSONWebKeySet
-		if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
-			return nil, errors.WithStack(ErrServerError.WithHintf("Unable to decode JSON Web Keys from location '%s'. Please check for typos and if the URL returns valid JSON.", location).WithCause(err).WithDebug(err.Error()))
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(document.body, &set); err != nil {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Unable to decode JSON Web Keys from location '%s'. Please check for typos and if the URL returns valid JSON.", location).WithCause(err).WithDebug(err.Error()))
+	}
+
+	s.Lock()
+	s.keys[location] = cachedJSONWebKeySet{set: set, fetchedAt: now, expiresAt: now.Add(s.cacheTTL(document.header, now))}
+	s.Unlock()
+
+	return &set, nil
+}
+
+// cacheTTL returns how long a response received at now may be cached according to its Cache-Control and Expires
+// headers.
+func (s *DefaultJWKSFetcherStrategy) cacheTTL(header http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-store" || directive == "no-cache" {
+			return 0
+		} else if strings.HasPrefix(directive, "max-age=") {
+			if seconds, err := strconv.ParseInt(strings.TrimPrefix(directive, "max-age="), 10, 64); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
 		}
+	}
 
-		s.keys[location] = set
-		return &set, nil
+	if expires := header.Get("Expires"); expires != "" {
+		if at, err := http.ParseTime(expires); err == nil {
+			if ttl := at.Sub(now); ttl > 0 {
+				return ttl
+			}
+			return 0
+		}
 	}
 
-	return &keys, nil
+	return s.ttl
 }
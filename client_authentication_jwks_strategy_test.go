@@ -22,10 +22,20 @@
 package fosite_test
 
 import (
+	"context"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,12 +43,13 @@ import (
 
 	. "github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestDefaultJWKSFetcherStrategy(t *testing.T) {
 	var h http.HandlerFunc
 
-	s := NewDefaultJWKSFetcherStrategy()
+	s := NewDefaultJWKSFetcherStrategy(JWKSFetcherWithMinRefreshInterval(0))
 	t.Run("case=fetching", func(t *testing.T) {
 		var set *jose.JSONWebKeySet
 		h = func(w http.ResponseWriter, r *http.Request) {
@@ -107,3 +118,195 @@ func TestDefaultJWKSFetcherStrategy(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestDefaultJWKSFetcherStrategyCaching(t *testing.T) {
+	var requests int
+	var cacheControl string
+	set := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				KeyID: "foo",
+				Use:   "sig",
+				Key:   &internal.MustRSAKey().PublicKey,
+			},
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(set))
+	}))
+	defer ts.Close()
+
+	for k, tc := range []struct {
+		d              string
+		cacheControl   string
+		expectRequests int
+	}{
+		{d: "default ttl caches the key set", expectRequests: 1},
+		{d: "max-age caches the key set", cacheControl: "public, max-age=3600", expectRequests: 1},
+		{d: "max-age of zero disables caching", cacheControl: "max-age=0", expectRequests: 3},
+		{d: "no-store disables caching", cacheControl: "no-store", expectRequests: 3},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			requests = 0
+			cacheControl = tc.cacheControl
+			s := NewDefaultJWKSFetcherStrategy(JWKSFetcherWithHTTPClient(ts.Client()))
+
+			for i := 0; i < 3; i++ {
+				keys, err := s.Resolve(ts.URL, false)
+				require.NoError(t, err)
+				assert.Len(t, keys.Key("foo"), 1)
+			}
+			assert.Equal(t, tc.expectRequests, requests)
+		})
+	}
+
+	t.Run("case=expired entries are fetched again", func(t *testing.T) {
+		requests = 0
+		cacheControl = ""
+		now := time.Now()
+		s := NewDefaultJWKSFetcherStrategy(JWKSFetcherWithCacheTTL(time.Minute), JWKSFetcherWithClock(func() time.Time { return now }))
+
+		_, err := s.Resolve(ts.URL, false)
+		require.NoError(t, err)
+		now = now.Add(time.Minute - time.Second)
+		_, err = s.Resolve(ts.URL, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, requests)
+
+		now = now.Add(time.Second)
+		_, err = s.Resolve(ts.URL, false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("case=oversized documents are rejected", func(t *testing.T) {
+		s := NewDefaultJWKSFetcherStrategy(JWKSFetcherWithMaxBodySize(16))
+		_, err := s.Resolve(ts.URL, true)
+		require.Error(t, err)
+		assert.Contains(t, ErrorToRFC6749Error(err).Hint, "exceeds the maximum size")
+	})
+}
+
+func TestAuthenticateClientRefreshesJWKSOnUnknownKeyID(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	var requests int
+	oldKey, newKey, unknownKey := internal.MustRSAKey(), internal.MustRSAKey(), internal.MustRSAKey()
+	set := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "old", Use: "sig", Key: &oldKey.PublicKey}}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, json.NewEncoder(w).Encode(set))
+	}))
+	defer ts.Close()
+
+	store := storage.NewMemoryStore()
+	store.Clients["bar"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "bar"},
+		JSONWebKeysURI:          ts.URL,
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	now := time.Now()
+	f := &Fosite{
+		JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(
+			JWKSFetcherWithHTTPClient(ts.Client()),
+			JWKSFetcherWithMinRefreshInterval(time.Minute),
+			JWKSFetcherWithClock(func() time.Time { return now }),
+		),
+		Store:    store,
+		TokenURL: "token-url",
+	}
+
+	authenticate := func(key *rsa.PrivateKey, kid, jti string) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": "bar",
+			"iss": "bar",
+			"jti": jti,
+			"aud": "token-url",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = kid
+		assertion, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		_, err = f.AuthenticateClient(context.Background(), new(http.Request), url.Values{
+			"client_assertion":      {assertion},
+			"client_assertion_type": {at},
+		})
+		return err
+	}
+
+	// initial fetch
+	require.NoError(t, authenticate(oldKey, "old", "1"))
+	assert.Equal(t, 1, requests)
+
+	// cache hit
+	require.NoError(t, authenticate(oldKey, "old", "2"))
+	assert.Equal(t, 1, requests)
+
+	// the client rotates its keys, the unknown kid forces a refresh once the minimum refresh interval has passed
+	set.Keys = []jose.JSONWebKey{{KeyID: "new", Use: "sig", Key: &newKey.PublicKey}}
+	require.Error(t, authenticate(newKey, "new", "3"))
+	assert.Equal(t, 1, requests)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, authenticate(newKey, "new", "4"))
+	assert.Equal(t, 2, requests)
+
+	// keys which are unknown even after refreshing are rejected, without refreshing again within the interval
+	err := authenticate(unknownKey, strings.Repeat("x", 4), "5")
+	require.Error(t, err)
+	assert.Equal(t, 2, requests)
+
+	now = now.Add(time.Minute)
+	err = authenticate(unknownKey, strings.Repeat("x", 4), "6")
+	require.Error(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestDefaultJWKSFetcherStrategyFetchesConcurrentlyOnce(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		require.NoError(t, json.NewEncoder(w).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{KeyID: "foo", Use: "sig", Key: &internal.MustRSAKey().PublicKey}},
+		}))
+	}))
+	defer ts.Close()
+
+	s := NewDefaultJWKSFetcherStrategy(JWKSFetcherWithHTTPClient(ts.Client()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys, err := s.Resolve(ts.URL, false)
+			require.NoError(t, err)
+			assert.Len(t, keys.Key("foo"), 1)
+		}()
+	}
+
+	// other locations are not blocked by the pending fetch
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(&jose.JSONWebKeySet{}))
+	}))
+	defer other.Close()
+	_, err := s.Resolve(other.URL, false)
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+}
+
+func TestDefaultJWKSFetcherStrategyTimesOut(t *testing.T) {
+	s := NewDefaultJWKSFetcherStrategy().(*DefaultJWKSFetcherStrategy)
+	assert.Equal(t, DefaultRemoteFetchTimeout, s.HTTPClient().Timeout)
+}
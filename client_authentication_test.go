@@ -104,6 +104,11 @@ func TestAuthenticateClient(t *testing.T) {
 	complexSecret, err := hasher.Hash(context.TODO(), []byte(complexSecretRaw))
 	require.NoError(t, err)
 
+	// a secret containing the characters which are encoded differently by application/x-www-form-urlencoded
+	formEncodedSecretRaw := "foo:bar+baz qux"
+	formEncodedSecret, err := hasher.Hash(context.TODO(), []byte(formEncodedSecretRaw))
+	require.NoError(t, err)
+
 	rsaKey := internal.MustRSAKey()
 	rsaJwks := &jose.JSONWebKeySet{
 		Keys: []jose.JSONWebKey{
@@ -126,6 +131,34 @@ func TestAuthenticateClient(t *testing.T) {
 		},
 	}
 
+	otherRSAKey := internal.MustRSAKey()
+	multiRSAJwks := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				KeyID: "kid-bar",
+				Use:   "sig",
+				Key:   &otherRSAKey.PublicKey,
+			},
+			{
+				KeyID: "kid-foo",
+				Use:   "sig",
+				Key:   &rsaKey.PublicKey,
+			},
+		},
+	}
+	kidlessRSAJwks := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Use: "sig",
+				Key: &otherRSAKey.PublicKey,
+			},
+			{
+				Use: "sig",
+				Key: &rsaKey.PublicKey,
+			},
+		},
+	}
+
 	var h http.HandlerFunc
 	h = func(w http.ResponseWriter, r *http.Request) {
 		require.NoError(t, json.NewEncoder(w).Encode(rsaJwks))
@@ -235,6 +268,59 @@ func TestAuthenticateClient(t *testing.T) {
 			r:         &http.Request{Header: http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("foo:%%%%%%%"))}}},
 			expectErr: ErrInvalidRequest,
 		},
+		{
+			d:      "should pass with a client secret containing a colon, a plus sign and a space via basic auth",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: formEncodedSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:   url.Values{},
+			r:      &http.Request{Header: http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("foo:foo%3Abar%2Bbaz+qux"))}}},
+		},
+		{
+			d:         "should fail because the client secret is not encoded using application/x-www-form-urlencoded",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: formEncodedSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:      url.Values{},
+			r:         &http.Request{Header: http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("foo:"+formEncodedSecretRaw))}}},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because client credentials are sent in both the header and the post body",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:      url.Values{"client_id": []string{"foo"}, "client_secret": []string{"bar"}},
+			r:         &http.Request{Header: clientBasicAuthHeader("foo", "bar")},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:         "should fail because the client id in the post body does not match the one in the header",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:      url.Values{"client_id": []string{"bar"}},
+			r:         &http.Request{Header: clientBasicAuthHeader("foo", "bar")},
+			expectErr: ErrInvalidRequest,
+		},
+		{
+			d:      "should pass because the client id in the post body matches the one in the header",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:   url.Values{"client_id": []string{"foo"}},
+			r:      &http.Request{Header: clientBasicAuthHeader("foo", "bar")},
+		},
+		{
+			d:         "should fail because the secret in the header is empty",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:      url.Values{},
+			r:         &http.Request{Header: clientBasicAuthHeader("foo", "")},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because an empty secret does not authenticate a confidential client without a secret",
+			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo"}, TokenEndpointAuthMethod: "client_secret_basic"},
+			form:      url.Values{},
+			r:         &http.Request{Header: clientBasicAuthHeader("foo", "")},
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:      "should pass because a public client sends its client id in the header with an empty secret",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Public: true}, TokenEndpointAuthMethod: "none"},
+			form:   url.Values{},
+			r:      &http.Request{Header: clientBasicAuthHeader("foo", "")},
+		},
 		{
 			d:         "should fail because client is confidential and id does not exist in header",
 			client:    &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_basic"},
@@ -280,6 +366,43 @@ func TestAuthenticateClient(t *testing.T) {
 			}, ecdsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
 			r: new(http.Request),
 		},
+		{
+			d:      "should pass with proper RSA assertion when the kid matches one of multiple JWKs",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: multiRSAJwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form: url.Values{"client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
+			r: new(http.Request),
+		},
+		{
+			d:      "should pass with proper RSA assertion without kid by trying all JWKs",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: kidlessRSAJwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form: url.Values{"client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "")}, "client_assertion_type": []string{at}},
+			r: new(http.Request),
+		},
+		{
+			d:      "should fail because RSA assertion without kid can not be verified by any JWK",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: kidlessRSAJwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form: url.Values{"client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, internal.MustRSAKey(), "")}, "client_assertion_type": []string{at}},
+			r:         new(http.Request),
+			expectErr: ErrInvalidRequest,
+		},
 		{
 			d:      "should fail because RSA assertion is used, but ECDSA assertion is required",
 			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: ecdsaJwks, TokenEndpointAuthMethod: "private_key_jwt", TokenEndpointAuthSigningAlgorithm: "ES256"},
@@ -408,6 +531,57 @@ func TestAuthenticateClient(t *testing.T) {
 			r:         new(http.Request),
 			expectErr: ErrInvalidClient,
 		},
+		{
+			d:      "should pass because client_secret_jwt assertion is signed with the client secret",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_jwt", TokenEndpointAuthSecret: []byte("aaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbbbbcccccccccccccccccccccddddddddddddddddddddddd")},
+			form: url.Values{"client_id": []string{"bar"}, "client_assertion": {mustGenerateHSAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
+			r: new(http.Request),
+		},
+		{
+			d:      "should fail because client_secret_jwt assertion is signed with a different secret",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_jwt", TokenEndpointAuthSecret: []byte("some-other-secret-some-other-secret")},
+			form: url.Values{"client_id": []string{"bar"}, "client_assertion": {mustGenerateHSAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
+			r:         new(http.Request),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:      "should fail because client_secret_jwt client has no secret registered",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, TokenEndpointAuthMethod: "client_secret_jwt"},
+			form: url.Values{"client_id": []string{"bar"}, "client_assertion": {mustGenerateHSAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
+			r:         new(http.Request),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:      "should fail because client_secret_jwt assertion is signed with RS256",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: rsaJwks, TokenEndpointAuthMethod: "client_secret_jwt", TokenEndpointAuthSigningAlgorithm: "RS256", TokenEndpointAuthSecret: []byte("aaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbbbbcccccccccccccccccccccddddddddddddddddddddddd")},
+			form: url.Values{"client_id": []string{"bar"}, "client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iss": "bar",
+				"jti": "12345",
+				"aud": "token-url",
+			}, rsaKey, "kid-foo")}, "client_assertion_type": []string{at}},
+			r:         new(http.Request),
+			expectErr: ErrInvalidClient,
+		},
 		{
 			d:      "should fail because JWT algorithm is none",
 			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "bar", Secret: barSecret}, JSONWebKeys: rsaJwks, TokenEndpointAuthMethod: "private_key_jwt"},
@@ -560,3 +734,315 @@ func TestAuthenticateClientTwice(t *testing.T) {
 	assert.EqualError(t, err, ErrJTIKnown.Error())
 	assert.Nil(t, c)
 }
+
+type stubHasher struct {
+	compared [][]byte
+}
+
+func (h *stubHasher) Compare(ctx context.Context, hash, data []byte) error {
+	h.compared = append(h.compared, data)
+	if string(hash) != "hashed:"+string(data) {
+		return errors.New("secrets do not match")
+	}
+	return nil
+}
+
+func (h *stubHasher) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	return []byte("hashed:" + string(data)), nil
+}
+
+func TestAuthenticateClientWithCustomHasher(t *testing.T) {
+	hasher := new(stubHasher)
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo", Secret: []byte("hashed:bar")}, TokenEndpointAuthMethod: "client_secret_basic"}
+	f := &Fosite{Store: store, Hasher: hasher}
+
+	c, err := f.AuthenticateClient(context.Background(), &http.Request{Header: clientBasicAuthHeader("foo", "bar")}, url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", c.GetID())
+
+	_, err = f.AuthenticateClient(context.Background(), &http.Request{Header: clientBasicAuthHeader("foo", "baz")}, url.Values{})
+	require.EqualError(t, err, ErrInvalidClient.Error())
+
+	assert.Equal(t, [][]byte{[]byte("bar"), []byte("baz")}, hasher.compared)
+}
+
+func TestAuthenticateClientAssertionJTIIsOnlyConsumedWhenValid(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key := internal.MustECDSAKey()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}},
+		}))
+	}))
+	defer ts.Close()
+
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient:                     &DefaultClient{ID: "bar"},
+		JSONWebKeysURI:                    ts.URL,
+		TokenEndpointAuthMethod:           "private_key_jwt",
+		TokenEndpointAuthSigningAlgorithm: "ES256",
+	}
+	store := storage.NewMemoryStore()
+	store.Clients[client.ID] = client
+	f := &Fosite{
+		JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(),
+		Store:               store,
+		TokenURL:            "token-url",
+	}
+
+	assertion := func(aud string) url.Values {
+		return url.Values{"client_assertion_type": {at}, "client_assertion": {mustGenerateECDSAAssertion(t, jwt.MapClaims{
+			"sub": "bar",
+			"iss": "bar",
+			"jti": "12345",
+			"aud": aud,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}, key, "kid-foo")}}
+	}
+
+	_, err := f.AuthenticateClient(context.Background(), new(http.Request), assertion("not-token-url"))
+	require.EqualError(t, err, ErrInvalidClient.Error())
+
+	c, err := f.AuthenticateClient(context.Background(), new(http.Request), assertion("token-url"))
+	require.NoError(t, err)
+	assert.Equal(t, client, c)
+
+	_, err = f.AuthenticateClient(context.Background(), new(http.Request), assertion("token-url"))
+	require.EqualError(t, err, ErrJTIKnown.Error())
+}
+
+func TestAuthenticateClientAssertionAudienceIsResolvedIssuer(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key := internal.MustRSAKey()
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "bar"},
+		JSONWebKeys:             &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}}},
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	store := storage.NewMemoryStore()
+	store.Clients[client.ID] = client
+	f := &Fosite{
+		JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(),
+		Store:               store,
+		TokenURL:            "https://static.example.com/token",
+		IssuerFromRequest: func(r *http.Request) string {
+			return "https://" + r.Host
+		},
+	}
+
+	for k, c := range []struct {
+		d         string
+		aud       interface{}
+		host      string
+		expectErr error
+	}{
+		{d: "token url", aud: "https://static.example.com/token", host: "tenant.example.com"},
+		{d: "issuer resolved from the request", aud: "https://tenant.example.com", host: "tenant.example.com"},
+		{d: "issuer in an audience array", aud: []string{"https://other.example.com", "https://tenant.example.com"}, host: "tenant.example.com"},
+		{d: "issuer of another host", aud: "https://tenant.example.com", host: "other.example.com", expectErr: ErrInvalidClient},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "https://"+c.host+"/token", nil)
+			form := url.Values{"client_assertion_type": {at}, "client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+				"sub": "bar",
+				"iss": "bar",
+				"jti": fmt.Sprintf("jti-%d", k),
+				"aud": c.aud,
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}, key, "kid-foo")}}
+
+			_, err := f.AuthenticateClient(context.Background(), r, form)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthenticateClientWithRotatedSecret(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "foo", Secret: []byte("hashed:new"), RotatedSecret: []byte("hashed:old")},
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}
+	store.Clients["bar"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "bar", Secret: []byte("hashed:new")},
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}
+
+	var retirable []string
+	f := &Fosite{Store: store, Hasher: new(stubHasher), ClientSecretRotationHook: func(_ context.Context, c Client) {
+		retirable = append(retirable, c.GetID())
+	}}
+
+	for k, c := range []struct {
+		d               string
+		id              string
+		secret          string
+		expectErr       error
+		expectRetirable []string
+	}{
+		{
+			d:      "should pass with the rotated secret without signaling the rotation",
+			id:     "foo",
+			secret: "old",
+		},
+		{
+			d:               "should pass with the current secret and signal that the rotated secret is retirable",
+			id:              "foo",
+			secret:          "new",
+			expectRetirable: []string{"foo"},
+		},
+		{
+			d:         "should fail with an unknown secret",
+			id:        "foo",
+			secret:    "other",
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:      "should pass without signaling the rotation because the client has no rotated secret",
+			id:     "bar",
+			secret: "new",
+		},
+		{
+			d:         "should fail because the client has no rotated secret",
+			id:        "bar",
+			secret:    "old",
+			expectErr: ErrInvalidClient,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			retirable = nil
+			client, err := f.AuthenticateClient(context.Background(), &http.Request{Header: clientBasicAuthHeader(c.id, c.secret)}, url.Values{})
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, c.id, client.GetID())
+			}
+			assert.Equal(t, c.expectRetirable, retirable)
+		})
+	}
+}
+
+func TestAuthenticateClientSigningAlgorithms(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key := internal.MustECDSAKey()
+	newClient := func(alg string) *DefaultOpenIDConnectClient {
+		return &DefaultOpenIDConnectClient{
+			DefaultClient: &DefaultClient{ID: "bar"},
+			JSONWebKeys: &jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}},
+			},
+			TokenEndpointAuthMethod:           "private_key_jwt",
+			TokenEndpointAuthSigningAlgorithm: alg,
+		}
+	}
+	claims := func(jti string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "bar", "iss": "bar", "jti": jti, "aud": "token-url", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+
+	for k, c := range []struct {
+		d          string
+		client     *DefaultOpenIDConnectClient
+		allowed    []string
+		assertion  string
+		expectHint string
+	}{
+		{
+			d:         "should pass because ES256 is allowed by default",
+			client:    newClient("ES256"),
+			assertion: mustGenerateECDSAAssertion(t, claims("1"), key, "kid-foo"),
+		},
+		{
+			d:          "should fail because the client enforces ES256 but the assertion uses HS256",
+			client:     newClient("ES256"),
+			assertion:  mustGenerateHSAssertion(t, claims("2"), nil, "kid-foo"),
+			expectHint: "The 'client_assertion' uses signing algorithm 'HS256' but the requested OAuth 2.0 Client enforces signing algorithm 'ES256'.",
+		},
+		{
+			d:          "should fail because the client enforces ES256 but the server does not allow it",
+			client:     newClient("ES256"),
+			allowed:    []string{"RS256"},
+			assertion:  mustGenerateECDSAAssertion(t, claims("3"), key, "kid-foo"),
+			expectHint: "The 'client_assertion' uses signing algorithm 'ES256' which is not allowed by this authorization server.",
+		},
+		{
+			d:          "should fail because unsigned assertions are never allowed",
+			client:     newClient("none"),
+			allowed:    []string{"none"},
+			assertion:  mustGenerateNoneAssertion(t, claims("4"), nil, "kid-foo"),
+			expectHint: "The 'client_assertion' uses signing algorithm 'none' which is not allowed by this authorization server.",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			store.Clients[c.client.ID] = c.client
+			f := &Fosite{
+				JWKSFetcherStrategy:                NewDefaultJWKSFetcherStrategy(),
+				Store:                              store,
+				TokenURL:                           "token-url",
+				TokenEndpointAuthSigningAlgorithms: c.allowed,
+			}
+
+			client, err := f.AuthenticateClient(context.Background(), new(http.Request), url.Values{"client_assertion_type": {at}, "client_assertion": {c.assertion}})
+			if c.expectHint == "" {
+				require.NoError(t, err)
+				assert.Equal(t, c.client, client)
+				return
+			}
+
+			require.EqualError(t, err, ErrInvalidClient.Error())
+			assert.Equal(t, c.expectHint, ErrorToRFC6749Error(err).Hint)
+		})
+	}
+}
+
+// racingJTIStore simulates concurrent requests with the same client assertion, which all pass the check of
+// ClientAssertionJWTValid before any of them called SetClientAssertionJWT.
+type racingJTIStore struct {
+	*storage.MemoryStore
+}
+
+func (s *racingJTIStore) ClientAssertionJWTValid(context.Context, string) error {
+	return nil
+}
+
+func TestAuthenticateClientAssertionJTIIsSetAtomically(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key := internal.MustRSAKey()
+	store := &racingJTIStore{MemoryStore: storage.NewMemoryStore()}
+	store.Clients["bar"] = &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{ID: "bar"},
+		JSONWebKeys: &jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}},
+		},
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	f := &Fosite{JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(), Store: store, TokenURL: "token-url"}
+
+	newAssertion := func(jti string, exp time.Time) url.Values {
+		return url.Values{"client_assertion_type": {at}, "client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+			"sub": "bar", "iss": "bar", "jti": jti, "aud": "token-url", "exp": exp.Unix(),
+		}, key, "kid-foo")}}
+	}
+
+	form := newAssertion("12345", time.Now().Add(time.Hour))
+	_, err := f.AuthenticateClient(context.Background(), new(http.Request), form)
+	require.NoError(t, err)
+
+	_, err = f.AuthenticateClient(context.Background(), new(http.Request), form)
+	require.EqualError(t, err, ErrJTIKnown.Error())
+
+	// The JTI of an expired assertion is forgotten and may be used again.
+	store.BlacklistedJTIs["67890"] = time.Now().Add(-time.Minute)
+	_, err = f.AuthenticateClient(context.Background(), new(http.Request), newAssertion("67890", time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// TLSClientAuthMethod is the PKI mutual-TLS client authentication method, see RFC 8705 Section 2.1.
+	TLSClientAuthMethod = "tls_client_auth"
+
+	// SelfSignedTLSClientAuthMethod is the self-signed certificate mutual-TLS client authentication method, see
+	// RFC 8705 Section 2.2.
+	SelfSignedTLSClientAuthMethod = "self_signed_tls_client_auth"
+)
+
+// ClientWithTLSClientAuth represents a client capable of authenticating using the tls_client_auth method. Exactly
+// one of the values should be set, it is matched against the client certificate presented in the request.
+type ClientWithTLSClientAuth interface {
+	// GetTLSClientAuthSubjectDN returns the expected subject distinguished name of the certificate.
+	GetTLSClientAuthSubjectDN() string
+
+	// GetTLSClientAuthSANDNS returns the expected dNSName SAN entry in the certificate.
+	GetTLSClientAuthSANDNS() string
+
+	// GetTLSClientAuthSANURI returns the expected uniformResourceIdentifier SAN entry in the certificate.
+	GetTLSClientAuthSANURI() string
+
+	// GetTLSClientAuthSANIP returns the expected iPAddress SAN entry in the certificate.
+	GetTLSClientAuthSANIP() string
+
+	// GetTLSClientAuthSANEmail returns the expected rfc822Name SAN entry in the certificate.
+	GetTLSClientAuthSANEmail() string
+}
+
+// CertificateBoundSession represents a session which binds the issued tokens to the certificate the client
+// authenticated with, see RFC 8705 Section 3.
+type CertificateBoundSession interface {
+	// SetCertificateThumbprint sets the base64url encoded SHA-256 thumbprint of the client certificate.
+	SetCertificateThumbprint(thumbprint string)
+
+	// GetCertificateThumbprint returns the base64url encoded SHA-256 thumbprint of the client certificate, or an
+	// empty string if the tokens are not bound to a certificate.
+	GetCertificateThumbprint() string
+}
+
+// CertificateThumbprint returns the base64url encoded SHA-256 thumbprint of the certificate as used by the
+// x5t#S256 confirmation method.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ValidateCertificateBinding checks that the certificate presented to a resource server matches the certificate the
+// token was bound to when it was issued, see RFC 8705 Section 3. The requester is typically obtained by introspecting
+// the token. Tokens which are not bound to a certificate are accepted.
+func ValidateCertificateBinding(requester Requester, presentedCert *x509.Certificate) error {
+	session, ok := requester.GetSession().(CertificateBoundSession)
+	if !ok || session.GetCertificateThumbprint() == "" {
+		return nil
+	} else if presentedCert == nil {
+		return errors.WithStack(ErrRequestUnauthorized.WithHint("The token is bound to a client certificate but no certificate was presented."))
+	} else if CertificateThumbprint(presentedCert) != session.GetCertificateThumbprint() {
+		return errors.WithStack(ErrRequestUnauthorized.WithHint("The token is bound to a different client certificate than the one presented."))
+	}
+
+	return nil
+}
+
+func isTLSClientAuthMethod(client Client) bool {
+	oidcClient, ok := client.(OpenIDConnectClient)
+	if !ok {
+		return false
+	}
+
+	method := oidcClient.GetTokenEndpointAuthMethod()
+	return method == TLSClientAuthMethod || method == SelfSignedTLSClientAuthMethod
+}
+
+// clientCertificateFromRequest returns the client certificate from the TLSClientCertificateHeader, if configured and
+// sent by a trusted proxy, or from the TLS connection. It returns nil if no certificate was presented. The returned
+// boolean is true if the certificate chain was verified, either by the TLS stack or by the trusted proxy.
+func (f *Fosite) clientCertificateFromRequest(r *http.Request) (*x509.Certificate, bool, error) {
+	if f.TLSClientCertificateHeader != "" && f.isTrustedProxy(r) {
+		if value := r.Header.Get(f.TLSClientCertificateHeader); value != "" {
+			decoded, err := url.QueryUnescape(value)
+			if err != nil {
+				return nil, false, errors.WithStack(ErrInvalidRequest.WithHint("The client certificate header could not be decoded from 'application/x-www-form-urlencoded'.").WithCause(err).WithDebug(err.Error()))
+			}
+
+			block, _ := pem.Decode([]byte(decoded))
+			if block == nil {
+				return nil, false, errors.WithStack(ErrInvalidRequest.WithHint("The client certificate header does not contain a PEM encoded certificate."))
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, false, errors.WithStack(ErrInvalidRequest.WithHint("The client certificate header does not contain a valid certificate.").WithCause(err).WithDebug(err.Error()))
+			}
+			return cert, true, nil
+		}
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0], len(r.TLS.VerifiedChains) > 0, nil
+	}
+
+	return nil, false, nil
+}
+
+func (f *Fosite) authenticateTLSClient(r *http.Request, client OpenIDConnectClient) error {
+	cert, verified, err := f.clientCertificateFromRequest(r)
+	if err != nil {
+		return err
+	} else if cert == nil {
+		return errors.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but no client certificate was presented.", client.GetTokenEndpointAuthMethod()))
+	}
+
+	switch client.GetTokenEndpointAuthMethod() {
+	case TLSClientAuthMethod:
+		tlsClient, ok := client.(ClientWithTLSClientAuth)
+		if !ok {
+			return errors.WithStack(ErrInvalidRequest.WithHintf("The server configuration does not support client authentication method '%s'.", TLSClientAuthMethod))
+		} else if !verified {
+			// PKI mutual-TLS relies on the certificate chain, a self-signed certificate could copy the subject of any client.
+			return errors.WithStack(ErrInvalidClient.WithHint("The client certificate chain could not be verified.").WithDebug("The TLS server must be configured to verify client certificates, for example with tls.VerifyClientCertIfGiven."))
+		} else if !matchTLSClientAuth(cert, tlsClient) {
+			return errors.WithStack(ErrInvalidClient.WithHint("The client certificate does not match the certificate registered for the OAuth 2.0 Client."))
+		}
+	case SelfSignedTLSClientAuthMethod:
+		keys := client.GetJSONWebKeys()
+		if keys == nil && client.GetJSONWebKeysURI() != "" {
+			if keys, err = f.JWKSFetcherStrategy.Resolve(client.GetJSONWebKeysURI(), false); err != nil {
+				return err
+			}
+		}
+
+		if !matchSelfSignedCertificate(cert, keys) {
+			return errors.WithStack(ErrInvalidClient.WithHint("The client certificate does not match any certificate in the JSON Web Key Set registered for the OAuth 2.0 Client."))
+		}
+	}
+
+	return nil
+}
+
+func matchTLSClientAuth(cert *x509.Certificate, client ClientWithTLSClientAuth) bool {
+	if dn := client.GetTLSClientAuthSubjectDN(); dn != "" {
+		return cert.Subject.String() == dn
+	} else if dns := client.GetTLSClientAuthSANDNS(); dns != "" {
+		for _, name := range cert.DNSNames {
+			if name == dns {
+				return true
+			}
+		}
+	} else if uri := client.GetTLSClientAuthSANURI(); uri != "" {
+		for _, u := range cert.URIs {
+			if u.String() == uri {
+				return true
+			}
+		}
+	} else if ip := net.ParseIP(client.GetTLSClientAuthSANIP()); ip != nil {
+		for _, addr := range cert.IPAddresses {
+			if addr.Equal(ip) {
+				return true
+			}
+		}
+	} else if email := client.GetTLSClientAuthSANEmail(); email != "" {
+		for _, address := range cert.EmailAddresses {
+			if address == email {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchSelfSignedCertificate(cert *x509.Certificate, keys *jose.JSONWebKeySet) bool {
+	if keys == nil {
+		return false
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	for _, key := range keys.Keys {
+		if len(key.CertificateThumbprintSHA256) > 0 && bytes.Equal(key.CertificateThumbprintSHA256, sum[:]) {
+			return true
+		}
+		for _, c := range key.Certificates {
+			if c.Equal(cert) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
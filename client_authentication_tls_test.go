@@ -0,0 +1,295 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+func mustGenerateCertificate(t *testing.T, commonName string, dnsNames ...string) *x509.Certificate {
+	key := internal.MustRSAKey()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"fosite"}},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// requestWithCertificate returns a request whose client certificate chain was verified by the TLS server.
+func requestWithCertificate(cert *x509.Certificate) *http.Request {
+	r := new(http.Request)
+	if cert != nil {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return r
+}
+
+// requestWithUnverifiedCertificate returns a request whose client certificate was requested but not verified by the
+// TLS server, as with tls.RequestClientCert.
+func requestWithUnverifiedCertificate(cert *x509.Certificate) *http.Request {
+	r := new(http.Request)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	return r
+}
+
+func TestAuthenticateClientWithTLSClientAuth(t *testing.T) {
+	cert := mustGenerateCertificate(t, "client.fosite", "client.fosite")
+	otherCert := mustGenerateCertificate(t, "other.fosite", "other.fosite")
+
+	for k, tc := range []struct {
+		d         string
+		client    *DefaultOpenIDConnectClient
+		r         *http.Request
+		expectErr error
+	}{
+		{
+			d:      "should pass because the subject DN matches",
+			client: &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: cert.Subject.String()},
+			r:      requestWithCertificate(cert),
+		},
+		{
+			d:      "should pass because the SAN DNS entry matches",
+			client: &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSANDNS: "client.fosite"},
+			r:      requestWithCertificate(cert),
+		},
+		{
+			d:         "should fail because the subject DN does not match",
+			client:    &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: cert.Subject.String()},
+			r:         requestWithCertificate(otherCert),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because the certificate chain was not verified",
+			client:    &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: cert.Subject.String()},
+			r:         requestWithUnverifiedCertificate(mustGenerateCertificate(t, "client.fosite", "client.fosite")),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because no certificate was presented",
+			client:    &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: cert.Subject.String()},
+			r:         requestWithCertificate(nil),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d:         "should fail because the client has no certificate metadata registered",
+			client:    &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "tls_client_auth"},
+			r:         requestWithCertificate(cert),
+			expectErr: ErrInvalidClient,
+		},
+		{
+			d: "should pass because the self-signed certificate is in the client's JSON Web Key Set",
+			client: &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "self_signed_tls_client_auth", JSONWebKeys: &jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{Key: cert.PublicKey, Use: "sig", Certificates: []*x509.Certificate{cert}}},
+			}},
+			r: requestWithUnverifiedCertificate(cert),
+		},
+		{
+			d: "should fail because the self-signed certificate is not in the client's JSON Web Key Set",
+			client: &DefaultOpenIDConnectClient{TokenEndpointAuthMethod: "self_signed_tls_client_auth", JSONWebKeys: &jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{Key: cert.PublicKey, Use: "sig", Certificates: []*x509.Certificate{cert}}},
+			}},
+			r:         requestWithCertificate(otherCert),
+			expectErr: ErrInvalidClient,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			tc.client.DefaultClient = &DefaultClient{ID: "foo"}
+			store.Clients["foo"] = tc.client
+			f := &Fosite{Store: store, JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy()}
+
+			c, err := f.AuthenticateClient(context.Background(), tc.r, url.Values{"client_id": {"foo"}})
+			if tc.expectErr != nil {
+				require.EqualError(t, err, tc.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.client, c)
+		})
+	}
+}
+
+func TestAuthenticateClientWithTLSClientCertificateHeader(t *testing.T) {
+	cert := mustGenerateCertificate(t, "client.fosite")
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "foo"},
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  cert.Subject.String(),
+	}
+	f := &Fosite{Store: store, TLSClientCertificateHeader: "X-Client-Cert", TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}}
+
+	for k, c := range []struct {
+		d          string
+		remoteAddr string
+		header     string
+		expectErr  error
+	}{
+		{d: "should pass because the proxy is in a trusted range", remoteAddr: "10.1.2.3:1234"},
+		{d: "should pass because the proxy is trusted", remoteAddr: "192.168.1.1:1234"},
+		{d: "should fail because the header is malformed", remoteAddr: "10.1.2.3:1234", header: "not-a-certificate", expectErr: ErrInvalidRequest},
+		{d: "should ignore the header because the caller is not a trusted proxy", remoteAddr: "203.0.113.7:1234", expectErr: ErrInvalidClient},
+		{d: "should ignore the header because the remote address is unknown", expectErr: ErrInvalidClient},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := new(http.Request)
+			r.RemoteAddr = c.remoteAddr
+			r.Header = http.Header{}
+			r.Header.Set("X-Client-Cert", url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))))
+			if c.header != "" {
+				r.Header.Set("X-Client-Cert", c.header)
+			}
+
+			_, err := f.AuthenticateClient(context.Background(), r, url.Values{"client_id": {"foo"}})
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNewAccessRequestBindsSessionToClientCertificate(t *testing.T) {
+	cert := mustGenerateCertificate(t, "client.fosite")
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultOpenIDConnectClient{
+		DefaultClient:           &DefaultClient{ID: "foo", GrantTypes: []string{"client_credentials"}},
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  cert.Subject.String(),
+	}
+	f := &Fosite{Store: store, TokenEndpointHandlers: TokenEndpointHandlers{&oauth2.ClientCredentialsGrantHandler{
+		HandleHelper:             &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+		ScopeStrategy:            HierarchicScopeStrategy,
+		AudienceMatchingStrategy: DefaultAudienceMatchingStrategy,
+	}}}
+
+	r := requestWithCertificate(cert)
+	r.Method = "POST"
+	r.PostForm = url.Values{"client_id": {"foo"}, "grant_type": {"client_credentials"}}
+	session := new(oauth2.JWTSession)
+	_, err := f.NewAccessRequest(context.Background(), r, session)
+	require.NoError(t, err)
+	assert.Equal(t, CertificateThumbprint(cert), session.GetCertificateThumbprint())
+}
+
+func TestValidateCertificateBinding(t *testing.T) {
+	cert := mustGenerateCertificate(t, "client.fosite")
+	otherCert := mustGenerateCertificate(t, "other.fosite")
+
+	bound := NewAccessRequest(&DefaultSession{CertificateThumbprint: CertificateThumbprint(cert)})
+	assert.NoError(t, ValidateCertificateBinding(bound, cert))
+	assert.EqualError(t, ValidateCertificateBinding(bound, otherCert), ErrRequestUnauthorized.Error())
+	assert.EqualError(t, ValidateCertificateBinding(bound, nil), ErrRequestUnauthorized.Error())
+
+	unbound := NewAccessRequest(&DefaultSession{})
+	assert.NoError(t, ValidateCertificateBinding(unbound, otherCert))
+}
+
+func TestCertificateBoundTokens(t *testing.T) {
+	cert := mustGenerateCertificate(t, "client.fosite")
+	otherCert := mustGenerateCertificate(t, "other.fosite")
+
+	store := storage.NewMemoryStore()
+	store.Users["peter"] = storage.MemoryUserRelation{Username: "peter", Password: "secret"}
+	store.Clients["foo"] = &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{
+			ID:         "foo",
+			GrantTypes: []string{"password", "refresh_token"},
+			Scopes:     []string{"offline"},
+		},
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  cert.Subject.String(),
+	}
+	f := compose.ComposeAllEnabled(new(compose.Config), store, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+
+	issue := func(form url.Values) AccessResponder {
+		r := requestWithCertificate(cert)
+		r.Method = "POST"
+		r.PostForm = form
+		ar, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+		require.NoError(t, err)
+		for _, scope := range ar.GetRequestedScopes() {
+			ar.GrantScope(scope)
+		}
+		resp, err := f.NewAccessResponse(context.Background(), ar)
+		require.NoError(t, err)
+		return resp
+	}
+
+	introspect := func(token string) AccessRequester {
+		_, ar, err := f.IntrospectToken(context.Background(), token, AccessToken, new(DefaultSession))
+		require.NoError(t, err)
+		return ar
+	}
+
+	resp := issue(url.Values{"client_id": {"foo"}, "grant_type": {"password"}, "username": {"peter"}, "password": {"secret"}, "scope": {"offline"}})
+	ar := introspect(resp.GetAccessToken())
+	assert.NoError(t, ValidateCertificateBinding(ar, cert))
+	assert.Error(t, ValidateCertificateBinding(ar, otherCert))
+
+	rw := httptest.NewRecorder()
+	f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: true, AccessRequester: ar})
+	var body struct {
+		Confirmation map[string]string `json:"cnf"`
+	}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	assert.Equal(t, map[string]string{"x5t#S256": CertificateThumbprint(cert)}, body.Confirmation)
+
+	// the binding is carried forward when refreshing the token
+	refreshed := issue(url.Values{"client_id": {"foo"}, "grant_type": {"refresh_token"}, "refresh_token": {resp.ToMap()["refresh_token"].(string)}})
+	ar = introspect(refreshed.GetAccessToken())
+	assert.NoError(t, ValidateCertificateBinding(ar, cert))
+	assert.Error(t, ValidateCertificateBinding(ar, otherCert))
+}
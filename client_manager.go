@@ -34,9 +34,18 @@ type ClientManager interface {
 	// ClientAssertionJWTValid returns an error if the JTI is
 	// known or the DB check failed and nil if the JTI is not known.
 	ClientAssertionJWTValid(ctx context.Context, jti string) error
+
+	JTIStore
+}
+
+// JTIStore protects JSON Web Tokens which must only be used once, for example client assertions, against replay
+// by remembering their JTI until they expire.
+type JTIStore interface {
 	// SetClientAssertionJWT marks a JTI as known for the given
 	// expiry time. Before inserting the new JTI, it will clean
 	// up any existing JTIs that have expired as those tokens can
-	// not be replayed due to the expiry.
+	// not be replayed due to the expiry. It returns ErrJTIKnown
+	// if the JTI is already known, which must be checked and set
+	// atomically to detect concurrent replays.
 	SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error
 }
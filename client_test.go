@@ -40,6 +40,7 @@ func TestDefaultClient(t *testing.T) {
 	assert.Equal(t, sc.ID, sc.GetID())
 	assert.Equal(t, sc.RedirectURIs, sc.GetRedirectURIs())
 	assert.Equal(t, sc.Secret, sc.GetHashedSecret())
+	assert.Empty(t, sc.GetRotatedHashedSecret())
 	assert.EqualValues(t, sc.ResponseTypes, sc.GetResponseTypes())
 	assert.EqualValues(t, sc.GrantTypes, sc.GetGrantTypes())
 	assert.EqualValues(t, sc.Scopes, sc.GetScopes())
@@ -54,3 +55,20 @@ func TestDefaultResponseModeClient_GetResponseMode(t *testing.T) {
 	rc := &DefaultResponseModeClient{ResponseModes: []ResponseModeType{ResponseModeFragment}}
 	assert.Equal(t, []ResponseModeType{ResponseModeFragment}, rc.GetResponseModes())
 }
+
+func TestGetClientTokenEndpointAuthMethod(t *testing.T) {
+	for k, c := range []struct {
+		client       Client
+		expectMethod string
+		expectPublic bool
+	}{
+		{client: &DefaultClient{}, expectMethod: "client_secret_basic"},
+		{client: &DefaultClient{Public: true}, expectMethod: "none", expectPublic: true},
+		{client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{}, TokenEndpointAuthMethod: "client_secret_post"}, expectMethod: "client_secret_post"},
+		{client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{}, TokenEndpointAuthMethod: "none"}, expectMethod: "none", expectPublic: true},
+		{client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{Public: true}}, expectMethod: "none", expectPublic: true},
+	} {
+		assert.Equal(t, c.expectMethod, GetClientTokenEndpointAuthMethod(c.client), "case=%d", k)
+		assert.Equal(t, c.expectPublic, IsPublicClient(c.client), "case=%d", k)
+	}
+}
@@ -52,24 +52,76 @@ type Factory func(config *Config, storage interface{}, strategy interface{}) int
 //  )
 //
 // Compose makes use of interface{} types in order to be able to handle a all types of stores, strategies and handlers.
+// It panics if the JARMSigningKey or IntrospectionSigningKey of the config does not satisfy its SigningKeyPolicy.
 func Compose(config *Config, storage interface{}, strategy interface{}, hasher fosite.Hasher, factories ...Factory) fosite.OAuth2Provider {
 	if hasher == nil {
-		hasher = &fosite.BCrypt{WorkFactor: config.GetHashCost()}
+		hasher = config.GetClientSecretsHasher()
+	}
+
+	if config.JARMSigningKey != nil {
+		mustSatisfySigningKeyPolicy(config.GetSigningKeyPolicy(), config.JARMSigningKey)
+	}
+	if config.IntrospectionSigningKey != nil {
+		mustSatisfySigningKeyPolicy(config.GetSigningKeyPolicy(), config.IntrospectionSigningKey)
 	}
 
 	f := &fosite.Fosite{
-		Store:                      storage.(fosite.Storage),
-		AuthorizeEndpointHandlers:  fosite.AuthorizeEndpointHandlers{},
-		TokenEndpointHandlers:      fosite.TokenEndpointHandlers{},
-		TokenIntrospectionHandlers: fosite.TokenIntrospectionHandlers{},
-		RevocationHandlers:         fosite.RevocationHandlers{},
-		Hasher:                     hasher,
-		ScopeStrategy:              config.GetScopeStrategy(),
-		AudienceMatchingStrategy:   config.GetAudienceStrategy(),
-		SendDebugMessagesToClients: config.SendDebugMessagesToClients,
-		TokenURL:                   config.TokenURL,
-		JWKSFetcherStrategy:        config.GetJWKSFetcherStrategy(),
-		MinParameterEntropy:        config.GetMinParameterEntropy(),
+		Store:                              storage.(fosite.Storage),
+		AuthorizeEndpointHandlers:          fosite.AuthorizeEndpointHandlers{},
+		TokenEndpointHandlers:              fosite.TokenEndpointHandlers{},
+		TokenIntrospectionHandlers:         fosite.TokenIntrospectionHandlers{},
+		RevocationHandlers:                 fosite.RevocationHandlers{},
+		Hasher:                             hasher,
+		ScopeStrategy:                      config.GetScopeStrategy(),
+		AudienceMatchingStrategy:           config.GetAudienceStrategy(),
+		RedirectURIMatchingStrategy:        config.GetRedirectURIMatchingStrategy(),
+		SendDebugMessagesToClients:         config.SendDebugMessagesToClients,
+		ErrorWriter:                        config.ErrorWriter,
+		ErrorHook:                          config.ErrorHook,
+		BearerRealm:                        config.BearerRealm,
+		ResourceServerAudience:             config.ResourceServerAudience,
+		BearerTokenLocations:               config.BearerTokenLocations,
+		BearerTokenHeader:                  config.BearerTokenHeader,
+		AuthorizeResponseHooks:             config.GetAuthorizeResponseHooks(),
+		HideUnsupportedGrantTypes:          config.HideUnsupportedGrantTypes,
+		TokenURL:                           config.TokenURL,
+		TLSClientCertificateHeader:         config.TLSClientCertificateHeader,
+		TrustedProxies:                     config.TrustedProxies,
+		JWKSFetcherStrategy:                config.GetJWKSFetcherStrategy(),
+		SectorIdentifierValidator:          config.GetSectorIdentifierValidator(),
+		StorageRetryPolicy:                 config.StorageRetryPolicy,
+		RateLimiter:                        config.RateLimiter,
+		TokenTypeHintMetricsHook:           config.TokenTypeHintMetricsHook,
+		DPoPNonceStrategy:                  config.DPoPNonceStrategy,
+		DPoPProofLifespan:                  config.DPoPProofLifespan,
+		Clock:                              config.Clock,
+		IntrospectionAudiencePolicy:        config.IntrospectionAudiencePolicy,
+		MinParameterEntropy:                config.GetMinParameterEntropy(),
+		MinStateEntropy:                    config.GetMinStateEntropy(),
+		StateReplayStore:                   config.StateReplayStore,
+		StateReplayWindow:                  config.StateReplayWindow,
+		JARMSigningKey:                     config.JARMSigningKey,
+		JARMSigningKeyID:                   config.JARMSigningKeyID,
+		JARMIssuer:                         config.JARMIssuer,
+		JARMLifespan:                       config.JARMLifespan,
+		JARMIncludeStateHash:               config.IncludeStateHash,
+		IntrospectionSigningKey:            config.IntrospectionSigningKey,
+		IntrospectionSigningKeyID:          config.IntrospectionSigningKeyID,
+		IntrospectionIssuer:                config.IntrospectionIssuer,
+		IssuerFromRequest:                  config.IssuerFromRequest,
+		IntrospectionCacheTTL:              config.IntrospectionCacheTTL,
+		RequestURIMaxBodySize:              config.RequestURIMaxBodySize,
+		RequestURIMaxRedirects:             config.RequestURIMaxRedirects,
+		MaxRequestBodySize:                 config.MaxRequestBodySize,
+		IDGenerator:                        config.IDGenerator,
+		KnownScopes:                        config.KnownScopes,
+		DefaultScopes:                      config.DefaultScopes,
+		EnabledResponseTypes:               config.EnabledResponseTypes,
+		ClientSecretRotationHook:           config.ClientSecretRotationHook,
+		TokenEndpointAuthSigningAlgorithms: config.TokenEndpointAuthSigningAlgorithms,
+		FormPostHTMLTemplate:               config.FormPostHTMLTemplate,
+		AuthorizeRequestLifespan:           config.AuthorizeRequestLifespan,
+		EncodedAuthorizeRequestLifespan:    config.EncodedAuthorizeRequestLifespan,
 	}
 
 	for _, factory := range factories {
@@ -91,9 +143,10 @@ func Compose(config *Config, storage interface{}, strategy interface{}, hasher f
 	return f
 }
 
-// ComposeAllEnabled returns a fosite instance with all OAuth2 and OpenID Connect handlers enabled.
+// ComposeAllEnabled returns a fosite instance with all OAuth2 and OpenID Connect handlers enabled. It panics if the key
+// does not satisfy the SigningKeyPolicy of the config.
 func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *rsa.PrivateKey) fosite.OAuth2Provider {
-	return Compose(
+	provider := Compose(
 		config,
 		storage,
 		&CommonStrategy{
@@ -115,10 +168,17 @@ func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *
 		OpenIDConnectImplicitFactory,
 		OpenIDConnectHybridFactory,
 		OpenIDConnectRefreshFactory,
+		OpenIDConnectNoneFactory,
 
 		OAuth2TokenIntrospectionFactory,
 		OAuth2TokenRevocationFactory,
 
 		OAuth2PKCEFactory,
 	)
+
+	if f, ok := provider.(*fosite.Fosite); ok && f.IntrospectionSigningKey == nil {
+		f.IntrospectionSigningKey = key
+	}
+
+	return provider
 }
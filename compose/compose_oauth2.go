@@ -30,18 +30,22 @@ import (
 // an access token, refresh token and authorize code validator.
 func OAuth2AuthorizeExplicitFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &oauth2.AuthorizeExplicitGrantHandler{
-		AccessTokenStrategy:      strategy.(oauth2.AccessTokenStrategy),
-		RefreshTokenStrategy:     strategy.(oauth2.RefreshTokenStrategy),
-		AuthorizeCodeStrategy:    strategy.(oauth2.AuthorizeCodeStrategy),
-		CoreStorage:              storage.(oauth2.CoreStorage),
-		AuthCodeLifespan:         config.GetAuthorizeCodeLifespan(),
-		RefreshTokenLifespan:     config.GetRefreshTokenLifespan(),
-		AccessTokenLifespan:      config.GetAccessTokenLifespan(),
-		ScopeStrategy:            config.GetScopeStrategy(),
-		AudienceMatchingStrategy: config.GetAudienceStrategy(),
-		TokenRevocationStorage:   storage.(oauth2.TokenRevocationStorage),
-		IsRedirectURISecure:      config.GetRedirectSecureChecker(),
-		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		AccessTokenStrategy:            strategy.(oauth2.AccessTokenStrategy),
+		RefreshTokenStrategy:           strategy.(oauth2.RefreshTokenStrategy),
+		AuthorizeCodeStrategy:          strategy.(oauth2.AuthorizeCodeStrategy),
+		CoreStorage:                    storage.(oauth2.CoreStorage),
+		AuthCodeLifespan:               config.GetAuthorizeCodeLifespan(),
+		RefreshTokenLifespan:           config.GetRefreshTokenLifespan(),
+		AccessTokenLifespan:            config.GetAccessTokenLifespan(),
+		ScopeStrategy:                  config.GetScopeStrategy(),
+		AudienceMatchingStrategy:       config.GetAudienceStrategy(),
+		TokenRevocationStorage:         storage.(oauth2.TokenRevocationStorage),
+		IsRedirectURISecure:            config.GetRedirectSecureChecker(),
+		RefreshTokenScopes:             config.GetRefreshTokenScopes(),
+		RequireOfflineAccessForOpenID:  config.RequireOfflineAccessForOpenID,
+		RequireConsentForOfflineAccess: config.RequireConsentForOfflineAccess,
+		StatelessAccessTokens:          config.StatelessAccessTokens,
+		Clock:                          config.Clock,
 	}
 }
 
@@ -50,9 +54,11 @@ func OAuth2AuthorizeExplicitFactory(config *Config, storage interface{}, strateg
 func OAuth2ClientCredentialsGrantFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &oauth2.ClientCredentialsGrantHandler{
 		HandleHelper: &oauth2.HandleHelper{
-			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
-			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
-			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+			StatelessAccessTokens: config.StatelessAccessTokens,
+			Clock:                 config.Clock,
 		},
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
@@ -63,14 +69,17 @@ func OAuth2ClientCredentialsGrantFactory(config *Config, storage interface{}, st
 // an access token, refresh token and authorize code validator.
 func OAuth2RefreshTokenGrantFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &oauth2.RefreshTokenGrantHandler{
-		AccessTokenStrategy:      strategy.(oauth2.AccessTokenStrategy),
-		RefreshTokenStrategy:     strategy.(oauth2.RefreshTokenStrategy),
-		TokenRevocationStorage:   storage.(oauth2.TokenRevocationStorage),
-		AccessTokenLifespan:      config.GetAccessTokenLifespan(),
-		RefreshTokenLifespan:     config.GetRefreshTokenLifespan(),
-		ScopeStrategy:            config.GetScopeStrategy(),
-		AudienceMatchingStrategy: config.GetAudienceStrategy(),
-		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		AccessTokenStrategy:           strategy.(oauth2.AccessTokenStrategy),
+		RefreshTokenStrategy:          strategy.(oauth2.RefreshTokenStrategy),
+		TokenRevocationStorage:        storage.(oauth2.TokenRevocationStorage),
+		AccessTokenLifespan:           config.GetAccessTokenLifespan(),
+		RefreshTokenLifespan:          config.GetRefreshTokenLifespan(),
+		ScopeStrategy:                 config.GetScopeStrategy(),
+		AudienceMatchingStrategy:      config.GetAudienceStrategy(),
+		RefreshTokenScopes:            config.GetRefreshTokenScopes(),
+		EnforceDPoPBoundRefreshTokens: config.EnforceDPoPBoundRefreshTokens,
+		StatelessAccessTokens:         config.StatelessAccessTokens,
+		Clock:                         config.Clock,
 	}
 }
 
@@ -83,6 +92,8 @@ func OAuth2AuthorizeImplicitFactory(config *Config, storage interface{}, strateg
 		AccessTokenLifespan:      config.GetAccessTokenLifespan(),
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
+		StatelessAccessTokens:    config.StatelessAccessTokens,
+		Clock:                    config.Clock,
 	}
 }
 
@@ -92,24 +103,34 @@ func OAuth2ResourceOwnerPasswordCredentialsFactory(config *Config, storage inter
 	return &oauth2.ResourceOwnerPasswordCredentialsGrantHandler{
 		ResourceOwnerPasswordCredentialsGrantStorage: storage.(oauth2.ResourceOwnerPasswordCredentialsGrantStorage),
 		HandleHelper: &oauth2.HandleHelper{
-			AccessTokenStrategy:  strategy.(oauth2.AccessTokenStrategy),
-			AccessTokenStorage:   storage.(oauth2.AccessTokenStorage),
-			AccessTokenLifespan:  config.GetAccessTokenLifespan(),
-			RefreshTokenLifespan: config.GetRefreshTokenLifespan(),
+			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+			RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
+			StatelessAccessTokens: config.StatelessAccessTokens,
+			Clock:                 config.Clock,
 		},
 		RefreshTokenStrategy:     strategy.(oauth2.RefreshTokenStrategy),
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
 		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		LoginThrottle:            config.LoginThrottle,
 	}
 }
 
-// OAuth2TokenRevocationFactory creates an OAuth2 token revocation handler.
+// OAuth2TokenRevocationFactory creates an OAuth2 token revocation handler. If Config.TokenDenylist is set, JWT
+// access tokens which are not found in the storage are added to it.
 func OAuth2TokenRevocationFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	jwtStrategy, _ := strategy.(jwt.JWTStrategy)
 	return &oauth2.TokenRevocationHandler{
-		TokenRevocationStorage: storage.(oauth2.TokenRevocationStorage),
-		AccessTokenStrategy:    strategy.(oauth2.AccessTokenStrategy),
-		RefreshTokenStrategy:   strategy.(oauth2.RefreshTokenStrategy),
+		TokenRevocationStorage:   storage.(oauth2.TokenRevocationStorage),
+		AccessTokenStrategy:      strategy.(oauth2.AccessTokenStrategy),
+		RefreshTokenStrategy:     strategy.(oauth2.RefreshTokenStrategy),
+		TokenTypeHintMetricsHook: config.TokenTypeHintMetricsHook,
+		TokenDenylist:            config.TokenDenylist,
+		JWTStrategy:              jwtStrategy,
+		Clock:                    config.Clock,
+		ClockSkew:                config.ClockSkew,
 	}
 }
 
@@ -121,6 +142,7 @@ func OAuth2TokenIntrospectionFactory(config *Config, storage interface{}, strate
 		CoreStorage:                   storage.(oauth2.CoreStorage),
 		ScopeStrategy:                 config.GetScopeStrategy(),
 		DisableRefreshTokenValidation: config.DisableRefreshTokenValidation,
+		RetryPolicy:                   config.StorageRetryPolicy,
 	}
 }
 
@@ -130,10 +152,17 @@ func OAuth2TokenIntrospectionFactory(config *Config, storage interface{}, strate
 // storage implementation at all.
 //
 // Due to the stateless nature of this factory, THE BUILT-IN REVOCATION MECHANISMS WILL NOT WORK.
-// If you need revocation, you can validate JWTs statefully, using the other factories.
+// If you need revocation, you can validate JWTs statefully, using the other factories, or set
+// Config.TokenDenylist, which is then checked for revoked tokens instead.
+//
+// Together with Config.StatelessAccessTokens, access tokens are neither persisted nor looked up. The storage is
+// then still required for exchanging authorization codes, refreshing tokens and revoking refresh tokens.
 func OAuth2StatelessJWTIntrospectionFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &oauth2.StatelessJWTValidator{
 		JWTStrategy:   strategy.(jwt.JWTStrategy),
 		ScopeStrategy: config.GetScopeStrategy(),
+		Clock:         config.Clock,
+		ClockSkew:     config.ClockSkew,
+		TokenDenylist: config.TokenDenylist,
 	}
 }
@@ -31,13 +31,36 @@ import (
 //
 // **Important note:** You must add this handler *after* you have added an OAuth2 authorize code handler!
 func OpenIDConnectExplicitFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	var nonceStorage openid.NonceReplayStorage
+	if config.EnforceNonceReplayProtection {
+		nonceStorage = storage.(openid.NonceReplayStorage)
+	}
+
 	return &openid.OpenIDConnectExplicitHandler{
-		OpenIDConnectRequestStorage: storage.(openid.OpenIDConnectRequestStorage),
+		OpenIDConnectRequestStorage:  storage.(openid.OpenIDConnectRequestStorage),
+		EnforceNonceReplayProtection: config.EnforceNonceReplayProtection,
+		NonceReplayStorage:           nonceStorage,
+		NonceReplayWindow:            config.NonceReplayWindow,
+		MinParameterEntropy:          config.GetMinNonceEntropy(),
+		Clock:                        config.Clock,
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
 		},
 		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
+			WithRedirectSecureChecker(config.GetRedirectSecureChecker()).
+			WithPromptNoneConsentPolicy(config.PromptNoneConsentPolicy).
+			WithClock(config.Clock),
+	}
+}
+
+// OpenIDConnectNoneFactory creates a handler validating OpenID Connect requests with response type "none", for example
+// requests with "prompt=none" which only check the end-user's session and consent.
+func OpenIDConnectNoneFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &openid.OpenIDConnectNoneHandler{
+		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
+			WithRedirectSecureChecker(config.GetRedirectSecureChecker()).
+			WithPromptNoneConsentPolicy(config.PromptNoneConsentPolicy).
+			WithClock(config.Clock),
 	}
 }
 
@@ -58,17 +81,23 @@ func OpenIDConnectRefreshFactory(config *Config, storage interface{}, strategy i
 func OpenIDConnectImplicitFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &openid.OpenIDConnectImplicitHandler{
 		AuthorizeImplicitGrantTypeHandler: &oauth2.AuthorizeImplicitGrantTypeHandler{
-			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
-			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
-			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+			StatelessAccessTokens: config.StatelessAccessTokens,
+			Clock:                 config.Clock,
 		},
 		ScopeStrategy: config.GetScopeStrategy(),
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
 		},
 		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+			WithRedirectSecureChecker(config.GetRedirectSecureChecker()).
+			WithPromptNoneConsentPolicy(config.PromptNoneConsentPolicy).
+			WithClock(config.Clock),
+		MinParameterEntropy: config.GetMinNonceEntropy(),
+		AllowMissingNonce:   config.AllowMissingNonce,
+		IncludeStateHash:    config.IncludeStateHash,
 	}
 }
 
@@ -78,27 +107,36 @@ func OpenIDConnectImplicitFactory(config *Config, storage interface{}, strategy
 func OpenIDConnectHybridFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
 	return &openid.OpenIDConnectHybridHandler{
 		AuthorizeExplicitGrantHandler: &oauth2.AuthorizeExplicitGrantHandler{
-			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
-			RefreshTokenStrategy:  strategy.(oauth2.RefreshTokenStrategy),
-			AuthorizeCodeStrategy: strategy.(oauth2.AuthorizeCodeStrategy),
-			CoreStorage:           storage.(oauth2.CoreStorage),
-			AuthCodeLifespan:      config.GetAuthorizeCodeLifespan(),
-			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
-			RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
-			IsRedirectURISecure:   config.GetRedirectSecureChecker(),
+			AccessTokenStrategy:            strategy.(oauth2.AccessTokenStrategy),
+			RefreshTokenStrategy:           strategy.(oauth2.RefreshTokenStrategy),
+			AuthorizeCodeStrategy:          strategy.(oauth2.AuthorizeCodeStrategy),
+			CoreStorage:                    storage.(oauth2.CoreStorage),
+			AuthCodeLifespan:               config.GetAuthorizeCodeLifespan(),
+			AccessTokenLifespan:            config.GetAccessTokenLifespan(),
+			RefreshTokenLifespan:           config.GetRefreshTokenLifespan(),
+			IsRedirectURISecure:            config.GetRedirectSecureChecker(),
+			RequireConsentForOfflineAccess: config.RequireConsentForOfflineAccess,
+			StatelessAccessTokens:          config.StatelessAccessTokens,
+			Clock:                          config.Clock,
 		},
 		ScopeStrategy: config.GetScopeStrategy(),
 		AuthorizeImplicitGrantTypeHandler: &oauth2.AuthorizeImplicitGrantTypeHandler{
-			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
-			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
-			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+			StatelessAccessTokens: config.StatelessAccessTokens,
+			Clock:                 config.Clock,
 		},
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
 		},
 		OpenIDConnectRequestStorage: storage.(openid.OpenIDConnectRequestStorage),
 		OpenIDConnectRequestValidator: openid.NewOpenIDConnectRequestValidator(config.AllowedPromptValues, strategy.(jwt.JWTStrategy)).
-			WithRedirectSecureChecker(config.GetRedirectSecureChecker()),
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+			WithRedirectSecureChecker(config.GetRedirectSecureChecker()).
+			WithPromptNoneConsentPolicy(config.PromptNoneConsentPolicy).
+			WithClock(config.Clock),
+		MinParameterEntropy: config.GetMinNonceEntropy(),
+		AllowMissingNonce:   config.AllowMissingNonce,
+		IncludeStateHash:    config.IncludeStateHash,
 	}
 }
@@ -0,0 +1,51 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package compose
+
+import (
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/rfc7523"
+)
+
+// RFC7523AssertionGrantFactory creates a JWT bearer authorization grant (RFC 7523) handler. The storage must
+// implement rfc7523.RFC7523KeyStorage.
+func RFC7523AssertionGrantFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	return &rfc7523.Handler{
+		HandleHelper: &oauth2.HandleHelper{
+			AccessTokenStrategy:   strategy.(oauth2.AccessTokenStrategy),
+			AccessTokenStorage:    storage.(oauth2.AccessTokenStorage),
+			AccessTokenLifespan:   config.GetAccessTokenLifespan(),
+			StatelessAccessTokens: config.StatelessAccessTokens,
+			Clock:                 config.Clock,
+		},
+		Storage:                  storage.(rfc7523.RFC7523KeyStorage),
+		JTIStore:                 storage.(fosite.JTIStore),
+		ScopeStrategy:            config.GetScopeStrategy(),
+		AudienceMatchingStrategy: config.GetAudienceStrategy(),
+		TokenURL:                 config.TokenURL,
+		IssuerFromRequest:        config.IssuerFromRequest,
+		MaxAssertionLifespan:     config.JWTBearerMaxAssertionLifespan,
+		ClockSkew:                config.ClockSkew,
+		SubjectMapper:            config.JWTBearerSubjectMapper,
+	}
+}
@@ -22,9 +22,17 @@
 package compose
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"time"
 
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
 	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/token/hmac"
@@ -42,29 +50,47 @@ func NewOAuth2HMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byt
 		Enigma: &hmac.HMACStrategy{
 			GlobalSecret:         secret,
 			RotatedGlobalSecrets: rotatedSecrets,
+			Keys:                 config.HMACKeys,
 			TokenEntropy:         config.GetTokenEntropy(),
 		},
 		AccessTokenLifespan:   config.GetAccessTokenLifespan(),
 		AuthorizeCodeLifespan: config.GetAuthorizeCodeLifespan(),
 		RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
+		AccessTokenPrefix:     config.AccessTokenPrefix,
+		Clock:                 config.Clock,
+		ClockSkew:             config.ClockSkew,
 	}
 }
 
+// NewOAuth2JWTStrategy returns a strategy signing JWT access tokens with RS256. It panics if the key does not satisfy
+// jwt.DefaultKeyPolicy, use NewOAuth2JWTStrategyWithKey to apply another policy.
 func NewOAuth2JWTStrategy(key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	if key != nil {
+		mustSatisfySigningKeyPolicy(jwt.DefaultKeyPolicy, key)
+	}
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.RS256JWTStrategy{
 			PrivateKey: key,
 		},
 		HMACSHAStrategy: strategy,
+		Clock:           strategyClock(strategy),
+		ClockSkew:       strategyClockSkew(strategy),
 	}
 }
 
+// NewOAuth2JWTECDSAStrategy returns a strategy signing JWT access tokens with ES256. It panics if the key does not
+// satisfy jwt.DefaultKeyPolicy, use NewOAuth2JWTStrategyWithKey to apply another policy.
 func NewOAuth2JWTECDSAStrategy(key *ecdsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	if key != nil {
+		mustSatisfySigningKeyPolicy(jwt.DefaultKeyPolicy, key)
+	}
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.ES256JWTStrategy{
 			PrivateKey: key,
 		},
 		HMACSHAStrategy: strategy,
+		Clock:           strategyClock(strategy),
+		ClockSkew:       strategyClockSkew(strategy),
 	}
 }
 
@@ -78,24 +104,215 @@ func NewOAuth2JWTECDSAStrategyWithIssuer(key *ecdsa.PrivateKey, strategy *oauth2
 	return NewOAuth2JWTECDSAStrategy(key, strategy).WithIssuer(issuer)
 }
 
+// NewOpenIDConnectStrategy returns a strategy signing ID Tokens with RS256. It panics if the key does not satisfy the
+// configured SigningKeyPolicy, use NewOpenIDConnectStrategyWithKey to handle the error instead.
 func NewOpenIDConnectStrategy(config *Config, key *rsa.PrivateKey) *openid.DefaultStrategy {
+	if key != nil {
+		mustSatisfySigningKeyPolicy(config.GetSigningKeyPolicy(), key)
+	}
 	return &openid.DefaultStrategy{
 		JWTStrategy: &jwt.RS256JWTStrategy{
 			PrivateKey: key,
 		},
-		Expiry:              config.GetIDTokenLifespan(),
-		Issuer:              config.IDTokenIssuer,
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+		Expiry:                      config.GetIDTokenLifespan(),
+		Issuer:                      config.IDTokenIssuer,
+		IssuerFromRequest:           config.IssuerFromRequest,
+		MinParameterEntropy:         config.GetMinNonceEntropy(),
+		ScopeClaim:                  config.IDTokenScopeClaim,
+		SubjectIdentifierAlgorithms: config.GetSubjectIdentifierAlgorithms(),
+		StrictAudience:              config.StrictIDTokenAudience,
+		Clock:                       config.Clock,
+		IDGenerator:                 config.IDGenerator,
+		ClaimsEnrichmentHook:        config.ClaimsEnrichmentHook,
 	}
 }
 
+// NewOpenIDConnectECDSAStrategy returns a strategy signing ID Tokens with ES256. It panics if the key does not satisfy
+// the configured SigningKeyPolicy, use NewOpenIDConnectStrategyWithKey to handle the error instead.
 func NewOpenIDConnectECDSAStrategy(config *Config, key *ecdsa.PrivateKey) *openid.DefaultStrategy {
+	if key != nil {
+		mustSatisfySigningKeyPolicy(config.GetSigningKeyPolicy(), key)
+	}
 	return &openid.DefaultStrategy{
 		JWTStrategy: &jwt.ES256JWTStrategy{
 			PrivateKey: key,
 		},
-		Expiry:              config.GetIDTokenLifespan(),
-		Issuer:              config.IDTokenIssuer,
-		MinParameterEntropy: config.GetMinParameterEntropy(),
+		Expiry:                      config.GetIDTokenLifespan(),
+		Issuer:                      config.IDTokenIssuer,
+		IssuerFromRequest:           config.IssuerFromRequest,
+		MinParameterEntropy:         config.GetMinNonceEntropy(),
+		ScopeClaim:                  config.IDTokenScopeClaim,
+		SubjectIdentifierAlgorithms: config.GetSubjectIdentifierAlgorithms(),
+		StrictAudience:              config.StrictIDTokenAudience,
+		Clock:                       config.Clock,
+		IDGenerator:                 config.IDGenerator,
+		ClaimsEnrichmentHook:        config.ClaimsEnrichmentHook,
+	}
+}
+
+// NewOAuth2JWTStrategyWithKey works like NewOAuth2JWTStrategy and NewOAuth2JWTECDSAStrategy, depending on the type of
+// the key, but returns an error if the key does not satisfy the configured SigningKeyPolicy.
+func NewOAuth2JWTStrategyWithKey(config *Config, key crypto.Signer, strategy *oauth2.HMACSHAStrategy) (*oauth2.DefaultJWTStrategy, error) {
+	j, err := newJWTStrategy(config, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.DefaultJWTStrategy{
+		JWTStrategy:          j,
+		HMACSHAStrategy:      strategy,
+		Clock:                strategyClock(strategy),
+		ClockSkew:            strategyClockSkew(strategy),
+		ScopeClaimMappers:    config.AccessTokenScopeClaimMappers,
+		IDGenerator:          config.IDGenerator,
+		RefreshTokenFormat:   config.RefreshTokenFormat,
+		ClaimsEnrichmentHook: config.ClaimsEnrichmentHook,
+		IssuerFromRequest:    config.IssuerFromRequest,
+	}, nil
+}
+
+// NewOpenIDConnectStrategyWithKey works like NewOpenIDConnectStrategy and NewOpenIDConnectECDSAStrategy, depending on
+// the type of the key, but returns an error if the key does not satisfy the configured SigningKeyPolicy.
+func NewOpenIDConnectStrategyWithKey(config *Config, key crypto.Signer) (*openid.DefaultStrategy, error) {
+	j, err := newJWTStrategy(config, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &openid.DefaultStrategy{
+		JWTStrategy:                 j,
+		Expiry:                      config.GetIDTokenLifespan(),
+		Issuer:                      config.IDTokenIssuer,
+		IssuerFromRequest:           config.IssuerFromRequest,
+		MinParameterEntropy:         config.GetMinNonceEntropy(),
+		ScopeClaim:                  config.IDTokenScopeClaim,
+		SubjectIdentifierAlgorithms: config.GetSubjectIdentifierAlgorithms(),
+		StrictAudience:              config.StrictIDTokenAudience,
+		Clock:                       config.Clock,
+		IDGenerator:                 config.IDGenerator,
+		ClaimsEnrichmentHook:        config.ClaimsEnrichmentHook,
+	}, nil
+}
+
+// NewOAuth2JWTStrategyWithKeyRing works like NewOAuth2JWTStrategyWithKey but signs access tokens with the active key of
+// the key ring and verifies them with the key referenced by their "kid" header. It returns an error if a key does not
+// satisfy the configured SigningKeyPolicy.
+func NewOAuth2JWTStrategyWithKeyRing(config *Config, ring *jwt.KeyRing, strategy *oauth2.HMACSHAStrategy) (*oauth2.DefaultJWTStrategy, error) {
+	j, err := newKeyRingJWTStrategy(config, ring, config.AccessTokenSigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.DefaultJWTStrategy{
+		JWTStrategy:          j,
+		HMACSHAStrategy:      strategy,
+		Clock:                strategyClock(strategy),
+		ClockSkew:            strategyClockSkew(strategy),
+		ScopeClaimMappers:    config.AccessTokenScopeClaimMappers,
+		IDGenerator:          config.IDGenerator,
+		RefreshTokenFormat:   config.RefreshTokenFormat,
+		ClaimsEnrichmentHook: config.ClaimsEnrichmentHook,
+		IssuerFromRequest:    config.IssuerFromRequest,
+	}, nil
+}
+
+// NewOpenIDConnectStrategyWithKeyRing works like NewOpenIDConnectStrategyWithKey but signs ID tokens with the active key
+// of the key ring. It returns an error if a key does not satisfy the configured SigningKeyPolicy.
+func NewOpenIDConnectStrategyWithKeyRing(config *Config, ring *jwt.KeyRing) (*openid.DefaultStrategy, error) {
+	j, err := newKeyRingJWTStrategy(config, ring, config.IDTokenSigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &openid.DefaultStrategy{
+		JWTStrategy:                 j,
+		Expiry:                      config.GetIDTokenLifespan(),
+		Issuer:                      config.IDTokenIssuer,
+		IssuerFromRequest:           config.IssuerFromRequest,
+		MinParameterEntropy:         config.GetMinNonceEntropy(),
+		ScopeClaim:                  config.IDTokenScopeClaim,
+		SubjectIdentifierAlgorithms: config.GetSubjectIdentifierAlgorithms(),
+		StrictAudience:              config.StrictIDTokenAudience,
+		Clock:                       config.Clock,
+		IDGenerator:                 config.IDGenerator,
+		ClaimsEnrichmentHook:        config.ClaimsEnrichmentHook,
+	}, nil
+}
+
+func newKeyRingJWTStrategy(config *Config, ring *jwt.KeyRing, alg string) (*jwt.KeyRingJWTStrategy, error) {
+	if len(ring.Keys) == 0 {
+		return nil, errors.New("Key ring does not contain any keys")
+	}
+
+	for _, k := range ring.Keys {
+		if err := config.GetSigningKeyPolicy().Validate(k.PrivateKey); err != nil {
+			return nil, errors.WithStack(err)
+		} else if err := jwt.ValidateSigningKey(k.Algorithm, k.PrivateKey); err != nil {
+			return nil, errors.Wrapf(err, "Key %s can not be used", k.KeyID)
+		}
+	}
+
+	// Unless configured, tokens are signed with the algorithm of the active key or, if no key is active, of the newest key.
+	if alg == "" {
+		alg = ring.Keys[len(ring.Keys)-1].Algorithm
+		for _, k := range ring.Keys {
+			if k.Active {
+				alg = k.Algorithm
+				break
+			}
+		}
+	}
+
+	if _, err := ring.ActiveKey(alg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &jwt.KeyRingJWTStrategy{KeyRing: ring, Algorithm: alg}, nil
+}
+
+func newJWTStrategy(config *Config, key crypto.Signer) (jwt.JWTStrategy, error) {
+	if err := config.GetSigningKeyPolicy().Validate(key); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	alg, err := jwt.SigningAlgorithmForKey(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch alg {
+	case "RS256":
+		return &jwt.RS256JWTStrategy{PrivateKey: key.(*rsa.PrivateKey)}, nil
+	case "ES256":
+		return &jwt.ES256JWTStrategy{PrivateKey: key.(*ecdsa.PrivateKey)}, nil
+	}
+
+	// There are no dedicated strategies for ES384 and ES512, so the key is used as the only key of a key ring.
+	kid, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ring := &jwt.KeyRing{Keys: []jwt.Key{{KeyID: base64.RawURLEncoding.EncodeToString(kid), Algorithm: alg, Active: true, PrivateKey: key}}}
+	return &jwt.KeyRingJWTStrategy{KeyRing: ring, Algorithm: alg}, nil
+}
+
+// mustSatisfySigningKeyPolicy panics if the key does not satisfy the policy. It is used by the constructors which can
+// not return an error, as a weak signing key is a configuration error which must not go unnoticed.
+func mustSatisfySigningKeyPolicy(policy jwt.KeyPolicy, key interface{}) {
+	if err := policy.Validate(key); err != nil {
+		panic(fmt.Sprintf("compose: the signing key does not satisfy the signing key policy: %s", err))
+	}
+}
+
+func strategyClock(strategy *oauth2.HMACSHAStrategy) fosite.Clock {
+	if strategy == nil {
+		return nil
+	}
+	return strategy.Clock
+}
+
+func strategyClockSkew(strategy *oauth2.HMACSHAStrategy) time.Duration {
+	if strategy == nil {
+		return 0
 	}
+	return strategy.ClockSkew
 }
@@ -22,10 +22,17 @@
 package compose
 
 import (
+	"crypto"
+	"html/template"
 	"net/url"
 	"time"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/handler/rfc7523"
+	"github.com/ory/fosite/token/hmac"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type Config struct {
@@ -45,44 +52,210 @@ type Config struct {
 	// IDTokenIssuer sets the default issuer of the ID Token.
 	IDTokenIssuer string
 
+	// AccessTokenScopeClaimMappers maps audiences to functions transforming the granted scopes into additional claims
+	// of JWT access tokens for that audience, for example "roles". Defaults to nil, which adds no claims.
+	AccessTokenScopeClaimMappers map[string]oauth2.ScopeClaimMapper
+
+	// RefreshTokenFormat selects whether the strategies returned by NewOAuth2JWTStrategyWithKey and
+	// NewOAuth2JWTStrategyWithKeyRing issue opaque or JWT refresh tokens. Defaults to oauth2.RefreshTokenFormatOpaque.
+	RefreshTokenFormat oauth2.RefreshTokenFormat
+
+	// ClaimsEnrichmentHook, if set, adds claims derived at issuance, for example "tenant_id", to the ID Tokens and JWT
+	// access tokens of the strategies returned by NewOpenIDConnectStrategy, NewOAuth2JWTStrategyWithKey and their
+	// variants. It only adds claims which are not already set and can not set the fosite.ProtectedClaims.
+	ClaimsEnrichmentHook fosite.ClaimsEnrichmentHook
+
+	// IDTokenScopeClaim, if set, adds the granted scopes to ID Tokens as an array under this claim name. Defaults to "",
+	// which does not add the scopes as OpenID Connect does not define such a claim.
+	IDTokenScopeClaim string
+
+	// PairwiseSubjectIdentifierSalt, if set, enables pairwise subject identifiers for clients with the subject type
+	// "pairwise". The salt must be kept secret and must not change, as it would change the subject identifiers.
+	PairwiseSubjectIdentifierSalt []byte
+
+	// StrictIDTokenAudience, if set to true, ensures that the audience of ID Tokens contains exactly the client ID and
+	// rejects sessions setting a different audience or authorized party. Defaults to false.
+	StrictIDTokenAudience bool
+
+	// EnforceNonceReplayProtection, if set to true, allows the nonce of an OpenID Connect authorization request to mint
+	// only one ID Token at the token endpoint. The storage must implement openid.NonceReplayStorage.
+	EnforceNonceReplayProtection bool
+
+	// NonceReplayWindow sets how long a nonce which minted an ID Token is remembered by the
+	// openid.NonceReplayStorage. Defaults to one hour.
+	NonceReplayWindow time.Duration
+
+	// AllowMissingNonce, if set to true, accepts OpenID Connect implicit and hybrid authorization requests without a
+	// nonce. Defaults to false, which rejects them with invalid_request as required by OpenID Connect Core 1.0. Only
+	// enable it for legacy clients, the nonce is what binds ID Tokens returned in the front channel to the session.
+	AllowMissingNonce bool
+
 	// HashCost sets the cost of the password hashing cost. Defaults to 12.
 	HashCost int
 
+	// ClientSecretsHasher is the hasher used to compare OAuth 2.0 Client secrets, for example an argon2id implementation.
+	// Defaults to fosite.BCrypt using HashCost as the work factor.
+	ClientSecretsHasher fosite.Hasher
+
 	// DisableRefreshTokenValidation sets the introspection endpoint to disable refresh token validation.
 	DisableRefreshTokenValidation bool
 
-	// SendDebugMessagesToClients if set to true, includes error debug messages in response payloads. Be aware that sensitive
+	// SendDebugMessagesToClients if set to true, includes error hints and debug messages in response payloads. Be aware that sensitive
 	// data may be exposed, depending on your implementation of Fosite. Such sensitive data might include database error
 	// codes or other information. Proceed with caution!
 	SendDebugMessagesToClients bool
 
+	// BearerRealm is the realm sent in the WWW-Authenticate challenge of bearer token errors. If empty, the realm is
+	// omitted.
+	BearerRealm string
+
+	// BearerTokenLocations are the parts of a request the bearer token is taken from by ValidateToken, in order of
+	// precedence. Omit fosite.BearerTokenLocationQuery to reject tokens in the URI. Defaults to
+	// fosite.DefaultBearerTokenLocations.
+	BearerTokenLocations []fosite.BearerTokenLocation
+
+	// BearerTokenHeader is the name of the request header used by fosite.BearerTokenLocationCustomHeader.
+	BearerTokenHeader string
+
+	// ResourceServerAudience, if set, is the audience tokens validated by ValidateToken must have been granted.
+	ResourceServerAudience string
+
+	// ErrorHook is called with the complete error, including hint and debug message, before an error response is written.
+	// Use it to log errors server-side.
+	ErrorHook fosite.ErrorHook
+
+	// AuthorizeResponseHooks are called after the authorize endpoint handlers populated the authorization response and
+	// may add custom parameters to it, for example "session_state". Parameters set by the handlers can not be changed.
+	AuthorizeResponseHooks []fosite.AuthorizeResponseHook
+
+	// EnableSessionManagement, if set to true, adds the OpenID Connect Session Management "session_state" parameter to
+	// authorization responses of OpenID Connect requests. The HTTP request must be attached to the context passed to
+	// NewAuthorizeResponse using fosite.WithHTTPRequest.
+	EnableSessionManagement bool
+
+	// BrowserStateCookieName is the name of the cookie holding the OP browser state used to compute the session_state.
+	// Defaults to openid.DefaultBrowserStateCookieName.
+	BrowserStateCookieName string
+
+	// SessionStateOrigin returns the origin of the relying party used to compute the session_state. Defaults to
+	// openid.RedirectURIOrigin.
+	SessionStateOrigin func(ar fosite.AuthorizeRequester) string
+
+	// ErrorWriter shapes the body of JSON error responses, for example to wrap errors in a custom envelope. Defaults
+	// to fosite.DefaultErrorWriter.
+	ErrorWriter fosite.ErrorWriter
+
+	// HideUnsupportedGrantTypes if set to true, returns error invalid_grant instead of unauthorized_client and
+	// unsupported_grant_type at the token endpoint. This prevents clients from learning which grant types are supported.
+	HideUnsupportedGrantTypes bool
+
 	// ScopeStrategy sets the scope strategy that should be supported, for example fosite.WildcardScopeStrategy.
 	ScopeStrategy fosite.ScopeStrategy
 
 	// AudienceMatchingStrategy sets the audience matching strategy that should be supported, defaults to fosite.DefaultsAudienceMatchingStrategy.
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 
+	// RedirectURIMatchingStrategy sets how redirect URIs of authorization requests are compared with the client's registered
+	// redirect URIs, for example fosite.LoopbackRedirectURIMatchingStrategy or
+	// fosite.LoopbackPortRangeRedirectURIMatchingStrategy for native apps. Defaults to
+	// fosite.ExactRedirectURIMatchingStrategy.
+	RedirectURIMatchingStrategy fosite.RedirectURIMatchingStrategy
+
 	// EnforcePKCE, if set to true, requires clients to perform authorize code flows with PKCE. Defaults to false.
 	EnforcePKCE bool
 
-	// EnforcePKCEForPublicClients requires only public clients to use PKCE with the authorize code flow. Defaults to false.
+	// EnforcePKCEForPublicClients requires public clients, see fosite.IsPublicClient, to use PKCE with the authorize
+	// code flow.
+	//
+	// Deprecated: public clients are required to use PKCE unless DisablePKCEForPublicClients is set.
 	EnforcePKCEForPublicClients bool
 
+	// DisablePKCEForPublicClients, if set to true, allows public clients, see fosite.IsPublicClient, to perform the
+	// authorize code flow without PKCE. Defaults to false, which requires PKCE for public clients as recommended by
+	// https://tools.ietf.org/html/rfc8252#section-8.1. It has no effect if EnforcePKCE is set.
+	DisablePKCEForPublicClients bool
+
 	// EnablePKCEPlainChallengeMethod sets whether or not to allow the plain challenge method (S256 should be used whenever possible, plain is really discouraged). Defaults to false.
 	EnablePKCEPlainChallengeMethod bool
 
 	// AllowedPromptValues sets which OpenID Connect prompt values the server supports. Defaults to []string{"login", "none", "consent", "select_account"}.
 	AllowedPromptValues []string
 
+	// PromptNoneConsentPolicy sets how OpenID Connect requests with "prompt=none" are handled if the end-user consented to
+	// only some of the requested scopes. Defaults to openid.PromptNoneConsentRequired.
+	PromptNoneConsentPolicy openid.PromptNoneConsentPolicy
+
 	// TokenURL is the the URL of the Authorization Server's Token Endpoint. If the authorization server is intended
 	// to be compatible with the private_key_jwt client authentication method (see http://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth),
 	// this value MUST be set.
 	TokenURL string
 
+	// TLSClientCertificateHeader is the name of the HTTP header containing the URL encoded PEM client certificate when
+	// TLS is terminated in front of the authorization server. Used by the tls_client_auth and self_signed_tls_client_auth
+	// client authentication methods. If not set, the certificate is taken from the TLS connection. The header is only
+	// read from requests sent by one of the TrustedProxies, which must verify the certificate chain.
+	TLSClientCertificateHeader string
+
+	// TrustedProxies lists the IP addresses and CIDR ranges of the reverse proxies in front of the authorization
+	// server. Headers set by proxies, such as TLSClientCertificateHeader and X-Forwarded-Proto, are ignored unless the
+	// request was sent by a trusted proxy. Defaults to nil, which trusts no proxy.
+	TrustedProxies []string
+
 	// JWKSFetcherStrategy is responsible for fetching JSON Web Keys from remote URLs. This is required when the private_key_jwt
 	// client authentication method is used. Defaults to fosite.DefaultJWKSFetcherStrategy.
 	JWKSFetcher fosite.JWKSFetcherStrategy
 
+	// SectorIdentifierValidator validates the sector_identifier_uri of clients using pairwise subject identifiers.
+	// Defaults to fosite.DefaultSectorIdentifierValidator using the HTTP client of the JWKSFetcher if
+	// PairwiseSubjectIdentifierSalt is set, and to nil otherwise.
+	SectorIdentifierValidator fosite.SectorIdentifierValidator
+
+	// IntrospectionAudiencePolicy decides whether the caller of the introspection endpoint may learn about the
+	// introspected token, for example fosite.AudienceRestrictedIntrospectionPolicy. Defaults to nil, which allows every
+	// authenticated caller to introspect every token.
+	IntrospectionAudiencePolicy fosite.IntrospectionAudiencePolicy
+
+	// RateLimiter, if set, rejects requests to the token, revocation and introspection endpoints with
+	// fosite.ErrSlowDown. Defaults to nil, which disables rate limiting.
+	RateLimiter fosite.RateLimiter
+
+	// TokenTypeHintMetricsHook, if set, is called with the outcome of the token_type_hint of introspection and
+	// revocation requests, which allows measuring how often the hint leads to the right token store on the first try.
+	TokenTypeHintMetricsHook fosite.TokenTypeHintMetricsHook
+
+	// DPoPNonceStrategy, if set, requires DPoP proofs to contain a server-provided nonce, for example
+	// fosite.HMACDPoPNonceStrategy. Defaults to nil, which does not require nonces.
+	DPoPNonceStrategy fosite.DPoPNonceStrategy
+
+	// DPoPProofLifespan sets how long DPoP proofs are accepted after they were issued. Defaults to one minute.
+	DPoPProofLifespan time.Duration
+
+	// EnforceDPoPBoundRefreshTokens, if set, binds the refresh tokens of confidential clients to the DPoP key they
+	// were issued for, like those of public clients. Defaults to false, which lets confidential clients rebind their
+	// tokens to a new key when refreshing.
+	EnforceDPoPBoundRefreshTokens bool
+
+	// StorageRetryPolicy retries idempotent storage reads, namely client and token session lookups, which failed
+	// because of a transient error. Defaults to nil, which disables retries.
+	StorageRetryPolicy *fosite.RetryPolicy
+
+	// IDTokenSigningAlgorithm is the algorithm ID tokens are signed with by NewOpenIDConnectStrategyWithKeyRing, one
+	// of jwt.SigningAlgorithms. Defaults to the algorithm of the active key.
+	IDTokenSigningAlgorithm string
+
+	// AccessTokenSigningAlgorithm is the algorithm JWT access tokens are signed with by
+	// NewOAuth2JWTStrategyWithKeyRing, one of jwt.SigningAlgorithms. Defaults to the algorithm of the active key.
+	AccessTokenSigningAlgorithm string
+
+	// HMACKeys is an ordered set of secrets, from oldest to newest, used by NewOAuth2HMACStrategy. Tokens are signed
+	// with the newest key and verified with the key they reference, which allows rotating the secret without
+	// invalidating outstanding tokens. Tokens signed with the global secret remain valid.
+	HMACKeys []hmac.Key
+
+	// AccessTokenPrefix is prepended to access tokens issued by NewOAuth2HMACStrategy, for example "ory_at_", so that
+	// API gateways can tell them apart from other bearer tokens. It is signed with the token. Defaults to no prefix.
+	AccessTokenPrefix string
+
 	// TokenEntropy indicates the entropy of the random string, used as the "message" part of the HMAC token.
 	// Defaults to 32.
 	TokenEntropy int
@@ -93,8 +266,162 @@ type Config struct {
 	// RefreshTokenScopes defines which OAuth scopes will be given refresh tokens during the authorization code grant exchange. This defaults to "offline" and "offline_access". When set to an empty array, all exchanges will be given refresh tokens.
 	RefreshTokenScopes []string
 
+	// RequireOfflineAccessForOpenID, if set, issues refresh tokens for OpenID Connect requests by the authorization code
+	// grant only if the "offline_access" scope was granted. RefreshTokenScopes then only applies to other requests.
+	// Clients can opt into this behavior individually by implementing fosite.OfflineAccessClient.
+	RequireOfflineAccessForOpenID bool
+
+	// RequireConsentForOfflineAccess, if set, additionally requires "prompt=consent" in the authorization request for
+	// a refresh token to be issued when the "offline_access" scope is required.
+	RequireConsentForOfflineAccess bool
+
 	// MinParameterEntropy controls the minimum size of state and nonce parameters. Defaults to fosite.MinParameterEntropy.
 	MinParameterEntropy int
+
+	// MinStateEntropy controls the minimum size of the state parameter. Defaults to MinParameterEntropy.
+	MinStateEntropy int
+
+	// MinNonceEntropy controls the minimum size of the OpenID Connect nonce parameter. Defaults to MinParameterEntropy.
+	MinNonceEntropy int
+
+	// StateReplayStore, if set, rejects authorization requests that reuse a state the same client already used within
+	// StateReplayWindow. Defaults to nil, which leaves checking the state to the client.
+	StateReplayStore fosite.StateReplayStore
+
+	// StateReplayWindow sets how long a used state is remembered by the StateReplayStore. Defaults to one hour.
+	StateReplayWindow time.Duration
+
+	// SigningKeyPolicy defines which keys tokens and responses may be signed with. The strategy constructors accepting a
+	// crypto.Signer return an error for other keys, while ComposeAllEnabled, Compose (for JARMSigningKey and
+	// IntrospectionSigningKey) and the other strategy constructors panic. Defaults to jwt.DefaultKeyPolicy.
+	SigningKeyPolicy *jwt.KeyPolicy
+
+	// JARMSigningKey signs authorization responses for the JWT response modes (JARM), for example "query.jwt". It must
+	// be an *rsa.PrivateKey (RS256) or an *ecdsa.PrivateKey on curve P-256 (ES256), P-384 (ES384) or P-521 (ES512).
+	// JWT response modes fail if it is not set.
+	JARMSigningKey crypto.Signer
+
+	// JARMSigningKeyID sets the "kid" header of JWT secured authorization responses. It must match the key ID of
+	// JARMSigningKey in the published JWKS. Defaults to the JWK thumbprint of the key.
+	JARMSigningKeyID string
+
+	// JARMIssuer sets the "iss" claim of JWT secured authorization responses, usually the authorization server's issuer URL.
+	JARMIssuer string
+
+	// JARMLifespan sets how long JWT secured authorization responses are valid. Defaults to ten minutes.
+	JARMLifespan time.Duration
+
+	// IncludeStateHash, if set to true, adds the s_hash claim, the hash of the state as defined by FAPI, to the ID
+	// Tokens returned from the authorization endpoint and to JWT secured authorization responses of requests with a
+	// state. Defaults to false.
+	IncludeStateHash bool
+
+	// IntrospectionSigningKey signs introspection responses requested as JWT by resource servers. It must be an
+	// *rsa.PrivateKey (RS256) or an *ecdsa.PrivateKey on curve P-256 (ES256), P-384 (ES384) or P-521 (ES512). Set it to use a different key than the one signing ID
+	// tokens; ComposeAllEnabled defaults to the ID token key.
+	IntrospectionSigningKey crypto.Signer
+
+	// IntrospectionSigningKeyID sets the "kid" header of signed introspection responses. It must match the key ID of
+	// IntrospectionSigningKey in the published JWKS. Defaults to the JWK thumbprint of the key.
+	IntrospectionSigningKeyID string
+
+	// IntrospectionIssuer sets the "iss" claim of signed introspection responses, usually the authorization server's issuer URL.
+	IntrospectionIssuer string
+
+	// IssuerFromRequest, if set, resolves the issuer from the HTTP request, for example from its host when several
+	// issuers are served from one binary. It sets the "iss" claim of ID Tokens, JWT access tokens, signed UserInfo and
+	// introspection responses and JWT secured authorization responses, falling back to IDTokenIssuer,
+	// IntrospectionIssuer and JARMIssuer if it returns an empty string. Client and JWT bearer assertions may use it as
+	// their audience. NewAuthorizeRequest and NewAccessRequest store the resolved issuer on the request, which
+	// NewAuthorizeResponse and NewAccessResponse use when issuing tokens. Access tokens created with
+	// NewOAuth2JWTStrategy must be configured with oauth2.DefaultJWTStrategy.WithIssuerFromRequest.
+	IssuerFromRequest fosite.IssuerFromRequest
+
+	// IntrospectionCacheTTL, if set, allows resource servers to cache the introspection responses of active tokens for
+	// this duration, bounded by the remaining lifetime of the token. Defaults to zero, which forbids caching.
+	IntrospectionCacheTTL time.Duration
+
+	// RequestURIMaxBodySize sets the maximum size in bytes of request objects fetched from a request_uri. Defaults to
+	// fosite.DefaultRequestURIMaxBodySize.
+	RequestURIMaxBodySize int64
+
+	// MaxRequestBodySize sets the maximum size in bytes of the bodies of requests to the token, revocation and
+	// introspection endpoints. Defaults to fosite.DefaultMaxRequestBodySize.
+	MaxRequestBodySize int64
+
+	// RequestURIMaxRedirects sets how many redirects are followed when fetching request objects from a request_uri.
+	// Defaults to fosite.DefaultRequestURIMaxRedirects, a negative value disables following redirects. Redirects are
+	// only followed to request URIs registered by the client.
+	RequestURIMaxRedirects int
+
+	// IDGenerator generates the IDs of requests, the "jti" claim of ID Tokens and Logout Tokens, and the "jti" claim of
+	// JWT access tokens created with NewOAuth2JWTStrategyWithKey or NewOAuth2JWTStrategyWithKeyRing. Use
+	// DefaultJWTStrategy.WithIDGenerator for JWT access token strategies created otherwise. The identifiers must be
+	// unique. Defaults to random UUIDs.
+	IDGenerator fosite.IDGenerator
+
+	// KnownScopes, if set, is the registry of all scopes of this authorization server. Requests for scopes not matching
+	// any of them are rejected with invalid_scope before the client's scopes are checked. Defaults to nil, which
+	// disables the registry.
+	KnownScopes []string
+
+	// DefaultScopes are requested on behalf of clients which omit the scope parameter, unless the client has its own
+	// default scopes, see fosite.ClientWithDefaultScopes. Defaults to nil.
+	DefaultScopes []string
+
+	// EnabledResponseTypes, if set, lists the response types accepted at the authorize endpoint regardless of the
+	// response types registered for clients, for example []string{"code"} to disable the implicit and hybrid flows.
+	// Defaults to nil, which accepts all response types of the composed handlers.
+	EnabledResponseTypes []string
+
+	// ClientSecretRotationHook, if set, is called when a client authenticates with its current secret while it still
+	// has a rotated secret, see fosite.ClientWithRotatedSecret.
+	ClientSecretRotationHook fosite.ClientSecretRotationHook
+
+	// TokenEndpointAuthSigningAlgorithms lists the algorithms client assertions may be signed with, in addition to the
+	// algorithm each client enforces. Defaults to fosite.DefaultTokenEndpointAuthSigningAlgorithms.
+	TokenEndpointAuthSigningAlgorithms []string
+
+	// JWTBearerMaxAssertionLifespan sets how far in the future assertions of the JWT bearer authorization grant may
+	// expire. Defaults to rfc7523.DefaultMaxAssertionLifespan.
+	JWTBearerMaxAssertionLifespan time.Duration
+
+	// JWTBearerSubjectMapper, if set, maps the subject of assertions of the JWT bearer authorization grant to the
+	// subject of the issued access tokens.
+	JWTBearerSubjectMapper rfc7523.SubjectMapper
+
+	// LoginThrottle, if set, limits failed logins of the resource owner password credentials grant, for example by
+	// locking out usernames after too many failures.
+	LoginThrottle oauth2.LoginThrottle
+
+	// StatelessAccessTokens, if set, does not persist access tokens. Use it together with the JWT access token strategy
+	// and OAuth2StatelessJWTIntrospectionFactory, which validates access tokens without looking them up. Authorization
+	// codes, refresh tokens and OpenID Connect requests are still persisted, and revoking access tokens requires
+	// TokenDenylist.
+	StatelessAccessTokens bool
+
+	// TokenDenylist, if set, records the tokens and grants revoked at the revocation endpoint until their access tokens
+	// expire, and is checked by OAuth2StatelessJWTIntrospectionFactory. storage.MemoryStore implements it.
+	TokenDenylist oauth2.TokenDenylist
+
+	// Clock returns the current time and is used to compute and validate token lifetimes. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// ClockSkew sets how much time the clocks of the authorization server and other parties may differ. Tokens remain
+	// valid for this duration after they expired and JWT time claims may be off by this duration. Defaults to zero.
+	ClockSkew time.Duration
+
+	// FormPostHTMLTemplate, if set, renders the authorization response for response_mode=form_post instead of
+	// fosite.FormPostDefaultTemplate, see fosite.Fosite.FormPostHTMLTemplate.
+	FormPostHTMLTemplate *template.Template
+
+	// AuthorizeRequestLifespan caps the lifetime of paused authorization requests, see
+	// fosite.Fosite.AuthorizeRequestLifespan. Defaults to fosite.DefaultAuthorizeRequestLifespan.
+	AuthorizeRequestLifespan time.Duration
+
+	// EncodedAuthorizeRequestLifespan sets how long an authorization request encoded with
+	// fosite.Fosite.EncodeAuthorizeRequest can be decoded. Defaults to AuthorizeRequestLifespan.
+	EncodedAuthorizeRequestLifespan time.Duration
 }
 
 // GetScopeStrategy returns the scope strategy to be used. Defaults to glob scope strategy.
@@ -113,6 +440,36 @@ func (c *Config) GetAudienceStrategy() fosite.AudienceMatchingStrategy {
 	return c.AudienceMatchingStrategy
 }
 
+// GetRedirectURIMatchingStrategy returns the redirect URI matching strategy to be used. Defaults to exact matching.
+func (c *Config) GetRedirectURIMatchingStrategy() fosite.RedirectURIMatchingStrategy {
+	if c.RedirectURIMatchingStrategy == nil {
+		c.RedirectURIMatchingStrategy = fosite.ExactRedirectURIMatchingStrategy
+	}
+	return c.RedirectURIMatchingStrategy
+}
+
+// GetAuthorizeResponseHooks returns the authorize response hooks, including the session_state hook if session
+// management is enabled.
+func (c *Config) GetAuthorizeResponseHooks() []fosite.AuthorizeResponseHook {
+	hooks := c.AuthorizeResponseHooks
+	if c.EnableSessionManagement {
+		strategy := &openid.SessionStateStrategy{
+			BrowserStateCookieName: c.BrowserStateCookieName,
+			Origin:                 c.SessionStateOrigin,
+		}
+		hooks = append(append([]fosite.AuthorizeResponseHook{}, hooks...), strategy.AuthorizeResponseHook)
+	}
+	return hooks
+}
+
+// GetSigningKeyPolicy returns the policy signing keys must satisfy. Defaults to jwt.DefaultKeyPolicy.
+func (c *Config) GetSigningKeyPolicy() jwt.KeyPolicy {
+	if c.SigningKeyPolicy == nil {
+		return jwt.DefaultKeyPolicy
+	}
+	return *c.SigningKeyPolicy
+}
+
 // GetAuthorizeCodeLifespan returns how long an authorize code should be valid. Defaults to one fifteen minutes.
 func (c *Config) GetAuthorizeCodeLifespan() time.Duration {
 	if c.AuthorizeCodeLifespan == 0 {
@@ -154,10 +511,19 @@ func (c *Config) GetHashCost() int {
 	return c.HashCost
 }
 
+// GetClientSecretsHasher returns the hasher used to compare OAuth 2.0 Client secrets. Defaults to fosite.BCrypt
+// using the configured hash cost.
+func (c *Config) GetClientSecretsHasher() fosite.Hasher {
+	if c.ClientSecretsHasher == nil {
+		c.ClientSecretsHasher = &fosite.BCrypt{WorkFactor: c.GetHashCost()}
+	}
+	return c.ClientSecretsHasher
+}
+
 // GetJWKSFetcherStrategy returns the JWKSFetcherStrategy.
 func (c *Config) GetJWKSFetcherStrategy() fosite.JWKSFetcherStrategy {
 	if c.JWKSFetcher == nil {
-		c.JWKSFetcher = fosite.NewDefaultJWKSFetcherStrategy()
+		c.JWKSFetcher = fosite.NewDefaultJWKSFetcherStrategy(fosite.JWKSFetcherWithClock(c.Clock))
 	}
 	return c.JWKSFetcher
 }
@@ -194,3 +560,43 @@ func (c *Config) GetMinParameterEntropy() int {
 		return c.MinParameterEntropy
 	}
 }
+
+// GetSubjectIdentifierAlgorithms returns the subject identifier algorithms for ID Tokens. The pairwise algorithm is
+// only available if PairwiseSubjectIdentifierSalt is set.
+func (c *Config) GetSubjectIdentifierAlgorithms() map[string]openid.SubjectIdentifierAlgorithm {
+	algorithms := map[string]openid.SubjectIdentifierAlgorithm{
+		fosite.PublicSubjectType: &openid.PublicSubjectIdentifierAlgorithm{},
+	}
+	if len(c.PairwiseSubjectIdentifierSalt) > 0 {
+		algorithms[fosite.PairwiseSubjectType] = &openid.PairwiseSubjectIdentifierAlgorithm{Salt: c.PairwiseSubjectIdentifierSalt}
+	}
+	return algorithms
+}
+
+// GetSectorIdentifierValidator returns the SectorIdentifierValidator.
+func (c *Config) GetSectorIdentifierValidator() fosite.SectorIdentifierValidator {
+	if c.SectorIdentifierValidator == nil && len(c.PairwiseSubjectIdentifierSalt) > 0 {
+		opts := []func(*fosite.DefaultSectorIdentifierValidator){fosite.SectorIdentifierValidatorWithClock(c.Clock)}
+		if fetcher, ok := c.GetJWKSFetcherStrategy().(*fosite.DefaultJWKSFetcherStrategy); ok {
+			opts = append(opts, fosite.SectorIdentifierValidatorWithHTTPClient(fetcher.HTTPClient()))
+		}
+		c.SectorIdentifierValidator = fosite.NewDefaultSectorIdentifierValidator(opts...)
+	}
+	return c.SectorIdentifierValidator
+}
+
+// GetMinStateEntropy returns MinStateEntropy if set. Defaults to GetMinParameterEntropy().
+func (c *Config) GetMinStateEntropy() int {
+	if c.MinStateEntropy == 0 {
+		return c.GetMinParameterEntropy()
+	}
+	return c.MinStateEntropy
+}
+
+// GetMinNonceEntropy returns MinNonceEntropy if set. Defaults to GetMinParameterEntropy().
+func (c *Config) GetMinNonceEntropy() int {
+	if c.MinNonceEntropy == 0 {
+		return c.GetMinParameterEntropy()
+	}
+	return c.MinNonceEntropy
+}
@@ -21,8 +21,39 @@
 
 package fosite
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 func NewContext() context.Context {
 	return context.Background()
 }
+
+type httpRequestContextKey struct{}
+
+// WithHTTPRequest returns a copy of the context which carries the HTTP request. Extensions which need access to the
+// request, for example to read cookies when creating the authorization response, retrieve it with HTTPRequestFromContext.
+func WithHTTPRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, httpRequestContextKey{}, r)
+}
+
+// HTTPRequestFromContext returns the HTTP request attached to the context with WithHTTPRequest, if any.
+func HTTPRequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(httpRequestContextKey{}).(*http.Request)
+	return r, ok && r != nil
+}
+
+type issuerContextKey struct{}
+
+// WithIssuer returns a copy of the context which carries the issuer resolved for the current request. ResolveIssuer
+// prefers it over resolving the issuer again, so that tokens issued after the HTTP request is gone use the same issuer.
+func WithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, issuerContextKey{}, issuer)
+}
+
+// IssuerFromContext returns the issuer attached to the context with WithIssuer, if any.
+func IssuerFromContext(ctx context.Context) (string, bool) {
+	issuer, ok := ctx.Value(issuerContextKey{}).(string)
+	return issuer, ok && issuer != ""
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// DPoPHeader is the HTTP header carrying the DPoP proof, see RFC 9449 Section 4.1.
+	DPoPHeader = "DPoP"
+
+	// DPoPNonceHeader is the HTTP header carrying a server-provided DPoP nonce, see RFC 9449 Section 8.
+	DPoPNonceHeader = "DPoP-Nonce"
+
+	// DPoPAccessTokenType is the token type of access tokens bound to a DPoP key.
+	DPoPAccessTokenType = "DPoP"
+
+	dpopProofType = "dpop+jwt"
+)
+
+// DPoPBoundSession represents a session which binds the issued tokens to the public key of the DPoP proof presented
+// at the token endpoint, see RFC 9449 Section 6.
+type DPoPBoundSession interface {
+	// SetDPoPKeyThumbprint sets the base64url encoded SHA-256 JWK thumbprint of the DPoP key.
+	SetDPoPKeyThumbprint(thumbprint string)
+
+	// GetDPoPKeyThumbprint returns the base64url encoded SHA-256 JWK thumbprint of the DPoP key, or an empty string if
+	// the tokens are not bound to a DPoP key.
+	GetDPoPKeyThumbprint() string
+}
+
+// DPoPNonceStrategy issues and validates server-provided DPoP nonces, see RFC 9449 Section 8.
+type DPoPNonceStrategy interface {
+	// NewDPoPNonce returns the nonce clients must include in their next DPoP proof.
+	NewDPoPNonce(ctx context.Context) (string, error)
+
+	// ValidateDPoPNonce returns an error if the nonce was not issued by NewDPoPNonce or is no longer current.
+	ValidateDPoPNonce(ctx context.Context, nonce string) error
+}
+
+// HMACDPoPNonceStrategy issues stateless DPoP nonces which rotate every Lifespan. A nonce is accepted during the
+// lifespan it was issued in and the one after, so that clients are not challenged again right after a rotation.
+type HMACDPoPNonceStrategy struct {
+	// Secret is used to authenticate the nonces and must be at least 32 bytes long.
+	Secret []byte
+
+	// Lifespan sets how often the nonce rotates. Defaults to five minutes.
+	Lifespan time.Duration
+
+	Clock Clock
+}
+
+func (s *HMACDPoPNonceStrategy) lifespan() time.Duration {
+	if s.Lifespan <= 0 {
+		return 5 * time.Minute
+	}
+	return s.Lifespan
+}
+
+func (s *HMACDPoPNonceStrategy) nonce(window int64) (string, error) {
+	if len(s.Secret) < 32 {
+		return "", errors.New("DPoP nonce secret must be at least 32 bytes long")
+	}
+
+	raw := make([]byte, 8, 8+sha256.Size)
+	binary.BigEndian.PutUint64(raw, uint64(window))
+
+	mac := hmac.New(sha256.New, s.Secret)
+	_, _ = mac.Write(raw)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(raw)), nil
+}
+
+func (s *HMACDPoPNonceStrategy) window() int64 {
+	return s.Clock.Now().UnixNano() / int64(s.lifespan())
+}
+
+// NewDPoPNonce returns the nonce of the current lifespan.
+func (s *HMACDPoPNonceStrategy) NewDPoPNonce(ctx context.Context) (string, error) {
+	return s.nonce(s.window())
+}
+
+// ValidateDPoPNonce accepts the nonces of the current and the previous lifespan.
+func (s *HMACDPoPNonceStrategy) ValidateDPoPNonce(ctx context.Context, nonce string) error {
+	current := s.window()
+	for _, window := range []int64{current, current - 1} {
+		expected, err := s.nonce(window)
+		if err != nil {
+			return err
+		} else if hmac.Equal([]byte(expected), []byte(nonce)) {
+			return nil
+		}
+	}
+	return errors.New("DPoP nonce is invalid or expired")
+}
+
+type dpopProofClaims struct {
+	JTI             string `json:"jti"`
+	HTTPMethod      string `json:"htm"`
+	HTTPURI         string `json:"htu"`
+	IssuedAt        int64  `json:"iat"`
+	AccessTokenHash string `json:"ath"`
+	Nonce           string `json:"nonce"`
+}
+
+// ValidateDPoPProof validates the DPoP proof of the request as defined in RFC 9449 Section 4.3 and returns the JWK
+// thumbprint of its public key. It returns an empty thumbprint and no error if the request has no DPoP proof.
+//
+// If accessToken is set, the proof must be bound to it using the "ath" claim, which is the case for requests to
+// resource servers. If a DPoPNonceStrategy is configured, the proof must contain a current nonce, otherwise
+// ErrUseDPoPNonce is returned along with a fresh nonce, which the error writers send in the DPoP-Nonce header.
+//
+// If the Store is set, the "jti" of the proof is remembered until the proof is no longer recent and proofs using a known
+// "jti" are rejected, so that a captured proof can not be replayed.
+//
+// X-Forwarded-Proto is only used to reconstruct the URI of the request if it was sent by one of the TrustedProxies.
+func (f *Fosite) ValidateDPoPProof(ctx context.Context, r *http.Request, accessToken string) (string, error) {
+	values := r.Header.Values(DPoPHeader)
+	if len(values) == 0 {
+		return "", nil
+	} else if len(values) > 1 {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The request must not contain more than one DPoP proof."))
+	}
+
+	proof, err := jose.ParseSigned(values[0])
+	if err != nil {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof is not a valid JSON Web Token.").WithCause(err).WithDebug(err.Error()))
+	} else if len(proof.Signatures) != 1 {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must have exactly one signature."))
+	}
+
+	header := proof.Signatures[0].Protected
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != dpopProofType {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof must have header 'typ' set to '%s'.", dpopProofType))
+	} else if header.JSONWebKey == nil || !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must contain a public key in header 'jwk'."))
+	} else if alg := header.Algorithm; alg == "" || alg == "none" || strings.HasPrefix(alg, "HS") {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof must be signed with an asymmetric algorithm but uses '%s'.", alg))
+	}
+
+	payload, err := proof.Verify(header.JSONWebKey)
+	if err != nil {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The signature of the DPoP proof is invalid.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	var claims dpopProofClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The claims of the DPoP proof could not be decoded.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	if claims.JTI == "" {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof must contain claim 'jti'."))
+	} else if claims.HTTPMethod != r.Method {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof was created for HTTP method '%s' but the request uses '%s'.", claims.HTTPMethod, r.Method))
+	} else if !f.matchDPoPURI(claims.HTTPURI, r) {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHintf("The DPoP proof was created for URI '%s' which does not match the request.", claims.HTTPURI))
+	} else if age := f.Clock.Now().Sub(issuedAt); age > f.GetDPoPProofLifespan() || age < -f.GetDPoPProofLifespan() {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof was not issued recently."))
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if claims.AccessTokenHash != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof is not bound to the access token presented."))
+		}
+	}
+
+	if f.DPoPNonceStrategy != nil {
+		if claims.Nonce == "" {
+			return "", f.useDPoPNonce(ctx, ErrUseDPoPNonce.WithHint("The DPoP proof must contain a server-provided nonce."))
+		} else if err := f.DPoPNonceStrategy.ValidateDPoPNonce(ctx, claims.Nonce); err != nil {
+			return "", f.useDPoPNonce(ctx, ErrUseDPoPNonce.WithHint("The nonce of the DPoP proof is invalid or expired.").WithCause(err).WithDebug(err.Error()))
+		}
+	}
+
+	sum, err := header.JSONWebKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", errors.WithStack(ErrInvalidDPoPProof.WithCause(err).WithDebug(err.Error()))
+	}
+	thumbprint := base64.RawURLEncoding.EncodeToString(sum)
+
+	if f.Store != nil {
+		// The proof is accepted until it is no longer recent, which is when it can be forgotten.
+		if err := f.Store.SetClientAssertionJWT(ctx, dpopJTIKey(thumbprint, claims.JTI), issuedAt.Add(f.GetDPoPProofLifespan())); errors.Is(err, ErrJTIKnown) {
+			return "", errors.WithStack(ErrInvalidDPoPProof.WithHint("The DPoP proof was already used."))
+		} else if err != nil {
+			return "", errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+		}
+	}
+
+	return thumbprint, nil
+}
+
+// dpopJTIKey namespaces the jti of a DPoP proof by the key which signed it, so that it does not collide with the jti of
+// proofs of other keys or of client assertions in the JTIStore.
+func dpopJTIKey(thumbprint, jti string) string {
+	return "dpop:" + thumbprint + ":" + jti
+}
+
+// useDPoPNonce attaches a fresh nonce to the use_dpop_nonce error, which the error writers send in the DPoP-Nonce
+// header.
+func (f *Fosite) useDPoPNonce(ctx context.Context, rfcerr *RFC6749Error) error {
+	nonce, err := f.DPoPNonceStrategy.NewDPoPNonce(ctx)
+	if err != nil {
+		return errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	rfcerr.dpopNonce = nonce
+	return errors.WithStack(rfcerr)
+}
+
+// SetDPoPNonceHeader sets a fresh nonce in the DPoP-Nonce header if a DPoPNonceStrategy is configured.
+func (f *Fosite) SetDPoPNonceHeader(ctx context.Context, rw http.ResponseWriter) error {
+	if f.DPoPNonceStrategy == nil {
+		return nil
+	}
+
+	nonce, err := f.DPoPNonceStrategy.NewDPoPNonce(ctx)
+	if err != nil {
+		return errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	rw.Header().Set(DPoPNonceHeader, nonce)
+	return nil
+}
+
+// ValidateDPoPBinding checks that the DPoP proof presented to a resource server was signed with the key the token
+// was bound to when it was issued, see RFC 9449 Section 7. The thumbprint is returned by ValidateDPoPProof and the
+// requester is typically obtained by introspecting the token. Tokens which are not bound to a DPoP key are accepted.
+func ValidateDPoPBinding(requester Requester, thumbprint string) error {
+	session, ok := requester.GetSession().(DPoPBoundSession)
+	if !ok || session.GetDPoPKeyThumbprint() == "" {
+		return nil
+	} else if thumbprint == "" {
+		return errors.WithStack(ErrRequestUnauthorized.WithHint("The token is bound to a DPoP key but no DPoP proof was presented."))
+	} else if thumbprint != session.GetDPoPKeyThumbprint() {
+		return errors.WithStack(ErrRequestUnauthorized.WithHint("The token is bound to a different DPoP key than the one of the DPoP proof."))
+	}
+
+	return nil
+}
+
+// matchDPoPURI compares the "htu" claim with the URI of the request, ignoring query and fragment.
+func (f *Fosite) matchDPoPURI(htu string, r *http.Request) bool {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" && f.isTrustedProxy(r) {
+		scheme = forwarded
+	}
+
+	if i := strings.IndexAny(htu, "?#"); i >= 0 {
+		htu = htu[:i]
+	}
+	return strings.EqualFold(htu, scheme+"://"+r.Host+r.URL.Path)
+}
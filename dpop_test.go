@@ -0,0 +1,309 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, typ string, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{EmbedJWK: true}).WithType(jose.ContentType(typ)))
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	object, err := signer.Sign(payload)
+	require.NoError(t, err)
+
+	proof, err := object.CompactSerialize()
+	require.NoError(t, err)
+	return proof
+}
+
+func TestValidateDPoPProof(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	thumbprint, err := (&jose.JSONWebKey{Key: &key.PublicKey}).Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+
+	nonces := &HMACDPoPNonceStrategy{Secret: []byte("some-super-secret-dpop-nonce-secret-32")}
+	nonce, err := nonces.NewDPoPNonce(context.Background())
+	require.NoError(t, err)
+
+	ath := sha256.Sum256([]byte("access-token"))
+	claims := func(modify func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"jti": "some-jti",
+			"htm": "POST",
+			"htu": "https://auth.example.org/token",
+			"iat": time.Now().Unix(),
+		}
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	for k, c := range []struct {
+		d           string
+		proof       string
+		accessToken string
+		nonces      DPoPNonceStrategy
+		expectErr   error
+	}{
+		{
+			d: "should pass without a DPoP proof",
+		},
+		{
+			d:     "should pass",
+			proof: newDPoPProof(t, key, "dpop+jwt", claims(nil)),
+		},
+		{
+			d:         "should fail because of the wrong type",
+			proof:     newDPoPProof(t, key, "JWT", claims(nil)),
+			expectErr: ErrInvalidDPoPProof,
+		},
+		{
+			d:         "should fail because of the wrong HTTP method",
+			proof:     newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["htm"] = "GET" })),
+			expectErr: ErrInvalidDPoPProof,
+		},
+		{
+			d:         "should fail because of the wrong URI",
+			proof:     newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["htu"] = "https://auth.example.org/introspect" })),
+			expectErr: ErrInvalidDPoPProof,
+		},
+		{
+			d:         "should fail because the proof is too old",
+			proof:     newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["iat"] = time.Now().Add(-time.Hour).Unix() })),
+			expectErr: ErrInvalidDPoPProof,
+		},
+		{
+			d:           "should fail because the proof is not bound to the access token",
+			proof:       newDPoPProof(t, key, "dpop+jwt", claims(nil)),
+			accessToken: "access-token",
+			expectErr:   ErrInvalidDPoPProof,
+		},
+		{
+			d:           "should pass because the proof is bound to the access token",
+			proof:       newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["ath"] = base64.RawURLEncoding.EncodeToString(ath[:]) })),
+			accessToken: "access-token",
+		},
+		{
+			d:         "should fail because the nonce is missing",
+			proof:     newDPoPProof(t, key, "dpop+jwt", claims(nil)),
+			nonces:    nonces,
+			expectErr: ErrUseDPoPNonce,
+		},
+		{
+			d:         "should fail because the nonce is invalid",
+			proof:     newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["nonce"] = "some-nonce" })),
+			nonces:    nonces,
+			expectErr: ErrUseDPoPNonce,
+		},
+		{
+			d:      "should pass with the nonce",
+			proof:  newDPoPProof(t, key, "dpop+jwt", claims(func(c map[string]interface{}) { c["nonce"] = nonce })),
+			nonces: nonces,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "https://auth.example.org/token?foo=bar", nil)
+			if c.proof != "" {
+				r.Header.Set(DPoPHeader, c.proof)
+			}
+
+			f := &Fosite{DPoPNonceStrategy: c.nonces}
+			actual, err := f.ValidateDPoPProof(context.Background(), r, c.accessToken)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			if c.proof != "" {
+				assert.Equal(t, base64.RawURLEncoding.EncodeToString(thumbprint), actual)
+			} else {
+				assert.Empty(t, actual)
+			}
+		})
+	}
+}
+
+func TestHMACDPoPNonceStrategy(t *testing.T) {
+	now := time.Now()
+	s := &HMACDPoPNonceStrategy{
+		Secret:   []byte("some-super-secret-dpop-nonce-secret-32"),
+		Lifespan: time.Minute,
+		Clock:    func() time.Time { return now },
+	}
+
+	nonce, err := s.NewDPoPNonce(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, s.ValidateDPoPNonce(context.Background(), nonce))
+
+	now = now.Add(time.Minute)
+	rotated, err := s.NewDPoPNonce(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, nonce, rotated)
+	assert.NoError(t, s.ValidateDPoPNonce(context.Background(), nonce), "the previous nonce is accepted after the rotation")
+
+	now = now.Add(time.Minute)
+	assert.Error(t, s.ValidateDPoPNonce(context.Background(), nonce))
+	assert.Error(t, (&HMACDPoPNonceStrategy{Secret: []byte("another-super-secret-dpop-nonce-secret")}).ValidateDPoPNonce(context.Background(), rotated))
+}
+
+func TestWriteAccessErrorDPoPNonce(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f := &Fosite{DPoPNonceStrategy: &HMACDPoPNonceStrategy{Secret: []byte("some-super-secret-dpop-nonce-secret-32")}}
+	r := httptest.NewRequest("POST", "https://auth.example.org/token", nil)
+	r.Header.Set(DPoPHeader, newDPoPProof(t, key, "dpop+jwt", map[string]interface{}{
+		"jti": "some-jti",
+		"htm": "POST",
+		"htu": "https://auth.example.org/token",
+		"iat": time.Now().Unix(),
+	}))
+
+	_, err = f.ValidateDPoPProof(context.Background(), r, "")
+	require.EqualError(t, err, ErrUseDPoPNonce.Error())
+
+	rw := httptest.NewRecorder()
+	f.WriteAccessError(rw, nil, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.NotEmpty(t, rw.Header().Get(DPoPNonceHeader))
+	require.NoError(t, f.DPoPNonceStrategy.ValidateDPoPNonce(context.Background(), rw.Header().Get(DPoPNonceHeader)))
+
+	rw = httptest.NewRecorder()
+	f.WriteAccessError(rw, nil, ErrInvalidRequest)
+	assert.Empty(t, rw.Header().Get(DPoPNonceHeader))
+}
+
+func TestValidateDPoPProofRejectsReplays(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f := &Fosite{Store: storage.NewMemoryStore()}
+	request := func(key *ecdsa.PrivateKey, jti string) *http.Request {
+		r := httptest.NewRequest("POST", "https://auth.example.org/token", nil)
+		r.Header.Set(DPoPHeader, newDPoPProof(t, key, "dpop+jwt", map[string]interface{}{
+			"jti": jti,
+			"htm": "POST",
+			"htu": "https://auth.example.org/token",
+			"iat": time.Now().Unix(),
+		}))
+		return r
+	}
+
+	r := request(key, "some-jti")
+	_, err = f.ValidateDPoPProof(context.Background(), r, "")
+	require.NoError(t, err)
+
+	_, err = f.ValidateDPoPProof(context.Background(), r, "")
+	require.EqualError(t, err, ErrInvalidDPoPProof.Error())
+	assert.Contains(t, ErrorToRFC6749Error(err).Hint, "already used")
+
+	_, err = f.ValidateDPoPProof(context.Background(), request(key, "some-other-jti"), "")
+	require.NoError(t, err)
+
+	// The jti is only unique per key.
+	_, err = f.ValidateDPoPProof(context.Background(), request(otherKey, "some-jti"), "")
+	require.NoError(t, err)
+}
+
+func TestValidateDPoPProofUsesClock(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	issuedAt := time.Now().Add(-time.Hour)
+	r := httptest.NewRequest("POST", "https://auth.example.org/token", nil)
+	r.Header.Set(DPoPHeader, newDPoPProof(t, key, "dpop+jwt", map[string]interface{}{
+		"jti": "some-jti",
+		"htm": "POST",
+		"htu": "https://auth.example.org/token",
+		"iat": issuedAt.Unix(),
+	}))
+
+	_, err = (&Fosite{}).ValidateDPoPProof(context.Background(), r, "")
+	require.EqualError(t, err, ErrInvalidDPoPProof.Error())
+
+	_, err = (&Fosite{Clock: func() time.Time { return issuedAt }}).ValidateDPoPProof(context.Background(), r, "")
+	require.NoError(t, err)
+}
+
+func TestValidateDPoPProofForwardedProto(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		d         string
+		trusted   []string
+		expectErr error
+	}{
+		{d: "should ignore X-Forwarded-Proto without trusted proxies", expectErr: ErrInvalidDPoPProof},
+		{d: "should ignore X-Forwarded-Proto of untrusted proxies", trusted: []string{"10.0.0.0/8"}, expectErr: ErrInvalidDPoPProof},
+		{d: "should use X-Forwarded-Proto of trusted proxies", trusted: []string{"192.0.2.1"}},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://auth.example.org/token", nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.Header.Set("X-Forwarded-Proto", "https")
+			r.Header.Set(DPoPHeader, newDPoPProof(t, key, "dpop+jwt", map[string]interface{}{
+				"jti": "some-jti",
+				"htm": "POST",
+				"htu": "https://auth.example.org/token",
+				"iat": time.Now().Unix(),
+			}))
+
+			_, err := (&Fosite{TrustedProxies: c.trusted}).ValidateDPoPProof(context.Background(), r, "")
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ErrorWriter shapes the body of the JSON error responses written by WriteAccessError, WriteIntrospectionError,
+// WriteRevocationResponse and WriteAuthorizeError if the error can not be sent to the redirect URI.
+type ErrorWriter interface {
+	// WriteError returns the response body for the given error and may set additional headers such as the content
+	// type. The error has already been sanitized unless SendDebugMessagesToClients is set. The status code as well as
+	// the Cache-Control, Pragma and WWW-Authenticate headers are set by fosite.
+	WriteError(header http.Header, err *RFC6749Error) ([]byte, error)
+}
+
+// DefaultErrorWriter writes errors as JSON objects as defined in https://tools.ietf.org/html/rfc6749#section-5.2.
+type DefaultErrorWriter struct{}
+
+func (DefaultErrorWriter) WriteError(header http.Header, err *RFC6749Error) ([]byte, error) {
+	header.Set("Content-Type", "application/json;charset=UTF-8")
+	return json.Marshal(err)
+}
+
+// ErrorHook receives errors before they are sanitized and written to the client.
+type ErrorHook func(err *RFC6749Error)
+
+// GetErrorWriter returns ErrorWriter if set. Defaults to DefaultErrorWriter.
+func (f *Fosite) GetErrorWriter() ErrorWriter {
+	if f.ErrorWriter == nil {
+		return DefaultErrorWriter{}
+	}
+	return f.ErrorWriter
+}
+
+// clientFacingError passes the error to the ErrorHook and removes hint and debug information unless
+// SendDebugMessagesToClients is set.
+func (f *Fosite) clientFacingError(err error) *RFC6749Error {
+	rfcerr := ErrorToRFC6749Error(err)
+	if f.ErrorHook != nil {
+		f.ErrorHook(rfcerr)
+	}
+
+	if !f.SendDebugMessagesToClients {
+		rfcerr = rfcerr.Sanitize()
+	}
+	return rfcerr
+}
+
+// writeRFC6749Error writes the error using the configured ErrorWriter.
+func (f *Fosite) writeRFC6749Error(rw http.ResponseWriter, rfcerr *RFC6749Error) {
+	if code := bearerErrorCode(rfcerr); code != "" {
+		// The introspection endpoint and resource servers respond with a challenge as described in Section 3 of
+		// OAuth 2.0 Bearer Token Usage if the bearer token used for authorization is invalid or lacks scope.
+		//
+		// See: https://tools.ietf.org/html/rfc6750#section-3
+		rw.Header().Set("WWW-Authenticate", f.bearerChallenge(code, rfcerr))
+	}
+
+	if rfcerr.dpopNonce != "" {
+		// The nonce the client must use in its next DPoP proof, see RFC 9449 Section 8.
+		rw.Header().Set(DPoPNonceHeader, rfcerr.dpopNonce)
+	}
+
+	js, err := f.GetErrorWriter().WriteError(rw.Header(), rfcerr)
+	if err != nil {
+		if f.SendDebugMessagesToClients {
+			errorMessage := EscapeJSONString(err.Error())
+			http.Error(rw, fmt.Sprintf(`{"error":"server_error","error_description":"%s"}`, errorMessage), http.StatusInternalServerError)
+		} else {
+			http.Error(rw, `{"error":"server_error"}`, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rw.WriteHeader(rfcerr.Code)
+	// ignoring the error because the connection is broken when it happens
+	_, _ = rw.Write(js)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+type envelopeErrorWriter struct {
+	received *RFC6749Error
+}
+
+func (w *envelopeErrorWriter) WriteError(header http.Header, err *RFC6749Error) ([]byte, error) {
+	w.received = err
+	header.Set("Content-Type", "application/problem+json")
+	return json.Marshal(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"code":   err.Name,
+			"detail": err.GetDescription(),
+			"status": err.Code,
+		}},
+	})
+}
+
+func TestErrorWriter(t *testing.T) {
+	for k, c := range []struct {
+		d            string
+		write        func(f *Fosite, rw http.ResponseWriter)
+		expectName   string
+		expectStatus int
+		expectBearer bool
+	}{
+		{
+			d: "access error",
+			write: func(f *Fosite, rw http.ResponseWriter) {
+				f.WriteAccessError(rw, nil, ErrInvalidGrant.WithHint("Some hint."))
+			},
+			expectName:   "invalid_grant",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			d: "authorize error with invalid redirect uri",
+			write: func(f *Fosite, rw http.ResponseWriter) {
+				f.WriteAuthorizeError(rw, NewAuthorizeRequest(), ErrInvalidRequest.WithHint("Some hint."))
+			},
+			expectName:   "invalid_request",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			d: "introspection error",
+			write: func(f *Fosite, rw http.ResponseWriter) {
+				f.WriteIntrospectionError(rw, ErrRequestUnauthorized.WithHint("Some hint."))
+			},
+			expectName:   "request_unauthorized",
+			expectStatus: http.StatusUnauthorized,
+			expectBearer: true,
+		},
+		{
+			d: "revocation error",
+			write: func(f *Fosite, rw http.ResponseWriter) {
+				f.WriteRevocationResponse(rw, ErrInvalidClient)
+			},
+			expectName:   "invalid_client",
+			expectStatus: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(c.d, func(t *testing.T) {
+			for _, w := range []ErrorWriter{nil, new(envelopeErrorWriter)} {
+				f := &Fosite{ErrorWriter: w}
+				rw := httptest.NewRecorder()
+				c.write(f, rw)
+
+				assert.Equal(t, c.expectStatus, rw.Code, "%d", k)
+				assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+				if c.expectBearer {
+					assert.Equal(t, `Bearer error="invalid_token", error_description="The request could not be authorized."`, rw.Header().Get("WWW-Authenticate"))
+				} else {
+					assert.Empty(t, rw.Header().Get("WWW-Authenticate"))
+				}
+
+				if w == nil {
+					assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+					var body map[string]interface{}
+					require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+					assert.Equal(t, c.expectName, body["error"])
+					continue
+				}
+
+				received := w.(*envelopeErrorWriter).received
+				require.NotNil(t, received)
+				assert.Equal(t, c.expectName, received.Name)
+				assert.Equal(t, c.expectStatus, received.Code)
+				assert.NotEmpty(t, received.Description)
+
+				assert.Equal(t, "application/problem+json", rw.Header().Get("Content-Type"))
+				var body struct {
+					Errors []struct {
+						Code   string `json:"code"`
+						Detail string `json:"detail"`
+						Status int    `json:"status"`
+					} `json:"errors"`
+				}
+				require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+				require.Len(t, body.Errors, 1)
+				assert.Equal(t, c.expectName, body.Errors[0].Code)
+				assert.Equal(t, received.GetDescription(), body.Errors[0].Detail)
+				assert.Equal(t, c.expectStatus, body.Errors[0].Status)
+			}
+		})
+	}
+}
+
+func TestErrorHook(t *testing.T) {
+	for k, c := range []struct {
+		d     string
+		write func(f *Fosite, rw *httptest.ResponseRecorder, err error) string
+	}{
+		{
+			d: "access error",
+			write: func(f *Fosite, rw *httptest.ResponseRecorder, err error) string {
+				f.WriteAccessError(rw, nil, err)
+				return rw.Body.String()
+			},
+		},
+		{
+			d: "authorize error with invalid redirect uri",
+			write: func(f *Fosite, rw *httptest.ResponseRecorder, err error) string {
+				f.WriteAuthorizeError(rw, NewAuthorizeRequest(), err)
+				return rw.Body.String()
+			},
+		},
+		{
+			d: "authorize error with redirect",
+			write: func(f *Fosite, rw *httptest.ResponseRecorder, err error) string {
+				ar := NewAuthorizeRequest()
+				ar.Client = &DefaultClient{RedirectURIs: []string{"https://foobar.com/cb"}}
+				ar.RedirectURI, _ = url.Parse("https://foobar.com/cb")
+				f.WriteAuthorizeError(rw, ar, err)
+				return rw.Header().Get("Location")
+			},
+		},
+		{
+			d: "introspection error",
+			write: func(f *Fosite, rw *httptest.ResponseRecorder, err error) string {
+				f.WriteIntrospectionError(rw, err)
+				return rw.Body.String()
+			},
+		},
+	} {
+		for _, debug := range []bool{false, true} {
+			t.Run(fmt.Sprintf("case=%d/description=%s/debug=%v", k, c.d, debug), func(t *testing.T) {
+				var logged *RFC6749Error
+				f := &Fosite{
+					SendDebugMessagesToClients: debug,
+					ErrorHook:                  func(err *RFC6749Error) { logged = err },
+				}
+
+				response := c.write(f, httptest.NewRecorder(), ErrInvalidRequest.WithHint("internal-hint").WithDebug("internal-debug"))
+
+				require.NotNil(t, logged)
+				assert.Equal(t, "invalid_request", logged.Name)
+				assert.Equal(t, "internal-hint", logged.Hint)
+				assert.Equal(t, "internal-debug", logged.Debug())
+
+				assert.Contains(t, response, "invalid_request")
+				if debug {
+					assert.Contains(t, response, "internal-hint")
+					assert.Contains(t, response, "internal-debug")
+				} else {
+					assert.NotContains(t, response, "internal-hint")
+					assert.NotContains(t, response, "internal-debug")
+				}
+			})
+		}
+	}
+}
@@ -38,7 +38,9 @@ var (
 	// ErrSerializationFailure is an error indicating that the transactional capable storage could not guarantee
 	// consistency of Update & Delete operations on the same rows between multiple sessions.
 	ErrSerializationFailure = errors.New("The request could not be completed due to concurrent access")
-	ErrUnknownRequest       = &RFC6749Error{
+	// ErrNonceAlreadyUsed is an error indicating that an OpenID Connect nonce has already been used to issue an ID Token.
+	ErrNonceAlreadyUsed = errors.New("Nonce has already been used")
+	ErrUnknownRequest   = &RFC6749Error{
 		Name:        errUnknownErrorName,
 		Description: "The handler is not responsible for this request.",
 		Code:        http.StatusBadRequest,
@@ -151,6 +153,21 @@ var (
 		Hint:        "The token expired.",
 		Code:        http.StatusUnauthorized,
 	}
+	ErrInvalidToken = &RFC6749Error{
+		Name:        errInvalidTokenFormatName,
+		Description: "The access token provided is expired, revoked, malformed, or invalid for other reasons.",
+		Code:        http.StatusUnauthorized,
+	}
+	ErrInsufficientScope = &RFC6749Error{
+		Name:        errInsufficientScopeName,
+		Description: "The request requires higher privileges than provided by the access token.",
+		Code:        http.StatusForbidden,
+	}
+	ErrMissingBearerToken = &RFC6749Error{
+		Name:        errMissingBearerTokenName,
+		Description: "The request does not contain a bearer token.",
+		Code:        http.StatusUnauthorized,
+	}
 	ErrScopeNotGranted = &RFC6749Error{
 		Name:        errScopeNotGrantedName,
 		Description: "The token was not granted the requested scope.",
@@ -214,6 +231,21 @@ var (
 		Name:        errJTIKnownName,
 		Code:        http.StatusBadRequest,
 	}
+	ErrInvalidDPoPProof = &RFC6749Error{
+		Description: "The DPoP proof is invalid.",
+		Name:        errInvalidDPoPProofName,
+		Code:        http.StatusBadRequest,
+	}
+	ErrUseDPoPNonce = &RFC6749Error{
+		Description: "The authorization server requires a nonce in the DPoP proof.",
+		Name:        errUseDPoPNonceName,
+		Code:        http.StatusBadRequest,
+	}
+	ErrSlowDown = &RFC6749Error{
+		Description: "The client sent too many requests and must slow down.",
+		Name:        errSlowDownName,
+		Code:        http.StatusTooManyRequests,
+	}
 )
 
 const (
@@ -243,6 +275,8 @@ const (
 	errTokenSignatureMismatchName  = "token_signature_mismatch"
 	errTokenExpiredName            = "token_expired"
 	errScopeNotGrantedName         = "scope_not_granted"
+	errInsufficientScopeName       = "insufficient_scope"
+	errMissingBearerTokenName      = "missing_bearer_token"
 	errTokenClaimName              = "token_claim"
 	errTokenInactiveName           = "token_inactive"
 	// errAuthorizationCodeInactiveName = "authorization_code_inactive"
@@ -251,6 +285,9 @@ const (
 	errRequestURINotSupportedName   = "request_uri_not_supported"
 	errRegistrationNotSupportedName = "registration_not_supported"
 	errJTIKnownName                 = "jti_known"
+	errSlowDownName                 = "slow_down"
+	errInvalidDPoPProofName         = "invalid_dpop_proof"
+	errUseDPoPNonceName             = "use_dpop_nonce"
 )
 
 func ErrorToRFC6749Error(err error) *RFC6749Error {
@@ -273,7 +310,14 @@ type RFC6749Error struct {
 	Hint        string
 	Code        int
 	DebugField  string
-	cause       error
+
+	// ParameterField names the request parameter which caused the error, for example "redirect_uri". It is not
+	// part of the error response and helps developers to find out which parameter was rejected.
+	ParameterField string
+	cause          error
+
+	// dpopNonce is sent in the DPoP-Nonce header of use_dpop_nonce errors, see RFC 9449 Section 8.
+	dpopNonce string
 }
 
 func (e *RFC6749Error) Status() string {
@@ -328,6 +372,18 @@ func (e *RFC6749Error) WithDebugf(debug string, args ...interface{}) *RFC6749Err
 	return e.WithDebug(fmt.Sprintf(debug, args...))
 }
 
+// Parameter returns the name of the request parameter which caused the error, if known.
+func (e *RFC6749Error) Parameter() string {
+	return e.ParameterField
+}
+
+// WithParameter returns a copy of the error which names the request parameter that caused it.
+func (e *RFC6749Error) WithParameter(parameter string) *RFC6749Error {
+	err := *e
+	err.ParameterField = parameter
+	return &err
+}
+
 func (e *RFC6749Error) WithDescription(description string) *RFC6749Error {
 	err := *e
 	err.Description = description
@@ -340,8 +396,10 @@ func (e *RFC6749Error) WithCause(cause error) *RFC6749Error {
 	return &err
 }
 
+// Sanitize removes the hint and debug information from the error so that it can be sent to clients.
 func (e *RFC6749Error) Sanitize() *RFC6749Error {
 	err := *e
+	err.Hint = ""
 	err.DebugField = ""
 	return &err
 }
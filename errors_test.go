@@ -22,6 +22,7 @@
 package fosite
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -37,3 +38,16 @@ func TestIs(t *testing.T) {
 		Name: errUnknownErrorName,
 	}, ErrUnknownRequest))
 }
+
+func TestWithParameter(t *testing.T) {
+	err := ErrInvalidRequest.WithHint("Foo.").WithParameter("redirect_uri")
+	assert.Equal(t, "redirect_uri", err.Parameter())
+	assert.Empty(t, ErrInvalidRequest.Parameter())
+	assert.True(t, errors.Is(err, ErrInvalidRequest))
+
+	// The parameter is not part of the error response.
+	assert.Equal(t, ErrInvalidRequest.WithHint("Foo.").ToValues(), err.ToValues())
+	expected, _ := json.Marshal(ErrInvalidRequest.WithHint("Foo."))
+	actual, _ := json.Marshal(err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
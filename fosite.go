@@ -22,9 +22,11 @@
 package fosite
 
 import (
+	"crypto"
 	"html/template"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 // AuthorizeEndpointHandlers is a list of AuthorizeEndpointHandler
@@ -83,7 +85,7 @@ func (t *RevocationHandlers) Append(h RevocationHandler) {
 	*t = append(*t, h)
 }
 
-// Fosite implements OAuth2Provider.
+// Fosite implements OAuth2Provider and ExtendedOAuth2Provider.
 type Fosite struct {
 	Store                      Storage
 	AuthorizeEndpointHandlers  AuthorizeEndpointHandlers
@@ -96,23 +98,284 @@ type Fosite struct {
 	JWKSFetcherStrategy        JWKSFetcherStrategy
 	HTTPClient                 *http.Client
 
+	// AuthorizeResponseHooks are called after the authorize endpoint handlers populated the authorization response and
+	// may add custom parameters to it.
+	AuthorizeResponseHooks []AuthorizeResponseHook
+
+	// ErrorWriter shapes the body of JSON error responses. Defaults to DefaultErrorWriter.
+	ErrorWriter ErrorWriter
+
+	// RedirectURIMatchingStrategy compares the redirect URI of authorization requests with the client's registered
+	// redirect URIs. Defaults to ExactRedirectURIMatchingStrategy.
+	RedirectURIMatchingStrategy RedirectURIMatchingStrategy
+
 	// TokenURL is the the URL of the Authorization Server's Token Endpoint.
 	TokenURL string
 
-	// SendDebugMessagesToClients if set to true, includes error debug messages in response payloads. Be aware that sensitive
+	// TLSClientCertificateHeader is the name of the HTTP header containing the URL encoded PEM client certificate if
+	// TLS is terminated in front of the authorization server. The header is only read from requests sent by one of the
+	// TrustedProxies, which must verify the certificate chain. If not set or if the header is missing, the certificate
+	// is taken from the TLS connection.
+	TLSClientCertificateHeader string
+
+	// TrustedProxies lists the IP addresses and CIDR ranges of the reverse proxies in front of the authorization
+	// server. Headers set by proxies, such as TLSClientCertificateHeader and X-Forwarded-Proto, are ignored unless the
+	// request was sent by a trusted proxy. Defaults to nil, which trusts no proxy.
+	TrustedProxies []string
+
+	// SendDebugMessagesToClients if set to true, includes error hints and debug messages in response payloads. Be aware that sensitive
 	// data may be exposed, depending on your implementation of Fosite. Such sensitive data might include database error
 	// codes or other information. Proceed with caution!
 	SendDebugMessagesToClients bool
 
+	// BearerRealm is the realm sent in the WWW-Authenticate challenge of bearer token errors, see
+	// https://tools.ietf.org/html/rfc6750#section-3. If empty, the realm is omitted.
+	BearerRealm string
+
+	// BearerTokenLocations are the parts of a request BearerTokenFromRequest takes the bearer token from, in order of
+	// precedence. Defaults to DefaultBearerTokenLocations.
+	BearerTokenLocations []BearerTokenLocation
+
+	// BearerTokenHeader is the name of the request header used by BearerTokenLocationCustomHeader.
+	BearerTokenHeader string
+
+	// ResourceServerAudience, if set, is the audience tokens validated by ValidateToken must have been granted.
+	ResourceServerAudience string
+
+	// ErrorHook is called with the complete error, including hint and debug message, before an error response is
+	// written by WriteAccessError, WriteAuthorizeError or WriteIntrospectionError. Use it to log errors server-side.
+	ErrorHook ErrorHook
+
+	// HideUnsupportedGrantTypes if set to true, returns error invalid_grant instead of unauthorized_client and
+	// unsupported_grant_type at the token endpoint. This prevents clients from learning which grant types are supported.
+	HideUnsupportedGrantTypes bool
+
 	// MinParameterEntropy controls the minimum size of state and nonce parameters. Defaults to fosite.MinParameterEntropy.
 	MinParameterEntropy int
 
+	// MinStateEntropy controls the minimum size of the state parameter. Defaults to MinParameterEntropy.
+	MinStateEntropy int
+
+	// StateReplayStore, if set, is used to reject authorization requests that reuse a state the same client already
+	// used within StateReplayWindow. Defaults to nil, which leaves checking the state to the client.
+	StateReplayStore StateReplayStore
+
+	// StateReplayWindow sets how long a used state is remembered by the StateReplayStore. Defaults to one hour.
+	StateReplayWindow time.Duration
+
+	// IntrospectionAudiencePolicy, if set, decides whether the caller of the introspection endpoint may learn about the
+	// introspected token, see AudienceRestrictedIntrospectionPolicy. Defaults to nil, which allows every authenticated
+	// caller to introspect every token.
+	IntrospectionAudiencePolicy IntrospectionAudiencePolicy
+
+	// RateLimiter, if set, rejects requests to the token, revocation and introspection endpoints with ErrSlowDown.
+	// Defaults to nil, which disables rate limiting.
+	RateLimiter RateLimiter
+
+	// TokenTypeHintMetricsHook, if set, is called with the outcome of the token_type_hint of introspection requests.
+	TokenTypeHintMetricsHook TokenTypeHintMetricsHook
+
+	// DPoPNonceStrategy, if set, requires DPoP proofs to contain a server-provided nonce, see RFC 9449 Section 8.
+	// Defaults to nil, which does not require nonces.
+	DPoPNonceStrategy DPoPNonceStrategy
+
+	// DPoPProofLifespan sets how long DPoP proofs are accepted after they were issued. Defaults to one minute.
+	DPoPProofLifespan time.Duration
+
+	// Clock returns the current time and is used to check when DPoP proofs were issued. Defaults to the system clock.
+	Clock Clock
+
+	// StorageRetryPolicy, if set, retries failed client lookups. Defaults to nil, which disables retries.
+	StorageRetryPolicy *RetryPolicy
+
+	// SectorIdentifierValidator, if set, rejects authorization requests of clients using pairwise subject identifiers
+	// whose redirect URIs are not included in the document at their sector_identifier_uri.
+	SectorIdentifierValidator SectorIdentifierValidator
+
+	// JARMSigningKey signs authorization responses for the JWT response modes (JARM), for example "query.jwt". It must
+	// be an *rsa.PrivateKey (RS256) or an *ecdsa.PrivateKey on curve P-256 (ES256), P-384 (ES384) or P-521 (ES512).
+	JARMSigningKey crypto.Signer
+
+	// JARMSigningKeyID is the "kid" header of JWT secured authorization responses and must match the key ID in the
+	// published JWKS. Defaults to the JWK thumbprint of JARMSigningKey.
+	JARMSigningKeyID string
+
+	// JARMIssuer is the "iss" claim of JWT secured authorization responses, usually the authorization server's issuer URL.
+	JARMIssuer string
+
+	// JARMLifespan sets how long JWT secured authorization responses are valid. Defaults to ten minutes.
+	JARMLifespan time.Duration
+
+	// JARMIncludeStateHash, if set to true, adds the s_hash claim, the hash of the state computed like the at_hash
+	// claim of ID Tokens, to JWT secured authorization responses of requests with a state. Defaults to false.
+	JARMIncludeStateHash bool
+
+	// IntrospectionSigningKey signs introspection responses requested as JWT, see WriteIntrospectionResponse. It must
+	// be an *rsa.PrivateKey (RS256) or an *ecdsa.PrivateKey on curve P-256 (ES256), P-384 (ES384) or P-521 (ES512) and
+	// may differ from the key signing ID tokens.
+	IntrospectionSigningKey crypto.Signer
+
+	// IntrospectionSigningKeyID is the "kid" header of signed introspection responses and must match the key ID in the
+	// published JWKS. Defaults to the JWK thumbprint of IntrospectionSigningKey.
+	IntrospectionSigningKeyID string
+
+	// IntrospectionIssuer is the "iss" claim of signed introspection responses, usually the authorization server's issuer URL.
+	IntrospectionIssuer string
+
+	// IssuerFromRequest, if set, resolves the issuer from the HTTP request, for example from its host. NewAuthorizeRequest
+	// and NewAccessRequest store it on the request. It is the "iss" claim of signed introspection and JWT secured
+	// authorization responses, falling back to IntrospectionIssuer and JARMIssuer, and an accepted audience of client
+	// assertions.
+	IssuerFromRequest IssuerFromRequest
+
+	// IntrospectionCacheTTL, if set, allows resource servers to cache the introspection responses of active tokens for
+	// this duration, bounded by the remaining lifetime of the token. Defaults to zero, which forbids caching any
+	// introspection response with "Cache-Control: no-store".
+	IntrospectionCacheTTL time.Duration
+
+	// RequestURIMaxBodySize sets the maximum size in bytes of request objects fetched from a request_uri. Defaults to
+	// DefaultRequestURIMaxBodySize.
+	RequestURIMaxBodySize int64
+
+	// MaxRequestBodySize sets the maximum size in bytes of the bodies of requests to the token, revocation and
+	// introspection endpoints. Larger bodies are rejected with invalid_request. Defaults to DefaultMaxRequestBodySize.
+	MaxRequestBodySize int64
+
+	// RequestURIMaxRedirects sets how many redirects are followed when fetching request objects from a request_uri.
+	// Defaults to DefaultRequestURIMaxRedirects, a negative value disables following redirects. Redirects are only
+	// followed to request URIs registered by the client.
+	RequestURIMaxRedirects int
+
+	// IDGenerator, if set, generates the IDs of authorize and access requests. Storage implementations use these IDs
+	// to revoke all tokens of a request. Defaults to nil, which generates random UUIDs.
+	IDGenerator IDGenerator
+
+	// KnownScopes, if set, is the registry of all scopes of this authorization server. The authorize and token
+	// endpoints reject requests for scopes not matching any of them, using ScopeStrategy, with invalid_scope before
+	// checking the scopes the client is allowed to request. Defaults to nil, which disables the registry.
+	KnownScopes []string
+
+	// DefaultScopes are requested on behalf of clients which omit the scope parameter at the authorize endpoint or
+	// at the token endpoint, except for the authorization_code and refresh_token grants which use the scopes of the
+	// original request. Clients may set their own, see ClientWithDefaultScopes. The default scopes are validated like
+	// requested scopes and rejected with invalid_scope if they exceed the client's scopes. Defaults to nil.
+	DefaultScopes []string
+
+	// EnabledResponseTypes, if set, lists the response types this authorization server accepts, for example "code" and
+	// "code id_token". Authorization requests for other response types are rejected with unsupported_response_type,
+	// regardless of the response types registered for the client. Defaults to nil, which accepts all response types
+	// the authorize endpoint handlers support.
+	EnabledResponseTypes []string
+
+	// ClientSecretRotationHook, if set, is called when a client authenticates with its current secret while it still
+	// has a rotated secret, signaling that the rotated secret can be retired.
+	ClientSecretRotationHook ClientSecretRotationHook
+
+	// TokenEndpointAuthSigningAlgorithms lists the algorithms client assertions of the private_key_jwt and
+	// client_secret_jwt authentication methods may be signed with, in addition to the algorithm each client enforces.
+	// Defaults to DefaultTokenEndpointAuthSigningAlgorithms. The algorithm "none" is never accepted.
+	TokenEndpointAuthSigningAlgorithms []string
+
 	// FormPostHTMLTemplate sets html template for rendering the authorization response when the request has response_mode=form_post. Defaults to fosite.FormPostDefaultTemplate
+	//
+	// The template receives the redirect URI as {{ .RedirURL }} and the response parameters as {{ .Parameters }}. It
+	// must render a form posting all parameters to the redirect URI and submit it automatically, as the default
+	// template does, for example to add branding to the page.
+	//
+	// The page is served with the headers of HTMLResponseSecurityHeaders. Inline scripts must carry the nonce
+	// attribute, set to {{ .Nonce }}, and inline event handlers such as onload are blocked by the default policy.
 	FormPostHTMLTemplate *template.Template
+
+	// WebMessageHTMLTemplate sets the html template for rendering the authorization response when the request has
+	// response_mode=web_message. Defaults to fosite.WebMessageDefaultTemplate.
+	WebMessageHTMLTemplate *template.Template
+
+	// HTMLResponseSecurityHeaders returns the security headers, for example the Content-Security-Policy, of the pages
+	// rendered for response_mode=form_post and response_mode=web_message. Defaults to
+	// fosite.DefaultHTMLResponseSecurityHeaders.
+	HTMLResponseSecurityHeaders HTMLResponseSecurityHeaders
+
+	// AuthorizeRequestLifespan caps the lifetime of authorization request state kept while the authorization request
+	// is paused, such as requests encoded with EncodeAuthorizeRequest. It must be between MinAuthorizeRequestLifespan
+	// and MaxAuthorizeRequestLifespan. Defaults to DefaultAuthorizeRequestLifespan.
+	AuthorizeRequestLifespan time.Duration
+
+	// EncodedAuthorizeRequestLifespan sets how long an authorization request encoded with EncodeAuthorizeRequest can
+	// be decoded. It must be at least MinAuthorizeRequestLifespan and must not exceed AuthorizeRequestLifespan.
+	// Defaults to AuthorizeRequestLifespan.
+	EncodedAuthorizeRequestLifespan time.Duration
 }
 
 const MinParameterEntropy = 8
 
+const (
+	// DefaultRequestURIMaxBodySize is the maximum size in bytes of request objects fetched from a request_uri.
+	DefaultRequestURIMaxBodySize = 1 << 16
+
+	// DefaultMaxRequestBodySize is the maximum size in bytes of the bodies of requests to the token, revocation and
+	// introspection endpoints.
+	DefaultMaxRequestBodySize = 256 << 10
+
+	// DefaultRequestURIMaxRedirects is the number of redirects followed when fetching a request_uri.
+	DefaultRequestURIMaxRedirects = 3
+)
+
+// DefaultTokenEndpointAuthSigningAlgorithms are the algorithms client assertions may be signed with by default.
+var DefaultTokenEndpointAuthSigningAlgorithms = []string{
+	"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "HS256", "HS384", "HS512",
+}
+
+// GetTokenEndpointAuthSigningAlgorithms returns TokenEndpointAuthSigningAlgorithms if set. Defaults to
+// DefaultTokenEndpointAuthSigningAlgorithms.
+func (f *Fosite) GetTokenEndpointAuthSigningAlgorithms() []string {
+	if len(f.TokenEndpointAuthSigningAlgorithms) == 0 {
+		return DefaultTokenEndpointAuthSigningAlgorithms
+	}
+	return f.TokenEndpointAuthSigningAlgorithms
+}
+
+// GetRequestURIMaxBodySize returns RequestURIMaxBodySize if set. Defaults to DefaultRequestURIMaxBodySize.
+func (f *Fosite) GetRequestURIMaxBodySize() int64 {
+	if f.RequestURIMaxBodySize <= 0 {
+		return DefaultRequestURIMaxBodySize
+	}
+	return f.RequestURIMaxBodySize
+}
+
+// GetRequestURIMaxRedirects returns RequestURIMaxRedirects if set. Defaults to DefaultRequestURIMaxRedirects.
+func (f *Fosite) GetRequestURIMaxRedirects() int {
+	if f.RequestURIMaxRedirects == 0 {
+		return DefaultRequestURIMaxRedirects
+	} else if f.RequestURIMaxRedirects < 0 {
+		return 0
+	}
+	return f.RequestURIMaxRedirects
+}
+
+// GetRedirectURIMatchingStrategy returns RedirectURIMatchingStrategy if set. Defaults to ExactRedirectURIMatchingStrategy.
+func (f *Fosite) GetRedirectURIMatchingStrategy() RedirectURIMatchingStrategy {
+	if f.RedirectURIMatchingStrategy == nil {
+		return ExactRedirectURIMatchingStrategy
+	}
+	return f.RedirectURIMatchingStrategy
+}
+
+// GetStateReplayWindow returns StateReplayWindow if set. Defaults to one hour.
+func (f *Fosite) GetStateReplayWindow() time.Duration {
+	if f.StateReplayWindow == 0 {
+		return time.Hour
+	}
+	return f.StateReplayWindow
+}
+
+// GetDPoPProofLifespan returns DPoPProofLifespan if set. Defaults to one minute.
+func (f *Fosite) GetDPoPProofLifespan() time.Duration {
+	if f.DPoPProofLifespan == 0 {
+		return time.Minute
+	}
+	return f.DPoPProofLifespan
+}
+
 // GetMinParameterEntropy returns MinParameterEntropy if set. Defaults to fosite.MinParameterEntropy.
 func (f *Fosite) GetMinParameterEntropy() int {
 	if f.MinParameterEntropy == 0 {
@@ -121,3 +384,11 @@ func (f *Fosite) GetMinParameterEntropy() int {
 		return f.MinParameterEntropy
 	}
 }
+
+// GetMinStateEntropy returns MinStateEntropy if set. Defaults to GetMinParameterEntropy().
+func (f *Fosite) GetMinStateEntropy() int {
+	if f.MinStateEntropy == 0 {
+		return f.GetMinParameterEntropy()
+	}
+	return f.MinStateEntropy
+}
@@ -62,6 +62,24 @@ type AuthorizeExplicitGrantHandler struct {
 	IsRedirectURISecure func(*url.URL) bool
 
 	RefreshTokenScopes []string
+
+	// RequireOfflineAccessForOpenID, if set, issues refresh tokens for OpenID Connect requests, which were granted the
+	// "openid" scope, only if the "offline_access" scope was granted as well, see
+	// https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess. RefreshTokenScopes then only applies to
+	// other requests. Clients can opt into this behavior by implementing fosite.OfflineAccessClient.
+	RequireOfflineAccessForOpenID bool
+
+	// RequireConsentForOfflineAccess, if set, additionally requires the authorization request to contain
+	// "prompt=consent" for a refresh token to be issued when the "offline_access" scope is required.
+	RequireConsentForOfflineAccess bool
+
+	// StatelessAccessTokens, if set, does not persist access tokens, see HandleHelper.StatelessAccessTokens. The
+	// authorization codes and refresh tokens are still persisted. As access tokens can not be looked up by the request
+	// they were issued for, they are not revoked when an authorization code is used twice.
+	StatelessAccessTokens bool
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
 }
 
 func (c *AuthorizeExplicitGrantHandler) secureChecker() func(*url.URL) bool {
@@ -91,7 +109,7 @@ func (c *AuthorizeExplicitGrantHandler) HandleAuthorizeEndpointRequest(ctx conte
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -108,7 +126,7 @@ func (c *AuthorizeExplicitGrantHandler) IssueAuthorizeCode(ctx context.Context,
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 	}
 
-	ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(c.AuthCodeLifespan))
+	ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, c.Clock.Now().Add(c.AuthCodeLifespan))
 	if err := c.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.GetSanitationWhiteList())); err != nil {
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 	}
@@ -125,8 +143,24 @@ func (c *AuthorizeExplicitGrantHandler) GetSanitationWhiteList() []string {
 	if len(c.SanitationWhiteList) > 0 {
 		return c.SanitationWhiteList
 	}
+	if c.RequireConsentForOfflineAccess {
+		// The prompt parameter decides whether a refresh token is issued at the token endpoint.
+		return []string{
+			"code",
+			"redirect_uri",
+			"prompt",
+		}
+	}
 	return []string{
 		"code",
 		"redirect_uri",
 	}
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *AuthorizeExplicitGrantHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{
+		GrantTypes:    []string{"authorization_code"},
+		ResponseTypes: []string{"code"},
+	}
+}
@@ -23,6 +23,7 @@ package oauth2
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/ory/fosite/storage"
@@ -41,8 +42,8 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 		return errors.WithStack(errors.WithStack(fosite.ErrUnknownRequest))
 	}
 
-	if !request.GetClient().GetGrantTypes().Has("authorization_code") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not allowed to use authorization grant \"authorization_code\"."))
+	if err := fosite.ValidateClientGrantType(request.GetClient(), "authorization_code"); err != nil {
+		return err
 	}
 
 	code := request.GetRequestForm().Get("code")
@@ -59,13 +60,30 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 		reqID := authorizeRequest.GetID()
 		hint := "The authorization code has already been used."
 		debug := ""
-		if revErr := c.TokenRevocationStorage.RevokeAccessToken(ctx, reqID); revErr != nil {
+
+		ctx, txErr := storage.MaybeBeginTx(ctx, c.TokenRevocationStorage)
+		if txErr != nil {
+			return errors.WithStack(fosite.ErrServerError.WithCause(txErr).WithDebug(txErr.Error()))
+		}
+
+		accessErr := c.TokenRevocationStorage.RevokeAccessToken(ctx, reqID)
+		if accessErr != nil {
 			hint += " Additionally, an error occurred during processing the access token revocation."
-			debug += "Revocation of access_token lead to error " + revErr.Error() + "."
+			debug += "Revocation of access_token lead to error " + accessErr.Error() + "."
 		}
-		if revErr := c.TokenRevocationStorage.RevokeRefreshToken(ctx, reqID); revErr != nil {
+		refreshErr := c.TokenRevocationStorage.RevokeRefreshToken(ctx, reqID)
+		if refreshErr != nil {
 			hint += " Additionally, an error occurred during processing the refresh token revocation."
-			debug += "Revocation of refresh_token lead to error " + revErr.Error() + "."
+			debug += "Revocation of refresh_token lead to error " + refreshErr.Error() + "."
+		}
+
+		if accessErr != nil || refreshErr != nil {
+			if rbErr := storage.MaybeRollbackTx(ctx, c.TokenRevocationStorage); rbErr != nil {
+				debug += " Rollback of the revocation lead to error " + rbErr.Error() + "."
+			}
+		} else if cErr := storage.MaybeCommitTx(ctx, c.TokenRevocationStorage); cErr != nil {
+			hint += " Additionally, an error occurred during processing the token revocation."
+			debug += "Committing the revocation lead to error " + cErr.Error() + "."
 		}
 		return errors.WithStack(fosite.ErrInvalidGrant.WithHint(hint).WithDebug(debug))
 	} else if err != nil && errors.Is(err, fosite.ErrNotFound) {
@@ -110,17 +128,24 @@ func (c *AuthorizeExplicitGrantHandler) HandleTokenEndpointRequest(ctx context.C
 	request.SetSession(authorizeRequest.GetSession())
 	request.SetID(authorizeRequest.GetID())
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+	request.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan).Round(time.Second))
 	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, c.Clock.Now().Add(c.RefreshTokenLifespan).Round(time.Second))
 	}
 
 	return nil
 }
 
 func canIssueRefreshToken(c *AuthorizeExplicitGrantHandler, request fosite.Requester) bool {
-	// Require one of the refresh token scopes, if set.
-	if len(c.RefreshTokenScopes) > 0 && !request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
+	if requiresOfflineAccess(c, request) {
+		// Require the offline_access scope and, if configured, the consent of the end-user.
+		if !request.GetGrantedScopes().Has("offline_access") {
+			return false
+		} else if c.RequireConsentForOfflineAccess && !fosite.Arguments(fosite.RemoveEmpty(strings.Split(request.GetRequestForm().Get("prompt"), " "))).Has("consent") {
+			return false
+		}
+	} else if len(c.RefreshTokenScopes) > 0 && !request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
+		// Require one of the refresh token scopes, if set.
 		return false
 	}
 	// Do not issue a refresh token to clients that cannot use the refresh token grant type.
@@ -130,6 +155,18 @@ func canIssueRefreshToken(c *AuthorizeExplicitGrantHandler, request fosite.Reque
 	return true
 }
 
+// requiresOfflineAccess returns true if the offline_access scope semantics of OpenID Connect apply to the request.
+func requiresOfflineAccess(c *AuthorizeExplicitGrantHandler, request fosite.Requester) bool {
+	if !request.GetGrantedScopes().Has("openid") {
+		return false
+	} else if c.RequireOfflineAccessForOpenID {
+		return true
+	}
+
+	client, ok := request.GetClient().(fosite.OfflineAccessClient)
+	return ok && client.GetRequireOfflineAccess()
+}
+
 func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	// grant_type REQUIRED.
 	// Value MUST be set to "authorization_code", as this is the explicit grant handler.
@@ -140,7 +177,9 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 	code := requester.GetRequestForm().Get("code")
 	signature := c.AuthorizeCodeStrategy.AuthorizeCodeSignature(code)
 	authorizeRequest, err := c.CoreStorage.GetAuthorizeCodeSession(ctx, signature, requester.GetSession())
-	if err != nil {
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithCause(err).WithDebug(err.Error()))
+	} else if err != nil {
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 	} else if err := c.AuthorizeCodeStrategy.ValidateAuthorizeCode(ctx, requester, code); err != nil {
 		// This needs to happen after store retrieval for the session to be hydrated properly
@@ -178,7 +217,7 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
 		}
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
-	} else if err := c.CoreStorage.CreateAccessTokenSession(ctx, accessSignature, requester.Sanitize([]string{})); err != nil {
+	} else if err := createAccessTokenSession(ctx, c.CoreStorage, c.StatelessAccessTokens, accessSignature, requester.Sanitize([]string{})); err != nil {
 		if rollBackTxnErr := storage.MaybeRollbackTx(ctx, c.CoreStorage); rollBackTxnErr != nil {
 			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebugf("error: %s; rollback error: %s", err, rollBackTxnErr))
 		}
@@ -194,7 +233,7 @@ func (c *AuthorizeExplicitGrantHandler) PopulateTokenEndpointResponse(ctx contex
 
 	responder.SetAccessToken(access)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, c.Clock.Now()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	if refresh != "" {
 		responder.SetExtra("refresh_token", refresh)
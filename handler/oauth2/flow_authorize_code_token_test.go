@@ -82,7 +82,7 @@ func TestAuthorizeCode_PopulateTokenEndpointResponse(t *testing.T) {
 						require.NoError(t, err)
 						areq.Form.Set("code", code)
 					},
-					expectErr: fosite.ErrServerError,
+					expectErr: fosite.ErrInvalidGrant,
 				},
 				{
 					areq: &fosite.AccessRequest{
@@ -220,6 +220,181 @@ func TestAuthorizeCode_PopulateTokenEndpointResponse(t *testing.T) {
 						assert.Equal(t, "foo", aresp.GetExtra("scope"))
 					},
 				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{},
+							Client:       &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}},
+							GrantedScope: fosite.Arguments{"openid", "offline"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccessForOpenID = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should not have refresh token because offline_access is required for OpenID Connect requests",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.Empty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{},
+							Client:       &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}},
+							GrantedScope: fosite.Arguments{"openid", "offline_access"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccessForOpenID = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should have refresh token because offline_access was granted to the OpenID Connect request",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.NotEmpty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{},
+							Client:       &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}},
+							GrantedScope: fosite.Arguments{"offline"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccessForOpenID = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should have refresh token because offline_access is not required for OAuth 2.0 requests",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.NotEmpty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{},
+							Client:       &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}}, RequireOfflineAccess: true},
+							GrantedScope: fosite.Arguments{"openid", "offline"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should not have refresh token because the client requires offline_access",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.Empty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{},
+							Client:       &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}}, RequireOfflineAccess: true},
+							GrantedScope: fosite.Arguments{"openid", "offline_access"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should have refresh token because the client was granted offline_access",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.NotEmpty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{"prompt": {"login"}},
+							Client:       &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}},
+							GrantedScope: fosite.Arguments{"openid", "offline_access"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccessForOpenID = true
+						h.RequireConsentForOfflineAccess = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should not have refresh token because consent was not prompted for",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.Empty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
+				{
+					areq: &fosite.AccessRequest{
+						GrantTypes: fosite.Arguments{"authorization_code"},
+						Request: fosite.Request{
+							Form:         url.Values{"prompt": {"login consent"}},
+							Client:       &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code", "refresh_token"}},
+							GrantedScope: fosite.Arguments{"openid", "offline_access"},
+							Session:      &fosite.DefaultSession{},
+							RequestedAt:  time.Now().UTC(),
+						},
+					},
+					setup: func(t *testing.T, areq *fosite.AccessRequest) {
+						h.RequireOfflineAccessForOpenID = true
+						h.RequireConsentForOfflineAccess = true
+						code, sig, err := strategy.GenerateAuthorizeCode(nil, nil)
+						require.NoError(t, err)
+						areq.Form.Add("code", code)
+
+						require.NoError(t, store.CreateAuthorizeCodeSession(nil, sig, areq))
+					},
+					description: "should have refresh token because consent was prompted for",
+					check: func(t *testing.T, aresp *fosite.AccessResponse) {
+						assert.NotEmpty(t, aresp.AccessToken)
+						assert.NotEmpty(t, aresp.GetExtra("refresh_token"))
+					},
+				},
 			} {
 				t.Run("case="+c.description, func(t *testing.T) {
 					h = AuthorizeExplicitGrantHandler{
@@ -456,6 +631,58 @@ func TestAuthorizeCode_HandleTokenEndpointRequest(t *testing.T) {
 	}
 }
 
+func TestAuthorizeCode_StorageErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := internal.NewMockCoreStorage(ctrl)
+	h := AuthorizeExplicitGrantHandler{
+		CoreStorage:           store,
+		AuthorizeCodeStrategy: hmacshaStrategy,
+	}
+
+	code, _, err := hmacshaStrategy.GenerateAuthorizeCode(nil, nil)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		description string
+		storageErr  error
+		expectErr   error
+	}{
+		{
+			description: "should fail with invalid_grant because the authorization code does not exist",
+			storageErr:  errors.WithStack(fosite.ErrNotFound),
+			expectErr:   fosite.ErrInvalidGrant,
+		},
+		{
+			description: "should fail with server_error because the storage is unavailable",
+			storageErr:  errors.New("connection refused"),
+			expectErr:   fosite.ErrServerError,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			areq := &fosite.AccessRequest{
+				GrantTypes: fosite.Arguments{"authorization_code"},
+				Request: fosite.Request{
+					Form:        url.Values{"code": {code}},
+					Client:      &fosite.DefaultClient{GrantTypes: fosite.Arguments{"authorization_code"}},
+					Session:     &fosite.DefaultSession{},
+					RequestedAt: time.Now().UTC(),
+				},
+			}
+
+			store.EXPECT().GetAuthorizeCodeSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, c.storageErr).Times(2)
+
+			err := h.HandleTokenEndpointRequest(context.Background(), areq)
+			require.EqualError(t, err, c.expectErr.Error())
+			assert.Equal(t, c.expectErr.(*fosite.RFC6749Error).Code, fosite.ErrorToRFC6749Error(err).Code)
+
+			err = h.PopulateTokenEndpointResponse(context.Background(), areq, fosite.NewAccessResponse())
+			require.EqualError(t, err, c.expectErr.Error())
+		})
+	}
+}
+
 func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 	var mockTransactional *internal.MockTransactional
 	var mockCoreStore *internal.MockCoreStorage
@@ -577,6 +804,41 @@ func TestAuthorizeCodeTransactional_HandleTokenEndpointRequest(t *testing.T) {
 			},
 			expectError: fosite.ErrServerError,
 		},
+		{
+			description: "transaction should be rolled back if `CreateRefreshTokenSession` returns an error",
+			setup: func() {
+				mockCoreStore.
+					EXPECT().
+					GetAuthorizeCodeSession(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(request, nil).
+					Times(1)
+				mockTransactional.
+					EXPECT().
+					BeginTX(propagatedContext).
+					Return(propagatedContext, nil)
+				mockCoreStore.
+					EXPECT().
+					InvalidateAuthorizeCodeSession(gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+				mockCoreStore.
+					EXPECT().
+					CreateAccessTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					Return(nil).
+					Times(1)
+				mockCoreStore.
+					EXPECT().
+					CreateRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).
+					Return(errors.New("Whoops, a nasty database error occurred!")).
+					Times(1)
+				mockTransactional.
+					EXPECT().
+					Rollback(propagatedContext).
+					Return(nil).
+					Times(1)
+			},
+			expectError: fosite.ErrServerError,
+		},
 		{
 			description: "should result in a server error if transaction cannot be created",
 			setup: func() {
@@ -45,6 +45,12 @@ type AuthorizeImplicitGrantTypeHandler struct {
 
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
+
+	// StatelessAccessTokens, if set, does not persist access tokens, see HandleHelper.StatelessAccessTokens.
+	StatelessAccessTokens bool
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
 }
 
 func (c *AuthorizeImplicitGrantTypeHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
@@ -67,7 +73,7 @@ func (c *AuthorizeImplicitGrantTypeHandler) HandleAuthorizeEndpointRequest(ctx c
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -84,7 +90,11 @@ func (c *AuthorizeImplicitGrantTypeHandler) HandleAuthorizeEndpointRequest(ctx c
 func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
 	// Only override expiry if none is set.
 	if ar.GetSession().GetExpiresAt(fosite.AccessToken).IsZero() {
-		ar.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+		ar.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan).Round(time.Second))
+	}
+
+	if session, ok := ar.GetSession().(fosite.GrantTypeSession); ok {
+		session.SetGrantType("implicit")
 	}
 
 	// Generate the code
@@ -93,11 +103,11 @@ func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 	}
 
-	if err := c.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, ar.Sanitize([]string{})); err != nil {
+	if err := createAccessTokenSession(ctx, c.AccessTokenStorage, c.StatelessAccessTokens, signature, ar.Sanitize([]string{})); err != nil {
 		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 	}
 	resp.AddParameter("access_token", token)
-	resp.AddParameter("expires_in", strconv.FormatInt(int64(getExpiresIn(ar, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC())/time.Second), 10))
+	resp.AddParameter("expires_in", strconv.FormatInt(int64(getExpiresIn(ar, fosite.AccessToken, c.AccessTokenLifespan, c.Clock.Now())/time.Second), 10))
 	resp.AddParameter("token_type", "bearer")
 	resp.AddParameter("state", ar.GetState())
 	resp.AddParameter("scope", strings.Join(ar.GetGrantedScopes(), " "))
@@ -106,3 +116,11 @@ func (c *AuthorizeImplicitGrantTypeHandler) IssueImplicitAccessToken(ctx context
 
 	return nil
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *AuthorizeImplicitGrantTypeHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{
+		GrantTypes:    []string{"implicit"},
+		ResponseTypes: []string{"token"},
+	}
+}
@@ -23,7 +23,6 @@ package oauth2
 
 import (
 	"context"
-	"time"
 
 	"github.com/pkg/errors"
 
@@ -45,9 +44,13 @@ func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(_ context.Con
 	}
 
 	client := request.GetClient()
+	if err := fosite.ValidateClientGrantType(client, "client_credentials"); err != nil {
+		return err
+	}
+
 	for _, scope := range request.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -58,12 +61,12 @@ func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(_ context.Con
 	// The client MUST authenticate with the authorization server as described in Section 3.2.1.
 	// This requirement is already fulfilled because fosite requires all token requests to be authenticated as described
 	// in https://tools.ietf.org/html/rfc6749#section-3.2.1
-	if client.IsPublic() {
+	if fosite.IsPublicClient(client) {
 		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client is marked as public and is thus not allowed to use authorization grant 'client_credentials'."))
 	}
 	// if the client is not public, he has already been authenticated by the access request handler.
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan))
+	request.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan))
 	return nil
 }
 
@@ -73,9 +76,14 @@ func (c *ClientCredentialsGrantHandler) PopulateTokenEndpointResponse(ctx contex
 		return errors.WithStack(fosite.ErrUnknownRequest)
 	}
 
-	if !request.GetClient().GetGrantTypes().Has("client_credentials") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not allowed to use authorization grant 'client_credentials'."))
+	if err := fosite.ValidateClientGrantType(request.GetClient(), "client_credentials"); err != nil {
+		return err
 	}
 
 	return c.IssueAccessToken(ctx, request, response)
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *ClientCredentialsGrantHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{GrantTypes: []string{"client_credentials"}}
+}
@@ -63,6 +63,16 @@ func TestClientCredentials_HandleTokenEndpointRequest(t *testing.T) {
 				areq.EXPECT().GetGrantTypes().Return(fosite.Arguments{""})
 			},
 		},
+		{
+			description: "should fail because the client is not allowed to use the grant type",
+			expectErr:   fosite.ErrUnauthorizedClient,
+			mock: func() {
+				areq.EXPECT().GetGrantTypes().Return(fosite.Arguments{"client_credentials"})
+				areq.EXPECT().GetClient().Return(&fosite.DefaultClient{
+					GrantTypes: fosite.Arguments{"authorization_code"},
+				})
+			},
+		},
 		{
 			description: "should fail because audience not valid",
 			expectErr:   fosite.ErrInvalidRequest,
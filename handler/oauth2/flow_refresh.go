@@ -47,6 +47,18 @@ type RefreshTokenGrantHandler struct {
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 	RefreshTokenScopes       []string
+
+	// EnforceDPoPBoundRefreshTokens, if set, binds the refresh tokens of confidential clients to the DPoP key they
+	// were issued for. Refresh tokens of public clients are always bound, see RFC 9449 Section 5. Confidential
+	// clients may otherwise present a proof of a new key when refreshing, which rebinds the issued tokens to it.
+	EnforceDPoPBoundRefreshTokens bool
+
+	// StatelessAccessTokens, if set, does not persist access tokens. The access tokens issued for the refresh token
+	// then remain valid until they expire, unless they are denied using StatelessJWTValidator.TokenDenylist.
+	StatelessAccessTokens bool
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
 }
 
 // HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc6749#section-6
@@ -57,8 +69,15 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 		return errors.WithStack(fosite.ErrUnknownRequest)
 	}
 
-	if !request.GetClient().GetGrantTypes().Has("refresh_token") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not allowed to use authorization grant 'refresh_token'."))
+	if err := fosite.ValidateClientGrantType(request.GetClient(), "refresh_token"); err != nil {
+		return err
+	}
+
+	// Unlike the authorization code grant, this grant does not use the redirect_uri parameter. It is therefore ignored,
+	// even if it was set to an empty value or differs from the one used in the initial authorization request.
+	var dpopThumbprint string
+	if session, ok := request.GetSession().(fosite.DPoPBoundSession); ok {
+		dpopThumbprint = session.GetDPoPKeyThumbprint()
 	}
 
 	refresh := request.GetRequestForm().Get("refresh_token")
@@ -86,13 +105,32 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the ID during the initial token issuance."))
 	}
 
+	if err := c.validateDPoPBinding(request, originalRequest, dpopThumbprint); err != nil {
+		return err
+	}
+
+	// The requested scope MUST NOT include any scope not originally granted by the resource owner, and if omitted is
+	// treated as equal to the scope originally granted by the resource owner.
+	grantedScopes := originalRequest.GetGrantedScopes()
+	if requestedScopes := request.GetRequestedScopes(); len(requestedScopes) > 0 {
+		for _, scope := range requestedScopes {
+			if !c.ScopeStrategy(originalRequest.GetGrantedScopes(), scope) {
+				return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The requested scope '%s' was not granted during the initial token issuance and can not be requested when refreshing.", scope).WithParameter("scope"))
+			}
+		}
+		grantedScopes = requestedScopes
+	} else {
+		request.SetRequestedScopes(originalRequest.GetRequestedScopes())
+	}
+
+	// The tokens issued when refreshing keep the ID of the original request, so they can be revoked together.
+	request.SetID(originalRequest.GetID())
 	request.SetSession(originalRequest.GetSession().Clone())
-	request.SetRequestedScopes(originalRequest.GetRequestedScopes())
 	request.SetRequestedAudience(originalRequest.GetRequestedAudience())
 
-	for _, scope := range originalRequest.GetGrantedScopes() {
+	for _, scope := range grantedScopes {
 		if !c.ScopeStrategy(request.GetClient().GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 		request.GrantScope(scope)
 	}
@@ -105,9 +143,28 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 		request.GrantAudience(audience)
 	}
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+	request.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan).Round(time.Second))
 	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, c.Clock.Now().Add(c.RefreshTokenLifespan).Round(time.Second))
+	}
+
+	return nil
+}
+
+// validateDPoPBinding checks that the DPoP proof of the request was signed with the key the refresh token is bound
+// to, see https://datatracker.ietf.org/doc/html/rfc9449#section-5
+func (c *RefreshTokenGrantHandler) validateDPoPBinding(request fosite.AccessRequester, originalRequest fosite.Requester, thumbprint string) error {
+	session, ok := originalRequest.GetSession().(fosite.DPoPBoundSession)
+	if !ok || session.GetDPoPKeyThumbprint() == "" {
+		return nil
+	} else if !fosite.IsPublicClient(request.GetClient()) && !c.EnforceDPoPBoundRefreshTokens {
+		return nil
+	}
+
+	if thumbprint == "" {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The refresh token is bound to a DPoP key but no DPoP proof was presented."))
+	} else if thumbprint != session.GetDPoPKeyThumbprint() {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The refresh token is bound to a different DPoP key than the one of the DPoP proof."))
 	}
 
 	return nil
@@ -148,17 +205,21 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 	storeReq := requester.Sanitize([]string{})
 	storeReq.SetID(ts.GetID())
 
-	if err := c.TokenRevocationStorage.CreateAccessTokenSession(ctx, accessSignature, storeReq); err != nil {
+	if err := createAccessTokenSession(ctx, c.TokenRevocationStorage, c.StatelessAccessTokens, accessSignature, storeReq); err != nil {
 		return handleRefreshTokenEndpointResponseStorageError(ctx, true, c.TokenRevocationStorage, err)
 	}
 
-	if err := c.TokenRevocationStorage.CreateRefreshTokenSession(ctx, refreshSignature, storeReq); err != nil {
+	// Narrowing the scope when refreshing only applies to the issued access token. The new refresh token keeps the
+	// scope originally granted by the resource owner, so that it can be requested again when refreshing later.
+	refreshReq := ts.Sanitize([]string{})
+	refreshReq.SetSession(requester.GetSession())
+	if err := c.TokenRevocationStorage.CreateRefreshTokenSession(ctx, refreshSignature, refreshReq); err != nil {
 		return handleRefreshTokenEndpointResponseStorageError(ctx, true, c.TokenRevocationStorage, err)
 	}
 
 	responder.SetAccessToken(accessToken)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, c.Clock.Now()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	responder.SetExtra("refresh_token", refreshToken)
 
@@ -192,3 +253,8 @@ func handleRefreshTokenEndpointResponseStorageError(ctx context.Context, rollbac
 
 	return errors.WithStack(fosite.ErrServerError.WithCause(storageErr).WithDebug(storageErr.Error()))
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *RefreshTokenGrantHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{GrantTypes: []string{"refresh_token"}}
+}
@@ -52,6 +52,31 @@ func TestRefreshFlow_HandleTokenEndpointRequest(t *testing.T) {
 			store := storage.NewMemoryStore()
 			var h RefreshTokenGrantHandler
 
+			// setupDPoP issues a refresh token bound to the DPoP key "bound-key" and presents a proof of the given key.
+			setupDPoP := func(public bool, thumbprint string) {
+				areq.GrantTypes = fosite.Arguments{"refresh_token"}
+				areq.Client = &fosite.DefaultClient{
+					ID:         "foo",
+					GrantTypes: fosite.Arguments{"refresh_token"},
+					Scopes:     []string{"foo", "offline"},
+					Public:     public,
+				}
+				areq.Session.(*fosite.DefaultSession).DPoPKeyThumbprint = thumbprint
+
+				token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+				require.NoError(t, err)
+
+				areq.Form.Add("refresh_token", token)
+				err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+					Client:         areq.Client,
+					GrantedScope:   fosite.Arguments{"foo", "offline"},
+					RequestedScope: fosite.Arguments{"foo", "offline"},
+					Session:        &fosite.DefaultSession{Subject: "othersub", DPoPKeyThumbprint: "bound-key"},
+					RequestedAt:    time.Now().UTC().Add(-time.Hour).Round(time.Hour),
+				})
+				require.NoError(t, err)
+			}
+
 			for _, c := range []struct {
 				description string
 				setup       func()
@@ -229,6 +254,159 @@ func TestRefreshFlow_HandleTokenEndpointRequest(t *testing.T) {
 						assert.Equal(t, time.Now().Add(time.Hour).UTC().Round(time.Second), areq.GetSession().GetExpiresAt(fosite.RefreshToken))
 					},
 				},
+				{
+					description: "should pass and narrow the granted scopes to the requested subset",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.RequestedScope = fosite.Arguments{"foo", "offline"}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "bar", "offline"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:         areq.Client,
+							GrantedScope:   fosite.Arguments{"foo", "bar", "offline"},
+							RequestedScope: fosite.Arguments{"foo", "bar", "offline"},
+							Session:        sess,
+							Form:           url.Values{"foo": []string{"bar"}},
+							RequestedAt:    time.Now().UTC().Add(-time.Hour).Round(time.Hour),
+						})
+						require.NoError(t, err)
+					},
+					expect: func(t *testing.T) {
+						assert.Equal(t, fosite.Arguments{"foo", "offline"}, areq.GrantedScope)
+						assert.Equal(t, fosite.Arguments{"foo", "offline"}, areq.RequestedScope)
+					},
+				},
+				{
+					description: "should pass and keep the original scopes because no scope was requested",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.RequestedScope = fosite.Arguments{}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "bar", "offline"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:         areq.Client,
+							GrantedScope:   fosite.Arguments{"foo", "bar", "offline"},
+							RequestedScope: fosite.Arguments{"foo", "bar", "offline"},
+							Session:        sess,
+							Form:           url.Values{"foo": []string{"bar"}},
+							RequestedAt:    time.Now().UTC().Add(-time.Hour).Round(time.Hour),
+						})
+						require.NoError(t, err)
+					},
+					expect: func(t *testing.T) {
+						assert.Equal(t, fosite.Arguments{"foo", "bar", "offline"}, areq.GrantedScope)
+						assert.Equal(t, fosite.Arguments{"foo", "bar", "offline"}, areq.RequestedScope)
+					},
+				},
+				{
+					description: "should pass and ignore an empty redirect_uri",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "offline"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						areq.Form.Add("redirect_uri", "")
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:         areq.Client,
+							GrantedScope:   fosite.Arguments{"foo", "offline"},
+							RequestedScope: fosite.Arguments{"foo", "offline"},
+							Session:        sess,
+							Form:           url.Values{"redirect_uri": []string{"https://foo.bar/cb"}},
+							RequestedAt:    time.Now().UTC().Add(-time.Hour).Round(time.Hour),
+						})
+						require.NoError(t, err)
+					},
+					expect: func(t *testing.T) {
+						assert.Equal(t, fosite.Arguments{"foo", "offline"}, areq.GrantedScope)
+					},
+				},
+				{
+					description: "should fail because the requested scope was not granted originally",
+					setup: func() {
+						areq.GrantTypes = fosite.Arguments{"refresh_token"}
+						areq.RequestedScope = fosite.Arguments{"foo", "bar", "offline"}
+						areq.Client = &fosite.DefaultClient{
+							ID:         "foo",
+							GrantTypes: fosite.Arguments{"refresh_token"},
+							Scopes:     []string{"foo", "bar", "offline"},
+						}
+
+						token, sig, err := strategy.GenerateRefreshToken(nil, nil)
+						require.NoError(t, err)
+
+						areq.Form.Add("refresh_token", token)
+						err = store.CreateRefreshTokenSession(nil, sig, &fosite.Request{
+							Client:         areq.Client,
+							GrantedScope:   fosite.Arguments{"foo", "offline"},
+							RequestedScope: fosite.Arguments{"foo", "bar", "offline"},
+							Session:        sess,
+							Form:           url.Values{"foo": []string{"bar"}},
+							RequestedAt:    time.Now().UTC().Add(-time.Hour).Round(time.Hour),
+						})
+						require.NoError(t, err)
+					},
+					expectErr: fosite.ErrInvalidScope,
+				},
+				{
+					description: "should pass because the public client presents a proof of the bound DPoP key",
+					setup: func() {
+						setupDPoP(true, "bound-key")
+					},
+					expect: func(t *testing.T) {
+						assert.Equal(t, "bound-key", areq.GetSession().(fosite.DPoPBoundSession).GetDPoPKeyThumbprint())
+					},
+				},
+				{
+					description: "should fail because the public client presents a proof of a different DPoP key",
+					setup: func() {
+						setupDPoP(true, "other-key")
+					},
+					expectErr: fosite.ErrInvalidGrant,
+				},
+				{
+					description: "should fail because the public client presents no DPoP proof",
+					setup: func() {
+						setupDPoP(true, "")
+					},
+					expectErr: fosite.ErrInvalidGrant,
+				},
+				{
+					description: "should pass because the confidential client may rebind its tokens to a different DPoP key",
+					setup: func() {
+						setupDPoP(false, "other-key")
+					},
+				},
+				{
+					description: "should fail because the confidential client presents a proof of a different DPoP key and bound refresh tokens are enforced",
+					setup: func() {
+						h.EnforceDPoPBoundRefreshTokens = true
+						setupDPoP(false, "other-key")
+					},
+					expectErr: fosite.ErrInvalidGrant,
+				},
 			} {
 				t.Run("case="+c.description, func(t *testing.T) {
 					h = RefreshTokenGrantHandler{
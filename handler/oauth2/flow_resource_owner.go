@@ -30,6 +30,17 @@ import (
 	"github.com/ory/fosite"
 )
 
+// LoginThrottle protects the resource owner password credentials grant against brute-force attacks, for example by
+// locking out a username after too many failed logins.
+type LoginThrottle interface {
+	// Check returns false if logins of the username using the client are currently locked out.
+	Check(ctx context.Context, username string, clientID string) (bool, error)
+
+	// Record records the outcome of a login of the username using the client. Implementations usually count failures
+	// and reset the count after a successful login.
+	Record(ctx context.Context, username string, clientID string, success bool) error
+}
+
 type ResourceOwnerPasswordCredentialsGrantHandler struct {
 	// ResourceOwnerPasswordCredentialsGrantStorage is used to persist session data across requests.
 	ResourceOwnerPasswordCredentialsGrantStorage ResourceOwnerPasswordCredentialsGrantStorage
@@ -39,6 +50,10 @@ type ResourceOwnerPasswordCredentialsGrantHandler struct {
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 	RefreshTokenScopes       []string
 
+	// LoginThrottle, if set, is consulted before the credentials are checked and records the outcome of each login.
+	// Locked out logins fail like logins with wrong credentials. Defaults to nil, which disables throttling.
+	LoginThrottle LoginThrottle
+
 	*HandleHelper
 }
 
@@ -50,14 +65,14 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) HandleTokenEndpointReques
 		return errors.WithStack(fosite.ErrUnknownRequest)
 	}
 
-	if !request.GetClient().GetGrantTypes().Has("password") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The client is not allowed to use authorization grant 'password'."))
+	if err := fosite.ValidateClientGrantType(request.GetClient(), "password"); err != nil {
+		return err
 	}
 
 	client := request.GetClient()
 	for _, scope := range request.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -69,23 +84,51 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) HandleTokenEndpointReques
 	password := request.GetRequestForm().Get("password")
 	if username == "" || password == "" {
 		return errors.WithStack(fosite.ErrInvalidRequest.WithHint("Username or password are missing from the POST body."))
-	} else if err := c.ResourceOwnerPasswordCredentialsGrantStorage.Authenticate(ctx, username, password); errors.Is(err, fosite.ErrNotFound) {
-		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to authenticate the provided username and password credentials.").WithCause(err).WithDebug(err.Error()))
-	} else if err != nil {
-		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	if err := c.authenticate(ctx, client, username, password); err != nil {
+		return err
 	}
 
 	// Credentials must not be passed around, potentially leaking to the database!
 	delete(request.GetRequestForm(), "password")
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+	request.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan).Round(time.Second))
 	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, c.Clock.Now().Add(c.RefreshTokenLifespan).Round(time.Second))
 	}
 
 	return nil
 }
 
+// authenticate checks the credentials of the resource owner, unless the LoginThrottle locked out the username.
+func (c *ResourceOwnerPasswordCredentialsGrantHandler) authenticate(ctx context.Context, client fosite.Client, username, password string) error {
+	if c.LoginThrottle != nil {
+		// Locked out logins return the same error as wrong credentials to not reveal the lockout.
+		if allowed, err := c.LoginThrottle.Check(ctx, username, client.GetID()); err != nil {
+			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		} else if !allowed {
+			return errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to authenticate the provided username and password credentials."))
+		}
+	}
+
+	err := c.ResourceOwnerPasswordCredentialsGrantStorage.Authenticate(ctx, username, password)
+	if err != nil && !errors.Is(err, fosite.ErrNotFound) {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	if c.LoginThrottle != nil {
+		if err := c.LoginThrottle.Record(ctx, username, client.GetID(), err == nil); err != nil {
+			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		}
+	}
+
+	if err != nil {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to authenticate the provided username and password credentials.").WithCause(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
 // PopulateTokenEndpointResponse implements https://tools.ietf.org/html/rfc6749#section-4.3.3
 func (c *ResourceOwnerPasswordCredentialsGrantHandler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	if !requester.GetGrantTypes().ExactOne("password") {
@@ -113,3 +156,8 @@ func (c *ResourceOwnerPasswordCredentialsGrantHandler) PopulateTokenEndpointResp
 
 	return nil
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *ResourceOwnerPasswordCredentialsGrantHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{GrantTypes: []string{"password"}}
+}
@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"testing"
@@ -34,6 +35,7 @@ import (
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestResourceOwnerFlow_HandleTokenEndpointRequest(t *testing.T) {
@@ -231,3 +233,69 @@ func TestResourceOwnerFlow_PopulateTokenEndpointResponse(t *testing.T) {
 		})
 	}
 }
+
+// lockoutThrottle locks out a username after maxFailures consecutive failed logins using the same client.
+type lockoutThrottle struct {
+	maxFailures int
+	failures    map[string]int
+}
+
+func (l *lockoutThrottle) Check(_ context.Context, username string, clientID string) (bool, error) {
+	return l.failures[clientID+"|"+username] < l.maxFailures, nil
+}
+
+func (l *lockoutThrottle) Record(_ context.Context, username string, clientID string, success bool) error {
+	if success {
+		delete(l.failures, clientID+"|"+username)
+	} else {
+		l.failures[clientID+"|"+username]++
+	}
+	return nil
+}
+
+func TestResourceOwnerFlow_HandleTokenEndpointRequestLoginThrottle(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Users["peter"] = storage.MemoryUserRelation{Username: "peter", Password: "secret"}
+	throttle := &lockoutThrottle{maxFailures: 3, failures: map[string]int{}}
+
+	h := ResourceOwnerPasswordCredentialsGrantHandler{
+		ResourceOwnerPasswordCredentialsGrantStorage: store,
+		HandleHelper: &HandleHelper{
+			AccessTokenStorage:   store,
+			AccessTokenLifespan:  time.Hour,
+			RefreshTokenLifespan: time.Hour,
+		},
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		LoginThrottle:            throttle,
+	}
+
+	login := func(clientID, password string) error {
+		areq := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		areq.GrantTypes = fosite.Arguments{"password"}
+		areq.Client = &fosite.DefaultClient{ID: clientID, GrantTypes: fosite.Arguments{"password"}}
+		areq.Form = url.Values{"username": {"peter"}, "password": {password}}
+		return h.HandleTokenEndpointRequest(context.Background(), areq)
+	}
+
+	for i := 0; i < 2; i++ {
+		require.EqualError(t, login("foo", "wrong"), fosite.ErrInvalidGrant.Error())
+	}
+
+	// A successful login resets the failures.
+	require.NoError(t, login("foo", "secret"))
+	assert.Empty(t, throttle.failures)
+
+	for i := 0; i < 3; i++ {
+		require.EqualError(t, login("foo", "wrong"), fosite.ErrInvalidGrant.Error())
+	}
+
+	// The lockout is indistinguishable from wrong credentials, even if the password is correct.
+	err := login("foo", "secret")
+	require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+	assert.Equal(t, "Unable to authenticate the provided username and password credentials.", fosite.ErrorToRFC6749Error(err).Hint)
+	assert.Equal(t, 3, throttle.failures["foo|peter"])
+
+	// Logins using other clients are throttled separately.
+	require.NoError(t, login("bar", "secret"))
+}
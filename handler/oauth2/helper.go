@@ -33,26 +33,44 @@ type HandleHelper struct {
 	AccessTokenStorage   AccessTokenStorage
 	AccessTokenLifespan  time.Duration
 	RefreshTokenLifespan time.Duration
+
+	// StatelessAccessTokens, if set, does not persist access tokens. This requires self-contained access tokens, such
+	// as the ones of DefaultJWTStrategy, which are validated using StatelessJWTValidator.
+	StatelessAccessTokens bool
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
 }
 
 func (h *HandleHelper) IssueAccessToken(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	token, signature, err := h.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
 	if err != nil {
 		return err
-	} else if err := h.AccessTokenStorage.CreateAccessTokenSession(ctx, signature, requester.Sanitize([]string{})); err != nil {
+	} else if err := createAccessTokenSession(ctx, h.AccessTokenStorage, h.StatelessAccessTokens, signature, requester.Sanitize([]string{})); err != nil {
 		return err
 	}
 
 	responder.SetAccessToken(token)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, h.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(getExpiresIn(requester, fosite.AccessToken, h.AccessTokenLifespan, h.Clock.Now()))
 	responder.SetScopes(requester.GetGrantedScopes())
 	return nil
 }
 
+// createAccessTokenSession persists the access token, unless access tokens are stateless.
+func createAccessTokenSession(ctx context.Context, storage AccessTokenStorage, stateless bool, signature string, requester fosite.Requester) error {
+	if stateless {
+		return nil
+	}
+	return storage.CreateAccessTokenSession(ctx, signature, requester)
+}
+
+// getExpiresIn returns the remaining lifetime of the token, which is never negative.
 func getExpiresIn(r fosite.Requester, key fosite.TokenType, defaultLifespan time.Duration, now time.Time) time.Duration {
 	if r.GetSession().GetExpiresAt(key).IsZero() {
 		return defaultLifespan
+	} else if expiresIn := r.GetSession().GetExpiresAt(key).Sub(now); expiresIn > 0 {
+		return expiresIn
 	}
-	return time.Duration(r.GetSession().GetExpiresAt(key).UnixNano() - now.UnixNano())
+	return 0
 }
@@ -42,6 +42,8 @@ func TestGetExpiresIn(t *testing.T) {
 		},
 	})
 	assert.Equal(t, time.Hour, getExpiresIn(r, fosite.AccessToken, time.Millisecond, now))
+	assert.Equal(t, time.Millisecond, getExpiresIn(r, fosite.RefreshToken, time.Millisecond, now))
+	assert.Equal(t, time.Duration(0), getExpiresIn(r, fosite.AccessToken, time.Millisecond, now.Add(2*time.Hour)))
 }
 
 func TestIssueAccessToken(t *testing.T) {
@@ -92,3 +94,25 @@ func TestIssueAccessToken(t *testing.T) {
 		}
 	}
 }
+
+func TestIssueAccessTokenStateless(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	areq := &fosite.AccessRequest{}
+	aresp := &fosite.AccessResponse{Extra: map[string]interface{}{}}
+	accessStrat := internal.NewMockAccessTokenStrategy(ctrl)
+	accessStore := internal.NewMockAccessTokenStorage(ctrl)
+	defer ctrl.Finish()
+
+	helper := HandleHelper{
+		AccessTokenStorage:    accessStore,
+		AccessTokenStrategy:   accessStrat,
+		AccessTokenLifespan:   time.Hour,
+		StatelessAccessTokens: true,
+	}
+
+	areq.Session = &fosite.DefaultSession{}
+	// accessStore expects no calls
+	accessStrat.EXPECT().GenerateAccessToken(nil, areq).Return("token", "signature", nil)
+	require.NoError(t, helper.IssueAccessToken(nil, areq, aresp))
+	assert.Equal(t, "token", aresp.GetAccessToken())
+}
@@ -34,6 +34,9 @@ type CoreValidator struct {
 	CoreStorage
 	ScopeStrategy                 fosite.ScopeStrategy
 	DisableRefreshTokenValidation bool
+
+	// RetryPolicy, if set, retries failed token session lookups.
+	RetryPolicy *fosite.RetryPolicy
 }
 
 func (c *CoreValidator) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
@@ -49,6 +52,8 @@ func (c *CoreValidator) IntrospectToken(ctx context.Context, token string, token
 	case fosite.RefreshToken:
 		if err = c.introspectRefreshToken(ctx, token, accessRequest, scopes); err == nil {
 			return fosite.RefreshToken, nil
+		} else if errors.Is(err, fosite.ErrServerError) {
+			return "", err
 		} else if err = c.introspectAccessToken(ctx, token, accessRequest, scopes); err == nil {
 			return fosite.AccessToken, nil
 		}
@@ -57,13 +62,26 @@ func (c *CoreValidator) IntrospectToken(ctx context.Context, token string, token
 
 	if err = c.introspectAccessToken(ctx, token, accessRequest, scopes); err == nil {
 		return fosite.AccessToken, nil
+	} else if errors.Is(err, fosite.ErrServerError) {
+		return "", err
 	} else if err := c.introspectRefreshToken(ctx, token, accessRequest, scopes); err == nil {
 		return fosite.RefreshToken, nil
+	} else if errors.Is(err, fosite.ErrServerError) {
+		return "", err
 	}
 
 	return "", err
 }
 
+// storageError distinguishes tokens which do not exist from failing storage backends. The former render the token
+// inactive while the latter must surface as a server error to not be mistaken for an authorization failure.
+func storageError(err error) error {
+	if errors.Is(err, fosite.ErrNotFound) {
+		return errors.WithStack(fosite.ErrRequestUnauthorized.WithCause(err).WithDebug(err.Error()))
+	}
+	return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+}
+
 func matchScopes(ss fosite.ScopeStrategy, granted, scopes []string) error {
 	for _, scope := range scopes {
 		if scope == "" {
@@ -80,9 +98,13 @@ func matchScopes(ss fosite.ScopeStrategy, granted, scopes []string) error {
 
 func (c *CoreValidator) introspectAccessToken(ctx context.Context, token string, accessRequest fosite.AccessRequester, scopes []string) error {
 	sig := c.CoreStrategy.AccessTokenSignature(token)
-	or, err := c.CoreStorage.GetAccessTokenSession(ctx, sig, accessRequest.GetSession())
+	var or fosite.Requester
+	err := c.RetryPolicy.Read(ctx, func() (err error) {
+		or, err = c.CoreStorage.GetAccessTokenSession(ctx, sig, accessRequest.GetSession())
+		return err
+	})
 	if err != nil {
-		return errors.WithStack(fosite.ErrRequestUnauthorized.WithCause(err).WithDebug(err.Error()))
+		return storageError(err)
 	} else if err := c.CoreStrategy.ValidateAccessToken(ctx, or, token); err != nil {
 		return err
 	}
@@ -97,10 +119,13 @@ func (c *CoreValidator) introspectAccessToken(ctx context.Context, token string,
 
 func (c *CoreValidator) introspectRefreshToken(ctx context.Context, token string, accessRequest fosite.AccessRequester, scopes []string) error {
 	sig := c.CoreStrategy.RefreshTokenSignature(token)
-	or, err := c.CoreStorage.GetRefreshTokenSession(ctx, sig, accessRequest.GetSession())
-
+	var or fosite.Requester
+	err := c.RetryPolicy.Read(ctx, func() (err error) {
+		or, err = c.CoreStorage.GetRefreshTokenSession(ctx, sig, accessRequest.GetSession())
+		return err
+	})
 	if err != nil {
-		return errors.WithStack(fosite.ErrRequestUnauthorized.WithCause(err).WithDebug(err.Error()))
+		return storageError(err)
 	} else if err := c.CoreStrategy.ValidateRefreshToken(ctx, or, token); err != nil {
 		return err
 	}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	jwtx "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/jwt"
@@ -34,6 +35,15 @@ import (
 type StatelessJWTValidator struct {
 	jwt.JWTStrategy
 	ScopeStrategy fosite.ScopeStrategy
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// ClockSkew is the time the exp, iat and nbf claims may be off when validating a token.
+	ClockSkew time.Duration
+
+	// TokenDenylist, if set, rejects tokens whose "jti" claim was denied when revoking them.
+	TokenDenylist TokenDenylist
 }
 
 // AccessTokenJWTToRequest tries to reconstruct fosite.Request from a JWT.
@@ -62,7 +72,17 @@ func AccessTokenJWTToRequest(token *jwtx.Token) fosite.Requester {
 		}
 	}
 
+	grantID, _ := mapClaims[GrantIDClaim].(string)
+
+	// Restores the binding of the token so that introspection reports it, see strategy_jwt.go
+	var certificateThumbprint, dpopKeyThumbprint string
+	if confirmation, ok := mapClaims["cnf"].(map[string]interface{}); ok {
+		certificateThumbprint, _ = confirmation["x5t#S256"].(string)
+		dpopKeyThumbprint, _ = confirmation["jkt"].(string)
+	}
+
 	return &fosite.Request{
+		ID:          grantID,
 		RequestedAt: requestedAt,
 		Client: &fosite.DefaultClient{
 			ID: clientId,
@@ -78,7 +98,9 @@ func AccessTokenJWTToRequest(token *jwtx.Token) fosite.Requester {
 			ExpiresAt: map[fosite.TokenType]time.Time{
 				fosite.AccessToken: claims.ExpiresAt,
 			},
-			Subject: claims.Subject,
+			Subject:               claims.Subject,
+			CertificateThumbprint: certificateThumbprint,
+			DPoPKeyThumbprint:     dpopKeyThumbprint,
 		},
 		// We do not really know which audiences were requested, so we set them to granted.
 		RequestedAudience: claims.Audience,
@@ -87,15 +109,26 @@ func AccessTokenJWTToRequest(token *jwtx.Token) fosite.Requester {
 }
 
 func (v *StatelessJWTValidator) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
-	t, err := validate(ctx, v.JWTStrategy, token)
+	if v.isExpired(token) {
+		return "", errors.WithStack(fosite.ErrTokenExpired.WithHint("The token expired."))
+	}
+
+	t, err := validate(ctx, v.JWTStrategy, token, v.Clock, v.ClockSkew)
 	if err != nil {
 		return "", err
 	}
 
 	// TODO: From here we assume it is an access token, but how do we know it is really and that is not an ID token?
+	if err := validateTokenUse(t, fosite.AccessToken); err != nil {
+		return "", err
+	}
 
 	requester := AccessTokenJWTToRequest(t)
 
+	if err := v.checkDenylist(ctx, requester); err != nil {
+		return fosite.AccessToken, err
+	}
+
 	if err := matchScopes(v.ScopeStrategy, requester.GetGrantedScopes(), scopes); err != nil {
 		return fosite.AccessToken, err
 	}
@@ -104,3 +137,36 @@ func (v *StatelessJWTValidator) IntrospectToken(ctx context.Context, token strin
 
 	return fosite.AccessToken, nil
 }
+
+// isExpired reports whether the exp claim of the token lies in the past. The claim is read without verifying the
+// signature so that floods of expired tokens are rejected before any signature verification or denylist lookup. This
+// is safe because a forged exp claim can only make a token inactive, never active.
+func (v *StatelessJWTValidator) isExpired(token string) bool {
+	claims := jwtx.MapClaims{}
+	if _, _, err := new(jwtx.Parser).ParseUnverified(token, claims); err != nil {
+		return false
+	}
+	return !claims.VerifyExpiresAt(v.Clock.Now().Add(-v.ClockSkew).Unix(), false)
+}
+
+func (v *StatelessJWTValidator) checkDenylist(ctx context.Context, requester fosite.Requester) error {
+	if v.TokenDenylist == nil {
+		return nil
+	}
+
+	// The token is denied either by itself or together with all tokens of its grant.
+	for _, id := range []string{requester.GetSession().(*JWTSession).JWTClaims.JTI, requester.GetID()} {
+		if id == "" {
+			continue
+		}
+
+		denied, err := v.TokenDenylist.IsDenied(ctx, id)
+		if err != nil {
+			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		} else if denied {
+			return errors.WithStack(fosite.ErrInactiveToken.WithHint("The token has been revoked."))
+		}
+	}
+
+	return nil
+}
@@ -22,11 +22,16 @@
 package oauth2
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	jwtx "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -151,3 +156,180 @@ func BenchmarkIntrospectJWT(b *testing.B) {
 
 	assert.NoError(b, err)
 }
+
+type mapTokenDenylist map[string]time.Time
+
+func (d mapTokenDenylist) Deny(_ context.Context, jti string, exp time.Time) error {
+	d[jti] = exp
+	return nil
+}
+
+func (d mapTokenDenylist) IsDenied(_ context.Context, jti string) (bool, error) {
+	_, ok := d[jti]
+	return ok, nil
+}
+
+func TestIntrospectJWTDenylist(t *testing.T) {
+	strat := &DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: internal.MustRSAKey(),
+		},
+	}
+	denylist := mapTokenDenylist{}
+	v := &StatelessJWTValidator{
+		JWTStrategy:   strat,
+		ScopeStrategy: fosite.HierarchicScopeStrategy,
+		TokenDenylist: denylist,
+	}
+
+	req := jwtValidCase(fosite.AccessToken)
+	req.Client = &fosite.DefaultClient{ID: "foo"}
+	token, _, err := strat.GenerateAccessToken(nil, req)
+	require.NoError(t, err)
+
+	_, err = v.IntrospectToken(nil, token, fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+	require.NoError(t, err)
+
+	r := &TokenRevocationHandler{TokenDenylist: denylist, JWTStrategy: strat}
+	require.EqualError(t, r.denyStatelessAccessToken(nil, token, fosite.AccessToken, &fosite.DefaultClient{ID: "bar"}), fosite.ErrUnauthorizedClient.Error())
+	require.Len(t, denylist, 0)
+	require.NoError(t, r.denyStatelessAccessToken(nil, token, fosite.AccessToken, &fosite.DefaultClient{ID: "foo"}))
+	require.Len(t, denylist, 1)
+
+	_, err = v.IntrospectToken(nil, token, fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+	require.EqualError(t, err, fosite.ErrInactiveToken.Error())
+}
+
+func TestIntrospectJWTConfirmation(t *testing.T) {
+	strat := &DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: internal.MustRSAKey(),
+		},
+	}
+	v := &StatelessJWTValidator{
+		JWTStrategy:   strat,
+		ScopeStrategy: fosite.HierarchicScopeStrategy,
+	}
+
+	for k, c := range []struct {
+		d                  string
+		bind               func(session *JWTSession)
+		expectConfirmation map[string]string
+	}{
+		{
+			d:                  "should report the certificate of mTLS bound tokens",
+			bind:               func(session *JWTSession) { session.CertificateThumbprint = "some-certificate-thumbprint" },
+			expectConfirmation: map[string]string{"x5t#S256": "some-certificate-thumbprint"},
+		},
+		{
+			d:                  "should report the key of DPoP bound tokens",
+			bind:               func(session *JWTSession) { session.DPoPKeyThumbprint = "some-key-thumbprint" },
+			expectConfirmation: map[string]string{"jkt": "some-key-thumbprint"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			req := jwtValidCase(fosite.AccessToken)
+			c.bind(req.Session.(*JWTSession))
+			token, _, err := strat.GenerateAccessToken(nil, req)
+			require.NoError(t, err)
+
+			areq := fosite.NewAccessRequest(nil)
+			_, err = v.IntrospectToken(nil, token, fosite.AccessToken, areq, []string{})
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			new(fosite.Fosite).WriteIntrospectionResponse(rw, &fosite.IntrospectionResponse{Active: true, TokenUse: fosite.AccessToken, AccessRequester: areq})
+
+			var params struct {
+				Confirmation map[string]string `json:"cnf"`
+			}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+			assert.Equal(t, c.expectConfirmation, params.Confirmation)
+		})
+	}
+}
+
+type countingTokenDenylist struct {
+	mapTokenDenylist
+	calls int
+}
+
+func (d *countingTokenDenylist) IsDenied(ctx context.Context, jti string) (bool, error) {
+	d.calls++
+	return d.mapTokenDenylist.IsDenied(ctx, jti)
+}
+
+type countingJWTStrategy struct {
+	jwt.JWTStrategy
+	decodes int
+}
+
+func (s *countingJWTStrategy) Decode(ctx context.Context, token string) (*jwtx.Token, error) {
+	s.decodes++
+	return s.JWTStrategy.Decode(ctx, token)
+}
+
+func TestIntrospectJWTExpiredFastPath(t *testing.T) {
+	strat := &DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: internal.MustRSAKey(),
+		},
+	}
+	counting := &countingJWTStrategy{JWTStrategy: strat}
+	denylist := &countingTokenDenylist{mapTokenDenylist: mapTokenDenylist{}}
+	v := &StatelessJWTValidator{
+		JWTStrategy:   counting,
+		ScopeStrategy: fosite.HierarchicScopeStrategy,
+		TokenDenylist: denylist,
+	}
+
+	req := jwtExpiredCase(fosite.AccessToken)
+	req.ID = "some-grant"
+	expired, _, err := strat.GenerateAccessToken(nil, req)
+	require.NoError(t, err)
+
+	_, err = v.IntrospectToken(nil, expired, fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+	require.EqualError(t, err, fosite.ErrTokenExpired.Error())
+	assert.Equal(t, 0, counting.decodes, "the signature of expired tokens must not be verified")
+	assert.Equal(t, 0, denylist.calls, "the denylist must not be consulted for expired tokens")
+
+	t.Run("case=expired tokens with invalid signature are inactive", func(t *testing.T) {
+		parts := strings.Split(expired, ".")
+		require.Len(t, parts, 3)
+		parts[2] = base64.RawURLEncoding.EncodeToString([]byte("invalid"))
+
+		_, err = v.IntrospectToken(nil, strings.Join(parts, "."), fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+		require.EqualError(t, err, fosite.ErrTokenExpired.Error())
+	})
+
+	t.Run("case=tokens within the clock skew are validated", func(t *testing.T) {
+		skewed := &StatelessJWTValidator{JWTStrategy: counting, TokenDenylist: denylist, ClockSkew: 48 * time.Hour}
+		_, err = skewed.IntrospectToken(nil, expired, fosite.AccessToken, fosite.NewAccessRequest(nil), []string{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, counting.decodes)
+		assert.NotZero(t, denylist.calls)
+	})
+}
+
+func BenchmarkIntrospectExpiredJWT(b *testing.B) {
+	strat := &DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: internal.MustRSAKey(),
+		},
+	}
+
+	v := &StatelessJWTValidator{
+		JWTStrategy:   strat,
+		TokenDenylist: mapTokenDenylist{},
+	}
+
+	token, _, err := strat.GenerateAccessToken(nil, jwtExpiredCase(fosite.AccessToken))
+	assert.NoError(b, err)
+	areq := fosite.NewAccessRequest(nil)
+
+	for n := 0; n < b.N; n++ {
+		_, err = v.IntrospectToken(nil, token, fosite.AccessToken, areq, []string{})
+	}
+
+	assert.EqualError(b, err, fosite.ErrTokenExpired.Error())
+}
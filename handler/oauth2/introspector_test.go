@@ -22,9 +22,11 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
@@ -59,9 +61,9 @@ func TestIntrospectToken(t *testing.T) {
 			setup: func() {
 				httpreq.Header.Set("Authorization", "bearer")
 				chgen.EXPECT().AccessTokenSignature("").Return("")
-				store.EXPECT().GetAccessTokenSession(nil, "", nil).Return(nil, errors.New(""))
+				store.EXPECT().GetAccessTokenSession(nil, "", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
 				chgen.EXPECT().RefreshTokenSignature("").Return("")
-				store.EXPECT().GetRefreshTokenSession(nil, "", nil).Return(nil, errors.New(""))
+				store.EXPECT().GetRefreshTokenSession(nil, "", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
 			},
 			expectErr: fosite.ErrRequestUnauthorized,
 		},
@@ -70,19 +72,35 @@ func TestIntrospectToken(t *testing.T) {
 			setup: func() {
 				httpreq.Header.Set("Authorization", "bearer 1234")
 				chgen.EXPECT().AccessTokenSignature("1234").AnyTimes().Return("asdf")
-				store.EXPECT().GetAccessTokenSession(nil, "asdf", nil).Return(nil, errors.New(""))
+				store.EXPECT().GetAccessTokenSession(nil, "asdf", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
 				chgen.EXPECT().RefreshTokenSignature("1234").Return("asdf")
-				store.EXPECT().GetRefreshTokenSession(nil, "asdf", nil).Return(nil, errors.New(""))
+				store.EXPECT().GetRefreshTokenSession(nil, "asdf", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
 			},
 			expectErr: fosite.ErrRequestUnauthorized,
 		},
+		{
+			description: "should fail with a server error because the storage is unavailable",
+			setup: func() {
+				store.EXPECT().GetAccessTokenSession(nil, "asdf", nil).Return(nil, errors.New("connection refused"))
+			},
+			expectErr: fosite.ErrServerError,
+		},
+		{
+			description: "should fail with a server error because the storage is unavailable when looking up the refresh token",
+			setup: func() {
+				store.EXPECT().GetAccessTokenSession(nil, "asdf", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
+				chgen.EXPECT().RefreshTokenSignature("1234").Return("asdf")
+				store.EXPECT().GetRefreshTokenSession(nil, "asdf", nil).Return(nil, errors.New("connection refused"))
+			},
+			expectErr: fosite.ErrServerError,
+		},
 		{
 			description: "should fail because validation fails",
 			setup: func() {
 				store.EXPECT().GetAccessTokenSession(nil, "asdf", nil).AnyTimes().Return(areq, nil)
 				chgen.EXPECT().ValidateAccessToken(nil, areq, "1234").Return(errors.WithStack(fosite.ErrTokenExpired))
 				chgen.EXPECT().RefreshTokenSignature("1234").Return("asdf")
-				store.EXPECT().GetRefreshTokenSession(nil, "asdf", nil).Return(nil, errors.New(""))
+				store.EXPECT().GetRefreshTokenSession(nil, "asdf", nil).Return(nil, errors.WithStack(fosite.ErrNotFound))
 			},
 			expectErr: fosite.ErrTokenExpired,
 		},
@@ -115,3 +133,28 @@ func TestIntrospectToken(t *testing.T) {
 		})
 	}
 }
+
+func TestIntrospectTokenRetriesStorageReads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := internal.NewMockCoreStorage(ctrl)
+	chgen := internal.NewMockCoreStrategy(ctrl)
+	areq := fosite.NewAccessRequest(nil)
+	defer ctrl.Finish()
+
+	v := &CoreValidator{
+		CoreStrategy: chgen,
+		CoreStorage:  store,
+		RetryPolicy:  &fosite.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond},
+	}
+
+	chgen.EXPECT().AccessTokenSignature("1234").Return("asdf")
+	gomock.InOrder(
+		store.EXPECT().GetAccessTokenSession(gomock.Any(), "asdf", nil).Return(nil, errors.New("connection reset by peer")),
+		store.EXPECT().GetAccessTokenSession(gomock.Any(), "asdf", nil).Return(areq, nil),
+	)
+	chgen.EXPECT().ValidateAccessToken(gomock.Any(), areq, "1234").Return(nil)
+
+	tu, err := v.IntrospectToken(context.Background(), "1234", fosite.AccessToken, areq, []string{})
+	require.NoError(t, err)
+	assert.Equal(t, fosite.AccessToken, tu)
+}
@@ -23,16 +23,34 @@ package oauth2
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type TokenRevocationHandler struct {
 	TokenRevocationStorage TokenRevocationStorage
 	RefreshTokenStrategy   RefreshTokenStrategy
 	AccessTokenStrategy    AccessTokenStrategy
+
+	// TokenTypeHintMetricsHook, if set, is called with the outcome of the token_type_hint of revocation requests.
+	TokenTypeHintMetricsHook fosite.TokenTypeHintMetricsHook
+
+	// TokenDenylist, if set, denies the grant of revoked tokens, and thereby all access tokens issued for it, until
+	// the last of them expires. JWT access tokens which are not found in the storage because they were issued with
+	// StatelessAccessTokens are denied by their "jti" claim, using JWTStrategy to validate them.
+	TokenDenylist TokenDenylist
+	JWTStrategy   jwt.JWTStrategy
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// ClockSkew is the time the exp, iat and nbf claims may be off when validating a token.
+	ClockSkew time.Duration
 }
 
 // RevokeToken implements https://tools.ietf.org/html/rfc7009#section-2.1
@@ -63,18 +81,84 @@ func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string,
 	}
 	// err2 can only be not nil if first err1 was not nil
 	if err2 != nil {
+		if r.TokenDenylist != nil && errors.Is(err1, fosite.ErrNotFound) && errors.Is(err2, fosite.ErrNotFound) {
+			return r.denyStatelessAccessToken(ctx, token, tokenType, client)
+		}
 		return storeErrorsToRevocationError(err1, err2)
 	}
 
+	// Access tokens are looked up first only if hinted, the second lookup is for the other token type.
+	found := fosite.RefreshToken
+	if (tokenType == fosite.AccessToken) == (err1 == nil) {
+		found = fosite.AccessToken
+	}
+	r.TokenTypeHintMetricsHook.Report(ctx, fosite.RevocationEndpoint, tokenType, found)
+
 	if ar.GetClient().GetID() != client.GetID() {
 		return errors.WithStack(fosite.ErrUnauthorizedClient)
 	}
 
 	requestID := ar.GetID()
+
+	if r.TokenDenylist != nil {
+		// The access tokens of the grant, including the ones refreshed using a revoked refresh token, expire at the latest
+		// when the most recently issued one does.
+		if err := r.TokenDenylist.Deny(ctx, requestID, ar.GetSession().GetExpiresAt(fosite.AccessToken)); err != nil {
+			return errors.WithStack(fosite.ErrTemporarilyUnavailable.WithCause(err).WithDebug(err.Error()))
+		}
+	}
+
+	ctx, err := storage.MaybeBeginTx(ctx, r.TokenRevocationStorage)
+	if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
 	err1 = r.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
 	err2 = r.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
 
-	return storeErrorsToRevocationError(err1, err2)
+	if err := storeErrorsToRevocationError(err1, err2); err != nil {
+		if rbErr := storage.MaybeRollbackTx(ctx, r.TokenRevocationStorage); rbErr != nil {
+			return errors.WithStack(fosite.ErrServerError.WithCause(rbErr).WithDebug(rbErr.Error()))
+		}
+		return err
+	}
+
+	if err := storage.MaybeCommitTx(ctx, r.TokenRevocationStorage); err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+// denyStatelessAccessToken adds a JWT access token, which was not persisted, to the TokenDenylist.
+func (r *TokenRevocationHandler) denyStatelessAccessToken(ctx context.Context, token string, tokenType fosite.TokenType, client fosite.Client) error {
+	if r.JWTStrategy == nil {
+		return nil
+	}
+
+	t, err := validate(ctx, r.JWTStrategy, token, r.Clock, r.ClockSkew)
+	if err != nil {
+		// Invalid and expired tokens do not need to be revoked, see https://tools.ietf.org/html/rfc7009#section-2.2
+		return nil
+	}
+	r.TokenTypeHintMetricsHook.Report(ctx, fosite.RevocationEndpoint, tokenType, fosite.AccessToken)
+
+	requester := AccessTokenJWTToRequest(t)
+	if requester.GetClient().GetID() != client.GetID() {
+		return errors.WithStack(fosite.ErrUnauthorizedClient)
+	}
+
+	claims := requester.GetSession().(*JWTSession).JWTClaims
+	if claims.JTI == "" {
+		return errors.WithStack(fosite.ErrServerError.WithHint("The access token can not be revoked because it has no 'jti' claim."))
+	}
+
+	if err := r.TokenDenylist.Deny(ctx, claims.JTI, claims.ExpiresAt); err != nil {
+		// the token may still be valid and the client should retry later
+		return errors.WithStack(fosite.ErrTemporarilyUnavailable.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return nil
 }
 
 func storeErrorsToRevocationError(err1, err2 error) error {
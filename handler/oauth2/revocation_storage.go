@@ -23,6 +23,7 @@ package oauth2
 
 import (
 	"context"
+	"time"
 )
 
 // TokenRevocationStorage provides the storage implementation
@@ -47,3 +48,15 @@ type TokenRevocationStorage interface {
 	// token as well.
 	RevokeAccessToken(ctx context.Context, requestID string) error
 }
+
+// TokenDenylist keeps track of revoked JWTs by their "jti" claim, and of revoked grants by the ID of their request,
+// which JWT access tokens carry in the GrantIDClaim. It allows revoking self-contained access tokens without looking
+// them up, see StatelessJWTValidator.
+type TokenDenylist interface {
+	// Deny denies the token with the given "jti" claim, or all tokens of the grant with the given ID. The tokens
+	// expire at exp, after which the entry is no longer needed and should be removed. A zero exp never expires.
+	Deny(ctx context.Context, jti string, exp time.Time) error
+
+	// IsDenied returns true if the token with the given "jti" claim, or the grant with the given ID, was denied.
+	IsDenied(ctx context.Context, jti string) (bool, error)
+}
@@ -22,6 +22,8 @@
 package oauth2
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -30,6 +32,7 @@ import (
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestRevokeToken(t *testing.T) {
@@ -234,3 +237,91 @@ func TestRevokeToken(t *testing.T) {
 		})
 	}
 }
+
+func TestRevokeTokenTransactional(t *testing.T) {
+	var mockTransactional *internal.MockTransactional
+	var mockStore *internal.MockTokenRevocationStorage
+	propagatedContext := context.Background()
+	request := &fosite.Request{ID: "request-id", Client: &fosite.DefaultClient{ID: "bar"}}
+
+	// some storage implementation that has support for transactions, notice the embedded type `storage.Transactional`
+	type transactionalStore struct {
+		storage.Transactional
+		TokenRevocationStorage
+	}
+
+	for _, testCase := range []struct {
+		description string
+		setup       func()
+		expectError error
+	}{
+		{
+			description: "transaction should be committed successfully if no errors occur",
+			setup: func() {
+				mockStore.EXPECT().GetRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).Return(request, nil)
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().RevokeRefreshToken(propagatedContext, "request-id").Return(nil)
+				mockStore.EXPECT().RevokeAccessToken(propagatedContext, "request-id").Return(fosite.ErrNotFound)
+				mockTransactional.EXPECT().Commit(propagatedContext).Return(nil)
+			},
+		},
+		{
+			description: "transaction should be rolled back if `RevokeAccessToken` returns an error",
+			setup: func() {
+				mockStore.EXPECT().GetRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).Return(request, nil)
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().RevokeRefreshToken(propagatedContext, "request-id").Return(nil)
+				mockStore.EXPECT().RevokeAccessToken(propagatedContext, "request-id").Return(errors.New("Whoops, a nasty database error occurred!"))
+				mockTransactional.EXPECT().Rollback(propagatedContext).Return(nil)
+			},
+			expectError: fosite.ErrTemporarilyUnavailable,
+		},
+		{
+			description: "should result in a server error if transaction cannot be rolled back",
+			setup: func() {
+				mockStore.EXPECT().GetRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).Return(request, nil)
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().RevokeRefreshToken(propagatedContext, "request-id").Return(errors.New("Whoops, a nasty database error occurred!"))
+				mockStore.EXPECT().RevokeAccessToken(propagatedContext, "request-id").Return(nil)
+				mockTransactional.EXPECT().Rollback(propagatedContext).Return(errors.New("Whoops, unable to rollback transaction!"))
+			},
+			expectError: fosite.ErrServerError,
+		},
+		{
+			description: "should result in a server error if transaction cannot be committed",
+			setup: func() {
+				mockStore.EXPECT().GetRefreshTokenSession(propagatedContext, gomock.Any(), gomock.Any()).Return(request, nil)
+				mockTransactional.EXPECT().BeginTX(propagatedContext).Return(propagatedContext, nil)
+				mockStore.EXPECT().RevokeRefreshToken(propagatedContext, "request-id").Return(nil)
+				mockStore.EXPECT().RevokeAccessToken(propagatedContext, "request-id").Return(nil)
+				mockTransactional.EXPECT().Commit(propagatedContext).Return(errors.New("Whoops, unable to commit transaction!"))
+			},
+			expectError: fosite.ErrServerError,
+		},
+	} {
+		t.Run(fmt.Sprintf("scenario=%s", testCase.description), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockTransactional = internal.NewMockTransactional(ctrl)
+			mockStore = internal.NewMockTokenRevocationStorage(ctrl)
+			testCase.setup()
+
+			h := TokenRevocationHandler{
+				TokenRevocationStorage: transactionalStore{
+					mockTransactional,
+					mockStore,
+				},
+				RefreshTokenStrategy: hmacshaStrategy,
+				AccessTokenStrategy:  hmacshaStrategy,
+			}
+
+			err := h.RevokeToken(propagatedContext, "foo", fosite.RefreshToken, &fosite.DefaultClient{ID: "bar"})
+			if testCase.expectError != nil {
+				require.EqualError(t, err, testCase.expectError.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -27,6 +27,11 @@ import (
 	"github.com/ory/fosite"
 )
 
+// CoreStorage stores authorization codes, access tokens and refresh tokens by their signature.
+//
+// Implementations should look up the signature using an index, such as a primary key or a map key. Implementations
+// which compare signatures themselves must not use == but hmac.CompareSignatures from package token/hmac, which takes
+// constant time and does not reveal how many leading characters of a signature match.
 type CoreStorage interface {
 	AuthorizeCodeStorage
 	AccessTokenStorage
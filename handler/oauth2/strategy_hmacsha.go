@@ -36,6 +36,20 @@ type HMACSHAStrategy struct {
 	AccessTokenLifespan   time.Duration
 	RefreshTokenLifespan  time.Duration
 	AuthorizeCodeLifespan time.Duration
+
+	// AccessTokenPrefix is prepended to access tokens, for example "ory_at_", so that API gateways can recognize them
+	// without introspection. The prefix is signed with the token. Changing it invalidates issued access tokens.
+	AccessTokenPrefix string
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// ClockSkew is the time a token is still accepted after it expired.
+	ClockSkew time.Duration
+}
+
+func (h HMACSHAStrategy) isExpired(exp time.Time) bool {
+	return exp.Add(h.ClockSkew).Before(h.Clock.Now())
 }
 
 func (h HMACSHAStrategy) AccessTokenSignature(token string) string {
@@ -49,18 +63,18 @@ func (h HMACSHAStrategy) AuthorizeCodeSignature(token string) string {
 }
 
 func (h HMACSHAStrategy) GenerateAccessToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+	return h.Enigma.GenerateWithPrefix(h.AccessTokenPrefix)
 }
 
 func (h HMACSHAStrategy) ValidateAccessToken(_ context.Context, r fosite.Requester, token string) (err error) {
 	var exp = r.GetSession().GetExpiresAt(fosite.AccessToken)
-	if exp.IsZero() && r.GetRequestedAt().Add(h.AccessTokenLifespan).Before(time.Now().UTC()) {
+	if exp.IsZero() && h.isExpired(r.GetRequestedAt().Add(h.AccessTokenLifespan)) {
 		return errors.WithStack(fosite.ErrTokenExpired.WithHintf("Access token expired at '%s'.", r.GetRequestedAt().Add(h.AccessTokenLifespan)))
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && h.isExpired(exp) {
 		return errors.WithStack(fosite.ErrTokenExpired.WithHintf("Access token expired at '%s'.", exp))
 	}
-	return h.Enigma.Validate(token)
+	return h.Enigma.ValidateWithPrefix(h.AccessTokenPrefix, token)
 }
 
 func (h HMACSHAStrategy) GenerateRefreshToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
@@ -73,7 +87,7 @@ func (h HMACSHAStrategy) ValidateRefreshToken(_ context.Context, r fosite.Reques
 		// Unlimited lifetime
 		return h.Enigma.Validate(token)
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && h.isExpired(exp) {
 		return errors.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", exp))
 	}
 	return h.Enigma.Validate(token)
@@ -85,10 +99,10 @@ func (h HMACSHAStrategy) GenerateAuthorizeCode(_ context.Context, _ fosite.Reque
 
 func (h HMACSHAStrategy) ValidateAuthorizeCode(_ context.Context, r fosite.Requester, token string) (err error) {
 	var exp = r.GetSession().GetExpiresAt(fosite.AuthorizeCode)
-	if exp.IsZero() && r.GetRequestedAt().Add(h.AuthorizeCodeLifespan).Before(time.Now().UTC()) {
+	if exp.IsZero() && h.isExpired(r.GetRequestedAt().Add(h.AuthorizeCodeLifespan)) {
 		return errors.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", r.GetRequestedAt().Add(h.AuthorizeCodeLifespan)))
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && h.isExpired(exp) {
 		return errors.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", exp))
 	}
 
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/hmac"
@@ -109,6 +110,28 @@ func TestHMACAccessToken(t *testing.T) {
 	}
 }
 
+func TestHMACAccessTokenWithPrefix(t *testing.T) {
+	strategy := hmacshaStrategy
+	strategy.AccessTokenPrefix = "ory_at_"
+
+	token, signature, err := strategy.GenerateAccessToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "ory_at_"), token)
+	assert.Equal(t, signature, strategy.AccessTokenSignature(token))
+	require.NoError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, token))
+
+	// A token with a wrong or missing prefix is rejected.
+	assert.Error(t, strategy.ValidateAccessToken(nil, &hmacValidCase, "ory_rt_"+strings.TrimPrefix(token, "ory_at_")))
+	assert.Error(t, strategy.ValidateAccessToken(nil, &hmacValidCase, strings.TrimPrefix(token, "ory_at_")))
+	assert.Error(t, hmacshaStrategy.ValidateAccessToken(nil, &hmacValidCase, token))
+
+	// Refresh tokens and authorize codes are not prefixed.
+	refreshToken, _, err := strategy.GenerateRefreshToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.False(t, strings.HasPrefix(refreshToken, "ory_at_"))
+	assert.Error(t, strategy.ValidateAccessToken(nil, &hmacValidCase, refreshToken))
+}
+
 func TestHMACRefreshToken(t *testing.T) {
 	for k, c := range []struct {
 		r    fosite.Request
@@ -170,3 +193,63 @@ func TestHMACAuthorizeCode(t *testing.T) {
 		})
 	}
 }
+
+func TestHMACValidateWithClock(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := fosite.Request{
+		Client: &fosite.DefaultClient{},
+		Session: &fosite.DefaultSession{
+			ExpiresAt: map[fosite.TokenType]time.Time{
+				fosite.AccessToken:   now,
+				fosite.AuthorizeCode: now,
+				fosite.RefreshToken:  now,
+			},
+		},
+	}
+
+	for k, c := range []struct {
+		d    string
+		now  time.Time
+		skew time.Duration
+		pass bool
+	}{
+		{d: "should pass because the token expires exactly now", now: now, pass: true},
+		{d: "should fail because the token expired a second ago", now: now.Add(time.Second), pass: false},
+		{d: "should pass because the token expired within the clock skew", now: now.Add(time.Minute), skew: time.Minute, pass: true},
+		{d: "should fail because the token expired before the clock skew", now: now.Add(time.Minute + time.Second), skew: time.Minute, pass: false},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			strategy := hmacshaStrategy
+			strategy.Clock = func() time.Time { return c.now }
+			strategy.ClockSkew = c.skew
+
+			for _, tc := range []struct {
+				generate func() (string, string, error)
+				validate func(string) error
+			}{
+				{
+					generate: func() (string, string, error) { return strategy.GenerateAccessToken(nil, &r) },
+					validate: func(token string) error { return strategy.ValidateAccessToken(nil, &r, token) },
+				},
+				{
+					generate: func() (string, string, error) { return strategy.GenerateRefreshToken(nil, &r) },
+					validate: func(token string) error { return strategy.ValidateRefreshToken(nil, &r, token) },
+				},
+				{
+					generate: func() (string, string, error) { return strategy.GenerateAuthorizeCode(nil, &r) },
+					validate: func(token string) error { return strategy.ValidateAuthorizeCode(nil, &r, token) },
+				},
+			} {
+				token, _, err := tc.generate()
+				assert.NoError(t, err)
+
+				err = tc.validate(token)
+				if c.pass {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, fosite.ErrTokenExpired.Error())
+				}
+			}
+		})
+	}
+}
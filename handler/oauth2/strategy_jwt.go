@@ -33,24 +33,112 @@ import (
 	"github.com/ory/fosite/token/jwt"
 )
 
+const timeValidationErrors = jwtx.ValidationErrorExpired | jwtx.ValidationErrorIssuedAt | jwtx.ValidationErrorNotValidYet
+
+// GrantIDClaim is the claim of JWT access tokens carrying the ID of the request they were issued for. The ID is kept
+// when refreshing tokens, so it identifies all tokens issued for the same grant.
+const GrantIDClaim = "grant_id"
+
+// TokenUseClaim is the claim distinguishing JWT refresh tokens, where it is set to "refresh_token", from JWT access
+// tokens, which do not carry it.
+const TokenUseClaim = "token_use"
+
+const (
+	// AccessTokenJWTType is the "typ" header of JWT access tokens, see
+	// https://datatracker.ietf.org/doc/html/rfc9068#section-2.1
+	AccessTokenJWTType = "at+jwt"
+
+	// RefreshTokenJWTType is the "typ" header of JWT refresh tokens, which prevents them from being accepted as
+	// access tokens by resource servers validating the "typ" header.
+	RefreshTokenJWTType = "rt+jwt"
+)
+
+// RefreshTokenFormat selects the format of the refresh tokens issued by DefaultJWTStrategy.
+type RefreshTokenFormat string
+
+const (
+	// RefreshTokenFormatOpaque issues opaque refresh tokens using the HMACSHAStrategy. This is the default.
+	RefreshTokenFormatOpaque RefreshTokenFormat = "opaque"
+
+	// RefreshTokenFormatJWT issues signed JWT refresh tokens, which carry the "jti", GrantIDClaim and "client_id"
+	// claims and can be inspected without a storage lookup. They are still persisted, so that rotating them when
+	// refreshing and rejecting reused refresh tokens works as with opaque refresh tokens.
+	RefreshTokenFormatJWT RefreshTokenFormat = "jwt"
+)
+
 // DefaultJWTStrategy is a JWT RS256 strategy.
 type DefaultJWTStrategy struct {
 	jwt.JWTStrategy
 	HMACSHAStrategy *HMACSHAStrategy
 	Issuer          string
 	ScopeField      jwt.JWTScopeFieldEnum
+
+	// IssuerFromRequest, if set, resolves the "iss" claim from the HTTP request attached to the context with
+	// fosite.WithHTTPRequest. If it returns an empty string, Issuer is used.
+	IssuerFromRequest fosite.IssuerFromRequest
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// ClockSkew is the time the exp, iat and nbf claims may be off when validating a token.
+	ClockSkew time.Duration
+
+	// ScopeClaimMappers maps audiences to a ScopeClaimMapper. The claims returned by the mapper of every granted
+	// audience are added to access tokens. Defaults to nil, which adds no claims.
+	ScopeClaimMappers map[string]ScopeClaimMapper
+
+	// IDGenerator generates the "jti" claim of access tokens whose session does not set one. Defaults to random UUIDs.
+	IDGenerator fosite.IDGenerator
+
+	// RefreshTokenFormat selects the format of issued refresh tokens. Refresh tokens of either format are accepted,
+	// which allows changing the format while refresh tokens are in use. Defaults to RefreshTokenFormatOpaque.
+	RefreshTokenFormat RefreshTokenFormat
+
+	// ClaimsEnrichmentHook, if set, returns additional claims for access tokens and JWT refresh tokens. It can not
+	// replace claims which are already set, nor set the fosite.ProtectedClaims and the "jti", "client_id",
+	// GrantIDClaim and TokenUseClaim claims.
+	ClaimsEnrichmentHook fosite.ClaimsEnrichmentHook
 }
 
+// ScopeClaimMapper transforms the granted scopes of an access token into additional claims understood by a resource
+// server, for example into a "roles" claim. Claims already set by the session or by the strategy are not overwritten.
+type ScopeClaimMapper func(scopes fosite.Arguments) map[string]interface{}
+
 func (h *DefaultJWTStrategy) WithIssuer(issuer string) *DefaultJWTStrategy {
 	h.Issuer = issuer
 	return h
 }
 
+func (h *DefaultJWTStrategy) WithIssuerFromRequest(resolve fosite.IssuerFromRequest) *DefaultJWTStrategy {
+	h.IssuerFromRequest = resolve
+	return h
+}
+
+func (h *DefaultJWTStrategy) WithIDGenerator(generator fosite.IDGenerator) *DefaultJWTStrategy {
+	h.IDGenerator = generator
+	return h
+}
+
 func (h *DefaultJWTStrategy) WithScopeField(scopeField jwt.JWTScopeFieldEnum) *DefaultJWTStrategy {
 	h.ScopeField = scopeField
 	return h
 }
 
+func (h *DefaultJWTStrategy) WithRefreshTokenFormat(format RefreshTokenFormat) *DefaultJWTStrategy {
+	h.RefreshTokenFormat = format
+	return h
+}
+
+func (h *DefaultJWTStrategy) WithClaimsEnrichmentHook(hook fosite.ClaimsEnrichmentHook) *DefaultJWTStrategy {
+	h.ClaimsEnrichmentHook = hook
+	return h
+}
+
+func (h *DefaultJWTStrategy) WithScopeClaimMappers(mappers map[string]ScopeClaimMapper) *DefaultJWTStrategy {
+	h.ScopeClaimMappers = mappers
+	return h
+}
+
 func (h DefaultJWTStrategy) signature(token string) string {
 	split := strings.Split(token, ".")
 	if len(split) != 3 {
@@ -69,11 +157,17 @@ func (h *DefaultJWTStrategy) GenerateAccessToken(ctx context.Context, requester
 }
 
 func (h *DefaultJWTStrategy) ValidateAccessToken(ctx context.Context, _ fosite.Requester, token string) error {
-	_, err := validate(ctx, h.JWTStrategy, token)
-	return err
+	t, err := validate(ctx, h.JWTStrategy, token, h.Clock, h.ClockSkew)
+	if err != nil {
+		return err
+	}
+	return validateTokenUse(t, fosite.AccessToken)
 }
 
 func (h DefaultJWTStrategy) RefreshTokenSignature(token string) string {
+	if isJWT(token) {
+		return h.signature(token)
+	}
 	return h.HMACSHAStrategy.RefreshTokenSignature(token)
 }
 
@@ -82,11 +176,22 @@ func (h DefaultJWTStrategy) AuthorizeCodeSignature(token string) string {
 }
 
 func (h *DefaultJWTStrategy) GenerateRefreshToken(ctx context.Context, req fosite.Requester) (token string, signature string, err error) {
+	if h.RefreshTokenFormat == RefreshTokenFormatJWT {
+		return h.generate(ctx, fosite.RefreshToken, req)
+	}
 	return h.HMACSHAStrategy.GenerateRefreshToken(ctx, req)
 }
 
 func (h *DefaultJWTStrategy) ValidateRefreshToken(ctx context.Context, req fosite.Requester, token string) error {
-	return h.HMACSHAStrategy.ValidateRefreshToken(ctx, req, token)
+	if !isJWT(token) {
+		return h.HMACSHAStrategy.ValidateRefreshToken(ctx, req, token)
+	}
+
+	t, err := validate(ctx, h.JWTStrategy, token, h.Clock, h.ClockSkew)
+	if err != nil {
+		return err
+	}
+	return validateTokenUse(t, fosite.RefreshToken)
 }
 
 func (h *DefaultJWTStrategy) GenerateAuthorizeCode(ctx context.Context, req fosite.Requester) (token string, signature string, err error) {
@@ -97,11 +202,36 @@ func (h *DefaultJWTStrategy) ValidateAuthorizeCode(ctx context.Context, req fosi
 	return h.HMACSHAStrategy.ValidateAuthorizeCode(ctx, req, token)
 }
 
-func validate(ctx context.Context, jwtStrategy jwt.JWTStrategy, token string) (t *jwtx.Token, err error) {
+// isJWT returns true if the token consists of three parts like a JWT, unlike the tokens of the HMACSHAStrategy.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// validateTokenUse ensures that refresh tokens are not accepted as access tokens and vice versa.
+func validateTokenUse(t *jwtx.Token, tokenType fosite.TokenType) error {
+	var use string
+	if claims, ok := t.Claims.(jwtx.MapClaims); ok {
+		use, _ = claims[TokenUseClaim].(string)
+	}
+	typ, _ := t.Header["typ"].(string)
+
+	if isRefreshToken := use == string(fosite.RefreshToken) || strings.EqualFold(typ, RefreshTokenJWTType); isRefreshToken != (tokenType == fosite.RefreshToken) {
+		return errors.WithStack(fosite.ErrTokenClaim.WithHintf("The token is not a valid %s.", tokenType))
+	}
+	return nil
+}
+
+func validate(ctx context.Context, jwtStrategy jwt.JWTStrategy, token string, clock fosite.Clock, skew time.Duration) (t *jwtx.Token, err error) {
 	t, err = jwtStrategy.Decode(ctx, token)
 
-	if err == nil {
-		err = t.Claims.Valid()
+	// The token is decoded using the system clock, so time based claims are validated again using the clock and skew.
+	var e *jwtx.ValidationError
+	if err == nil || (errors.As(err, &e) && t != nil && e.Errors&^timeValidationErrors == 0) {
+		if claims, ok := t.Claims.(jwtx.MapClaims); ok {
+			err = jwt.ValidateTimeClaims(claims, clock.Now(), skew)
+		} else {
+			err = t.Claims.Valid()
+		}
 	}
 
 	if err != nil {
@@ -143,20 +273,94 @@ func (h *DefaultJWTStrategy) generate(ctx context.Context, tokenType fosite.Toke
 	} else if jwtSession.GetJWTClaims() == nil {
 		return "", "", errors.New("GetTokenClaims() must not be nil")
 	} else {
-		claims := jwtSession.GetJWTClaims().
+		container := jwtSession.GetJWTClaims()
+		if c, ok := container.(*jwt.JWTClaims); ok && tokenType == fosite.RefreshToken {
+			// The claims of the session are kept for the access tokens, and the refresh token gets its own "jti".
+			copied := *c
+			copied.JTI = ""
+			container = &copied
+		}
+
+		claims := container.
 			With(
 				jwtSession.GetExpiresAt(tokenType),
 				requester.GetGrantedScopes(),
 				requester.GetGrantedAudience(),
 			).
 			WithDefaults(
-				time.Now().UTC(),
-				h.Issuer,
+				h.Clock.Now(),
+				fosite.ResolveIssuer(ctx, h.IssuerFromRequest, h.Issuer),
 			).
 			WithScopeField(
 				h.ScopeField,
 			)
 
-		return h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), jwtSession.GetJWTHeader())
+		mapClaims := claims.ToMapClaims()
+		if c, ok := claims.(*jwt.JWTClaims); ok && c.JTI == "" {
+			mapClaims["jti"] = h.IDGenerator.New()
+		}
+		if tokenType == fosite.RefreshToken {
+			mapClaims[TokenUseClaim] = string(fosite.RefreshToken)
+			if jwtSession.GetExpiresAt(tokenType).IsZero() {
+				// Refresh tokens may never expire
+				delete(mapClaims, "exp")
+			}
+
+			// Refresh tokens are only sent to the token endpoint, so they do not name resource servers or carry the
+			// scopes a resource server would check. The granted scopes and audience are kept in the storage.
+			delete(mapClaims, "aud")
+			delete(mapClaims, "scp")
+			delete(mapClaims, "scope")
+		}
+		if _, ok := mapClaims[GrantIDClaim]; !ok && requester.GetID() != "" {
+			mapClaims[GrantIDClaim] = requester.GetID()
+		}
+		if _, ok := mapClaims["client_id"]; !ok && requester.GetClient() != nil {
+			// Allows checking which client a token was issued to without looking it up, see AccessTokenJWTToRequest
+			mapClaims["client_id"] = requester.GetClient().GetID()
+		}
+		if session, ok := jwtSession.(fosite.CertificateBoundSession); ok && session.GetCertificateThumbprint() != "" {
+			// Binds the token to the client certificate, see https://tools.ietf.org/html/rfc8705#section-3.1
+			mapClaims["cnf"] = map[string]interface{}{"x5t#S256": session.GetCertificateThumbprint()}
+		}
+		if session, ok := jwtSession.(fosite.DPoPBoundSession); ok && session.GetDPoPKeyThumbprint() != "" {
+			// Binds the token to the DPoP key, see https://datatracker.ietf.org/doc/html/rfc9449#section-6.1
+			mapClaims["cnf"] = map[string]interface{}{"jkt": session.GetDPoPKeyThumbprint()}
+		}
+
+		for _, audience := range requester.GetGrantedAudience() {
+			mapper, ok := h.ScopeClaimMappers[audience]
+			if !ok || tokenType == fosite.RefreshToken {
+				continue
+			}
+
+			for k, v := range mapper(requester.GetGrantedScopes()) {
+				if _, ok := mapClaims[k]; !ok {
+					mapClaims[k] = v
+				}
+			}
+		}
+
+		if err := h.ClaimsEnrichmentHook.Enrich(ctx, tokenType, requester, mapClaims, "jti", "client_id", GrantIDClaim, TokenUseClaim); err != nil {
+			return "", "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		}
+
+		typ := AccessTokenJWTType
+		if tokenType == fosite.RefreshToken {
+			typ = RefreshTokenJWTType
+		}
+		return h.JWTStrategy.Generate(ctx, mapClaims, &typedHeaders{Mapper: jwtSession.GetJWTHeader(), typ: typ})
 	}
 }
+
+// typedHeaders sets the "typ" header, which jwt.Headers does not allow to set.
+type typedHeaders struct {
+	jwt.Mapper
+	typ string
+}
+
+func (h *typedHeaders) ToMap() map[string]interface{} {
+	headers := h.Mapper.ToMap()
+	headers["typ"] = h.typ
+	return headers
+}
@@ -47,6 +47,10 @@ type JWTSession struct {
 	ExpiresAt map[fosite.TokenType]time.Time
 	Username  string
 	Subject   string
+
+	CertificateThumbprint string
+	DPoPKeyThumbprint     string
+	GrantType             string
 }
 
 func (j *JWTSession) GetJWTClaims() jwt.JWTClaimsContainer {
@@ -88,6 +92,15 @@ func (s *JWTSession) GetUsername() string {
 	return s.Username
 }
 
+// SetSubject sets the subject of the session and the "sub" claim of JWT access tokens.
+func (s *JWTSession) SetSubject(subject string) {
+	if s.JWTClaims == nil {
+		s.JWTClaims = &jwt.JWTClaims{}
+	}
+	s.Subject = subject
+	s.JWTClaims.Subject = subject
+}
+
 func (s *JWTSession) GetSubject() string {
 	if s == nil {
 		return ""
@@ -96,6 +109,42 @@ func (s *JWTSession) GetSubject() string {
 	return s.Subject
 }
 
+func (s *JWTSession) SetCertificateThumbprint(thumbprint string) {
+	s.CertificateThumbprint = thumbprint
+}
+
+func (s *JWTSession) GetCertificateThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.CertificateThumbprint
+}
+
+func (s *JWTSession) SetDPoPKeyThumbprint(thumbprint string) {
+	s.DPoPKeyThumbprint = thumbprint
+}
+
+func (s *JWTSession) GetDPoPKeyThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.DPoPKeyThumbprint
+}
+
+func (s *JWTSession) SetGrantType(grantType string) {
+	s.GrantType = grantType
+}
+
+func (s *JWTSession) GetGrantType() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.GrantType
+}
+
 func (s *JWTSession) Clone() fosite.Session {
 	if s == nil {
 		return nil
@@ -22,13 +22,17 @@
 package oauth2
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	jwtx "github.com/dgrijalva/jwt-go"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -228,3 +232,219 @@ func TestAccessToken(t *testing.T) {
 		}
 	}
 }
+
+func TestAccessTokenCertificateBinding(t *testing.T) {
+	r := jwtValidCase(fosite.AccessToken)
+	token, _, err := j.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+	payload := decodeJWTPayload(t, token)
+	assert.NotContains(t, payload, "cnf")
+
+	r.Session.(*JWTSession).SetCertificateThumbprint("thumbprint")
+	token, _, err = j.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+	payload = decodeJWTPayload(t, token)
+	assert.Equal(t, map[string]interface{}{"x5t#S256": "thumbprint"}, payload["cnf"])
+}
+
+func TestAccessTokenScopeClaimMappers(t *testing.T) {
+	s := &DefaultJWTStrategy{
+		JWTStrategy: j.JWTStrategy,
+		ScopeClaimMappers: map[string]ScopeClaimMapper{
+			"https://api.example.com": func(scopes fosite.Arguments) map[string]interface{} {
+				roles := []string{}
+				if scopes.Has("email") {
+					roles = append(roles, "mailer")
+				}
+				return map[string]interface{}{"roles": roles, "foo": "overwritten"}
+			},
+		},
+	}
+
+	t.Run("case=claims are added for the mapped audience", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.GrantAudience("https://api.example.com")
+		token, _, err := s.GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+
+		payload := decodeJWTPayload(t, token)
+		assert.Equal(t, []interface{}{"mailer"}, payload["roles"])
+		// Claims of the session are not overwritten.
+		assert.Equal(t, "bar", payload["foo"])
+	})
+
+	t.Run("case=claims are not added for other audiences", func(t *testing.T) {
+		r := jwtValidCase(fosite.AccessToken)
+		r.GrantAudience("https://other.example.com")
+		token, _, err := s.GenerateAccessToken(nil, r)
+		require.NoError(t, err)
+		assert.NotContains(t, decodeJWTPayload(t, token), "roles")
+	})
+}
+
+func TestAccessTokenClockSkew(t *testing.T) {
+	r := jwtValidCase(fosite.AccessToken)
+	r.Session.(*JWTSession).SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(time.Second*30))
+	token, _, err := j.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+
+	later := func() time.Time { return time.Now().Add(time.Minute) }
+	for k, c := range []struct {
+		d     string
+		clock fosite.Clock
+		skew  time.Duration
+		pass  bool
+	}{
+		{d: "should pass because the token is not expired yet", pass: true},
+		{d: "should fail because the token is expired according to the clock", clock: later, pass: false},
+		{d: "should pass because the token expired within the clock skew", clock: later, skew: time.Minute, pass: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			strategy := *j
+			strategy.Clock = c.clock
+			strategy.ClockSkew = c.skew
+
+			err := strategy.ValidateAccessToken(nil, r, token)
+			if c.pass {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, fosite.ErrTokenExpired.Error())
+			}
+		})
+	}
+}
+
+func decodeJWTHeader(t *testing.T, token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	var header map[string]interface{}
+	require.NoError(t, json.Unmarshal(rawHeader, &header))
+	return header
+}
+
+func decodeJWTPayload(t *testing.T, token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	rawPayload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(rawPayload, &payload))
+	return payload
+}
+
+func TestAccessTokenIDGenerator(t *testing.T) {
+	s := *j
+	s.WithIDGenerator(func() string { return "01ARZ3NDEKTSV4RRFFQ69G5FAV" })
+
+	r := jwtValidCase(fosite.AccessToken)
+	token, _, err := s.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+	assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", decodeJWTPayload(t, token)["jti"])
+
+	r.Session.(*JWTSession).JWTClaims.JTI = "from-session"
+	token, _, err = s.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+	assert.Equal(t, "from-session", decodeJWTPayload(t, token)["jti"])
+}
+
+func TestRefreshTokenFormat(t *testing.T) {
+	s := *j
+	s.HMACSHAStrategy = &hmacshaStrategy
+	s.WithRefreshTokenFormat(RefreshTokenFormatJWT)
+
+	r := jwtValidCase(fosite.RefreshToken)
+	r.ID = "grant"
+	r.Client = &fosite.DefaultClient{ID: "client"}
+	r.Session.(*JWTSession).ExpiresAt[fosite.AccessToken] = time.Now().UTC().Add(time.Minute)
+
+	access, _, err := s.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+	refresh, signature, err := s.GenerateRefreshToken(nil, r)
+	require.NoError(t, err)
+	assert.Equal(t, signature, s.RefreshTokenSignature(refresh))
+
+	claims := decodeJWTPayload(t, refresh)
+	assert.Equal(t, "refresh_token", claims[TokenUseClaim])
+	assert.Equal(t, "grant", claims[GrantIDClaim])
+	assert.Equal(t, "client", claims["client_id"])
+	assert.NotEqual(t, decodeJWTPayload(t, access)["jti"], claims["jti"])
+	assert.Equal(t, float64(r.Session.GetExpiresAt(fosite.RefreshToken).Unix()), claims["exp"])
+	assert.Equal(t, r.Session.GetExpiresAt(fosite.AccessToken).Unix(), r.Session.(*JWTSession).JWTClaims.ExpiresAt.Unix(), "the claims of the session must not change")
+
+	require.NoError(t, s.ValidateRefreshToken(nil, r, refresh))
+	require.NoError(t, s.ValidateAccessToken(nil, r, access))
+	assert.EqualError(t, s.ValidateRefreshToken(nil, r, access), fosite.ErrTokenClaim.Error())
+	assert.EqualError(t, s.ValidateAccessToken(nil, r, refresh), fosite.ErrTokenClaim.Error())
+
+	// The "typ" header distinguishes the tokens, and refresh tokens do not name resource servers or scopes.
+	assert.Equal(t, AccessTokenJWTType, decodeJWTHeader(t, access)["typ"])
+	assert.Equal(t, RefreshTokenJWTType, decodeJWTHeader(t, refresh)["typ"])
+	assert.Contains(t, decodeJWTPayload(t, access), "aud")
+	assert.Contains(t, decodeJWTPayload(t, access), "scp")
+	assert.NotContains(t, claims, "aud")
+	assert.NotContains(t, claims, "scp")
+	assert.NotContains(t, claims, "scope")
+
+	// A refresh token is rejected as access token based on its "typ" header alone.
+	delete(claims, TokenUseClaim)
+	headers := &jwt.Headers{}
+	untyped, _, err := s.JWTStrategy.Generate(nil, jwtx.MapClaims(claims), &typedHeaders{Mapper: headers, typ: RefreshTokenJWTType})
+	require.NoError(t, err)
+	assert.EqualError(t, s.ValidateAccessToken(nil, r, untyped), fosite.ErrTokenClaim.Error())
+	_, err = (&StatelessJWTValidator{JWTStrategy: s.JWTStrategy, ScopeStrategy: fosite.HierarchicScopeStrategy}).IntrospectToken(nil, untyped, fosite.AccessToken, fosite.NewAccessRequest(new(JWTSession)), nil)
+	assert.EqualError(t, err, fosite.ErrTokenClaim.Error())
+	require.NoError(t, s.ValidateRefreshToken(nil, r, untyped))
+
+	// Refresh tokens which never expire carry no "exp" claim.
+	r.Session.(*JWTSession).ExpiresAt[fosite.RefreshToken] = time.Time{}
+	refresh, _, err = s.GenerateRefreshToken(nil, r)
+	require.NoError(t, err)
+	assert.NotContains(t, decodeJWTPayload(t, refresh), "exp")
+	require.NoError(t, s.ValidateRefreshToken(nil, r, refresh))
+
+	// Opaque refresh tokens remain valid after changing the format.
+	s.WithRefreshTokenFormat(RefreshTokenFormatOpaque)
+	opaque, signature, err := s.GenerateRefreshToken(nil, r)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(opaque, "."), 2)
+	assert.Equal(t, signature, s.RefreshTokenSignature(opaque))
+	require.NoError(t, s.ValidateRefreshToken(nil, r, opaque))
+}
+
+func TestAccessTokenClaimsEnrichmentHook(t *testing.T) {
+	s := *j
+	s.WithClaimsEnrichmentHook(func(_ context.Context, tokenType fosite.TokenType, requester fosite.Requester) (map[string]interface{}, error) {
+		assert.Equal(t, fosite.AccessToken, tokenType)
+		return map[string]interface{}{
+			"tenant_id": "tenant-" + requester.GetSession().GetSubject(),
+			"sub":       "mallory",
+			"iss":       "https://evil.example.com",
+			"aud":       []string{"evil"},
+			"exp":       0,
+			"jti":       "forged",
+		}, nil
+	})
+
+	r := jwtValidCase(fosite.AccessToken)
+	r.Session.(*JWTSession).Subject = "peter"
+	token, _, err := s.GenerateAccessToken(nil, r)
+	require.NoError(t, err)
+
+	claims := decodeJWTPayload(t, token)
+	assert.Equal(t, "tenant-peter", claims["tenant_id"])
+	assert.Equal(t, "peter", claims["sub"])
+	assert.Equal(t, "fosite", claims["iss"])
+	assert.Nil(t, claims["aud"])
+	assert.Equal(t, float64(r.Session.GetExpiresAt(fosite.AccessToken).Unix()), claims["exp"])
+	assert.NotEqual(t, "forged", claims["jti"])
+
+	s.WithClaimsEnrichmentHook(func(context.Context, fosite.TokenType, fosite.Requester) (map[string]interface{}, error) {
+		return nil, errors.New("tenant unknown")
+	})
+	_, _, err = s.GenerateAccessToken(nil, r)
+	assert.EqualError(t, err, fosite.ErrServerError.Error())
+}
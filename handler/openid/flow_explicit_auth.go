@@ -23,6 +23,7 @@ package openid
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -34,6 +35,20 @@ type OpenIDConnectExplicitHandler struct {
 	OpenIDConnectRequestStorage   OpenIDConnectRequestStorage
 	OpenIDConnectRequestValidator *OpenIDConnectRequestValidator
 
+	// EnforceNonceReplayProtection, if set to true, allows every nonce to mint only one ID Token at the token endpoint.
+	// The nonce is tracked using NonceReplayStorage for NonceReplayWindow, which defaults to one hour.
+	EnforceNonceReplayProtection bool
+	NonceReplayStorage           NonceReplayStorage
+	NonceReplayWindow            time.Duration
+
+	// MinParameterEntropy is the minimum length of the nonce. Authorization requests with a shorter nonce are rejected
+	// before an authorization code is returned.
+	MinParameterEntropy int
+
+	// Clock returns the current time and is used to compute when a nonce may be used again. Defaults to the system
+	// clock.
+	Clock fosite.Clock
+
 	*IDTokenHandleHelper
 }
 
@@ -58,6 +73,10 @@ func (c *OpenIDConnectExplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 		return errors.WithStack(fosite.ErrMisconfiguration.WithDebug("The authorization code has not been issued yet, indicating a broken code configuration."))
 	}
 
+	if nonce := ar.GetRequestForm().Get("nonce"); len(nonce) > 0 && len(nonce) < c.MinParameterEntropy {
+		return errors.WithStack(fosite.ErrInvalidRequest.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", c.MinParameterEntropy).WithParameter("nonce"))
+	}
+
 	if err := c.OpenIDConnectRequestValidator.ValidatePrompt(ctx, ar); err != nil {
 		return err
 	}
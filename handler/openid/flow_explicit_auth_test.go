@@ -53,6 +53,7 @@ func makeOpenIDConnectExplicitHandler(ctrl *gomock.Controller, minParameterEntro
 			IDTokenStrategy: j,
 		},
 		OpenIDConnectRequestValidator: NewOpenIDConnectRequestValidator(nil, j.JWTStrategy),
+		MinParameterEntropy:           minParameterEntropy,
 	}, store
 }
 
@@ -121,6 +122,15 @@ func TestExplicit_HandleAuthorizeEndpointRequest(t *testing.T) {
 				return h
 			},
 		},
+		{
+			description: "should fail because the nonce is too short",
+			setup: func() OpenIDConnectExplicitHandler {
+				h, _ := makeOpenIDConnectExplicitHandler(ctrl, fosite.MinParameterEntropy)
+				areq.Form.Set("nonce", "1111111")
+				return h
+			},
+			expectErr: fosite.ErrInvalidRequest,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 			h := c.setup()
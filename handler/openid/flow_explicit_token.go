@@ -23,13 +23,37 @@ package openid
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ory/fosite"
 )
 
+// HandleTokenEndpointRequest rejects the authorization code if EnforceNonceReplayProtection is set and the nonce of its
+// authorization request already minted an ID Token. The check runs before any handler issues tokens, so a rejected
+// exchange leaves no tokens behind.
 func (c *OpenIDConnectExplicitHandler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if !c.EnforceNonceReplayProtection || !request.GetGrantTypes().ExactOne("authorization_code") {
+		return errors.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	code := request.GetRequestForm().Get("code")
+	authorize, err := c.OpenIDConnectRequestStorage.GetOpenIDConnectSession(ctx, code, request)
+	if errors.Is(err, ErrNoSessionFound) {
+		return errors.WithStack(fosite.ErrUnknownRequest.WithCause(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	} else if !authorize.GetGrantedScopes().Has("openid") {
+		// PopulateTokenEndpointResponse reports the broken configuration.
+		return errors.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if err := c.validateNonceNotReplayed(ctx, request, authorize, code); err != nil {
+		return err
+	}
+
+	// The authorization code grant is handled by the OAuth 2.0 handler, this handler only adds the ID Token.
 	return errors.WithStack(fosite.ErrUnknownRequest)
 }
 
@@ -38,7 +62,8 @@ func (c *OpenIDConnectExplicitHandler) PopulateTokenEndpointResponse(ctx context
 		return errors.WithStack(fosite.ErrUnknownRequest)
 	}
 
-	authorize, err := c.OpenIDConnectRequestStorage.GetOpenIDConnectSession(ctx, requester.GetRequestForm().Get("code"), requester)
+	code := requester.GetRequestForm().Get("code")
+	authorize, err := c.OpenIDConnectRequestStorage.GetOpenIDConnectSession(ctx, code, requester)
 	if errors.Is(err, ErrNoSessionFound) {
 		return errors.WithStack(fosite.ErrUnknownRequest.WithCause(err).WithDebug(err.Error()))
 	} else if err != nil {
@@ -49,8 +74,8 @@ func (c *OpenIDConnectExplicitHandler) PopulateTokenEndpointResponse(ctx context
 		return errors.WithStack(fosite.ErrMisconfiguration.WithDebug("An OpenID Connect session was found but the openid scope is missing, probably due to a broken code configuration."))
 	}
 
-	if !requester.GetClient().GetGrantTypes().Has("authorization_code") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not allowed to use the authorization grant \"authorization_code\"."))
+	if err := fosite.ValidateClientGrantType(requester.GetClient(), "authorization_code"); err != nil {
+		return err
 	}
 
 	sess, ok := requester.GetSession().(Session)
@@ -74,3 +99,33 @@ func (c *OpenIDConnectExplicitHandler) PopulateTokenEndpointResponse(ctx context
 
 	return c.IssueExplicitIDToken(ctx, authorize, responder)
 }
+
+func (c *OpenIDConnectExplicitHandler) validateNonceNotReplayed(ctx context.Context, requester fosite.AccessRequester, authorize fosite.Requester, code string) error {
+	nonce := authorize.GetRequestForm().Get("nonce")
+	if nonce == "" {
+		return nil
+	}
+
+	if authorize.GetClient() != nil && authorize.GetClient().GetID() != requester.GetClient().GetID() {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The OAuth 2.0 Client ID from this request does not match the one from the authorize request."))
+	}
+
+	if c.NonceReplayStorage == nil {
+		return errors.WithStack(fosite.ErrMisconfiguration.WithDebug("Nonce replay protection is enabled but no nonce replay storage was configured."))
+	}
+
+	if err := c.NonceReplayStorage.SetNonceUsed(ctx, requester.GetClient().GetID(), nonce, code, c.Clock.Now().Add(c.getNonceReplayWindow())); errors.Is(err, fosite.ErrNonceAlreadyUsed) {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The nonce of the authorization request has already been used to issue an ID Token.").WithCause(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+func (c *OpenIDConnectExplicitHandler) getNonceReplayWindow() time.Duration {
+	if c.NonceReplayWindow == 0 {
+		return time.Hour
+	}
+	return c.NonceReplayWindow
+}
@@ -24,6 +24,7 @@ package openid
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/golang/mock/gomock"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 	"github.com/ory/fosite/token/jwt"
 )
 
@@ -161,3 +163,105 @@ func TestExplicit_PopulateTokenEndpointResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestExplicit_HandleTokenEndpointRequest_NonceReplayProtection(t *testing.T) {
+	store := storage.NewMemoryStore()
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"authorization_code"}}
+
+	h := &OpenIDConnectExplicitHandler{
+		OpenIDConnectRequestStorage: store,
+		IDTokenHandleHelper: &IDTokenHandleHelper{
+			IDTokenStrategy: &DefaultStrategy{
+				JWTStrategy: &jwt.RS256JWTStrategy{
+					PrivateKey: key,
+				},
+				MinParameterEntropy: fosite.MinParameterEntropy,
+			},
+		},
+		EnforceNonceReplayProtection: true,
+		NonceReplayStorage:           store,
+	}
+
+	newSession := func() *DefaultSession {
+		return &DefaultSession{
+			Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+			Headers: &jwt.Headers{},
+		}
+	}
+
+	authorize := func(code, nonce string) {
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = client
+		ar.Session = newSession()
+		ar.GrantedScope = fosite.Arguments{"openid"}
+		ar.Form.Set("nonce", nonce)
+		require.NoError(t, store.CreateOpenIDConnectSession(nil, code, ar))
+	}
+
+	exchange := func(code string) error {
+		areq := fosite.NewAccessRequest(newSession())
+		areq.GrantTypes = fosite.Arguments{"authorization_code"}
+		areq.Client = client
+		areq.Form.Set("code", code)
+		if err := h.HandleTokenEndpointRequest(nil, areq); !errors.Is(err, fosite.ErrUnknownRequest) {
+			return err
+		}
+		return h.PopulateTokenEndpointResponse(nil, areq, fosite.NewAccessResponse())
+	}
+
+	authorize("code-1", "nonce-11111111")
+	require.NoError(t, exchange("code-1"))
+
+	t.Run("case=replaying the authorization code fails", func(t *testing.T) {
+		err := exchange("code-1")
+		require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+		assert.True(t, errors.Is(err, fosite.ErrNonceAlreadyUsed))
+	})
+
+	t.Run("case=reusing the nonce with another authorization code fails", func(t *testing.T) {
+		authorize("code-2", "nonce-11111111")
+		require.EqualError(t, exchange("code-2"), fosite.ErrInvalidGrant.Error())
+	})
+
+	t.Run("case=a fresh nonce passes", func(t *testing.T) {
+		authorize("code-3", "nonce-22222222")
+		require.NoError(t, exchange("code-3"))
+	})
+
+	t.Run("case=codes without nonce are not tracked", func(t *testing.T) {
+		authorize("code-4", "")
+		require.NoError(t, exchange("code-4"))
+		require.NoError(t, exchange("code-4"))
+	})
+
+	t.Run("case=fails because the code was issued to another client", func(t *testing.T) {
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = &fosite.DefaultClient{ID: "bar"}
+		ar.Session = newSession()
+		ar.GrantedScope = fosite.Arguments{"openid"}
+		ar.Form.Set("nonce", "nonce-33333333")
+		require.NoError(t, store.CreateOpenIDConnectSession(nil, "code-5", ar))
+		require.EqualError(t, exchange("code-5"), fosite.ErrInvalidGrant.Error())
+	})
+
+	t.Run("case=a nonce can be used again once the replay window passed", func(t *testing.T) {
+		h.NonceReplayWindow = -time.Second
+		defer func() { h.NonceReplayWindow = 0 }()
+
+		authorize("code-6", "nonce-44444444")
+		require.NoError(t, exchange("code-6"))
+		authorize("code-7", "nonce-44444444")
+		require.NoError(t, exchange("code-7"))
+	})
+
+	t.Run("case=the replay window is computed with the clock", func(t *testing.T) {
+		h.Clock = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+		defer func() { h.Clock = nil }()
+
+		// The replay window of one hour ended an hour ago according to the clock of the handler.
+		authorize("code-8", "nonce-55555555")
+		require.NoError(t, exchange("code-8"))
+		authorize("code-9", "nonce-55555555")
+		require.NoError(t, exchange("code-9"))
+	})
+}
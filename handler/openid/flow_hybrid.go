@@ -23,7 +23,6 @@ package openid
 
 import (
 	"context"
-	"encoding/base64"
 	"time"
 
 	"github.com/pkg/errors"
@@ -44,6 +43,14 @@ type OpenIDConnectHybridHandler struct {
 	Enigma *jwt.RS256JWTStrategy
 
 	MinParameterEntropy int
+
+	// AllowMissingNonce, if set to true, accepts requests without a nonce. Defaults to false, which rejects them with
+	// invalid_request as required by OpenID Connect Core 1.0 for the hybrid flow.
+	AllowMissingNonce bool
+
+	// IncludeStateHash, if set to true, adds the s_hash claim, protecting the integrity of the state, to ID Tokens of
+	// requests with a state. Defaults to false.
+	IncludeStateHash bool
 }
 
 func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
@@ -67,9 +74,11 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 	//}
 
 	if nonce := ar.GetRequestForm().Get("nonce"); len(nonce) == 0 {
-		return errors.WithStack(fosite.ErrInvalidRequest.WithHint("Parameter \"nonce\" must be set when using the OpenID Connect Hybrid Flow."))
+		if !c.AllowMissingNonce {
+			return errors.WithStack(fosite.ErrInvalidRequest.WithHint("Parameter \"nonce\" must be set when using the OpenID Connect Hybrid Flow.").WithParameter("nonce"))
+		}
 	} else if len(nonce) < c.MinParameterEntropy {
-		return errors.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", c.MinParameterEntropy))
+		return errors.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", c.MinParameterEntropy).WithParameter("nonce"))
 	}
 
 	sess, ok := ar.GetSession().(Session)
@@ -84,7 +93,7 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -107,7 +116,7 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		// }
 
 		// This is required because we must limit the authorize code lifespan.
-		ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, time.Now().UTC().Add(c.AuthorizeExplicitGrantHandler.AuthCodeLifespan).Round(time.Second))
+		ar.GetSession().SetExpiresAt(fosite.AuthorizeCode, c.AuthorizeExplicitGrantHandler.Clock.Now().Add(c.AuthorizeExplicitGrantHandler.AuthCodeLifespan).Round(time.Second))
 		if err := c.AuthorizeExplicitGrantHandler.CoreStorage.CreateAuthorizeCodeSession(ctx, signature, ar.Sanitize(c.AuthorizeExplicitGrantHandler.GetSanitationWhiteList())); err != nil {
 			return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
 		}
@@ -115,11 +124,11 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		resp.AddParameter("code", code)
 		ar.SetResponseTypeHandled("code")
 
-		hash, err := c.Enigma.Hash(ctx, []byte(resp.GetParameters().Get("code")))
+		hash, err := c.IDTokenHandleHelper.tokenHash(ctx, ar, resp.GetParameters().Get("code"), c.Enigma)
 		if err != nil {
 			return err
 		}
-		claims.CodeHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.Enigma.GetSigningMethodLength()/2]))
+		claims.CodeHash = hash
 
 		if ar.GetGrantedScopes().Has("openid") {
 			if err := c.OpenIDConnectRequestStorage.CreateOpenIDConnectSession(ctx, resp.GetCode(), ar.Sanitize(oidcParameters)); err != nil {
@@ -136,11 +145,11 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		}
 		ar.SetResponseTypeHandled("token")
 
-		hash, err := c.Enigma.Hash(ctx, []byte(resp.GetParameters().Get("access_token")))
+		hash, err := c.IDTokenHandleHelper.tokenHash(ctx, ar, resp.GetParameters().Get("access_token"), c.Enigma)
 		if err != nil {
 			return err
 		}
-		claims.AccessTokenHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.Enigma.GetSigningMethodLength()/2]))
+		claims.AccessTokenHash = hash
 	}
 
 	if resp.GetParameters().Get("state") == "" {
@@ -152,6 +161,14 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 		return nil
 	}
 
+	if state := ar.GetState(); c.IncludeStateHash && state != "" {
+		hash, err := c.IDTokenHandleHelper.tokenHash(ctx, ar, state, c.Enigma)
+		if err != nil {
+			return err
+		}
+		claims.StateHash = hash
+	}
+
 	if err := c.IDTokenHandleHelper.IssueImplicitIDToken(ctx, ar, resp); err != nil {
 		return errors.WithStack(err)
 	}
@@ -161,3 +178,11 @@ func (c *OpenIDConnectHybridHandler) HandleAuthorizeEndpointRequest(ctx context.
 	// there is no need to check for https, because implicit flow does not require https
 	// https://tools.ietf.org/html/rfc6819#section-4.4.2
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *OpenIDConnectHybridHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{
+		GrantTypes:    []string{"authorization_code", "implicit"},
+		ResponseTypes: []string{"code id_token", "code token", "code id_token token"},
+	}
+}
@@ -23,7 +23,6 @@ package openid
 
 import (
 	"context"
-	"encoding/base64"
 
 	"github.com/pkg/errors"
 
@@ -41,6 +40,14 @@ type OpenIDConnectImplicitHandler struct {
 	RS256JWTStrategy *jwt.RS256JWTStrategy
 
 	MinParameterEntropy int
+
+	// AllowMissingNonce, if set to true, accepts requests without a nonce. Defaults to false, which rejects them with
+	// invalid_request as required by OpenID Connect Core 1.0 for ID Tokens returned from the authorization endpoint.
+	AllowMissingNonce bool
+
+	// IncludeStateHash, if set to true, adds the s_hash claim, protecting the integrity of the state, to ID Tokens of
+	// requests with a state. Defaults to false.
+	IncludeStateHash bool
 }
 
 func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
@@ -65,15 +72,17 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 	//}
 
 	if nonce := ar.GetRequestForm().Get("nonce"); len(nonce) == 0 {
-		return errors.WithStack(fosite.ErrInvalidRequest.WithHint("Parameter 'nonce' must be set when using the OpenID Connect Implicit Flow."))
+		if !c.AllowMissingNonce {
+			return errors.WithStack(fosite.ErrInvalidRequest.WithHint("Parameter 'nonce' must be set when using the OpenID Connect Implicit Flow.").WithParameter("nonce"))
+		}
 	} else if len(nonce) < c.MinParameterEntropy {
-		return errors.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", c.MinParameterEntropy))
+		return errors.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", c.MinParameterEntropy).WithParameter("nonce"))
 	}
 
 	client := ar.GetClient()
 	for _, scope := range ar.GetRequestedScopes() {
 		if !c.ScopeStrategy(client.GetScopes(), scope) {
-			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope))
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
 		}
 	}
 
@@ -93,16 +102,25 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 		}
 
 		ar.SetResponseTypeHandled("token")
-		hash, err := c.RS256JWTStrategy.Hash(ctx, []byte(resp.GetParameters().Get("access_token")))
+		hash, err := c.tokenHash(ctx, ar, resp.GetParameters().Get("access_token"), c.RS256JWTStrategy)
 		if err != nil {
 			return err
 		}
 
-		claims.AccessTokenHash = base64.RawURLEncoding.EncodeToString([]byte(hash[:c.RS256JWTStrategy.GetSigningMethodLength()/2]))
+		claims.AccessTokenHash = hash
 	} else {
 		resp.AddParameter("state", ar.GetState())
 	}
 
+	if state := ar.GetState(); c.IncludeStateHash && state != "" {
+		hash, err := c.tokenHash(ctx, ar, state, c.RS256JWTStrategy)
+		if err != nil {
+			return err
+		}
+
+		claims.StateHash = hash
+	}
+
 	if err := c.IssueImplicitIDToken(ctx, ar, resp); err != nil {
 		return errors.WithStack(err)
 	}
@@ -113,3 +131,11 @@ func (c *OpenIDConnectImplicitHandler) HandleAuthorizeEndpointRequest(ctx contex
 	ar.SetResponseTypeHandled("id_token")
 	return nil
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *OpenIDConnectImplicitHandler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{
+		GrantTypes:    []string{"implicit"},
+		ResponseTypes: []string{"id_token", "id_token token"},
+	}
+}
@@ -263,3 +263,73 @@ func TestImplicit_HandleAuthorizeEndpointRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestImplicit_HandleAuthorizeEndpointRequestRequiresNonce(t *testing.T) {
+	for k, c := range []struct {
+		description       string
+		form              url.Values
+		allowMissingNonce bool
+		expectErr         error
+	}{
+		{
+			description: "should fail because the nonce is missing",
+			form:        url.Values{},
+			expectErr:   fosite.ErrInvalidRequest,
+		},
+		{
+			description: "should fail because the nonce is empty",
+			form:        url.Values{"nonce": {""}},
+			expectErr:   fosite.ErrInvalidRequest,
+		},
+		{
+			description: "should pass because the nonce is set",
+			form:        url.Values{"nonce": {"some-random-foo-nonce-wow"}},
+		},
+		{
+			description:       "should pass without nonce because missing nonces are allowed",
+			form:              url.Values{},
+			allowMissingNonce: true,
+		},
+		{
+			description:       "should fail because the nonce is too short even though missing nonces are allowed",
+			form:              url.Values{"nonce": {"short"}},
+			allowMissingNonce: true,
+			expectErr:         fosite.ErrInsufficientEntropy,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			areq := fosite.NewAuthorizeRequest()
+			areq.Form = c.form
+			areq.ResponseTypes = fosite.Arguments{"id_token", "token"}
+			areq.RequestedScope = fosite.Arguments{"openid"}
+			areq.GrantedScope = fosite.Arguments{"openid"}
+			areq.State = "some-random-foo-state"
+			areq.Client = &fosite.DefaultClient{
+				GrantTypes:    fosite.Arguments{"implicit"},
+				ResponseTypes: fosite.Arguments{"id_token token"},
+				Scopes:        []string{"openid"},
+			}
+			areq.Session = &DefaultSession{
+				Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+				Headers: &jwt.Headers{},
+				Subject: "peter",
+			}
+			aresp := fosite.NewAuthorizeResponse()
+
+			h := makeOpenIDConnectImplicitHandler(fosite.MinParameterEntropy)
+			h.AllowMissingNonce = c.allowMissingNonce
+			err := h.HandleAuthorizeEndpointRequest(nil, areq, aresp)
+
+			if c.expectErr != nil {
+				assert.EqualError(t, err, c.expectErr.Error())
+				assert.Empty(t, aresp.GetParameters().Get("id_token"))
+				assert.Empty(t, aresp.GetParameters().Get("access_token"))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotEmpty(t, aresp.GetParameters().Get("id_token"))
+			assert.NotEmpty(t, aresp.GetParameters().Get("access_token"))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// OpenIDConnectNoneHandler validates OpenID Connect requests with response type "none", for example to check the
+// end-user's session and consent with "prompt=none" without issuing any credentials. The response itself, which only
+// contains the state, is created by fosite.
+type OpenIDConnectNoneHandler struct {
+	OpenIDConnectRequestValidator *OpenIDConnectRequestValidator
+}
+
+func (c *OpenIDConnectNoneHandler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
+	// The requested instead of the granted scopes are checked, as a request with "prompt=none" where the end-user did not
+	// consent to any scope must fail with "consent_required".
+	if !(ar.GetRequestedScopes().Has("openid") && ar.GetResponseTypes().ExactOne("none")) {
+		return nil
+	}
+
+	// If prompt is "none", the validator rejects the request with "login_required" or "consent_required" unless the
+	// end-user is authenticated and has consented to the requested scopes, see PromptNoneConsentPolicy.
+	return c.OpenIDConnectRequestValidator.ValidatePrompt(ctx, ar)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestNone_HandleAuthorizeEndpointRequest(t *testing.T) {
+	h := &OpenIDConnectNoneHandler{
+		OpenIDConnectRequestValidator: NewOpenIDConnectRequestValidator(nil, &jwt.RS256JWTStrategy{PrivateKey: key}),
+	}
+
+	for k, c := range []struct {
+		description   string
+		responseTypes fosite.Arguments
+		prompt        string
+		requested     fosite.Arguments
+		granted       fosite.Arguments
+		authTime      time.Time
+		expectErr     error
+	}{
+		{
+			description:   "should pass because not responsible for handling response type code",
+			responseTypes: fosite.Arguments{"code"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"openid"},
+		},
+		{
+			description:   "should pass because not responsible for handling non OpenID Connect requests",
+			responseTypes: fosite.Arguments{"none"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"foo"},
+		},
+		{
+			description:   "should pass because the end-user is authenticated and consented",
+			responseTypes: fosite.Arguments{"none"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"openid", "foo"},
+			granted:       fosite.Arguments{"openid", "foo"},
+			authTime:      time.Now().UTC().Add(-time.Hour),
+		},
+		{
+			description:   "should fail because the end-user did not consent to all scopes",
+			responseTypes: fosite.Arguments{"none"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"openid", "foo"},
+			granted:       fosite.Arguments{"openid"},
+			authTime:      time.Now().UTC().Add(-time.Hour),
+			expectErr:     fosite.ErrConsentRequired,
+		},
+		{
+			description:   "should fail because the end-user did not consent at all",
+			responseTypes: fosite.Arguments{"none"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"openid"},
+			authTime:      time.Now().UTC().Add(-time.Hour),
+			expectErr:     fosite.ErrConsentRequired,
+		},
+		{
+			description:   "should fail because the end-user authenticated during the request",
+			responseTypes: fosite.Arguments{"none"},
+			prompt:        "none",
+			requested:     fosite.Arguments{"openid"},
+			granted:       fosite.Arguments{"openid"},
+			authTime:      time.Now().UTC().Add(time.Second),
+			expectErr:     fosite.ErrLoginRequired,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			session := NewDefaultSession()
+			session.Claims.Subject = "peter"
+			session.Claims.AuthTime = c.authTime
+			session.Claims.RequestedAt = time.Now().UTC()
+
+			ar := fosite.NewAuthorizeRequest()
+			ar.ResponseTypes = c.responseTypes
+			ar.Form = url.Values{"prompt": {c.prompt}}
+			ar.Client = &fosite.DefaultClient{ID: "foo"}
+			ar.RequestedScope = c.requested
+			ar.GrantedScope = c.granted
+			ar.Session = session
+
+			err := h.HandleAuthorizeEndpointRequest(context.Background(), ar, fosite.NewAuthorizeResponse())
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Empty(t, ar.HandledResponseTypes)
+		})
+	}
+}
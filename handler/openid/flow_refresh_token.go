@@ -43,8 +43,8 @@ func (c *OpenIDConnectRefreshHandler) HandleTokenEndpointRequest(ctx context.Con
 		return errors.WithStack(fosite.ErrUnknownRequest)
 	}
 
-	if !request.GetClient().GetGrantTypes().Has("refresh_token") {
-		return errors.WithStack(fosite.ErrUnauthorizedClient.WithHint("The OAuth 2.0 Client is not allowed to use the authorization grant \"refresh_token\"."))
+	if err := fosite.ValidateClientGrantType(request.GetClient(), "refresh_token"); err != nil {
+		return err
 	}
 
 	// Refresh tokens can only be issued by an authorize_code which in turn disables the need to check if the id_token
@@ -65,6 +65,7 @@ func (c *OpenIDConnectRefreshHandler) HandleTokenEndpointRequest(ctx context.Con
 	sess.IDTokenClaims().JTI = ""
 	sess.IDTokenClaims().AccessTokenHash = ""
 	sess.IDTokenClaims().CodeHash = ""
+	sess.IDTokenClaims().StateHash = ""
 	return nil
 }
 
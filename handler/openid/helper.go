@@ -22,12 +22,12 @@
 package openid
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
+	"crypto"
 	"encoding/base64"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type IDTokenHandleHelper struct {
@@ -35,19 +35,48 @@ type IDTokenHandleHelper struct {
 }
 
 func (i *IDTokenHandleHelper) GetAccessTokenHash(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) string {
-	token := responder.GetAccessToken()
+	if alg := i.signingAlgorithm(requester); alg != "" {
+		if hash, err := jwt.TokenHashForAlgorithm(responder.GetAccessToken(), alg); err == nil {
+			return hash
+		}
+	}
 
-	buffer := bytes.NewBufferString(token)
-	hash := sha256.New()
-	// sha256.digest.Write() always returns nil for err, the panic should never happen
-	_, err := hash.Write(buffer.Bytes())
+	hash, err := jwt.TokenHash(responder.GetAccessToken(), crypto.SHA256)
+	// SHA-256 is always available and hashing never fails, the panic should never happen
 	if err != nil {
 		panic(err)
 	}
-	hashBuf := bytes.NewBuffer(hash.Sum([]byte{}))
-	len := hashBuf.Len()
 
-	return base64.RawURLEncoding.EncodeToString(hashBuf.Bytes()[:len/2])
+	return hash
+}
+
+// tokenHash computes the value of the at_hash and c_hash claims using the hash function of the algorithm the ID Token
+// is signed with. If the algorithm is not known, the hash function of the fallback strategy is used.
+func (i *IDTokenHandleHelper) tokenHash(ctx context.Context, requester fosite.Requester, value string, fallback jwt.JWTStrategy) (string, error) {
+	if alg := i.signingAlgorithm(requester); alg != "" {
+		return jwt.TokenHashForAlgorithm(value, alg)
+	}
+
+	hash, err := fallback.Hash(ctx, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(hash[:fallback.GetSigningMethodLength()/2]), nil
+}
+
+// signingAlgorithm returns the algorithm the ID Token issued for the request is signed with or an empty string if the
+// IDTokenStrategy does not report it. Errors are returned by the IDTokenStrategy when the ID Token is generated.
+func (i *IDTokenHandleHelper) signingAlgorithm(requester fosite.Requester) string {
+	s, ok := i.IDTokenStrategy.(IDTokenSigningAlgorithmStrategy)
+	if !ok {
+		return ""
+	}
+
+	alg, err := s.IDTokenSigningAlgorithm(requester.GetClient())
+	if err != nil {
+		return ""
+	}
+	return alg
 }
 
 func (i *IDTokenHandleHelper) generateIDToken(ctx context.Context, fosr fosite.Requester) (token string, err error) {
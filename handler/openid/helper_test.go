@@ -22,12 +22,20 @@
 package openid
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
 	"net/url"
 	"testing"
 
+	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
@@ -130,6 +138,7 @@ func TestGetAccessTokenHash(t *testing.T) {
 
 	defer ctrl.Finish()
 
+	req.EXPECT().GetClient().Return(&fosite.DefaultClient{})
 	resp.EXPECT().GetAccessToken().Return("7a35f818-9164-48cb-8c8f-e1217f44228431c41102-d410-4ed5-9276-07ba53dfdcd8")
 
 	h := &IDTokenHandleHelper{IDTokenStrategy: strat}
@@ -137,3 +146,183 @@ func TestGetAccessTokenHash(t *testing.T) {
 	hash := h.GetAccessTokenHash(nil, req, resp)
 	assert.Equal(t, "Zfn_XBitThuDJiETU3OALQ", hash)
 }
+
+func TestGetAccessTokenHashUsesClientSigningAlgorithm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	h := &IDTokenHandleHelper{IDTokenStrategy: &DefaultStrategy{JWTStrategy: &jwt.KeyRingJWTStrategy{KeyRing: &jwt.KeyRing{Keys: []jwt.Key{
+		{KeyID: "ec", Algorithm: "ES384", PrivateKey: ecKey},
+	}}}}}
+
+	req := fosite.NewAccessRequest(nil)
+	req.Client = &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}, IDTokenSignedResponseAlg: "ES384"}
+	resp := fosite.NewAccessResponse()
+	resp.SetAccessToken("7a35f818-9164-48cb-8c8f-e1217f44228431c41102-d410-4ed5-9276-07ba53dfdcd8")
+
+	expected, err := jwt.TokenHash(resp.GetAccessToken(), crypto.SHA384)
+	require.NoError(t, err)
+	assert.Equal(t, expected, h.GetAccessTokenHash(context.Background(), req, resp))
+}
+
+func TestFrontChannelIDTokenHashes(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	keyRingStrategy := &DefaultStrategy{JWTStrategy: &jwt.KeyRingJWTStrategy{KeyRing: &jwt.KeyRing{Keys: []jwt.Key{
+		{KeyID: "rs", Algorithm: "RS256", PrivateKey: internal.MustRSAKey()},
+		{KeyID: "ec", Algorithm: "ES384", PrivateKey: ecKey},
+	}}}}
+
+	for k, c := range []struct {
+		responseTypes fosite.Arguments
+		alg           string
+		hash          crypto.Hash
+		expectAtHash  bool
+		expectCHash   bool
+	}{
+		{responseTypes: fosite.Arguments{"id_token", "token"}, hash: crypto.SHA256, expectAtHash: true},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, hash: crypto.SHA256, expectCHash: true},
+		{responseTypes: fosite.Arguments{"code", "id_token", "token"}, hash: crypto.SHA256, expectAtHash: true, expectCHash: true},
+		{responseTypes: fosite.Arguments{"id_token", "token"}, alg: "ES384", hash: crypto.SHA384, expectAtHash: true},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, alg: "ES384", hash: crypto.SHA384, expectCHash: true},
+		{responseTypes: fosite.Arguments{"code", "id_token", "token"}, alg: "ES384", hash: crypto.SHA384, expectAtHash: true, expectCHash: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/response_type=%s/alg=%s", k, c.responseTypes, c.alg), func(t *testing.T) {
+			areq := fosite.NewAuthorizeRequest()
+			areq.Form = url.Values{"nonce": {"some-random-foo-nonce-wow"}}
+			areq.ResponseTypes = c.responseTypes
+			areq.RequestedScope = fosite.Arguments{"openid"}
+			areq.GrantedScope = fosite.Arguments{"openid"}
+			areq.State = "some-random-foo-state"
+			areq.Client = &fosite.DefaultOpenIDConnectClient{
+				DefaultClient: &fosite.DefaultClient{
+					ID:            "foo",
+					GrantTypes:    fosite.Arguments{"authorization_code", "implicit"},
+					ResponseTypes: fosite.Arguments{"id_token token", "code id_token", "code id_token token"},
+					Scopes:        []string{"openid"},
+				},
+				IDTokenSignedResponseAlg: c.alg,
+			}
+			areq.Session = &DefaultSession{
+				Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+				Headers: &jwt.Headers{},
+				Subject: "peter",
+			}
+			aresp := fosite.NewAuthorizeResponse()
+
+			if c.responseTypes.Has("code") {
+				h := makeOpenIDConnectHybridHandler(fosite.MinParameterEntropy)
+				if c.alg != "" {
+					h.IDTokenHandleHelper = &IDTokenHandleHelper{IDTokenStrategy: keyRingStrategy}
+				}
+				require.NoError(t, h.HandleAuthorizeEndpointRequest(context.Background(), areq, aresp))
+			} else {
+				h := makeOpenIDConnectImplicitHandler(fosite.MinParameterEntropy)
+				if c.alg != "" {
+					h.IDTokenHandleHelper = &IDTokenHandleHelper{IDTokenStrategy: keyRingStrategy}
+				}
+				require.NoError(t, h.HandleAuthorizeEndpointRequest(context.Background(), areq, aresp))
+			}
+
+			claims := jwtgo.MapClaims{}
+			token, _, err := new(jwtgo.Parser).ParseUnverified(aresp.GetParameters().Get("id_token"), claims)
+			require.NoError(t, err)
+			if c.alg != "" {
+				assert.Equal(t, c.alg, token.Header["alg"])
+			}
+
+			if c.expectAtHash {
+				accessToken := aresp.GetParameters().Get("access_token")
+				require.NotEmpty(t, accessToken)
+				expected, err := jwt.TokenHash(accessToken, c.hash)
+				require.NoError(t, err)
+				assert.Equal(t, expected, claims["at_hash"])
+			} else {
+				assert.Empty(t, claims["at_hash"])
+			}
+
+			if c.expectCHash {
+				code := aresp.GetParameters().Get("code")
+				require.NotEmpty(t, code)
+				expected, err := jwt.TokenHash(code, c.hash)
+				require.NoError(t, err)
+				assert.Equal(t, expected, claims["c_hash"])
+			} else {
+				assert.Empty(t, claims["c_hash"])
+			}
+		})
+	}
+}
+
+func TestFrontChannelIDTokenStateHash(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	keyRingStrategy := &DefaultStrategy{JWTStrategy: &jwt.KeyRingJWTStrategy{KeyRing: &jwt.KeyRing{Keys: []jwt.Key{
+		{KeyID: "ec", Algorithm: "ES384", PrivateKey: ecKey},
+	}}}}
+
+	for k, c := range []struct {
+		responseTypes    fosite.Arguments
+		alg              string
+		state            string
+		includeStateHash bool
+		expected         string
+	}{
+		{responseTypes: fosite.Arguments{"id_token"}, state: "some-random-foo-state", includeStateHash: true, expected: "5v7MsM-gBA0zHFvIjmaPPg"},
+		{responseTypes: fosite.Arguments{"id_token", "token"}, state: "some-random-foo-state", includeStateHash: true, expected: "5v7MsM-gBA0zHFvIjmaPPg"},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, state: "some-random-foo-state", includeStateHash: true, expected: "5v7MsM-gBA0zHFvIjmaPPg"},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, alg: "ES384", state: "some-random-foo-state", includeStateHash: true, expected: "09ZsCSK0Hi5HywAh5j-KgM9RBsPayhIo"},
+		{responseTypes: fosite.Arguments{"id_token", "token"}, alg: "ES384", state: "some-random-foo-state", includeStateHash: true, expected: "09ZsCSK0Hi5HywAh5j-KgM9RBsPayhIo"},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, state: "some-random-foo-state"},
+		{responseTypes: fosite.Arguments{"id_token", "token"}, state: "some-random-foo-state"},
+		{responseTypes: fosite.Arguments{"code", "id_token"}, includeStateHash: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/response_type=%s/alg=%s/include=%t", k, c.responseTypes, c.alg, c.includeStateHash), func(t *testing.T) {
+			areq := fosite.NewAuthorizeRequest()
+			areq.Form = url.Values{"nonce": {"some-random-foo-nonce-wow"}}
+			areq.ResponseTypes = c.responseTypes
+			areq.RequestedScope = fosite.Arguments{"openid"}
+			areq.GrantedScope = fosite.Arguments{"openid"}
+			areq.State = c.state
+			areq.Client = &fosite.DefaultOpenIDConnectClient{
+				DefaultClient: &fosite.DefaultClient{
+					ID:         "foo",
+					GrantTypes: fosite.Arguments{"authorization_code", "implicit"},
+					Scopes:     []string{"openid"},
+				},
+				IDTokenSignedResponseAlg: c.alg,
+			}
+			areq.Session = &DefaultSession{
+				Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+				Headers: &jwt.Headers{},
+				Subject: "peter",
+			}
+			aresp := fosite.NewAuthorizeResponse()
+
+			if c.responseTypes.Has("code") {
+				h := makeOpenIDConnectHybridHandler(fosite.MinParameterEntropy)
+				h.IncludeStateHash = c.includeStateHash
+				if c.alg != "" {
+					h.IDTokenHandleHelper = &IDTokenHandleHelper{IDTokenStrategy: keyRingStrategy}
+				}
+				require.NoError(t, h.HandleAuthorizeEndpointRequest(context.Background(), areq, aresp))
+			} else {
+				h := makeOpenIDConnectImplicitHandler(fosite.MinParameterEntropy)
+				h.IncludeStateHash = c.includeStateHash
+				if c.alg != "" {
+					h.IDTokenHandleHelper = &IDTokenHandleHelper{IDTokenStrategy: keyRingStrategy}
+				}
+				require.NoError(t, h.HandleAuthorizeEndpointRequest(context.Background(), areq, aresp))
+			}
+
+			claims := jwtgo.MapClaims{}
+			_, _, err := new(jwtgo.Parser).ParseUnverified(aresp.GetParameters().Get("id_token"), claims)
+			require.NoError(t, err)
+			if c.expected == "" {
+				assert.NotContains(t, claims, "s_hash")
+			} else {
+				assert.Equal(t, c.expected, claims["s_hash"])
+			}
+		})
+	}
+}
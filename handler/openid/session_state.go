@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// DefaultBrowserStateCookieName is the default name of the cookie holding the OP browser state.
+const DefaultBrowserStateCookieName = "opbs"
+
+// SessionStateStrategy adds the "session_state" parameter defined by OpenID Connect Session Management 1.0 to
+// authorization responses of OpenID Connect requests. Relying parties use it together with the OP's
+// check_session_iframe to detect changes of the end-user's login status.
+//
+// The OP browser state is read from a cookie of the HTTP request, which must be attached to the context passed to
+// NewAuthorizeResponse using fosite.WithHTTPRequest. If the request or cookie is missing, no session_state is added.
+type SessionStateStrategy struct {
+	// BrowserStateCookieName is the name of the cookie holding the OP browser state. Defaults to DefaultBrowserStateCookieName.
+	BrowserStateCookieName string
+
+	// Origin returns the origin of the relying party. Defaults to RedirectURIOrigin.
+	Origin func(ar fosite.AuthorizeRequester) string
+}
+
+// ComputeSessionState computes the session_state as defined in
+// https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions:
+//
+//	hex(SHA-256(client_id + " " + origin + " " + browser_state + " " + salt)) + "." + salt
+func ComputeSessionState(clientID, origin, browserState, salt string) string {
+	hash := sha256.Sum256([]byte(clientID + " " + origin + " " + browserState + " " + salt))
+	return hex.EncodeToString(hash[:]) + "." + salt
+}
+
+// RedirectURIOrigin returns the origin (scheme, host and port) of the authorization request's redirect URI.
+func RedirectURIOrigin(ar fosite.AuthorizeRequester) string {
+	redirectURI := ar.GetRedirectURI()
+	if redirectURI == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s://%s", redirectURI.Scheme, redirectURI.Host)
+}
+
+func (s *SessionStateStrategy) browserStateCookieName() string {
+	if s.BrowserStateCookieName == "" {
+		return DefaultBrowserStateCookieName
+	}
+	return s.BrowserStateCookieName
+}
+
+func (s *SessionStateStrategy) origin(ar fosite.AuthorizeRequester) string {
+	if s.Origin == nil {
+		return RedirectURIOrigin(ar)
+	}
+	return s.Origin(ar)
+}
+
+// AuthorizeResponseHook implements fosite.AuthorizeResponseHook and adds the session_state to authorization responses
+// of OpenID Connect requests.
+func (s *SessionStateStrategy) AuthorizeResponseHook(ctx context.Context, ar fosite.AuthorizeRequester, resp fosite.AuthorizeResponder) error {
+	if !ar.GetGrantedScopes().Has("openid") {
+		return nil
+	}
+
+	r, ok := fosite.HTTPRequestFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	cookie, err := r.Cookie(s.browserStateCookieName())
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	resp.AddParameter("session_state", ComputeSessionState(ar.GetClient().GetID(), s.origin(ar), cookie.Value, hex.EncodeToString(salt)))
+	return nil
+}
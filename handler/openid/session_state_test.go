@@ -0,0 +1,108 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestComputeSessionState(t *testing.T) {
+	// hex(SHA-256("my-client https://client.example.org:8443 some-browser-state some-salt")) + "." + salt
+	assert.Equal(t,
+		"cc0c0d1f9b414bd2588dc2d991e8fa10a98ea5e4f6938fd29521731c062bc65e.some-salt",
+		ComputeSessionState("my-client", "https://client.example.org:8443", "some-browser-state", "some-salt"),
+	)
+	assert.Equal(t,
+		"f293dba335d9ac556e0309ddbffc29dea7bfadac20fa4aa0c19ace1f41a00eed.other-salt",
+		ComputeSessionState("my-client", "https://client.example.org:8443", "some-browser-state", "other-salt"),
+	)
+}
+
+func TestSessionStateStrategy(t *testing.T) {
+	newRequest := func(scopes ...string) *fosite.AuthorizeRequest {
+		ar := fosite.NewAuthorizeRequest()
+		ar.Client = &fosite.DefaultClient{ID: "my-client"}
+		ar.RedirectURI, _ = url.Parse("https://client.example.org:8443/cb?foo=bar")
+		ar.GrantedScope = scopes
+		return ar
+	}
+
+	r := &http.Request{Header: http.Header{}}
+	r.AddCookie(&http.Cookie{Name: DefaultBrowserStateCookieName, Value: "some-browser-state"})
+	ctx := fosite.WithHTTPRequest(context.Background(), r)
+
+	t.Run("case=adds a verifiable session_state", func(t *testing.T) {
+		s := &SessionStateStrategy{}
+		resp := fosite.NewAuthorizeResponse()
+		require.NoError(t, s.AuthorizeResponseHook(ctx, newRequest("openid"), resp))
+
+		sessionState := resp.GetParameters().Get("session_state")
+		parts := strings.Split(sessionState, ".")
+		require.Len(t, parts, 2)
+		assert.Equal(t, ComputeSessionState("my-client", "https://client.example.org:8443", "some-browser-state", parts[1]), sessionState)
+	})
+
+	t.Run("case=uses a new salt for every response", func(t *testing.T) {
+		s := &SessionStateStrategy{}
+		first, second := fosite.NewAuthorizeResponse(), fosite.NewAuthorizeResponse()
+		require.NoError(t, s.AuthorizeResponseHook(ctx, newRequest("openid"), first))
+		require.NoError(t, s.AuthorizeResponseHook(ctx, newRequest("openid"), second))
+		assert.NotEqual(t, first.GetParameters().Get("session_state"), second.GetParameters().Get("session_state"))
+	})
+
+	t.Run("case=uses the configured cookie name and origin", func(t *testing.T) {
+		r := &http.Request{Header: http.Header{}}
+		r.AddCookie(&http.Cookie{Name: "custom", Value: "custom-browser-state"})
+
+		s := &SessionStateStrategy{
+			BrowserStateCookieName: "custom",
+			Origin:                 func(fosite.AuthorizeRequester) string { return "https://origin.example.org" },
+		}
+		resp := fosite.NewAuthorizeResponse()
+		require.NoError(t, s.AuthorizeResponseHook(fosite.WithHTTPRequest(context.Background(), r), newRequest("openid"), resp))
+
+		sessionState := resp.GetParameters().Get("session_state")
+		salt := sessionState[strings.Index(sessionState, ".")+1:]
+		assert.Equal(t, ComputeSessionState("my-client", "https://origin.example.org", "custom-browser-state", salt), sessionState)
+	})
+
+	t.Run("case=skips non OpenID Connect requests", func(t *testing.T) {
+		resp := fosite.NewAuthorizeResponse()
+		require.NoError(t, new(SessionStateStrategy).AuthorizeResponseHook(ctx, newRequest("foo"), resp))
+		assert.Empty(t, resp.GetParameters().Get("session_state"))
+	})
+
+	t.Run("case=skips requests without browser state", func(t *testing.T) {
+		resp := fosite.NewAuthorizeResponse()
+		require.NoError(t, new(SessionStateStrategy).AuthorizeResponseHook(context.Background(), newRequest("openid"), resp))
+		assert.Empty(t, resp.GetParameters().Get("session_state"))
+	})
+}
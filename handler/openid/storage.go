@@ -23,6 +23,7 @@ package openid
 
 import (
 	"context"
+	"time"
 
 	"github.com/ory/fosite"
 )
@@ -43,3 +44,11 @@ type OpenIDConnectRequestStorage interface {
 	// DeleteOpenIDConnectSession removes an open id connect session from the store.
 	DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error
 }
+
+// NonceReplayStorage keeps track of the nonces used to mint ID Tokens at the token endpoint.
+type NonceReplayStorage interface {
+	// SetNonceUsed marks the nonce of the client as used by the given authorization code until the given expiry time.
+	// It returns fosite.ErrNonceAlreadyUsed if the nonce has already been used and has not expired yet, either by the
+	// same or by another authorization code. Expired nonces may be cleaned up.
+	SetNonceUsed(ctx context.Context, clientID string, nonce string, authorizeCode string, exp time.Time) error
+}
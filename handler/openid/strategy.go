@@ -30,3 +30,13 @@ import (
 type OpenIDConnectTokenStrategy interface {
 	GenerateIDToken(ctx context.Context, requester fosite.Requester) (token string, err error)
 }
+
+// IDTokenSigningAlgorithmStrategy is implemented by OpenIDConnectTokenStrategy implementations which sign ID Tokens
+// with an algorithm depending on the client, see DefaultStrategy.IDTokenSigningAlgorithm.
+type IDTokenSigningAlgorithmStrategy interface {
+	IDTokenSigningAlgorithm(client fosite.Client) (string, error)
+}
+
+type LogoutTokenStrategy interface {
+	GenerateLogoutToken(ctx context.Context, client fosite.Client, subject, sessionID string) (token string, err error)
+}
@@ -55,6 +55,10 @@ type DefaultSession struct {
 	ExpiresAt map[fosite.TokenType]time.Time
 	Username  string
 	Subject   string
+
+	CertificateThumbprint string
+	DPoPKeyThumbprint     string
+	GrantType             string
 }
 
 func NewDefaultSession() *DefaultSession {
@@ -66,6 +70,42 @@ func NewDefaultSession() *DefaultSession {
 	}
 }
 
+func (s *DefaultSession) SetCertificateThumbprint(thumbprint string) {
+	s.CertificateThumbprint = thumbprint
+}
+
+func (s *DefaultSession) GetCertificateThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.CertificateThumbprint
+}
+
+func (s *DefaultSession) SetDPoPKeyThumbprint(thumbprint string) {
+	s.DPoPKeyThumbprint = thumbprint
+}
+
+func (s *DefaultSession) GetDPoPKeyThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.DPoPKeyThumbprint
+}
+
+func (s *DefaultSession) SetGrantType(grantType string) {
+	s.GrantType = grantType
+}
+
+func (s *DefaultSession) GetGrantType() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.GrantType
+}
+
 func (s *DefaultSession) Clone() fosite.Session {
 	if s == nil {
 		return nil
@@ -127,7 +167,37 @@ type DefaultStrategy struct {
 	Expiry time.Duration
 	Issuer string
 
+	// IssuerFromRequest, if set, resolves the "iss" claim of ID Tokens and Logout Tokens from the HTTP request attached
+	// to the context with fosite.WithHTTPRequest. If it returns an empty string, Issuer is used.
+	IssuerFromRequest fosite.IssuerFromRequest
+
 	MinParameterEntropy int
+
+	// ScopeClaim, if set, is the name of the claim under which the granted scopes are added to the ID Token as an array.
+	// Only scopes which were requested by the client are included. Defaults to "", which does not add the scopes, as
+	// OpenID Connect does not define such a claim.
+	ScopeClaim string
+
+	// SubjectIdentifierAlgorithms maps subject types, for example fosite.PairwiseSubjectType, to the algorithm computing
+	// the "sub" claim for clients of that subject type. Clients of subject type fosite.PublicSubjectType receive the
+	// local subject if no algorithm is set for it.
+	SubjectIdentifierAlgorithms map[string]SubjectIdentifierAlgorithm
+
+	// StrictAudience, if set to true, ensures that the "aud" claim of ID Tokens contains exactly the client ID and that
+	// the "azp" claim, if set, is the client ID. Sessions setting a different audience or authorized party are rejected
+	// with a server error. Defaults to false, which adds the client ID to the audience of the session.
+	StrictAudience bool
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
+
+	// IDGenerator generates the "jti" claim of Logout Tokens and of ID Tokens whose session does not set one. Defaults
+	// to random UUIDs.
+	IDGenerator fosite.IDGenerator
+
+	// ClaimsEnrichmentHook, if set, returns additional claims for ID Tokens. It can not replace claims which are
+	// already set, nor set the fosite.ProtectedClaims and the "nonce" claim.
+	ClaimsEnrichmentHook fosite.ClaimsEnrichmentHook
 }
 
 func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.Requester) (token string, err error) {
@@ -145,6 +215,11 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		return "", errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because subject is an empty string."))
 	}
 
+	subject, err := ObfuscateSubject(h.SubjectIdentifierAlgorithms, claims.Subject, requester.GetClient())
+	if err != nil {
+		return "", err
+	}
+
 	if requester.GetRequestForm().Get("grant_type") != "refresh_token" {
 		maxAge, err := strconv.ParseInt(requester.GetRequestForm().Get("max_age"), 10, 64)
 		if err != nil {
@@ -152,7 +227,7 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		}
 
 		// Adds a bit of wiggle room for timing issues
-		if claims.AuthTime.After(time.Now().UTC().Add(time.Second * 5)) {
+		if claims.AuthTime.After(h.Clock.Now().Add(time.Second * 5)) {
 			return "", errors.WithStack(fosite.ErrServerError.WithDebug("Failed to validate OpenID Connect request because authentication time is in the future."))
 		}
 
@@ -203,26 +278,26 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 				return "", errors.WithStack(fosite.ErrServerError.WithDebug("Unable to decode id token from 'id_token_hint' to *jwt.StandardClaims."))
 			} else if hintSub, _ := hintClaims["sub"].(string); hintSub == "" {
 				return "", errors.WithStack(fosite.ErrServerError.WithDebug("Provided id token from 'id_token_hint' does not have a subject."))
-			} else if hintSub != claims.Subject {
+			} else if hintSub != subject {
 				return "", errors.WithStack(fosite.ErrServerError.WithDebug("Subject from authorization mismatches id token subject from 'id_token_hint'."))
 			}
 		}
 	}
 
 	if claims.ExpiresAt.IsZero() {
-		claims.ExpiresAt = time.Now().UTC().Add(h.Expiry)
+		claims.ExpiresAt = h.Clock.Now().Add(h.Expiry)
 	}
 
-	if claims.ExpiresAt.Before(time.Now().UTC()) {
+	if claims.ExpiresAt.Before(h.Clock.Now()) {
 		return "", errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because expiry claim can not be in the past."))
 	}
 
 	if claims.AuthTime.IsZero() {
-		claims.AuthTime = time.Now().UTC()
+		claims.AuthTime = h.Clock.Now()
 	}
 
 	if claims.Issuer == "" {
-		claims.Issuer = h.Issuer
+		claims.Issuer = fosite.ResolveIssuer(ctx, h.IssuerFromRequest, h.Issuer)
 	}
 
 	nonce := requester.GetRequestForm().Get("nonce")
@@ -233,10 +308,94 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		return "", errors.WithStack(fosite.ErrInsufficientEntropy.WithHintf("Parameter 'nonce' is set but does not satisfy the minimum entropy of %d characters.", h.MinParameterEntropy))
 	}
 
+	if h.StrictAudience {
+		if err := validateStrictAudience(claims, requester.GetClient().GetID()); err != nil {
+			return "", err
+		}
+	}
+
 	claims.Nonce = nonce
 	claims.Audience = stringslice.Unique(append(claims.Audience, requester.GetClient().GetID()))
-	claims.IssuedAt = time.Now().UTC()
+	claims.IssuedAt = h.Clock.Now()
+
+	mapClaims := claims.ToMapClaims()
+	if claims.JTI == "" {
+		mapClaims["jti"] = h.IDGenerator.New()
+	}
+	if subject != claims.Subject {
+		mapClaims["sub"] = subject
+	}
+	if h.ScopeClaim != "" {
+		// Standard and custom claims of the session take precedence over the scope claim.
+		if _, ok := mapClaims[h.ScopeClaim]; !ok {
+			scopes := []string{}
+			for _, scope := range requester.GetGrantedScopes() {
+				if requester.GetRequestedScopes().Has(scope) {
+					scopes = append(scopes, scope)
+				}
+			}
+			mapClaims[h.ScopeClaim] = scopes
+		}
+	}
+
+	if err := h.ClaimsEnrichmentHook.Enrich(ctx, fosite.IDToken, requester, mapClaims, "nonce"); err != nil {
+		return "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	alg, err := h.IDTokenSigningAlgorithm(requester.GetClient())
+	if err != nil {
+		return "", err
+	}
+
+	// The headers of the session are copied so that the algorithm of this client is not stored in the session.
+	headers := &jwt.Headers{Extra: map[string]interface{}{}}
+	for k, v := range sess.IDTokenHeaders().Extra {
+		headers.Add(k, v)
+	}
+	if alg != "" {
+		headers.Add("alg", alg)
+	}
 
-	token, _, err = h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), sess.IDTokenHeaders())
+	token, _, err = h.JWTStrategy.Generate(ctx, mapClaims, headers)
 	return token, err
 }
+
+// IDTokenSigningAlgorithm returns the algorithm ID Tokens issued to the client are signed with, which is the client's
+// "id_token_signed_response_alg" or, if not set, the algorithm of the JWTStrategy. It returns an error if the
+// JWTStrategy can not sign tokens with the algorithm the client requested. If the JWTStrategy does not implement
+// jwt.SigningAlgorithmStrategy and the client does not request an algorithm, the algorithm is empty.
+func (h DefaultStrategy) IDTokenSigningAlgorithm(client fosite.Client) (string, error) {
+	var requested string
+	if c, ok := client.(fosite.IDTokenSigningAlgorithmClient); ok {
+		requested = c.GetIDTokenSignedResponseAlg()
+	}
+
+	s, ok := h.JWTStrategy.(jwt.SigningAlgorithmStrategy)
+	if !ok {
+		if requested != "" {
+			return "", errors.WithStack(fosite.ErrInvalidClient.WithHintf("The OAuth 2.0 Client requested ID Tokens signed with algorithm '%s' which is not supported by this authorization server.", requested))
+		}
+		return "", nil
+	} else if requested == "" {
+		return s.GetSigningAlgorithm(), nil
+	}
+
+	if err := s.ValidateSigningAlgorithm(requested); err != nil {
+		return "", errors.WithStack(fosite.ErrInvalidClient.WithHintf("The OAuth 2.0 Client requested ID Tokens signed with algorithm '%s' which is not supported by this authorization server.", requested).WithCause(err).WithDebug(err.Error()))
+	}
+	return requested, nil
+}
+
+func validateStrictAudience(claims *jwt.IDTokenClaims, clientID string) error {
+	for _, aud := range claims.Audience {
+		if aud != clientID {
+			return errors.WithStack(fosite.ErrServerError.WithDebugf("Failed to generate id token because the session sets audience '%s' but the audience must only contain the client ID '%s'.", aud, clientID))
+		}
+	}
+
+	if azp, ok := claims.Extra["azp"]; ok && azp != clientID {
+		return errors.WithStack(fosite.ErrServerError.WithDebugf("Failed to generate id token because the session sets authorized party '%v' but it must be the client ID '%s'.", azp, clientID))
+	}
+
+	return nil
+}
@@ -23,11 +23,17 @@ package openid
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	jwtgo "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/jwt"
@@ -298,3 +304,231 @@ func TestJWTStrategy_GenerateIDToken(t *testing.T) {
 		})
 	}
 }
+
+func TestJWTStrategy_GenerateIDTokenScopeClaim(t *testing.T) {
+	newRequest := func(extra map[string]interface{}) *fosite.AccessRequest {
+		req := fosite.NewAccessRequest(&DefaultSession{
+			Claims:  &jwt.IDTokenClaims{Subject: "peter", Extra: extra},
+			Headers: &jwt.Headers{},
+		})
+		req.Client = &fosite.DefaultClient{ID: "foo"}
+		req.RequestedScope = fosite.Arguments{"openid", "email"}
+		req.GrantScope("openid")
+		req.GrantScope("email")
+		// Granted by the server but not requested by the client.
+		req.GrantScope("internal")
+		return req
+	}
+
+	decode := func(t *testing.T, j *DefaultStrategy, req fosite.Requester) map[string]interface{} {
+		token, err := j.GenerateIDToken(context.Background(), req)
+		require.NoError(t, err)
+		decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+		require.NoError(t, err)
+		return decoded.Claims.(jwtgo.MapClaims)
+	}
+
+	t.Run("case=scopes are not emitted by default", func(t *testing.T) {
+		j := &DefaultStrategy{JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key}}
+		claims := decode(t, j, newRequest(nil))
+		assert.NotContains(t, claims, "scope")
+		assert.NotContains(t, claims, "scp")
+	})
+
+	t.Run("case=requested and granted scopes are emitted under the configured claim", func(t *testing.T) {
+		j := &DefaultStrategy{JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key}, ScopeClaim: "scp"}
+		claims := decode(t, j, newRequest(nil))
+		assert.Equal(t, []interface{}{"openid", "email"}, claims["scp"])
+	})
+
+	t.Run("case=standard and custom claims are not overwritten", func(t *testing.T) {
+		j := &DefaultStrategy{JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key}, ScopeClaim: "sub"}
+		assert.Equal(t, "peter", decode(t, j, newRequest(nil))["sub"])
+
+		j.ScopeClaim = "scope"
+		assert.Equal(t, "custom", decode(t, j, newRequest(map[string]interface{}{"scope": "custom"}))["scope"])
+	})
+}
+
+func TestJWTStrategy_GenerateIDTokenStrictAudience(t *testing.T) {
+	for k, c := range []struct {
+		d         string
+		strict    bool
+		audience  []string
+		extra     map[string]interface{}
+		expectErr bool
+		expectAud []interface{}
+	}{
+		{
+			d:         "should pass in strict mode because the audience is empty",
+			strict:    true,
+			expectAud: []interface{}{"foo"},
+		},
+		{
+			d:         "should pass in strict mode because the audience is the client ID",
+			strict:    true,
+			audience:  []string{"foo"},
+			extra:     map[string]interface{}{"azp": "foo"},
+			expectAud: []interface{}{"foo"},
+		},
+		{
+			d:         "should fail in strict mode because the session sets a different audience",
+			strict:    true,
+			audience:  []string{"https://api.example.com"},
+			expectErr: true,
+		},
+		{
+			d:         "should fail in strict mode because the session sets a different authorized party",
+			strict:    true,
+			extra:     map[string]interface{}{"azp": "bar"},
+			expectErr: true,
+		},
+		{
+			d:         "should pass in lenient mode and add the client ID to the audience",
+			audience:  []string{"https://api.example.com"},
+			extra:     map[string]interface{}{"azp": "bar"},
+			expectAud: []interface{}{"https://api.example.com", "foo"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			j := &DefaultStrategy{JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key}, StrictAudience: c.strict}
+			req := fosite.NewAccessRequest(&DefaultSession{
+				Claims:  &jwt.IDTokenClaims{Subject: "peter", Audience: c.audience, Extra: c.extra},
+				Headers: &jwt.Headers{},
+			})
+			req.Client = &fosite.DefaultClient{ID: "foo"}
+
+			token, err := j.GenerateIDToken(context.Background(), req)
+			if c.expectErr {
+				require.EqualError(t, err, fosite.ErrServerError.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, c.expectAud, decoded.Claims.(jwtgo.MapClaims)["aud"])
+		})
+	}
+}
+
+func TestJWTStrategy_GenerateIDTokenClientSigningAlgorithm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ring := &jwt.KeyRing{Keys: []jwt.Key{
+		{KeyID: "rsa", Algorithm: "RS256", Active: true, PrivateKey: key},
+		{KeyID: "ec", Algorithm: "ES256", Active: true, PrivateKey: ecKey},
+	}}
+
+	for k, c := range []struct {
+		d         string
+		strategy  jwt.JWTStrategy
+		alg       string
+		expectErr error
+		expectAlg string
+		expectKid string
+	}{
+		{
+			d:         "should sign with the default algorithm because the client does not request one",
+			strategy:  &jwt.KeyRingJWTStrategy{KeyRing: ring},
+			expectAlg: "RS256",
+			expectKid: "rsa",
+		},
+		{
+			d:         "should sign with the algorithm requested by the client",
+			strategy:  &jwt.KeyRingJWTStrategy{KeyRing: ring},
+			alg:       "ES256",
+			expectAlg: "ES256",
+			expectKid: "ec",
+		},
+		{
+			d:         "should fail because the key ring does not contain a key for the requested algorithm",
+			strategy:  &jwt.KeyRingJWTStrategy{KeyRing: ring},
+			alg:       "ES512",
+			expectErr: fosite.ErrInvalidClient,
+		},
+		{
+			d:         "should fail because the strategy only signs with RS256",
+			strategy:  &jwt.RS256JWTStrategy{PrivateKey: key},
+			alg:       "ES256",
+			expectErr: fosite.ErrInvalidClient,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			j := &DefaultStrategy{JWTStrategy: c.strategy}
+			req := fosite.NewAccessRequest(&DefaultSession{
+				Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+				Headers: &jwt.Headers{},
+			})
+			req.Client = &fosite.DefaultOpenIDConnectClient{
+				DefaultClient:            &fosite.DefaultClient{ID: "foo"},
+				IDTokenSignedResponseAlg: c.alg,
+			}
+
+			token, err := j.GenerateIDToken(context.Background(), req)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, c.expectAlg, decoded.Header["alg"])
+			assert.Equal(t, c.expectKid, decoded.Header["kid"])
+
+			// The algorithm of the client is not stored in the session.
+			assert.Empty(t, req.GetSession().(*DefaultSession).Headers.Extra)
+		})
+	}
+}
+
+func TestJWTStrategy_GenerateIDTokenIDGenerator(t *testing.T) {
+	j := &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key},
+		IDGenerator: func() string { return "01ARZ3NDEKTSV4RRFFQ69G5FAV" },
+	}
+	req := fosite.NewAccessRequest(&DefaultSession{
+		Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+		Headers: &jwt.Headers{},
+	})
+	req.Client = &fosite.DefaultClient{ID: "foo"}
+
+	token, err := j.GenerateIDToken(context.Background(), req)
+	require.NoError(t, err)
+	decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", decoded.Claims.(jwtgo.MapClaims)["jti"])
+}
+
+func TestJWTStrategy_GenerateIDTokenClaimsEnrichmentHook(t *testing.T) {
+	j := &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key},
+		ClaimsEnrichmentHook: func(_ context.Context, tokenType fosite.TokenType, requester fosite.Requester) (map[string]interface{}, error) {
+			assert.Equal(t, fosite.IDToken, tokenType)
+			return map[string]interface{}{"tenant_id": "tenant-" + requester.GetClient().GetID(), "sub": "mallory", "nonce": "forged"}, nil
+		},
+	}
+	req := fosite.NewAccessRequest(&DefaultSession{
+		Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+		Headers: &jwt.Headers{},
+	})
+	req.Client = &fosite.DefaultClient{ID: "foo"}
+	req.Form.Set("nonce", "some-secure-nonce-state")
+
+	token, err := j.GenerateIDToken(context.Background(), req)
+	require.NoError(t, err)
+	decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+	require.NoError(t, err)
+	claims := decoded.Claims.(jwtgo.MapClaims)
+	assert.Equal(t, "tenant-foo", claims["tenant_id"])
+	assert.Equal(t, "peter", claims["sub"])
+	assert.Equal(t, "some-secure-nonce-state", claims["nonce"])
+
+	j.ClaimsEnrichmentHook = func(context.Context, fosite.TokenType, fosite.Requester) (map[string]interface{}, error) {
+		return nil, errors.New("tenant unknown")
+	}
+	_, err = j.GenerateIDToken(context.Background(), req)
+	assert.EqualError(t, err, fosite.ErrServerError.Error())
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// GenerateLogoutToken generates a Logout Token for OpenID Connect Back-Channel Logout. The sid claim is only included
+// if the client requires it by setting backchannel_logout_session_required, otherwise the logout is identified by the
+// sub claim alone.
+func (h DefaultStrategy) GenerateLogoutToken(ctx context.Context, client fosite.Client, subject, sessionID string) (token string, err error) {
+	if subject != "" {
+		if subject, err = ObfuscateSubject(h.SubjectIdentifierAlgorithms, subject, client); err != nil {
+			return "", err
+		}
+	}
+
+	claims := &jwt.LogoutTokenClaims{
+		JTI:      h.IDGenerator.New(),
+		Issuer:   fosite.ResolveIssuer(ctx, h.IssuerFromRequest, h.Issuer),
+		Subject:  subject,
+		Audience: []string{client.GetID()},
+		IssuedAt: h.Clock.Now(),
+	}
+
+	if c, ok := client.(fosite.BackChannelLogoutClient); ok && c.GetBackChannelLogoutSessionRequired() {
+		if sessionID == "" {
+			return "", errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate logout token because the client requires a session ID but none was given."))
+		}
+		claims.SessionID = sessionID
+	} else if subject == "" {
+		return "", errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate logout token because subject is an empty string."))
+	}
+
+	token, _, err = h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), jwt.NewHeaders())
+	return token, err
+}
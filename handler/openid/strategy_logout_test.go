@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestJWTStrategy_GenerateLogoutToken(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		Issuer: "https://fosite.example/",
+	}
+
+	for k, c := range []struct {
+		description     string
+		client          fosite.Client
+		subject         string
+		sessionID       string
+		expectErr       bool
+		expectSessionID bool
+	}{
+		{
+			description:     "should include sid because the client requires it",
+			client:          &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}, BackChannelLogoutSessionRequired: true},
+			subject:         "peter",
+			sessionID:       "session-id",
+			expectSessionID: true,
+		},
+		{
+			description: "should omit sid because the client does not require it",
+			client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}},
+			subject:     "peter",
+			sessionID:   "session-id",
+		},
+		{
+			description: "should omit sid for clients without back-channel logout metadata",
+			client:      &fosite.DefaultClient{ID: "foo"},
+			subject:     "peter",
+			sessionID:   "session-id",
+		},
+		{
+			description: "should fail because the client requires a sid but none was given",
+			client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}, BackChannelLogoutSessionRequired: true},
+			subject:     "peter",
+			expectErr:   true,
+		},
+		{
+			description: "should fail because sub-based logout requires a subject",
+			client:      &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}},
+			sessionID:   "session-id",
+			expectErr:   true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			token, err := j.GenerateLogoutToken(context.TODO(), c.client, c.subject, c.sessionID)
+			if c.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			decoded, err := j.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			claims := decoded.Claims.(jwtgo.MapClaims)
+
+			assert.Equal(t, c.subject, claims["sub"])
+			assert.Equal(t, "https://fosite.example/", claims["iss"])
+			assert.Equal(t, []interface{}{"foo"}, claims["aud"])
+			assert.Contains(t, claims["events"], jwt.BackChannelLogoutEvent)
+			assert.NotContains(t, claims, "nonce")
+			if c.expectSessionID {
+				assert.Equal(t, c.sessionID, claims["sid"])
+			} else {
+				assert.NotContains(t, claims, "sid")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// SubjectIdentifierAlgorithm computes the subject identifier of an end-user as presented to a client, see
+// https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+type SubjectIdentifierAlgorithm interface {
+	// Obfuscate returns the subject identifier of the local subject for the given client.
+	Obfuscate(subject string, client fosite.Client) (string, error)
+}
+
+// PublicSubjectIdentifierAlgorithm presents the local subject to all clients.
+type PublicSubjectIdentifierAlgorithm struct{}
+
+func (g *PublicSubjectIdentifierAlgorithm) Obfuscate(subject string, _ fosite.Client) (string, error) {
+	return subject, nil
+}
+
+// PairwiseSubjectIdentifierAlgorithm presents a different subject identifier to every sector. The identifier is the
+// hex encoded SHA-256 hash of the sector identifier, the local subject and Salt.
+//
+// The sector identifier is the host of the client's sector_identifier_uri or, if the client has not set one, the host
+// of its redirect URIs. Clients without sector_identifier_uri must not use redirect URIs with different hosts.
+type PairwiseSubjectIdentifierAlgorithm struct {
+	Salt []byte
+}
+
+func (g *PairwiseSubjectIdentifierAlgorithm) Obfuscate(subject string, client fosite.Client) (string, error) {
+	if len(g.Salt) == 0 {
+		return "", errors.WithStack(fosite.ErrMisconfiguration.WithDebug("The salt for pairwise subject identifiers is not set."))
+	}
+
+	sector, err := sectorIdentifier(client)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(sector))
+	_, _ = h.Write([]byte(subject))
+	_, _ = h.Write(g.Salt)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sectorIdentifier(client fosite.Client) (string, error) {
+	if c, ok := client.(fosite.SubjectTypeClient); ok && c.GetSectorIdentifierURI() != "" {
+		u, err := url.Parse(c.GetSectorIdentifierURI())
+		if err != nil || u.Host == "" {
+			return "", errors.WithStack(fosite.ErrServerError.WithDebugf("Unable to determine the sector identifier from the sector_identifier_uri of client '%s'.", client.GetID()))
+		}
+		return u.Host, nil
+	}
+
+	var host string
+	for _, redirectURI := range client.GetRedirectURIs() {
+		u, err := url.Parse(redirectURI)
+		if err != nil || u.Host == "" {
+			return "", errors.WithStack(fosite.ErrServerError.WithDebugf("Unable to determine the sector identifier from the redirect URI '%s' of client '%s'.", redirectURI, client.GetID()))
+		} else if host != "" && host != u.Host {
+			return "", errors.WithStack(fosite.ErrServerError.WithDebugf("Client '%s' uses redirect URIs with different hosts and must set a sector_identifier_uri to use pairwise subject identifiers.", client.GetID()))
+		}
+		host = u.Host
+	}
+
+	if host == "" {
+		return "", errors.WithStack(fosite.ErrServerError.WithDebugf("Client '%s' has neither a sector_identifier_uri nor redirect URIs to determine the sector identifier from.", client.GetID()))
+	}
+	return host, nil
+}
+
+// ObfuscateSubject returns the subject identifier of the local subject for the client, using the algorithm of the
+// client's subject type. Clients which do not declare a subject type use PublicSubjectType. Use it to set the "sub"
+// claim of UserInfo responses, which must match the one of the ID Token.
+func ObfuscateSubject(algorithms map[string]SubjectIdentifierAlgorithm, subject string, client fosite.Client) (string, error) {
+	subjectType := fosite.PublicSubjectType
+	if c, ok := client.(fosite.SubjectTypeClient); ok && c.GetSubjectType() != "" {
+		subjectType = c.GetSubjectType()
+	}
+
+	algorithm, ok := algorithms[subjectType]
+	if !ok && subjectType == fosite.PublicSubjectType {
+		return subject, nil
+	} else if !ok {
+		return "", errors.WithStack(fosite.ErrServerError.WithDebugf("Subject type '%s' of client '%s' is not supported.", subjectType, client.GetID()))
+	}
+
+	return algorithm.Obfuscate(subject, client)
+}
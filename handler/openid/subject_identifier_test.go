@@ -0,0 +1,152 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package openid
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestObfuscateSubject(t *testing.T) {
+	algorithms := map[string]SubjectIdentifierAlgorithm{
+		fosite.PublicSubjectType:   &PublicSubjectIdentifierAlgorithm{},
+		fosite.PairwiseSubjectType: &PairwiseSubjectIdentifierAlgorithm{Salt: []byte("some-salt")},
+	}
+
+	newClient := func(id, subjectType, sectorIdentifierURI string, redirectURIs ...string) fosite.Client {
+		return &fosite.DefaultOpenIDConnectClient{
+			DefaultClient:       &fosite.DefaultClient{ID: id, RedirectURIs: redirectURIs},
+			SubjectType:         subjectType,
+			SectorIdentifierURI: sectorIdentifierURI,
+		}
+	}
+
+	obfuscate := func(t *testing.T, client fosite.Client) string {
+		sub, err := ObfuscateSubject(algorithms, "peter", client)
+		require.NoError(t, err)
+		return sub
+	}
+
+	t.Run("case=clients in different sectors get different subjects", func(t *testing.T) {
+		a := obfuscate(t, newClient("a", fosite.PairwiseSubjectType, "", "https://a.example.com/cb"))
+		b := obfuscate(t, newClient("b", fosite.PairwiseSubjectType, "", "https://b.example.com/cb"))
+		assert.NotEqual(t, a, b)
+		assert.NotEqual(t, "peter", a)
+		assert.NotEqual(t, "peter", b)
+	})
+
+	t.Run("case=clients in the same sector get the same subject", func(t *testing.T) {
+		a := obfuscate(t, newClient("a", fosite.PairwiseSubjectType, "https://sector.example.com/sector.json", "https://a.example.com/cb"))
+		b := obfuscate(t, newClient("b", fosite.PairwiseSubjectType, "https://sector.example.com/other.json", "https://b.example.com/cb"))
+		assert.Equal(t, a, b)
+
+		c := obfuscate(t, newClient("c", fosite.PairwiseSubjectType, "", "https://a.example.com/cb", "https://a.example.com/other"))
+		d := obfuscate(t, newClient("d", fosite.PairwiseSubjectType, "", "https://a.example.com/cb"))
+		assert.Equal(t, c, d)
+	})
+
+	t.Run("case=the same sector gets different subjects for different users", func(t *testing.T) {
+		client := newClient("a", fosite.PairwiseSubjectType, "", "https://a.example.com/cb")
+		other, err := ObfuscateSubject(algorithms, "alice", client)
+		require.NoError(t, err)
+		assert.NotEqual(t, obfuscate(t, client), other)
+	})
+
+	t.Run("case=public clients get the local subject", func(t *testing.T) {
+		assert.Equal(t, "peter", obfuscate(t, newClient("a", fosite.PublicSubjectType, "", "https://a.example.com/cb")))
+		assert.Equal(t, "peter", obfuscate(t, newClient("a", "", "", "https://a.example.com/cb")))
+		assert.Equal(t, "peter", obfuscate(t, &fosite.DefaultClient{ID: "a"}))
+
+		sub, err := ObfuscateSubject(nil, "peter", &fosite.DefaultClient{ID: "a"})
+		require.NoError(t, err)
+		assert.Equal(t, "peter", sub)
+	})
+
+	for k, c := range []struct {
+		d          string
+		algorithms map[string]SubjectIdentifierAlgorithm
+		client     fosite.Client
+	}{
+		{
+			d:          "should fail because pairwise subject identifiers are not enabled",
+			algorithms: nil,
+			client:     newClient("a", fosite.PairwiseSubjectType, "", "https://a.example.com/cb"),
+		},
+		{
+			d:          "should fail because the redirect URIs have different hosts",
+			algorithms: algorithms,
+			client:     newClient("a", fosite.PairwiseSubjectType, "", "https://a.example.com/cb", "https://b.example.com/cb"),
+		},
+		{
+			d:          "should fail because the sector can not be determined",
+			algorithms: algorithms,
+			client:     newClient("a", fosite.PairwiseSubjectType, ""),
+		},
+		{
+			d:          "should fail because the salt is missing",
+			algorithms: map[string]SubjectIdentifierAlgorithm{fosite.PairwiseSubjectType: &PairwiseSubjectIdentifierAlgorithm{}},
+			client:     newClient("a", fosite.PairwiseSubjectType, "", "https://a.example.com/cb"),
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			_, err := ObfuscateSubject(c.algorithms, "peter", c.client)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestJWTStrategy_GenerateIDTokenPairwiseSubject(t *testing.T) {
+	j := &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{PrivateKey: key},
+		SubjectIdentifierAlgorithms: map[string]SubjectIdentifierAlgorithm{
+			fosite.PairwiseSubjectType: &PairwiseSubjectIdentifierAlgorithm{Salt: []byte("some-salt")},
+		},
+	}
+
+	client := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient: &fosite.DefaultClient{ID: "foo", RedirectURIs: []string{"https://foo.example.com/cb"}},
+		SubjectType:   fosite.PairwiseSubjectType,
+	}
+	expected, err := ObfuscateSubject(j.SubjectIdentifierAlgorithms, "peter", client)
+	require.NoError(t, err)
+
+	session := &DefaultSession{Claims: &jwt.IDTokenClaims{Subject: "peter"}, Headers: &jwt.Headers{}}
+	req := fosite.NewAccessRequest(session)
+	req.Client = client
+
+	token, err := j.GenerateIDToken(context.Background(), req)
+	require.NoError(t, err)
+	decoded, err := j.JWTStrategy.Decode(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, expected, decoded.Claims.(jwtgo.MapClaims)["sub"])
+
+	// The session keeps the local subject.
+	assert.Equal(t, "peter", session.Claims.Subject)
+}
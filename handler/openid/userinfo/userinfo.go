@@ -0,0 +1,334 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package userinfo assembles the responses of the OpenID Connect UserInfo Endpoint, see
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+package userinfo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// ScopeClaims maps the scopes defined by OpenID Connect to the claims they grant access to, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+var ScopeClaims = map[string][]string{
+	"profile": {"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username", "profile",
+		"picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at"},
+	"email":   {"email", "email_verified"},
+	"address": {"address"},
+	"phone":   {"phone_number", "phone_number_verified"},
+}
+
+// TokenIntrospector validates access tokens, usually it is the fosite.OAuth2Provider.
+type TokenIntrospector interface {
+	IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error)
+}
+
+// bearerTokenExtractor is implemented by TokenIntrospectors which take the bearer token from the configured
+// locations, such as *fosite.Fosite.
+type bearerTokenExtractor interface {
+	BearerTokenFromRequest(r *http.Request) string
+}
+
+// bearerErrorWriter is implemented by TokenIntrospectors which write bearer token errors using the configured realm
+// and error hooks, such as *fosite.Fosite.
+type bearerErrorWriter interface {
+	WriteBearerError(rw http.ResponseWriter, err error)
+}
+
+// Handler assembles UserInfo responses. The claims of the response are taken from the ID Token claims of the
+// session the access token was issued with.
+type Handler struct {
+	// TokenIntrospector validates the bearer access token.
+	TokenIntrospector TokenIntrospector
+
+	// JWTStrategy signs the UserInfo responses of clients which declare userinfo_signed_response_alg.
+	JWTStrategy jwt.JWTStrategy
+
+	// SigningAlgorithm is the algorithm used by JWTStrategy. Defaults to RS256.
+	SigningAlgorithm string
+
+	// Issuer is the "iss" claim of signed UserInfo responses.
+	Issuer string
+
+	// IssuerFromRequest, if set, resolves the "iss" claim of signed UserInfo responses from the UserInfo request. If it
+	// returns an empty string, Issuer is used.
+	IssuerFromRequest fosite.IssuerFromRequest
+
+	// SubjectIdentifierAlgorithms computes the "sub" claim, see openid.DefaultStrategy.
+	SubjectIdentifierAlgorithms map[string]openid.SubjectIdentifierAlgorithm
+
+	// JWKSFetcherStrategy fetches the keys of clients which register a jwks_uri and declare
+	// userinfo_encrypted_response_alg.
+	JWKSFetcherStrategy fosite.JWKSFetcherStrategy
+}
+
+// Response is a UserInfo response.
+type Response struct {
+	// Claims are the claims about the end-user.
+	Claims map[string]interface{}
+
+	// Token is the signed and/or encrypted JWT containing the claims if the client requested one.
+	Token string
+}
+
+// bearerToken returns the bearer token of the request using the BearerTokenLocations of the TokenIntrospector, or the
+// default locations if it does not implement BearerTokenFromRequest.
+func (h *Handler) bearerToken(r *http.Request) string {
+	if extractor, ok := h.TokenIntrospector.(bearerTokenExtractor); ok {
+		return extractor.BearerTokenFromRequest(r)
+	}
+	return new(fosite.Fosite).BearerTokenFromRequest(r)
+}
+
+func (h *Handler) signingAlgorithm() string {
+	if h.SigningAlgorithm == "" {
+		return "RS256"
+	}
+	return h.SigningAlgorithm
+}
+
+// NewUserInfoResponse validates the bearer access token of the request and assembles the UserInfo response. The
+// session is populated by the token introspection and must implement openid.Session.
+//
+// Only claims covered by the granted scopes are included, in addition to the claims requested for the UserInfo
+// Endpoint using the "claims" parameter. The "claims" parameter is read from the request form of the introspected
+// access request and is therefore only considered if the storage retains it.
+func (h *Handler) NewUserInfoResponse(ctx context.Context, r *http.Request, session fosite.Session) (*Response, error) {
+	if _, ok := fosite.HTTPRequestFromContext(ctx); !ok {
+		ctx = fosite.WithHTTPRequest(ctx, r)
+	}
+
+	token := h.bearerToken(r)
+	if token == "" {
+		return nil, errors.WithStack(fosite.ErrInvalidToken.WithHint("The request does not contain a bearer access token."))
+	}
+
+	_, ar, err := h.TokenIntrospector.IntrospectToken(ctx, token, fosite.AccessToken, session)
+	if err != nil {
+		if rfcerr := fosite.ErrorToRFC6749Error(err); rfcerr.Code >= http.StatusInternalServerError {
+			return nil, errors.WithStack(rfcerr)
+		}
+		return nil, errors.WithStack(fosite.ErrInvalidToken.WithCause(err).WithDebug(err.Error()))
+	}
+
+	if !ar.GetGrantedScopes().Has("openid") {
+		return nil, errors.WithStack(fosite.ErrInsufficientScope.WithHint("The access token was not granted the 'openid' scope."))
+	}
+
+	sess, ok := ar.GetSession().(openid.Session)
+	if !ok {
+		return nil, errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate the UserInfo response because session must be of type fosite/handler/openid.Session."))
+	}
+
+	idTokenClaims := sess.IDTokenClaims()
+	if idTokenClaims.Subject == "" {
+		return nil, errors.WithStack(fosite.ErrServerError.WithDebug("Failed to generate the UserInfo response because subject is an empty string."))
+	}
+
+	subject, err := openid.ObfuscateSubject(h.SubjectIdentifierAlgorithms, idTokenClaims.Subject, ar.GetClient())
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := requestedClaims(ar.GetRequestForm().Get("claims"))
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range ar.GetGrantedScopes() {
+		for _, claim := range ScopeClaims[scope] {
+			allowed[claim] = true
+		}
+	}
+
+	claims := map[string]interface{}{}
+	for claim, value := range idTokenClaims.Extra {
+		if allowed[claim] {
+			claims[claim] = value
+		}
+	}
+	claims["sub"] = subject
+
+	resp := &Response{Claims: claims}
+	if client, ok := ar.GetClient().(fosite.UserInfoClient); ok {
+		if resp.Token, err = h.generateJWT(ctx, client, ar.GetClient(), claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// requestedClaims returns the names of the claims requested for the UserInfo Endpoint by the "claims" parameter, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter
+func requestedClaims(raw string) (map[string]bool, error) {
+	claims := map[string]bool{}
+	if raw == "" {
+		return claims, nil
+	}
+
+	var request struct {
+		UserInfo map[string]json.RawMessage `json:"userinfo"`
+	}
+	if err := json.Unmarshal([]byte(raw), &request); err != nil {
+		return nil, errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebugf("Unable to decode the claims parameter: %s", err.Error()))
+	}
+
+	for claim := range request.UserInfo {
+		claims[claim] = true
+	}
+	return claims, nil
+}
+
+func (h *Handler) generateJWT(ctx context.Context, c fosite.UserInfoClient, client fosite.Client, claims map[string]interface{}) (string, error) {
+	signingAlg, encryptionAlg := c.GetUserInfoSigningAlgorithm(), c.GetUserInfoEncryptionAlgorithm()
+	if signingAlg == "" && encryptionAlg == "" {
+		return "", nil
+	}
+
+	// If only encryption is requested, the UserInfo response is an encrypted JSON document.
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	if signingAlg != "" {
+		if signingAlg != h.signingAlgorithm() {
+			return "", errors.WithStack(fosite.ErrServerError.WithDebugf("The OAuth 2.0 Client requires UserInfo responses signed with '%s' but only '%s' is supported.", signingAlg, h.signingAlgorithm()))
+		} else if h.JWTStrategy == nil {
+			return "", errors.WithStack(fosite.ErrMisconfiguration.WithDebug("No JWT strategy is configured for signing UserInfo responses."))
+		}
+
+		mapClaims := jwtgo.MapClaims{}
+		for k, v := range claims {
+			mapClaims[k] = v
+		}
+		mapClaims["iss"] = fosite.ResolveIssuer(ctx, h.IssuerFromRequest, h.Issuer)
+		mapClaims["aud"] = client.GetID()
+
+		token, _, err := h.JWTStrategy.Generate(ctx, mapClaims, jwt.NewHeaders())
+		if err != nil {
+			return "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		} else if encryptionAlg == "" {
+			return token, nil
+		}
+		payload = []byte(token)
+	}
+
+	return h.encrypt(c, client, payload)
+}
+
+func (h *Handler) encrypt(c fosite.UserInfoClient, client fosite.Client, payload []byte) (string, error) {
+	alg, enc := c.GetUserInfoEncryptionAlgorithm(), c.GetUserInfoEncryptionEncoding()
+	if enc == "" {
+		// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+		enc = string(jose.A128CBC_HS256)
+	}
+
+	key, err := h.encryptionKey(client, alg)
+	if err != nil {
+		return "", err
+	}
+
+	opts := new(jose.EncrypterOptions)
+	if c.GetUserInfoSigningAlgorithm() != "" {
+		opts = opts.WithContentType("JWT")
+	}
+
+	encrypter, err := jose.NewEncrypter(jose.ContentEncryption(enc), jose.Recipient{
+		Algorithm: jose.KeyAlgorithm(alg),
+		Key:       key.Key,
+		KeyID:     key.KeyID,
+	}, opts)
+	if err != nil {
+		return "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebugf("Unable to encrypt the UserInfo response using '%s' and '%s': %s", alg, enc, err.Error()))
+	}
+
+	object, err := encrypter.Encrypt(payload)
+	if err != nil {
+		return "", errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	return object.CompactSerialize()
+}
+
+func (h *Handler) encryptionKey(client fosite.Client, alg string) (*jose.JSONWebKey, error) {
+	oidcClient, ok := client.(fosite.OpenIDConnectClient)
+	if !ok {
+		return nil, errors.WithStack(fosite.ErrServerError.WithDebug("The OAuth 2.0 Client requires encrypted UserInfo responses but does not implement fosite.OpenIDConnectClient."))
+	}
+
+	keys := oidcClient.GetJSONWebKeys()
+	if keys == nil && oidcClient.GetJSONWebKeysURI() != "" && h.JWKSFetcherStrategy != nil {
+		var err error
+		if keys, err = h.JWKSFetcherStrategy.Resolve(oidcClient.GetJSONWebKeysURI(), false); err != nil {
+			return nil, err
+		}
+	}
+
+	if keys != nil {
+		for i := range keys.Keys {
+			key := &keys.Keys[i]
+			if (key.Use == "" || key.Use == "enc") && (key.Algorithm == "" || key.Algorithm == alg) {
+				return key, nil
+			}
+		}
+	}
+
+	return nil, errors.WithStack(fosite.ErrServerError.WithDebugf("The OAuth 2.0 Client has no key for encrypting UserInfo responses using '%s'.", alg))
+}
+
+// WriteUserInfoResponse writes the UserInfo response as JSON or, if the client requested a signed or encrypted
+// response, as JWT.
+func (h *Handler) WriteUserInfoResponse(rw http.ResponseWriter, resp *Response) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	if resp.Token != "" {
+		rw.Header().Set("Content-Type", "application/jwt")
+		_, _ = rw.Write([]byte(resp.Token))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(rw).Encode(resp.Claims)
+}
+
+// WriteUserInfoError writes the error as bearer token error using the WWW-Authenticate header, see
+// https://tools.ietf.org/html/rfc6750#section-3. The error is written by the WriteBearerError method of the
+// TokenIntrospector, or the default *fosite.Fosite if it does not implement WriteBearerError.
+func (h *Handler) WriteUserInfoError(rw http.ResponseWriter, err error) {
+	if writer, ok := h.TokenIntrospector.(bearerErrorWriter); ok {
+		writer.WriteBearerError(rw, err)
+		return
+	}
+	new(fosite.Fosite).WriteBearerError(rw, err)
+}
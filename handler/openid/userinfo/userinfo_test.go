@@ -0,0 +1,303 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package userinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type stubIntrospector struct {
+	ar  fosite.AccessRequester
+	err error
+}
+
+func (s *stubIntrospector) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error) {
+	return fosite.AccessToken, s.ar, s.err
+}
+
+func newUserInfoRequest() *http.Request {
+	r := httptest.NewRequest("GET", "/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer some-token")
+	return r
+}
+
+func newAccessRequest(client fosite.Client, form url.Values, scopes ...string) *fosite.AccessRequest {
+	ar := fosite.NewAccessRequest(&openid.DefaultSession{
+		Claims: &jwt.IDTokenClaims{
+			Subject: "peter",
+			Extra: map[string]interface{}{
+				"name":         "Peter",
+				"email":        "peter@example.org",
+				"phone_number": "+1 555 0100",
+				"secret":       "foo",
+			},
+		},
+		Headers: &jwt.Headers{},
+	})
+	ar.Client = client
+	ar.Form = form
+	ar.GrantedScope = scopes
+	return ar
+}
+
+func TestNewUserInfoResponse(t *testing.T) {
+	client := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}}
+
+	for k, c := range []struct {
+		d          string
+		ar         fosite.AccessRequester
+		introErr   error
+		expectErr  *fosite.RFC6749Error
+		expectBody map[string]interface{}
+	}{
+		{
+			d:         "should fail because the token is invalid",
+			ar:        newAccessRequest(client, url.Values{}, "openid"),
+			introErr:  fosite.ErrTokenExpired,
+			expectErr: fosite.ErrInvalidToken,
+		},
+		{
+			d:         "should fail with a server error because the token could not be introspected",
+			ar:        newAccessRequest(client, url.Values{}, "openid"),
+			introErr:  fosite.ErrServerError.WithDebug("storage unavailable"),
+			expectErr: fosite.ErrServerError,
+		},
+		{
+			d:         "should fail because the openid scope was not granted",
+			ar:        newAccessRequest(client, url.Values{}, "profile"),
+			expectErr: fosite.ErrInsufficientScope,
+		},
+		{
+			d:          "should only return the subject without further scopes",
+			ar:         newAccessRequest(client, url.Values{}, "openid"),
+			expectBody: map[string]interface{}{"sub": "peter"},
+		},
+		{
+			d:          "should return the claims of the granted scopes",
+			ar:         newAccessRequest(client, url.Values{}, "openid", "profile", "email"),
+			expectBody: map[string]interface{}{"sub": "peter", "name": "Peter", "email": "peter@example.org"},
+		},
+		{
+			d:          "should return the claims requested for the userinfo endpoint",
+			ar:         newAccessRequest(client, url.Values{"claims": {`{"userinfo":{"phone_number":null}}`}}, "openid"),
+			expectBody: map[string]interface{}{"sub": "peter", "phone_number": "+1 555 0100"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			h := &Handler{TokenIntrospector: &stubIntrospector{ar: c.ar, err: c.introErr}}
+
+			resp, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+
+				rw := httptest.NewRecorder()
+				h.WriteUserInfoError(rw, err)
+				assert.Equal(t, c.expectErr.Code, rw.Code)
+				if c.expectErr.Code >= http.StatusInternalServerError {
+					assert.Empty(t, rw.Header().Get("WWW-Authenticate"))
+					return
+				}
+				assert.Contains(t, rw.Header().Get("WWW-Authenticate"), fmt.Sprintf(`error="%s"`, c.expectErr.Error()))
+				return
+			}
+			require.NoError(t, err)
+
+			rw := httptest.NewRecorder()
+			h.WriteUserInfoResponse(rw, resp)
+			assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+			assert.Equal(t, c.expectBody, body)
+		})
+	}
+}
+
+type stubLocationIntrospector struct {
+	stubIntrospector
+	header string
+}
+
+func (s *stubLocationIntrospector) BearerTokenFromRequest(r *http.Request) string {
+	return r.Header.Get(s.header)
+}
+
+func TestNewUserInfoResponseBearerTokenLocations(t *testing.T) {
+	client := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}}
+	ar := newAccessRequest(client, url.Values{}, "openid")
+
+	t.Run("case=should reject the DPoP scheme because the proof is not validated", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/userinfo", nil)
+		r.Header.Set("Authorization", "DPoP some-token")
+
+		_, err := (&Handler{TokenIntrospector: &stubIntrospector{ar: ar}}).NewUserInfoResponse(context.Background(), r, new(openid.DefaultSession))
+		require.EqualError(t, err, fosite.ErrInvalidToken.Error())
+	})
+
+	t.Run("case=should use the bearer token locations of the introspector", func(t *testing.T) {
+		h := &Handler{TokenIntrospector: &stubLocationIntrospector{stubIntrospector: stubIntrospector{ar: ar}, header: "X-Access-Token"}}
+
+		_, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+		require.EqualError(t, err, fosite.ErrInvalidToken.Error())
+
+		r := httptest.NewRequest("GET", "/userinfo", nil)
+		r.Header.Set("X-Access-Token", "some-token")
+		_, err = h.NewUserInfoResponse(context.Background(), r, new(openid.DefaultSession))
+		require.NoError(t, err)
+	})
+}
+
+type stubBearerErrorIntrospector struct {
+	stubIntrospector
+	*fosite.Fosite
+}
+
+func (s *stubBearerErrorIntrospector) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, session fosite.Session, scope ...string) (fosite.TokenUse, fosite.AccessRequester, error) {
+	return s.stubIntrospector.IntrospectToken(ctx, token, tokenUse, session, scope...)
+}
+
+func TestWriteUserInfoErrorBearerRealm(t *testing.T) {
+	client := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: "foo"}}
+	h := &Handler{TokenIntrospector: &stubBearerErrorIntrospector{
+		stubIntrospector: stubIntrospector{ar: newAccessRequest(client, url.Values{}, "profile")},
+		Fosite:           &fosite.Fosite{BearerRealm: "example"},
+	}}
+
+	_, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+	require.Error(t, err)
+
+	rw := httptest.NewRecorder()
+	h.WriteUserInfoError(rw, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, `Bearer realm="example", error="insufficient_scope", error_description="The request requires higher privileges than provided by the access token."`, rw.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+}
+
+func TestNewUserInfoResponseSigned(t *testing.T) {
+	key := internal.MustRSAKey()
+	client := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:            &fosite.DefaultClient{ID: "foo"},
+		UserInfoSigningAlgorithm: "RS256",
+	}
+
+	h := &Handler{
+		TokenIntrospector: &stubIntrospector{ar: newAccessRequest(client, url.Values{}, "openid", "email")},
+		JWTStrategy:       &jwt.RS256JWTStrategy{PrivateKey: key},
+		Issuer:            "https://auth.example.org",
+	}
+
+	resp, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Token)
+
+	rw := httptest.NewRecorder()
+	h.WriteUserInfoResponse(rw, resp)
+	assert.Equal(t, "application/jwt", rw.Header().Get("Content-Type"))
+
+	token, err := jwtgo.Parse(rw.Body.String(), func(t *jwtgo.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwtgo.MapClaims)
+	assert.Equal(t, "peter", claims["sub"])
+	assert.Equal(t, "peter@example.org", claims["email"])
+	assert.Equal(t, "https://auth.example.org", claims["iss"])
+	assert.Equal(t, "foo", claims["aud"])
+	assert.Nil(t, claims["name"])
+
+	t.Run("case=should fail because the signing algorithm is not supported", func(t *testing.T) {
+		client.UserInfoSigningAlgorithm = "ES256"
+		defer func() { client.UserInfoSigningAlgorithm = "RS256" }()
+
+		_, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+		require.EqualError(t, err, fosite.ErrServerError.Error())
+	})
+}
+
+func TestNewUserInfoResponseEncrypted(t *testing.T) {
+	signingKey, encryptionKey := internal.MustRSAKey(), internal.MustRSAKey()
+	client := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient:               &fosite.DefaultClient{ID: "foo"},
+		UserInfoSigningAlgorithm:    "RS256",
+		UserInfoEncryptionAlgorithm: "RSA-OAEP",
+		JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{KeyID: "sig", Use: "sig", Key: &signingKey.PublicKey},
+			{KeyID: "enc", Use: "enc", Key: &encryptionKey.PublicKey},
+		}},
+	}
+
+	h := &Handler{
+		TokenIntrospector: &stubIntrospector{ar: newAccessRequest(client, url.Values{}, "openid", "profile")},
+		JWTStrategy:       &jwt.RS256JWTStrategy{PrivateKey: signingKey},
+	}
+
+	resp, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+	require.NoError(t, err)
+
+	object, err := jose.ParseEncrypted(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "enc", object.Header.KeyID)
+	assert.Equal(t, "A128CBC-HS256", object.Header.ExtraHeaders["enc"])
+
+	decrypted, err := object.Decrypt(encryptionKey)
+	require.NoError(t, err)
+
+	token, err := jwtgo.Parse(string(decrypted), func(t *jwtgo.Token) (interface{}, error) {
+		return &signingKey.PublicKey, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Peter", token.Claims.(jwtgo.MapClaims)["name"])
+
+	t.Run("case=should return an encrypted JSON document without signing algorithm", func(t *testing.T) {
+		client.UserInfoSigningAlgorithm = ""
+		defer func() { client.UserInfoSigningAlgorithm = "RS256" }()
+
+		resp, err := h.NewUserInfoResponse(context.Background(), newUserInfoRequest(), new(openid.DefaultSession))
+		require.NoError(t, err)
+
+		object, err := jose.ParseEncrypted(resp.Token)
+		require.NoError(t, err)
+		decrypted, err := object.Decrypt(encryptionKey)
+		require.NoError(t, err)
+
+		var claims map[string]interface{}
+		require.NoError(t, json.Unmarshal(decrypted, &claims))
+		assert.Equal(t, map[string]interface{}{"sub": "peter", "name": "Peter"}, claims)
+	})
+}
@@ -36,10 +36,30 @@ import (
 	"github.com/ory/go-convenience/stringslice"
 )
 
+// PromptNoneConsentPolicy defines how requests with "prompt=none" are handled when the end-user has consented to only
+// some of the requested scopes.
+type PromptNoneConsentPolicy int
+
+const (
+	// PromptNoneConsentRequired rejects the request with "consent_required" unless all requested scopes were granted.
+	// This is the behavior mandated by OpenID Connect Core 1.0 Section 3.1.2.1.
+	PromptNoneConsentRequired PromptNoneConsentPolicy = iota
+
+	// PromptNonePartialConsent proceeds with the request and issues tokens for the granted subset of the requested scopes.
+	PromptNonePartialConsent
+)
+
 type OpenIDConnectRequestValidator struct {
 	AllowedPrompt       []string
 	Strategy            jwt.JWTStrategy
 	IsRedirectURISecure func(*url.URL) bool
+
+	// PromptNoneConsentPolicy controls how "prompt=none" requests with partial consent are handled. Defaults to
+	// PromptNoneConsentRequired.
+	PromptNoneConsentPolicy PromptNoneConsentPolicy
+
+	// Clock returns the current time. Defaults to the system clock.
+	Clock fosite.Clock
 }
 
 func NewOpenIDConnectRequestValidator(prompt []string, strategy jwt.JWTStrategy) *OpenIDConnectRequestValidator {
@@ -58,6 +78,16 @@ func (v *OpenIDConnectRequestValidator) WithRedirectSecureChecker(checker func(*
 	return v
 }
 
+func (v *OpenIDConnectRequestValidator) WithPromptNoneConsentPolicy(policy PromptNoneConsentPolicy) *OpenIDConnectRequestValidator {
+	v.PromptNoneConsentPolicy = policy
+	return v
+}
+
+func (v *OpenIDConnectRequestValidator) WithClock(clock fosite.Clock) *OpenIDConnectRequestValidator {
+	v.Clock = clock
+	return v
+}
+
 func (v *OpenIDConnectRequestValidator) secureChecker() func(*url.URL) bool {
 	if v.IsRedirectURISecure == nil {
 		v.IsRedirectURISecure = fosite.IsRedirectURISecure
@@ -69,7 +99,7 @@ func (v *OpenIDConnectRequestValidator) ValidatePrompt(ctx context.Context, req
 	// prompt is case sensitive!
 	prompt := fosite.RemoveEmpty(strings.Split(req.GetRequestForm().Get("prompt"), " "))
 
-	if req.GetClient().IsPublic() {
+	if fosite.IsPublicClient(req.GetClient()) {
 		// Threat: Malicious Client Obtains Existing Authorization by Fraud
 		// https://tools.ietf.org/html/rfc6819#section-4.2.3
 		//
@@ -120,7 +150,7 @@ func (v *OpenIDConnectRequestValidator) ValidatePrompt(ctx context.Context, req
 	}
 
 	// Adds a bit of wiggle room for timing issues
-	if claims.AuthTime.After(time.Now().UTC().Add(time.Second * 5)) {
+	if claims.AuthTime.After(v.Clock.Now().Add(time.Second * 5)) {
 		return errors.WithStack(fosite.ErrServerError.WithDebug("Failed to validate OpenID Connect request because authentication time is in the future."))
 	}
 
@@ -141,6 +171,13 @@ func (v *OpenIDConnectRequestValidator) ValidatePrompt(ctx context.Context, req
 		if claims.AuthTime.After(claims.RequestedAt) {
 			return errors.WithStack(fosite.ErrLoginRequired.WithHint("Failed to validate OpenID Connect request because prompt was set to 'none' but auth_time happened after the authorization request was registered, indicating that the user was logged in during this request which is not allowed."))
 		}
+		if v.PromptNoneConsentPolicy == PromptNoneConsentRequired {
+			for _, scope := range req.GetRequestedScopes() {
+				if !req.GetGrantedScopes().Has(scope) {
+					return errors.WithStack(fosite.ErrConsentRequired.WithHintf("Failed to validate OpenID Connect request because prompt was set to 'none' but the end-user has not consented to scope '%s'.", scope))
+				}
+			}
+		}
 	}
 
 	if stringslice.Has(prompt, "login") {
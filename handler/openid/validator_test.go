@@ -280,6 +280,67 @@ func TestValidatePrompt(t *testing.T) {
 	}
 }
 
+func TestValidatePromptNoneConsentPolicy(t *testing.T) {
+	var j = &DefaultStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: key,
+		},
+		MinParameterEntropy: fosite.MinParameterEntropy,
+	}
+
+	for k, tc := range []struct {
+		d         string
+		policy    PromptNoneConsentPolicy
+		granted   fosite.Arguments
+		expectErr error
+	}{
+		{
+			d:       "should pass because all requested scopes were consented to",
+			policy:  PromptNoneConsentRequired,
+			granted: fosite.Arguments{"openid", "email", "profile"},
+		},
+		{
+			d:         "should fail because only some of the requested scopes were consented to",
+			policy:    PromptNoneConsentRequired,
+			granted:   fosite.Arguments{"openid", "email"},
+			expectErr: fosite.ErrConsentRequired,
+		},
+		{
+			d:       "should pass and keep the consented subset because partial consent is allowed",
+			policy:  PromptNonePartialConsent,
+			granted: fosite.Arguments{"openid", "email"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			v := NewOpenIDConnectRequestValidator(nil, j).WithPromptNoneConsentPolicy(tc.policy)
+			req := &fosite.AuthorizeRequest{
+				Request: fosite.Request{
+					Form:           url.Values{"prompt": {"none"}},
+					Client:         &fosite.DefaultClient{},
+					RequestedScope: fosite.Arguments{"openid", "email", "profile"},
+					GrantedScope:   tc.granted,
+					Session: &DefaultSession{
+						Subject: "foo",
+						Claims: &jwt.IDTokenClaims{
+							Subject:     "foo",
+							RequestedAt: time.Now().UTC(),
+							AuthTime:    time.Now().UTC().Add(-time.Minute),
+						},
+					},
+				},
+			}
+
+			err := v.ValidatePrompt(context.TODO(), req)
+			if tc.expectErr != nil {
+				require.EqualError(t, err, tc.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.granted, req.GetGrantedScopes())
+		})
+	}
+}
+
 func parse(u string) *url.URL {
 	o, _ := url.Parse(u)
 	return o
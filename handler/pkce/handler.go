@@ -63,6 +63,14 @@ func (c *Handler) HandleAuthorizeEndpointRequest(ctx context.Context, ar fosite.
 		return err
 	}
 
+	// A S256 challenge is the base64url encoded SHA-256 hash of the verifier and a plain challenge is the verifier
+	// itself, so both must follow the syntax of code verifiers.
+	if challenge != "" {
+		if err := validateSyntax(fosite.ErrInvalidRequest, "code challenge", challenge); err != nil {
+			return err
+		}
+	}
+
 	code := resp.GetCode()
 	if len(code) == 0 {
 		return errors.WithStack(fosite.ErrServerError.WithDebug("The PKCE handler must be loaded after the authorize code handler."))
@@ -92,7 +100,7 @@ func (c *Handler) validate(challenge, method string, client fosite.Client) error
 				WithHint("Clients must include a code_challenge when performing the authorize code flow, but it is missing.").
 				WithDebug("The server is configured in a way that enforces PKCE for clients."))
 		}
-		if c.ForceForPublicClients && client.IsPublic() {
+		if c.ForceForPublicClients && fosite.IsPublicClient(client) {
 			return errors.WithStack(fosite.ErrInvalidRequest.
 				WithHint("This client must include a code_challenge when performing the authorize code flow, but it is missing.").
 				WithDebug("The server is configured in a way that enforces PKCE for this client."))
@@ -124,6 +132,22 @@ func (c *Handler) validate(challenge, method string, client fosite.Client) error
 	return nil
 }
 
+// validateSyntax checks that value is 43 to 128 characters long and only uses the unreserved characters
+// [A-Z] / [a-z] / [0-9] / "-" / "." / "_" / "~" as defined in https://tools.ietf.org/html/rfc7636#section-4.1
+func validateSyntax(base *fosite.RFC6749Error, name, value string) error {
+	if len(value) < 43 {
+		return errors.WithStack(base.
+			WithHintf("The PKCE %s must be at least 43 characters.", name))
+	} else if len(value) > 128 {
+		return errors.WithStack(base.
+			WithHintf("The PKCE %s can not be longer than 128 characters.", name))
+	} else if verifierWrongFormat.MatchString(value) {
+		return errors.WithStack(base.
+			WithHintf("The PKCE %s must only contain [a-Z], [0-9], '-', '.', '_', '~'.", name))
+	}
+	return nil
+}
+
 func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
 	if !request.GetGrantTypes().ExactOne("authorization_code") {
 		return errors.WithStack(fosite.ErrUnknownRequest)
@@ -168,15 +192,8 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 	// 	43-octet URL safe string to use as the code verifier.
 
 	// Validation
-	if len(verifier) < 43 {
-		return errors.WithStack(fosite.ErrInvalidGrant.
-			WithHint("The PKCE code verifier must be at least 43 characters."))
-	} else if len(verifier) > 128 {
-		return errors.WithStack(fosite.ErrInvalidGrant.
-			WithHint("The PKCE code verifier can not be longer than 128 characters."))
-	} else if verifierWrongFormat.MatchString(verifier) {
-		return errors.WithStack(fosite.ErrInvalidGrant.
-			WithHint("The PKCE code verifier must only contain [a-Z], [0-9], '-', '.', '_', '~'."))
+	if err := validateSyntax(fosite.ErrInvalidGrant, "code verifier", verifier); err != nil {
+		return err
 	}
 
 	// Upon receipt of the request at the token endpoint, the server
@@ -227,3 +244,12 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
 	return nil
 }
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *Handler) Capabilities() fosite.Capabilities {
+	methods := []string{"S256"}
+	if c.EnablePlainChallengeMethod {
+		methods = append(methods, "plain")
+	}
+	return fosite.Capabilities{CodeChallengeMethods: methods}
+}
@@ -26,6 +26,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -64,7 +65,8 @@ func TestPKCEHandleAuthorizeEndpointRequest(t *testing.T) {
 
 	w.AddParameter("code", "foo")
 
-	r.Form.Add("code_challenge", "challenge")
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	r.Form.Add("code_challenge", challenge)
 	r.Form.Add("code_challenge_method", "plain")
 
 	r.ResponseTypes = fosite.Arguments{}
@@ -92,10 +94,94 @@ func TestPKCEHandleAuthorizeEndpointRequest(t *testing.T) {
 	h.Force = true
 	require.Error(t, h.HandleAuthorizeEndpointRequest(context.Background(), r, w))
 
-	r.Form.Set("code_challenge", "challenge")
+	r.Form.Set("code_challenge", challenge)
 	require.NoError(t, h.HandleAuthorizeEndpointRequest(context.Background(), r, w))
 }
 
+func TestPKCEHandleAuthorizeEndpointRequestValidatesChallengeSyntax(t *testing.T) {
+	h := &Handler{
+		Storage:                    storage.NewMemoryStore(),
+		AuthorizeCodeStrategy:      new(oauth2.HMACSHAStrategy),
+		EnablePlainChallengeMethod: true,
+	}
+
+	for k, tc := range []struct {
+		d         string
+		challenge string
+		expectErr bool
+	}{
+		{d: "fails with 42 characters", challenge: strings.Repeat("a", 42), expectErr: true},
+		{d: "passes with 43 characters", challenge: strings.Repeat("a", 43)},
+		{d: "passes with 128 characters", challenge: strings.Repeat("a", 128)},
+		{d: "fails with 129 characters", challenge: strings.Repeat("a", 129), expectErr: true},
+		{d: "passes with all unreserved characters", challenge: "ABCXYZabcxyz0189-._~" + strings.Repeat("a", 23)},
+		{d: "fails with a space", challenge: strings.Repeat("a", 42) + " ", expectErr: true},
+		{d: "fails with a plus sign", challenge: strings.Repeat("a", 42) + "+", expectErr: true},
+		{d: "fails with a padding character", challenge: strings.Repeat("a", 42) + "=", expectErr: true},
+		{d: "fails with a non-ascii character", challenge: strings.Repeat("a", 41) + "ä", expectErr: true},
+	} {
+		for _, method := range []string{"plain", "S256"} {
+			t.Run(fmt.Sprintf("case=%d/method=%s/description=%s", k, method, tc.d), func(t *testing.T) {
+				w := fosite.NewAuthorizeResponse()
+				w.AddParameter("code", "foo")
+				r := fosite.NewAuthorizeRequest()
+				r.Client = &fosite.DefaultClient{}
+				r.ResponseTypes = fosite.Arguments{"code"}
+				r.Form.Add("code_challenge", tc.challenge)
+				r.Form.Add("code_challenge_method", method)
+
+				err := h.HandleAuthorizeEndpointRequest(context.Background(), r, w)
+				if tc.expectErr {
+					require.EqualError(t, err, fosite.ErrInvalidRequest.Error())
+				} else {
+					require.NoError(t, err)
+				}
+			})
+		}
+	}
+}
+
+func TestPKCEHandleTokenEndpointRequestValidatesVerifierSyntax(t *testing.T) {
+	s := storage.NewMemoryStore()
+	ms := &mockCodeStrategy{}
+	h := &Handler{Storage: s, AuthorizeCodeStrategy: ms, EnablePlainChallengeMethod: true}
+
+	for k, tc := range []struct {
+		d         string
+		verifier  string
+		expectErr bool
+	}{
+		{d: "fails with 42 characters", verifier: strings.Repeat("a", 42), expectErr: true},
+		{d: "passes with 43 characters", verifier: strings.Repeat("a", 43)},
+		{d: "passes with 128 characters", verifier: strings.Repeat("a", 128)},
+		{d: "fails with 129 characters", verifier: strings.Repeat("a", 129), expectErr: true},
+		{d: "fails with a slash", verifier: strings.Repeat("a", 42) + "/", expectErr: true},
+		{d: "fails with a percent-encoded character", verifier: strings.Repeat("a", 40) + "%20", expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			code := fmt.Sprintf("verifier-syntax-%d", k)
+			ms.signature = code
+
+			// The challenge is stored as is so that only the syntax of the verifier is checked.
+			ar := fosite.NewAuthorizeRequest()
+			ar.Form.Add("code_challenge", tc.verifier)
+			ar.Form.Add("code_challenge_method", "plain")
+			require.NoError(t, s.CreatePKCERequestSession(context.Background(), code, ar))
+
+			r := fosite.NewAccessRequest(nil)
+			r.GrantTypes = fosite.Arguments{"authorization_code"}
+			r.Form.Add("code_verifier", tc.verifier)
+
+			err := h.HandleTokenEndpointRequest(context.Background(), r)
+			if tc.expectErr {
+				require.EqualError(t, err, fosite.ErrInvalidGrant.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestPKCEHandlerValidate(t *testing.T) {
 	s := storage.NewMemoryStore()
 	ms := &mockCodeStrategy{}
@@ -0,0 +1,285 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7523
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// GrantTypeJWTBearer is the grant type of the JWT bearer authorization grant, see
+// https://tools.ietf.org/html/rfc7523#section-2.1
+const GrantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// DefaultMaxAssertionLifespan is the default of Handler.MaxAssertionLifespan.
+const DefaultMaxAssertionLifespan = time.Hour
+
+// SubjectMapper maps the subject of an assertion signed by the issuer to the subject of the issued access token.
+type SubjectMapper func(ctx context.Context, issuer string, subject string) (string, error)
+
+// Handler implements the JWT bearer authorization grant of RFC 7523. The client exchanging the assertion is
+// authenticated by the token endpoint as for every other grant.
+type Handler struct {
+	*oauth2.HandleHelper
+
+	// Storage resolves the keys of the trusted issuers of assertions.
+	Storage RFC7523KeyStorage
+
+	// JTIStore rejects assertions whose "jti" was already used.
+	JTIStore fosite.JTIStore
+
+	ScopeStrategy            fosite.ScopeStrategy
+	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
+
+	// TokenURL is the URL of the token endpoint. Assertions must contain it or the issuer resolved for the request
+	// with IssuerFromRequest in their audiences.
+	TokenURL string
+
+	// IssuerFromRequest resolves the issuer from the HTTP request when several issuers are served from one binary.
+	IssuerFromRequest fosite.IssuerFromRequest
+
+	// MaxAssertionLifespan sets how far in the future assertions may expire. Their "jti" is remembered until then.
+	// Defaults to DefaultMaxAssertionLifespan.
+	MaxAssertionLifespan time.Duration
+
+	// ClockSkew sets how much the clock of the issuer may differ when validating the "exp", "nbf" and "iat" claims.
+	ClockSkew time.Duration
+
+	// SubjectMapper, if set, maps the "sub" claim of assertions to the subject of the issued access tokens. Defaults
+	// to nil, which uses the "sub" claim as is.
+	SubjectMapper SubjectMapper
+}
+
+// GetMaxAssertionLifespan returns MaxAssertionLifespan if set. Defaults to DefaultMaxAssertionLifespan.
+func (c *Handler) GetMaxAssertionLifespan() time.Duration {
+	if c.MaxAssertionLifespan <= 0 {
+		return DefaultMaxAssertionLifespan
+	}
+	return c.MaxAssertionLifespan
+}
+
+// HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc7523#section-2.1 and validates the assertion
+// as described in https://tools.ietf.org/html/rfc7523#section-3
+func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite.AccessRequester) error {
+	if !request.GetGrantTypes().ExactOne(GrantTypeJWTBearer) {
+		return errors.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	client := request.GetClient()
+	if err := fosite.ValidateClientGrantType(client, GrantTypeJWTBearer); err != nil {
+		return err
+	}
+
+	assertion := request.GetRequestForm().Get("assertion")
+	if assertion == "" {
+		return errors.WithStack(fosite.ErrInvalidRequest.WithHintf("The assertion request parameter must be set when using grant_type of '%s'.", GrantTypeJWTBearer))
+	}
+
+	token, err := jwt.ParseSigned(assertion)
+	if err != nil {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to parse the JSON Web Token passed in the assertion request parameter.").WithCause(err).WithDebug(err.Error()))
+	}
+
+	claims, err := c.verifyAssertion(ctx, token, c.audiences(ctx, request))
+	if err != nil {
+		return err
+	}
+
+	allowed, err := c.Storage.GetPublicKeyScopes(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	for _, scope := range request.GetRequestedScopes() {
+		if !c.ScopeStrategy(client.GetScopes(), scope) {
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The OAuth 2.0 Client is not allowed to request scope '%s'.", scope).WithParameter("scope"))
+		} else if !c.ScopeStrategy(allowed, scope) {
+			return errors.WithStack(fosite.ErrInvalidScope.WithHintf("The issuer of the assertion is not allowed to grant scope '%s'.", scope).WithParameter("scope"))
+		}
+	}
+
+	if err := c.AudienceMatchingStrategy(client.GetAudience(), request.GetRequestedAudience()); err != nil {
+		return err
+	}
+
+	subject := claims.Subject
+	if c.SubjectMapper != nil {
+		if subject, err = c.SubjectMapper(ctx, claims.Issuer, claims.Subject); err != nil {
+			return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The subject of the assertion is not known to this authorization server.").WithCause(err).WithDebug(err.Error()))
+		}
+	}
+
+	session, ok := request.GetSession().(Session)
+	if !ok {
+		return errors.WithStack(fosite.ErrServerError.WithDebug("The session must implement rfc7523.Session."))
+	}
+
+	// The assertion is only consumed once it passed all other checks.
+	if err := c.JTIStore.SetClientAssertionJWT(ctx, assertionJTIKey(claims.Issuer, claims.ID), claims.Expiry.Time()); errors.Is(err, fosite.ErrJTIKnown) {
+		return errors.WithStack(fosite.ErrInvalidGrant.WithHint("The 'jti' claim of the assertion was already used."))
+	} else if err != nil {
+		return errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	session.SetSubject(subject)
+	for _, scope := range request.GetRequestedScopes() {
+		request.GrantScope(scope)
+	}
+
+	request.GetSession().SetExpiresAt(fosite.AccessToken, c.Clock.Now().Add(c.AccessTokenLifespan))
+	return nil
+}
+
+// audiences returns the accepted audiences of assertions, the token endpoint URL and the issuer resolved for the request.
+func (c *Handler) audiences(ctx context.Context, request fosite.AccessRequester) []string {
+	issuer := fosite.ResolveIssuer(ctx, c.IssuerFromRequest, "")
+	if ir, ok := request.(fosite.IssuerRequester); ok && ir.GetIssuer() != "" {
+		issuer = ir.GetIssuer()
+	}
+	return fosite.RemoveEmpty([]string{c.TokenURL, issuer})
+}
+
+// verifyAssertion verifies the signature of the assertion with the keys of its issuer and validates its claims.
+func (c *Handler) verifyAssertion(ctx context.Context, token *jwt.JSONWebToken, audiences []string) (*jwt.Claims, error) {
+	var claims jwt.Claims
+	if err := token.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to decode the claims of the assertion.").WithCause(err).WithDebug(err.Error()))
+	} else if claims.Issuer == "" {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("The assertion must contain the 'iss' claim."))
+	} else if claims.Subject == "" {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("The assertion must contain the 'sub' claim."))
+	}
+
+	keys, err := c.findPublicKeys(ctx, token, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var verified bool
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != token.Headers[0].Algorithm {
+			continue
+		} else if err := token.Claims(key.Key, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("Unable to verify the signature of the assertion with the keys of its issuer."))
+	}
+
+	if len(audiences) == 0 {
+		return nil, errors.WithStack(fosite.ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
+	} else if claims.Expiry == nil {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("The assertion must contain the 'exp' claim."))
+	} else if claims.ID == "" {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("The assertion must contain the 'jti' claim."))
+	}
+
+	now := c.Clock.Now()
+	if err := claims.ValidateWithLeeway(jwt.Expected{Time: now}, c.ClockSkew); err != nil {
+		var hint string
+		switch err {
+		case jwt.ErrExpired:
+			hint = "The assertion has expired."
+		case jwt.ErrNotValidYet:
+			hint = "The assertion is not valid yet."
+		case jwt.ErrIssuedInTheFuture:
+			hint = "The assertion was issued in the future."
+		default:
+			hint = "Unable to validate the claims of the assertion."
+		}
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint(hint).WithCause(err).WithDebug(err.Error()))
+	}
+
+	var found bool
+	for _, aud := range audiences {
+		if claims.Audience.Contains(aud) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHint("The 'aud' claim of the assertion must contain the authorization server's token endpoint URL."))
+	}
+
+	if claims.Expiry.Time().After(now.Add(c.GetMaxAssertionLifespan() + c.ClockSkew)) {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHintf("The assertion must expire within %s.", c.GetMaxAssertionLifespan()))
+	}
+
+	return &claims, nil
+}
+
+// findPublicKeys returns the keys the issuer signs assertions for the subject with, only the key with the ID in the
+// header of the assertion if it has one.
+func (c *Handler) findPublicKeys(ctx context.Context, token *jwt.JSONWebToken, claims *jwt.Claims) ([]jose.JSONWebKey, error) {
+	if keyID := token.Headers[0].KeyID; keyID != "" {
+		key, err := c.Storage.GetPublicKey(ctx, claims.Issuer, claims.Subject, keyID)
+		if errors.Is(err, fosite.ErrNotFound) {
+			return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHintf("No public key with ID '%s' is registered for issuer '%s' and subject '%s'.", keyID, claims.Issuer, claims.Subject))
+		} else if err != nil {
+			return nil, errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+		}
+		return []jose.JSONWebKey{*key}, nil
+	}
+
+	keys, err := c.Storage.GetPublicKeys(ctx, claims.Issuer, claims.Subject)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return nil, errors.WithStack(fosite.ErrInvalidGrant.WithHintf("No public keys are registered for issuer '%s' and subject '%s'.", claims.Issuer, claims.Subject))
+	} else if err != nil {
+		return nil, errors.WithStack(fosite.ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+	return keys.Keys, nil
+}
+
+// PopulateTokenEndpointResponse implements https://tools.ietf.org/html/rfc6749#section-5.1
+func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, request fosite.AccessRequester, response fosite.AccessResponder) error {
+	if !request.GetGrantTypes().ExactOne(GrantTypeJWTBearer) {
+		return errors.WithStack(fosite.ErrUnknownRequest)
+	}
+
+	if err := fosite.ValidateClientGrantType(request.GetClient(), GrantTypeJWTBearer); err != nil {
+		return err
+	}
+
+	return c.IssueAccessToken(ctx, request, response)
+}
+
+// Capabilities implements fosite.CapabilitiesHandler.
+func (c *Handler) Capabilities() fosite.Capabilities {
+	return fosite.Capabilities{GrantTypes: []string{GrantTypeJWTBearer}}
+}
+
+// assertionJTIKey returns the key under which the "jti" of an assertion is remembered in the JTIStore. A "jti" is
+// only unique per issuer, and the key is prefixed with the grant type so that it does not collide with the JTIs of
+// client assertions, which share the JTIStore. The issuer is escaped so that it can not contain the separator.
+func assertionJTIKey(issuer, jti string) string {
+	return GrantTypeJWTBearer + ":" + url.QueryEscape(issuer) + ":" + jti
+}
@@ -0,0 +1,391 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7523
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+var _ RFC7523KeyStorage = (*storage.MemoryStore)(nil)
+
+func mustSignAssertion(t *testing.T, key interface{}, keyID string, claims jwt.Claims) string {
+	opts := new(jose.SignerOptions)
+	if keyID != "" {
+		opts = opts.WithHeader(jose.HeaderKey("kid"), keyID)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, opts.WithType("JWT"))
+	require.NoError(t, err)
+	assertion, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return assertion
+}
+
+func TestHandleTokenEndpointRequest(t *testing.T) {
+	key := internal.MustRSAKey()
+	otherKey := internal.MustRSAKey()
+
+	store := storage.NewMemoryStore()
+	store.SetIssuerPublicKeys("https://partner.example.com", "alice", storage.IssuerPublicKeys{
+		Keys:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "partner-key", Algorithm: "RS256", Use: "sig", Key: &key.PublicKey}}},
+		Scopes: []string{"photos"},
+	})
+
+	h := &Handler{
+		HandleHelper:             &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+		Storage:                  store,
+		JTIStore:                 store,
+		ScopeStrategy:            fosite.ExactScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		TokenURL:                 "https://auth.example.com/token",
+	}
+
+	validClaims := func(jti string) jwt.Claims {
+		return jwt.Claims{
+			Issuer:   "https://partner.example.com",
+			Subject:  "alice",
+			Audience: jwt.Audience{"https://auth.example.com/token"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:       jti,
+		}
+	}
+
+	for k, c := range []struct {
+		d          string
+		grantTypes fosite.Arguments
+		scopes     fosite.Arguments
+		assertion  func() string
+		expectErr  error
+		expectHint string
+	}{
+		{
+			d:          "should ignore other grant types",
+			grantTypes: fosite.Arguments{"client_credentials"},
+			assertion:  func() string { return "" },
+			expectErr:  fosite.ErrUnknownRequest,
+		},
+		{
+			d:          "should fail because the assertion is missing",
+			assertion:  func() string { return "" },
+			expectErr:  fosite.ErrInvalidRequest,
+			expectHint: "The assertion request parameter must be set when using grant_type of 'urn:ietf:params:oauth:grant-type:jwt-bearer'.",
+		},
+		{
+			d:          "should fail because the assertion is malformed",
+			assertion:  func() string { return "foo.bar.baz" },
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "Unable to parse the JSON Web Token passed in the assertion request parameter.",
+		},
+		{
+			d:      "should pass with a valid assertion",
+			scopes: fosite.Arguments{"photos"},
+			assertion: func() string {
+				return mustSignAssertion(t, key, "partner-key", validClaims("1"))
+			},
+		},
+		{
+			d: "should pass with a valid assertion without key ID",
+			assertion: func() string {
+				return mustSignAssertion(t, key, "", validClaims("2"))
+			},
+		},
+		{
+			d: "should fail because the assertion was already used",
+			assertion: func() string {
+				return mustSignAssertion(t, key, "partner-key", validClaims("1"))
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The 'jti' claim of the assertion was already used.",
+		},
+		{
+			d: "should fail because the assertion expired",
+			assertion: func() string {
+				claims := validClaims("3")
+				claims.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return mustSignAssertion(t, key, "partner-key", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The assertion has expired.",
+		},
+		{
+			d: "should fail because the assertion expires too far in the future",
+			assertion: func() string {
+				claims := validClaims("4")
+				claims.Expiry = jwt.NewNumericDate(time.Now().Add(2 * time.Hour))
+				return mustSignAssertion(t, key, "partner-key", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The assertion must expire within 1h0m0s.",
+		},
+		{
+			d: "should fail because the assertion is not valid yet",
+			assertion: func() string {
+				claims := validClaims("5")
+				claims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+				return mustSignAssertion(t, key, "partner-key", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The assertion is not valid yet.",
+		},
+		{
+			d: "should fail because the issuer is unknown",
+			assertion: func() string {
+				claims := validClaims("6")
+				claims.Issuer = "https://unknown.example.com"
+				return mustSignAssertion(t, key, "", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "No public keys are registered for issuer 'https://unknown.example.com' and subject 'alice'.",
+		},
+		{
+			d: "should fail because the issuer may not issue assertions for the subject",
+			assertion: func() string {
+				claims := validClaims("7")
+				claims.Subject = "bob"
+				return mustSignAssertion(t, key, "partner-key", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "No public key with ID 'partner-key' is registered for issuer 'https://partner.example.com' and subject 'bob'.",
+		},
+		{
+			d: "should fail because the assertion is signed with another key",
+			assertion: func() string {
+				return mustSignAssertion(t, otherKey, "partner-key", validClaims("8"))
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "Unable to verify the signature of the assertion with the keys of its issuer.",
+		},
+		{
+			d: "should fail because the assertion is intended for another audience",
+			assertion: func() string {
+				claims := validClaims("9")
+				claims.Audience = jwt.Audience{"https://other.example.com/token"}
+				return mustSignAssertion(t, key, "partner-key", claims)
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The 'aud' claim of the assertion must contain the authorization server's token endpoint URL.",
+		},
+		{
+			d: "should fail because the assertion has no jti",
+			assertion: func() string {
+				return mustSignAssertion(t, key, "partner-key", validClaims(""))
+			},
+			expectErr:  fosite.ErrInvalidGrant,
+			expectHint: "The assertion must contain the 'jti' claim.",
+		},
+		{
+			d:      "should fail because the issuer may not grant the scope",
+			scopes: fosite.Arguments{"photos", "offline"},
+			assertion: func() string {
+				return mustSignAssertion(t, key, "partner-key", validClaims("10"))
+			},
+			expectErr:  fosite.ErrInvalidScope,
+			expectHint: "The issuer of the assertion is not allowed to grant scope 'offline'.",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			grantTypes := c.grantTypes
+			if grantTypes == nil {
+				grantTypes = fosite.Arguments{GrantTypeJWTBearer}
+			}
+
+			request := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			request.GrantTypes = grantTypes
+			request.Client = &fosite.DefaultClient{GrantTypes: []string{GrantTypeJWTBearer}, Scopes: []string{"photos", "offline"}}
+			request.Form.Set("assertion", c.assertion())
+			request.SetRequestedScopes(c.scopes)
+
+			err := h.HandleTokenEndpointRequest(context.Background(), request)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				if c.expectHint != "" {
+					assert.Equal(t, c.expectHint, fosite.ErrorToRFC6749Error(err).Hint)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "alice", request.GetSession().GetSubject())
+			assert.ElementsMatch(t, c.scopes, request.GetGrantedScopes())
+			assert.False(t, request.GetSession().GetExpiresAt(fosite.AccessToken).IsZero())
+		})
+	}
+}
+
+func TestHandleTokenEndpointRequestSubjectMapper(t *testing.T) {
+	key := internal.MustRSAKey()
+	store := storage.NewMemoryStore()
+	store.SetIssuerPublicKeys("https://partner.example.com", "alice", storage.IssuerPublicKeys{
+		Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "partner-key", Key: &key.PublicKey}}},
+	})
+
+	h := &Handler{
+		HandleHelper:             &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+		Storage:                  store,
+		JTIStore:                 store,
+		ScopeStrategy:            fosite.ExactScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		TokenURL:                 "https://auth.example.com/token",
+		SubjectMapper: func(_ context.Context, issuer string, subject string) (string, error) {
+			return "partner|" + subject, nil
+		},
+	}
+
+	request := fosite.NewAccessRequest(new(oauth2.JWTSession))
+	request.GrantTypes = fosite.Arguments{GrantTypeJWTBearer}
+	request.Client = &fosite.DefaultClient{GrantTypes: []string{GrantTypeJWTBearer}}
+	request.Form.Set("assertion", mustSignAssertion(t, key, "partner-key", jwt.Claims{
+		Issuer:   "https://partner.example.com",
+		Subject:  "alice",
+		Audience: jwt.Audience{"https://auth.example.com/token"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		ID:       "1",
+	}))
+
+	require.NoError(t, h.HandleTokenEndpointRequest(context.Background(), request))
+	assert.Equal(t, "partner|alice", request.GetSession().GetSubject())
+	assert.Equal(t, "partner|alice", request.GetSession().(*oauth2.JWTSession).JWTClaims.Subject)
+}
+
+func TestHandleTokenEndpointRequestIssuerAudience(t *testing.T) {
+	key := internal.MustRSAKey()
+	store := storage.NewMemoryStore()
+	store.SetIssuerPublicKeys("https://partner.example.com", "alice", storage.IssuerPublicKeys{
+		Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "partner-key", Key: &key.PublicKey}}},
+	})
+
+	h := &Handler{
+		HandleHelper:             &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+		Storage:                  store,
+		JTIStore:                 store,
+		ScopeStrategy:            fosite.ExactScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		TokenURL:                 "https://auth.example.com/token",
+	}
+
+	for k, c := range []struct {
+		d         string
+		issuer    string
+		audience  string
+		expectErr error
+	}{
+		{d: "token url", issuer: "https://tenant.example.com", audience: "https://auth.example.com/token"},
+		{d: "resolved issuer", issuer: "https://tenant.example.com", audience: "https://tenant.example.com"},
+		{d: "issuer of another tenant", issuer: "https://tenant.example.com", audience: "https://other.example.com", expectErr: fosite.ErrInvalidGrant},
+		{d: "no resolved issuer", audience: "https://tenant.example.com", expectErr: fosite.ErrInvalidGrant},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			request := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			request.GrantTypes = fosite.Arguments{GrantTypeJWTBearer}
+			request.Client = &fosite.DefaultClient{GrantTypes: []string{GrantTypeJWTBearer}}
+			request.SetIssuer(c.issuer)
+			request.Form.Set("assertion", mustSignAssertion(t, key, "partner-key", jwt.Claims{
+				Issuer:   "https://partner.example.com",
+				Subject:  "alice",
+				Audience: jwt.Audience{c.audience},
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				ID:       fmt.Sprintf("jti-%d", k),
+			}))
+
+			err := h.HandleTokenEndpointRequest(context.Background(), request)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHandleTokenEndpointRequestJTINamespace(t *testing.T) {
+	key := internal.MustRSAKey()
+	store := storage.NewMemoryStore()
+	for _, issuer := range []string{"https://partner.example.com", "https://other-partner.example.com"} {
+		store.SetIssuerPublicKeys(issuer, "alice", storage.IssuerPublicKeys{
+			Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "partner-key", Key: &key.PublicKey}}},
+		})
+	}
+
+	h := &Handler{
+		HandleHelper:             &oauth2.HandleHelper{AccessTokenLifespan: time.Hour},
+		Storage:                  store,
+		JTIStore:                 store,
+		ScopeStrategy:            fosite.ExactScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		TokenURL:                 "https://auth.example.com/token",
+	}
+
+	// A client assertion with the same "jti" must not consume the assertion.
+	require.NoError(t, store.SetClientAssertionJWT(context.Background(), "shared-jti", time.Now().Add(time.Minute)))
+
+	for k, c := range []struct {
+		d         string
+		issuer    string
+		expectErr error
+	}{
+		{d: "should pass although a client assertion used the jti", issuer: "https://partner.example.com"},
+		{d: "should pass because the jti was used by another issuer", issuer: "https://other-partner.example.com"},
+		{d: "should fail because the issuer already used the jti", issuer: "https://partner.example.com", expectErr: fosite.ErrInvalidGrant},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			request := fosite.NewAccessRequest(new(fosite.DefaultSession))
+			request.GrantTypes = fosite.Arguments{GrantTypeJWTBearer}
+			request.Client = &fosite.DefaultClient{GrantTypes: []string{GrantTypeJWTBearer}}
+			request.Form.Set("assertion", mustSignAssertion(t, key, "partner-key", jwt.Claims{
+				Issuer:   c.issuer,
+				Subject:  "alice",
+				Audience: jwt.Audience{"https://auth.example.com/token"},
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				ID:       "shared-jti",
+			}))
+
+			err := h.HandleTokenEndpointRequest(context.Background(), request)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHandleTokenEndpointRequestUnauthorizedClient(t *testing.T) {
+	h := &Handler{HandleHelper: new(oauth2.HandleHelper)}
+	request := fosite.NewAccessRequest(new(fosite.DefaultSession))
+	request.GrantTypes = fosite.Arguments{GrantTypeJWTBearer}
+	request.Client = &fosite.DefaultClient{GrantTypes: []string{"client_credentials"}}
+
+	err := h.HandleTokenEndpointRequest(context.Background(), request)
+	require.EqualError(t, err, fosite.ErrUnauthorizedClient.Error())
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package rfc7523
+
+import (
+	"context"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// RFC7523KeyStorage resolves the public keys of trusted issuers of JWT bearer assertions, see
+// https://tools.ietf.org/html/rfc7523#section-3. Keys are registered per issuer and subject: an issuer may only
+// issue assertions for the subjects it has keys for.
+type RFC7523KeyStorage interface {
+	// GetPublicKey returns the key with the given ID the issuer uses to sign assertions for the subject, or
+	// fosite.ErrNotFound.
+	GetPublicKey(ctx context.Context, issuer string, subject string, keyID string) (*jose.JSONWebKey, error)
+
+	// GetPublicKeys returns all keys the issuer uses to sign assertions for the subject, or fosite.ErrNotFound if
+	// the issuer is not trusted to issue assertions for the subject.
+	GetPublicKeys(ctx context.Context, issuer string, subject string) (*jose.JSONWebKeySet, error)
+
+	// GetPublicKeyScopes returns the scopes which may be requested with assertions of the issuer for the subject.
+	GetPublicKeyScopes(ctx context.Context, issuer string, subject string) ([]string, error)
+}
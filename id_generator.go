@@ -0,0 +1,41 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "github.com/pborman/uuid"
+
+// IDGenerator returns unique identifiers, for example for the "jti" claim of tokens or for the ID of requests which
+// storage implementations use to look up and revoke tokens. Use it to embed your own scheme, for example ULIDs. The
+// identifiers must be unique across all requests and tokens. A nil IDGenerator, or one returning an empty string,
+// generates random UUIDs.
+type IDGenerator func() string
+
+// New returns a new identifier.
+func (g IDGenerator) New() string {
+	if g == nil {
+		return uuid.New()
+	}
+	if id := g(); id != "" {
+		return id
+	}
+	return uuid.New()
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite/compose"
+)
+
+func TestAccessResponseParameters(t *testing.T) {
+	f := compose.Compose(&compose.Config{AccessTokenLifespan: time.Hour}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory)
+	ts := mockServer(t, f, nil)
+	defer ts.Close()
+
+	for k, c := range []struct {
+		d           string
+		scope       string
+		expectScope string
+	}{
+		{
+			d:           "should echo the granted scope",
+			scope:       "fosite",
+			expectScope: "fosite",
+		},
+		{
+			d:           "should include the granted scope of a downscoped response",
+			scope:       "fosite openid",
+			expectScope: "fosite",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(url.Values{
+				"grant_type": {"client_credentials"},
+				"scope":      {c.scope},
+			}.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("my-client", "foobar")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, http.StatusOK, resp.StatusCode, "%+v", body)
+
+			// JSON numbers are decoded as float64, a whole number of seconds has no fractional part.
+			expiresIn, ok := body["expires_in"].(float64)
+			require.True(t, ok, "%+v", body)
+			assert.Equal(t, float64(int64(expiresIn)), expiresIn)
+			assert.True(t, expiresIn >= 3599 && expiresIn <= 3600, "%v", expiresIn)
+			assert.Equal(t, c.expectScope, body["scope"])
+		})
+	}
+}
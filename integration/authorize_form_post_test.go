@@ -22,7 +22,10 @@
 package integration_test
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
@@ -182,3 +185,52 @@ func TestAuthorizeFormPostResponseMode(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeFormPostCustomTemplate(t *testing.T) {
+	formPostTemplate := template.Must(template.New("form_post").Parse(`<html>
+   <head>
+      <title>Acme Corp</title>
+   </head>
+   <body>
+      <form method="post" action="{{ .RedirURL }}">
+         {{ range $key,$value := .Parameters }}{{ range $parameter:= $value}}
+            <input type="hidden" name="{{$key}}" value="{{$parameter}}"/>
+         {{ end }}{{ end }}
+      </form>
+      <script type="text/javascript" nonce="{{ .Nonce }}">document.forms[0].submit();</script>
+      <p id="acme-marker">Redirecting you back to {{ .RedirURL }}</p>
+   </body>
+</html>`))
+
+	f := compose.ComposeAllEnabled(&compose.Config{FormPostHTMLTemplate: formPostTemplate}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	defaultClient := fositeStore.Clients["my-client"].(*fosite.DefaultClient)
+	defaultClient.RedirectURIs[0] = ts.URL + "/callback"
+	fositeStore.Clients["response-mode-client"] = &fosite.DefaultResponseModeClient{
+		DefaultClient: defaultClient,
+		ResponseModes: []fosite.ResponseModeType{fosite.ResponseModeFormPost},
+	}
+	oauthClient.ClientID = "response-mode-client"
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errors.New("Dont follow redirects")
+		},
+	}
+	resp, err := client.Get(oauthClient.AuthCodeURL("12345678901234567890", goauth.SetAuthURLParam("response_mode", "form_post")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `<p id="acme-marker">Redirecting you back to `+ts.URL+`/callback</p>`)
+
+	code, state, _, _, _, _, err := internal.ParseFormPostResponse(ts.URL+"/callback", ioutil.NopCloser(bytes.NewReader(body)))
+	require.NoError(t, err)
+	assert.Equal(t, "12345678901234567890", state)
+	assert.NotEmpty(t, code)
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestAuthorizeJWTResponseModes(t *testing.T) {
+	session := &defaultSession{
+		DefaultSession: &openid.DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject: "peter",
+			},
+			Headers: &jwt.Headers{},
+		},
+	}
+	jarmKey := internal.MustRSAKey()
+	f := compose.ComposeAllEnabled(&compose.Config{
+		JARMSigningKey: jarmKey,
+		JARMIssuer:     "https://jarm.example.com",
+	}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, session)
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	oauthClient.ClientID = "jarm-client"
+	oauthClient.Scopes = []string{"openid"}
+	fositeStore.Clients["jarm-client"] = &fosite.DefaultResponseModeClient{
+		DefaultClient: &fosite.DefaultClient{
+			ID:            "jarm-client",
+			Secret:        []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+			RedirectURIs:  []string{ts.URL + "/callback"},
+			ResponseTypes: []string{"code", "token", "id_token token"},
+			GrantTypes:    []string{"implicit", "authorization_code"},
+			Scopes:        []string{"openid"},
+		},
+		ResponseModes: []fosite.ResponseModeType{fosite.ResponseModeJWT, fosite.ResponseModeQueryJWT, fosite.ResponseModeFragmentJWT, fosite.ResponseModeFormPostJWT},
+	}
+
+	const state = "12345678901234567890"
+	for k, c := range []struct {
+		description  string
+		responseType string
+		responseMode fosite.ResponseModeType
+		transport    fosite.ResponseModeType
+		check        func(t *testing.T, claims jwtgo.MapClaims)
+	}{
+		{
+			description:  "should pass authorize code grant with response mode query.jwt",
+			responseType: "code",
+			responseMode: fosite.ResponseModeQueryJWT,
+			transport:    fosite.ResponseModeQuery,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.NotEmpty(t, claims["code"])
+			},
+		},
+		{
+			description:  "should pass authorize code grant with response mode jwt using the query",
+			responseType: "code",
+			responseMode: fosite.ResponseModeJWT,
+			transport:    fosite.ResponseModeQuery,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.NotEmpty(t, claims["code"])
+			},
+		},
+		{
+			description:  "should pass authorize code grant with response mode fragment.jwt",
+			responseType: "code",
+			responseMode: fosite.ResponseModeFragmentJWT,
+			transport:    fosite.ResponseModeFragment,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.NotEmpty(t, claims["code"])
+			},
+		},
+		{
+			description:  "should pass authorize code grant with response mode form_post.jwt",
+			responseType: "code",
+			responseMode: fosite.ResponseModeFormPostJWT,
+			transport:    fosite.ResponseModeFormPost,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.NotEmpty(t, claims["code"])
+			},
+		},
+		{
+			description:  "should pass implicit grant with response mode jwt using the fragment",
+			responseType: "id_token token",
+			responseMode: fosite.ResponseModeJWT,
+			transport:    fosite.ResponseModeFragment,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.NotEmpty(t, claims["access_token"])
+				assert.NotEmpty(t, claims["id_token"])
+			},
+		},
+		{
+			description:  "should fail implicit grant with insecure response mode query.jwt and sign the error",
+			responseType: "token",
+			responseMode: fosite.ResponseModeQueryJWT,
+			transport:    fosite.ResponseModeQuery,
+			check: func(t *testing.T, claims jwtgo.MapClaims) {
+				assert.Equal(t, "unsupported_response_mode", claims["error"])
+				assert.Empty(t, claims["access_token"])
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			authURL := strings.Replace(oauthClient.AuthCodeURL(state, goauth.SetAuthURLParam("response_mode", string(c.responseMode)), goauth.SetAuthURLParam("nonce", "111111111")), "response_type=code", "response_type="+url.QueryEscape(c.responseType), -1)
+			var callbackURL *url.URL
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					callbackURL = req.URL
+					return errors.New("Dont follow redirects")
+				},
+			}
+
+			resp, err := client.Get(authURL)
+			var parameters url.Values
+			switch c.transport {
+			case fosite.ResponseModeQuery:
+				require.Error(t, err)
+				require.NotNil(t, callbackURL)
+				parameters = callbackURL.Query()
+			case fosite.ResponseModeFragment:
+				require.Error(t, err)
+				require.NotNil(t, callbackURL)
+				parameters, err = url.ParseQuery(callbackURL.Fragment)
+				require.NoError(t, err)
+			case fosite.ResponseModeFormPost:
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				_, _, _, _, parameters, _, err = internal.ParseFormPostResponse(ts.URL+"/callback", resp.Body)
+				require.NoError(t, err)
+			}
+
+			// Only the signed response is sent to the client.
+			require.NotEmpty(t, parameters.Get("response"))
+			assert.Empty(t, parameters.Get("state"))
+			assert.Empty(t, parameters.Get("code"))
+
+			claims := jwtgo.MapClaims{}
+			token, err := jwtgo.ParseWithClaims(parameters.Get("response"), claims, func(token *jwtgo.Token) (interface{}, error) {
+				return &jarmKey.PublicKey, nil
+			})
+			require.NoError(t, err)
+			require.True(t, token.Valid)
+
+			assert.Equal(t, "https://jarm.example.com", claims["iss"])
+			assert.Equal(t, "jarm-client", claims["aud"])
+			assert.NotEmpty(t, claims["exp"])
+			assert.Equal(t, state, claims["state"])
+			c.check(t, claims)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestAuthorizeResponseTypeNoneWithPromptNone(t *testing.T) {
+	session := &defaultSession{
+		DefaultSession: &openid.DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject:     "peter",
+				AuthTime:    time.Now().UTC().Add(-time.Hour),
+				RequestedAt: time.Now().UTC(),
+			},
+			Headers: &jwt.Headers{},
+		},
+	}
+	f := compose.ComposeAllEnabled(&compose.Config{}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, session)
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	oauthClient.ClientID = "none-client"
+	fositeStore.Clients["none-client"] = &fosite.DefaultClient{
+		ID:            "none-client",
+		Secret:        []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		RedirectURIs:  []string{ts.URL + "/callback"},
+		ResponseTypes: []string{"none"},
+		Scopes:        []string{"openid", "photos"},
+	}
+
+	const state = "12345678901234567890"
+	for k, c := range []struct {
+		description string
+		scopes      []string
+		check       func(t *testing.T, query url.Values)
+	}{
+		{
+			description: "should only return the state because the end-user consented",
+			scopes:      []string{"openid"},
+			check: func(t *testing.T, query url.Values) {
+				assert.Equal(t, url.Values{"state": {state}}, query)
+			},
+		},
+		{
+			description: "should return consent_required because the end-user did not consent to scope photos",
+			scopes:      []string{"openid", "photos"},
+			check: func(t *testing.T, query url.Values) {
+				assert.Equal(t, "consent_required", query.Get("error"))
+				assert.Equal(t, state, query.Get("state"))
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
+			oauthClient.Scopes = c.scopes
+			authURL := strings.Replace(oauthClient.AuthCodeURL(state, goauth.SetAuthURLParam("prompt", "none")), "response_type=code", "response_type=none", -1)
+
+			var callbackURL *url.URL
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					callbackURL = req.URL
+					return errors.New("Dont follow redirects")
+				},
+			}
+
+			_, err := client.Get(authURL)
+			require.Error(t, err)
+			require.NotNil(t, callbackURL)
+			c.check(t, callbackURL.Query())
+		})
+	}
+}
@@ -54,7 +54,8 @@ func TestAuthorizeResponseModes(t *testing.T) {
 			Headers: &jwt.Headers{},
 		},
 	}
-	f := compose.ComposeAllEnabled(new(compose.Config), fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	// Hints are only sent to clients in debug mode.
+	f := compose.ComposeAllEnabled(&compose.Config{SendDebugMessagesToClients: true}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
 	ts := mockServer(t, f, session)
 	defer ts.Close()
 
@@ -182,6 +183,70 @@ func TestAuthorizeResponseModes(t *testing.T) {
 				assert.NotEmpty(t, token.Expiry)
 			},
 		},
+		{
+			description:  "Should only return the state for response type none with response mode query",
+			responseType: "none",
+			responseMode: "query",
+			setup: func() {
+				state = "12345678901234567890"
+				oauthClient.Scopes = []string{"openid"}
+				responseModeClient.ResponseModes = []fosite.ResponseModeType{fosite.ResponseModeQuery}
+			},
+			check: func(t *testing.T, stateFromServer string, code string, token goauth.Token, iDToken string, err map[string]string) {
+				assert.Empty(t, err)
+				assert.EqualValues(t, state, stateFromServer)
+				assert.Empty(t, code)
+				assert.Empty(t, token.AccessToken)
+				assert.Empty(t, iDToken)
+			},
+		},
+		{
+			description:  "Should only return the state for response type none with response mode fragment",
+			responseType: "none",
+			responseMode: "fragment",
+			setup: func() {
+				state = "12345678901234567890"
+				oauthClient.Scopes = []string{"openid"}
+				responseModeClient.ResponseModes = []fosite.ResponseModeType{fosite.ResponseModeFragment}
+			},
+			check: func(t *testing.T, stateFromServer string, code string, token goauth.Token, iDToken string, err map[string]string) {
+				assert.Empty(t, err)
+				assert.EqualValues(t, state, stateFromServer)
+				assert.Empty(t, code)
+				assert.Empty(t, token.AccessToken)
+				assert.Empty(t, iDToken)
+			},
+		},
+		{
+			description:  "Should only return the state for response type none with response mode form_post",
+			responseType: "none",
+			responseMode: "form_post",
+			setup: func() {
+				state = "12345678901234567890"
+				oauthClient.Scopes = []string{"openid"}
+				responseModeClient.ResponseModes = []fosite.ResponseModeType{fosite.ResponseModeFormPost}
+			},
+			check: func(t *testing.T, stateFromServer string, code string, token goauth.Token, iDToken string, err map[string]string) {
+				assert.Empty(t, err)
+				assert.EqualValues(t, state, stateFromServer)
+				assert.Empty(t, code)
+				assert.Empty(t, token.AccessToken)
+				assert.Empty(t, iDToken)
+			},
+		},
+		{
+			description:  "Should fail because response type none is combined with code",
+			responseType: "none%20code",
+			responseMode: "query",
+			setup: func() {
+				state = "12345678901234567890"
+				responseModeClient.ResponseModes = []fosite.ResponseModeType{fosite.ResponseModeQuery}
+			},
+			check: func(t *testing.T, stateFromServer string, code string, token goauth.Token, iDToken string, err map[string]string) {
+				assert.Equal(t, "unsupported_response_type", err["Name"])
+				assert.Equal(t, "The response_type 'none' can not be combined with other response types.", err["Hint"])
+			},
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.description), func(t *testing.T) {
 			c.setup()
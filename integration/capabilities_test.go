@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+func TestCapabilities(t *testing.T) {
+	secret := []byte("some-secret-thats-random-some-secret-thats-random-")
+	key := internal.MustRSAKey()
+	newProvider := func(config *compose.Config, factories ...compose.Factory) fosite.ExtendedOAuth2Provider {
+		return compose.Compose(config, storage.NewMemoryStore(), &compose.CommonStrategy{
+			CoreStrategy:               compose.NewOAuth2HMACStrategy(config, secret, nil),
+			OpenIDConnectTokenStrategy: compose.NewOpenIDConnectStrategy(config, key),
+		}, nil, factories...).(fosite.ExtendedOAuth2Provider)
+	}
+
+	t.Run("case=no handlers", func(t *testing.T) {
+		assert.Equal(t, fosite.Capabilities{}, newProvider(new(compose.Config)).Capabilities())
+	})
+
+	t.Run("case=authorize code flow", func(t *testing.T) {
+		c := newProvider(new(compose.Config), compose.OAuth2AuthorizeExplicitFactory).Capabilities()
+		assert.Equal(t, []string{"authorization_code"}, c.GrantTypes)
+		assert.Equal(t, []string{"code", "none"}, c.ResponseTypes)
+		assert.Equal(t, []fosite.ResponseModeType{fosite.ResponseModeQuery, fosite.ResponseModeFragment, fosite.ResponseModeFormPost, fosite.ResponseModeWebMessage}, c.ResponseModes)
+		assert.Empty(t, c.CodeChallengeMethods)
+		assert.Equal(t, []string{"client_secret_basic", "client_secret_post", "none", "tls_client_auth", "self_signed_tls_client_auth"}, c.TokenEndpointAuthMethods)
+	})
+
+	t.Run("case=enabling factories adds capabilities", func(t *testing.T) {
+		c := newProvider(&compose.Config{EnablePKCEPlainChallengeMethod: true, TokenURL: "https://example.com/token", JARMSigningKey: key},
+			compose.OAuth2AuthorizeExplicitFactory,
+			compose.OAuth2AuthorizeImplicitFactory,
+			compose.OAuth2ClientCredentialsGrantFactory,
+			compose.OAuth2RefreshTokenGrantFactory,
+			compose.OpenIDConnectImplicitFactory,
+			compose.OpenIDConnectHybridFactory,
+			compose.OAuth2PKCEFactory,
+		).Capabilities()
+		assert.Equal(t, []string{"authorization_code", "implicit", "client_credentials", "refresh_token"}, c.GrantTypes)
+		assert.Equal(t, []string{"code", "token", "id_token", "id_token token", "code id_token", "code token", "code id_token token", "none"}, c.ResponseTypes)
+		assert.Contains(t, c.ResponseModes, fosite.ResponseModeQueryJWT)
+		assert.Equal(t, []string{"S256", "plain"}, c.CodeChallengeMethods)
+		assert.Contains(t, c.TokenEndpointAuthMethods, "private_key_jwt")
+	})
+
+	t.Run("case=disabling factories removes capabilities", func(t *testing.T) {
+		c := newProvider(new(compose.Config),
+			compose.OAuth2ClientCredentialsGrantFactory,
+			compose.OAuth2ResourceOwnerPasswordCredentialsFactory,
+		).Capabilities()
+		assert.Equal(t, []string{"client_credentials", "password"}, c.GrantTypes)
+		assert.Empty(t, c.ResponseTypes)
+		assert.Empty(t, c.ResponseModes)
+		assert.Empty(t, c.CodeChallengeMethods)
+		assert.NotContains(t, c.TokenEndpointAuthMethods, "private_key_jwt")
+	})
+
+	t.Run("case=client authentication methods follow the allowed assertion algorithms", func(t *testing.T) {
+		for k, tc := range []struct {
+			config   *compose.Config
+			expected []string
+		}{
+			{
+				config:   &compose.Config{TokenURL: "https://example.com/token"},
+				expected: []string{"client_secret_basic", "client_secret_post", "none", "tls_client_auth", "self_signed_tls_client_auth", "client_secret_jwt", "private_key_jwt"},
+			},
+			{
+				config:   &compose.Config{TokenURL: "https://example.com/token", TokenEndpointAuthSigningAlgorithms: []string{"ES256"}},
+				expected: []string{"client_secret_basic", "client_secret_post", "none", "tls_client_auth", "self_signed_tls_client_auth", "private_key_jwt"},
+			},
+			{
+				config:   &compose.Config{TokenURL: "https://example.com/token", TokenEndpointAuthSigningAlgorithms: []string{"HS256"}},
+				expected: []string{"client_secret_basic", "client_secret_post", "none", "tls_client_auth", "self_signed_tls_client_auth", "client_secret_jwt"},
+			},
+			{
+				config:   &compose.Config{IssuerFromRequest: func(*http.Request) string { return "https://example.com" }},
+				expected: []string{"client_secret_basic", "client_secret_post", "none", "tls_client_auth", "self_signed_tls_client_auth", "client_secret_jwt", "private_key_jwt"},
+			},
+		} {
+			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+				c := newProvider(tc.config, compose.OAuth2ClientCredentialsGrantFactory).Capabilities()
+				assert.Equal(t, tc.expected, c.TokenEndpointAuthMethods)
+			})
+		}
+	})
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/rfc7523"
+)
+
+func TestTokenEndpointRejectsGrantTypesNotAllowedForClient(t *testing.T) {
+	fositeStore.Clients["implicit-client"] = &fosite.DefaultClient{
+		ID:         "implicit-client",
+		Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		GrantTypes: []string{"implicit"},
+		Scopes:     []string{"fosite"},
+	}
+	defer delete(fositeStore.Clients, "implicit-client")
+
+	f := compose.Compose(&compose.Config{SendDebugMessagesToClients: true}, fositeStore, hmacStrategy, nil,
+		compose.OAuth2AuthorizeExplicitFactory,
+		compose.OAuth2RefreshTokenGrantFactory,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		compose.OAuth2ResourceOwnerPasswordCredentialsFactory,
+		compose.RFC7523AssertionGrantFactory,
+	)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	for k, grantType := range []string{
+		"authorization_code",
+		"refresh_token",
+		"client_credentials",
+		"password",
+		rfc7523.GrantTypeJWTBearer,
+	} {
+		t.Run(fmt.Sprintf("case=%d/grant_type=%s", k, grantType), func(t *testing.T) {
+			req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(url.Values{"grant_type": {grantType}}.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("implicit-client", "foobar")
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			assert.Equal(t, "unauthorized_client", body["error"])
+			assert.Equal(t, fmt.Sprintf("The OAuth 2.0 Client is not allowed to use authorization grant '%s'.", grantType), body["error_hint"])
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite/compose"
+)
+
+func TestClientCredentialsDefaultScopes(t *testing.T) {
+	for k, c := range []struct {
+		d             string
+		defaultScopes []string
+		scope         string
+		expectCode    int
+		expectScope   string
+	}{
+		{
+			d:             "should grant the default scopes if the scope is omitted",
+			defaultScopes: []string{"fosite"},
+			expectCode:    http.StatusOK,
+			expectScope:   "fosite",
+		},
+		{
+			d:             "should not apply the default scopes if the scope is requested",
+			defaultScopes: []string{"fosite", "offline"},
+			scope:         "fosite",
+			expectCode:    http.StatusOK,
+			expectScope:   "fosite",
+		},
+		{
+			d:             "should fail because the default scopes exceed the client's scopes",
+			defaultScopes: []string{"fosite", "admin"},
+			expectCode:    http.StatusBadRequest,
+		},
+		{
+			d:          "should fail because the requested scopes exceed the client's scopes",
+			scope:      "fosite admin",
+			expectCode: http.StatusBadRequest,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := compose.Compose(&compose.Config{DefaultScopes: c.defaultScopes}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory)
+			ts := mockServer(t, f, nil)
+			defer ts.Close()
+
+			form := url.Values{"grant_type": {"client_credentials"}}
+			if c.scope != "" {
+				form.Set("scope", c.scope)
+			}
+			req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(form.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("my-client", "foobar")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, c.expectCode, resp.StatusCode, "%+v", body)
+			if c.expectCode != http.StatusOK {
+				assert.Equal(t, "invalid_scope", body["error"])
+				return
+			}
+			assert.Equal(t, c.expectScope, body["scope"])
+		})
+	}
+}
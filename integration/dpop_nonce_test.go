@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, method, uri, nonce, accessToken string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{EmbedJWK: true}).WithType("dpop+jwt"))
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"jti": time.Now().String(),
+		"htm": method,
+		"htu": uri,
+		"iat": time.Now().Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	object, err := signer.Sign(payload)
+	require.NoError(t, err)
+	proof, err := object.CompactSerialize()
+	require.NoError(t, err)
+	return proof
+}
+
+func TestDPoPNonceHandshake(t *testing.T) {
+	f := compose.Compose(&compose.Config{
+		DPoPNonceStrategy: &fosite.HMACDPoPNonceStrategy{Secret: []byte("some-super-secret-dpop-nonce-secret-32")},
+	}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	request := func(nonce string) (*http.Response, map[string]interface{}) {
+		res, body, errs := gorequest.New().Post(ts.URL+"/token").
+			SetBasicAuth("my-client", "foobar").
+			Set(fosite.DPoPHeader, newDPoPProof(t, key, "POST", ts.URL+"/token", nonce, "")).
+			Type("form").
+			SendStruct(map[string]string{"grant_type": "client_credentials", "scope": "fosite"}).
+			End()
+		require.Len(t, errs, 0)
+
+		var result map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &result))
+		return res, result
+	}
+
+	// The first request is challenged to use a nonce.
+	res, body := request("")
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "use_dpop_nonce", body["error"])
+	nonce := res.Header.Get(fosite.DPoPNonceHeader)
+	require.NotEmpty(t, nonce)
+
+	// The second request with the nonce is accepted and yields a DPoP-bound access token.
+	res, body = request(nonce)
+	assert.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+	assert.Equal(t, fosite.DPoPAccessTokenType, body["token_type"])
+	accessToken, _ := body["access_token"].(string)
+	require.NotEmpty(t, accessToken)
+
+	_, other := request(nonce)
+	introspect := func(proof string) (*http.Response, string) {
+		req := gorequest.New().Post(ts.URL+"/introspect").
+			Set("Authorization", "DPoP "+accessToken).
+			Type("form").
+			SendStruct(map[string]string{"token": other["access_token"].(string)})
+		if proof != "" {
+			req = req.Set(fosite.DPoPHeader, proof)
+		}
+		res, body, errs := req.End()
+		require.Len(t, errs, 0)
+		return res, body
+	}
+
+	// The DPoP-bound access token authorizing the introspection request requires a proof.
+	res, _ = introspect("")
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, introspection := introspect(newDPoPProof(t, key, "POST", ts.URL+"/introspect", nonce, accessToken))
+	assert.Equal(t, http.StatusOK, res.StatusCode, introspection)
+	assert.Contains(t, introspection, `"active":true`)
+}
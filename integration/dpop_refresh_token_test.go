@@ -0,0 +1,96 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func TestDPoPBoundRefreshToken(t *testing.T) {
+	f := compose.Compose(&compose.Config{RefreshTokenScopes: []string{}}, fositeStore, hmacStrategy, nil,
+		compose.OAuth2ResourceOwnerPasswordCredentialsFactory, compose.OAuth2RefreshTokenGrantFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	fositeStore.Clients["public-dpop-client"] = &fosite.DefaultClient{
+		ID:         "public-dpop-client",
+		Public:     true,
+		GrantTypes: []string{"password", "refresh_token"},
+		Scopes:     []string{"fosite", "offline"},
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	request := func(key *ecdsa.PrivateKey, form map[string]string) (*http.Response, map[string]interface{}) {
+		form["client_id"] = "public-dpop-client"
+		res, body, errs := gorequest.New().Post(ts.URL+"/token").
+			Set(fosite.DPoPHeader, newDPoPProof(t, key, "POST", ts.URL+"/token", "", "")).
+			Type("form").
+			SendStruct(form).
+			End()
+		require.Len(t, errs, 0)
+
+		var result map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &result))
+		return res, result
+	}
+
+	res, body := request(key, map[string]string{"grant_type": "password", "username": "peter", "password": "secret", "scope": "fosite offline"})
+	require.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+	assert.Equal(t, fosite.DPoPAccessTokenType, body["token_type"])
+	refreshToken, _ := body["refresh_token"].(string)
+	require.NotEmpty(t, refreshToken)
+
+	// The refresh token of the public client is bound to the DPoP key and can not be used with a different one.
+	res, body = request(otherKey, map[string]string{"grant_type": "refresh_token", "refresh_token": refreshToken})
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	res, body = request(key, map[string]string{"grant_type": "refresh_token", "refresh_token": refreshToken})
+	require.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+	assert.Equal(t, fosite.DPoPAccessTokenType, body["token_type"])
+	rotated, _ := body["refresh_token"].(string)
+	require.NotEmpty(t, rotated)
+
+	// The rotated refresh token remains bound to the same key.
+	res, body = request(otherKey, map[string]string{"grant_type": "refresh_token", "refresh_token": rotated})
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	res, body = request(key, map[string]string{"grant_type": "refresh_token", "refresh_token": rotated})
+	assert.Equal(t, http.StatusOK, res.StatusCode, "%+v", body)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestEnabledResponseTypes(t *testing.T) {
+	f := compose.ComposeAllEnabled(&compose.Config{
+		EnabledResponseTypes: []string{"code", "code id_token"},
+	}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, newIDSession(&jwt.IDTokenClaims{Subject: "peter"}))
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	oauthClient.Scopes = []string{"openid"}
+	fositeStore.Clients["my-client"].(*fosite.DefaultClient).RedirectURIs[0] = ts.URL + "/callback"
+
+	for k, c := range []struct {
+		d             string
+		responseType  string
+		expectAuthErr string
+	}{
+		{
+			d:             "should fail because the implicit flow is disabled",
+			responseType:  "token",
+			expectAuthErr: "unsupported_response_type",
+		},
+		{
+			d:             "should fail because the implicit flow with an ID token is disabled",
+			responseType:  "id_token token",
+			expectAuthErr: "unsupported_response_type",
+		},
+		{
+			d:             "should fail because the hybrid flow returning an access token is disabled",
+			responseType:  "code token",
+			expectAuthErr: "unsupported_response_type",
+		},
+		{
+			d:            "should pass because the hybrid flow returning an ID token is enabled",
+			responseType: "id_token code",
+		},
+		{
+			d:            "should pass because the code flow is enabled",
+			responseType: "code",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			var callbackURL *url.URL
+			client := &http.Client{
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					callbackURL = req.URL
+					return errors.New("Dont follow redirects")
+				},
+			}
+
+			_, err := client.Get(oauthClient.AuthCodeURL("12345678901234567890",
+				oauth2.SetAuthURLParam("response_type", c.responseType),
+				oauth2.SetAuthURLParam("nonce", "11111111111111111111"),
+			))
+			require.Error(t, err)
+			require.NotNil(t, callbackURL)
+
+			// Errors which occur before the response mode is known are always returned in the query.
+			params := callbackURL.Query()
+			if c.responseType != "code" && params.Get("error") == "" {
+				params, err = url.ParseQuery(callbackURL.Fragment)
+				require.NoError(t, err)
+			}
+
+			if c.expectAuthErr != "" {
+				assert.Equal(t, c.expectAuthErr, params.Get("error"))
+				return
+			}
+			require.Empty(t, params.Get("error"), "%s", params.Get("error_description"))
+
+			token, err := oauthClient.Exchange(oauth2.NoContext, params.Get("code"))
+			require.NoError(t, err)
+			assert.NotEmpty(t, token.AccessToken)
+			assert.NotEmpty(t, token.Extra("id_token"))
+		})
+	}
+}
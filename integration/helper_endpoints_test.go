@@ -40,7 +40,7 @@ type stackTracer interface {
 
 func tokenRevocationHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session fosite.Session) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		ctx := fosite.NewContext()
+		ctx := req.Context()
 		err := oauth2.NewRevocationRequest(ctx, req)
 		if err != nil {
 			t.Logf("Revoke request failed because %+v", err)
@@ -51,7 +51,7 @@ func tokenRevocationHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session
 
 func tokenIntrospectionHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session fosite.Session) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		ctx := fosite.NewContext()
+		ctx := req.Context()
 		ar, err := oauth2.NewIntrospectionRequest(ctx, req, session)
 		if err != nil {
 			t.Logf("Introspection request failed because: %+v", err)
@@ -65,7 +65,7 @@ func tokenIntrospectionHandler(t *testing.T, oauth2 fosite.OAuth2Provider, sessi
 
 func tokenInfoHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session fosite.Session) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		ctx := fosite.NewContext()
+		ctx := req.Context()
 		_, resp, err := oauth2.IntrospectToken(ctx, fosite.AccessTokenFromRequest(req), fosite.AccessToken, session)
 		if err != nil {
 			t.Logf("Info request failed because: %+v", err)
@@ -85,7 +85,7 @@ func tokenInfoHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session fosite
 
 func authEndpointHandler(t *testing.T, oauth2 fosite.OAuth2Provider, session fosite.Session) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		ctx := fosite.NewContext()
+		ctx := req.Context()
 
 		ar, err := oauth2.NewAuthorizeRequest(ctx, req)
 		if err != nil {
@@ -148,7 +148,7 @@ func authCallbackHandler(t *testing.T) func(rw http.ResponseWriter, req *http.Re
 func tokenEndpointHandler(t *testing.T, provider fosite.OAuth2Provider) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
 		req.ParseMultipartForm(1 << 20)
-		ctx := fosite.NewContext()
+		ctx := req.Context()
 
 		accessRequest, err := provider.NewAccessRequest(ctx, req, &oauth2.JWTSession{})
 		if err != nil {
@@ -162,6 +162,10 @@ func tokenEndpointHandler(t *testing.T, provider fosite.OAuth2Provider) func(rw
 			accessRequest.GrantScope("fosite")
 		}
 
+		for _, a := range accessRequest.GetRequestedAudience() {
+			accessRequest.GrantAudience(a)
+		}
+
 		response, err := provider.NewAccessResponse(ctx, accessRequest)
 		if err != nil {
 			t.Logf("Access request failed because: %+v", err)
@@ -0,0 +1,103 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestComposeAllEnabledUsesIDGenerator(t *testing.T) {
+	var generated int
+	config := &compose.Config{IDGenerator: func() string {
+		generated++
+		return fmt.Sprintf("generated-%d", generated)
+	}}
+	key := internal.MustRSAKey()
+	f := compose.ComposeAllEnabled(config, storage.NewExampleStore(), []byte("some-secret-thats-random-some-secret-thats-random-"), key)
+	ctx := context.Background()
+
+	newSession := func() *openid.DefaultSession {
+		return &openid.DefaultSession{
+			Claims:  &jwt.IDTokenClaims{Subject: "peter", RequestedAt: time.Now().UTC(), AuthTime: time.Now().UTC()},
+			Headers: &jwt.Headers{},
+			Subject: "peter",
+		}
+	}
+
+	ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", "/auth?"+url.Values{
+		"client_id":     {"my-client"},
+		"response_type": {"code"},
+		"scope":         {"openid"},
+		"state":         {"some-random-state"},
+		"nonce":         {"some-random-nonce"},
+		"redirect_uri":  {"http://localhost:3846/callback"},
+	}.Encode(), nil))
+	require.NoError(t, err)
+	assert.Equal(t, "generated-1", ar.GetID())
+
+	ar.GrantScope("openid")
+	authorizeResponse, err := f.NewAuthorizeResponse(ctx, ar, newSession())
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("POST", "/token", strings.NewReader(url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {authorizeResponse.GetCode()},
+		"redirect_uri": {"http://localhost:3846/callback"},
+	}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("my-client", "foobar")
+	accessRequest, err := f.NewAccessRequest(ctx, r, newSession())
+	require.NoError(t, err)
+	assert.Equal(t, "generated-1", accessRequest.GetID(), "the access request continues the authorize request")
+
+	accessResponse, err := f.NewAccessResponse(ctx, accessRequest)
+	require.NoError(t, err)
+	idToken, _ := accessResponse.GetExtra("id_token").(string)
+	claims := jwtgo.MapClaims{}
+	_, _, err = new(jwtgo.Parser).ParseUnverified(idToken, claims)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("generated-%d", generated), claims["jti"])
+
+	logoutToken, err := compose.NewOpenIDConnectStrategy(config, key).GenerateLogoutToken(ctx, &fosite.DefaultClient{ID: "my-client"}, "peter", "")
+	require.NoError(t, err)
+	claims = jwtgo.MapClaims{}
+	_, _, err = new(jwtgo.Parser).ParseUnverified(logoutToken, claims)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("generated-%d", generated), claims["jti"])
+}
@@ -24,12 +24,14 @@ package integration_test
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"testing"
 
 	"github.com/parnurzeal/gorequest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	goauth "golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/compose"
@@ -143,3 +145,153 @@ func runIntrospectTokenTest(t *testing.T, strategy oauth2.AccessTokenStrategy, i
 		})
 	}
 }
+
+func TestIntrospectTokenGrantType(t *testing.T) {
+	f := compose.Compose(new(compose.Config), fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	token, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	res := struct {
+		Active     bool   `json:"active"`
+		ClientType string `json:"client_type"`
+		GrantType  string `json:"grant_type"`
+	}{}
+	_, body, errs := gorequest.New().Post(ts.URL+"/introspect").
+		SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+		Type("form").
+		SendStruct(map[string]string{"token": token.AccessToken}).
+		End()
+	require.Len(t, errs, 0)
+	require.NoError(t, json.Unmarshal([]byte(body), &res))
+
+	assert.True(t, res.Active)
+	assert.Equal(t, "client_credentials", res.GrantType)
+	assert.Equal(t, fosite.ClientTypeConfidential, res.ClientType)
+}
+
+func TestIntrospectTokenAudienceRestricted(t *testing.T) {
+	secret := []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`) // = "foobar"
+	fositeStore.Clients["audience-client"] = &fosite.DefaultClient{
+		ID:         "audience-client",
+		Secret:     secret,
+		GrantTypes: []string{"client_credentials"},
+		Scopes:     []string{"fosite"},
+		Audience:   []string{"resource-server-a"},
+	}
+	fositeStore.Clients["resource-server-a"] = &fosite.DefaultClient{ID: "resource-server-a", Secret: secret}
+	fositeStore.Clients["resource-server-b"] = &fosite.DefaultClient{ID: "resource-server-b", Secret: secret}
+	defer func() {
+		delete(fositeStore.Clients, "audience-client")
+		delete(fositeStore.Clients, "resource-server-a")
+		delete(fositeStore.Clients, "resource-server-b")
+	}()
+
+	f := compose.Compose(&compose.Config{IntrospectionAudiencePolicy: fosite.AudienceRestrictedIntrospectionPolicy}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := &clientcredentials.Config{
+		ClientID:       "audience-client",
+		ClientSecret:   "foobar",
+		Scopes:         []string{"fosite"},
+		TokenURL:       ts.URL + "/token",
+		EndpointParams: url.Values{"audience": {"resource-server-a"}},
+	}
+	token, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		d        string
+		caller   string
+		isActive bool
+	}{
+		{d: "should be active for the resource server in the token's audience", caller: "resource-server-a", isActive: true},
+		{d: "should be inactive for a resource server not in the token's audience", caller: "resource-server-b", isActive: false},
+		{d: "should be active for the client the token was issued to", caller: "audience-client", isActive: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			res := struct {
+				Active bool `json:"active"`
+			}{}
+			_, body, errs := gorequest.New().Post(ts.URL+"/introspect").
+				SetBasicAuth(c.caller, "foobar").
+				Type("form").
+				SendStruct(map[string]string{"token": token.AccessToken}).
+				End()
+			require.Len(t, errs, 0)
+			require.NoError(t, json.Unmarshal([]byte(body), &res))
+			assert.Equal(t, c.isActive, res.Active, "%s", body)
+		})
+	}
+}
+
+func TestIntrospectTokenMultipleAudiences(t *testing.T) {
+	audiences := []string{"https://www.ory.sh/api", "https://www.ory.sh/api/v2"}
+	fositeStore.Clients["multi-audience-client"] = &fosite.DefaultClient{
+		ID:         "multi-audience-client",
+		Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		GrantTypes: []string{"client_credentials"},
+		Scopes:     []string{"fosite"},
+		Audience:   audiences,
+	}
+	defer delete(fositeStore.Clients, "multi-audience-client")
+
+	for _, c := range []struct {
+		description string
+		strategy    oauth2.AccessTokenStrategy
+		factory     compose.Factory
+	}{
+		{
+			description: "HMAC strategy with OAuth2TokenIntrospectionFactory",
+			strategy:    hmacStrategy,
+			factory:     compose.OAuth2TokenIntrospectionFactory,
+		},
+		{
+			description: "JWT strategy with OAuth2TokenIntrospectionFactory",
+			strategy:    jwtStrategy,
+			factory:     compose.OAuth2TokenIntrospectionFactory,
+		},
+		{
+			description: "JWT strategy with OAuth2StatelessJWTIntrospectionFactory",
+			strategy:    jwtStrategy,
+			factory:     compose.OAuth2StatelessJWTIntrospectionFactory,
+		},
+	} {
+		t.Run(fmt.Sprintf("description=%s", c.description), func(t *testing.T) {
+			f := compose.Compose(new(compose.Config), fositeStore, c.strategy, nil, compose.OAuth2ClientCredentialsGrantFactory, c.factory)
+			ts := mockServer(t, f, &fosite.DefaultSession{})
+			defer ts.Close()
+
+			oauthClient := &clientcredentials.Config{
+				ClientID:       "multi-audience-client",
+				ClientSecret:   "foobar",
+				Scopes:         []string{"fosite"},
+				TokenURL:       ts.URL + "/token",
+				EndpointParams: url.Values{"audience": audiences},
+			}
+			token, err := oauthClient.Token(goauth.NoContext)
+			require.NoError(t, err)
+
+			res := struct {
+				Active   bool            `json:"active"`
+				Audience json.RawMessage `json:"aud"`
+			}{}
+			_, body, errs := gorequest.New().Post(ts.URL+"/introspect").
+				SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+				Type("form").
+				SendStruct(map[string]string{"token": token.AccessToken}).
+				End()
+			require.Len(t, errs, 0)
+			require.NoError(t, json.Unmarshal([]byte(body), &res), "%s", body)
+			require.True(t, res.Active, "%s", body)
+
+			var aud []string
+			require.NoError(t, json.Unmarshal(res.Audience, &aud), "aud must be a JSON array: %s", res.Audience)
+			assert.Equal(t, audiences, aud)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+// issuerSession is a session for both JWT access tokens and ID Tokens.
+type issuerSession struct {
+	*openid.DefaultSession
+	JWTClaims *jwt.JWTClaims
+}
+
+func (s *issuerSession) GetJWTClaims() jwt.JWTClaimsContainer {
+	return s.JWTClaims
+}
+
+func (s *issuerSession) GetJWTHeader() *jwt.Headers {
+	return &jwt.Headers{}
+}
+
+func (s *issuerSession) Clone() fosite.Session {
+	claims := *s.JWTClaims
+	return &issuerSession{
+		DefaultSession: s.DefaultSession.Clone().(*openid.DefaultSession),
+		JWTClaims:      &claims,
+	}
+}
+
+func newIssuerSession() *issuerSession {
+	return &issuerSession{
+		DefaultSession: &openid.DefaultSession{
+			Claims:  &jwt.IDTokenClaims{Subject: "peter"},
+			Headers: &jwt.Headers{},
+			Subject: "peter",
+		},
+		JWTClaims: &jwt.JWTClaims{Subject: "peter"},
+	}
+}
+
+func TestIssuerFromRequest(t *testing.T) {
+	key := internal.MustRSAKey()
+	config := &compose.Config{
+		IDTokenIssuer:           "https://default.example.com",
+		IntrospectionIssuer:     "https://default.example.com",
+		IntrospectionSigningKey: key,
+		IssuerFromRequest: func(r *http.Request) string {
+			switch r.Host {
+			case "tenant-a.example.com", "tenant-b.example.com":
+				return "https://" + r.Host
+			}
+			return ""
+		},
+	}
+	f := compose.Compose(
+		config,
+		fositeStore,
+		&compose.CommonStrategy{
+			CoreStrategy: compose.NewOAuth2JWTStrategy(key, compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil)).
+				WithIssuer("https://default.example.com").
+				WithIssuerFromRequest(config.IssuerFromRequest),
+			OpenIDConnectTokenStrategy: compose.NewOpenIDConnectStrategy(config, key),
+			JWTStrategy:                &jwt.RS256JWTStrategy{PrivateKey: key},
+		},
+		nil,
+		compose.OAuth2AuthorizeImplicitFactory,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		compose.OpenIDConnectImplicitFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+	)
+
+	fositeStore.Clients["issuer-client"] = &fosite.DefaultClient{
+		ID:            "issuer-client",
+		Secret:        []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		RedirectURIs:  []string{"https://client.example.com/callback"},
+		ResponseTypes: []string{"id_token token"},
+		GrantTypes:    []string{"implicit", "client_credentials"},
+		Scopes:        []string{"fosite", "openid"},
+	}
+
+	issuerOf := func(t *testing.T, token string) interface{} {
+		claims := jwtgo.MapClaims{}
+		_, err := jwtgo.ParseWithClaims(token, claims, func(*jwtgo.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		require.NoError(t, err)
+		return claims["iss"]
+	}
+
+	for k, c := range []struct {
+		host   string
+		issuer string
+	}{
+		{host: "tenant-a.example.com", issuer: "https://tenant-a.example.com"},
+		{host: "tenant-b.example.com", issuer: "https://tenant-b.example.com"},
+		{host: "unknown.example.com", issuer: "https://default.example.com"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/host=%s", k, c.host), func(t *testing.T) {
+			authorize := httptest.NewRequest("GET", "https://"+c.host+"/auth?"+url.Values{
+				"response_type": {"id_token token"},
+				"client_id":     {"issuer-client"},
+				"redirect_uri":  {"https://client.example.com/callback"},
+				"scope":         {"openid fosite"},
+				"state":         {"12345678901234567890"},
+				"nonce":         {"11111111111111111111"},
+			}.Encode(), nil)
+			ctx := fosite.WithHTTPRequest(context.Background(), authorize)
+
+			ar, err := f.NewAuthorizeRequest(ctx, authorize)
+			require.NoError(t, err)
+			ar.GrantScope("openid")
+			ar.GrantScope("fosite")
+			resp, err := f.NewAuthorizeResponse(ctx, ar, newIssuerSession())
+			require.NoError(t, err)
+
+			assert.Equal(t, c.issuer, issuerOf(t, resp.GetParameters().Get("id_token")))
+			assert.Equal(t, c.issuer, issuerOf(t, resp.GetParameters().Get("access_token")))
+
+			token := httptest.NewRequest("POST", "https://"+c.host+"/token", strings.NewReader(url.Values{
+				"grant_type": {"client_credentials"},
+				"scope":      {"fosite"},
+			}.Encode()))
+			token.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			token.SetBasicAuth("issuer-client", "foobar")
+			ctx = fosite.WithHTTPRequest(context.Background(), token)
+
+			accessRequest, err := f.NewAccessRequest(ctx, token, &oauth2.JWTSession{JWTClaims: new(jwt.JWTClaims)})
+			require.NoError(t, err)
+			accessRequest.GrantScope("fosite")
+			accessResponse, err := f.NewAccessResponse(ctx, accessRequest)
+			require.NoError(t, err)
+			assert.Equal(t, c.issuer, issuerOf(t, accessResponse.GetAccessToken()))
+
+			introspect := httptest.NewRequest("POST", "https://"+c.host+"/introspect", strings.NewReader(url.Values{
+				"token": {accessResponse.GetAccessToken()},
+			}.Encode()))
+			introspect.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			introspect.Header.Set("Accept", fosite.IntrospectionJWTContentType)
+			introspect.SetBasicAuth("issuer-client", "foobar")
+
+			ir, err := f.NewIntrospectionRequest(context.Background(), introspect, &oauth2.JWTSession{})
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rec, ir)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, c.issuer, issuerOf(t, rec.Body.String()))
+		})
+	}
+}
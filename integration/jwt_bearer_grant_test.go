@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/rfc7523"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+)
+
+func TestJWTBearerGrant(t *testing.T) {
+	key := internal.MustRSAKey()
+	fositeStore.Clients["jwt-bearer-client"] = &fosite.DefaultClient{
+		ID:         "jwt-bearer-client",
+		Secret:     []byte(`$2a$10$IxMdI6d.LIRZPpSfEwNoeu4rY3FhDREsxFJXikcgdRRAStxUlsuEO`), // = "foobar"
+		GrantTypes: []string{rfc7523.GrantTypeJWTBearer},
+		Scopes:     []string{"fosite"},
+	}
+	fositeStore.SetIssuerPublicKeys("https://partner.example.com", "alice", storage.IssuerPublicKeys{
+		Keys:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "partner-key", Key: &key.PublicKey}}},
+		Scopes: []string{"fosite"},
+	})
+	defer delete(fositeStore.Clients, "jwt-bearer-client")
+	defer delete(fositeStore.IssuerPublicKeys, "https://partner.example.com")
+
+	const tokenURL = "https://auth.example.com/token"
+	f := compose.Compose(&compose.Config{TokenURL: tokenURL}, fositeStore, hmacStrategy, nil, compose.RFC7523AssertionGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "partner-key"))
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		d           string
+		issuer      string
+		jti         string
+		exp         time.Time
+		expectError string
+	}{
+		{d: "should pass with a valid assertion", issuer: "https://partner.example.com", jti: "1", exp: time.Now().Add(time.Minute)},
+		{d: "should fail because the assertion was already used", issuer: "https://partner.example.com", jti: "1", exp: time.Now().Add(time.Minute), expectError: "invalid_grant"},
+		{d: "should fail because the assertion expired", issuer: "https://partner.example.com", jti: "2", exp: time.Now().Add(-time.Minute), expectError: "invalid_grant"},
+		{d: "should fail because the issuer is unknown", issuer: "https://unknown.example.com", jti: "3", exp: time.Now().Add(time.Minute), expectError: "invalid_grant"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			assertion, err := josejwt.Signed(signer).Claims(josejwt.Claims{
+				Issuer:   c.issuer,
+				Subject:  "alice",
+				Audience: josejwt.Audience{tokenURL},
+				Expiry:   josejwt.NewNumericDate(c.exp),
+				ID:       c.jti,
+			}).CompactSerialize()
+			require.NoError(t, err)
+
+			req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(url.Values{
+				"grant_type": {rfc7523.GrantTypeJWTBearer},
+				"assertion":  {assertion},
+				"scope":      {"fosite"},
+			}.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("jwt-bearer-client", "foobar")
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			if c.expectError != "" {
+				assert.Equal(t, c.expectError, body["error"])
+				return
+			}
+
+			require.Equal(t, http.StatusOK, res.StatusCode, "%v", body)
+			assert.NotEmpty(t, body["access_token"])
+			assert.Equal(t, "fosite", body["scope"])
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+func decodeRefreshTokenClaims(t *testing.T, token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3, "refresh token %s is not a JWT", token)
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	claims := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	return claims
+}
+
+func TestJWTRefreshTokens(t *testing.T) {
+	strategy := *jwtStrategy
+	strategy.WithRefreshTokenFormat(oauth2.RefreshTokenFormatJWT)
+
+	f := compose.Compose(&compose.Config{RefreshTokenScopes: []string{}}, fositeStore, &strategy, nil, compose.OAuth2ResourceOwnerPasswordCredentialsFactory, compose.OAuth2RefreshTokenGrantFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	token, err := oauthClient.PasswordCredentialsToken(goauth.NoContext, "peter", "secret")
+	require.NoError(t, err)
+
+	first := decodeRefreshTokenClaims(t, token.RefreshToken)
+	assert.Equal(t, "refresh_token", first[oauth2.TokenUseClaim])
+	assert.Equal(t, "my-client", first["client_id"])
+	assert.NotEmpty(t, first[oauth2.GrantIDClaim])
+	assert.NotEmpty(t, first["jti"])
+
+	refresh := func(refreshToken string) (*goauth.Token, error) {
+		return oauthClient.TokenSource(goauth.NoContext, &goauth.Token{
+			AccessToken:  "expired",
+			RefreshToken: refreshToken,
+			Expiry:       time.Now().Add(-time.Hour),
+		}).Token()
+	}
+
+	rotated, err := refresh(token.RefreshToken)
+	require.NoError(t, err)
+	require.NotEqual(t, token.RefreshToken, rotated.RefreshToken)
+
+	second := decodeRefreshTokenClaims(t, rotated.RefreshToken)
+	assert.Equal(t, first[oauth2.GrantIDClaim], second[oauth2.GrantIDClaim], "rotated refresh tokens belong to the same grant")
+	assert.NotEqual(t, first["jti"], second["jti"])
+
+	_, err = refresh(token.RefreshToken)
+	require.Error(t, err, "a rotated refresh token must not be accepted again")
+	assert.Contains(t, err.Error(), "invalid_grant")
+
+	_, err = refresh(rotated.RefreshToken)
+	require.NoError(t, err)
+}
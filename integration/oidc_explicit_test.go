@@ -78,8 +78,8 @@ func TestOpenIDConnectExplicitFlow(t *testing.T) {
 				oauthClient.Scopes = []string{"openid"}
 				return oauthClient.AuthCodeURL("12345678901234567890") + "&nonce=1"
 			},
-			authStatusCode: http.StatusOK,
-			expectTokenErr: "insufficient_entropy",
+			expectAuthErr:  "invalid_request",
+			authStatusCode: http.StatusNotAcceptable, // code from internal test callback handler when error occurs
 		},
 		{
 			session:     newIDSession(&jwt.IDTokenClaims{Subject: "peter"}),
@@ -170,3 +170,44 @@ func TestOpenIDConnectExplicitFlow(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenIDConnectExplicitFlowNonceReplayProtection(t *testing.T) {
+	f := compose.ComposeAllEnabled(&compose.Config{EnforceNonceReplayProtection: true}, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, newIDSession(&jwt.IDTokenClaims{Subject: "peter"}))
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	oauthClient.Scopes = []string{"openid"}
+	fositeStore.Clients["my-client"].(*fosite.DefaultClient).RedirectURIs[0] = ts.URL + "/callback"
+
+	nonce := fmt.Sprintf("nonce-%d", time.Now().UnixNano())
+	authorize := func() string {
+		resp, err := http.Get(oauthClient.AuthCodeURL("12345678901234567890") + "&nonce=" + nonce)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		code := resp.Request.URL.Query().Get("code")
+		require.NotEmpty(t, code)
+		return code
+	}
+
+	code := authorize()
+	token, err := oauthClient.Exchange(oauth2.NoContext, code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token.Extra("id_token"))
+
+	t.Run("case=should fail because the authorization code is replayed", func(t *testing.T) {
+		_, err := oauthClient.Exchange(oauth2.NoContext, code)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid_grant")
+	})
+
+	t.Run("case=should fail because the nonce is replayed", func(t *testing.T) {
+		accessTokens := len(fositeStore.AccessTokens)
+		_, err := oauthClient.Exchange(oauth2.NoContext, authorize())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid_grant")
+		assert.Len(t, fositeStore.AccessTokens, accessTokens, "the rejected exchange must not issue an access token")
+	})
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func TestPublicClientTokenEndpoint(t *testing.T) {
+	// Public clients are required to use PKCE by default.
+	f := compose.Compose(new(compose.Config), fositeStore, hmacStrategy, nil, compose.OAuth2AuthorizeExplicitFactory, compose.OAuth2PKCEFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	// The client is not marked as public but declares that it does not authenticate at the token endpoint.
+	fositeStore.Clients["native-client"] = &fosite.DefaultOpenIDConnectClient{
+		DefaultClient: &fosite.DefaultClient{
+			ID:            "native-client",
+			RedirectURIs:  []string{ts.URL + "/callback"},
+			ResponseTypes: []string{"code"},
+			GrantTypes:    []string{"authorization_code"},
+			Scopes:        []string{"fosite"},
+		},
+		TokenEndpointAuthMethod: "none",
+	}
+	defer delete(fositeStore.Clients, "native-client")
+
+	verifier := "somechallengesomechallengesomechallengesomechallengesomechallenge"
+	hash := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	authorize := func(t *testing.T, pkce bool) *http.Response {
+		query := url.Values{
+			"client_id":     {"native-client"},
+			"response_type": {"code"},
+			"redirect_uri":  {ts.URL + "/callback"},
+			"scope":         {"fosite"},
+			"state":         {"12345678901234567890"},
+		}
+		if pkce {
+			query.Set("code_challenge", challenge)
+			query.Set("code_challenge_method", "S256")
+		}
+
+		resp, err := http.Get(ts.URL + "/auth?" + query.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp
+	}
+
+	for k, c := range []struct {
+		d          string
+		form       url.Values
+		expectCode int
+		expectErr  string
+	}{
+		{
+			d:          "should exchange the code with the code verifier and without a secret",
+			form:       url.Values{"code_verifier": {verifier}},
+			expectCode: http.StatusOK,
+		},
+		{
+			d:          "should fail because the code verifier is missing",
+			form:       url.Values{},
+			expectCode: http.StatusBadRequest,
+			expectErr:  "invalid_grant",
+		},
+		{
+			d:          "should fail because the public client presents a secret",
+			form:       url.Values{"code_verifier": {verifier}, "client_secret": {"foobar"}},
+			expectCode: http.StatusUnauthorized,
+			expectErr:  "invalid_client",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			resp := authorize(t, true)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			code := resp.Request.URL.Query().Get("code")
+			require.NotEmpty(t, code)
+
+			c.form.Set("grant_type", "authorization_code")
+			c.form.Set("client_id", "native-client")
+			c.form.Set("redirect_uri", ts.URL+"/callback")
+			c.form.Set("code", code)
+			resp, err := http.PostForm(ts.URL+"/token", c.form)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			require.Equal(t, c.expectCode, resp.StatusCode, "%+v", body)
+			if c.expectErr != "" {
+				assert.Equal(t, c.expectErr, body["error"])
+				return
+			}
+			assert.NotEmpty(t, body["access_token"])
+		})
+	}
+
+	t.Run("case=should require a code challenge", func(t *testing.T) {
+		resp := authorize(t, false)
+		assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Request.URL.Query().Get("code"))
+	})
+
+	t.Run("case=should not require a code challenge if disabled", func(t *testing.T) {
+		f := compose.Compose(&compose.Config{DisablePKCEForPublicClients: true}, fositeStore, hmacStrategy, nil, compose.OAuth2AuthorizeExplicitFactory, compose.OAuth2PKCEFactory, compose.OAuth2TokenIntrospectionFactory)
+		optOut := mockServer(t, f, &fosite.DefaultSession{})
+		defer optOut.Close()
+
+		resp, err := http.Get(optOut.URL + "/auth?" + url.Values{
+			"client_id":     {"native-client"},
+			"response_type": {"code"},
+			"redirect_uri":  {ts.URL + "/callback"},
+			"scope":         {"fosite"},
+			"state":         {"12345678901234567890"},
+		}.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, resp.Request.URL.Query().Get("code"))
+	})
+}
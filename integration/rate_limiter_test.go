@@ -0,0 +1,106 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+// blockingRateLimiter allows a limited number of requests per endpoint and client.
+type blockingRateLimiter struct {
+	sync.Mutex
+	limit int
+	calls map[string]int
+}
+
+func (l *blockingRateLimiter) Allow(ctx context.Context, endpoint fosite.Endpoint, clientID string, r *http.Request) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	key := string(endpoint) + ":" + clientID
+	l.calls[key]++
+	return l.calls[key] <= l.limit
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &blockingRateLimiter{limit: 1, calls: map[string]int{}}
+	f := compose.Compose(&compose.Config{RateLimiter: limiter}, fositeStore, hmacStrategy, nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+		compose.OAuth2TokenRevocationFactory,
+	)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	token, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	_, err = oauthClient.Token(goauth.NoContext)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "slow_down")
+	assert.Contains(t, err.Error(), fmt.Sprintf("%d", http.StatusTooManyRequests))
+
+	for _, endpoint := range []string{"/introspect", "/revoke"} {
+		t.Run("endpoint="+endpoint, func(t *testing.T) {
+			for k, expectStatus := range []int{http.StatusOK, http.StatusTooManyRequests} {
+				res, body, errs := gorequest.New().Post(ts.URL+endpoint).
+					SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+					Type("form").
+					SendStruct(map[string]string{"token": token.AccessToken}).
+					End()
+				require.Len(t, errs, 0)
+				assert.Equal(t, expectStatus, res.StatusCode, "request %d: %s", k, body)
+
+				if expectStatus == http.StatusTooManyRequests {
+					var e struct {
+						Name string `json:"error"`
+					}
+					require.NoError(t, json.Unmarshal([]byte(body), &e))
+					assert.Equal(t, "slow_down", e.Name)
+				}
+			}
+		})
+	}
+
+	// The limiter is consulted before the client is authenticated.
+	res, _, errs := gorequest.New().Post(ts.URL+"/token").
+		SetBasicAuth(oauthClient.ClientID, "wrong-secret").
+		Type("form").
+		SendStruct(map[string]string{"grant_type": "client_credentials"}).
+		End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+}
@@ -50,6 +50,9 @@ type introspectionResponse struct {
 	IssuedAt  int64    `json:"iat,omitempty"`
 	Subject   string   `json:"sub,omitempty"`
 	Username  string   `json:"username,omitempty"`
+
+	ClientType string `json:"client_type,omitempty"`
+	GrantType  string `json:"grant_type,omitempty"`
 }
 
 func TestRefreshTokenFlow(t *testing.T) {
@@ -133,6 +136,9 @@ func TestRefreshTokenFlow(t *testing.T) {
 				assert.NotEmpty(t, or.IssuedAt)
 				assert.True(t, or.Active)
 				assert.EqualValues(t, "peter", or.Subject)
+				assert.EqualValues(t, "authorization_code", or.GrantType)
+				assert.EqualValues(t, "refresh_token", rr.GrantType)
+				assert.EqualValues(t, fosite.ClientTypeConfidential, rr.ClientType)
 				assert.EqualValues(t, "peteru", or.Username)
 
 				assert.EqualValues(t, or.Audience, rr.Audience)
@@ -261,3 +267,106 @@ func TestRefreshTokenFlow(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshTokenFlowScopeNarrowing(t *testing.T) {
+	session := &defaultSession{
+		DefaultSession: &openid.DefaultSession{
+			Claims: &jwt.IDTokenClaims{
+				Subject: "peter",
+			},
+			Headers: &jwt.Headers{},
+			Subject: "peter",
+		},
+	}
+	f := compose.ComposeAllEnabled(new(compose.Config), fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ts := mockServer(t, f, session)
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	oauthClient.Scopes = []string{"fosite", "offline"}
+	fositeStore.Clients["my-client"].(*fosite.DefaultClient).RedirectURIs[0] = ts.URL + "/callback"
+
+	for _, c := range []struct {
+		description string
+		form        url.Values
+		pass        bool
+		expectScope string
+	}{
+		{
+			description: "should pass and keep the original scopes",
+			form:        url.Values{},
+			pass:        true,
+			expectScope: "fosite offline",
+		},
+		{
+			description: "should pass and narrow the scopes",
+			form:        url.Values{"scope": {"offline"}},
+			pass:        true,
+			expectScope: "offline",
+		},
+		{
+			description: "should pass and ignore an empty redirect_uri",
+			form:        url.Values{"redirect_uri": {""}},
+			pass:        true,
+			expectScope: "fosite offline",
+		},
+		{
+			description: "should fail because the scope was not granted originally",
+			form:        url.Values{"scope": {"fosite offline openid"}},
+			pass:        false,
+		},
+	} {
+		t.Run("case="+c.description, func(t *testing.T) {
+			resp, err := http.Get(oauthClient.AuthCodeURL("1234567890"))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			token, err := oauthClient.Exchange(oauth2.NoContext, resp.Request.URL.Query().Get("code"))
+			require.NoError(t, err)
+
+			c.form.Set("grant_type", "refresh_token")
+			c.form.Set("refresh_token", token.RefreshToken)
+			req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(c.form.Encode()))
+			require.NoError(t, err)
+			req.SetBasicAuth("my-client", "foobar")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var body struct {
+				AccessToken  string `json:"access_token"`
+				RefreshToken string `json:"refresh_token"`
+				Scope        string `json:"scope"`
+				Error        string `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			if !c.pass {
+				assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+				assert.Equal(t, fosite.ErrInvalidScope.Error(), body.Error)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, c.expectScope, body.Scope)
+
+			req, err = http.NewRequest("POST", ts.URL+"/introspect", strings.NewReader(url.Values{"token": {body.AccessToken}}.Encode()))
+			require.NoError(t, err)
+			req.SetBasicAuth("my-client", "foobar")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			res, err = http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			var intro introspectionResponse
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&intro))
+			assert.True(t, intro.Active)
+			assert.Equal(t, c.expectScope, intro.Scope)
+
+			// The new refresh token keeps the originally granted scopes.
+			refreshed, err := oauthClient.TokenSource(oauth2.NoContext, &oauth2.Token{RefreshToken: body.RefreshToken}).Token()
+			require.NoError(t, err)
+			assert.Equal(t, "fosite offline", refreshed.Extra("scope"))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func TestClientCredentialsFlowKnownScopes(t *testing.T) {
+	f := compose.Compose(&compose.Config{KnownScopes: []string{"fosite", "offline", "openid", "admin"}, SendDebugMessagesToClients: true}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	for k, c := range []struct {
+		d          string
+		scopes     []string
+		expectHint string
+	}{
+		{
+			d:      "should pass because the scope is known and allowed",
+			scopes: []string{"fosite"},
+		},
+		{
+			d:          "should fail with the client error because the known scope is not allowed for the client",
+			scopes:     []string{"fosite", "admin"},
+			expectHint: "The OAuth 2.0 Client is not allowed to request scope 'admin'.",
+		},
+		{
+			d:          "should fail with the registry error because the scope is unknown",
+			scopes:     []string{"fosite", "unknown"},
+			expectHint: "The requested scope 'unknown' is not known to this authorization server.",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			oauthClient.Scopes = c.scopes
+			token, err := oauthClient.Token(goauth.NoContext)
+			if c.expectHint == "" {
+				require.NoError(t, err)
+				assert.NotEmpty(t, token.AccessToken)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, string(err.(*goauth.RetrieveError).Body), `"error":"invalid_scope"`)
+			assert.Contains(t, string(err.(*goauth.RetrieveError).Body), c.expectHint)
+		})
+	}
+}
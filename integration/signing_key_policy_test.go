@@ -0,0 +1,112 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestSigningKeyPolicy(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	strong, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	config := new(compose.Config)
+	hmacStrategy := compose.NewOAuth2HMACStrategy(config, []byte("some-secret-thats-random-some-secret-thats-random-"), nil)
+
+	_, err = compose.NewOpenIDConnectStrategyWithKey(config, weak)
+	assert.Error(t, err)
+	_, err = compose.NewOAuth2JWTStrategyWithKey(config, weak, hmacStrategy)
+	assert.Error(t, err)
+
+	s, err := compose.NewOpenIDConnectStrategyWithKey(config, strong)
+	require.NoError(t, err)
+	assert.Equal(t, &jwt.RS256JWTStrategy{PrivateKey: strong}, s.JWTStrategy)
+	_, err = compose.NewOAuth2JWTStrategyWithKey(config, strong, hmacStrategy)
+	require.NoError(t, err)
+
+	// The constructors which can not return an error panic instead.
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	assert.Panics(t, func() { compose.NewOpenIDConnectStrategy(config, weak) })
+	assert.Panics(t, func() { compose.NewOpenIDConnectECDSAStrategy(config, p224) })
+	assert.Panics(t, func() { compose.NewOAuth2JWTStrategy(weak, hmacStrategy) })
+	assert.Panics(t, func() { compose.NewOAuth2JWTECDSAStrategy(p224, hmacStrategy) })
+	assert.Panics(t, func() {
+		compose.ComposeAllEnabled(config, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), weak)
+	})
+	assert.Panics(t, func() { compose.Compose(&compose.Config{JARMSigningKey: weak}, fositeStore, hmacStrategy, nil) })
+	assert.Panics(t, func() {
+		compose.Compose(&compose.Config{IntrospectionSigningKey: p224}, fositeStore, hmacStrategy, nil)
+	})
+	assert.NotPanics(t, func() {
+		compose.ComposeAllEnabled(config, fositeStore, []byte("some-secret-thats-random-some-secret-thats-random-"), strong)
+	})
+
+	// The policy is configurable.
+	config.SigningKeyPolicy = &jwt.KeyPolicy{MinRSAKeySize: 1024}
+	_, err = compose.NewOpenIDConnectStrategyWithKey(config, weak)
+	require.NoError(t, err)
+	assert.NotPanics(t, func() { compose.NewOpenIDConnectStrategy(config, weak) })
+}
+
+func TestSigningKeyCurves(t *testing.T) {
+	config := new(compose.Config)
+	for k, c := range []struct {
+		curve elliptic.Curve
+		alg   string
+	}{
+		{curve: elliptic.P256(), alg: "ES256"},
+		{curve: elliptic.P384(), alg: "ES384"},
+		{curve: elliptic.P521(), alg: "ES512"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/alg=%s", k, c.alg), func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(c.curve, rand.Reader)
+			require.NoError(t, err)
+
+			s, err := compose.NewOpenIDConnectStrategyWithKey(config, key)
+			require.NoError(t, err)
+
+			token, _, err := s.JWTStrategy.Generate(context.Background(), jwtgo.MapClaims{"sub": "peter"}, &jwt.Headers{})
+			require.NoError(t, err)
+
+			decoded, err := s.JWTStrategy.Decode(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, c.alg, decoded.Method.Alg())
+		})
+	}
+}
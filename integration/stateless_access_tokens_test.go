@@ -0,0 +1,133 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/storage"
+)
+
+// accessTokenCountingStore counts how often access tokens are persisted or looked up.
+type accessTokenCountingStore struct {
+	*storage.MemoryStore
+
+	sync.Mutex
+	created, looked int
+}
+
+func (s *accessTokenCountingStore) CreateAccessTokenSession(ctx context.Context, signature string, request fosite.Requester) error {
+	s.Lock()
+	s.created++
+	s.Unlock()
+	return s.MemoryStore.CreateAccessTokenSession(ctx, signature, request)
+}
+
+func (s *accessTokenCountingStore) GetAccessTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	s.Lock()
+	s.looked++
+	s.Unlock()
+	return s.MemoryStore.GetAccessTokenSession(ctx, signature, session)
+}
+
+// mapTokenDenylist is a TokenDenylist which never forgets denied tokens.
+type mapTokenDenylist struct {
+	sync.Mutex
+	denied map[string]time.Time
+}
+
+func (d *mapTokenDenylist) Deny(_ context.Context, jti string, exp time.Time) error {
+	d.Lock()
+	defer d.Unlock()
+	d.denied[jti] = exp
+	return nil
+}
+
+func (d *mapTokenDenylist) IsDenied(_ context.Context, jti string) (bool, error) {
+	d.Lock()
+	defer d.Unlock()
+	_, ok := d.denied[jti]
+	return ok, nil
+}
+
+func TestStatelessAccessTokens(t *testing.T) {
+	store := &accessTokenCountingStore{MemoryStore: fositeStore}
+	denylist := &mapTokenDenylist{denied: map[string]time.Time{}}
+	f := compose.Compose(&compose.Config{StatelessAccessTokens: true, TokenDenylist: denylist}, store, jwtStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2StatelessJWTIntrospectionFactory, compose.OAuth2TokenRevocationFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	token, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	introspect := func() bool {
+		res := struct {
+			Active bool `json:"active"`
+		}{}
+		_, body, errs := gorequest.New().Post(ts.URL+"/introspect").
+			SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+			Type("form").
+			SendStruct(map[string]string{"token": token.AccessToken}).
+			End()
+		require.Len(t, errs, 0)
+		require.NoError(t, json.Unmarshal([]byte(body), &res))
+		return res.Active
+	}
+
+	assert.True(t, introspect())
+	hres, _, errs := gorequest.New().Get(ts.URL+"/info").
+		Set("Authorization", "bearer "+token.AccessToken).
+		End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusOK, hres.StatusCode)
+
+	assert.Equal(t, 0, store.created, "access tokens must not be persisted")
+	assert.Equal(t, 0, store.looked, "access tokens must be validated without a storage lookup")
+
+	resp, _, errs := gorequest.New().Post(ts.URL+"/revoke").
+		SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+		Type("form").
+		SendStruct(map[string]string{"token": token.AccessToken, "token_type_hint": "access_token"}).End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, denylist.denied, 1)
+
+	assert.False(t, introspect())
+	hres, _, errs = gorequest.New().Get(ts.URL+"/info").
+		Set("Authorization", "bearer "+token.AccessToken).
+		End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusUnauthorized, hres.StatusCode)
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type tenantContextKey struct{}
+
+// contextRecordingStore records which storage methods were called and fails the test if the context passed to any
+// of them does not carry the tenant of the originating request.
+type contextRecordingStore struct {
+	*storage.MemoryStore
+	t *testing.T
+
+	sync.Mutex
+	calls map[string]int
+}
+
+func (s *contextRecordingStore) record(ctx context.Context, method string) {
+	assert.Equal(s.t, "tenant-a", ctx.Value(tenantContextKey{}), "storage method %s did not receive the request context", method)
+
+	s.Lock()
+	defer s.Unlock()
+	s.calls[method]++
+}
+
+func (s *contextRecordingStore) GetClient(ctx context.Context, id string) (fosite.Client, error) {
+	s.record(ctx, "GetClient")
+	return s.MemoryStore.GetClient(ctx, id)
+}
+
+func (s *contextRecordingStore) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	s.record(ctx, "ClientAssertionJWTValid")
+	return s.MemoryStore.ClientAssertionJWTValid(ctx, jti)
+}
+
+func (s *contextRecordingStore) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	s.record(ctx, "SetClientAssertionJWT")
+	return s.MemoryStore.SetClientAssertionJWT(ctx, jti, exp)
+}
+
+func (s *contextRecordingStore) SetStateUsed(ctx context.Context, clientID string, state string, exp time.Time) error {
+	s.record(ctx, "SetStateUsed")
+	return s.MemoryStore.SetStateUsed(ctx, clientID, state, exp)
+}
+
+func (s *contextRecordingStore) SetNonceUsed(ctx context.Context, clientID string, nonce string, authorizeCode string, exp time.Time) error {
+	s.record(ctx, "SetNonceUsed")
+	return s.MemoryStore.SetNonceUsed(ctx, clientID, nonce, authorizeCode, exp)
+}
+
+func (s *contextRecordingStore) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) error {
+	s.record(ctx, "CreateOpenIDConnectSession")
+	return s.MemoryStore.CreateOpenIDConnectSession(ctx, authorizeCode, requester)
+}
+
+func (s *contextRecordingStore) GetOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) (fosite.Requester, error) {
+	s.record(ctx, "GetOpenIDConnectSession")
+	return s.MemoryStore.GetOpenIDConnectSession(ctx, authorizeCode, requester)
+}
+
+func (s *contextRecordingStore) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	s.record(ctx, "DeleteOpenIDConnectSession")
+	return s.MemoryStore.DeleteOpenIDConnectSession(ctx, authorizeCode)
+}
+
+func (s *contextRecordingStore) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	s.record(ctx, "CreateAuthorizeCodeSession")
+	return s.MemoryStore.CreateAuthorizeCodeSession(ctx, code, req)
+}
+
+func (s *contextRecordingStore) GetAuthorizeCodeSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	s.record(ctx, "GetAuthorizeCodeSession")
+	return s.MemoryStore.GetAuthorizeCodeSession(ctx, code, session)
+}
+
+func (s *contextRecordingStore) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	s.record(ctx, "InvalidateAuthorizeCodeSession")
+	return s.MemoryStore.InvalidateAuthorizeCodeSession(ctx, code)
+}
+
+func (s *contextRecordingStore) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	s.record(ctx, "CreatePKCERequestSession")
+	return s.MemoryStore.CreatePKCERequestSession(ctx, code, req)
+}
+
+func (s *contextRecordingStore) GetPKCERequestSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	s.record(ctx, "GetPKCERequestSession")
+	return s.MemoryStore.GetPKCERequestSession(ctx, code, session)
+}
+
+func (s *contextRecordingStore) DeletePKCERequestSession(ctx context.Context, code string) error {
+	s.record(ctx, "DeletePKCERequestSession")
+	return s.MemoryStore.DeletePKCERequestSession(ctx, code)
+}
+
+func (s *contextRecordingStore) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	s.record(ctx, "CreateAccessTokenSession")
+	return s.MemoryStore.CreateAccessTokenSession(ctx, signature, req)
+}
+
+func (s *contextRecordingStore) GetAccessTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	s.record(ctx, "GetAccessTokenSession")
+	return s.MemoryStore.GetAccessTokenSession(ctx, signature, session)
+}
+
+func (s *contextRecordingStore) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	s.record(ctx, "DeleteAccessTokenSession")
+	return s.MemoryStore.DeleteAccessTokenSession(ctx, signature)
+}
+
+func (s *contextRecordingStore) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	s.record(ctx, "CreateRefreshTokenSession")
+	return s.MemoryStore.CreateRefreshTokenSession(ctx, signature, req)
+}
+
+func (s *contextRecordingStore) GetRefreshTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	s.record(ctx, "GetRefreshTokenSession")
+	return s.MemoryStore.GetRefreshTokenSession(ctx, signature, session)
+}
+
+func (s *contextRecordingStore) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	s.record(ctx, "DeleteRefreshTokenSession")
+	return s.MemoryStore.DeleteRefreshTokenSession(ctx, signature)
+}
+
+func (s *contextRecordingStore) Authenticate(ctx context.Context, name string, secret string) error {
+	s.record(ctx, "Authenticate")
+	return s.MemoryStore.Authenticate(ctx, name, secret)
+}
+
+func (s *contextRecordingStore) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	s.record(ctx, "RevokeRefreshToken")
+	return s.MemoryStore.RevokeRefreshToken(ctx, requestID)
+}
+
+func (s *contextRecordingStore) RevokeAccessToken(ctx context.Context, requestID string) error {
+	s.record(ctx, "RevokeAccessToken")
+	return s.MemoryStore.RevokeAccessToken(ctx, requestID)
+}
+
+func TestStorageReceivesRequestContext(t *testing.T) {
+	store := &contextRecordingStore{MemoryStore: storage.NewExampleStore(), t: t, calls: map[string]int{}}
+	f := compose.ComposeAllEnabled(new(compose.Config), store, []byte("some-secret-thats-random-some-secret-thats-random-"), internal.MustRSAKey())
+	ctx := context.WithValue(context.Background(), tenantContextKey{}, "tenant-a")
+
+	newSession := func() *openid.DefaultSession {
+		return &openid.DefaultSession{
+			Claims:  &jwt.IDTokenClaims{Subject: "peter", RequestedAt: time.Now().UTC(), AuthTime: time.Now().UTC()},
+			Headers: &jwt.Headers{},
+			Subject: "peter",
+		}
+	}
+	tokenRequest := func(path string, form url.Values) *http.Request {
+		r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode())).WithContext(ctx)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("my-client", "foobar")
+		return r
+	}
+
+	verifier := "KGCt4m8AmjUvIR5ArTByrmehjtbxn1A49YpTZhsH8N7fhDr7LQayn9xx6mck"
+	hash := sha256.Sum256([]byte(verifier))
+
+	authorizeURL := "/auth?" + url.Values{
+		"client_id":             {"my-client"},
+		"response_type":         {"code"},
+		"scope":                 {"openid offline"},
+		"state":                 {"some-random-state"},
+		"nonce":                 {"some-random-nonce"},
+		"redirect_uri":          {"http://localhost:3846/callback"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(hash[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	ar, err := f.NewAuthorizeRequest(ctx, httptest.NewRequest("GET", authorizeURL, nil).WithContext(ctx))
+	require.NoError(t, err)
+	ar.GrantScope("openid")
+	ar.GrantScope("offline")
+	authorizeResponse, err := f.NewAuthorizeResponse(ctx, ar, newSession())
+	require.NoError(t, err)
+
+	accessRequest, err := f.NewAccessRequest(ctx, tokenRequest("/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {authorizeResponse.GetCode()},
+		"redirect_uri":  {"http://localhost:3846/callback"},
+		"code_verifier": {verifier},
+	}), newSession())
+	require.NoError(t, err)
+	accessResponse, err := f.NewAccessResponse(ctx, accessRequest)
+	require.NoError(t, err)
+	refreshToken, _ := accessResponse.GetExtra("refresh_token").(string)
+	require.NotEmpty(t, refreshToken)
+
+	refreshRequest, err := f.NewAccessRequest(ctx, tokenRequest("/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}), newSession())
+	require.NoError(t, err)
+	refreshResponse, err := f.NewAccessResponse(ctx, refreshRequest)
+	require.NoError(t, err)
+
+	introspection, err := f.NewIntrospectionRequest(ctx, tokenRequest("/introspect", url.Values{
+		"token": {refreshResponse.GetAccessToken()},
+	}), newSession())
+	require.NoError(t, err)
+	assert.True(t, introspection.IsActive())
+
+	require.NoError(t, f.NewRevocationRequest(ctx, tokenRequest("/revoke", url.Values{
+		"token": {refreshResponse.GetAccessToken()},
+	})))
+
+	for _, method := range []string{
+		"GetClient",
+		"CreateAuthorizeCodeSession",
+		"GetAuthorizeCodeSession",
+		"InvalidateAuthorizeCodeSession",
+		"CreatePKCERequestSession",
+		"GetPKCERequestSession",
+		"DeletePKCERequestSession",
+		"CreateOpenIDConnectSession",
+		"GetOpenIDConnectSession",
+		"CreateAccessTokenSession",
+		"GetAccessTokenSession",
+		"CreateRefreshTokenSession",
+		"GetRefreshTokenSession",
+		"RevokeAccessToken",
+		"RevokeRefreshToken",
+	} {
+		assert.NotZero(t, store.calls[method], "expected storage method %s to be called", method)
+	}
+}
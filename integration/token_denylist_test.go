@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func introspectActive(t *testing.T, ts string, token string) bool {
+	res := struct {
+		Active bool `json:"active"`
+	}{}
+	_, body, errs := gorequest.New().Post(ts+"/introspect").
+		SetBasicAuth("my-client", "foobar").
+		Type("form").
+		SendStruct(map[string]string{"token": token}).
+		End()
+	require.Len(t, errs, 0)
+	require.NoError(t, json.Unmarshal([]byte(body), &res))
+	return res.Active
+}
+
+func revoke(t *testing.T, ts string, token string) {
+	resp, _, errs := gorequest.New().Post(ts+"/revoke").
+		SetBasicAuth("my-client", "foobar").
+		Type("form").
+		SendStruct(map[string]string{"token": token}).End()
+	require.Len(t, errs, 0)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTokenDenylistRevokesAccessToken(t *testing.T) {
+	f := compose.Compose(&compose.Config{TokenDenylist: fositeStore}, fositeStore, jwtStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2StatelessJWTIntrospectionFactory, compose.OAuth2TokenRevocationFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	a, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+	b, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	assert.True(t, introspectActive(t, ts.URL, a.AccessToken))
+	revoke(t, ts.URL, a.AccessToken)
+	assert.False(t, introspectActive(t, ts.URL, a.AccessToken))
+	assert.True(t, introspectActive(t, ts.URL, b.AccessToken), "tokens of other grants must remain active")
+}
+
+func TestTokenDenylistRevokesRefreshTokenDescendants(t *testing.T) {
+	f := compose.Compose(&compose.Config{TokenDenylist: fositeStore, RefreshTokenScopes: []string{}}, fositeStore, jwtStrategy, nil, compose.OAuth2ResourceOwnerPasswordCredentialsFactory, compose.OAuth2RefreshTokenGrantFactory, compose.OAuth2StatelessJWTIntrospectionFactory, compose.OAuth2TokenRevocationFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2Client(ts)
+	token, err := oauthClient.PasswordCredentialsToken(goauth.NoContext, "peter", "secret")
+	require.NoError(t, err)
+	require.NotEmpty(t, token.RefreshToken)
+
+	token.Expiry = token.Expiry.Add(-time.Hour * 24)
+	refreshed, err := oauthClient.TokenSource(goauth.NoContext, token).Token()
+	require.NoError(t, err)
+	require.NotEqual(t, token.AccessToken, refreshed.AccessToken)
+
+	assert.True(t, introspectActive(t, ts.URL, refreshed.AccessToken))
+	revoke(t, ts.URL, refreshed.RefreshToken)
+	assert.False(t, introspectActive(t, ts.URL, token.AccessToken))
+	assert.False(t, introspectActive(t, ts.URL, refreshed.AccessToken))
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/parnurzeal/gorequest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+type tokenTypeHintMetric struct {
+	endpoint fosite.Endpoint
+	hint     fosite.TokenUse
+	outcome  fosite.TokenTypeHintOutcome
+}
+
+func TestTokenTypeHintMetrics(t *testing.T) {
+	var lock sync.Mutex
+	var metrics []tokenTypeHintMetric
+	hook := func(ctx context.Context, endpoint fosite.Endpoint, hint fosite.TokenUse, outcome fosite.TokenTypeHintOutcome) {
+		lock.Lock()
+		defer lock.Unlock()
+		metrics = append(metrics, tokenTypeHintMetric{endpoint: endpoint, hint: hint, outcome: outcome})
+	}
+
+	f := compose.Compose(&compose.Config{TokenTypeHintMetricsHook: hook}, fositeStore, hmacStrategy, nil,
+		compose.OAuth2ClientCredentialsGrantFactory,
+		compose.OAuth2TokenIntrospectionFactory,
+		compose.OAuth2TokenRevocationFactory,
+	)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	for k, c := range []struct {
+		endpoint string
+		hint     string
+		expect   []tokenTypeHintMetric
+	}{
+		{
+			endpoint: "/introspect",
+			hint:     "access_token",
+			expect:   []tokenTypeHintMetric{{endpoint: fosite.IntrospectionEndpoint, hint: fosite.AccessToken, outcome: fosite.TokenTypeHintHit}},
+		},
+		{
+			endpoint: "/introspect",
+			hint:     "refresh_token",
+			expect:   []tokenTypeHintMetric{{endpoint: fosite.IntrospectionEndpoint, hint: fosite.RefreshToken, outcome: fosite.TokenTypeHintFallback}},
+		},
+		{
+			endpoint: "/introspect",
+		},
+		{
+			endpoint: "/revoke",
+			hint:     "access_token",
+			expect:   []tokenTypeHintMetric{{endpoint: fosite.RevocationEndpoint, hint: fosite.AccessToken, outcome: fosite.TokenTypeHintHit}},
+		},
+		{
+			endpoint: "/revoke",
+			hint:     "refresh_token",
+			expect:   []tokenTypeHintMetric{{endpoint: fosite.RevocationEndpoint, hint: fosite.RefreshToken, outcome: fosite.TokenTypeHintFallback}},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/endpoint=%s/hint=%s", k, c.endpoint, c.hint), func(t *testing.T) {
+			token, err := oauthClient.Token(goauth.NoContext)
+			require.NoError(t, err)
+
+			metrics = nil
+			res, body, errs := gorequest.New().Post(ts.URL+c.endpoint).
+				SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+				Type("form").
+				SendStruct(map[string]string{"token": token.AccessToken, "token_type_hint": c.hint}).
+				End()
+			require.Len(t, errs, 0)
+			require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+
+			assert.Equal(t, c.expect, metrics)
+		})
+	}
+}
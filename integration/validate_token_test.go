@@ -0,0 +1,181 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+)
+
+func resourceServer(provider fosite.OAuth2Provider, tokenType fosite.TokenUse, requiredScopes ...string) *httptest.Server {
+	f := provider.(fosite.ExtendedOAuth2Provider)
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ar, err := f.ValidateToken(req.Context(), req, tokenType, new(fosite.DefaultSession), requiredScopes...)
+		if err != nil {
+			f.WriteBearerError(rw, err)
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{"client_id": ar.GetClient().GetID(), "scope": ar.GetGrantedScopes()})
+	}))
+}
+
+func TestValidateToken(t *testing.T) {
+	for k, c := range []struct {
+		d               string
+		config          *compose.Config
+		tokenType       fosite.TokenUse
+		requiredScopes  []string
+		useRefreshToken bool
+		noToken         bool
+		expectStatus    int
+		expectError     string
+	}{
+		{
+			d:              "should accept a valid access token",
+			config:         &compose.Config{},
+			tokenType:      fosite.AccessToken,
+			requiredScopes: []string{"fosite"},
+			expectStatus:   http.StatusOK,
+		},
+		{
+			d:              "should accept an access token granted the resource server's audience",
+			config:         &compose.Config{ResourceServerAudience: "https://www.ory.sh/api"},
+			tokenType:      fosite.AccessToken,
+			requiredScopes: []string{"fosite"},
+			expectStatus:   http.StatusOK,
+		},
+		{
+			d:            "should reject an access token not granted the resource server's audience",
+			config:       &compose.Config{ResourceServerAudience: "https://www.ory.sh/other"},
+			tokenType:    fosite.AccessToken,
+			expectStatus: http.StatusUnauthorized,
+			expectError:  "invalid_token",
+		},
+		{
+			d:            "should reject an expired access token",
+			config:       &compose.Config{AccessTokenLifespan: -time.Minute},
+			tokenType:    fosite.AccessToken,
+			expectStatus: http.StatusUnauthorized,
+			expectError:  "invalid_token",
+		},
+		{
+			d:              "should reject an access token lacking the required scope",
+			config:         &compose.Config{},
+			tokenType:      fosite.AccessToken,
+			requiredScopes: []string{"fosite", "admin"},
+			expectStatus:   http.StatusForbidden,
+			expectError:    "insufficient_scope",
+		},
+		{
+			d:               "should reject a refresh token used as access token",
+			config:          &compose.Config{},
+			tokenType:       fosite.AccessToken,
+			useRefreshToken: true,
+			expectStatus:    http.StatusUnauthorized,
+			expectError:     "invalid_token",
+		},
+		{
+			d:            "should reject an access token used as refresh token",
+			config:       &compose.Config{},
+			tokenType:    fosite.RefreshToken,
+			expectStatus: http.StatusUnauthorized,
+			expectError:  "invalid_token",
+		},
+		{
+			d:            "should reject a request without token",
+			config:       &compose.Config{},
+			tokenType:    fosite.AccessToken,
+			noToken:      true,
+			expectStatus: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			c.config.RefreshTokenScopes = []string{}
+			f := compose.Compose(c.config, fositeStore, hmacStrategy, nil, compose.OAuth2ResourceOwnerPasswordCredentialsFactory, compose.OAuth2RefreshTokenGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+			ts := mockServer(t, f, &fosite.DefaultSession{})
+			defer ts.Close()
+			rs := resourceServer(f, c.tokenType, c.requiredScopes...)
+			defer rs.Close()
+
+			form := url.Values{
+				"grant_type": {"password"},
+				"username":   {"peter"},
+				"password":   {"secret"},
+				"scope":      {"fosite"},
+				"audience":   {"https://www.ory.sh/api"},
+			}
+			tokenReq, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(form.Encode()))
+			require.NoError(t, err)
+			tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			tokenReq.SetBasicAuth("my-client", "foobar")
+			tokenRes, err := http.DefaultClient.Do(tokenReq)
+			require.NoError(t, err)
+			defer tokenRes.Body.Close()
+			require.Equal(t, http.StatusOK, tokenRes.StatusCode)
+
+			var token struct {
+				AccessToken  string `json:"access_token"`
+				RefreshToken string `json:"refresh_token"`
+			}
+			require.NoError(t, json.NewDecoder(tokenRes.Body).Decode(&token))
+
+			req, err := http.NewRequest("GET", rs.URL, nil)
+			require.NoError(t, err)
+			if c.useRefreshToken {
+				require.NotEmpty(t, token.RefreshToken)
+				req.Header.Set("Authorization", "Bearer "+token.RefreshToken)
+			} else if !c.noToken {
+				req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, c.expectStatus, res.StatusCode)
+			if c.noToken {
+				assert.Equal(t, "Bearer", res.Header.Get("WWW-Authenticate"))
+				return
+			} else if c.expectError == "" {
+				assert.Empty(t, res.Header.Get("WWW-Authenticate"))
+				return
+			}
+
+			assert.Contains(t, res.Header.Get("WWW-Authenticate"), fmt.Sprintf(`error="%s"`, c.expectError))
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			assert.Equal(t, c.expectError, body["error"])
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package jwa contains the mapping between keys and JSON Web Algorithms shared by the fosite and token/jwt packages,
+// which can not import each other. See https://tools.ietf.org/html/rfc7518
+package jwa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// ECDSACurve returns the elliptic curve used by the ECDSA algorithm, see https://tools.ietf.org/html/rfc7518#section-3.4
+func ECDSACurve(alg string) (elliptic.Curve, bool) {
+	switch alg {
+	case "ES256":
+		return elliptic.P256(), true
+	case "ES384":
+		return elliptic.P384(), true
+	case "ES512":
+		return elliptic.P521(), true
+	}
+	return nil, false
+}
+
+// SigningAlgorithm returns the algorithm tokens are signed with by default when using the key: RS256 for RSA keys and
+// ES256, ES384 or ES512 for ECDSA keys on curve P-256, P-384 or P-521. It returns an empty string for any other key.
+func SigningAlgorithm(key crypto.Signer) string {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		for _, alg := range []string{"ES256", "ES384", "ES512"} {
+			if curve, _ := ECDSACurve(alg); k.Curve == curve {
+				return alg
+			}
+		}
+	}
+	return ""
+}
+
+// TokenHash hashes the ASCII representation of the value and returns the base64url encoding of the left-most half of
+// the hash, as used by the at_hash, c_hash and s_hash claims.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
+func TokenHash(value string, hash crypto.Hash) (string, error) {
+	if !hash.Available() {
+		return "", errors.Errorf("Hash function %d is not available", hash)
+	}
+
+	h := hash.New()
+	if _, err := h.Write([]byte(value)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
@@ -30,7 +30,7 @@ import (
 
 func MustRSAKey() *rsa.PrivateKey {
 	// #nosec
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
 	}
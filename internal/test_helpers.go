@@ -27,6 +27,7 @@ import (
 
 	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/net/html"
@@ -49,19 +50,18 @@ func ParseFormPostResponse(redirectURL string, resp io.ReadCloser) (authorizatio
 		return "", "", "", token, customParameters, rFC6749Error, errors.New("Malformed html")
 	}
 
-	htmlEvent := body.Attr[0].Key
-	if htmlEvent != "onload" {
-		return "", "", "", token, customParameters, rFC6749Error, errors.New("onload event is missing")
+	form := getNextNoneTextNode(body.FirstChild)
+	if form.Data != "form" {
+		return "", "", "", token, customParameters, rFC6749Error, errors.New("html form is missing")
 	}
 
-	onLoadFunc := body.Attr[0].Val
-	if onLoadFunc != "javascript:document.forms[0].submit()" {
-		return "", "", "", token, customParameters, rFC6749Error, errors.New("onload function is missing")
+	script := getNextNoneTextNode(form)
+	if script == nil || script.Data != "script" || script.FirstChild == nil {
+		return "", "", "", token, customParameters, rFC6749Error, errors.New("auto submit script is missing")
 	}
 
-	form := getNextNoneTextNode(body.FirstChild)
-	if form.Data != "form" {
-		return "", "", "", token, customParameters, rFC6749Error, errors.New("html form is missing")
+	if strings.TrimSpace(script.FirstChild.Data) != "document.forms[0].submit();" {
+		return "", "", "", token, customParameters, rFC6749Error, errors.New("auto submit script is wrong")
 	}
 
 	for _, attr := range form.Attr {
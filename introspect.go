@@ -40,7 +40,8 @@ func AccessTokenFromRequest(req *http.Request) string {
 
 	auth := req.Header.Get("Authorization")
 	split := strings.SplitN(auth, " ", 2)
-	if len(split) != 2 || !strings.EqualFold(split[0], "bearer") {
+	// DPoP-bound access tokens use the DPoP authorization scheme, see RFC 9449 Section 7.1.
+	if len(split) != 2 || !(strings.EqualFold(split[0], "bearer") || strings.EqualFold(split[0], "dpop")) {
 		// Nothing in Authorization header, try access_token
 		// Empty string returned if there's no such parameter
 		if err := req.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
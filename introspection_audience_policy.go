@@ -0,0 +1,39 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// IntrospectionAudiencePolicy decides whether the authenticated caller of the introspection endpoint, usually a
+// resource server, may learn about the introspected token. If it returns false, the token is reported as inactive.
+type IntrospectionAudiencePolicy func(ctx context.Context, caller Client, token AccessRequester) bool
+
+// AudienceRestrictedIntrospectionPolicy only allows introspecting tokens whose granted audience includes the caller's
+// client ID, or which were issued to the caller itself.
+func AudienceRestrictedIntrospectionPolicy(ctx context.Context, caller Client, token AccessRequester) bool {
+	if caller == nil {
+		return false
+	} else if token.GetClient() != nil && token.GetClient().GetID() == caller.GetID() {
+		return true
+	}
+	return Arguments(token.GetGrantedAudience()).Has(caller.GetID())
+}
@@ -112,8 +112,8 @@ import (
 func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, session Session) (IntrospectionResponder, error) {
 	if r.Method != "POST" {
 		return &IntrospectionResponse{Active: false}, errors.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s' but expected 'POST'.", r.Method))
-	} else if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
-		return &IntrospectionResponse{Active: false}, errors.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithCause(err).WithDebug(err.Error()))
+	} else if err := f.parseRequestForm(r); err != nil {
+		return &IntrospectionResponse{Active: false}, err
 	} else if len(r.PostForm) == 0 {
 		return &IntrospectionResponse{Active: false}, errors.WithStack(ErrInvalidRequest.WithHint("The POST body can not be empty."))
 	}
@@ -121,15 +121,33 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 	token := r.PostForm.Get("token")
 	tokenTypeHint := r.PostForm.Get("token_type_hint")
 	scope := r.PostForm.Get("scope")
+
+	if err := f.checkRateLimit(ctx, IntrospectionEndpoint, r); err != nil {
+		return &IntrospectionResponse{Active: false}, err
+	}
+
+	var caller Client
 	if clientToken := AccessTokenFromRequest(r); clientToken != "" {
 		if token == clientToken {
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHint("Bearer and introspection token are identical."))
 		}
 
-		if tu, _, err := f.IntrospectToken(ctx, clientToken, AccessToken, session.Clone()); err != nil {
+		// Resource servers authorizing the request with a DPoP-bound token must present a proof for it.
+		dpopThumbprint, err := f.ValidateDPoPProof(ctx, r, clientToken)
+		if err != nil {
+			return &IntrospectionResponse{Active: false}, err
+		}
+
+		if tu, callerRequest, err := f.IntrospectToken(ctx, clientToken, AccessToken, session.Clone()); errors.Is(err, ErrServerError) {
+			return &IntrospectionResponse{Active: false}, errors.WithStack(err)
+		} else if err != nil {
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHint("HTTP Authorization header missing, malformed, or credentials used are invalid."))
 		} else if tu != "" && tu != AccessToken {
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHintf("HTTP Authorization header did not provide a token of type 'access_token', got type '%s'.", tu))
+		} else if err := ValidateDPoPBinding(callerRequest, dpopThumbprint); err != nil {
+			return &IntrospectionResponse{Active: false}, err
+		} else {
+			caller = callerRequest.GetClient()
 		}
 	} else {
 		id, secret, ok := r.BasicAuth()
@@ -147,21 +165,34 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client Secret from HTTP basic authorization header, make sure it is properly encoded.").WithCause(err).WithDebug(err.Error()))
 		}
 
-		client, err := f.Store.GetClient(ctx, clientID)
-		if err != nil {
+		client, err := f.getClient(ctx, clientID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+		} else if err != nil {
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHint("Unable to find OAuth 2.0 Client from HTTP basic authorization header.").WithCause(err).WithDebug(err.Error()))
 		}
 
 		// Enforce client authentication
-		if err := f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(clientSecret)); err != nil {
+		if err := f.compareClientSecret(ctx, client, []byte(clientSecret)); err != nil {
 			return &IntrospectionResponse{Active: false}, errors.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid."))
 		}
+		caller = client
 	}
 
 	tu, ar, err := f.IntrospectToken(ctx, token, TokenUse(tokenTypeHint), session, RemoveEmpty(strings.Split(scope, " "))...)
-	if err != nil {
+	if errors.Is(err, ErrServerError) {
+		// Storage failures must not be reported as inactive tokens.
+		return &IntrospectionResponse{Active: false}, errors.WithStack(err)
+	} else if err != nil {
 		return &IntrospectionResponse{Active: false}, errors.WithStack(ErrInactiveToken.WithHint("An introspection strategy indicated that the token is inactive.").WithCause(err).WithDebug(err.Error()))
 	}
+
+	f.TokenTypeHintMetricsHook.Report(ctx, IntrospectionEndpoint, TokenUse(tokenTypeHint), tu)
+
+	if f.IntrospectionAudiencePolicy != nil && !f.IntrospectionAudiencePolicy(ctx, caller, ar) {
+		return &IntrospectionResponse{Active: false}, errors.WithStack(ErrInactiveToken.WithHint("The token was not issued for the audience of the introspecting client."))
+	}
+
 	accessTokenType := ""
 
 	if tu == AccessToken {
@@ -169,10 +200,13 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 	}
 
 	return &IntrospectionResponse{
-		Active:          true,
-		AccessRequester: ar,
-		TokenUse:        tu,
-		AccessTokenType: accessTokenType,
+		Active:               true,
+		AccessRequester:      ar,
+		TokenUse:             tu,
+		AccessTokenType:      accessTokenType,
+		Caller:               caller,
+		JWTResponseRequested: strings.Contains(r.Header.Get("Accept"), IntrospectionJWTContentType),
+		Issuer:               ResolveIssuer(WithHTTPRequest(ctx, r), f.IssuerFromRequest, f.IntrospectionIssuer),
 	}, nil
 }
 
@@ -181,6 +215,15 @@ type IntrospectionResponse struct {
 	AccessRequester AccessRequester `json:"extra"`
 	TokenUse        TokenUse        `json:"token_use,omitempty"`
 	AccessTokenType string          `json:"token_type,omitempty"`
+
+	// Caller is the client that introspected the token.
+	Caller Client `json:"-"`
+
+	// JWTResponseRequested is true if the caller asked for a signed introspection response.
+	JWTResponseRequested bool `json:"-"`
+
+	// Issuer is the "iss" claim of the signed introspection response, resolved for the introspection request.
+	Issuer string `json:"-"`
 }
 
 func (r *IntrospectionResponse) IsActive() bool {
@@ -198,3 +241,15 @@ func (r *IntrospectionResponse) GetTokenUse() TokenUse {
 func (r *IntrospectionResponse) GetAccessTokenType() string {
 	return r.AccessTokenType
 }
+
+func (r *IntrospectionResponse) GetCaller() Client {
+	return r.Caller
+}
+
+func (r *IntrospectionResponse) GetIssuer() string {
+	return r.Issuer
+}
+
+func (r *IntrospectionResponse) IsJWTResponseRequested() bool {
+	return r.JWTResponseRequested
+}
@@ -145,6 +145,43 @@ func TestNewIntrospectionRequest(t *testing.T) {
 			isActive:  false,
 			expectErr: ErrInactiveToken,
 		},
+		{
+			description: "should fail with a server error instead of an inactive token because the storage is unavailable",
+			setup: func() {
+				f.TokenIntrospectionHandlers = TokenIntrospectionHandlers{validator}
+				httpreq = &http.Request{
+					Method: "POST",
+					Header: http.Header{
+						"Authorization": []string{"bearer some-token"},
+					},
+					PostForm: url.Values{
+						"token": []string{"introspect-token"},
+					},
+				}
+				validator.EXPECT().IntrospectToken(context.TODO(), "some-token", gomock.Any(), gomock.Any(), gomock.Any()).Return(TokenUse(""), nil)
+				validator.EXPECT().IntrospectToken(context.TODO(), "introspect-token", gomock.Any(), gomock.Any(), gomock.Any()).Return(TokenUse(""), ErrServerError.WithCause(newErr))
+			},
+			isActive:  false,
+			expectErr: ErrServerError,
+		},
+		{
+			description: "should fail with a server error because the storage is unavailable when authorizing the request",
+			setup: func() {
+				f.TokenIntrospectionHandlers = TokenIntrospectionHandlers{validator}
+				httpreq = &http.Request{
+					Method: "POST",
+					Header: http.Header{
+						"Authorization": []string{"bearer some-token"},
+					},
+					PostForm: url.Values{
+						"token": []string{"introspect-token"},
+					},
+				}
+				validator.EXPECT().IntrospectToken(context.TODO(), "some-token", gomock.Any(), gomock.Any(), gomock.Any()).Return(TokenUse(""), ErrServerError.WithCause(newErr))
+			},
+			isActive:  false,
+			expectErr: ErrServerError,
+		},
 		{
 			description: "should pass",
 			setup: func() {
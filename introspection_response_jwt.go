@@ -0,0 +1,137 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/http"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// IntrospectionJWTContentType is the media type of signed introspection responses, see
+// https://datatracker.ietf.org/doc/html/rfc9701#section-5
+const IntrospectionJWTContentType = "application/token-introspection+jwt"
+
+// JWTIntrospectionResponder is an IntrospectionResponder which knows whether the resource server asked for a signed
+// introspection response.
+type JWTIntrospectionResponder interface {
+	IntrospectionResponder
+
+	// IsJWTResponseRequested returns true if the response should be written as a signed JWT.
+	IsJWTResponseRequested() bool
+
+	// GetCaller returns the client which introspected the token, used as the audience of the signed response.
+	GetCaller() Client
+
+	// GetIssuer returns the "iss" claim of the signed response. If empty, IntrospectionIssuer is used.
+	GetIssuer() string
+}
+
+// GetIntrospectionSigningKeyID returns IntrospectionSigningKeyID if set. Defaults to the base64url encoded SHA-256 JWK
+// thumbprint of the introspection signing key.
+func (f *Fosite) GetIntrospectionSigningKeyID() (string, error) {
+	if f.IntrospectionSigningKeyID != "" {
+		return f.IntrospectionSigningKeyID, nil
+	}
+	if f.IntrospectionSigningKey == nil {
+		return "", errors.New("the introspection signing key is not set")
+	}
+
+	return signingKeyThumbprint(f.IntrospectionSigningKey)
+}
+
+// IntrospectionSigningJWK returns the public key signed introspection responses can be verified with. Add it to the
+// authorization server's published JWKS so resource servers can resolve the "kid" of the responses.
+func (f *Fosite) IntrospectionSigningJWK() (*jose.JSONWebKey, error) {
+	method := jwtSigningMethod(f.IntrospectionSigningKey)
+	if method == nil {
+		return nil, errors.Errorf("expected the introspection signing key to be an *rsa.PrivateKey or an *ecdsa.PrivateKey on curve P-256, P-384 or P-521 but got %T", f.IntrospectionSigningKey)
+	}
+
+	kid, err := f.GetIntrospectionSigningKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{
+		Key:       f.IntrospectionSigningKey.Public(),
+		KeyID:     kid,
+		Algorithm: method.Alg(),
+		Use:       "sig",
+	}, nil
+}
+
+// writeIntrospectionJWT writes the introspection response as a JWT as defined in
+// https://datatracker.ietf.org/doc/html/rfc9701#section-5
+func (f *Fosite) writeIntrospectionJWT(rw http.ResponseWriter, r JWTIntrospectionResponder) {
+	token, err := f.signIntrospectionResponse(r)
+	if err != nil {
+		f.writeJsonError(rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", IntrospectionJWTContentType)
+	f.setIntrospectionCacheHeaders(rw, r)
+	_, _ = rw.Write([]byte(token))
+}
+
+func (f *Fosite) signIntrospectionResponse(r JWTIntrospectionResponder) (string, error) {
+	method := jwtSigningMethod(f.IntrospectionSigningKey)
+	if method == nil {
+		return "", errors.WithStack(ErrMisconfiguration.WithHint("The authorization server is not configured to sign introspection responses.").WithDebugf("Expected the introspection signing key to be an *rsa.PrivateKey or an *ecdsa.PrivateKey on curve P-256, P-384 or P-521 but got %T.", f.IntrospectionSigningKey))
+	}
+
+	issuer := r.GetIssuer()
+	if issuer == "" {
+		issuer = f.IntrospectionIssuer
+	}
+
+	if issuer == "" {
+		return "", errors.WithStack(ErrMisconfiguration.WithHint("The authorization server is not configured to sign introspection responses.").WithDebug("The introspection issuer must be set."))
+	}
+
+	kid, err := f.GetIntrospectionSigningKeyID()
+	if err != nil {
+		return "", errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                 issuer,
+		"iat":                 f.Clock.Now().Unix(),
+		"token_introspection": newIntrospectionResponseBody(r),
+	}
+	if caller := r.GetCaller(); caller != nil {
+		claims["aud"] = caller.GetID()
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["typ"] = "token-introspection+jwt"
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(f.IntrospectionSigningKey)
+	if err != nil {
+		return "", errors.WithStack(ErrServerError.WithCause(err).WithDebug(err.Error()))
+	}
+	return signed, nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+)
+
+func TestWriteIntrospectionResponseJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	ar := NewAccessRequest(&DefaultSession{Subject: "peter"})
+	ar.Client = &DefaultClient{ID: "foo"}
+	ar.GrantedScope = Arguments{"read"}
+	ar.RequestedAt = time.Now().UTC()
+	issuedAt := time.Now().UTC().Add(-time.Hour)
+
+	for k, c := range []struct {
+		d   string
+		key crypto.Signer
+		kid string
+		alg string
+	}{
+		{d: "rsa key with thumbprint kid", key: rsaKey, alg: "RS256"},
+		{d: "rsa key with configured kid", key: rsaKey, kid: "introspection-1", alg: "RS256"},
+		{d: "ecdsa key with thumbprint kid", key: ecKey, alg: "ES256"},
+		{d: "ecdsa key on curve P-384", key: p384Key, alg: "ES384"},
+		{d: "ecdsa key on curve P-521", key: p521Key, alg: "ES512"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			f := &Fosite{
+				IntrospectionSigningKey:   c.key,
+				IntrospectionSigningKeyID: c.kid,
+				IntrospectionIssuer:       "https://auth.example.com",
+				Clock:                     func() time.Time { return issuedAt },
+			}
+
+			jwk, err := f.IntrospectionSigningJWK()
+			require.NoError(t, err)
+			if c.kid != "" {
+				assert.Equal(t, c.kid, jwk.KeyID)
+			}
+			assert.Equal(t, c.alg, jwk.Algorithm)
+			jwks := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*jwk}}
+
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, &IntrospectionResponse{
+				Active:               true,
+				AccessRequester:      ar,
+				Caller:               &DefaultClient{ID: "resource-server"},
+				JWTResponseRequested: true,
+			})
+			require.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, IntrospectionJWTContentType, rw.Header().Get("Content-Type"))
+
+			claims := jwt.MapClaims{}
+			token, err := jwt.ParseWithClaims(rw.Body.String(), claims, func(token *jwt.Token) (interface{}, error) {
+				assert.Equal(t, "token-introspection+jwt", token.Header["typ"])
+				kid, _ := token.Header["kid"].(string)
+				keys := jwks.Key(kid)
+				if len(keys) != 1 {
+					return nil, fmt.Errorf("kid %q not found in JWKS", kid)
+				}
+				assert.Equal(t, keys[0].Algorithm, token.Method.Alg())
+				return keys[0].Key, nil
+			})
+			require.NoError(t, err)
+			require.True(t, token.Valid)
+
+			assert.Equal(t, "https://auth.example.com", claims["iss"])
+			assert.Equal(t, "resource-server", claims["aud"])
+			assert.EqualValues(t, issuedAt.Unix(), claims["iat"])
+			introspection, ok := claims["token_introspection"].(map[string]interface{})
+			require.True(t, ok)
+			assert.Equal(t, true, introspection["active"])
+			assert.Equal(t, "foo", introspection["client_id"])
+			assert.Equal(t, "peter", introspection["sub"])
+			assert.Equal(t, "read", introspection["scope"])
+		})
+	}
+}
+
+func TestWriteIntrospectionResponseJWTInactive(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f := &Fosite{IntrospectionSigningKey: key, IntrospectionIssuer: "https://auth.example.com"}
+
+	rw := httptest.NewRecorder()
+	f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, JWTResponseRequested: true})
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rw.Body.String(), claims, func(token *jwt.Token) (interface{}, error) {
+		return key.Public(), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"active": false}, claims["token_introspection"])
+}
+
+func TestWriteIntrospectionResponseJWTRequiresSigningKey(t *testing.T) {
+	f := &Fosite{IntrospectionIssuer: "https://auth.example.com"}
+
+	rw := httptest.NewRecorder()
+	f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: false, JWTResponseRequested: true})
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+
+	_, err := f.IntrospectionSigningJWK()
+	assert.Error(t, err)
+}
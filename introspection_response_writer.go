@@ -23,8 +23,10 @@ package fosite
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -50,13 +52,15 @@ import (
 // specification.  In these cases, the authorization server MUST instead
 // respond with an introspection response with the "active" field set to
 // "false" as described in Section 2.2.
+//
+// Server errors, for example a failing storage backend, are written as error responses and not as inactive tokens.
 func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 	if err == nil {
 		return
 	}
 
 	// Inactive token errors should never written out as an error.
-	if !errors.Is(err, ErrInactiveToken) && (errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrRequestUnauthorized)) {
+	if !errors.Is(err, ErrInactiveToken) && (errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrRequestUnauthorized) || errors.Is(err, ErrServerError) || errors.Is(err, ErrSlowDown) || errors.Is(err, ErrInvalidDPoPProof) || errors.Is(err, ErrUseDPoPNonce)) {
 		f.writeJsonError(rw, err)
 		return
 	}
@@ -196,7 +200,21 @@ func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 //	 {
 //	   "active": false
 //	 }
+//
+// Resource servers may ask for a signed response by sending "Accept: application/token-introspection+jwt", see
+// https://datatracker.ietf.org/doc/html/rfc9701. The response is then a JWT signed with IntrospectionSigningKey whose
+// "token_introspection" claim holds the object shown above. Its "kid" header names the signing key, see
+// IntrospectionSigningJWK, so resource servers verify the response by looking up that key in the authorization
+// server's published JWKS and checking the signature, the "iss" claim and that the "aud" claim is their client ID.
 func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder) {
+	if jr, ok := r.(JWTIntrospectionResponder); ok && jr.IsJWTResponseRequested() {
+		f.writeIntrospectionJWT(rw, jr)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	f.setIntrospectionCacheHeaders(rw, r)
+
 	if !r.IsActive() {
 		_ = json.NewEncoder(rw).Encode(&struct {
 			Active bool `json:"active"`
@@ -204,35 +222,103 @@ func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r Introspect
 		return
 	}
 
+	_ = json.NewEncoder(rw).Encode(newIntrospectionResponseBody(r))
+}
+
+// setIntrospectionCacheHeaders forbids caching the introspection response, as required for responses containing
+// tokens or token metadata by https://tools.ietf.org/html/rfc6749#section-5.1, unless IntrospectionCacheTTL allows
+// resource servers to cache responses of active tokens.
+func (f *Fosite) setIntrospectionCacheHeaders(rw http.ResponseWriter, r IntrospectionResponder) {
+	if maxAge := f.introspectionCacheMaxAge(r); maxAge > 0 {
+		rw.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+}
+
+// introspectionCacheMaxAge returns for how many seconds the introspection response may be cached. Responses must not
+// outlive the token they describe, so the IntrospectionCacheTTL is bounded by the token's remaining lifetime.
+func (f *Fosite) introspectionCacheMaxAge(r IntrospectionResponder) int64 {
+	if f.IntrospectionCacheTTL <= 0 || !r.IsActive() {
+		return 0
+	}
+
+	tokenUse := r.GetTokenUse()
+	if tokenUse == "" {
+		tokenUse = AccessToken
+	}
+
+	maxAge := f.IntrospectionCacheTTL
+	if expiresAt := r.GetAccessRequester().GetSession().GetExpiresAt(tokenUse); !expiresAt.IsZero() {
+		if remaining := expiresAt.Sub(f.Clock.Now()); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+	return int64(maxAge / time.Second)
+}
+
+type introspectionResponseBody struct {
+	Active    bool     `json:"active"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	// Confirmation binds the token to the client certificate, see https://tools.ietf.org/html/rfc8705#section-3.2, or
+	// to the DPoP key, see https://datatracker.ietf.org/doc/html/rfc9449#section-6.2
+	Confirmation map[string]string `json:"cnf,omitempty"`
+	// ClientType is either "public" or "confidential".
+	ClientType string `json:"client_type,omitempty"`
+	// GrantType is the grant type used to issue the token, for example "client_credentials".
+	GrantType string `json:"grant_type,omitempty"`
+	// Session is not included per default because it might expose sensitive information.
+	// Session   Session  `json:"sess,omitempty"`
+}
+
+func newIntrospectionResponseBody(r IntrospectionResponder) *introspectionResponseBody {
+	if !r.IsActive() {
+		return &introspectionResponseBody{Active: false}
+	}
+
 	expiresAt := int64(0)
 	if !r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).IsZero() {
 		expiresAt = r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).Unix()
 	}
 
-	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	rw.Header().Set("Cache-Control", "no-store")
-	rw.Header().Set("Pragma", "no-cache")
-	_ = json.NewEncoder(rw).Encode(struct {
-		Active    bool     `json:"active"`
-		ClientID  string   `json:"client_id,omitempty"`
-		Scope     string   `json:"scope,omitempty"`
-		Audience  []string `json:"aud,omitempty"`
-		ExpiresAt int64    `json:"exp,omitempty"`
-		IssuedAt  int64    `json:"iat,omitempty"`
-		Subject   string   `json:"sub,omitempty"`
-		Username  string   `json:"username,omitempty"`
-		// Session is not included per default because it might expose sensitive information.
-		// Session   Session  `json:"sess,omitempty"`
-	}{
-		Active:    true,
-		ClientID:  r.GetAccessRequester().GetClient().GetID(),
-		Scope:     strings.Join(r.GetAccessRequester().GetGrantedScopes(), " "),
-		ExpiresAt: expiresAt,
-		IssuedAt:  r.GetAccessRequester().GetRequestedAt().Unix(),
-		Subject:   r.GetAccessRequester().GetSession().GetSubject(),
-		Audience:  r.GetAccessRequester().GetGrantedAudience(),
-		Username:  r.GetAccessRequester().GetSession().GetUsername(),
+	var confirmation map[string]string
+	if session, ok := r.GetAccessRequester().GetSession().(CertificateBoundSession); ok && session.GetCertificateThumbprint() != "" {
+		confirmation = map[string]string{"x5t#S256": session.GetCertificateThumbprint()}
+	}
+	if session, ok := r.GetAccessRequester().GetSession().(DPoPBoundSession); ok && session.GetDPoPKeyThumbprint() != "" {
+		// See https://datatracker.ietf.org/doc/html/rfc9449#section-6.2
+		confirmation = map[string]string{"jkt": session.GetDPoPKeyThumbprint()}
+	}
+
+	// The client type is only reported with the grant type. Tokens introspected without looking up their session,
+	// for example stateless JWTs, do not know their grant type and their client is not the registered client.
+	var grantType, clientType string
+	if session, ok := r.GetAccessRequester().GetSession().(GrantTypeSession); ok && session.GetGrantType() != "" {
+		grantType = session.GetGrantType()
+		clientType = GetClientType(r.GetAccessRequester().GetClient())
+	}
+
+	return &introspectionResponseBody{
+		Active:       true,
+		ClientID:     r.GetAccessRequester().GetClient().GetID(),
+		Scope:        strings.Join(r.GetAccessRequester().GetGrantedScopes(), " "),
+		ExpiresAt:    expiresAt,
+		IssuedAt:     r.GetAccessRequester().GetRequestedAt().Unix(),
+		Subject:      r.GetAccessRequester().GetSession().GetSubject(),
+		Audience:     r.GetAccessRequester().GetGrantedAudience(),
+		Username:     r.GetAccessRequester().GetSession().GetUsername(),
+		Confirmation: confirmation,
+		ClientType:   clientType,
+		GrantType:    grantType,
 		// Session is not included because it might expose sensitive information.
 		// Session:   r.GetAccessRequester().GetSession(),
-	})
+	}
 }
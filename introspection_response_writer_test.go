@@ -23,6 +23,7 @@ package fosite_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -52,6 +53,10 @@ func TestWriteIntrospectionError(t *testing.T) {
 	rw.EXPECT().Write(gomock.Any())
 	f.WriteIntrospectionError(rw, errors.WithStack(ErrInvalidRequest))
 
+	rw.EXPECT().WriteHeader(http.StatusInternalServerError)
+	rw.EXPECT().Write(gomock.Any())
+	f.WriteIntrospectionError(rw, errors.WithStack(ErrServerError.WithCause(errors.New("connection refused"))))
+
 	rw.EXPECT().Write([]byte("{\"active\":false}\n"))
 	f.WriteIntrospectionError(rw, errors.New(""))
 
@@ -67,6 +72,7 @@ func TestWriteIntrospectionResponse(t *testing.T) {
 	defer c.Finish()
 
 	rw := internal.NewMockResponseWriter(c)
+	rw.EXPECT().Header().Return(http.Header{}).AnyTimes()
 	rw.EXPECT().Write(gomock.Any()).AnyTimes()
 	f.WriteIntrospectionResponse(rw, &IntrospectionResponse{
 		AccessRequester: NewAccessRequest(nil),
@@ -144,3 +150,160 @@ func TestWriteIntrospectionResponseBody(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteIntrospectionResponseGrantType(t *testing.T) {
+	f := new(Fosite)
+
+	for k, c := range []struct {
+		d                string
+		client           *DefaultClient
+		grantType        string
+		expectGrantType  string
+		expectClientType string
+	}{
+		{
+			d:                "should report a confidential client",
+			client:           &DefaultClient{ID: "foo"},
+			grantType:        "client_credentials",
+			expectGrantType:  "client_credentials",
+			expectClientType: ClientTypeConfidential,
+		},
+		{
+			d:                "should report a public client",
+			client:           &DefaultClient{ID: "foo", Public: true},
+			grantType:        "authorization_code",
+			expectGrantType:  "authorization_code",
+			expectClientType: ClientTypePublic,
+		},
+		{
+			d:      "should report neither if the grant type is unknown",
+			client: &DefaultClient{ID: "foo"},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			ar := NewAccessRequest(&DefaultSession{GrantType: c.grantType})
+			ar.Client = c.client
+
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: true, TokenUse: AccessToken, AccessRequester: ar})
+
+			var params struct {
+				ClientType string `json:"client_type"`
+				GrantType  string `json:"grant_type"`
+			}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+			assert.Equal(t, c.expectGrantType, params.GrantType)
+			assert.Equal(t, c.expectClientType, params.ClientType)
+		})
+	}
+}
+
+func TestWriteIntrospectionResponseCacheHeaders(t *testing.T) {
+	for k, c := range []struct {
+		d            string
+		ttl          time.Duration
+		active       bool
+		expiresIn    time.Duration
+		cacheControl string
+	}{
+		{
+			d:            "should forbid caching by default",
+			active:       true,
+			expiresIn:    time.Hour,
+			cacheControl: "no-store",
+		},
+		{
+			d:            "should forbid caching of inactive tokens",
+			ttl:          time.Minute,
+			active:       false,
+			expiresIn:    time.Hour,
+			cacheControl: "no-store",
+		},
+		{
+			d:            "should allow caching for the configured duration",
+			ttl:          time.Minute,
+			active:       true,
+			expiresIn:    time.Hour,
+			cacheControl: "private, max-age=60",
+		},
+		{
+			d:            "should allow caching for the configured duration if the token does not expire",
+			ttl:          time.Minute,
+			active:       true,
+			cacheControl: "private, max-age=60",
+		},
+		{
+			d:            "should bound the cache lifetime by the remaining lifetime of the token",
+			ttl:          time.Minute,
+			active:       true,
+			expiresIn:    30*time.Second + 500*time.Millisecond,
+			cacheControl: "private, max-age=30",
+		},
+		{
+			d:            "should forbid caching if the token expires within a second",
+			ttl:          time.Minute,
+			active:       true,
+			expiresIn:    500 * time.Millisecond,
+			cacheControl: "no-store",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			now := time.Now().Add(-time.Hour).UTC()
+			f := &Fosite{IntrospectionCacheTTL: c.ttl, Clock: func() time.Time { return now }}
+			sess := &DefaultSession{}
+			if c.expiresIn != 0 {
+				sess.SetExpiresAt(AccessToken, now.Add(c.expiresIn))
+			}
+
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: c.active, TokenUse: AccessToken, AccessRequester: NewAccessRequest(sess)})
+
+			assert.Equal(t, c.cacheControl, rw.Header().Get("Cache-Control"))
+			if c.cacheControl == "no-store" {
+				assert.Equal(t, "no-cache", rw.Header().Get("Pragma"))
+			} else {
+				assert.Empty(t, rw.Header().Get("Pragma"))
+			}
+		})
+	}
+}
+
+func TestWriteIntrospectionResponseConfirmation(t *testing.T) {
+	f := new(Fosite)
+
+	for k, c := range []struct {
+		d                  string
+		session            *DefaultSession
+		expectConfirmation map[string]string
+	}{
+		{
+			d:       "should report the certificate of mTLS bound tokens",
+			session: &DefaultSession{CertificateThumbprint: "some-certificate-thumbprint"},
+			expectConfirmation: map[string]string{
+				"x5t#S256": "some-certificate-thumbprint",
+			},
+		},
+		{
+			d:       "should report the key of DPoP bound tokens",
+			session: &DefaultSession{DPoPKeyThumbprint: "some-key-thumbprint"},
+			expectConfirmation: map[string]string{
+				"jkt": "some-key-thumbprint",
+			},
+		},
+		{
+			d:       "should not report a confirmation for bearer tokens",
+			session: &DefaultSession{},
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			f.WriteIntrospectionResponse(rw, &IntrospectionResponse{Active: true, TokenUse: AccessToken, AccessRequester: NewAccessRequest(c.session)})
+
+			var params struct {
+				Confirmation map[string]string `json:"cnf"`
+			}
+			require.NoError(t, json.NewDecoder(rw.Body).Decode(&params))
+			assert.Equal(t, c.expectConfirmation, params.Confirmation)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+)
+
+// IssuerFromRequest resolves the issuer of the authorization server from the HTTP request, for example from its host
+// when several issuers are served from one binary. If it returns an empty string, the configured issuer is used.
+type IssuerFromRequest func(r *http.Request) string
+
+// IssuerRequester is implemented by requesters which carry the issuer resolved from the HTTP request, such as Request.
+type IssuerRequester interface {
+	// GetIssuer returns the resolved issuer or an empty string.
+	GetIssuer() string
+}
+
+// ResolveIssuer returns the issuer attached to the context with WithIssuer or, if there is none, the issuer resolved
+// by resolve from the HTTP request attached to the context with WithHTTPRequest. It returns fallback if resolve is nil,
+// no request is attached or resolve returns an empty string.
+func ResolveIssuer(ctx context.Context, resolve IssuerFromRequest, fallback string) string {
+	if ctx == nil {
+		return fallback
+	} else if issuer, ok := IssuerFromContext(ctx); ok {
+		return issuer
+	}
+
+	if resolve == nil {
+		return fallback
+	}
+
+	r, ok := HTTPRequestFromContext(ctx)
+	if !ok {
+		return fallback
+	}
+
+	if issuer := resolve(r); issuer != "" {
+		return issuer
+	}
+	return fallback
+}
+
+// resolveIssuer returns the issuer attached to the context or, if there is none, the issuer resolved from the HTTP
+// request with IssuerFromRequest. It returns an empty string if neither is available.
+func (f *Fosite) resolveIssuer(ctx context.Context, r *http.Request) string {
+	if ctx != nil {
+		if issuer, ok := IssuerFromContext(ctx); ok {
+			return issuer
+		}
+	}
+
+	if f.IssuerFromRequest == nil || r == nil {
+		return ""
+	}
+	return f.IssuerFromRequest(r)
+}
+
+// requesterIssuer returns the issuer carried by the requester, or an empty string.
+func requesterIssuer(requester interface{}) string {
+	if ir, ok := requester.(IssuerRequester); ok {
+		return ir.GetIssuer()
+	}
+	return ""
+}
+
+// withRequesterIssuer attaches the issuer carried by the requester to the context, if there is one.
+func withRequesterIssuer(ctx context.Context, requester interface{}) context.Context {
+	if issuer := requesterIssuer(requester); issuer != "" {
+		return WithIssuer(ctx, issuer)
+	}
+	return ctx
+}
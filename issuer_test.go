@@ -0,0 +1,61 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/ory/fosite"
+)
+
+func TestResolveIssuer(t *testing.T) {
+	byHost := func(r *http.Request) string {
+		if r.Host == "tenant.example.com" {
+			return "https://tenant.example.com"
+		}
+		return ""
+	}
+	tenant := WithHTTPRequest(context.Background(), httptest.NewRequest("GET", "https://tenant.example.com/token", nil))
+	unknown := WithHTTPRequest(context.Background(), httptest.NewRequest("GET", "https://unknown.example.com/token", nil))
+
+	for k, c := range []struct {
+		d       string
+		ctx     context.Context
+		resolve IssuerFromRequest
+		expect  string
+	}{
+		{d: "resolves the issuer from the request", ctx: tenant, resolve: byHost, expect: "https://tenant.example.com"},
+		{d: "falls back if the issuer can not be resolved", ctx: unknown, resolve: byHost, expect: "https://auth.example.com"},
+		{d: "falls back without request", ctx: context.Background(), resolve: byHost, expect: "https://auth.example.com"},
+		{d: "falls back without resolver", ctx: tenant, expect: "https://auth.example.com"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			assert.Equal(t, c.expect, ResolveIssuer(c.ctx, c.resolve, "https://auth.example.com"))
+		})
+	}
+}
@@ -162,6 +162,24 @@ type OAuth2Provider interface {
 	WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder)
 }
 
+// ExtendedOAuth2Provider is implemented by OAuth2Providers which also validate bearer tokens for resource servers and
+// report their capabilities, such as Fosite. It is kept apart from OAuth2Provider so that existing implementations
+// of OAuth2Provider are not broken; use a type assertion to access it.
+type ExtendedOAuth2Provider interface {
+	// ValidateToken validates the bearer token of a request to a resource server and returns the access requester
+	// holding the token's session if the token is of the given type and was granted the required scopes. Errors can be
+	// written using WriteBearerError.
+	ValidateToken(ctx context.Context, r *http.Request, tokenType TokenUse, session Session, requiredScopes ...string) (AccessRequester, error)
+
+	// WriteBearerError responds with an error if a resource server rejected the bearer token of a request as defined
+	// in https://tools.ietf.org/html/rfc6750#section-3
+	WriteBearerError(rw http.ResponseWriter, err error)
+
+	// Capabilities returns the grant types, response types, response modes, PKCE code challenge methods and client
+	// authentication methods enabled by the registered handlers and the configuration.
+	Capabilities() Capabilities
+}
+
 // IntrospectionResponder is the response object that will be returned when token introspection was successful,
 // for example when the client is allowed to perform token introspection. Refer to
 // https://tools.ietf.org/search/rfc7662#section-2.2 for more details.
@@ -0,0 +1,68 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// Endpoint identifies an endpoint of the authorization server, for example in calls to the RateLimiter.
+type Endpoint string
+
+const (
+	TokenEndpoint         Endpoint = "token"
+	RevocationEndpoint    Endpoint = "revocation"
+	IntrospectionEndpoint Endpoint = "introspection"
+)
+
+// RateLimiter protects the token, revocation and introspection endpoints against brute-force attacks. It is consulted
+// before the client is authenticated and therefore before any expensive cryptographic operation.
+type RateLimiter interface {
+	// Allow returns false if the request must be rejected. The client ID is taken from the HTTP basic authorization
+	// header or the "client_id" form parameter and has not been authenticated yet, it may be empty. The remote
+	// address is available through the request.
+	Allow(ctx context.Context, endpoint Endpoint, clientID string, r *http.Request) bool
+}
+
+// checkRateLimit returns ErrSlowDown if the RateLimiter rejects the request. It must be called after the request
+// form was parsed.
+func (f *Fosite) checkRateLimit(ctx context.Context, endpoint Endpoint, r *http.Request) error {
+	if f.RateLimiter == nil {
+		return nil
+	}
+
+	clientID := r.PostForm.Get("client_id")
+	if id, _, ok := r.BasicAuth(); ok {
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			clientID = unescaped
+		}
+	}
+
+	if !f.RateLimiter.Allow(ctx, endpoint, clientID, r) {
+		return errors.WithStack(ErrSlowDown.WithHintf("Too many requests were sent to the %s endpoint.", endpoint))
+	}
+	return nil
+}
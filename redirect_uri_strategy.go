@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// RedirectURIMatchingStrategy reports whether the redirect URI requested by the client matches one of the redirect
+// URIs registered by the client. Implementations must never relax matching for non-loopback http redirect URIs.
+type RedirectURIMatchingStrategy func(requested, registered string, client Client) bool
+
+// ExactRedirectURIMatchingStrategy compares the redirect URIs using simple string comparison as defined in
+// https://tools.ietf.org/html/rfc3986#section-6.2.1.
+func ExactRedirectURIMatchingStrategy(requested, registered string, _ Client) bool {
+	return requested == registered
+}
+
+// LoopbackRedirectURIMatchingStrategy works like ExactRedirectURIMatchingStrategy but ignores the port of loopback
+// redirect URIs such as http://127.0.0.1:51004/cb and http://[::1]:51004/cb, which native apps use to receive the
+// authorization response on an ephemeral port (see https://tools.ietf.org/html/rfc8252#section-7.3). Clients which
+// declare an application type other than NativeApplicationType are matched exactly.
+func LoopbackRedirectURIMatchingStrategy(requested, registered string, client Client) bool {
+	if requested == registered {
+		return true
+	}
+
+	if c, ok := client.(ApplicationTypeClient); ok && c.GetApplicationType() != "" && c.GetApplicationType() != NativeApplicationType {
+		return false
+	}
+
+	parsed, err := url.Parse(requested)
+	if err != nil {
+		return false
+	}
+
+	return isMatchingAsLoopback(parsed, registered)
+}
+
+var portRangePattern = regexp.MustCompile(`^[^/]*//[^/?#]*:\[[^\]]*\]`)
+
+var loopbackPortRangePattern = regexp.MustCompile(`^(http://(?:127\.0\.0\.1|\[::1\])):\[(\d{1,5})-(\d{1,5})\]([/?].*)?$`)
+
+// LoopbackPortRangeRedirectURIMatchingStrategy works like ExactRedirectURIMatchingStrategy but allows clients to
+// register loopback redirect URIs with a port range, for example http://127.0.0.1:[49152-65535]/cb. A requested
+// redirect URI matches such a registration if it uses the same loopback address, path and query and its port lies
+// within the range. This is stricter than LoopbackRedirectURIMatchingStrategy, which accepts any port. Clients which
+// declare an application type other than NativeApplicationType are matched exactly.
+func LoopbackPortRangeRedirectURIMatchingStrategy(requested, registered string, client Client) bool {
+	if requested == registered {
+		return true
+	}
+
+	if c, ok := client.(ApplicationTypeClient); ok && c.GetApplicationType() != "" && c.GetApplicationType() != NativeApplicationType {
+		return false
+	}
+
+	base, low, high, ok := parseLoopbackPortRange(registered)
+	if !ok {
+		return false
+	}
+
+	parsed, err := url.Parse(requested)
+	if err != nil || parsed.Port() == "" {
+		return false
+	}
+
+	port, err := strconv.Atoi(parsed.Port())
+	if err != nil || port < low || port > high {
+		return false
+	}
+
+	return parsed.Scheme == base.Scheme &&
+		parsed.Hostname() == base.Hostname() &&
+		parsed.Path == base.Path &&
+		parsed.RawQuery == base.RawQuery &&
+		parsed.Fragment == ""
+}
+
+// ValidateLoopbackPortRangeRedirectURI checks a redirect URI with a port range, for example
+// http://127.0.0.1:[49152-65535]/cb, before it is registered for a client. Redirect URIs without a port range are
+// not affected by this check.
+func ValidateLoopbackPortRangeRedirectURI(registered string) error {
+	if !portRangePattern.MatchString(registered) {
+		return nil
+	}
+
+	if !loopbackPortRangePattern.MatchString(registered) {
+		return errors.WithStack(ErrInvalidRequest.WithHintf("Redirect URI '%s' declares a port range, which is only allowed for http redirect URIs using the loopback addresses 127.0.0.1 and [::1].", registered))
+	}
+
+	if _, _, _, ok := parseLoopbackPortRange(registered); !ok {
+		return errors.WithStack(ErrInvalidRequest.WithHintf("Redirect URI '%s' declares an invalid port range, ports must be in ascending order between 1 and 65535.", registered))
+	}
+	return nil
+}
+
+// parseLoopbackPortRange splits a loopback redirect URI with a port range into the redirect URI without a port and
+// the bounds of the range.
+func parseLoopbackPortRange(registered string) (*url.URL, int, int, bool) {
+	matches := loopbackPortRangePattern.FindStringSubmatch(registered)
+	if len(matches) != 5 {
+		return nil, 0, 0, false
+	}
+
+	low, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	high, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	if low < 1 || high > 65535 || low > high {
+		return nil, 0, 0, false
+	}
+
+	base, err := url.Parse(matches[1] + matches[4])
+	if err != nil || base.Fragment != "" {
+		return nil, 0, 0, false
+	}
+	return base, low, high, true
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRemoteFetchTimeout is the timeout of the default HTTP client used to fetch remote documents such as JSON Web
+// Key Sets and sector identifier documents.
+const DefaultRemoteFetchTimeout = 10 * time.Second
+
+// errRemoteDocumentTooLarge is returned by remoteFetcher if a document exceeds its maximum body size.
+var errRemoteDocumentTooLarge = errors.New("the remote document exceeds the maximum body size")
+
+// newDefaultRemoteHTTPClient returns the HTTP client used to fetch remote documents if none is configured. Unlike
+// http.DefaultClient it times out, so that a slow remote can not block authorization and token requests forever.
+func newDefaultRemoteHTTPClient() *http.Client {
+	return &http.Client{Timeout: DefaultRemoteFetchTimeout}
+}
+
+// remoteDocument is a document fetched by remoteFetcher.
+type remoteDocument struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+type remoteFetch struct {
+	done     chan struct{}
+	document *remoteDocument
+	err      error
+}
+
+// remoteFetcher fetches remote documents with a bounded body size. No lock is held while a request is in flight, and
+// concurrent fetches of the same location share one request.
+type remoteFetcher struct {
+	client      *http.Client
+	maxBodySize int64
+
+	mu      sync.Mutex
+	pending map[string]*remoteFetch
+}
+
+func newRemoteFetcher(client *http.Client, maxBodySize int64) *remoteFetcher {
+	return &remoteFetcher{client: client, maxBodySize: maxBodySize, pending: make(map[string]*remoteFetch)}
+}
+
+// fetch returns the document at location. The body of documents larger than the maximum body size is not read and
+// errRemoteDocumentTooLarge is returned instead.
+func (f *remoteFetcher) fetch(ctx context.Context, location string) (*remoteDocument, error) {
+	f.mu.Lock()
+	if call, ok := f.pending[location]; ok {
+		f.mu.Unlock()
+		<-call.done
+		return call.document, call.err
+	}
+
+	call := &remoteFetch{done: make(chan struct{})}
+	f.pending[location] = call
+	f.mu.Unlock()
+
+	call.document, call.err = f.do(ctx, location)
+
+	f.mu.Lock()
+	delete(f.pending, location)
+	f.mu.Unlock()
+	close(call.done)
+
+	return call.document, call.err
+}
+
+func (f *remoteFetcher) do(ctx context.Context, location string) (*remoteDocument, error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, f.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	} else if int64(len(body)) > f.maxBodySize {
+		return nil, errRemoteDocumentTooLarge
+	}
+
+	return &remoteDocument{statusCode: response.StatusCode, header: response.Header, body: body}, nil
+}
@@ -39,6 +39,10 @@ type Request struct {
 	Session           Session    `json:"session" gorethink:"session"`
 	RequestedAudience Arguments  `json:"requestedAudience"`
 	GrantedAudience   Arguments  `json:"grantedAudience"`
+
+	// Issuer is the issuer resolved from the HTTP request with Fosite.IssuerFromRequest by NewAuthorizeRequest and
+	// NewAccessRequest. It is empty if no issuer was resolved and is not persisted.
+	Issuer string `json:"-" gorethink:"-"`
 }
 
 func NewRequest() *Request {
@@ -64,6 +68,16 @@ func (a *Request) SetID(id string) {
 	a.ID = id
 }
 
+// GetIssuer returns the issuer resolved from the HTTP request, if any.
+func (a *Request) GetIssuer() string {
+	return a.Issuer
+}
+
+// SetIssuer sets the issuer resolved from the HTTP request.
+func (a *Request) SetIssuer(issuer string) {
+	a.Issuer = issuer
+}
+
 func (a *Request) GetRequestForm() url.Values {
 	return a.Form
 }
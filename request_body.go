@@ -0,0 +1,87 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+var errRequestBodyTooLarge = errors.New("http: request body too large")
+
+// limitedRequestBody fails reads with errRequestBodyTooLarge once more than remaining bytes were read from the
+// wrapped body.
+type limitedRequestBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedRequestBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errRequestBodyTooLarge
+	}
+	// Read one byte more than allowed to tell a body of exactly the limit apart from a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n, b.remaining = int(b.remaining), -1
+		return n, errRequestBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// GetMaxRequestBodySize returns MaxRequestBodySize if set. Defaults to DefaultMaxRequestBodySize.
+func (f *Fosite) GetMaxRequestBodySize() int64 {
+	if f.MaxRequestBodySize <= 0 {
+		return DefaultMaxRequestBodySize
+	}
+	return f.MaxRequestBodySize
+}
+
+// parseRequestForm parses the form of POST requests to the token, revocation and introspection endpoints, rejecting
+// bodies larger than GetMaxRequestBodySize with invalid_request.
+func (f *Fosite) parseRequestForm(r *http.Request) error {
+	if r.Body != nil {
+		body := r.Body
+		r.Body = &limitedRequestBody{ReadCloser: body, remaining: f.GetMaxRequestBodySize()}
+		defer func() { r.Body = body }()
+	}
+
+	// ParseMultipartForm discards the errors of ParseForm for bodies which are not multipart encoded, so the form is
+	// parsed first to tell if the body exceeded the limit.
+	err := r.ParseForm()
+	if !errors.Is(err, errRequestBodyTooLarge) {
+		err = r.ParseMultipartForm(1 << 20)
+	}
+
+	if errors.Is(err, errRequestBodyTooLarge) {
+		return errors.WithStack(ErrInvalidRequest.WithHintf("The HTTP body must not be larger than %d bytes.", f.GetMaxRequestBodySize()).WithCause(err).WithDebug(err.Error()))
+	} else if err != nil && err != http.ErrNotMultipart {
+		return errors.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithCause(err).WithDebug(err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+)
+
+func TestMaxRequestBodySize(t *testing.T) {
+	f := &Fosite{MaxRequestBodySize: 64}
+	endpoints := map[string]func(r *http.Request) error{
+		"token": func(r *http.Request) error {
+			_, err := f.NewAccessRequest(context.Background(), r, new(DefaultSession))
+			return err
+		},
+		"revocation": func(r *http.Request) error {
+			return f.NewRevocationRequest(context.Background(), r)
+		},
+		"introspection": func(r *http.Request) error {
+			_, err := f.NewIntrospectionRequest(context.Background(), r, new(DefaultSession))
+			return err
+		},
+	}
+
+	for k, c := range []struct {
+		d        string
+		body     string
+		tooLarge bool
+	}{
+		{d: "body below the limit", body: url.Values{"token": {"foo"}}.Encode()},
+		{d: "body at the limit", body: "token=" + strings.Repeat("a", 58)},
+		{d: "body above the limit", body: "token=" + strings.Repeat("a", 59), tooLarge: true},
+		{d: "body far above the limit", body: "token=" + strings.Repeat("a", 1<<20), tooLarge: true},
+	} {
+		for name, endpoint := range endpoints {
+			t.Run(fmt.Sprintf("case=%d/description=%s/endpoint=%s", k, c.d, name), func(t *testing.T) {
+				r := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				err := endpoint(r)
+				if !c.tooLarge {
+					assert.NotEmpty(t, r.PostForm.Get("token"))
+					if err != nil {
+						assert.NotContains(t, ErrorToRFC6749Error(err).Hint, "must not be larger")
+					}
+					return
+				}
+
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+				rfcerr := ErrorToRFC6749Error(err)
+				assert.Equal(t, http.StatusBadRequest, rfcerr.Code)
+				assert.Contains(t, rfcerr.Hint, "64 bytes")
+			})
+		}
+	}
+
+	t.Run("case=multipart body above the limit", func(t *testing.T) {
+		body := "--b\r\nContent-Disposition: form-data; name=\"token\"\r\n\r\n" + strings.Repeat("a", 128) + "\r\n--b--\r\n"
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+
+		err := endpoints["token"](r)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+		assert.Equal(t, http.StatusBadRequest, ErrorToRFC6749Error(err).Code)
+	})
+
+	t.Run("case=defaults to DefaultMaxRequestBodySize", func(t *testing.T) {
+		assert.EqualValues(t, DefaultMaxRequestBodySize, new(Fosite).GetMaxRequestBodySize())
+		assert.EqualValues(t, 64, f.GetMaxRequestBodySize())
+	})
+}
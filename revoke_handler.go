@@ -23,8 +23,6 @@ package fosite
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -50,12 +48,16 @@ import (
 func (f *Fosite) NewRevocationRequest(ctx context.Context, r *http.Request) error {
 	if r.Method != "POST" {
 		return errors.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s' but expected 'POST'.", r.Method))
-	} else if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
-		return errors.WithStack(ErrInvalidRequest.WithHint("Unable to parse HTTP body, make sure to send a properly formatted form request body.").WithCause(err).WithDebug(err.Error()))
+	} else if err := f.parseRequestForm(r); err != nil {
+		return err
 	} else if len(r.PostForm) == 0 {
 		return errors.WithStack(ErrInvalidRequest.WithHint("The POST body can not be empty."))
 	}
 
+	if err := f.checkRateLimit(ctx, RevocationEndpoint, r); err != nil {
+		return err
+	}
+
 	client, err := f.AuthenticateClient(ctx, r, r.PostForm)
 	if err != nil {
 		return err
@@ -103,27 +105,11 @@ func (f *Fosite) WriteRevocationResponse(rw http.ResponseWriter, err error) {
 	}
 
 	if errors.Is(err, ErrInvalidRequest) {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(ErrInvalidRequest)
-		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
-			return
-		}
-
-		rw.WriteHeader(ErrInvalidRequest.Code)
-		_, _ = rw.Write(js)
+		f.writeRFC6749Error(rw, ErrInvalidRequest)
 	} else if errors.Is(err, ErrInvalidClient) {
-		rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-		js, err := json.Marshal(ErrInvalidClient)
-		if err != nil {
-			http.Error(rw, fmt.Sprintf(`{"error": "%s"}`, err.Error()), http.StatusInternalServerError)
-			return
-		}
-
-		rw.WriteHeader(ErrInvalidClient.Code)
-		_, _ = rw.Write(js)
+		f.writeRFC6749Error(rw, ErrInvalidClient)
+	} else if errors.Is(err, ErrSlowDown) {
+		f.writeRFC6749Error(rw, ErrSlowDown)
 	} else {
 		// 200 OK
 		rw.WriteHeader(http.StatusOK)
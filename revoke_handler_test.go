@@ -206,7 +206,7 @@ func TestNewRevocationRequest(t *testing.T) {
 				Method:   c.method,
 			}
 			c.mock()
-			ctx := NewContext()
+			ctx := context.TODO()
 			fosite.RevocationHandlers = c.handlers
 			err := fosite.NewRevocationRequest(ctx, r)
 
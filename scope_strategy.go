@@ -21,7 +21,11 @@
 
 package fosite
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 // ScopeStrategy is a strategy for matching scopes.
 type ScopeStrategy func(haystack []string, needle string) bool
@@ -101,3 +105,36 @@ func WildcardScopeStrategy(matchers []string, needle string) bool {
 
 	return false
 }
+
+// validateKnownScopes returns ErrInvalidScope if one of the scopes is not in the registry of known scopes.
+func (f *Fosite) validateKnownScopes(scopes []string) error {
+	if f.KnownScopes == nil {
+		return nil
+	}
+
+	strategy := f.ScopeStrategy
+	if strategy == nil {
+		strategy = ExactScopeStrategy
+	}
+
+	for _, scope := range scopes {
+		if !strategy(f.KnownScopes, scope) {
+			return errors.WithStack(ErrInvalidScope.WithHintf("The requested scope '%s' is not known to this authorization server.", scope).WithParameter("scope"))
+		}
+	}
+
+	return nil
+}
+
+// withDefaultScopes returns the requested scopes or, if the request omitted the scope parameter, the client's default
+// scopes, see ClientWithDefaultScopes, falling back to DefaultScopes.
+func (f *Fosite) withDefaultScopes(client Client, scopes Arguments) Arguments {
+	if len(scopes) > 0 {
+		return scopes
+	}
+
+	if c, ok := client.(ClientWithDefaultScopes); ok && len(c.GetDefaultScopes()) > 0 {
+		return c.GetDefaultScopes()
+	}
+	return f.DefaultScopes
+}
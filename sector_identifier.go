@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/go-convenience/stringslice"
+)
+
+// SectorIdentifierValidator validates the sector_identifier_uri of clients using pairwise subject identifiers, see
+// https://openid.net/specs/openid-connect-registration-1_0.html#SectorIdentifierValidation
+type SectorIdentifierValidator interface {
+	// ValidateSectorIdentifier returns an error if the client uses pairwise subject identifiers and its registered
+	// redirect URIs are not included in the document at its sector_identifier_uri.
+	ValidateSectorIdentifier(ctx context.Context, client Client) error
+}
+
+const (
+	// DefaultSectorIdentifierCacheTTL is the time a fetched sector identifier document is cached.
+	DefaultSectorIdentifierCacheTTL = time.Hour
+
+	// DefaultSectorIdentifierFailureCacheTTL is the time a failure to fetch a sector identifier document is cached.
+	DefaultSectorIdentifierFailureCacheTTL = time.Minute
+
+	// DefaultSectorIdentifierMaxBodySize is the maximum size in bytes of a sector identifier document.
+	DefaultSectorIdentifierMaxBodySize = 1 << 20
+)
+
+type cachedSectorIdentifier struct {
+	redirectURIs []string
+	err          error
+	expiresAt    time.Time
+}
+
+// DefaultSectorIdentifierValidator fetches sector identifier documents using HTTP and caches them for a fixed TTL.
+// Failures to fetch a document are cached for a shorter TTL, so that an unreachable sector_identifier_uri is not
+// requested on every authorization request.
+type DefaultSectorIdentifierValidator struct {
+	fetcher    *remoteFetcher
+	documents  map[string]cachedSectorIdentifier
+	ttl        time.Duration
+	failureTTL time.Duration
+	clock      Clock
+	sync.Mutex
+}
+
+// SectorIdentifierValidatorWithHTTPClient sets the HTTP client used to fetch sector identifier documents. Defaults to
+// a client which times out after DefaultRemoteFetchTimeout.
+func SectorIdentifierValidatorWithHTTPClient(client *http.Client) func(*DefaultSectorIdentifierValidator) {
+	return func(v *DefaultSectorIdentifierValidator) {
+		v.fetcher.client = client
+	}
+}
+
+// SectorIdentifierValidatorWithCacheTTL sets how long a fetched sector identifier document is cached. Defaults to
+// DefaultSectorIdentifierCacheTTL.
+func SectorIdentifierValidatorWithCacheTTL(ttl time.Duration) func(*DefaultSectorIdentifierValidator) {
+	return func(v *DefaultSectorIdentifierValidator) {
+		v.ttl = ttl
+	}
+}
+
+// SectorIdentifierValidatorWithFailureCacheTTL sets how long a failure to fetch a sector identifier document is
+// cached. Defaults to DefaultSectorIdentifierFailureCacheTTL.
+func SectorIdentifierValidatorWithFailureCacheTTL(ttl time.Duration) func(*DefaultSectorIdentifierValidator) {
+	return func(v *DefaultSectorIdentifierValidator) {
+		v.failureTTL = ttl
+	}
+}
+
+// SectorIdentifierValidatorWithClock sets the clock used to compute when cached sector identifier documents and
+// failures expire. Defaults to the system clock.
+func SectorIdentifierValidatorWithClock(clock Clock) func(*DefaultSectorIdentifierValidator) {
+	return func(v *DefaultSectorIdentifierValidator) {
+		v.clock = clock
+	}
+}
+
+func NewDefaultSectorIdentifierValidator(opts ...func(*DefaultSectorIdentifierValidator)) *DefaultSectorIdentifierValidator {
+	v := &DefaultSectorIdentifierValidator{
+		fetcher:    newRemoteFetcher(newDefaultRemoteHTTPClient(), DefaultSectorIdentifierMaxBodySize),
+		documents:  make(map[string]cachedSectorIdentifier),
+		ttl:        DefaultSectorIdentifierCacheTTL,
+		failureTTL: DefaultSectorIdentifierFailureCacheTTL,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+func (v *DefaultSectorIdentifierValidator) ValidateSectorIdentifier(ctx context.Context, client Client) error {
+	c, ok := client.(SubjectTypeClient)
+	if !ok || c.GetSubjectType() != PairwiseSubjectType || c.GetSectorIdentifierURI() == "" {
+		return nil
+	}
+
+	location := c.GetSectorIdentifierURI()
+	if u, err := url.Parse(location); err != nil || u.Scheme != "https" {
+		return errors.WithStack(ErrUnauthorizedClient.WithHintf("The sector_identifier_uri '%s' of the OAuth 2.0 Client must use the https scheme.", location))
+	}
+
+	redirectURIs, err := v.fetch(ctx, location)
+	if err != nil {
+		return err
+	}
+
+	for _, redirectURI := range client.GetRedirectURIs() {
+		if !stringslice.Has(redirectURIs, redirectURI) {
+			return errors.WithStack(ErrUnauthorizedClient.WithHintf("The redirect URI '%s' of the OAuth 2.0 Client is not included in the document at its sector_identifier_uri '%s'.", redirectURI, location))
+		}
+	}
+
+	return nil
+}
+
+func (v *DefaultSectorIdentifierValidator) fetch(ctx context.Context, location string) ([]string, error) {
+	now := v.clock.Now()
+
+	v.Lock()
+	document, ok := v.documents[location]
+	v.Unlock()
+
+	if ok && now.Before(document.expiresAt) {
+		return document.redirectURIs, document.err
+	}
+
+	redirectURIs, err := v.fetchDocument(ctx, location)
+	ttl := v.ttl
+	if err != nil {
+		ttl = v.failureTTL
+	}
+
+	v.Lock()
+	v.documents[location] = cachedSectorIdentifier{redirectURIs: redirectURIs, err: err, expiresAt: now.Add(ttl)}
+	v.Unlock()
+
+	return redirectURIs, err
+}
+
+func (v *DefaultSectorIdentifierValidator) fetchDocument(ctx context.Context, location string) ([]string, error) {
+	document, err := v.fetcher.fetch(ctx, location)
+	if errors.Is(err, errRemoteDocumentTooLarge) {
+		return nil, errors.WithStack(ErrServerError.WithHintf("The sector identifier document from location '%s' exceeds the maximum size of %d bytes.", location, v.fetcher.maxBodySize))
+	} else if err != nil {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Unable to fetch the sector identifier document from location '%s'. Check for typos or other network issues.", location).WithCause(err).WithDebug(err.Error()))
+	}
+
+	if document.statusCode != http.StatusOK {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Expected status code 200 from location '%s' but received code %d.", location, document.statusCode))
+	}
+
+	var redirectURIs []string
+	if err := json.Unmarshal(document.body, &redirectURIs); err != nil {
+		return nil, errors.WithStack(ErrServerError.WithHintf("Unable to decode the sector identifier document from location '%s'. It must be a JSON array of redirect URIs.", location).WithCause(err).WithDebug(err.Error()))
+	}
+
+	return redirectURIs, nil
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func newSectorIdentifierServer(hits *int32) *httptest.Server {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		switch r.URL.Path {
+		case "/sector.json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `["https://a.example.com/cb","https://b.example.com/cb"]`)
+		case "/invalid.json":
+			fmt.Fprint(w, `{"redirect_uris":["https://a.example.com/cb"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return ts
+}
+
+func newPairwiseClient(sectorIdentifierURI string, redirectURIs ...string) *DefaultOpenIDConnectClient {
+	return &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{
+			ID:            "foo",
+			RedirectURIs:  redirectURIs,
+			ResponseTypes: []string{"code"},
+			Scopes:        []string{"openid"},
+		},
+		SubjectType:         PairwiseSubjectType,
+		SectorIdentifierURI: sectorIdentifierURI,
+	}
+}
+
+func TestDefaultSectorIdentifierValidator(t *testing.T) {
+	var hits int32
+	ts := newSectorIdentifierServer(&hits)
+	defer ts.Close()
+	v := NewDefaultSectorIdentifierValidator(SectorIdentifierValidatorWithHTTPClient(ts.Client()))
+
+	for k, c := range []struct {
+		d         string
+		client    Client
+		expectErr error
+	}{
+		{
+			d:      "should pass because the redirect URIs are included in the sector identifier document",
+			client: newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb", "https://b.example.com/cb"),
+		},
+		{
+			d:         "should fail because a redirect URI is not included in the sector identifier document",
+			client:    newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb", "https://c.example.com/cb"),
+			expectErr: ErrUnauthorizedClient,
+		},
+		{
+			d:         "should fail because the sector identifier document is not a JSON array",
+			client:    newPairwiseClient(ts.URL+"/invalid.json", "https://a.example.com/cb"),
+			expectErr: ErrServerError,
+		},
+		{
+			d:         "should fail because the sector identifier document does not exist",
+			client:    newPairwiseClient(ts.URL+"/not-found.json", "https://a.example.com/cb"),
+			expectErr: ErrServerError,
+		},
+		{
+			d:         "should fail because the sector_identifier_uri is unreachable",
+			client:    newPairwiseClient("https://127.0.0.1:1/sector.json", "https://a.example.com/cb"),
+			expectErr: ErrServerError,
+		},
+		{
+			d:         "should fail because the sector_identifier_uri does not use https",
+			client:    newPairwiseClient("http://sector.example.com/sector.json", "https://a.example.com/cb"),
+			expectErr: ErrUnauthorizedClient,
+		},
+		{
+			d:      "should pass because the client does not use pairwise subject identifiers",
+			client: &DefaultOpenIDConnectClient{DefaultClient: &DefaultClient{ID: "foo"}, SectorIdentifierURI: "http://127.0.0.1:1/sector.json"},
+		},
+		{
+			d:      "should pass because the client does not set a sector_identifier_uri",
+			client: newPairwiseClient("", "https://a.example.com/cb"),
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			err := v.ValidateSectorIdentifier(context.Background(), c.client)
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("case=sector identifier documents are cached", func(t *testing.T) {
+		v := NewDefaultSectorIdentifierValidator(SectorIdentifierValidatorWithHTTPClient(ts.Client()))
+		client := newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb")
+
+		before := atomic.LoadInt32(&hits)
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		assert.EqualValues(t, before+1, atomic.LoadInt32(&hits))
+	})
+
+	t.Run("case=sector identifier documents expire", func(t *testing.T) {
+		v := NewDefaultSectorIdentifierValidator(SectorIdentifierValidatorWithHTTPClient(ts.Client()), SectorIdentifierValidatorWithCacheTTL(0))
+		client := newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb")
+
+		before := atomic.LoadInt32(&hits)
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		assert.EqualValues(t, before+2, atomic.LoadInt32(&hits))
+	})
+
+	t.Run("case=sector identifier documents expire after the cache ttl", func(t *testing.T) {
+		now := time.Now()
+		v := NewDefaultSectorIdentifierValidator(
+			SectorIdentifierValidatorWithHTTPClient(ts.Client()),
+			SectorIdentifierValidatorWithCacheTTL(time.Hour),
+			SectorIdentifierValidatorWithClock(func() time.Time { return now }),
+		)
+		client := newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb")
+
+		before := atomic.LoadInt32(&hits)
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		now = now.Add(time.Hour - time.Second)
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		assert.EqualValues(t, before+1, atomic.LoadInt32(&hits))
+
+		now = now.Add(time.Second)
+		require.NoError(t, v.ValidateSectorIdentifier(context.Background(), client))
+		assert.EqualValues(t, before+2, atomic.LoadInt32(&hits))
+	})
+
+	t.Run("case=failures are cached", func(t *testing.T) {
+		for k, c := range []struct {
+			failureTTL   time.Duration
+			expectedHits int32
+		}{
+			{failureTTL: time.Hour, expectedHits: 1},
+			{failureTTL: 0, expectedHits: 2},
+		} {
+			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+				v := NewDefaultSectorIdentifierValidator(SectorIdentifierValidatorWithHTTPClient(ts.Client()), SectorIdentifierValidatorWithFailureCacheTTL(c.failureTTL))
+				client := newPairwiseClient(ts.URL+"/not-found.json", "https://a.example.com/cb")
+
+				before := atomic.LoadInt32(&hits)
+				require.EqualError(t, v.ValidateSectorIdentifier(context.Background(), client), ErrServerError.Error())
+				require.EqualError(t, v.ValidateSectorIdentifier(context.Background(), client), ErrServerError.Error())
+				assert.EqualValues(t, before+c.expectedHits, atomic.LoadInt32(&hits))
+			})
+		}
+	})
+}
+
+func TestNewAuthorizeRequestSectorIdentifier(t *testing.T) {
+	var hits int32
+	ts := newSectorIdentifierServer(&hits)
+	defer ts.Close()
+
+	store := storage.NewMemoryStore()
+	f := &Fosite{
+		Store:                     store,
+		ScopeStrategy:             ExactScopeStrategy,
+		AudienceMatchingStrategy:  DefaultAudienceMatchingStrategy,
+		SectorIdentifierValidator: NewDefaultSectorIdentifierValidator(SectorIdentifierValidatorWithHTTPClient(ts.Client())),
+	}
+
+	newRequest := func() *http.Request {
+		return &http.Request{Header: http.Header{}, URL: &url.URL{RawQuery: url.Values{
+			"redirect_uri":  {"https://a.example.com/cb"},
+			"client_id":     {"foo"},
+			"response_type": {"code"},
+			"scope":         {"openid"},
+			"state":         {"some-random-state"},
+		}.Encode()}}
+	}
+
+	t.Run("case=should pass with a matching sector identifier document", func(t *testing.T) {
+		store.Clients["foo"] = newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb")
+		_, err := f.NewAuthorizeRequest(context.Background(), newRequest())
+		require.NoError(t, err)
+	})
+
+	t.Run("case=should fail with a non-matching sector identifier document", func(t *testing.T) {
+		store.Clients["foo"] = newPairwiseClient(ts.URL+"/sector.json", "https://a.example.com/cb", "https://c.example.com/cb")
+		_, err := f.NewAuthorizeRequest(context.Background(), newRequest())
+		require.EqualError(t, err, ErrUnauthorizedClient.Error())
+	})
+
+	t.Run("case=should fail with an unreachable sector_identifier_uri", func(t *testing.T) {
+		store.Clients["foo"] = newPairwiseClient("https://127.0.0.1:1/sector.json", "https://a.example.com/cb")
+		_, err := f.NewAuthorizeRequest(context.Background(), newRequest())
+		require.EqualError(t, err, ErrServerError.Error())
+	})
+}
@@ -22,6 +22,7 @@
 package fosite
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -29,6 +30,10 @@ import (
 
 // Session is an interface that is used to store session data between OAuth2 requests. It can be used to look up
 // when a session expires or what the subject's name was.
+//
+// Storage implementations persist sessions using a SessionSerializer, so a session must round-trip through the
+// serializer in use. The default JSONSessionSerializer only persists exported fields; session types with private
+// fields, interface fields or data that must be protected at rest should be used with a custom SessionSerializer.
 type Session interface {
 	// SetExpiresAt sets the expiration time of a token.
 	//
@@ -50,11 +55,45 @@ type Session interface {
 	Clone() Session
 }
 
+// GrantTypeSession represents a session which records the grant type used to issue its tokens. Resource servers can
+// use it, for example, to only accept tokens issued using the "client_credentials" grant.
+type GrantTypeSession interface {
+	// SetGrantType sets the grant type used to issue the tokens of this session.
+	SetGrantType(grantType string)
+
+	// GetGrantType returns the grant type used to issue the tokens of this session, or an empty string if unknown.
+	GetGrantType() string
+}
+
+// SessionSerializer marshals sessions to bytes and back and can be used by storage implementations to persist them.
+type SessionSerializer interface {
+	// Marshal returns the serialized form of the session.
+	Marshal(session Session) ([]byte, error)
+
+	// Unmarshal populates the session, which is usually an empty session of the expected type, from its serialized form.
+	Unmarshal(data []byte, session Session) error
+}
+
+// JSONSessionSerializer is the default SessionSerializer and encodes sessions as JSON.
+type JSONSessionSerializer struct{}
+
+func (JSONSessionSerializer) Marshal(session Session) ([]byte, error) {
+	return json.Marshal(session)
+}
+
+func (JSONSessionSerializer) Unmarshal(data []byte, session Session) error {
+	return json.Unmarshal(data, session)
+}
+
 // DefaultSession is a default implementation of the session interface.
 type DefaultSession struct {
 	ExpiresAt map[TokenType]time.Time
 	Username  string
 	Subject   string
+
+	CertificateThumbprint string
+	DPoPKeyThumbprint     string
+	GrantType             string
 }
 
 func (s *DefaultSession) SetExpiresAt(key TokenType, exp time.Time) {
@@ -82,6 +121,10 @@ func (s *DefaultSession) GetUsername() string {
 	return s.Username
 }
 
+func (s *DefaultSession) SetSubject(subject string) {
+	s.Subject = subject
+}
+
 func (s *DefaultSession) GetSubject() string {
 	if s == nil {
 		return ""
@@ -90,6 +133,42 @@ func (s *DefaultSession) GetSubject() string {
 	return s.Subject
 }
 
+func (s *DefaultSession) SetCertificateThumbprint(thumbprint string) {
+	s.CertificateThumbprint = thumbprint
+}
+
+func (s *DefaultSession) GetCertificateThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.CertificateThumbprint
+}
+
+func (s *DefaultSession) SetDPoPKeyThumbprint(thumbprint string) {
+	s.DPoPKeyThumbprint = thumbprint
+}
+
+func (s *DefaultSession) GetDPoPKeyThumbprint() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.DPoPKeyThumbprint
+}
+
+func (s *DefaultSession) SetGrantType(grantType string) {
+	s.GrantType = grantType
+}
+
+func (s *DefaultSession) GetGrantType() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.GrantType
+}
+
 func (s *DefaultSession) Clone() Session {
 	if s == nil {
 		return nil
@@ -22,9 +22,16 @@
 package fosite
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSession(t *testing.T) {
@@ -33,3 +40,98 @@ func TestSession(t *testing.T) {
 	assert.Empty(t, s.GetUsername())
 	assert.Nil(t, s.Clone())
 }
+
+func TestJSONSessionSerializer(t *testing.T) {
+	session := &DefaultSession{
+		Subject:   "peter",
+		Username:  "peteru",
+		ExpiresAt: map[TokenType]time.Time{AccessToken: time.Now().UTC().Round(time.Second)},
+	}
+
+	var s SessionSerializer = JSONSessionSerializer{}
+	data, err := s.Marshal(session)
+	require.NoError(t, err)
+
+	restored := new(DefaultSession)
+	require.NoError(t, s.Unmarshal(data, restored))
+	assert.Equal(t, session, restored)
+}
+
+type encryptedFieldSession struct {
+	DefaultSession
+	secret string
+}
+
+type encryptedFieldSessionPayload struct {
+	DefaultSession
+	Secret []byte `json:"secret"`
+}
+
+// encryptingSessionSerializer encrypts the private secret field of encryptedFieldSession at rest.
+type encryptingSessionSerializer struct {
+	aead cipher.AEAD
+}
+
+func (s *encryptingSessionSerializer) Marshal(session Session) ([]byte, error) {
+	es := session.(*encryptedFieldSession)
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&encryptedFieldSessionPayload{
+		DefaultSession: es.DefaultSession,
+		Secret:         s.aead.Seal(nonce, nonce, []byte(es.secret), nil),
+	})
+}
+
+func (s *encryptingSessionSerializer) Unmarshal(data []byte, session Session) error {
+	var payload encryptedFieldSessionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	nonce, ciphertext := payload.Secret[:s.aead.NonceSize()], payload.Secret[s.aead.NonceSize():]
+	secret, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return err
+	}
+
+	es := session.(*encryptedFieldSession)
+	es.DefaultSession = payload.DefaultSession
+	es.secret = string(secret)
+	return nil
+}
+
+func TestCustomSessionSerializer(t *testing.T) {
+	block, err := aes.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	session := &encryptedFieldSession{
+		DefaultSession: DefaultSession{Subject: "peter"},
+		secret:         "some-secret-value",
+	}
+
+	t.Run("case=json serializer drops private fields", func(t *testing.T) {
+		s := JSONSessionSerializer{}
+		data, err := s.Marshal(session)
+		require.NoError(t, err)
+
+		restored := new(encryptedFieldSession)
+		require.NoError(t, s.Unmarshal(data, restored))
+		assert.Equal(t, "peter", restored.Subject)
+		assert.Empty(t, restored.secret)
+	})
+
+	t.Run("case=custom serializer encrypts private fields at rest", func(t *testing.T) {
+		var s SessionSerializer = &encryptingSessionSerializer{aead: aead}
+		data, err := s.Marshal(session)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "some-secret-value")
+
+		restored := new(encryptedFieldSession)
+		require.NoError(t, s.Unmarshal(data, restored))
+		assert.Equal(t, session, restored)
+	})
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+)
+
+// StateReplayStore keeps track of the state values used in authorization responses. If set, NewAuthorizeResponse
+// rejects an authorization request whose state was already used by the same client within the replay window. This is
+// an optional hardening measure: per RFC 6749 the client remains responsible for binding the state to the user agent
+// and checking it.
+type StateReplayStore interface {
+	// SetStateUsed marks the state as used by the client until the given expiry time. It returns ErrInvalidState if the
+	// state was already used by the client and has not expired yet, and any other error if the check failed. Expired
+	// states may be cleaned up as they can no longer be replayed.
+	SetStateUsed(ctx context.Context, clientID string, state string, exp time.Time) error
+}
@@ -21,7 +21,47 @@
 
 package fosite
 
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // Storage defines fosite's minimal storage interface.
 type Storage interface {
 	ClientManager
 }
+
+// ExpiredPruner is implemented by storages which are able to remove expired authorization codes, tokens and
+// sessions. Use PruneExpired to clean up such a storage in bounded batches.
+type ExpiredPruner interface {
+	// PruneExpired removes at most limit entries which expired before the given time and returns the number of
+	// entries removed. A limit of zero or less removes all expired entries.
+	PruneExpired(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// PruneExpired removes all entries from the storage which expired before the given time, deleting at most batchSize
+// entries per call to the storage so that locks or transactions are held only briefly. It stops early if the
+// context is canceled and returns the number of entries removed.
+func PruneExpired(ctx context.Context, pruner ExpiredPruner, before time.Time, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("batch size must be greater than zero")
+	}
+
+	var total int
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, errors.WithStack(err)
+		}
+
+		pruned, err := pruner.PruneExpired(ctx, before, batchSize)
+		total += pruned
+		if err != nil {
+			return total, err
+		}
+		if pruned < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/fosite"
 )
@@ -36,6 +37,25 @@ type MemoryUserRelation struct {
 	Password string
 }
 
+// IssuerPublicKeys are the keys a trusted issuer signs RFC 7523 JWT bearer assertions for a subject with, and the
+// scopes these assertions may request.
+type IssuerPublicKeys struct {
+	Keys   jose.JSONWebKeySet
+	Scopes []string
+}
+
+// UsedStateKey identifies a state used by a client in MemoryStore.UsedStates.
+type UsedStateKey struct {
+	ClientID string
+	State    string
+}
+
+// UsedNonceKey identifies a nonce used by a client in MemoryStore.UsedNonces.
+type UsedNonceKey struct {
+	ClientID string
+	Nonce    string
+}
+
 type MemoryStore struct {
 	Clients         map[string]fosite.Client
 	AuthorizeCodes  map[string]StoreAuthorizeCode
@@ -45,9 +65,20 @@ type MemoryStore struct {
 	PKCES           map[string]fosite.Requester
 	Users           map[string]MemoryUserRelation
 	BlacklistedJTIs map[string]time.Time
+	// In-memory client ID and state to expiry time
+	UsedStates map[UsedStateKey]time.Time
+	// In-memory client ID and nonce to expiry time
+	UsedNonces map[UsedNonceKey]time.Time
 	// In-memory request ID to token signatures
 	AccessTokenRequestIDs  map[string]string
 	RefreshTokenRequestIDs map[string]string
+	// In-memory issuer and subject to public keys
+	IssuerPublicKeys map[string]map[string]IssuerPublicKeys
+	// In-memory denied token or grant ID to expiry time
+	DeniedTokens map[string]time.Time
+
+	// clock decides which client assertion JTIs, denied tokens, states and nonces expired.
+	clock fosite.Clock
 
 	clientsMutex                sync.RWMutex
 	authorizeCodesMutex         sync.RWMutex
@@ -57,8 +88,12 @@ type MemoryStore struct {
 	pkcesMutex                  sync.RWMutex
 	usersMutex                  sync.RWMutex
 	blacklistedJTIsMutex        sync.RWMutex
+	usedStatesMutex             sync.RWMutex
+	usedNoncesMutex             sync.RWMutex
 	accessTokenRequestIDsMutex  sync.RWMutex
 	refreshTokenRequestIDsMutex sync.RWMutex
+	issuerPublicKeysMutex       sync.RWMutex
+	deniedTokensMutex           sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -73,6 +108,10 @@ func NewMemoryStore() *MemoryStore {
 		AccessTokenRequestIDs:  make(map[string]string),
 		RefreshTokenRequestIDs: make(map[string]string),
 		BlacklistedJTIs:        make(map[string]time.Time),
+		UsedStates:             make(map[UsedStateKey]time.Time),
+		UsedNonces:             make(map[UsedNonceKey]time.Time),
+		IssuerPublicKeys:       make(map[string]map[string]IssuerPublicKeys),
+		DeniedTokens:           make(map[string]time.Time),
 	}
 }
 
@@ -161,7 +200,7 @@ func (s *MemoryStore) ClientAssertionJWTValid(_ context.Context, jti string) err
 	s.blacklistedJTIsMutex.RLock()
 	defer s.blacklistedJTIsMutex.RUnlock()
 
-	if exp, exists := s.BlacklistedJTIs[jti]; exists && exp.After(time.Now()) {
+	if exp, exists := s.BlacklistedJTIs[jti]; exists && exp.After(s.clock.Now()) {
 		return fosite.ErrJTIKnown
 	}
 
@@ -174,7 +213,7 @@ func (s *MemoryStore) SetClientAssertionJWT(_ context.Context, jti string, exp t
 
 	// delete expired jtis
 	for j, e := range s.BlacklistedJTIs {
-		if e.Before(time.Now()) {
+		if e.Before(s.clock.Now()) {
 			delete(s.BlacklistedJTIs, j)
 		}
 	}
@@ -187,6 +226,85 @@ func (s *MemoryStore) SetClientAssertionJWT(_ context.Context, jti string, exp t
 	return nil
 }
 
+func (s *MemoryStore) Deny(_ context.Context, jti string, exp time.Time) error {
+	s.deniedTokensMutex.Lock()
+	defer s.deniedTokensMutex.Unlock()
+
+	if s.DeniedTokens == nil {
+		s.DeniedTokens = make(map[string]time.Time)
+	}
+
+	// delete entries of expired tokens
+	for j, e := range s.DeniedTokens {
+		if !e.IsZero() && e.Before(s.clock.Now()) {
+			delete(s.DeniedTokens, j)
+		}
+	}
+
+	s.DeniedTokens[jti] = exp
+	return nil
+}
+
+func (s *MemoryStore) IsDenied(_ context.Context, jti string) (bool, error) {
+	return s.isDenied(jti, s.clock.Now()), nil
+}
+
+func (s *MemoryStore) isDenied(jti string, now time.Time) bool {
+	s.deniedTokensMutex.RLock()
+	defer s.deniedTokensMutex.RUnlock()
+
+	exp, exists := s.DeniedTokens[jti]
+	return exists && (exp.IsZero() || exp.After(now))
+}
+
+func (s *MemoryStore) SetStateUsed(_ context.Context, clientID string, state string, exp time.Time) error {
+	s.usedStatesMutex.Lock()
+	defer s.usedStatesMutex.Unlock()
+
+	if s.UsedStates == nil {
+		s.UsedStates = make(map[UsedStateKey]time.Time)
+	}
+
+	// delete expired states
+	for k, e := range s.UsedStates {
+		if e.Before(s.clock.Now()) {
+			delete(s.UsedStates, k)
+		}
+	}
+
+	key := UsedStateKey{ClientID: clientID, State: state}
+	if _, exists := s.UsedStates[key]; exists {
+		return fosite.ErrInvalidState
+	}
+
+	s.UsedStates[key] = exp
+	return nil
+}
+
+func (s *MemoryStore) SetNonceUsed(_ context.Context, clientID string, nonce string, _ string, exp time.Time) error {
+	s.usedNoncesMutex.Lock()
+	defer s.usedNoncesMutex.Unlock()
+
+	if s.UsedNonces == nil {
+		s.UsedNonces = make(map[UsedNonceKey]time.Time)
+	}
+
+	// delete expired nonces
+	for k, e := range s.UsedNonces {
+		if e.Before(s.clock.Now()) {
+			delete(s.UsedNonces, k)
+		}
+	}
+
+	key := UsedNonceKey{ClientID: clientID, Nonce: nonce}
+	if _, exists := s.UsedNonces[key]; exists {
+		return fosite.ErrNonceAlreadyUsed
+	}
+
+	s.UsedNonces[key] = exp
+	return nil
+}
+
 func (s *MemoryStore) CreateAuthorizeCodeSession(_ context.Context, code string, req fosite.Requester) error {
 	s.authorizeCodesMutex.Lock()
 	defer s.authorizeCodesMutex.Unlock()
@@ -323,11 +441,16 @@ func (s *MemoryStore) Authenticate(_ context.Context, name string, secret string
 		return fosite.ErrNotFound
 	}
 	if rel.Password != secret {
-		return errors.New("Invalid credentials")
+		return errors.WithStack(fosite.ErrNotFound.WithDebug("Invalid credentials"))
 	}
 	return nil
 }
 
+// PruneExpired implements fosite.ExpiredPruner. Entries whose session carries no expiry are never pruned.
+func (s *MemoryStore) PruneExpired(_ context.Context, before time.Time, limit int) (int, error) {
+	return s.evictExpired(before, 0, limit), nil
+}
+
 func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, requestID string) error {
 	s.refreshTokenRequestIDsMutex.Lock()
 	defer s.refreshTokenRequestIDsMutex.Unlock()
@@ -356,3 +479,56 @@ func (s *MemoryStore) RevokeAccessToken(ctx context.Context, requestID string) e
 	}
 	return nil
 }
+
+// SetIssuerPublicKeys trusts the issuer to sign RFC 7523 JWT bearer assertions for the subject with the keys.
+func (s *MemoryStore) SetIssuerPublicKeys(issuer string, subject string, keys IssuerPublicKeys) {
+	s.issuerPublicKeysMutex.Lock()
+	defer s.issuerPublicKeysMutex.Unlock()
+
+	if s.IssuerPublicKeys == nil {
+		s.IssuerPublicKeys = make(map[string]map[string]IssuerPublicKeys)
+	}
+	if s.IssuerPublicKeys[issuer] == nil {
+		s.IssuerPublicKeys[issuer] = make(map[string]IssuerPublicKeys)
+	}
+	s.IssuerPublicKeys[issuer][subject] = keys
+}
+
+func (s *MemoryStore) getIssuerPublicKeys(issuer string, subject string) (IssuerPublicKeys, error) {
+	s.issuerPublicKeysMutex.RLock()
+	defer s.issuerPublicKeysMutex.RUnlock()
+
+	keys, ok := s.IssuerPublicKeys[issuer][subject]
+	if !ok {
+		return IssuerPublicKeys{}, fosite.ErrNotFound
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) GetPublicKey(_ context.Context, issuer string, subject string, keyID string) (*jose.JSONWebKey, error) {
+	keys, err := s.getIssuerPublicKeys(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if found := keys.Keys.Key(keyID); len(found) > 0 {
+		return &found[0], nil
+	}
+	return nil, fosite.ErrNotFound
+}
+
+func (s *MemoryStore) GetPublicKeys(_ context.Context, issuer string, subject string) (*jose.JSONWebKeySet, error) {
+	keys, err := s.getIssuerPublicKeys(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	return &keys.Keys, nil
+}
+
+func (s *MemoryStore) GetPublicKeyScopes(_ context.Context, issuer string, subject string) ([]string, error) {
+	keys, err := s.getIssuerPublicKeys(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	return keys.Scopes, nil
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// ErrStorageFull is returned by TTLMemoryStore when an authorization code, PKCE, OpenID Connect or token session
+// can not be stored because MaxEntries has been reached and no expired entry could be evicted.
+var ErrStorageFull = errors.New("storage capacity exceeded")
+
+// TTLMemoryStoreConfig configures a TTLMemoryStore.
+type TTLMemoryStoreConfig struct {
+	// MaxEntries limits the number of entries kept per kind of session (authorization codes, PKCE sessions,
+	// OpenID Connect sessions, access and refresh tokens). Zero means unlimited.
+	MaxEntries int
+
+	// EvictionInterval sets how often expired entries are evicted in the background. If zero, no background
+	// eviction takes place and EvictExpired must be called manually.
+	EvictionInterval time.Duration
+
+	// DefaultTTL is used for entries whose session carries no expiry for the respective token type, counted from
+	// the time the request was made. Zero means such entries never expire.
+	DefaultTTL time.Duration
+
+	// Clock returns the current time and decides which entries expired. Defaults to the system clock.
+	Clock fosite.Clock
+}
+
+// TTLMemoryStore is a MemoryStore which evicts expired authorization codes, PKCE and OpenID Connect sessions,
+// tokens, client assertion JTIs, states, nonces and denied tokens, and which bounds the number of entries it keeps. Unlike the example
+// MemoryStore it is suitable for long-running, single-instance deployments. Call Close to stop the background
+// eviction.
+type TTLMemoryStore struct {
+	*MemoryStore
+
+	config TTLMemoryStoreConfig
+	done   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+// NewTTLMemoryStore returns a TTLMemoryStore and, if config.EvictionInterval is set, starts evicting expired
+// entries in the background.
+func NewTTLMemoryStore(config TTLMemoryStoreConfig) *TTLMemoryStore {
+	s := &TTLMemoryStore{
+		MemoryStore: NewMemoryStore(),
+		config:      config,
+		done:        make(chan struct{}),
+	}
+	s.MemoryStore.clock = config.Clock
+
+	if config.EvictionInterval > 0 {
+		s.wg.Add(1)
+		go s.evictPeriodically()
+	}
+
+	return s
+}
+
+// Close stops the background eviction. It is safe to call Close more than once.
+func (s *TTLMemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+func (s *TTLMemoryStore) evictPeriodically() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.EvictionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.EvictExpired()
+		}
+	}
+}
+
+// EvictExpired removes all expired entries and returns how many were removed.
+func (s *TTLMemoryStore) EvictExpired() int {
+	return s.evictExpired(s.config.Clock.Now(), s.config.DefaultTTL, 0)
+}
+
+// PruneExpired implements fosite.ExpiredPruner and honors DefaultTTL for entries without an expiry.
+func (s *TTLMemoryStore) PruneExpired(_ context.Context, before time.Time, limit int) (int, error) {
+	return s.evictExpired(before, s.config.DefaultTTL, limit), nil
+}
+
+func (s *TTLMemoryStore) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) error {
+	if err := s.ensureCapacity(&s.idSessionsMutex, func() int { return len(s.IDSessions) }); err != nil {
+		return err
+	}
+	return s.MemoryStore.CreateOpenIDConnectSession(ctx, authorizeCode, requester)
+}
+
+func (s *TTLMemoryStore) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	if err := s.ensureCapacity(&s.authorizeCodesMutex, func() int { return len(s.AuthorizeCodes) }); err != nil {
+		return err
+	}
+	return s.MemoryStore.CreateAuthorizeCodeSession(ctx, code, req)
+}
+
+func (s *TTLMemoryStore) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	if err := s.ensureCapacity(&s.pkcesMutex, func() int { return len(s.PKCES) }); err != nil {
+		return err
+	}
+	return s.MemoryStore.CreatePKCERequestSession(ctx, code, req)
+}
+
+func (s *TTLMemoryStore) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	if err := s.ensureCapacity(&s.accessTokensMutex, func() int { return len(s.AccessTokens) }); err != nil {
+		return err
+	}
+	return s.MemoryStore.CreateAccessTokenSession(ctx, signature, req)
+}
+
+func (s *TTLMemoryStore) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	if err := s.ensureCapacity(&s.refreshTokensMutex, func() int { return len(s.RefreshTokens) }); err != nil {
+		return err
+	}
+	return s.MemoryStore.CreateRefreshTokenSession(ctx, signature, req)
+}
+
+// ensureCapacity evicts expired entries if the map guarded by mu has reached MaxEntries and returns ErrStorageFull
+// if that did not free up any space. The check is best-effort: concurrent writers may briefly exceed MaxEntries.
+func (s *TTLMemoryStore) ensureCapacity(mu *sync.RWMutex, size func() int) error {
+	if s.config.MaxEntries <= 0 {
+		return nil
+	}
+
+	full := func() bool {
+		mu.RLock()
+		defer mu.RUnlock()
+		return size() >= s.config.MaxEntries
+	}
+
+	if !full() {
+		return nil
+	}
+
+	s.EvictExpired()
+	if full() {
+		return errors.WithStack(ErrStorageFull)
+	}
+	return nil
+}
+
+// expiresAt returns when the entry expires, or the zero time if it never does.
+func expiresAt(requester fosite.Requester, tokenType fosite.TokenType, defaultTTL time.Duration) time.Time {
+	if session := requester.GetSession(); session != nil {
+		if exp := session.GetExpiresAt(tokenType); !exp.IsZero() {
+			return exp
+		}
+	}
+	if defaultTTL > 0 && !requester.GetRequestedAt().IsZero() {
+		return requester.GetRequestedAt().Add(defaultTTL)
+	}
+	return time.Time{}
+}
+
+func isExpired(requester fosite.Requester, tokenType fosite.TokenType, now time.Time, defaultTTL time.Duration) bool {
+	exp := expiresAt(requester, tokenType, defaultTTL)
+	return !exp.IsZero() && exp.Before(now)
+}
+
+// evictExpired removes entries which expired before now and returns how many were removed. If limit is positive, at
+// most limit entries are removed.
+func (s *MemoryStore) evictExpired(now time.Time, defaultTTL time.Duration, limit int) int {
+	var evicted int
+	done := func() bool {
+		return limit > 0 && evicted >= limit
+	}
+
+	s.authorizeCodesMutex.Lock()
+	for code, rel := range s.AuthorizeCodes {
+		if done() {
+			break
+		}
+		if isExpired(rel.Requester, fosite.AuthorizeCode, now, defaultTTL) {
+			delete(s.AuthorizeCodes, code)
+			evicted++
+		}
+	}
+	s.authorizeCodesMutex.Unlock()
+
+	s.idSessionsMutex.Lock()
+	for code, req := range s.IDSessions {
+		if done() {
+			break
+		}
+		if isExpired(req, fosite.AuthorizeCode, now, defaultTTL) {
+			delete(s.IDSessions, code)
+			evicted++
+		}
+	}
+	s.idSessionsMutex.Unlock()
+
+	s.pkcesMutex.Lock()
+	for code, req := range s.PKCES {
+		if done() {
+			break
+		}
+		if isExpired(req, fosite.AuthorizeCode, now, defaultTTL) {
+			delete(s.PKCES, code)
+			evicted++
+		}
+	}
+	s.pkcesMutex.Unlock()
+
+	// Lock the request ID index before the tokens, in the same order as Create*TokenSession does.
+	s.accessTokenRequestIDsMutex.Lock()
+	s.accessTokensMutex.Lock()
+	for signature, req := range s.AccessTokens {
+		if done() {
+			break
+		}
+		if isExpired(req, fosite.AccessToken, now, defaultTTL) {
+			delete(s.AccessTokens, signature)
+			if s.AccessTokenRequestIDs[req.GetID()] == signature {
+				delete(s.AccessTokenRequestIDs, req.GetID())
+			}
+			evicted++
+		}
+	}
+	s.accessTokensMutex.Unlock()
+	s.accessTokenRequestIDsMutex.Unlock()
+
+	s.refreshTokenRequestIDsMutex.Lock()
+	s.refreshTokensMutex.Lock()
+	for signature, req := range s.RefreshTokens {
+		if done() {
+			break
+		}
+		if isExpired(req, fosite.RefreshToken, now, defaultTTL) {
+			delete(s.RefreshTokens, signature)
+			if s.RefreshTokenRequestIDs[req.GetID()] == signature {
+				delete(s.RefreshTokenRequestIDs, req.GetID())
+			}
+			evicted++
+		}
+	}
+	s.refreshTokensMutex.Unlock()
+	s.refreshTokenRequestIDsMutex.Unlock()
+
+	s.blacklistedJTIsMutex.Lock()
+	for jti, exp := range s.BlacklistedJTIs {
+		if done() {
+			break
+		}
+		if exp.Before(now) {
+			delete(s.BlacklistedJTIs, jti)
+			evicted++
+		}
+	}
+	s.blacklistedJTIsMutex.Unlock()
+
+	s.usedStatesMutex.Lock()
+	for key, exp := range s.UsedStates {
+		if done() {
+			break
+		}
+		if exp.Before(now) {
+			delete(s.UsedStates, key)
+			evicted++
+		}
+	}
+	s.usedStatesMutex.Unlock()
+
+	s.usedNoncesMutex.Lock()
+	for key, exp := range s.UsedNonces {
+		if done() {
+			break
+		}
+		if exp.Before(now) {
+			delete(s.UsedNonces, key)
+			evicted++
+		}
+	}
+	s.usedNoncesMutex.Unlock()
+
+	s.deniedTokensMutex.Lock()
+	for jti, exp := range s.DeniedTokens {
+		if done() {
+			break
+		}
+		if !exp.IsZero() && exp.Before(now) {
+			delete(s.DeniedTokens, jti)
+			evicted++
+		}
+	}
+	s.deniedTokensMutex.Unlock()
+
+	return evicted
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTTLTestRequest(id string, requestedAt time.Time, expiresAt map[fosite.TokenType]time.Time) *fosite.Request {
+	r := fosite.NewRequest()
+	r.ID = id
+	r.RequestedAt = requestedAt
+	r.Session = &fosite.DefaultSession{ExpiresAt: expiresAt}
+	return r
+}
+
+func TestTTLMemoryStoreEvictsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now().UTC()}
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{DefaultTTL: time.Hour, Clock: clock.Now})
+	defer s.Close()
+
+	expiring := newTTLTestRequest("expiring", clock.Now(), map[fosite.TokenType]time.Time{
+		fosite.AuthorizeCode: clock.Now().Add(time.Minute),
+		fosite.AccessToken:   clock.Now().Add(time.Minute),
+	})
+	lasting := newTTLTestRequest("lasting", clock.Now(), map[fosite.TokenType]time.Time{
+		fosite.AuthorizeCode: clock.Now().Add(2 * time.Hour),
+		fosite.AccessToken:   clock.Now().Add(2 * time.Hour),
+		fosite.RefreshToken:  clock.Now().Add(2 * time.Hour),
+	})
+
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "expiring", expiring))
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "lasting", lasting))
+	require.NoError(t, s.CreatePKCERequestSession(ctx, "expiring", expiring))
+	require.NoError(t, s.CreateOpenIDConnectSession(ctx, "expiring", expiring))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "expiring", expiring))
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "lasting", lasting))
+	// The refresh token of the expiring request has no expiry in its session and falls back to DefaultTTL.
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "expiring", expiring))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "lasting", lasting))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", clock.Now().Add(time.Minute)))
+	require.NoError(t, s.SetNonceUsed(ctx, "client", "nonce", "expiring", clock.Now().Add(time.Minute)))
+
+	assert.Equal(t, 0, s.EvictExpired())
+
+	clock.Add(2 * time.Minute)
+	assert.Equal(t, 6, s.EvictExpired())
+
+	_, err := s.GetAuthorizeCodeSession(ctx, "expiring", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	_, err = s.GetPKCERequestSession(ctx, "expiring", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	_, err = s.GetOpenIDConnectSession(ctx, "expiring", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	_, err = s.GetAccessTokenSession(ctx, "expiring", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	assert.NotContains(t, s.AccessTokenRequestIDs, "expiring")
+	assert.NoError(t, s.ClientAssertionJWTValid(ctx, "jti"))
+	assert.Empty(t, s.UsedNonces)
+
+	_, err = s.GetRefreshTokenSession(ctx, "expiring", nil)
+	assert.NoError(t, err)
+	_, err = s.GetAuthorizeCodeSession(ctx, "lasting", nil)
+	assert.NoError(t, err)
+	_, err = s.GetAccessTokenSession(ctx, "lasting", nil)
+	assert.NoError(t, err)
+
+	clock.Add(time.Hour)
+	assert.Equal(t, 1, s.EvictExpired())
+
+	_, err = s.GetRefreshTokenSession(ctx, "expiring", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	assert.NotContains(t, s.RefreshTokenRequestIDs, "expiring")
+	_, err = s.GetRefreshTokenSession(ctx, "lasting", nil)
+	assert.NoError(t, err)
+}
+
+func TestTTLMemoryStoreDeniedTokensExpire(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now().UTC()}
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{Clock: clock.Now})
+	defer s.Close()
+
+	require.NoError(t, s.Deny(ctx, "expiring", clock.Now().Add(time.Minute)))
+	require.NoError(t, s.Deny(ctx, "forever", time.Time{}))
+
+	denied, err := s.IsDenied(ctx, "expiring")
+	require.NoError(t, err)
+	assert.True(t, denied)
+	denied, err = s.IsDenied(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, denied)
+
+	clock.Add(2 * time.Minute)
+	denied, err = s.IsDenied(ctx, "expiring")
+	require.NoError(t, err)
+	assert.False(t, denied, "the token expired and does not need to be denied anymore")
+
+	assert.Equal(t, 1, s.EvictExpired())
+	assert.NotContains(t, s.DeniedTokens, "expiring")
+	denied, err = s.IsDenied(ctx, "forever")
+	require.NoError(t, err)
+	assert.True(t, denied)
+}
+
+func TestTTLMemoryStoreWritesUseClock(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now().UTC().Add(-time.Hour)}
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{Clock: clock.Now})
+	defer s.Close()
+
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", clock.Now().Add(time.Minute)))
+	require.NoError(t, s.Deny(ctx, "token", clock.Now().Add(time.Minute)))
+
+	// The entries expire after the test clock but before the system clock, so they must survive the next write.
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "other-jti", clock.Now().Add(time.Minute)))
+	require.NoError(t, s.Deny(ctx, "other-token", clock.Now().Add(time.Minute)))
+	assert.Contains(t, s.BlacklistedJTIs, "jti")
+	assert.Contains(t, s.DeniedTokens, "token")
+	assert.EqualError(t, s.SetClientAssertionJWT(ctx, "jti", clock.Now().Add(time.Minute)), fosite.ErrJTIKnown.Error())
+
+	clock.Add(2 * time.Minute)
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", clock.Now().Add(time.Minute)))
+	require.NoError(t, s.Deny(ctx, "new-token", clock.Now().Add(time.Minute)))
+	assert.NotContains(t, s.BlacklistedJTIs, "other-jti")
+	assert.NotContains(t, s.DeniedTokens, "token")
+}
+
+func TestTTLMemoryStoreEvictsInBackground(t *testing.T) {
+	ctx := context.Background()
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{EvictionInterval: 10 * time.Millisecond})
+	defer s.Close()
+
+	req := newTTLTestRequest("foo", time.Now().UTC(), map[fosite.TokenType]time.Time{
+		fosite.AccessToken: time.Now().UTC().Add(20 * time.Millisecond),
+	})
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "foo", req))
+
+	assert.Eventually(t, func() bool {
+		_, err := s.GetAccessTokenSession(ctx, "foo", nil)
+		return errors.Is(err, fosite.ErrNotFound)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTTLMemoryStoreMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Now().UTC()}
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{MaxEntries: 2, Clock: clock.Now})
+	defer s.Close()
+
+	for k := 0; k < 2; k++ {
+		req := newTTLTestRequest(fmt.Sprintf("%d", k), clock.Now(), map[fosite.TokenType]time.Time{
+			fosite.AccessToken: clock.Now().Add(time.Duration(k+1) * time.Minute),
+		})
+		require.NoError(t, s.CreateAccessTokenSession(ctx, fmt.Sprintf("%d", k), req))
+	}
+
+	err := s.CreateAccessTokenSession(ctx, "2", newTTLTestRequest("2", clock.Now(), nil))
+	assert.True(t, errors.Is(err, ErrStorageFull))
+
+	// Other kinds of sessions have their own limit.
+	assert.NoError(t, s.CreateRefreshTokenSession(ctx, "2", newTTLTestRequest("2", clock.Now(), nil)))
+
+	clock.Add(90 * time.Second)
+	assert.NoError(t, s.CreateAccessTokenSession(ctx, "2", newTTLTestRequest("2", clock.Now(), nil)))
+	assert.Len(t, s.AccessTokens, 2)
+}
+
+func TestTTLMemoryStoreConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	s := NewTTLMemoryStore(TTLMemoryStoreConfig{EvictionInterval: time.Millisecond, MaxEntries: 1000})
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				id := fmt.Sprintf("%d-%d", w, k)
+				now := time.Now().UTC()
+				req := newTTLTestRequest(id, now, map[fosite.TokenType]time.Time{
+					fosite.AuthorizeCode: now.Add(time.Duration(k%2) * time.Hour),
+					fosite.AccessToken:   now.Add(time.Duration(k%2) * time.Hour),
+					fosite.RefreshToken:  now.Add(time.Duration(k%2) * time.Hour),
+				})
+
+				assert.NoError(t, s.CreateAuthorizeCodeSession(ctx, id, req))
+				assert.NoError(t, s.CreateAccessTokenSession(ctx, id, req))
+				assert.NoError(t, s.CreateRefreshTokenSession(ctx, id, req))
+				_, _ = s.GetAccessTokenSession(ctx, id, nil)
+				_ = s.DeleteRefreshTokenSession(ctx, id)
+				_ = s.InvalidateAuthorizeCodeSession(ctx, id)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	s.EvictExpired()
+	for _, req := range s.AccessTokens {
+		assert.True(t, req.GetSession().GetExpiresAt(fosite.AccessToken).After(time.Now()))
+	}
+	assert.Len(t, s.AccessTokens, 400)
+}
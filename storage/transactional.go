@@ -14,6 +14,14 @@ import "context"
 // Implementations for `Commit` & `Rollback` should look for the transaction object inside the supplied context using the same
 // key used by `BeginTX`. If these methods have been called, it is expected that a txn object should be available in the provided
 // context.
+//
+// The following handler operations participate in a transaction when the storage implements `Transactional`. The
+// authorization code grant (`oauth2.AuthorizeExplicitGrantHandler`) invalidates the authorization code and creates the
+// access and refresh token sessions in one transaction and, if an authorization code is used twice, revokes the access and
+// refresh tokens issued for it in one transaction. The refresh token grant (`oauth2.RefreshTokenGrantHandler`) revokes the
+// old access and refresh tokens and creates the new access and refresh token sessions in one transaction. Token revocation
+// (`oauth2.TokenRevocationHandler`) revokes the access and refresh tokens of a request in one transaction. All other
+// operations, including reads, are performed without a transaction.
 type Transactional interface {
 	BeginTX(ctx context.Context) (context.Context, error)
 	Commit(ctx context.Context) error
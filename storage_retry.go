@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy retries idempotent storage reads which failed because of a transient error, for example a dropped
+// database connection. Writes and other mutating operations are never retried.
+//
+// Reads which fail with ErrNotFound are not retried, as are reads whose context is canceled.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed read is retried. Defaults to zero, which disables retries.
+	MaxRetries int
+
+	// Backoff is the time to wait before the first retry. It doubles with every further retry. Defaults to 50ms.
+	Backoff time.Duration
+
+	// MaxBackoff caps the time to wait between two retries. Defaults to one second.
+	MaxBackoff time.Duration
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff, max := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = 50 * time.Millisecond
+	}
+	if max <= 0 {
+		max = time.Second
+	}
+
+	for i := 0; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// Read calls read and retries it according to the policy until it succeeds, fails with a permanent error or the
+// context is canceled. A nil policy calls read exactly once.
+func (p *RetryPolicy) Read(ctx context.Context, read func() error) error {
+	err := read()
+	if p == nil {
+		return err
+	}
+
+	for attempt := 0; attempt < p.MaxRetries && err != nil && !errors.Is(err, ErrNotFound); attempt++ {
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		err = read()
+	}
+
+	return err
+}
+
+// getClient loads the client using the StorageRetryPolicy.
+func (f *Fosite) getClient(ctx context.Context, id string) (client Client, err error) {
+	err = f.StorageRetryPolicy.Read(ctx, func() error {
+		client, err = f.Store.GetClient(ctx, id)
+		return err
+	})
+	return client, err
+}
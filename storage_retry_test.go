@@ -0,0 +1,176 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+// flakyStore fails the first GetClient call of every client with a transient error.
+type flakyStore struct {
+	*storage.MemoryStore
+	calls map[string]int
+}
+
+func (s *flakyStore) GetClient(ctx context.Context, id string) (Client, error) {
+	s.calls[id]++
+	if s.calls[id] == 1 {
+		return nil, errors.New("connection reset by peer")
+	}
+	return s.MemoryStore.GetClient(ctx, id)
+}
+
+func TestRetryPolicyRead(t *testing.T) {
+	transient := errors.New("connection reset by peer")
+
+	for k, c := range []struct {
+		d           string
+		policy      *RetryPolicy
+		ctx         func() context.Context
+		errs        []error
+		expectErr   error
+		expectCalls int
+	}{
+		{
+			d:           "should not retry without a policy",
+			errs:        []error{transient, nil},
+			expectErr:   transient,
+			expectCalls: 1,
+		},
+		{
+			d:           "should not retry by default",
+			policy:      &RetryPolicy{},
+			errs:        []error{transient, nil},
+			expectErr:   transient,
+			expectCalls: 1,
+		},
+		{
+			d:           "should succeed on the second attempt",
+			policy:      &RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			errs:        []error{transient, nil},
+			expectCalls: 2,
+		},
+		{
+			d:           "should give up after the maximum number of retries",
+			policy:      &RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond},
+			errs:        []error{transient, transient, transient, nil},
+			expectErr:   transient,
+			expectCalls: 3,
+		},
+		{
+			d:           "should not retry if the resource does not exist",
+			policy:      &RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			errs:        []error{errors.WithStack(ErrNotFound), nil},
+			expectErr:   ErrNotFound,
+			expectCalls: 1,
+		},
+		{
+			d:      "should stop retrying once the context is canceled",
+			policy: &RetryPolicy{MaxRetries: 3, Backoff: time.Hour},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			errs:        []error{transient, nil},
+			expectErr:   transient,
+			expectCalls: 1,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			ctx := context.Background()
+			if c.ctx != nil {
+				ctx = c.ctx()
+			}
+
+			var calls int
+			err := c.policy.Read(ctx, func() error {
+				calls++
+				return c.errs[calls-1]
+			})
+
+			if c.expectErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, c.expectErr))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, c.expectCalls, calls)
+		})
+	}
+}
+
+func TestStorageRetryPolicyClientLookup(t *testing.T) {
+	newRequest := func() *http.Request {
+		return &http.Request{
+			Header: http.Header{},
+			Form: url.Values{
+				"redirect_uri":  {"https://foobar.com/cb"},
+				"client_id":     {"foo"},
+				"response_type": {"code"},
+				"state":         {"strong-state"},
+			},
+		}
+	}
+
+	newStore := func() *flakyStore {
+		s := storage.NewMemoryStore()
+		s.Clients["foo"] = &DefaultClient{
+			ID:            "foo",
+			RedirectURIs:  []string{"https://foobar.com/cb"},
+			ResponseTypes: []string{"code"},
+		}
+		return &flakyStore{MemoryStore: s, calls: map[string]int{}}
+	}
+
+	t.Run("case=should fail without retries", func(t *testing.T) {
+		f := &Fosite{Store: newStore()}
+		_, err := f.NewAuthorizeRequest(context.Background(), newRequest())
+		require.EqualError(t, err, ErrInvalidClient.Error())
+	})
+
+	t.Run("case=should succeed on the second attempt", func(t *testing.T) {
+		store := newStore()
+		f := &Fosite{
+			Store:                    store,
+			ScopeStrategy:            ExactScopeStrategy,
+			AudienceMatchingStrategy: DefaultAudienceMatchingStrategy,
+			StorageRetryPolicy:       &RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond},
+		}
+		ar, err := f.NewAuthorizeRequest(context.Background(), newRequest())
+		require.NoError(t, err)
+		assert.Equal(t, "foo", ar.GetClient().GetID())
+		assert.Equal(t, 2, store.calls["foo"])
+	})
+}
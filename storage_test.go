@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+type countingPruner struct {
+	ExpiredPruner
+	calls []int
+}
+
+func (p *countingPruner) PruneExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	p.calls = append(p.calls, limit)
+	return p.ExpiredPruner.PruneExpired(ctx, before, limit)
+}
+
+func TestPruneExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	store := storage.NewMemoryStore()
+	for k := 0; k < 5; k++ {
+		expired := NewRequest()
+		expired.ID = fmt.Sprintf("expired-%d", k)
+		expired.Session = &DefaultSession{ExpiresAt: map[TokenType]time.Time{
+			AccessToken:  now.Add(-time.Minute),
+			RefreshToken: now.Add(-time.Minute),
+		}}
+		require.NoError(t, store.CreateAccessTokenSession(ctx, expired.ID, expired))
+		require.NoError(t, store.CreateRefreshTokenSession(ctx, expired.ID, expired))
+	}
+
+	active := NewRequest()
+	active.ID = "active"
+	active.Session = &DefaultSession{ExpiresAt: map[TokenType]time.Time{
+		AuthorizeCode: now.Add(time.Minute),
+		AccessToken:   now.Add(time.Minute),
+		RefreshToken:  now.Add(time.Minute),
+	}}
+	require.NoError(t, store.CreateAuthorizeCodeSession(ctx, "active", active))
+	require.NoError(t, store.CreateAccessTokenSession(ctx, "active", active))
+	require.NoError(t, store.CreateRefreshTokenSession(ctx, "active", active))
+
+	pruner := &countingPruner{ExpiredPruner: store}
+	pruned, err := PruneExpired(ctx, pruner, now, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 10, pruned)
+	assert.Equal(t, []int{3, 3, 3, 3}, pruner.calls)
+
+	assert.Len(t, store.AccessTokens, 1)
+	assert.Len(t, store.RefreshTokens, 1)
+	_, err = store.GetAuthorizeCodeSession(ctx, "active", nil)
+	assert.NoError(t, err)
+	_, err = store.GetAccessTokenSession(ctx, "active", nil)
+	assert.NoError(t, err)
+	_, err = store.GetRefreshTokenSession(ctx, "active", nil)
+	assert.NoError(t, err)
+}
+
+func TestPruneExpiredStopsWhenContextIsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pruner := &countingPruner{ExpiredPruner: storage.NewMemoryStore()}
+	_, err := PruneExpired(ctx, pruner, time.Now(), 10)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, pruner.calls)
+}
+
+func TestPruneExpiredRequiresBatchSize(t *testing.T) {
+	_, err := PruneExpired(context.Background(), storage.NewMemoryStore(), time.Now(), 0)
+	assert.Error(t, err)
+}
@@ -27,8 +27,9 @@ package hmac
 import (
 	"crypto/hmac"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
-	"fmt"
+	"hash"
 	"strings"
 	"sync"
 
@@ -37,14 +38,36 @@ import (
 	"github.com/ory/fosite"
 )
 
+// Key is a secret identified by a key ID.
+type Key struct {
+	// ID is encoded in every token signed with this key. It must not contain "." or "~".
+	ID string
+
+	// Secret is used for signing HMAC-SHA256 and must be at least 32 byte long.
+	Secret []byte
+}
+
 // HMACStrategy is responsible for generating and validating challenges.
 type HMACStrategy struct {
 	TokenEntropy         int
 	GlobalSecret         []byte
 	RotatedGlobalSecrets [][]byte
+
+	// Keys is an ordered set of secrets, from oldest to newest. If set, tokens are signed with the newest key and are
+	// prefixed with its ID, for example "key-2~<token>.<signature>". Tokens are verified with the key their ID
+	// references, so retired keys remain valid as long as they are part of the set. Tokens without a key ID are
+	// verified with GlobalSecret and RotatedGlobalSecrets, which allows migrating to Keys.
+	Keys []Key
+
+	// hashes holds a *sync.Pool of keyed HMAC-SHA512/256 hashes per signing key, see generateHMAC.
+	hashes sync.Map
+
 	sync.Mutex
 }
 
+// keyIDSeparator separates the key ID from the token key.
+const keyIDSeparator = "~"
+
 const (
 	// key should be at least 256 bit long, making it
 	minimumEntropy = 32
@@ -58,15 +81,34 @@ var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
 // Generate generates a token and a matching signature or returns an error.
 // This method implements rfc6819 Section 5.1.4.2.2: Use High Entropy for Secrets.
 func (c *HMACStrategy) Generate() (string, string, error) {
+	return c.GenerateWithPrefix("")
+}
+
+// GenerateWithPrefix works like Generate but prepends prefix to the token, for example "ory_at_<token>.<signature>".
+// The prefix is part of the signed material, so a token only validates with ValidateWithPrefix and the same prefix.
+func (c *HMACStrategy) GenerateWithPrefix(tokenPrefix string) (string, string, error) {
 	c.Lock()
 	defer c.Unlock()
 
-	if len(c.GlobalSecret) < minimumSecretLength {
-		return "", "", errors.Errorf("secret for signing HMAC-SHA256 is expected to be 32 byte long, got %d byte", len(c.GlobalSecret))
+	if strings.ContainsAny(tokenPrefix, "."+keyIDSeparator) {
+		return "", "", errors.Errorf("token prefix must not contain '.' or '%s', got '%s'", keyIDSeparator, tokenPrefix)
+	}
+
+	secret, prefix := c.GlobalSecret, ""
+	if len(c.Keys) > 0 {
+		key := c.Keys[len(c.Keys)-1]
+		if key.ID == "" || strings.ContainsAny(key.ID, "."+keyIDSeparator) {
+			return "", "", errors.Errorf("key id for signing HMAC-SHA256 must not be empty or contain '.' or '%s', got '%s'", keyIDSeparator, key.ID)
+		}
+		secret, prefix = key.Secret, key.ID+keyIDSeparator
+	}
+
+	if len(secret) < minimumSecretLength {
+		return "", "", errors.Errorf("secret for signing HMAC-SHA256 is expected to be 32 byte long, got %d byte", len(secret))
 	}
 
 	var signingKey [32]byte
-	copy(signingKey[:], c.GlobalSecret)
+	copy(signingKey[:], secret)
 
 	if c.TokenEntropy < minimumEntropy {
 		c.TokenEntropy = minimumEntropy
@@ -84,15 +126,46 @@ func (c *HMACStrategy) Generate() (string, string, error) {
 		return "", "", errors.WithStack(err)
 	}
 
-	signature := generateHMAC(tokenKey, &signingKey)
-
-	encodedSignature := b64.EncodeToString(signature)
-	encodedToken := fmt.Sprintf("%s.%s", b64.EncodeToString(tokenKey), encodedSignature)
-	return encodedToken, encodedSignature, nil
+	var mac [sha512.Size256]byte
+	signature := c.generateHMAC(mac[:0], tokenPrefix, tokenKey, &signingKey)
+
+	// The token is encoded into a single buffer: <token prefix><key id prefix><token key>.<signature>
+	encodedKeyLen, encodedSignatureLen := b64.EncodedLen(len(tokenKey)), b64.EncodedLen(len(signature))
+	encoded := make([]byte, len(tokenPrefix)+len(prefix)+encodedKeyLen+1+encodedSignatureLen)
+	offset := copy(encoded, tokenPrefix)
+	offset += copy(encoded[offset:], prefix)
+	b64.Encode(encoded[offset:], tokenKey)
+	offset += encodedKeyLen
+	encoded[offset] = '.'
+	b64.Encode(encoded[offset+1:], signature)
+
+	encodedToken := string(encoded)
+	return encodedToken, encodedToken[len(encodedToken)-encodedSignatureLen:], nil
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
 func (c *HMACStrategy) Validate(token string) (err error) {
+	return c.ValidateWithPrefix("", token)
+}
+
+// ValidateWithPrefix validates a token generated by GenerateWithPrefix. Tokens which do not start with the prefix,
+// or which were signed with another prefix, are rejected.
+func (c *HMACStrategy) ValidateWithPrefix(tokenPrefix, token string) (err error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return errors.WithStack(fosite.ErrInvalidTokenFormat)
+	}
+	token = strings.TrimPrefix(token, tokenPrefix)
+
+	if i := strings.Index(token, keyIDSeparator); i >= 0 && i < strings.Index(token, ".") {
+		kid := token[:i]
+		for _, key := range c.Keys {
+			if key.ID == kid {
+				return c.validate(key.Secret, tokenPrefix, token[i+len(keyIDSeparator):])
+			}
+		}
+		return errors.WithStack(fosite.ErrTokenSignatureMismatch)
+	}
+
 	var keys [][]byte
 
 	if len(c.GlobalSecret) > 0 {
@@ -104,7 +177,7 @@ func (c *HMACStrategy) Validate(token string) (err error) {
 	}
 
 	for _, key := range keys {
-		if err = c.validate(key, token); err == nil {
+		if err = c.validate(key, tokenPrefix, token); err == nil {
 			return nil
 		} else if errors.Is(err, fosite.ErrTokenSignatureMismatch) {
 		} else {
@@ -119,7 +192,7 @@ func (c *HMACStrategy) Validate(token string) (err error) {
 	return err
 }
 
-func (c *HMACStrategy) validate(secret []byte, token string) error {
+func (c *HMACStrategy) validate(secret []byte, tokenPrefix, token string) error {
 	if len(secret) < minimumSecretLength {
 		return errors.Errorf("secret for signing HMAC-SHA256 is expected to be 32 byte long, got %d byte", len(secret))
 	}
@@ -127,13 +200,13 @@ func (c *HMACStrategy) validate(secret []byte, token string) error {
 	var signingKey [32]byte
 	copy(signingKey[:], secret)
 
-	split := strings.Split(token, ".")
-	if len(split) != 2 {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 || strings.IndexByte(token[dot+1:], '.') >= 0 {
 		return errors.WithStack(fosite.ErrInvalidTokenFormat)
 	}
 
-	tokenKey := split[0]
-	tokenSignature := split[1]
+	tokenKey := token[:dot]
+	tokenSignature := token[dot+1:]
 	if tokenKey == "" || tokenSignature == "" {
 		return errors.WithStack(fosite.ErrInvalidTokenFormat)
 	}
@@ -148,7 +221,8 @@ func (c *HMACStrategy) validate(secret []byte, token string) error {
 		return errors.WithStack(err)
 	}
 
-	expectedMAC := generateHMAC(decodedTokenKey, &signingKey)
+	var mac [sha512.Size256]byte
+	expectedMAC := c.generateHMAC(mac[:0], tokenPrefix, decodedTokenKey, &signingKey)
 	if !hmac.Equal(expectedMAC, decodedTokenSignature) {
 		// Hash is invalid
 		return errors.WithStack(fosite.ErrTokenSignatureMismatch)
@@ -167,12 +241,46 @@ func (c *HMACStrategy) Signature(token string) string {
 	return split[1]
 }
 
-func generateHMAC(data []byte, key *[32]byte) []byte {
-	h := hmac.New(sha512.New512_256, key[:])
+// ValidateSignature checks that the signature, for example one loaded from storage, belongs to the token. It is
+// cheaper than Validate because the HMAC is not derived again, and compares in constant time.
+func (c *HMACStrategy) ValidateSignature(token string, signature string) error {
+	if !CompareSignatures(c.Signature(token), signature) {
+		return errors.WithStack(fosite.ErrTokenSignatureMismatch)
+	}
+	return nil
+}
+
+// CompareSignatures reports whether two token signatures are equal. The comparison takes constant time for signatures
+// of the same length so that the number of matching leading characters is not revealed. Storage implementations
+// which compare signatures in memory must use it instead of ==.
+func CompareSignatures(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// generateHMAC appends the HMAC-SHA512/256 of the token prefix followed by the token key to dst. Setting up a keyed
+// hash costs more than hashing a token, so the hashes are pooled per key and reset before they are used again.
+func (c *HMACStrategy) generateHMAC(dst []byte, tokenPrefix string, tokenKey []byte, key *[32]byte) []byte {
+	pool, ok := c.hashes.Load(*key)
+	if !ok {
+		k := *key
+		pool, _ = c.hashes.LoadOrStore(k, &sync.Pool{New: func() interface{} {
+			return hmac.New(sha512.New512_256, k[:])
+		}})
+	}
+
+	h := pool.(*sync.Pool).Get().(hash.Hash)
+	defer pool.(*sync.Pool).Put(h)
+	h.Reset()
+
 	// sha512.digest.Write() always returns nil for err, the panic should never happen
-	_, err := h.Write(data)
-	if err != nil {
+	if _, err := h.Write([]byte(tokenPrefix)); err != nil {
+		panic(err)
+	}
+	if _, err := h.Write(tokenKey); err != nil {
 		panic(err)
 	}
-	return h.Sum(nil)
+	return h.Sum(dst)
 }
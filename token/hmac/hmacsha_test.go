@@ -22,6 +22,15 @@
 package hmac
 
 import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ory/fosite"
@@ -131,3 +140,237 @@ func TestValidateWithRotatedKeyInvalid(t *testing.T) {
 
 	require.EqualError(t, new(HMACStrategy).Validate(token), "a secret for signing HMAC-SHA256 is expected to be defined, but none were")
 }
+
+func TestValidateSignature(t *testing.T) {
+	cg := HMACStrategy{
+		GlobalSecret: []byte("1234567890123456789012345678901234567890"),
+	}
+
+	token, signature, err := cg.Generate()
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		d         string
+		token     string
+		signature string
+		expectErr bool
+	}{
+		{d: "matching signature", token: token, signature: signature},
+		{d: "signature differs in the last character", token: token, signature: signature[:len(signature)-1] + "x", expectErr: true},
+		{d: "signature is a prefix", token: token, signature: signature[:len(signature)-1], expectErr: true},
+		{d: "signature is longer", token: token, signature: signature + "x", expectErr: true},
+		{d: "empty signature", token: token, signature: "", expectErr: true},
+		{d: "token without signature", token: "foo", signature: "", expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			err := cg.ValidateSignature(c.token, c.signature)
+			if c.expectErr {
+				require.EqualError(t, err, fosite.ErrTokenSignatureMismatch.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestSignaturesAreNotComparedWithEqualityOperators makes sure that signatures and MACs are only ever compared
+// using hmac.Equal or subtle.ConstantTimeCompare, because == and != return as soon as the first byte differs.
+func TestSignaturesAreNotComparedWithEqualityOperators(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "hmacsha.go", nil, 0)
+	require.NoError(t, err)
+
+	isSecret := func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		name := strings.ToLower(id.Name)
+		return strings.Contains(name, "sig") || strings.Contains(name, "mac") || name == "a" || name == "b"
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		e, ok := n.(*ast.BinaryExpr)
+		if !ok || (e.Op != token.EQL && e.Op != token.NEQ) {
+			return true
+		}
+
+		// Comparing against a constant, for example the empty string, does not leak the secret.
+		if _, ok := e.X.(*ast.BasicLit); ok {
+			return true
+		} else if _, ok := e.Y.(*ast.BasicLit); ok {
+			return true
+		}
+
+		assert.False(t, isSecret(e.X) || isSecret(e.Y), "signatures must be compared in constant time at %s", fset.Position(e.Pos()))
+		return true
+	})
+}
+
+func TestValidateWithRotatedKeySet(t *testing.T) {
+	a := Key{ID: "a", Secret: []byte("1234567890123456789012345678901234567890")}
+	b := Key{ID: "b", Secret: []byte("0000000090123456789012345678901234567890")}
+
+	legacy := HMACStrategy{GlobalSecret: []byte("abcdefgh90123456789012345678901234567890")}
+	legacyToken, _, err := legacy.Generate()
+	require.NoError(t, err)
+
+	cg := HMACStrategy{GlobalSecret: legacy.GlobalSecret, Keys: []Key{a}}
+	tokenA, signatureA, err := cg.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tokenA, "a~"))
+	assert.Equal(t, signatureA, cg.Signature(tokenA))
+
+	// Rotating to key b keeps tokens signed with key a and the global secret valid.
+	cg.Keys = []Key{a, b}
+	tokenB, _, err := cg.Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tokenB, "b~"))
+
+	require.NoError(t, cg.Validate(tokenA))
+	require.NoError(t, cg.Validate(tokenB))
+	require.NoError(t, cg.Validate(legacyToken))
+
+	// The key ID selects the key, a token can not be verified with a different key.
+	require.EqualError(t, cg.Validate("b~"+strings.TrimPrefix(tokenA, "a~")), fosite.ErrTokenSignatureMismatch.Error())
+	require.EqualError(t, cg.Validate("c~"+strings.TrimPrefix(tokenA, "a~")), fosite.ErrTokenSignatureMismatch.Error())
+
+	// Retiring key a invalidates the tokens signed with it.
+	cg.Keys = []Key{b}
+	require.EqualError(t, cg.Validate(tokenA), fosite.ErrTokenSignatureMismatch.Error())
+	require.NoError(t, cg.Validate(tokenB))
+}
+
+func TestGenerateFailsWithInvalidKeyID(t *testing.T) {
+	for _, id := range []string{"", "a.b", "a~b"} {
+		cg := HMACStrategy{Keys: []Key{{ID: id, Secret: []byte("1234567890123456789012345678901234567890")}}}
+		_, _, err := cg.Generate()
+		require.Error(t, err, id)
+	}
+}
+
+func TestGenerateAndValidateWithPrefix(t *testing.T) {
+	for _, keys := range [][]Key{nil, {{ID: "a", Secret: []byte("1234567890123456789012345678901234567890")}}} {
+		cg := HMACStrategy{GlobalSecret: []byte("abcdefgh90123456789012345678901234567890"), Keys: keys}
+
+		token, signature, err := cg.GenerateWithPrefix("ory_at_")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(token, "ory_at_"), token)
+		assert.Equal(t, signature, cg.Signature(token))
+		require.NoError(t, cg.ValidateWithPrefix("ory_at_", token))
+
+		// The prefix is signed, so it can neither be removed nor replaced.
+		require.Error(t, cg.Validate(token))
+		require.EqualError(t, cg.ValidateWithPrefix("", strings.TrimPrefix(token, "ory_at_")), fosite.ErrTokenSignatureMismatch.Error())
+		require.EqualError(t, cg.ValidateWithPrefix("ory_rt_", "ory_rt_"+strings.TrimPrefix(token, "ory_at_")), fosite.ErrTokenSignatureMismatch.Error())
+		require.EqualError(t, cg.ValidateWithPrefix("ory_rt_", token), fosite.ErrInvalidTokenFormat.Error())
+
+		// Tokens without a prefix do not validate with one.
+		plain, _, err := cg.Generate()
+		require.NoError(t, err)
+		require.Error(t, cg.ValidateWithPrefix("ory_at_", "ory_at_"+plain))
+	}
+}
+
+func TestGenerateFailsWithInvalidPrefix(t *testing.T) {
+	cg := HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+	for _, prefix := range []string{"a.b", "a~b"} {
+		_, _, err := cg.GenerateWithPrefix(prefix)
+		require.Error(t, err, prefix)
+	}
+}
+
+// referenceToken formats a token the way the strategy always did: the base64 encoded key and the base64 encoded
+// HMAC-SHA512/256 of the prefix and key, separated by a dot.
+func referenceToken(t *testing.T, secret []byte, tokenPrefix, keyPrefix string, tokenKey []byte) string {
+	var signingKey [32]byte
+	copy(signingKey[:], secret)
+
+	h := hmac.New(sha512.New512_256, signingKey[:])
+	_, err := h.Write(append([]byte(tokenPrefix), tokenKey...))
+	require.NoError(t, err)
+	return fmt.Sprintf("%s%s%s.%s", tokenPrefix, keyPrefix, base64.RawURLEncoding.EncodeToString(tokenKey), base64.RawURLEncoding.EncodeToString(h.Sum(nil)))
+}
+
+func TestGenerateMatchesReferenceFormat(t *testing.T) {
+	secret := []byte("1234567890123456789012345678901234567890")
+	other := []byte("0987654321098765432109876543210987654321")
+
+	for k, c := range []struct {
+		d         string
+		strategy  *HMACStrategy
+		prefix    string
+		secret    []byte
+		keyPrefix string
+	}{
+		{d: "global secret", strategy: &HMACStrategy{GlobalSecret: secret}, secret: secret},
+		{d: "global secret with higher entropy", strategy: &HMACStrategy{GlobalSecret: secret, TokenEntropy: 61}, secret: secret},
+		{d: "global secret with token prefix", strategy: &HMACStrategy{GlobalSecret: secret}, prefix: "ory_at_", secret: secret},
+		{d: "key set", strategy: &HMACStrategy{Keys: []Key{{ID: "key-1", Secret: secret}, {ID: "key-2", Secret: other}}}, prefix: "ory_rt_", secret: other, keyPrefix: "key-2~"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				token, signature, err := c.strategy.GenerateWithPrefix(c.prefix)
+				require.NoError(t, err)
+
+				encodedKey := strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(token, c.prefix), c.keyPrefix), ".", 2)[0]
+				tokenKey, err := base64.RawURLEncoding.DecodeString(encodedKey)
+				require.NoError(t, err)
+				assert.Len(t, tokenKey, c.strategy.TokenEntropy)
+
+				expected := referenceToken(t, c.secret, c.prefix, c.keyPrefix, tokenKey)
+				assert.Equal(t, expected, token)
+				assert.Equal(t, expected[strings.LastIndex(expected, ".")+1:], signature)
+				require.NoError(t, c.strategy.ValidateWithPrefix(c.prefix, token))
+			}
+		})
+	}
+}
+
+func TestGenerateConcurrentlyWithSeveralKeys(t *testing.T) {
+	secrets := [][]byte{
+		[]byte("1234567890123456789012345678901234567890"),
+		[]byte("0987654321098765432109876543210987654321"),
+	}
+	strategies := []*HMACStrategy{{GlobalSecret: secrets[0]}, {GlobalSecret: secrets[1]}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				s := strategies[(i+j)%2]
+				token, _, err := s.Generate()
+				assert.NoError(t, err)
+				assert.NoError(t, s.Validate(token))
+				assert.Error(t, strategies[(i+j+1)%2].Validate(token))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	cg := &HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, _, err := cg.GenerateWithPrefix("ory_at_"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	cg := &HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}
+	token, _, err := cg.GenerateWithPrefix("ory_at_")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := cg.ValidateWithPrefix("ory_at_", token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -21,7 +21,12 @@
 
 package jwt
 
-import "time"
+import (
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
 
 // Mapper is the interface used internally to map key-value pairs
 type Mapper interface {
@@ -93,3 +98,31 @@ func Copy(elements map[string]interface{}) (result map[string]interface{}) {
 
 	return result
 }
+
+// ValidateTimeClaims validates the exp, iat and nbf claims against the given time. The skew is the time a claim
+// may be off to account for clocks which are not perfectly in sync. The returned error is a *jwt.ValidationError
+// just like the one returned by jwt.MapClaims.Valid.
+func ValidateTimeClaims(claims jwt.MapClaims, now time.Time, skew time.Duration) error {
+	vErr := new(jwt.ValidationError)
+
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), false) {
+		vErr.Inner = errors.New("Token is expired")
+		vErr.Errors |= jwt.ValidationErrorExpired
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(skew).Unix(), false) {
+		vErr.Inner = errors.New("Token used before issued")
+		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		vErr.Inner = errors.New("Token is not valid yet")
+		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+
+	if vErr.Errors == 0 {
+		return nil
+	}
+
+	return vErr
+}
@@ -43,6 +43,7 @@ type IDTokenClaims struct {
 	AuthenticationContextClassReference string
 	AuthenticationMethodsReference      string
 	CodeHash                            string
+	StateHash                           string
 	Extra                               map[string]interface{}
 }
 
@@ -75,6 +76,10 @@ func (c *IDTokenClaims) ToMap() map[string]interface{} {
 		ret["c_hash"] = c.CodeHash
 	}
 
+	if len(c.StateHash) > 0 {
+		ret["s_hash"] = c.StateHash
+	}
+
 	if !c.AuthTime.IsZero() {
 		ret["auth_time"] = c.AuthTime.Unix()
 	}
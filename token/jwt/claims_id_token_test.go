@@ -41,6 +41,7 @@ var idTokenClaims = &IDTokenClaims{
 	RequestedAt:                         time.Now().UTC(),
 	AccessTokenHash:                     "foobar",
 	CodeHash:                            "barfoo",
+	StateHash:                           "bazfoo",
 	AuthenticationContextClassReference: "acr",
 	AuthenticationMethodsReference:      "amr",
 	Extra: map[string]interface{}{
@@ -72,6 +73,7 @@ func TestIDTokenClaimsToMap(t *testing.T) {
 		"baz":       idTokenClaims.Extra["baz"],
 		"at_hash":   idTokenClaims.AccessTokenHash,
 		"c_hash":    idTokenClaims.CodeHash,
+		"s_hash":    idTokenClaims.StateHash,
 		"auth_time": idTokenClaims.AuthTime.Unix(),
 		"acr":       idTokenClaims.AuthenticationContextClassReference,
 		"amr":       idTokenClaims.AuthenticationMethodsReference,
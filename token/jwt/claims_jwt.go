@@ -152,10 +152,19 @@ func (c *JWTClaims) FromMap(m map[string]interface{}) {
 				c.Issuer = s
 			}
 		case "aud":
-			if s, ok := v.(string); ok {
-				c.Audience = []string{s}
-			} else if s, ok := v.([]string); ok {
-				c.Audience = s
+			switch aud := v.(type) {
+			case string:
+				c.Audience = []string{aud}
+			case []string:
+				c.Audience = aud
+			case []interface{}:
+				// JSON decoding yields []interface{} for the list of audiences.
+				c.Audience = make([]string, 0, len(aud))
+				for _, a := range aud {
+					if s, ok := a.(string); ok {
+						c.Audience = append(c.Audience, s)
+					}
+				}
 			}
 		case "iat":
 			switch v.(type) {
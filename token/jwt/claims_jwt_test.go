@@ -91,6 +91,15 @@ func TestClaimsFromMap(t *testing.T) {
 	assert.Equal(t, jwtClaims, &claims)
 }
 
+func TestClaimsFromMapWithDecodedAudiences(t *testing.T) {
+	var claims JWTClaims
+	claims.FromMap(map[string]interface{}{"aud": []interface{}{"https://api-a.example.com", "https://api-b.example.com"}})
+	assert.Equal(t, []string{"https://api-a.example.com", "https://api-b.example.com"}, claims.Audience)
+
+	claims.FromMap(map[string]interface{}{"aud": "https://api-a.example.com"})
+	assert.Equal(t, []string{"https://api-a.example.com"}, claims.Audience)
+}
+
 func TestScopeFieldString(t *testing.T) {
 	jwtClaimsWithString := jwtClaims.WithScopeField(JWTScopeFieldString)
 	// Making a copy of jwtClaimsMap.
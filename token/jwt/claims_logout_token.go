@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
+)
+
+// BackChannelLogoutEvent is the member of the events claim which identifies a JSON Web Token as a logout token.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutTokenClaims represent the claims used in OpenID Connect Back-Channel Logout tokens
+type LogoutTokenClaims struct {
+	JTI       string
+	Issuer    string
+	Subject   string
+	Audience  []string
+	IssuedAt  time.Time
+	SessionID string
+	Extra     map[string]interface{}
+}
+
+// ToMap will transform the headers to a map structure
+func (c *LogoutTokenClaims) ToMap() map[string]interface{} {
+	var ret = Copy(c.Extra)
+	ret["iss"] = c.Issuer
+	ret["jti"] = c.JTI
+	ret["events"] = map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}}
+
+	if len(c.JTI) == 0 {
+		ret["jti"] = uuid.New()
+	}
+
+	if len(c.Audience) > 0 {
+		ret["aud"] = c.Audience
+	} else {
+		ret["aud"] = []string{}
+	}
+
+	if len(c.Subject) > 0 {
+		ret["sub"] = c.Subject
+	}
+
+	if len(c.SessionID) > 0 {
+		ret["sid"] = c.SessionID
+	}
+
+	// A logout token must never contain a nonce, see https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+	delete(ret, "nonce")
+
+	ret["iat"] = float64(c.IssuedAt.Unix())
+	return ret
+}
+
+// Add will add a key-value pair to the extra field
+func (c *LogoutTokenClaims) Add(key string, value interface{}) {
+	if c.Extra == nil {
+		c.Extra = make(map[string]interface{})
+	}
+	c.Extra[key] = value
+}
+
+// Get will get a value from the extra field based on a given key
+func (c *LogoutTokenClaims) Get(key string) interface{} {
+	return c.ToMap()[key]
+}
+
+// ToMapClaims will return a jwt-go MapClaims representation
+func (c LogoutTokenClaims) ToMapClaims() jwt.MapClaims {
+	return c.ToMap()
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/ory/fosite/token/jwt"
+)
+
+func TestLogoutTokenClaimsToMap(t *testing.T) {
+	claims := &LogoutTokenClaims{
+		JTI:       "foo-id",
+		Issuer:    "fosite",
+		Subject:   "peter",
+		Audience:  []string{"tests"},
+		IssuedAt:  time.Now().UTC().Round(time.Second),
+		SessionID: "session-id",
+		Extra: map[string]interface{}{
+			"foo":   "bar",
+			"nonce": "must-be-removed",
+		},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"jti":    claims.JTI,
+		"iss":    claims.Issuer,
+		"sub":    claims.Subject,
+		"aud":    claims.Audience,
+		"iat":    float64(claims.IssuedAt.Unix()),
+		"sid":    claims.SessionID,
+		"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+		"foo":    "bar",
+	}, claims.ToMap())
+
+	m := (&LogoutTokenClaims{Subject: "peter"}).ToMap()
+	assert.NotEmpty(t, m["jti"])
+	assert.NotContains(t, m, "sid")
+}
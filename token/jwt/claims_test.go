@@ -22,10 +22,13 @@
 package jwt
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToString(t *testing.T) {
@@ -44,3 +47,34 @@ func TestToTime(t *testing.T) {
 	assert.Equal(t, now, ToTime(now.Unix()))
 	assert.Equal(t, now, ToTime(float64(now.Unix())))
 }
+
+func TestValidateTimeClaims(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for k, tc := range []struct {
+		d      string
+		claims jwt.MapClaims
+		skew   time.Duration
+		errors uint32
+	}{
+		{d: "should pass without time claims", claims: jwt.MapClaims{}},
+		{d: "should pass because exp is now", claims: jwt.MapClaims{"exp": float64(now.Unix())}},
+		{d: "should fail because exp is in the past", claims: jwt.MapClaims{"exp": float64(now.Add(-time.Second).Unix())}, errors: jwt.ValidationErrorExpired},
+		{d: "should pass because exp is within the skew", claims: jwt.MapClaims{"exp": float64(now.Add(-time.Minute).Unix())}, skew: time.Minute},
+		{d: "should fail because iat is in the future", claims: jwt.MapClaims{"iat": float64(now.Add(time.Second).Unix())}, errors: jwt.ValidationErrorIssuedAt},
+		{d: "should pass because iat is within the skew", claims: jwt.MapClaims{"iat": float64(now.Add(time.Minute).Unix())}, skew: time.Minute},
+		{d: "should fail because nbf is in the future", claims: jwt.MapClaims{"nbf": float64(now.Add(time.Minute + time.Second).Unix())}, skew: time.Minute, errors: jwt.ValidationErrorNotValidYet},
+		{d: "should pass because nbf is within the skew", claims: jwt.MapClaims{"nbf": float64(now.Add(time.Minute).Unix())}, skew: time.Minute},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			err := ValidateTimeClaims(tc.claims, now, tc.skew)
+			if tc.errors == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			e, ok := err.(*jwt.ValidationError)
+			require.True(t, ok)
+			assert.Equal(t, tc.errors, e.Errors)
+		})
+	}
+}
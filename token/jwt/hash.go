@@ -0,0 +1,46 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"crypto"
+
+	"github.com/ory/fosite/internal/jwa"
+)
+
+// TokenHash computes the value of the at_hash, c_hash and s_hash claims of an ID Token. It hashes the ASCII
+// representation of the value and returns the base64url encoding of the left-most half of the hash.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
+func TokenHash(value string, hash crypto.Hash) (string, error) {
+	return jwa.TokenHash(value, hash)
+}
+
+// TokenHashForAlgorithm works like TokenHash but uses the hash algorithm of the given JWS alg header value of the
+// ID Token, for example SHA-256 for RS256 and SHA-384 for ES384.
+func TokenHashForAlgorithm(value string, alg string) (string, error) {
+	hash, err := signingMethodHash(alg)
+	if err != nil {
+		return "", err
+	}
+	return TokenHash(value, hash)
+}
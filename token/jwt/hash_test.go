@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"crypto"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenHash(t *testing.T) {
+	// The access token, the code and their SHA-256 hashes are taken from
+	// https://openid.net/specs/openid-connect-core-1_0.html#code-id_token-tokenExample. The specification has no
+	// SHA-384 examples, so those values were computed with:
+	//
+	//   printf %s "$value" | openssl dgst -sha384 -binary | head -c 24 | base64 | tr '+/' '-_' | tr -d '='
+	const accessToken = "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+	const code = "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk"
+
+	for k, tc := range []struct {
+		value  string
+		hash   crypto.Hash
+		alg    string
+		expect string
+	}{
+		{value: accessToken, hash: crypto.SHA256, alg: "RS256", expect: "77QmUPtjPfzWtF2AnpK9RQ"},
+		{value: code, hash: crypto.SHA256, alg: "ES256", expect: "LDktKdoQak3Pk0cnXxCltA"},
+		{value: accessToken, hash: crypto.SHA384, alg: "RS384", expect: "jtAeDp945y1dDqU3nkIVGNZP1HjH_MFs"},
+		{value: code, hash: crypto.SHA384, alg: "ES384", expect: "Mq-knyaEMtWGfnBi2POEZb1kiLx10_DF"},
+	} {
+		t.Run(fmt.Sprintf("case=%d/alg=%s", k, tc.alg), func(t *testing.T) {
+			actual, err := TokenHash(tc.value, tc.hash)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, actual)
+
+			actual, err = TokenHashForAlgorithm(tc.value, tc.alg)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, actual)
+		})
+	}
+
+	_, err := TokenHashForAlgorithm(accessToken, "none")
+	assert.Error(t, err)
+
+	_, err = TokenHash(accessToken, crypto.Hash(0))
+	assert.Error(t, err)
+}
@@ -34,12 +34,11 @@ func NewHeaders() *Headers {
 
 // ToMap will transform the headers to a map structure
 func (h *Headers) ToMap() map[string]interface{} {
-	var filter = map[string]bool{"alg": true, "typ": true}
-	var extra = map[string]interface{}{}
+	var extra = make(map[string]interface{}, len(h.Extra))
 
 	// filter known values from extra.
 	for k, v := range h.Extra {
-		if _, ok := filter[k]; !ok {
+		if k != "alg" && k != "typ" {
 			extra[k] = v
 		}
 	}
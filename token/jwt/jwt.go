@@ -29,8 +29,10 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/sha256"
-	"fmt"
+	"encoding/base64"
+	"encoding/json"
 	"strings"
+	"sync"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
@@ -47,31 +49,40 @@ type JWTStrategy interface {
 	GetSigningMethodLength() int
 }
 
+// SigningAlgorithmStrategy is implemented by JWT strategies which report the algorithms they sign tokens with.
+type SigningAlgorithmStrategy interface {
+	// GetSigningAlgorithm returns the algorithm tokens are signed with unless the "alg" header selects another one.
+	GetSigningAlgorithm() string
+
+	// ValidateSigningAlgorithm returns an error if tokens can not be signed with the algorithm.
+	ValidateSigningAlgorithm(alg string) error
+}
+
 // RS256JWTStrategy is responsible for generating and validating JWT challenges
 type RS256JWTStrategy struct {
 	PrivateKey *rsa.PrivateKey
 }
 
+// GetSigningAlgorithm returns RS256.
+func (j *RS256JWTStrategy) GetSigningAlgorithm() string {
+	return "RS256"
+}
+
+// ValidateSigningAlgorithm returns an error unless the algorithm is RS256.
+func (j *RS256JWTStrategy) ValidateSigningAlgorithm(alg string) error {
+	if alg != "RS256" {
+		return errors.Errorf("Signing algorithm %s is not supported, expected RS256", alg)
+	}
+	return nil
+}
+
 // Generate generates a new authorize code or returns an error. set secret
 func (j *RS256JWTStrategy) Generate(ctx context.Context, claims jwt.Claims, header Mapper) (string, string, error) {
 	if header == nil || claims == nil {
 		return "", "", errors.New("Either claims or header is nil.")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header = assign(token.Header, header.ToMap())
-
-	var sig, sstr string
-	var err error
-	if sstr, err = token.SigningString(); err != nil {
-		return "", "", errors.WithStack(err)
-	}
-
-	if sig, err = token.Method.Sign(sstr, j.PrivateKey); err != nil {
-		return "", "", errors.WithStack(err)
-	}
-
-	return fmt.Sprintf("%s.%s", sstr, sig), sig, nil
+	return signToken(jwt.SigningMethodRS256, headerKey{alg: "RS256"}, claims, header, j.PrivateKey)
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
@@ -132,26 +143,26 @@ type ES256JWTStrategy struct {
 	PrivateKey *ecdsa.PrivateKey
 }
 
+// GetSigningAlgorithm returns ES256.
+func (j *ES256JWTStrategy) GetSigningAlgorithm() string {
+	return "ES256"
+}
+
+// ValidateSigningAlgorithm returns an error unless the algorithm is ES256.
+func (j *ES256JWTStrategy) ValidateSigningAlgorithm(alg string) error {
+	if alg != "ES256" {
+		return errors.Errorf("Signing algorithm %s is not supported, expected ES256", alg)
+	}
+	return nil
+}
+
 // Generate generates a new authorize code or returns an error. set secret
 func (j *ES256JWTStrategy) Generate(ctx context.Context, claims jwt.Claims, header Mapper) (string, string, error) {
 	if header == nil || claims == nil {
 		return "", "", errors.New("Either claims or header is nil.")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-	token.Header = assign(token.Header, header.ToMap())
-
-	var sig, sstr string
-	var err error
-	if sstr, err = token.SigningString(); err != nil {
-		return "", "", errors.WithStack(err)
-	}
-
-	if sig, err = token.Method.Sign(sstr, j.PrivateKey); err != nil {
-		return "", "", errors.WithStack(err)
-	}
-
-	return fmt.Sprintf("%s.%s", sstr, sig), sig, nil
+	return signToken(jwt.SigningMethodES256, headerKey{alg: "ES256"}, claims, header, j.PrivateKey)
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
@@ -216,3 +227,65 @@ func assign(a, b map[string]interface{}) map[string]interface{} {
 	}
 	return a
 }
+
+// headerKey identifies the header of tokens which are signed without extra headers.
+type headerKey struct {
+	alg    string
+	kid    string
+	hasKid bool
+}
+
+// encodedHeaders caches the encoded header segment per headerKey, because the header of tokens without extra headers
+// never changes.
+var encodedHeaders sync.Map
+
+// signToken signs the claims and returns the token and its signature. The token is the same as the one returned by
+// jwt.Token.SignedString, but the header segment is only encoded once for tokens without extra headers and the token is
+// assembled in a single buffer.
+func signToken(method jwt.SigningMethod, base headerKey, claims jwt.Claims, header Mapper, key interface{}) (string, string, error) {
+	extra := header.ToMap()
+
+	var encodedHeader string
+	if cached, ok := encodedHeaders.Load(base); ok && len(extra) == 0 {
+		encodedHeader = cached.(string)
+	} else {
+		h := map[string]interface{}{"typ": "JWT", "alg": method.Alg()}
+		if typ, ok := extra["typ"]; ok {
+			// Headers never returns "typ", but other mappers may declare the media type of the token, for example
+			// "at+jwt" for access tokens.
+			h["typ"] = typ
+		}
+		if base.hasKid {
+			h["kid"] = base.kid
+		}
+
+		raw, err := json.Marshal(assign(h, extra))
+		if err != nil {
+			return "", "", errors.WithStack(err)
+		}
+		encodedHeader = base64.RawURLEncoding.EncodeToString(raw)
+
+		if len(extra) == 0 {
+			encodedHeaders.Store(base, encodedHeader)
+		}
+	}
+
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	signingString := make([]byte, len(encodedHeader)+1+base64.RawURLEncoding.EncodedLen(len(raw)))
+	offset := copy(signingString, encodedHeader)
+	signingString[offset] = '.'
+	base64.RawURLEncoding.Encode(signingString[offset+1:], raw)
+
+	signature, err := method.Sign(string(signingString), key)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	token := make([]byte, 0, len(signingString)+1+len(signature))
+	token = append(append(append(token, signingString...), '.'), signature...)
+	return string(token), signature, nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+
+	"github.com/pkg/errors"
+)
+
+// KeyPolicy defines which keys may be used for signing tokens. Use it to refuse weak keys before they are used.
+type KeyPolicy struct {
+	// MinRSAKeySize is the minimum size of RSA keys in bits.
+	MinRSAKeySize int
+
+	// AllowedCurves are the names of the elliptic curves ECDSA keys may use, for example "P-256".
+	AllowedCurves []string
+}
+
+// DefaultKeyPolicy requires RSA keys of at least 2048 bits and ECDSA keys on the P-256, P-384 or P-521 curves.
+var DefaultKeyPolicy = KeyPolicy{
+	MinRSAKeySize: 2048,
+	AllowedCurves: []string{"P-256", "P-384", "P-521"},
+}
+
+// Validate returns an error if the private or public key does not satisfy the policy or is of an unsupported type.
+func (p KeyPolicy) Validate(key interface{}) error {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return p.Validate(&k.PublicKey)
+	case *ecdsa.PrivateKey:
+		return p.Validate(&k.PublicKey)
+	case *rsa.PublicKey:
+		if size := k.N.BitLen(); size < p.MinRSAKeySize {
+			return errors.Errorf("RSA key of %d bits is smaller than the required minimum of %d bits", size, p.MinRSAKeySize)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		name := k.Curve.Params().Name
+		for _, allowed := range p.AllowedCurves {
+			if name == allowed {
+				return nil
+			}
+		}
+		return errors.Errorf("Elliptic curve %s is not allowed, expected one of %v", name, p.AllowedCurves)
+	}
+
+	return errors.Errorf("Key of type %T is not supported", key)
+}
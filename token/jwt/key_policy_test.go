@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPolicy(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		d         string
+		policy    KeyPolicy
+		key       interface{}
+		expectErr bool
+	}{
+		{d: "should reject a 1024 bit RSA key", policy: DefaultKeyPolicy, key: rsa1024, expectErr: true},
+		{d: "should accept a 2048 bit RSA key", policy: DefaultKeyPolicy, key: rsa2048},
+		{d: "should accept a 2048 bit RSA public key", policy: DefaultKeyPolicy, key: &rsa2048.PublicKey},
+		{d: "should reject a 2048 bit RSA key if 3072 bits are required", policy: KeyPolicy{MinRSAKeySize: 3072}, key: rsa2048, expectErr: true},
+		{d: "should reject a P-224 key", policy: DefaultKeyPolicy, key: p224, expectErr: true},
+		{d: "should accept a P-256 key", policy: DefaultKeyPolicy, key: p256},
+		{d: "should reject a P-256 key if only P-384 is allowed", policy: KeyPolicy{AllowedCurves: []string{"P-384"}}, key: p256, expectErr: true},
+		{d: "should reject unsupported key types", policy: DefaultKeyPolicy, key: []byte("secret"), expectErr: true},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			err := c.policy.Validate(c.key)
+			if c.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
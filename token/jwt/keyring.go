@@ -0,0 +1,322 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/internal/jwa"
+)
+
+// Key is a private key held by a KeyRing.
+type Key struct {
+	// KeyID is set as the "kid" header of every token signed with this key.
+	KeyID string
+
+	// Algorithm is the JWS algorithm this key is used with, for example "RS256".
+	Algorithm string
+
+	// Active designates this key as the one used for signing tokens of its algorithm. At most one key per
+	// algorithm may be active.
+	Active bool
+
+	// PrivateKey is the private key, either an *rsa.PrivateKey or an *ecdsa.PrivateKey.
+	PrivateKey crypto.Signer
+}
+
+// SigningAlgorithms are the JWS algorithms supported by KeyRingJWTStrategy.
+var SigningAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// ValidateSigningKey returns an error if the algorithm is not one of SigningAlgorithms or can not be used with the
+// key, for example because ES384 requires an ECDSA key on curve P-384.
+func ValidateSigningKey(alg string, key crypto.Signer) error {
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		if _, ok := key.(*rsa.PrivateKey); !ok {
+			return errors.Errorf("Signing algorithm %s requires an RSA key but got %T", alg, key)
+		}
+		return nil
+	case "ES256", "ES384", "ES512":
+		curve, _ := jwa.ECDSACurve(alg)
+		if k, ok := key.(*ecdsa.PrivateKey); !ok {
+			return errors.Errorf("Signing algorithm %s requires an ECDSA key but got %T", alg, key)
+		} else if k.Curve != curve {
+			return errors.Errorf("Signing algorithm %s requires elliptic curve %s but the key uses %s", alg, curve.Params().Name, k.Curve.Params().Name)
+		}
+		return nil
+	}
+	return errors.Errorf("Signing algorithm %s is not supported, expected one of %s", alg, strings.Join(SigningAlgorithms, ", "))
+}
+
+// SigningAlgorithmForKey returns the algorithm tokens are signed with by default when using the key: RS256 for RSA keys
+// and ES256, ES384 or ES512 for ECDSA keys on curve P-256, P-384 or P-521.
+func SigningAlgorithmForKey(key crypto.Signer) (string, error) {
+	if alg := jwa.SigningAlgorithm(key); alg != "" {
+		return alg, nil
+	} else if k, ok := key.(*ecdsa.PrivateKey); ok {
+		return "", errors.Errorf("Elliptic curve %s is not supported, expected one of P-256, P-384 and P-521", k.Curve.Params().Name)
+	}
+	return "", errors.Errorf("Signing key of type %T is not supported", key)
+}
+
+// KeyRing is an ordered set of keys. All keys are used to verify tokens, but only one key per algorithm
+// is used to sign them.
+//
+// Keys are expected to be ordered from oldest to newest. The signing key for an algorithm is the key that is marked
+// as active or, if none is, the newest (last) key of that algorithm. The selection never depends on map iteration
+// order, which makes the choice of the signing key stable during key rotation.
+type KeyRing struct {
+	Keys []Key
+}
+
+// ActiveKey returns the key used for signing tokens with the given algorithm.
+func (r *KeyRing) ActiveKey(alg string) (*Key, error) {
+	var active, newest *Key
+	for i := range r.Keys {
+		k := &r.Keys[i]
+		if k.Algorithm != alg {
+			continue
+		}
+
+		newest = k
+		if !k.Active {
+			continue
+		}
+
+		if active != nil {
+			return nil, errors.Errorf("Key ring contains more than one active key for algorithm %s: %s and %s", alg, active.KeyID, k.KeyID)
+		}
+		active = k
+	}
+
+	if active != nil {
+		return active, nil
+	} else if newest != nil {
+		return newest, nil
+	}
+
+	return nil, errors.Errorf("Key ring does not contain a key for algorithm %s", alg)
+}
+
+// Key returns the key with the given key ID.
+func (r *KeyRing) Key(kid string) (*Key, bool) {
+	for i := range r.Keys {
+		if r.Keys[i].KeyID == kid {
+			return &r.Keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// KeysForAlgorithm returns all keys of the given algorithm in the order of the key ring.
+func (r *KeyRing) KeysForAlgorithm(alg string) []*Key {
+	var keys []*Key
+	for i := range r.Keys {
+		if r.Keys[i].Algorithm == alg {
+			keys = append(keys, &r.Keys[i])
+		}
+	}
+	return keys
+}
+
+// PublicJWKS returns the public keys of the key ring as a JSON Web Key Set, for example to be served at
+// "/.well-known/jwks.json". It contains every key in the ring: the active keys, keys which will become active next and
+// therefore have to be known by relying parties in advance, and previous keys which still verify outstanding tokens.
+func (r *KeyRing) PublicJWKS() *jose.JSONWebKeySet {
+	set := &jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(r.Keys))}
+	for _, k := range r.Keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       k.PrivateKey.Public(),
+			KeyID:     k.KeyID,
+			Algorithm: k.Algorithm,
+			Use:       "sig",
+		})
+	}
+	return set
+}
+
+// KeyRingJWTStrategy is responsible for generating and validating JWT challenges using a KeyRing. Tokens are signed with the
+// active key of the algorithm and carry its key ID in the "kid" header. Tokens are validated against the key referenced by
+// their "kid" header or, if they have none, against every key of their algorithm.
+//
+// The algorithm can be chosen per token by setting the "alg" header, for example to the algorithm a client registered
+// using "id_token_signed_response_alg". The token is then signed with the active key of that algorithm.
+type KeyRingJWTStrategy struct {
+	KeyRing *KeyRing
+
+	// Algorithm is the algorithm used for signing tokens, one of SigningAlgorithms. Defaults to RS256.
+	Algorithm string
+}
+
+func (j *KeyRingJWTStrategy) algorithm() string {
+	if j.Algorithm == "" {
+		return "RS256"
+	}
+	return j.Algorithm
+}
+
+// GetSigningAlgorithm returns Algorithm if set. Defaults to RS256.
+func (j *KeyRingJWTStrategy) GetSigningAlgorithm() string {
+	return j.algorithm()
+}
+
+// ValidateSigningAlgorithm returns an error if the key ring does not contain a key which can sign tokens with the
+// algorithm.
+func (j *KeyRingJWTStrategy) ValidateSigningAlgorithm(alg string) error {
+	key, err := j.KeyRing.ActiveKey(alg)
+	if err != nil {
+		return err
+	}
+	return ValidateSigningKey(alg, key.PrivateKey)
+}
+
+// Generate generates a new authorize code or returns an error. set secret
+func (j *KeyRingJWTStrategy) Generate(ctx context.Context, claims jwt.Claims, header Mapper) (string, string, error) {
+	if header == nil || claims == nil {
+		return "", "", errors.New("Either claims or header is nil.")
+	}
+
+	// Headers.ToMap filters the "alg" header, which is why it is read from the extra headers.
+	alg := j.algorithm()
+	if h, ok := header.(*Headers); ok {
+		if requested, ok := h.Get("alg").(string); ok && requested != "" {
+			alg = requested
+		}
+	}
+
+	key, err := j.KeyRing.ActiveKey(alg)
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	} else if err := ValidateSigningKey(alg, key.PrivateKey); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+
+	return signToken(jwt.GetSigningMethod(alg), headerKey{alg: alg, kid: key.KeyID, hasKid: true}, claims, header, key.PrivateKey)
+}
+
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (j *KeyRingJWTStrategy) Validate(ctx context.Context, token string) (string, error) {
+	if _, err := j.Decode(ctx, token); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return j.GetSignature(ctx, token)
+}
+
+// Decode will decode a JWT token
+func (j *KeyRingJWTStrategy) Decode(ctx context.Context, token string) (*jwt.Token, error) {
+	var candidates []*Key
+	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		alg := t.Method.Alg()
+		if kid, ok := t.Header["kid"].(string); ok {
+			key, found := j.KeyRing.Key(kid)
+			if !found {
+				return nil, errors.Errorf("Unknown key id: %s", kid)
+			} else if key.Algorithm != alg {
+				return nil, errors.Errorf("Unexpected signing method: %v", t.Header["alg"])
+			}
+			return key.PrivateKey.Public(), nil
+		}
+
+		candidates = j.KeyRing.KeysForAlgorithm(alg)
+		if len(candidates) == 0 {
+			return nil, errors.Errorf("Unexpected signing method: %v", t.Header["alg"])
+		}
+		return candidates[0].PrivateKey.Public(), nil
+	})
+
+	// Tokens without a key ID are verified against every key of their algorithm.
+	for i := 1; i < len(candidates) && isSignatureInvalid(err); i++ {
+		key := candidates[i]
+		parsedToken, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+			return key.PrivateKey.Public(), nil
+		})
+	}
+
+	if err != nil {
+		return parsedToken, errors.WithStack(err)
+	} else if !parsedToken.Valid {
+		return parsedToken, errors.WithStack(fosite.ErrInactiveToken)
+	}
+
+	return parsedToken, err
+}
+
+// GetSignature will return the signature of a token
+func (j *KeyRingJWTStrategy) GetSignature(ctx context.Context, token string) (string, error) {
+	split := strings.Split(token, ".")
+	if len(split) != 3 {
+		return "", errors.New("Header, body and signature must all be set")
+	}
+	return split[2], nil
+}
+
+// Hash will return a given hash based on the byte input or an error upon fail
+func (j *KeyRingJWTStrategy) Hash(ctx context.Context, in []byte) ([]byte, error) {
+	hash, err := signingMethodHash(j.algorithm())
+	if err != nil {
+		return []byte{}, err
+	}
+
+	h := hash.New()
+	if _, err := h.Write(in); err != nil {
+		return []byte{}, errors.WithStack(err)
+	}
+	return h.Sum([]byte{}), nil
+}
+
+// GetSigningMethodLength will return the length of the signing method
+func (j *KeyRingJWTStrategy) GetSigningMethodLength() int {
+	hash, err := signingMethodHash(j.algorithm())
+	if err != nil {
+		return 0
+	}
+	return hash.Size()
+}
+
+func isSignatureInvalid(err error) bool {
+	var e *jwt.ValidationError
+	return errors.As(err, &e) && e.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
+
+func signingMethodHash(alg string) (crypto.Hash, error) {
+	switch m := jwt.GetSigningMethod(alg).(type) {
+	case *jwt.SigningMethodRSA:
+		return m.Hash, nil
+	case *jwt.SigningMethodRSAPSS:
+		return m.Hash, nil
+	case *jwt.SigningMethodECDSA:
+		return m.Hash, nil
+	case *jwt.SigningMethodHMAC:
+		return m.Hash, nil
+	}
+	return 0, errors.Errorf("Signing algorithm %s is not supported", alg)
+}
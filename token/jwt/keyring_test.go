@@ -0,0 +1,289 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite/internal"
+)
+
+func TestKeyRingActiveKey(t *testing.T) {
+	for k, tc := range []struct {
+		d         string
+		keys      []Key
+		expectKID string
+		expectErr bool
+	}{
+		{
+			d:         "designated active key wins over newer keys",
+			keys:      []Key{{KeyID: "old", Algorithm: "RS256"}, {KeyID: "active", Algorithm: "RS256", Active: true}, {KeyID: "new", Algorithm: "RS256"}},
+			expectKID: "active",
+		},
+		{
+			d:         "newest key is used if none is active",
+			keys:      []Key{{KeyID: "old", Algorithm: "RS256"}, {KeyID: "new", Algorithm: "RS256"}},
+			expectKID: "new",
+		},
+		{
+			d:         "keys of other algorithms are ignored",
+			keys:      []Key{{KeyID: "rsa", Algorithm: "RS256"}, {KeyID: "ec", Algorithm: "ES256", Active: true}},
+			expectKID: "rsa",
+		},
+		{
+			d:         "more than one active key is ambiguous",
+			keys:      []Key{{KeyID: "a", Algorithm: "RS256", Active: true}, {KeyID: "b", Algorithm: "RS256", Active: true}},
+			expectErr: true,
+		},
+		{
+			d:         "no key for the algorithm",
+			keys:      []Key{{KeyID: "ec", Algorithm: "ES256"}},
+			expectErr: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			key, err := (&KeyRing{Keys: tc.keys}).ActiveKey("RS256")
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectKID, key.KeyID)
+		})
+	}
+}
+
+func TestKeyRingJWTStrategy(t *testing.T) {
+	ring := &KeyRing{Keys: []Key{
+		{KeyID: "current", Algorithm: "RS256", Active: true, PrivateKey: internal.MustRSAKey()},
+		{KeyID: "next", Algorithm: "RS256", PrivateKey: internal.MustRSAKey()},
+	}}
+	strategy := &KeyRingJWTStrategy{KeyRing: ring}
+	claims := &JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+	t.Run("case=the active key is always used for signing", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			token, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), header)
+			require.NoError(t, err)
+
+			parsed, err := strategy.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			assert.Equal(t, "current", parsed.Header["kid"])
+		}
+	})
+
+	t.Run("case=tokens signed by any key in the ring validate", func(t *testing.T) {
+		for _, kid := range []string{"current", "next"} {
+			key, ok := ring.Key(kid)
+			require.True(t, ok)
+
+			signer := &KeyRingJWTStrategy{KeyRing: &KeyRing{Keys: []Key{*key}}}
+			token, _, err := signer.Generate(context.TODO(), claims.ToMapClaims(), header)
+			require.NoError(t, err)
+
+			_, err = strategy.Validate(context.TODO(), token)
+			require.NoError(t, err, kid)
+		}
+	})
+
+	t.Run("case=tokens without kid validate against every key of the algorithm", func(t *testing.T) {
+		key, _ := ring.Key("next")
+		token, _, err := (&RS256JWTStrategy{PrivateKey: key.PrivateKey.(*rsa.PrivateKey)}).Generate(context.TODO(), claims.ToMapClaims(), header)
+		require.NoError(t, err)
+
+		_, err = strategy.Validate(context.TODO(), token)
+		require.NoError(t, err)
+	})
+
+	t.Run("case=tokens signed by unknown keys are rejected", func(t *testing.T) {
+		token, _, err := (&RS256JWTStrategy{PrivateKey: internal.MustRSAKey()}).Generate(context.TODO(), claims.ToMapClaims(), header)
+		require.NoError(t, err)
+
+		_, err = strategy.Validate(context.TODO(), token)
+		require.Error(t, err)
+	})
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	previous := Key{KeyID: "previous", Algorithm: "RS256", Active: true, PrivateKey: internal.MustRSAKey()}
+	next := Key{KeyID: "next", Algorithm: "RS256", PrivateKey: internal.MustRSAKey()}
+
+	ring := &KeyRing{Keys: []Key{previous, next}}
+	strategy := &KeyRingJWTStrategy{KeyRing: ring}
+	claims := &JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+	old, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), header)
+	require.NoError(t, err)
+
+	// Activates the next key while keeping the previous key for verification.
+	ring.Keys[0].Active, ring.Keys[1].Active = false, true
+
+	rotated, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), header)
+	require.NoError(t, err)
+
+	parsed, err := strategy.Decode(context.TODO(), rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "next", parsed.Header["kid"])
+
+	parsed, err = strategy.Decode(context.TODO(), old)
+	require.NoError(t, err)
+	assert.Equal(t, "previous", parsed.Header["kid"])
+
+	// Tokens signed by a key which was removed from the ring are rejected.
+	ring.Keys = ring.Keys[1:]
+	_, err = strategy.Validate(context.TODO(), old)
+	require.Error(t, err)
+	_, err = strategy.Validate(context.TODO(), rotated)
+	require.NoError(t, err)
+}
+
+func TestKeyRingPublicJWKS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	ring := &KeyRing{Keys: []Key{
+		{KeyID: "current", Algorithm: "RS256", Active: true, PrivateKey: internal.MustRSAKey()},
+		{KeyID: "next", Algorithm: "ES256", PrivateKey: ecKey},
+	}}
+
+	set := ring.PublicJWKS()
+	require.Len(t, set.Keys, 2)
+	for i, key := range set.Keys {
+		assert.Equal(t, ring.Keys[i].KeyID, key.KeyID)
+		assert.Equal(t, ring.Keys[i].Algorithm, key.Algorithm)
+		assert.Equal(t, "sig", key.Use)
+		assert.True(t, key.IsPublic())
+		assert.Equal(t, ring.Keys[i].PrivateKey.Public(), key.Key)
+	}
+
+	// Tokens can be verified with the published key matching their "kid" header.
+	strategy := &KeyRingJWTStrategy{KeyRing: ring}
+	token, _, err := strategy.Generate(context.TODO(), (&JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}).ToMapClaims(), header)
+	require.NoError(t, err)
+
+	parsed, err := strategy.Decode(context.TODO(), token)
+	require.NoError(t, err)
+	keys := set.Key(parsed.Header["kid"].(string))
+	require.Len(t, keys, 1)
+	assert.Equal(t, ring.Keys[0].PrivateKey.Public(), keys[0].Key)
+}
+
+func TestKeyRingJWTStrategySigningAlgorithms(t *testing.T) {
+	ecKey := func(curve elliptic.Curve) *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+		return key
+	}
+
+	rsaKey := internal.MustRSAKey()
+	ring := &KeyRing{Keys: []Key{
+		{KeyID: "rs256", Algorithm: "RS256", PrivateKey: rsaKey},
+		{KeyID: "ps256", Algorithm: "PS256", PrivateKey: rsaKey},
+		{KeyID: "es256", Algorithm: "ES256", PrivateKey: ecKey(elliptic.P256())},
+		{KeyID: "es384", Algorithm: "ES384", PrivateKey: ecKey(elliptic.P384())},
+		{KeyID: "es512", Algorithm: "ES512", PrivateKey: ecKey(elliptic.P521())},
+	}}
+	claims := &JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+	for _, alg := range []string{"RS256", "PS256", "ES256", "ES384", "ES512"} {
+		t.Run(fmt.Sprintf("case=round trip/alg=%s", alg), func(t *testing.T) {
+			strategy := &KeyRingJWTStrategy{KeyRing: ring, Algorithm: alg}
+			token, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), &Headers{})
+			require.NoError(t, err)
+
+			parsed, err := strategy.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			assert.Equal(t, alg, parsed.Header["alg"])
+			assert.Equal(t, strings.ToLower(alg), parsed.Header["kid"])
+		})
+
+		t.Run(fmt.Sprintf("case=alg header selects the key/alg=%s", alg), func(t *testing.T) {
+			strategy := &KeyRingJWTStrategy{KeyRing: ring}
+			token, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), &Headers{Extra: map[string]interface{}{"alg": alg}})
+			require.NoError(t, err)
+
+			parsed, err := strategy.Decode(context.TODO(), token)
+			require.NoError(t, err)
+			assert.Equal(t, alg, parsed.Header["alg"])
+		})
+	}
+
+	t.Run("case=unsupported combinations are rejected", func(t *testing.T) {
+		for k, tc := range []struct {
+			alg    string
+			key    Key
+			expect string
+		}{
+			{alg: "ES384", key: Key{KeyID: "a", Algorithm: "ES384", PrivateKey: ecKey(elliptic.P256())}, expect: "Signing algorithm ES384 requires elliptic curve P-384 but the key uses P-256"},
+			{alg: "RS256", key: Key{KeyID: "a", Algorithm: "RS256", PrivateKey: ecKey(elliptic.P256())}, expect: "Signing algorithm RS256 requires an RSA key but got *ecdsa.PrivateKey"},
+			{alg: "ES256", key: Key{KeyID: "a", Algorithm: "ES256", PrivateKey: rsaKey}, expect: "Signing algorithm ES256 requires an ECDSA key but got *rsa.PrivateKey"},
+			{alg: "HS256", key: Key{KeyID: "a", Algorithm: "HS256", PrivateKey: rsaKey}, expect: "Signing algorithm HS256 is not supported, expected one of RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512"},
+			{alg: "ES512", key: Key{KeyID: "a", Algorithm: "ES256", PrivateKey: ecKey(elliptic.P256())}, expect: "Key ring does not contain a key for algorithm ES512"},
+		} {
+			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+				strategy := &KeyRingJWTStrategy{KeyRing: &KeyRing{Keys: []Key{tc.key}}, Algorithm: tc.alg}
+				_, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), &Headers{})
+				require.EqualError(t, err, tc.expect)
+			})
+		}
+	})
+}
+
+func TestSigningAlgorithmForKey(t *testing.T) {
+	alg, err := SigningAlgorithmForKey(internal.MustRSAKey())
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", alg)
+
+	for k, c := range []struct {
+		curve    elliptic.Curve
+		expected string
+	}{
+		{curve: elliptic.P256(), expected: "ES256"},
+		{curve: elliptic.P384(), expected: "ES384"},
+		{curve: elliptic.P521(), expected: "ES512"},
+		{curve: elliptic.P224()},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(c.curve, rand.Reader)
+			require.NoError(t, err)
+
+			alg, err := SigningAlgorithmForKey(key)
+			if c.expected == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, alg)
+		})
+	}
+}
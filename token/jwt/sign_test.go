@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite/internal"
+)
+
+// referenceToken signs the claims the way jwt-go does, which the strategies must produce byte for byte.
+func referenceToken(t *testing.T, method jwt.SigningMethod, base map[string]interface{}, claims jwt.Claims, header Mapper, key interface{}) (string, string) {
+	token := jwt.NewWithClaims(method, claims)
+	for k, v := range base {
+		token.Header[k] = v
+	}
+	token.Header = assign(token.Header, header.ToMap())
+
+	signingString, err := token.SigningString()
+	require.NoError(t, err)
+	signature, err := method.Sign(signingString, key)
+	require.NoError(t, err)
+	return signingString, signature
+}
+
+func TestGenerateMatchesReference(t *testing.T) {
+	rsaKey := internal.MustRSAKey()
+	ecKey := func(curve elliptic.Curve) *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+		return key
+	}
+	ecKeys := map[string]*ecdsa.PrivateKey{"ES256": ecKey(elliptic.P256()), "ES384": ecKey(elliptic.P384()), "ES512": ecKey(elliptic.P521())}
+
+	claims := jwt.MapClaims{
+		"sub": "peter",
+		"iat": float64(time.Unix(1600000000, 0).Unix()),
+		"exp": float64(time.Unix(1600003600, 0).Unix()),
+		"aud": []string{"foo", "bar"},
+		"ext": map[string]interface{}{"baz": true},
+	}
+
+	type strategyCase struct {
+		d        string
+		strategy JWTStrategy
+		method   jwt.SigningMethod
+		base     map[string]interface{}
+		key      crypto.Signer
+	}
+	cases := []strategyCase{
+		{d: "RS256", strategy: &RS256JWTStrategy{PrivateKey: rsaKey}, method: jwt.SigningMethodRS256, key: rsaKey},
+		{d: "ES256", strategy: &ES256JWTStrategy{PrivateKey: ecKeys["ES256"]}, method: jwt.SigningMethodES256, key: ecKeys["ES256"]},
+	}
+	for _, alg := range SigningAlgorithms {
+		var key crypto.Signer = rsaKey
+		if strings.HasPrefix(alg, "ES") {
+			key = ecKeys[alg]
+		}
+		cases = append(cases, strategyCase{
+			d:        "key ring " + alg,
+			strategy: &KeyRingJWTStrategy{KeyRing: &KeyRing{Keys: []Key{{KeyID: "key-" + alg, Algorithm: alg, PrivateKey: key}}}, Algorithm: alg},
+			method:   jwt.GetSigningMethod(alg),
+			base:     map[string]interface{}{"kid": "key-" + alg},
+			key:      key,
+		})
+	}
+
+	for k, c := range cases {
+		for _, h := range []*Headers{{}, {Extra: map[string]interface{}{"foo": "bar", "kid": "other", "typ": "at+jwt"}}} {
+			t.Run(fmt.Sprintf("case=%d/strategy=%s/headers=%d", k, c.d, len(h.Extra)), func(t *testing.T) {
+				// The second run uses the cached header.
+				for i := 0; i < 2; i++ {
+					token, signature, err := c.strategy.Generate(context.TODO(), claims, h)
+					require.NoError(t, err)
+
+					expectedSigningString, expectedSignature := referenceToken(t, c.method, c.base, claims, h, c.key)
+					require.True(t, strings.HasSuffix(token, "."+signature))
+					signingString := strings.TrimSuffix(token, "."+signature)
+					assert.Equal(t, expectedSigningString, signingString)
+					require.NoError(t, c.method.Verify(signingString, signature, c.key.Public()))
+
+					// PKCS #1 v1.5 signatures are deterministic, PSS and ECDSA signatures are randomized.
+					if strings.HasPrefix(c.method.Alg(), "RS") {
+						assert.Equal(t, expectedSigningString+"."+expectedSignature, token)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkGenerateRS256(b *testing.B) {
+	benchmarkGenerate(b, &RS256JWTStrategy{PrivateKey: internal.MustRSAKey()})
+}
+
+func BenchmarkGenerateES256(b *testing.B) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(b, err)
+	benchmarkGenerate(b, &ES256JWTStrategy{PrivateKey: key})
+}
+
+func benchmarkGenerate(b *testing.B, strategy JWTStrategy) {
+	claims := &IDTokenClaims{
+		JTI:         "some-id",
+		Subject:     "peter",
+		Issuer:      "https://www.ory.sh",
+		Audience:    []string{"some-client"},
+		Nonce:       "some-nonce",
+		IssuedAt:    time.Now().UTC(),
+		ExpiresAt:   time.Now().UTC().Add(time.Hour),
+		RequestedAt: time.Now().UTC(),
+		AuthTime:    time.Now().UTC(),
+	}
+	headers := NewHeaders()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, _, err := strategy.Generate(context.TODO(), claims.ToMapClaims(), headers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
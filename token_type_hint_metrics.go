@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import "context"
+
+// TokenTypeHintOutcome describes whether the token_type_hint of an introspection or revocation request pointed to
+// the right token store.
+type TokenTypeHintOutcome string
+
+const (
+	// TokenTypeHintHit means the token was found with the hinted token type on the first lookup.
+	TokenTypeHintHit TokenTypeHintOutcome = "hit"
+
+	// TokenTypeHintFallback means the token was not found with the hinted token type but with another one.
+	TokenTypeHintFallback TokenTypeHintOutcome = "fallback"
+)
+
+// TokenTypeHintMetricsHook is called whenever a token was found during introspection or revocation of a request
+// carrying a token_type_hint, which allows measuring how accurate the hints sent by clients are.
+type TokenTypeHintMetricsHook func(ctx context.Context, endpoint Endpoint, hint TokenUse, outcome TokenTypeHintOutcome)
+
+// Report calls the hook with the outcome of the token_type_hint if the hint names a known token type. It does
+// nothing if the hook is nil.
+func (h TokenTypeHintMetricsHook) Report(ctx context.Context, endpoint Endpoint, hint, found TokenUse) {
+	if h == nil || (hint != AccessToken && hint != RefreshToken) {
+		return
+	}
+
+	outcome := TokenTypeHintHit
+	if hint != found {
+		outcome = TokenTypeHintFallback
+	}
+	h(ctx, endpoint, hint, outcome)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateToken validates the bearer token of a request to a resource server. The token is taken from the request
+// using BearerTokenFromRequest and introspected using the TokenIntrospectionHandlers, which either look up the token
+// in the storage or verify it as JWT. The token must be of the given type, must have been granted all required scopes
+// and, if ResourceServerAudience is set, must have been granted that audience. Tokens bound to a DPoP key (RFC 9449)
+// or a client certificate (RFC 8705) are only accepted with a matching DPoP proof or certificate.
+//
+// If the token is valid, the returned access requester holds the populated session. Otherwise the error is one of
+// the errors defined in https://tools.ietf.org/html/rfc6750#section-3.1, or ErrMissingBearerToken if the request
+// does not contain a token, and can be written using WriteBearerError.
+func (f *Fosite) ValidateToken(ctx context.Context, r *http.Request, tokenType TokenUse, session Session, requiredScopes ...string) (AccessRequester, error) {
+	token, dpopScheme := f.BearerTokenFromRequest(r), false
+	if token == "" {
+		// DPoP-bound access tokens use the DPoP authorization scheme, see RFC 9449 Section 7.1.
+		token = f.dpopTokenFromRequest(r)
+		dpopScheme = token != ""
+	}
+	if token == "" {
+		return nil, errors.WithStack(ErrMissingBearerToken)
+	}
+
+	tokenUse, ar, err := f.IntrospectToken(ctx, token, tokenType, session)
+	if err != nil {
+		return nil, errors.WithStack(toBearerTokenError(err))
+	} else if tokenUse != tokenType {
+		return nil, errors.WithStack(ErrInvalidToken.WithHintf("The token is a '%s' but a '%s' was expected.", tokenUse, tokenType))
+	}
+
+	dpopThumbprint, err := f.ValidateDPoPProof(ctx, r, token)
+	if err != nil {
+		return nil, errors.WithStack(toBearerTokenError(err))
+	} else if dpopScheme && dpopThumbprint == "" {
+		return nil, errors.WithStack(ErrInvalidToken.WithHint("The DPoP authorization scheme requires a DPoP proof."))
+	} else if session, ok := ar.GetSession().(DPoPBoundSession); ok && session.GetDPoPKeyThumbprint() != "" && !dpopScheme {
+		return nil, errors.WithStack(ErrInvalidToken.WithHint("The token is bound to a DPoP key and must be sent using the DPoP authorization scheme."))
+	} else if err := ValidateDPoPBinding(ar, dpopThumbprint); err != nil {
+		return nil, errors.WithStack(toBearerTokenError(err))
+	}
+
+	cert, _, err := f.clientCertificateFromRequest(r)
+	if err != nil {
+		return nil, errors.WithStack(toBearerTokenError(err))
+	} else if err := ValidateCertificateBinding(ar, cert); err != nil {
+		return nil, errors.WithStack(toBearerTokenError(err))
+	}
+
+	strategy := f.ScopeStrategy
+	if strategy == nil {
+		strategy = ExactScopeStrategy
+	}
+	for _, scope := range requiredScopes {
+		if !strategy(ar.GetGrantedScopes(), scope) {
+			return nil, errors.WithStack(ErrInsufficientScope.WithHintf("The token was not granted the required scope '%s'.", scope))
+		}
+	}
+
+	if f.ResourceServerAudience != "" && !ar.GetGrantedAudience().Has(f.ResourceServerAudience) {
+		return nil, errors.WithStack(ErrInvalidToken.WithHintf("The token was not issued for audience '%s'.", f.ResourceServerAudience))
+	}
+
+	return ar, nil
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+)
+
+func TestValidateTokenBindings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	thumbprint, err := (&jose.JSONWebKey{Key: &key.PublicKey}).Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+
+	cert := mustGenerateCertificate(t, "client.fosite")
+	otherCert := mustGenerateCertificate(t, "other.fosite")
+
+	proof := func(key *ecdsa.PrivateKey) string {
+		ath := sha256.Sum256([]byte("some-token"))
+		return newDPoPProof(t, key, "dpop+jwt", map[string]interface{}{
+			"jti": "some-jti",
+			"htm": "GET",
+			"htu": "https://rs.example.org/resource",
+			"iat": time.Now().Unix(),
+			"ath": base64.RawURLEncoding.EncodeToString(ath[:]),
+		})
+	}
+
+	for k, c := range []struct {
+		d              string
+		authorization  string
+		dpopProof      string
+		cert           *x509.Certificate
+		dpopThumbprint string
+		certThumbprint string
+		expectErr      error
+	}{
+		{
+			d:         "should fail because the request does not contain a token",
+			expectErr: ErrMissingBearerToken,
+		},
+		{
+			d:             "should pass because the token is not bound",
+			authorization: "Bearer some-token",
+		},
+		{
+			d:              "should pass because the DPoP proof matches the bound key",
+			authorization:  "DPoP some-token",
+			dpopProof:      proof(key),
+			dpopThumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+		},
+		{
+			d:              "should fail because the DPoP-bound token was sent without proof",
+			authorization:  "DPoP some-token",
+			dpopThumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+			expectErr:      ErrInvalidToken,
+		},
+		{
+			d:              "should fail because the DPoP proof was signed by a different key",
+			authorization:  "DPoP some-token",
+			dpopProof:      proof(otherKey),
+			dpopThumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+			expectErr:      ErrInvalidToken,
+		},
+		{
+			d:              "should fail because the DPoP-bound token was sent using the bearer scheme",
+			authorization:  "Bearer some-token",
+			dpopProof:      proof(key),
+			dpopThumbprint: base64.RawURLEncoding.EncodeToString(thumbprint),
+			expectErr:      ErrInvalidToken,
+		},
+		{
+			d:             "should fail because the DPoP scheme was used without proof",
+			authorization: "DPoP some-token",
+			expectErr:     ErrInvalidToken,
+		},
+		{
+			d:              "should pass because the certificate matches the bound certificate",
+			authorization:  "Bearer some-token",
+			cert:           cert,
+			certThumbprint: CertificateThumbprint(cert),
+		},
+		{
+			d:              "should fail because the certificate-bound token was sent without certificate",
+			authorization:  "Bearer some-token",
+			certThumbprint: CertificateThumbprint(cert),
+			expectErr:      ErrInvalidToken,
+		},
+		{
+			d:              "should fail because a different certificate was presented",
+			authorization:  "Bearer some-token",
+			cert:           otherCert,
+			certThumbprint: CertificateThumbprint(cert),
+			expectErr:      ErrInvalidToken,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, c.d), func(t *testing.T) {
+			validator := internal.NewMockTokenIntrospector(ctrl)
+			f := &Fosite{TokenIntrospectionHandlers: TokenIntrospectionHandlers{validator}}
+			validator.EXPECT().IntrospectToken(gomock.Any(), "some-token", AccessToken, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, _ string, _ TokenUse, ar AccessRequester, _ []string) (TokenUse, error) {
+					session := ar.GetSession().(*DefaultSession)
+					session.SetDPoPKeyThumbprint(c.dpopThumbprint)
+					session.SetCertificateThumbprint(c.certThumbprint)
+					return AccessToken, nil
+				}).AnyTimes()
+
+			r, err := http.NewRequest("GET", "https://rs.example.org/resource", nil)
+			require.NoError(t, err)
+			if c.authorization != "" {
+				r.Header.Set("Authorization", c.authorization)
+			}
+			if c.dpopProof != "" {
+				r.Header.Set(DPoPHeader, c.dpopProof)
+			}
+			r.TLS = new(tls.ConnectionState)
+			if c.cert != nil {
+				r.TLS.PeerCertificates = []*x509.Certificate{c.cert}
+			}
+
+			_, err = f.ValidateToken(context.Background(), r, AccessToken, new(DefaultSession))
+			if c.expectErr != nil {
+				require.EqualError(t, err, c.expectErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}